@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// newIngress parses the annotations of an Ingress using r to resolve the
+// referenced Secrets, Services and ConfigMaps
+func newIngress(ing *networking.Ingress, r resolver.Resolver) (*Ingress, error) {
+	parsed, err := annotations.NewAnnotationExtractor(r).Extract(ing)
+	if err != nil {
+		return nil, err
+	}
+
+	anns := newAnnotationsIngress(parsed)
+	parseLocalAnnotations(ing, &anns)
+
+	return &Ingress{
+		Ingress:           *ing,
+		ParsedAnnotations: &anns,
+	}, nil
+}
+
+// newAnnotationsIngress copies the annotations parsed by ingress-nginx. The
+// annotations only supported by the validator are set by parseLocalAnnotations.
+func newAnnotationsIngress(parsed *annotations.Ingress) AnnotationsIngress {
+	return AnnotationsIngress{
+		ObjectMeta:                  parsed.ObjectMeta,
+		BackendProtocol:             parsed.BackendProtocol,
+		Aliases:                     parsed.Aliases,
+		BasicDigestAuth:             parsed.BasicDigestAuth,
+		Canary:                      parsed.Canary,
+		CertificateAuth:             parsed.CertificateAuth,
+		ClientBodyBufferSize:        parsed.ClientBodyBufferSize,
+		CustomHeaders:               parsed.CustomHeaders,
+		ConfigurationSnippet:        parsed.ConfigurationSnippet,
+		Connection:                  parsed.Connection,
+		CorsConfig:                  parsed.CorsConfig,
+		CustomHTTPErrors:            parsed.CustomHTTPErrors,
+		DisableProxyInterceptErrors: parsed.DisableProxyInterceptErrors,
+		DefaultBackend:              parsed.DefaultBackend,
+		FastCGI:                     parsed.FastCGI,
+		Denied:                      parsed.Denied,
+		ExternalAuth:                parsed.ExternalAuth,
+		EnableGlobalAuth:            parsed.EnableGlobalAuth,
+		HTTP2PushPreload:            parsed.HTTP2PushPreload,
+		Opentelemetry:               parsed.Opentelemetry,
+		Proxy:                       parsed.Proxy,
+		ProxySSL:                    parsed.ProxySSL,
+		RateLimit:                   parsed.RateLimit,
+		Redirect:                    parsed.Redirect,
+		Rewrite:                     parsed.Rewrite,
+		Satisfy:                     parsed.Satisfy,
+		ServerSnippet:               parsed.ServerSnippet,
+		ServiceUpstream:             parsed.ServiceUpstream,
+		SessionAffinity:             parsed.SessionAffinity,
+		SSLPassthrough:              parsed.SSLPassthrough,
+		UsePortInRedirects:          parsed.UsePortInRedirects,
+		UpstreamHashBy:              parsed.UpstreamHashBy,
+		LoadBalancing:               parsed.LoadBalancing,
+		UpstreamVhost:               parsed.UpstreamVhost,
+		Denylist:                    parsed.Denylist,
+		XForwardedPrefix:            parsed.XForwardedPrefix,
+		SSLCipher:                   parsed.SSLCipher,
+		Logs:                        parsed.Logs,
+		ModSecurity:                 parsed.ModSecurity,
+		Mirror:                      parsed.Mirror,
+		StreamSnippet:               parsed.StreamSnippet,
+		Allowlist:                   parsed.Allowlist,
+		Errors:                      parsed.Errors,
+	}
+}
+
+// parseLocalAnnotations sets the values of the annotations not supported by
+// the ingress-nginx annotation parsers
+func parseLocalAnnotations(ing *networking.Ingress, anns *AnnotationsIngress) {
+	// h2c is rejected by the backend-protocol parser, which falls back to HTTP
+	if bp, ok := ing.GetAnnotations()[parser.GetAnnotationWithPrefix("backend-protocol")]; ok &&
+		strings.EqualFold(strings.TrimSpace(bp), backendProtocolH2C) {
+		anns.BackendProtocol = backendProtocolH2C
+	}
+
+	anns.SubFilter = parseSubFilter(ing)
+}
+
+// checkAnnotationErrors reports the annotations ignored because of an
+// invalid value, and the Ingresses left out of the configuration because
+// their annotations can not be parsed
+func (n *NGINXController) checkAnnotationErrors(ingresses []*Ingress, _ *Configuration) []Finding {
+	findings := []Finding{}
+
+	for _, ing := range ingresses {
+		if ing.ParsedAnnotations == nil {
+			continue
+		}
+		for _, name := range sortedKeys(ing.ParsedAnnotations.Errors) {
+			findings = append(findings, Finding{
+				Rule:     "annotation-invalid",
+				Severity: SeverityWarning,
+				Ingress:  k8s.MetaNamespaceKey(ing),
+				Message:  fmt.Sprintf("%v; the annotation is ignored", ing.ParsedAnnotations.Errors[name]),
+			})
+		}
+	}
+
+	parseErrors := n.store.GetIngressParseErrors()
+	for _, key := range sortedKeys(parseErrors) {
+		findings = append(findings, Finding{
+			Rule:     "annotation-parse-error",
+			Severity: SeverityError,
+			Ingress:  key,
+			Message:  fmt.Sprintf("the annotations can not be parsed, the Ingress is left out of the configuration: %v", parseErrors[key]),
+		})
+	}
+
+	return findings
+}
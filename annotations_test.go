@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const annotationErrorsManifests = `
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+  namespace: default
+spec:
+  ports:
+  - port: 80
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: app
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/canary-weight: "10"
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: app.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: app
+            port:
+              number: 80
+`
+
+func TestCheckAnnotationErrors(t *testing.T) {
+	n := newTestController(t, annotationErrorsManifests)
+
+	_, findings := n.validate(n.store.ListIngresses())
+
+	invalid := findingsWithRule(findings, "annotation-invalid")
+	if len(invalid) != 1 {
+		t.Fatalf("expected 1 annotation-invalid finding, got %v", invalid)
+	}
+	if invalid[0].Ingress != "default/app" || !strings.Contains(invalid[0].Message, "canary") {
+		t.Errorf("unexpected finding %+v", invalid[0])
+	}
+}
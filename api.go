@@ -0,0 +1,390 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+const (
+	// apiValidatePath validates the objects of the body
+	apiValidatePath = "/v1/validate"
+	// apiReportPath returns the last validation of the cluster state
+	apiReportPath = "/v1/report"
+	// apiOpenAPIPath returns the OpenAPI specification of the API
+	apiOpenAPIPath = "/v1/openapi.json"
+
+	// apiRequestSource is the source of the objects of the requests
+	apiRequestSource = "request"
+	// maxAPIRequestSize limits the size of the body of the requests, each
+	// one being validated with a copy of the state of the cluster
+	maxAPIRequestSize = 8 << 20
+)
+
+// apiValidateResponse is the result of a validation requested to the API
+type apiValidateResponse struct {
+	// Valid is false when a finding is an error
+	Valid bool `json:"valid"`
+	// Findings are the findings of the Ingresses of the request
+	Findings []Finding `json:"findings"`
+}
+
+// apiError is the body of the failed requests
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// registerAPI adds the validation API to the mux of the webhook, the
+// endpoints being wrapped by the authentication of the webhook, and the
+// validations by its limiters. The API requires the authentication, as the
+// callers are restricted to the namespaces of their tokens.
+func (n *NGINXController) registerAPI(mux *http.ServeMux, auth *webhookAuth, limiter *validationLimiter, namespaceLimiter *namespaceRateLimiter) error {
+	if auth == nil {
+		return errors.New("--enable-api requires --webhook-client-ca or --webhook-token-file")
+	}
+
+	mux.Handle(apiValidatePath, auth.wrap(limiter.wrap(n.apiValidateHandler(namespaceLimiter))))
+	mux.Handle(apiReportPath, auth.wrap(http.HandlerFunc(n.apiReportHandler)))
+	mux.HandleFunc(apiOpenAPIPath, apiOpenAPIHandler)
+	return nil
+}
+
+// apiValidateHandler validates the manifests of the body, YAML or JSON, on
+// top of the state of the cluster
+func (n *NGINXController) apiValidateHandler(namespaceLimiter *namespaceRateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n.apiValidate(w, r, namespaceLimiter)
+	}
+}
+
+func (n *NGINXController) apiValidate(w http.ResponseWriter, r *http.Request, namespaceLimiter *namespaceRateLimiter) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	body := http.MaxBytesReader(w, r.Body, maxAPIRequestSize)
+
+	var findings []Finding
+	var err error
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json", "application/yaml", "application/x-yaml":
+		findings, err = n.apiValidateManifests(body, callerFromContext(r.Context()), namespaceLimiter)
+	default:
+		writeAPIError(w, http.StatusUnsupportedMediaType,
+			fmt.Errorf("unsupported content type %q, expected application/json or application/yaml manifests", mediaType))
+		return
+	}
+
+	var badRequest *apiBadRequestError
+	var forbidden *apiForbiddenError
+	var rateLimited *namespaceRateLimitError
+	switch {
+	case errors.As(err, &badRequest):
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	case errors.As(err, &forbidden):
+		writeAPIError(w, http.StatusForbidden, err)
+		return
+	case errors.As(err, &rateLimited):
+		admissionShedTotal.Add(shedReason(err), 1)
+		w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(namespaceLimiter.retryAfter().Seconds())))))
+		writeAPIError(w, http.StatusTooManyRequests, err)
+		return
+	case err != nil:
+		klog.Errorf("Error validating API request: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := &apiValidateResponse{Valid: true, Findings: findings}
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			resp.Valid = false
+		}
+	}
+	writeAPIResponse(w, http.StatusOK, resp)
+}
+
+// apiBadRequestError is returned for invalid request bodies
+type apiBadRequestError struct {
+	err error
+}
+
+func (e *apiBadRequestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *apiBadRequestError) Unwrap() error {
+	return e.err
+}
+
+// apiForbiddenError is returned for the Ingresses of namespaces the caller
+// is not allowed to validate
+type apiForbiddenError struct {
+	namespace string
+}
+
+func (e *apiForbiddenError) Error() string {
+	return fmt.Sprintf("the caller is not allowed to validate the Ingresses of namespace %v", e.namespace)
+}
+
+// apiValidateManifests validates the configuration obtained adding the
+// objects of the manifests to the ones of the cluster, and returns the
+// findings of the Ingresses of the manifests. The Ingresses must be in
+// namespaces of the caller, each namespace taking a token of the limiter.
+func (n *NGINXController) apiValidateManifests(body io.Reader, caller *webhookCaller, namespaceLimiter *namespaceRateLimiter) ([]Finding, error) {
+	s, err := n.requestStore(body)
+	if err != nil {
+		return nil, err
+	}
+
+	ingresses := s.ListIngresses()
+	requested := []*Ingress{}
+	for _, ing := range ingresses {
+		if !strings.HasPrefix(s.GetIngressSource(k8s.MetaNamespaceKey(ing)), apiRequestSource) {
+			continue
+		}
+		if !caller.allows(ing.Namespace) {
+			return nil, &apiForbiddenError{namespace: ing.Namespace}
+		}
+		requested = append(requested, ing)
+	}
+	if len(requested) == 0 {
+		return nil, &apiBadRequestError{err: errors.New("the request contains no Ingress")}
+	}
+	limited := map[string]bool{}
+	for _, ing := range requested {
+		if limited[ing.Namespace] {
+			continue
+		}
+		limited[ing.Namespace] = true
+		if err := namespaceLimiter.accept(ing.Namespace); err != nil {
+			return nil, err
+		}
+	}
+
+	// the settings of the webhook can be reloaded during the validation
+	n.configLock.RLock()
+	defer n.configLock.RUnlock()
+	_, all := newOfflineController(n.cfg, s).validate(ingresses)
+	return findingsForIngresses(all, requested), nil
+}
+
+// requestStore returns a copy of the state of the cluster with the objects of
+// the manifests of a request
+func (n *NGINXController) requestStore(body io.Reader) (*memoryStore, error) {
+	cluster, ok := n.store.(*memoryStore)
+	if !ok {
+		return nil, errors.New("the state of the cluster can not be copied")
+	}
+
+	s := cluster.clone()
+	if err := s.LoadManifest(body, apiRequestSource); err != nil {
+		return nil, &apiBadRequestError{err: err}
+	}
+	return s, nil
+}
+
+// apiReportHandler returns the last validation of the Ingresses of the
+// cluster, restricted to the namespaces of the caller
+func (n *NGINXController) apiReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	n.preValidationLock.RLock()
+	result := n.preValidation
+	n.preValidationLock.RUnlock()
+
+	if result == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, errors.New("the Ingresses of the cluster were not validated yet"))
+		return
+	}
+	writeAPIResponse(w, http.StatusOK, n.callerReport(result, callerFromContext(r.Context())))
+}
+
+// callerReport returns the part of the validation of the cluster the caller
+// can read: the findings of the Ingresses of its namespaces, the global
+// findings being only returned to the callers allowed every namespace
+func (n *NGINXController) callerReport(result *PreValidationResult, caller *webhookCaller) *PreValidationResult {
+	if caller.allowsAll() {
+		return result
+	}
+
+	scoped := &PreValidationResult{Time: result.Time, Valid: true, Findings: []Finding{}}
+	for _, ing := range n.store.ListIngresses() {
+		if caller.allows(ing.Namespace) {
+			scoped.Ingresses++
+		}
+	}
+	for _, f := range result.Findings {
+		namespace, _, _ := strings.Cut(f.Ingress, "/")
+		if f.Ingress == "" || !caller.allows(namespace) {
+			continue
+		}
+		scoped.Findings = append(scoped.Findings, f)
+		if f.Severity == SeverityError {
+			scoped.Valid = false
+		}
+	}
+	return scoped
+}
+
+func writeAPIResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.Errorf("Error writing API response: %v", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeAPIResponse(w, status, &apiError{Error: err.Error()})
+}
+
+// apiOpenAPIHandler returns the OpenAPI specification of the API
+func apiOpenAPIHandler(w http.ResponseWriter, _ *http.Request) {
+	writeAPIResponse(w, http.StatusOK, apiOpenAPISpec())
+}
+
+var (
+	goTimeType   = reflect.TypeOf(time.Time{})
+	severityType = reflect.TypeOf(Severity(""))
+)
+
+// apiOpenAPISpec generates the OpenAPI 3 specification of the API, the
+// schemas being generated from the types of the responses
+func apiOpenAPISpec() map[string]interface{} {
+	schemas := map[string]interface{}{}
+	ref := func(v interface{}) map[string]interface{} {
+		return openAPISchema(reflect.TypeOf(v), schemas)
+	}
+	jsonContent := func(schema map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+	}
+	response := func(description string, schema map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"description": description, "content": jsonContent(schema)}
+	}
+	errorSchema := ref(apiError{})
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "nginx-config-validator",
+			"version": "v1",
+		},
+		"paths": map[string]interface{}{
+			apiValidatePath: map[string]interface{}{
+				"post": map[string]interface{}{
+					"summary": "Validate Kubernetes manifests on top of the state of the cluster",
+					"requestBody": map[string]interface{}{
+						"required": true,
+						"content": map[string]interface{}{
+							"application/json": map[string]interface{}{"schema": map[string]interface{}{"type": "object"}},
+							"application/yaml": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+						},
+					},
+					"responses": map[string]interface{}{
+						"200": response("Findings of the Ingresses of the request", ref(apiValidateResponse{})),
+						"400": response("Invalid manifests", errorSchema),
+						"403": response("Ingresses of namespaces the caller is not allowed to validate", errorSchema),
+						"429": response("The webhook is overloaded or a namespace exceeded its rate of validations", errorSchema),
+						"415": response("Unsupported content type", errorSchema),
+					},
+				},
+			},
+			apiReportPath: map[string]interface{}{
+				"get": map[string]interface{}{
+					"summary": "Last validation of the Ingresses of the namespaces of the caller",
+					"responses": map[string]interface{}{
+						"200": response("Result of the validation", ref(PreValidationResult{})),
+						"503": response("The cluster was not validated yet", errorSchema),
+					},
+				},
+			},
+		},
+		"components": map[string]interface{}{
+			"schemas": schemas,
+		},
+	}
+}
+
+// openAPISchema returns the schema of t, the structs being added to schemas
+// and referenced
+func openAPISchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case goTimeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case severityType:
+		return map[string]interface{}{"type": "string", "enum": []Severity{SeverityError, SeverityWarning, SeverityInfo}}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		name := strings.TrimPrefix(t.Name(), "api")
+		name = strings.ToUpper(name[:1]) + name[1:]
+		if _, ok := schemas[name]; !ok {
+			// registered first for the recursive types
+			schemas[name] = nil
+			properties := map[string]interface{}{}
+			required := []string{}
+			for i := 0; i < t.NumField(); i++ {
+				f := t.Field(i)
+				tag := strings.Split(f.Tag.Get("json"), ",")
+				if !f.IsExported() || tag[0] == "-" || tag[0] == "" {
+					continue
+				}
+				properties[tag[0]] = openAPISchema(f.Type, schemas)
+				if len(tag) == 1 || tag[1] != "omitempty" {
+					required = append(required, tag[0])
+				}
+			}
+			schemas[name] = map[string]interface{}{"type": "object", "properties": properties, "required": required}
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": openAPISchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": openAPISchema(t.Elem(), schemas)}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	}
+	return map[string]interface{}{}
+}
+
+// runOpenAPI prints the OpenAPI specification of the validation API
+func runOpenAPI(_ []string, stdout, stderr io.Writer) int {
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(apiOpenAPISpec()); err != nil {
+		fmt.Fprintf(stderr, "error writing OpenAPI specification: %v\n", err)
+		return exitInternal
+	}
+	return exitOK
+}
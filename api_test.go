@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIValidateHandler(t *testing.T) {
+	// an Ingress of the cluster with the same error as the request
+	cluster := strings.ReplaceAll(preValidationManifests, "h2c", "cluster")
+
+	testCases := map[string]struct {
+		method      string
+		contentType string
+		body        string
+		expected    int
+		valid       bool
+		rules       []string
+		err         string
+	}{
+		"YAML manifests": {
+			contentType: "application/yaml",
+			body:        preValidationManifests,
+			expected:    http.StatusOK,
+			rules:       []string{"h2c-nginx-version"},
+		},
+		"JSON manifest": {
+			contentType: "application/json; charset=utf-8",
+			body: `{"apiVersion": "networking.k8s.io/v1", "kind": "Ingress", "metadata": {"name": "web", "namespace": "default"},
+"spec": {"ingressClassName": "nginx", "rules": [{"host": "web.example.com", "http": {"paths": [
+{"path": "/", "pathType": "Prefix", "backend": {"service": {"name": "web", "port": {"number": 80}}}}]}}]}}`,
+			expected: http.StatusOK,
+			valid:    true,
+		},
+		"no Ingress": {
+			contentType: "application/yaml",
+			body:        "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n  namespace: default\n",
+			expected:    http.StatusBadRequest,
+			err:         "the request contains no Ingress",
+		},
+		"invalid manifest": {
+			contentType: "application/yaml",
+			body:        "kind: [Ingress\n",
+			expected:    http.StatusBadRequest,
+		},
+		"unsupported content type": {
+			contentType: "application/xml",
+			body:        "<Ingress/>",
+			expected:    http.StatusUnsupportedMediaType,
+			err:         `unsupported content type "application/xml"`,
+		},
+		"GET": {
+			method:   http.MethodGet,
+			expected: http.StatusMethodNotAllowed,
+			err:      "method not allowed",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n := newTestController(t, cluster)
+			n.cfg.NginxVersion = "1.13.9"
+
+			method := tc.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, apiValidatePath, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			rec := httptest.NewRecorder()
+			n.apiValidateHandler(nil)(rec, req)
+
+			if rec.Code != tc.expected {
+				t.Fatalf("expected status %v, got %v: %v", tc.expected, rec.Code, rec.Body.String())
+			}
+			if rec.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("expected a JSON response, got %q", rec.Header().Get("Content-Type"))
+			}
+			if tc.expected != http.StatusOK {
+				apiErr := &apiError{}
+				if err := json.Unmarshal(rec.Body.Bytes(), apiErr); err != nil || !strings.Contains(apiErr.Error, tc.err) {
+					t.Errorf("expected an error containing %q, got %q", tc.err, rec.Body.String())
+				}
+				return
+			}
+
+			resp := &apiValidateResponse{}
+			if err := json.Unmarshal(rec.Body.Bytes(), resp); err != nil {
+				t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+			}
+			rules := []string{}
+			for _, f := range resp.Findings {
+				if f.Ingress == "default/cluster" {
+					t.Errorf("expected only the findings of the request, got %+v", f)
+				}
+				if f.Severity == SeverityError {
+					rules = append(rules, f.Rule)
+				}
+			}
+			if resp.Valid != tc.valid || strings.Join(rules, ",") != strings.Join(tc.rules, ",") {
+				t.Errorf("expected valid %v with the errors %v, got %v with %v", tc.valid, tc.rules, resp.Valid, rules)
+			}
+
+			// the objects of the request are not added to the cluster
+			if len(n.store.ListIngresses()) != 1 {
+				t.Errorf("expected the Ingresses of the cluster unchanged, got %v", len(n.store.ListIngresses()))
+			}
+		})
+	}
+}
+
+func TestAPIReportHandler(t *testing.T) {
+	n := newTestController(t, preValidationManifests)
+	report := func(method string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		n.apiReportHandler(rec, httptest.NewRequest(method, apiReportPath, nil))
+		return rec
+	}
+
+	if rec := report(http.MethodGet); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected no report before the pre-validation, got %v", rec.Code)
+	}
+
+	n.preValidationLock.Lock()
+	n.preValidation = &PreValidationResult{Ingresses: 1, Valid: true}
+	n.preValidationLock.Unlock()
+	rec := report(http.MethodGet)
+	result := &PreValidationResult{}
+	if err := json.Unmarshal(rec.Body.Bytes(), result); rec.Code != http.StatusOK || err != nil || !result.Valid || result.Ingresses != 1 {
+		t.Errorf("expected the last validation, got %v %q", rec.Code, rec.Body.String())
+	}
+
+	if rec := report(http.MethodPost); rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != http.MethodGet {
+		t.Errorf("expected POST to be rejected, got %v", rec.Code)
+	}
+}
+
+func TestRegisterAPI(t *testing.T) {
+	n := newTestController(t, "")
+	if err := n.registerAPI(http.NewServeMux(), nil, nil, nil); err == nil {
+		t.Errorf("expected the API to be refused without authentication")
+	}
+
+	mux := http.NewServeMux()
+	auth := &webhookAuth{allowedCNs: map[string]bool{}, tokens: []webhookToken{{value: []byte("token"), caller: &webhookCaller{}}}}
+	if err := n.registerAPI(mux, auth, newValidationLimiter(1, 0, time.Second), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for path, expected := range map[string]int{
+		apiValidatePath: http.StatusUnauthorized,
+		apiReportPath:   http.StatusUnauthorized,
+		// the specification is public
+		apiOpenAPIPath: http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != expected {
+			t.Errorf("expected status %v for %v, got %v", expected, path, rec.Code)
+		}
+	}
+}
+
+func TestAPIOpenAPISpec(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"openapi"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected exit code %v, got %v (%v)", exitOK, code, stderr.String())
+	}
+
+	var spec struct {
+		OpenAPI    string                     `json:"openapi"`
+		Paths      map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Type       string                     `json:"type"`
+				Properties map[string]json.RawMessage `json:"properties"`
+				Required   []string                   `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	// the properties are compared without the indentation
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, stdout.Bytes()); err != nil {
+		t.Fatalf("invalid specification: %v", err)
+	}
+	if err := json.Unmarshal(compact.Bytes(), &spec); err != nil {
+		t.Fatalf("invalid specification: %v", err)
+	}
+	if spec.OpenAPI != "3.0.3" || spec.Paths[apiValidatePath] == nil || spec.Paths[apiReportPath] == nil {
+		t.Errorf("unexpected specification %v with paths %v", spec.OpenAPI, spec.Paths)
+	}
+
+	validate, ok := spec.Components.Schemas["ValidateResponse"]
+	if !ok || strings.Join(validate.Required, ",") != "valid,findings" {
+		t.Errorf("expected the schema of the validate response, got %+v", validate)
+	}
+	if string(validate.Properties["findings"]) != `{"items":{"$ref":"#/components/schemas/Finding"},"type":"array"}` {
+		t.Errorf("expected the findings to reference the Finding schema, got %s", validate.Properties["findings"])
+	}
+	finding, ok := spec.Components.Schemas["Finding"]
+	if !ok {
+		t.Fatalf("expected a schema for Finding, got %v", spec.Components.Schemas)
+	}
+	if got := string(finding.Properties["severity"]); got != `{"enum":["error","warning","info"],"type":"string"}` {
+		t.Errorf("expected the severities as an enum, got %s", got)
+	}
+	if got := string(spec.Components.Schemas["PreValidationResult"].Properties["time"]); got != `{"format":"date-time","type":"string"}` {
+		t.Errorf("expected the time as a date-time, got %s", got)
+	}
+	if _, ok := spec.Components.Schemas["Error"]; !ok {
+		t.Errorf("expected a schema for the errors, got %v", spec.Components.Schemas)
+	}
+}
+
+const apiIngressManifest = `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: app
+  namespace: %v
+spec:
+  rules:
+  - host: %v.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: app
+            port:
+              number: 80
+`
+
+// newAPITestServer returns the API of a controller whose token file allows
+// the token "team-a" in the team-a namespace and the token "admin" in all
+func newAPITestServer(t *testing.T) (*NGINXController, http.Handler) {
+	t.Helper()
+
+	tokenFile := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(tokenFile, []byte("team-a team-a\nadmin\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	n := newTestController(t, "")
+	n.cfg.WebhookTokenFile = tokenFile
+	auth, err := newWebhookAuth(n.cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	if err := n.registerAPI(mux, auth, newValidationLimiter(1, 0, time.Second), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return n, mux
+}
+
+func TestAPIValidateCallerNamespaces(t *testing.T) {
+	_, handler := newAPITestServer(t)
+
+	tests := []struct {
+		name        string
+		token       string
+		contentType string
+		namespace   string
+		status      int
+	}{
+		{name: "namespace of the token", token: "team-a", contentType: "application/yaml", namespace: "team-a", status: http.StatusOK},
+		{name: "other namespace", token: "team-a", contentType: "application/yaml", namespace: "team-b", status: http.StatusForbidden},
+		{name: "token of all namespaces", token: "admin", contentType: "application/yaml", namespace: "team-b", status: http.StatusOK},
+		{name: "nginx.conf", token: "admin", contentType: "text/plain", namespace: "team-a", status: http.StatusUnsupportedMediaType},
+		{name: "without token", contentType: "application/yaml", namespace: "team-a", status: http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body := strings.NewReader(strings.ReplaceAll(apiIngressManifest, "%v", tc.namespace))
+			req := httptest.NewRequest(http.MethodPost, apiValidatePath, body)
+			req.Header.Set("Content-Type", tc.contentType)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tc.status {
+				t.Errorf("expected %d, got %d: %v", tc.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAPIReportScope(t *testing.T) {
+	n, handler := newAPITestServer(t)
+	n.preValidation = &PreValidationResult{
+		Valid: false,
+		Findings: []Finding{
+			{Rule: "rule", Severity: SeverityError, Ingress: "team-a/app"},
+			{Rule: "rule", Severity: SeverityError, Ingress: "team-b/app"},
+			{Rule: "global", Severity: SeverityError},
+		},
+	}
+
+	for token, expected := range map[string]int{"team-a": 1, "admin": 3} {
+		req := httptest.NewRequest(http.MethodGet, apiReportPath, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		result := &PreValidationResult{}
+		if err := json.Unmarshal(rec.Body.Bytes(), result); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(result.Findings) != expected {
+			t.Errorf("%v: expected %d findings, got %v", token, expected, result.Findings)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+const (
+	// argoCDTrackingAnnotation is the annotation ArgoCD tracks the resources
+	// of an application with, <app>:<group>/<kind>:<namespace>/<name>
+	argoCDTrackingAnnotation = "argocd.argoproj.io/tracking-id"
+	// defaultArgoCDTrackingLabel is the label ArgoCD tracks the resources of
+	// an application with by default
+	defaultArgoCDTrackingLabel = "app.kubernetes.io/instance"
+	// argoCDAppEnv is the environment variable set by ArgoCD with the name of
+	// the application
+	argoCDAppEnv = "ARGOCD_APP_NAME"
+
+	// defaultTerminationLog is the file Kubernetes reads the termination
+	// message of a container from, shown by ArgoCD when a hook fails
+	defaultTerminationLog = "/dev/termination-log"
+	// maxTerminationMessage is the size of the termination messages kept by
+	// Kubernetes
+	maxTerminationMessage = 4096
+)
+
+// argoCDTracked returns true when the Ingress belongs to the application,
+// by its tracking annotation or label
+func argoCDTracked(ing *Ingress, app, label string) bool {
+	if id, ok := ing.Annotations[argoCDTrackingAnnotation]; ok {
+		return strings.HasPrefix(id, app+":")
+	}
+	return ing.Labels[label] == app
+}
+
+// argoCDMessage summarizes the findings for the termination message of the
+// hook, the errors first, truncated to the size kept by Kubernetes
+func argoCDMessage(app string, findings []Finding) string {
+	counts := map[Severity]int{}
+	for _, f := range findings {
+		counts[f.Severity]++
+	}
+
+	b := &strings.Builder{}
+	if app != "" {
+		fmt.Fprintf(b, "application %v: ", app)
+	}
+	fmt.Fprintf(b, "%d errors, %d warnings\n", counts[SeverityError], counts[SeverityWarning])
+	for _, severity := range []Severity{SeverityError, SeverityWarning} {
+		for _, f := range findings {
+			if f.Severity == severity {
+				fmt.Fprintf(b, "%v %v: %v\n", f.Severity, f.Ingress, f.Message)
+			}
+		}
+	}
+
+	message := b.String()
+	if len(message) > maxTerminationMessage {
+		message = message[:maxTerminationMessage-len("...\n")] + "...\n"
+	}
+	return message
+}
+
+// writeTerminationMessage writes the message to the termination log of the
+// container. Nothing is written outside of Kubernetes, where the file does
+// not exist.
+func writeTerminationMessage(path, message string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, message); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// runArgoCD validates the manifests of the target revision of an ArgoCD
+// application against the live state of the cluster, as a PreSync hook. The
+// findings of the Ingresses of the revision, and of the live Ingresses of
+// the application, are printed and written to the termination message, and
+// the hook fails when there are errors so the sync does.
+func runArgoCD(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("argocd", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	cfg := &NginxConfiguration{}
+	addConfigurationFlags(fs, cfg)
+
+	in := &validateInput{}
+	addInputFlags(fs, in, cfg)
+	app := fs.String("argocd-app", os.Getenv(argoCDAppEnv),
+		"Name of the ArgoCD application, whose live Ingresses are validated with the manifests. Defaults to $"+argoCDAppEnv+".")
+	trackingLabel := fs.String("argocd-tracking-label", defaultArgoCDTrackingLabel,
+		"Label ArgoCD tracks the resources of the applications with, used when they have no "+argoCDTrackingAnnotation+" annotation.")
+	prune := fs.Bool("prune", false, "Validate as if the sync prunes the live Ingresses of the application missing from the manifests.")
+	terminationLog := fs.String("termination-log", defaultTerminationLog,
+		"File of the termination message of the container, shown by ArgoCD as the message of the failed hook. Empty disables it.")
+	strict := fs.Bool("strict", false, "Fail the hook on warnings.")
+	addWorkDirFlags(fs, cfg)
+	addSecretStorageFlags(fs, cfg)
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitInternal
+	}
+
+	if *prune && *app == "" {
+		fmt.Fprintln(stderr, "--prune requires --argocd-app")
+		return exitInternal
+	}
+
+	// the revision is validated against the live state
+	in.againstCluster = true
+	s, err := in.load(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitInternal
+	}
+
+	var reported []*Ingress
+	for _, ing := range s.ListIngresses() {
+		key := k8s.MetaNamespaceKey(ing)
+		// the Ingresses of the cluster have no source
+		fromRevision := s.GetIngressSource(key) != ""
+		tracked := *app != "" && argoCDTracked(ing, *app, *trackingLabel)
+		switch {
+		case fromRevision:
+			reported = append(reported, ing)
+		case tracked && *prune:
+			s.removeIngress(key)
+		case tracked:
+			reported = append(reported, ing)
+		}
+	}
+
+	_, all := newOfflineController(cfg, s).validate(s.ListIngresses())
+	findings := findingsForIngresses(all, reported)
+
+	if err := writeFindings(stdout, "text", findings); err != nil {
+		fmt.Fprintf(stderr, "error writing findings: %v\n", err)
+		return exitInternal
+	}
+	if *terminationLog != "" {
+		if err := writeTerminationMessage(*terminationLog, argoCDMessage(*app, findings)); err != nil {
+			fmt.Fprintf(stderr, "error writing termination message: %v\n", err)
+		}
+	}
+
+	failOn := failOnError
+	if *strict {
+		failOn = failOnWarning
+	}
+	return findingsExitCode(findings, failOn)
+}
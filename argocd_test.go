@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestArgoCDTracked(t *testing.T) {
+	testCases := map[string]struct {
+		annotations map[string]string
+		labels      map[string]string
+		expected    bool
+	}{
+		"tracking annotation": {
+			annotations: map[string]string{argoCDTrackingAnnotation: "web:networking.k8s.io/Ingress:default/web"},
+			expected:    true,
+		},
+		"annotation of another application": {
+			annotations: map[string]string{argoCDTrackingAnnotation: "web-staging:networking.k8s.io/Ingress:default/web"},
+			// the label is ignored when the annotation is set
+			labels: map[string]string{defaultArgoCDTrackingLabel: "web"},
+		},
+		"tracking label": {
+			labels:   map[string]string{defaultArgoCDTrackingLabel: "web"},
+			expected: true,
+		},
+		"not tracked": {},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ing := &Ingress{}
+			ing.ObjectMeta = metav1.ObjectMeta{Annotations: tc.annotations, Labels: tc.labels}
+			if got := argoCDTracked(ing, "web", defaultArgoCDTrackingLabel); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestArgoCDMessage(t *testing.T) {
+	findings := []Finding{
+		{Severity: SeverityWarning, Ingress: "default/web", Message: "permissive CORS"},
+		{Severity: SeverityInfo, Ingress: "default/web", Message: "not reported"},
+		{Severity: SeverityError, Ingress: "default/api", Message: "invalid certificate"},
+	}
+	expected := `application web: 1 errors, 1 warnings
+error default/api: invalid certificate
+warning default/web: permissive CORS
+`
+	if got := argoCDMessage("web", findings); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if got := argoCDMessage("", nil); got != "0 errors, 0 warnings\n" {
+		t.Errorf("unexpected message without application %q", got)
+	}
+
+	long := []Finding{{Severity: SeverityError, Ingress: "default/web", Message: strings.Repeat("x", 2*maxTerminationMessage)}}
+	got := argoCDMessage("web", long)
+	if len(got) != maxTerminationMessage || !strings.HasSuffix(got, "...\n") {
+		t.Errorf("expected the message truncated to %v bytes, got %v bytes ending with %q", maxTerminationMessage, len(got), got[len(got)-10:])
+	}
+}
+
+func TestWriteTerminationMessage(t *testing.T) {
+	dir := t.TempDir()
+	if err := writeTerminationMessage(filepath.Join(dir, "missing"), "message"); err != nil {
+		t.Errorf("expected nothing to be written outside of Kubernetes, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("expected the termination log not to be created, got %v", err)
+	}
+
+	path := writeTestFile(t, dir, "termination-log", "previous message")
+	if err := writeTerminationMessage(path, "message"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "message" {
+		t.Errorf("expected the termination log to be replaced, got %q", data)
+	}
+}
+
+func TestFindingsForIngresses(t *testing.T) {
+	n := newTestController(t, isolationManifests)
+	web, api := testIngress(t, n, "default/web"), testIngress(t, n, "default/api")
+	findings := []Finding{
+		{Rule: "b", Ingress: "default/web"},
+		{Rule: "a", Ingress: "default/api"},
+		{Rule: "c", Ingress: "default/other"},
+	}
+
+	got := findingsForIngresses(findings, []*Ingress{web, api, web})
+	rules := []string{}
+	for _, f := range got {
+		rules = append(rules, f.Ingress+" "+f.Rule)
+	}
+	if strings.Join(rules, ",") != "default/api a,default/web b" {
+		t.Errorf("expected the findings of default/api and default/web once, got %v", rules)
+	}
+	if got := findingsForIngresses(findings, nil); got == nil || len(got) != 0 {
+		t.Errorf("expected no findings, got %v", got)
+	}
+}
+
+func TestMemoryStoreRemoveIngress(t *testing.T) {
+	s := newMemoryStore("")
+	if err := s.LoadManifest(strings.NewReader(isolationManifests), "test"); err != nil {
+		t.Fatal(err)
+	}
+	s.removeIngress("default/api")
+
+	ingresses := s.ListIngresses()
+	if len(ingresses) != 1 || ingresses[0].Name != "web" {
+		t.Errorf("expected only default/web, got %v", ingresses)
+	}
+	if source := s.GetIngressSource("default/api"); source != "" {
+		t.Errorf("expected the source to be removed, got %q", source)
+	}
+}
+
+func TestRunArgoCD(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	t.Setenv(argoCDAppEnv, "")
+	if code := runCLI([]string{"argocd", "--prune", "-f", "ingress.yaml"}, &stdout, &stderr); code != exitInternal {
+		t.Errorf("expected exit code %v, got %v", exitInternal, code)
+	}
+	if !strings.Contains(stderr.String(), "--prune requires --argocd-app") {
+		t.Errorf("expected --prune to require the application, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+const (
+	// auditPath serves the report of the last audit of the cluster state
+	auditPath = "/audit"
+	// defaultAuditCertificatePeriod is the period in which the expiring
+	// certificates are listed by the audits
+	defaultAuditCertificatePeriod = 30 * 24 * time.Hour
+	// defaultAuditMaxReports keeps a week of hourly reports
+	defaultAuditMaxReports = 168
+)
+
+var (
+	auditsTotal                   = expvar.NewInt("audits_total")
+	auditLastTimestampSeconds     = expvar.NewInt("audit_last_timestamp_seconds")
+	auditLastDurationSeconds      = expvar.NewFloat("audit_last_duration_seconds")
+	auditLastFindings             = expvar.NewInt("audit_last_findings")
+	auditLastNewFindings          = expvar.NewInt("audit_last_new_findings")
+	auditLastDrift                = expvar.NewInt("audit_last_drift")
+	auditLastExpiringCertificates = expvar.NewInt("audit_last_expiring_certificates")
+	auditLastConfigDrift          = expvar.NewInt("audit_last_config_drift")
+)
+
+// AuditReport is the result of an audit of the cluster state, compared with
+// the previous audit
+type AuditReport struct {
+	Time      time.Time `json:"time"`
+	Ingresses int       `json:"ingresses"`
+	Findings  []Finding `json:"findings"`
+	// NewFindings are the findings absent from the previous audit
+	NewFindings []Finding `json:"newFindings"`
+	// ResolvedFindings are the findings of the previous audit that are gone
+	ResolvedFindings []Finding `json:"resolvedFindings"`
+	// Drift lists the Ingresses created, changed or deleted since the
+	// previous audit
+	Drift []AuditDrift `json:"drift"`
+	// ExpiringCertificates are the certificates of the servers expiring
+	// within AuditCertificatePeriod
+	ExpiringCertificates []AuditCertificate `json:"expiringCertificates"`
+	// ConfigDrift lists the differences between the nginx.conf served by the
+	// ingress controller and the configuration of the Ingresses, empty when
+	// RunningConfig is not set
+	ConfigDrift []Finding `json:"configDrift"`
+
+	// checksums of the Ingresses, compared by the next audit
+	checksums map[string]string
+}
+
+// AuditDrift is an Ingress changed between two audits
+type AuditDrift struct {
+	Ingress string `json:"ingress"`
+	// Change is created, changed or deleted
+	Change string `json:"change"`
+}
+
+// AuditCertificate is a certificate of a server expiring soon
+type AuditCertificate struct {
+	Host    string    `json:"host"`
+	Secret  string    `json:"secret"`
+	Expires time.Time `json:"expires"`
+}
+
+// audit validates the cluster state independently of the admission
+// requests and compares the result with the previous audit
+func (n *NGINXController) audit() *AuditReport {
+	start := time.Now()
+	ingresses := n.store.ListIngresses()
+	cfg, findings := n.validate(ingresses)
+
+	report := &AuditReport{
+		Time:                 start.UTC(),
+		Ingresses:            len(ingresses),
+		Findings:             []Finding{},
+		NewFindings:          []Finding{},
+		ResolvedFindings:     []Finding{},
+		Drift:                []AuditDrift{},
+		ExpiringCertificates: []AuditCertificate{},
+		ConfigDrift:          []Finding{},
+		checksums:            map[string]string{},
+	}
+	for _, f := range findings {
+		report.Findings = append(report.Findings, f.withoutSource())
+	}
+	for _, ing := range ingresses {
+		report.checksums[k8s.MetaNamespaceKey(&ing.Ingress)] = ingressChecksum(&ing.Ingress)
+	}
+
+	if cfg != nil {
+		for _, server := range cfg.Servers {
+			cert := server.SSLCert
+			if server.Hostname == "_" || cert == nil || cert.ExpireTime.IsZero() || cert.ExpireTime.Sub(start) > n.cfg.AuditCertificatePeriod {
+				continue
+			}
+			report.ExpiringCertificates = append(report.ExpiringCertificates, AuditCertificate{
+				Host:    server.Hostname,
+				Secret:  fmt.Sprintf("%v/%v", cert.Namespace, cert.Name),
+				Expires: cert.ExpireTime.UTC(),
+			})
+		}
+	}
+
+	if cfg != nil && n.cfg.RunningConfig != "" {
+		running, err := readRunningConfig(context.Background(), n.cfg.RunningConfig)
+		if err == nil {
+			report.ConfigDrift, err = configDrift(running, cfg)
+		}
+		if err != nil {
+			klog.Warningf("Error comparing the running configuration %v: %v", n.cfg.RunningConfig, err)
+			report.ConfigDrift = []Finding{}
+		}
+	}
+
+	n.lastAuditLock.Lock()
+	previous := n.lastAudit
+	if previous != nil {
+		report.compare(previous)
+	}
+	n.lastAudit = report
+	n.lastAuditLock.Unlock()
+
+	// a reload in progress looks like a drift, only the differences found by
+	// two audits in a row are notified
+	if n.leading() && previous != nil {
+		n.notifications.configDrifted(persistentDrift(previous.ConfigDrift, report.ConfigDrift))
+	}
+
+	auditsTotal.Add(1)
+	auditLastTimestampSeconds.Set(start.Unix())
+	auditLastDurationSeconds.Set(time.Since(start).Seconds())
+	auditLastFindings.Set(int64(len(report.Findings)))
+	auditLastNewFindings.Set(int64(len(report.NewFindings)))
+	auditLastDrift.Set(int64(len(report.Drift)))
+	auditLastExpiringCertificates.Set(int64(len(report.ExpiringCertificates)))
+	auditLastConfigDrift.Set(int64(len(report.ConfigDrift)))
+
+	klog.Infof("Audited %d Ingresses: %d findings, %d new, %d resolved, %d Ingresses changed, %d certificates expiring, %d differences with the running configuration",
+		report.Ingresses, len(report.Findings), len(report.NewFindings), len(report.ResolvedFindings),
+		len(report.Drift), len(report.ExpiringCertificates), len(report.ConfigDrift))
+	return report
+}
+
+// persistentDrift returns the differences with the running configuration
+// found by both audits
+func persistentDrift(previous, current []Finding) []Finding {
+	seen := map[Finding]bool{}
+	for _, f := range previous {
+		seen[f] = true
+	}
+	persistent := []Finding{}
+	for _, f := range current {
+		if seen[f] {
+			persistent = append(persistent, f)
+		}
+	}
+	return persistent
+}
+
+// compare sets the differences with the previous audit
+func (r *AuditReport) compare(previous *AuditReport) {
+	seen := map[Finding]bool{}
+	for _, f := range previous.Findings {
+		seen[f] = true
+	}
+	current := map[Finding]bool{}
+	for _, f := range r.Findings {
+		current[f] = true
+		if !seen[f] {
+			r.NewFindings = append(r.NewFindings, f)
+		}
+	}
+	for _, f := range previous.Findings {
+		if !current[f] {
+			r.ResolvedFindings = append(r.ResolvedFindings, f)
+		}
+	}
+
+	for key, checksum := range r.checksums {
+		switch old, ok := previous.checksums[key]; {
+		case !ok:
+			r.Drift = append(r.Drift, AuditDrift{Ingress: key, Change: "created"})
+		case old != checksum:
+			r.Drift = append(r.Drift, AuditDrift{Ingress: key, Change: "changed"})
+		}
+	}
+	for key := range previous.checksums {
+		if _, ok := r.checksums[key]; !ok {
+			r.Drift = append(r.Drift, AuditDrift{Ingress: key, Change: "deleted"})
+		}
+	}
+	sort.Slice(r.Drift, func(i, j int) bool { return r.Drift[i].Ingress < r.Drift[j].Ingress })
+}
+
+// write stores the report in the directory, in a file named after the time
+// of the audit, and removes the oldest reports beyond maxReports
+func (r *AuditReport) write(directory string, maxReports int) error {
+	raw, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("audit-%v.json", r.Time.Format("20060102T150405Z"))
+	if err := os.WriteFile(filepath.Join(directory, name), raw, 0o644); err != nil {
+		return err
+	}
+	return pruneAuditReports(directory, maxReports)
+}
+
+// pruneAuditReports removes the oldest reports of the directory beyond
+// maxReports, 0 keeping them all. The names of the reports sort by time.
+func pruneAuditReports(directory string, maxReports int) error {
+	if maxReports <= 0 {
+		return nil
+	}
+
+	reports, err := filepath.Glob(filepath.Join(directory, "audit-*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(reports)
+	var errs []error
+	for len(reports) > maxReports {
+		if err := os.Remove(reports[0]); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+		reports = reports[1:]
+	}
+	return errors.Join(errs...)
+}
+
+// runAudits audits the cluster state now and every AuditInterval until the
+// stop channel is closed. The leader writes the reports to AuditDirectory.
+func (n *NGINXController) runAudits() {
+	ticker := time.NewTicker(n.cfg.AuditInterval)
+	defer ticker.Stop()
+
+	for {
+		report := n.audit()
+		if n.leading() && n.cfg.AuditDirectory != "" {
+			if err := report.write(n.cfg.AuditDirectory, n.cfg.AuditMaxReports); err != nil {
+				klog.Errorf("Error writing audit report: %v", err)
+			}
+		}
+
+		select {
+		case <-n.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// auditHandler returns the report of the last audit. The report covers every
+// namespace, so only the callers allowed all of them can read it.
+func (n *NGINXController) auditHandler(w http.ResponseWriter, r *http.Request) {
+	if !callerFromContext(r.Context()).allowsAll() {
+		http.Error(w, "the audit report covers every namespace", http.StatusForbidden)
+		return
+	}
+
+	n.lastAuditLock.RLock()
+	report := n.lastAudit
+	n.lastAuditLock.RUnlock()
+
+	if report == nil {
+		http.Error(w, "no audit yet", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		klog.Errorf("Error writing audit report: %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAuditReportCompare(t *testing.T) {
+	kept := Finding{Rule: "cors-permissive", Ingress: "default/web"}
+	resolved := Finding{Rule: "tls-policy", Ingress: "default/api"}
+	added := Finding{Rule: "snippet", Ingress: "default/web"}
+
+	previous := &AuditReport{
+		Findings:  []Finding{kept, resolved},
+		checksums: map[string]string{"default/api": "a", "default/web": "w", "default/old": "o"},
+	}
+	r := &AuditReport{
+		Findings:  []Finding{kept, added},
+		checksums: map[string]string{"default/api": "a", "default/web": "w2", "default/new": "n"},
+	}
+	r.compare(previous)
+
+	if !reflect.DeepEqual(r.NewFindings, []Finding{added}) {
+		t.Errorf("expected the new finding %+v, got %+v", added, r.NewFindings)
+	}
+	if !reflect.DeepEqual(r.ResolvedFindings, []Finding{resolved}) {
+		t.Errorf("expected the resolved finding %+v, got %+v", resolved, r.ResolvedFindings)
+	}
+	expected := []AuditDrift{
+		{Ingress: "default/new", Change: "created"},
+		{Ingress: "default/old", Change: "deleted"},
+		{Ingress: "default/web", Change: "changed"},
+	}
+	if !reflect.DeepEqual(r.Drift, expected) {
+		t.Errorf("expected the drift %+v, got %+v", expected, r.Drift)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	n := newTestController(t, isolationManifests)
+	n.cfg.AuditCertificatePeriod = defaultAuditCertificatePeriod
+	total := auditsTotal.Value()
+
+	first := n.audit()
+	if first.Ingresses != 2 || len(first.NewFindings) != 0 || len(first.Drift) != 0 {
+		t.Errorf("expected the first audit to have nothing to compare with, got %+v", first)
+	}
+	for _, f := range first.Findings {
+		if f.Source != "" {
+			t.Errorf("expected the findings without source, got %+v", f)
+		}
+	}
+
+	s := n.store.(*memoryStore)
+	s.removeIngress("default/api")
+	web := testIngress(t, n, "default/web").Ingress.DeepCopy()
+	web.Annotations = map[string]string{"nginx.ingress.kubernetes.io/ssl-redirect": "false"}
+	if err := s.Add(web); err != nil {
+		t.Fatal(err)
+	}
+	second := n.audit()
+	expected := []AuditDrift{
+		{Ingress: "default/api", Change: "deleted"},
+		{Ingress: "default/web", Change: "changed"},
+	}
+	if !reflect.DeepEqual(second.Drift, expected) {
+		t.Errorf("expected the drift %+v, got %+v", expected, second.Drift)
+	}
+
+	if auditsTotal.Value() != total+2 || auditLastDrift.Value() != 2 {
+		t.Errorf("expected 2 more audits with 2 Ingresses changed, got %v audits and %v changed",
+			auditsTotal.Value()-total, auditLastDrift.Value())
+	}
+}
+
+func TestAuditExpiringCertificates(t *testing.T) {
+	expires := time.Now().Add(10 * 24 * time.Hour).Truncate(time.Second).UTC()
+	_, _, certPEM, keyPEM := testCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "web.example.com"},
+		DNSNames:     []string{"web.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     expires,
+	}, nil, nil)
+
+	n := newTestController(t, htmlReportManifests)
+	if err := n.store.(*memoryStore).Add(&apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-tls", Namespace: "default"},
+		Type:       apiv1.SecretTypeTLS,
+		Data:       map[string][]byte{apiv1.TLSCertKey: certPEM, apiv1.TLSPrivateKeyKey: keyPEM},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the Secret of api.example.com does not exist
+	n.cfg.AuditCertificatePeriod = defaultAuditCertificatePeriod
+	expected := []AuditCertificate{{Host: "web.example.com", Secret: "default/web-tls", Expires: expires}}
+	if report := n.audit(); !reflect.DeepEqual(report.ExpiringCertificates, expected) {
+		t.Errorf("expected %+v, got %+v", expected, report.ExpiringCertificates)
+	}
+
+	n.cfg.AuditCertificatePeriod = 24 * time.Hour
+	if report := n.audit(); len(report.ExpiringCertificates) != 0 {
+		t.Errorf("expected no certificate expiring within a day, got %+v", report.ExpiringCertificates)
+	}
+}
+
+func TestRunAudits(t *testing.T) {
+	n := newTestController(t, isolationManifests)
+	n.cfg.AuditInterval = time.Hour
+	n.cfg.AuditDirectory = t.TempDir()
+	n.cfg.DisableLeaderElection = true
+	// a single audit before stopping
+	close(n.stopCh)
+	n.runAudits()
+
+	files, err := filepath.Glob(filepath.Join(n.cfg.AuditDirectory, "audit-*Z.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected a report, got %v %v", files, err)
+	}
+	raw, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := &AuditReport{}
+	if err := json.Unmarshal(raw, report); err != nil || report.Ingresses != 2 {
+		t.Errorf("expected the report of 2 Ingresses, got %+v %v", report, err)
+	}
+}
+
+func TestAuditHandler(t *testing.T) {
+	n := newTestController(t, isolationManifests)
+
+	w := httptest.NewRecorder()
+	n.auditHandler(w, httptest.NewRequest(http.MethodGet, auditPath, nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected %v before the first audit, got %v", http.StatusNotFound, w.Code)
+	}
+
+	n.audit()
+	w = httptest.NewRecorder()
+	n.auditHandler(w, httptest.NewRequest(http.MethodGet, auditPath, nil))
+	report := &AuditReport{}
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), report) != nil || report.Ingresses != 2 {
+		t.Errorf("expected the last report, got %v %q", w.Code, w.Body.String())
+	}
+}
+
+func TestAuditReportRetention(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		report := &AuditReport{Time: start.Add(time.Duration(i) * time.Hour), Findings: []Finding{}}
+		if err := report.write(dir, 3); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	reports, err := filepath.Glob(filepath.Join(dir, "audit-*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 3 {
+		t.Fatalf("expected 3 reports to be kept, got %v", reports)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "audit-20260101T010000Z.json")); !os.IsNotExist(err) {
+		t.Errorf("expected the oldest reports to be removed, got %v", reports)
+	}
+}
+
+func TestAuditHandlerCaller(t *testing.T) {
+	n := newOfflineController(&NginxConfiguration{}, newMemoryStore(""))
+	n.lastAudit = &AuditReport{Findings: []Finding{}}
+
+	for _, tc := range []struct {
+		caller *webhookCaller
+		status int
+	}{
+		{caller: &webhookCaller{}, status: http.StatusOK},
+		{caller: &webhookCaller{namespaces: map[string]bool{"team-a": true}}, status: http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodGet, auditPath, nil)
+		req = req.WithContext(context.WithValue(req.Context(), webhookCallerKey{}, tc.caller))
+		rec := httptest.NewRecorder()
+		n.auditHandler(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("expected %d, got %d", tc.status, rec.Code)
+		}
+	}
+}
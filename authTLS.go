@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// maxAuthTLSVerifyDepth is the depth above which the verify depth is
+// reported as suspicious: client certificate chains are short
+const maxAuthTLSVerifyDepth = 5
+
+var authTLSVerifyClientValues = []string{"on", "off", "optional", "optional_no_ca"}
+
+// checkAuthTLS validates the mutual TLS configuration of the Ingresses end to
+// end. The annotation parser only stores problems in AuthTLSError, which
+// makes nginx deny every request of the server at runtime; here they are
+// reported as errors.
+func (n *NGINXController) checkAuthTLS(ingresses []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	now := time.Now()
+
+	for _, server := range cfg.Servers {
+		if server.AuthTLSError == "" {
+			continue
+		}
+		findings = append(findings, Finding{
+			Rule:     "auth-tls-error",
+			Severity: SeverityError,
+			Ingress:  serverIngress(server),
+			Host:     server.Hostname,
+			Message:  fmt.Sprintf("mutual TLS is misconfigured, nginx denies every request: %v", server.AuthTLSError),
+		})
+	}
+
+	for _, ing := range ingresses {
+		anns := ing.GetAnnotations()
+		secretName, ok := anns[parser.GetAnnotationWithPrefix("auth-tls-secret")]
+		if !ok {
+			continue
+		}
+
+		key := k8s.MetaNamespaceKey(ing)
+		report := func(severity Severity, format string, args ...interface{}) {
+			findings = append(findings, Finding{
+				Rule:     "auth-tls",
+				Severity: severity,
+				Ingress:  key,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		}
+
+		if v, ok := anns[parser.GetAnnotationWithPrefix("auth-tls-verify-client")]; ok && !containsString(authTLSVerifyClientValues, v) {
+			report(SeverityError, "invalid auth-tls-verify-client %q, expected one of %v", v, authTLSVerifyClientValues)
+		}
+
+		if v, ok := anns[parser.GetAnnotationWithPrefix("auth-tls-verify-depth")]; ok {
+			depth, err := strconv.Atoi(strings.TrimSpace(v))
+			switch {
+			case err != nil || depth < 1:
+				report(SeverityError, "invalid auth-tls-verify-depth %q, it must be a positive integer", v)
+			case depth > maxAuthTLSVerifyDepth:
+				report(SeverityWarning, "auth-tls-verify-depth %d allows very long client certificate chains", depth)
+			}
+		}
+
+		if v, ok := anns[parser.GetAnnotationWithPrefix("auth-tls-error-page")]; ok {
+			if u, err := url.Parse(v); err != nil || u.Scheme == "" || u.Host == "" {
+				report(SeverityWarning, "auth-tls-error-page %q is not an absolute URL", v)
+			}
+		}
+
+		ns, name, err := k8s.ParseNameNS(secretName)
+		if err != nil {
+			report(SeverityError, "auth-tls-secret %q must use the format namespace/name", secretName)
+			continue
+		}
+		secretKey := fmt.Sprintf("%v/%v", ns, name)
+
+		secret, err := n.store.GetSecret(secretKey)
+		if err != nil {
+			report(SeverityError, "auth-tls-secret %v does not exist; nginx denies every request of the hosts of the Ingress", secretKey)
+			continue
+		}
+
+		caData, ok := secret.Data["ca.crt"]
+		if !ok {
+			report(SeverityError, "auth-tls-secret %v does not contain ca.crt; mutual authentication is disabled", secretKey)
+			continue
+		}
+
+		cas, err := parsePEMCertificates(caData)
+		if err != nil || len(cas) == 0 {
+			report(SeverityError, "ca.crt of auth-tls-secret %v does not contain valid certificates: %v", secretKey, err)
+			continue
+		}
+		for _, ca := range cas {
+			if !ca.IsCA {
+				report(SeverityWarning, "certificate %q in ca.crt of %v is not a CA; client certificates can not be verified against it",
+					ca.Subject.CommonName, secretKey)
+			}
+			if now.After(ca.NotAfter) {
+				report(SeverityError, "CA %q in ca.crt of %v expired on %v; every client certificate is rejected",
+					ca.Subject.CommonName, secretKey, ca.NotAfter.Format(time.RFC3339))
+			}
+		}
+	}
+
+	return findings
+}
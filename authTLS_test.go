@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// authTLSIngress returns the manifest of an Ingress with the annotations
+func authTLSIngress(annotations map[string]string) string {
+	manifest := `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+  annotations:
+`
+	for k, v := range annotations {
+		manifest += fmt.Sprintf("    nginx.ingress.kubernetes.io/%v: %q\n", k, v)
+	}
+	return manifest + `spec:
+  ingressClassName: nginx
+  tls:
+  - hosts: [web.example.com]
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`
+}
+
+func TestCheckAuthTLS(t *testing.T) {
+	now := time.Now()
+	caTemplate := func(serial int64, name string, notAfter time.Time) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             now.Add(-48 * time.Hour),
+			NotAfter:              notAfter,
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		}
+	}
+	ca, caKey, caPEM, _ := testCertificate(t, caTemplate(1, "client ca", now.Add(time.Hour)), nil, nil)
+	_, _, expiredPEM, _ := testCertificate(t, caTemplate(3, "expired ca", now.Add(-time.Hour)), nil, nil)
+	_, _, leafPEM, _ := testCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(4),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+	}, ca, caKey)
+
+	tests := []struct {
+		name        string
+		secret      map[string][]byte
+		annotations map[string]string
+		expected    []string
+	}{
+		{
+			name:     "valid CA",
+			secret:   map[string][]byte{"ca.crt": caPEM},
+			expected: []string{},
+		},
+		{
+			name:     "missing secret",
+			expected: []string{"auth-tls-error/error", "auth-tls/error"},
+		},
+		{
+			name:     "secret without ca.crt",
+			secret:   map[string][]byte{"tls.crt": caPEM},
+			expected: []string{"auth-tls/error"},
+		},
+		{
+			name:     "invalid ca.crt",
+			secret:   map[string][]byte{"ca.crt": []byte("not a certificate")},
+			expected: []string{"auth-tls/error"},
+		},
+		{
+			name:     "expired CA",
+			secret:   map[string][]byte{"ca.crt": expiredPEM},
+			expected: []string{"auth-tls/error"},
+		},
+		{
+			name:     "not a CA",
+			secret:   map[string][]byte{"ca.crt": leafPEM},
+			expected: []string{"auth-tls/warning"},
+		},
+		{
+			name:   "annotations",
+			secret: map[string][]byte{"ca.crt": caPEM},
+			annotations: map[string]string{
+				"auth-tls-verify-client": "required",
+				"auth-tls-verify-depth":  "10",
+				"auth-tls-error-page":    "/error",
+			},
+			expected: []string{"auth-tls/error", "auth-tls/warning", "auth-tls/warning"},
+		},
+		{
+			name:        "invalid verify depth",
+			secret:      map[string][]byte{"ca.crt": caPEM},
+			annotations: map[string]string{"auth-tls-verify-depth": "0"},
+			expected:    []string{"auth-tls/error"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			annotations := map[string]string{"auth-tls-secret": "default/client-ca"}
+			for k, v := range tc.annotations {
+				annotations[k] = v
+			}
+			n := newTestController(t, authTLSIngress(annotations))
+			if tc.secret != nil {
+				if err := n.store.(*memoryStore).Add(&apiv1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "client-ca", Namespace: "default"},
+					Data:       tc.secret,
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ingresses := n.store.ListIngresses()
+			_, _, cfg := n.getConfiguration(ingresses)
+
+			got := []string{}
+			for _, f := range n.checkAuthTLS(ingresses, cfg) {
+				got = append(got, fmt.Sprintf("%v/%v", f.Rule, f.Severity))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// defaultAuthURLProbeTimeout is used when AuthURLProbeTimeout is not set
+const defaultAuthURLProbeTimeout = 5 * time.Second
+
+// checkExternalAuthURLs reports auth-url annotations that are not valid URLs,
+// do not use HTTPS when it is required and, when probing is enabled, point
+// to endpoints that do not answer: nginx returns 500 for every request of
+// the location when the authentication subrequest fails
+func (n *NGINXController) checkExternalAuthURLs(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	probed := map[string]error{}
+
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			authURL := loc.ExternalAuth.URL
+			if authURL == "" {
+				continue
+			}
+
+			u, err := parseAuthURL(authURL)
+			if err != nil {
+				findings = append(findings, newLocationFinding("auth-url-invalid", SeverityError, server, loc,
+					"auth-url %q of location %q is not valid: %v", authURL, loc.Path, err))
+				continue
+			}
+
+			if n.cfg.RequireSecureAuthURL && u.Scheme != "https" {
+				findings = append(findings, newLocationFinding("auth-url-insecure", SeverityError, server, loc,
+					"auth-url %q of location %q does not use HTTPS; credentials and session cookies are sent in clear text",
+					authURL, loc.Path))
+			}
+
+			if !n.cfg.ProbeAuthURLs || strings.Contains(authURL, "$") {
+				// URLs containing nginx variables are only known at request time
+				continue
+			}
+
+			probeErr, ok := probed[authURL]
+			if !ok {
+				probeErr = n.probeAuthURL(authURL)
+				probed[authURL] = probeErr
+			}
+			if probeErr != nil {
+				findings = append(findings, newLocationFinding("auth-url-unreachable", SeverityError, server, loc,
+					"auth-url %q of location %q does not respond: %v; nginx rejects every request of the location while the authentication service is down",
+					authURL, loc.Path, probeErr))
+			}
+		}
+	}
+
+	return findings
+}
+
+// parseAuthURL checks the URL used in the authentication subrequest is
+// absolute and uses HTTP or HTTPS
+func parseAuthURL(authURL string) (*url.URL, error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("scheme must be http or https")
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("host is missing")
+	}
+	return u, nil
+}
+
+// probeAuthURL sends a HEAD request to the authentication endpoint. Any
+// response other than a server error means the endpoint is alive: the
+// authentication service answers 401 or 403 to requests without credentials.
+func (n *NGINXController) probeAuthURL(authURL string) error {
+	timeout := n.cfg.AuthURLProbeTimeout
+	if timeout == 0 {
+		timeout = defaultAuthURLProbeTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, authURL, http.NoBody)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{
+		// the certificate is checked by nginx only when proxy-ssl-verify is
+		// enabled, the probe only checks the endpoint answers
+		Transport: &http.Transport{
+			//nolint:gosec // the probe does not send credentials
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	klog.V(3).Infof("auth-url %v answered %v", authURL, resp.Status)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("status %v", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseAuthURL(t *testing.T) {
+	tests := map[string]bool{
+		"https://auth.example.com/verify":            true,
+		"http://auth.default.svc.cluster.local:8080": true,
+		"ftp://auth.example.com":                     false,
+		"/verify":                                    false,
+		"https://":                                   false,
+		"https://auth.example.com/%zz":               false,
+	}
+
+	for authURL, valid := range tests {
+		if _, err := parseAuthURL(authURL); valid != (err == nil) {
+			t.Errorf("%q: expected valid %v, got %v", authURL, valid, err)
+		}
+	}
+}
+
+func TestProbeAuthURL(t *testing.T) {
+	handler := func(status int) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodHead {
+				t.Errorf("expected a HEAD request, got %v", r.Method)
+			}
+			w.WriteHeader(status)
+		})
+	}
+
+	unauthorized := httptest.NewTLSServer(handler(http.StatusUnauthorized))
+	defer unauthorized.Close()
+	redirect := httptest.NewServer(http.RedirectHandler("http://127.0.0.1:1/login", http.StatusFound))
+	defer redirect.Close()
+	failing := httptest.NewServer(handler(http.StatusBadGateway))
+	defer failing.Close()
+	closed := httptest.NewServer(handler(http.StatusOK))
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		authURL string
+		alive   bool
+	}{
+		{"unauthorized", unauthorized.URL, true},
+		{"redirect", redirect.URL, true},
+		{"server error", failing.URL, false},
+		{"connection refused", closed.URL, false},
+	}
+
+	n := newTestController(t, "")
+	n.cfg.AuthURLProbeTimeout = time.Second
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := n.probeAuthURL(tc.authURL); tc.alive != (err == nil) {
+				t.Errorf("expected alive %v, got %v", tc.alive, err)
+			}
+		})
+	}
+}
+
+const authURLManifests = `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/auth-url: %v
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`
+
+func TestCheckExternalAuthURLs(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	tests := []struct {
+		name     string
+		authURL  string
+		secure   bool
+		probe    bool
+		expected []string
+	}{
+		{
+			name:    "http allowed",
+			authURL: "http://auth.example.com/verify",
+		},
+		{
+			name:     "http with secure auth URLs",
+			authURL:  "http://auth.example.com/verify",
+			secure:   true,
+			expected: []string{"auth-url-insecure"},
+		},
+		{
+			name:     "unreachable",
+			authURL:  failing.URL + "/verify",
+			probe:    true,
+			expected: []string{"auth-url-unreachable"},
+		},
+		{
+			name:    "nginx variables are not probed",
+			authURL: failing.URL + "/verify?host=$host",
+			probe:   true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, fmt.Sprintf(authURLManifests, tc.authURL))
+			n.cfg.RequireSecureAuthURL = tc.secure
+			n.cfg.ProbeAuthURLs = tc.probe
+
+			rules := []string{}
+			for _, f := range n.checkExternalAuthURLs(ingresses, cfg) {
+				rules = append(rules, f.Rule)
+			}
+			if fmt.Sprint(rules) != fmt.Sprint(tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, rules)
+			}
+		})
+	}
+}
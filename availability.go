@@ -0,0 +1,143 @@
+package main
+
+import (
+	"path"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+)
+
+// availabilityRules contains the checks focused on the availability of the
+// backends, executed only when the availability audit is enabled
+var availabilityRules = []validationRule{
+	(*NGINXController).checkBackendAvailability,
+}
+
+// isProductionHost returns true if the host matches one of the configured
+// production host patterns
+func (n *NGINXController) isProductionHost(host string) bool {
+	for _, pattern := range n.cfg.ProductionHosts {
+		if ok, err := path.Match(pattern, host); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkBackendAvailability reports backends not covered by a
+// PodDisruptionBudget and production hosts served by a single replica
+func (n *NGINXController) checkBackendAvailability(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	reported := map[string]bool{}
+
+	for _, server := range cfg.Servers {
+		production := n.isProductionHost(server.Hostname)
+
+		for _, loc := range server.Locations {
+			svc := loc.Service
+			if loc.IsDefBackend || svc == nil || len(svc.Spec.Selector) == 0 {
+				continue
+			}
+
+			key := server.Hostname + "|" + svc.Namespace + "/" + svc.Name
+			if reported[key] {
+				continue
+			}
+			reported[key] = true
+
+			deployments := n.serviceDeployments(svc)
+			pdbs := n.servicePodDisruptionBudgets(svc)
+
+			severity := SeverityInfo
+			if production {
+				severity = SeverityWarning
+			}
+			if len(pdbs) == 0 {
+				findings = append(findings, newLocationFinding("availability-pdb", severity, server, loc,
+					"the pods of Service %v/%v are not covered by a PodDisruptionBudget; node drains can remove all of them at once",
+					svc.Namespace, svc.Name))
+			}
+
+			replicas := int32(0)
+			for _, d := range deployments {
+				if d.Spec.Replicas == nil {
+					replicas++
+					continue
+				}
+				replicas += *d.Spec.Replicas
+			}
+
+			if production && len(deployments) > 0 && replicas <= 1 {
+				findings = append(findings, newLocationFinding("availability-single-replica", SeverityWarning, server, loc,
+					"production host is served by Service %v/%v backed by %d replica(s); any restart causes downtime",
+					svc.Namespace, svc.Name, replicas))
+			}
+
+			for _, pdb := range pdbs {
+				if len(deployments) > 0 && blocksEviction(pdb, replicas) {
+					findings = append(findings, newLocationFinding("availability-pdb-blocks-drain", SeverityWarning, server, loc,
+						"PodDisruptionBudget %v/%v does not allow any disruption of the %d replica(s) of Service %v/%v; node drains will be blocked",
+						pdb.Namespace, pdb.Name, replicas, svc.Namespace, svc.Name))
+				}
+			}
+		}
+	}
+
+	return findings
+}
+
+// serviceDeployments returns the Deployments whose pods are selected by the service
+func (n *NGINXController) serviceDeployments(svc *apiv1.Service) []*appsv1.Deployment {
+	selector := labels.SelectorFromSet(svc.Spec.Selector)
+
+	deployments := []*appsv1.Deployment{}
+	for _, d := range n.store.ListDeployments(svc.Namespace) {
+		if selector.Matches(labels.Set(d.Spec.Template.Labels)) {
+			deployments = append(deployments, d)
+		}
+	}
+	return deployments
+}
+
+// servicePodDisruptionBudgets returns the PodDisruptionBudgets selecting the
+// pods of the service. Pod labels are approximated by the service selector.
+func (n *NGINXController) servicePodDisruptionBudgets(svc *apiv1.Service) []*policyv1.PodDisruptionBudget {
+	podLabels := labels.Set(svc.Spec.Selector)
+
+	pdbs := []*policyv1.PodDisruptionBudget{}
+	for _, pdb := range n.store.ListPodDisruptionBudgets(svc.Namespace) {
+		if pdb.Spec.Selector == nil {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil {
+			klog.Warningf("Invalid selector in PodDisruptionBudget %v/%v: %v", pdb.Namespace, pdb.Name, err)
+			continue
+		}
+		if !selector.Empty() && selector.Matches(podLabels) {
+			pdbs = append(pdbs, pdb)
+		}
+	}
+	return pdbs
+}
+
+// blocksEviction returns true if the budget does not allow evicting any of
+// the replicas
+func blocksEviction(pdb *policyv1.PodDisruptionBudget, replicas int32) bool {
+	if pdb.Spec.MaxUnavailable != nil {
+		maxUnavailable, err := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MaxUnavailable, int(replicas), true)
+		return err == nil && maxUnavailable == 0
+	}
+
+	if pdb.Spec.MinAvailable != nil {
+		minAvailable, err := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MinAvailable, int(replicas), true)
+		return err == nil && int32(minAvailable) >= replicas
+	}
+
+	return false
+}
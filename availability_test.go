@@ -0,0 +1,172 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const availabilityManifests = `
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: default
+spec:
+  selector:
+    app: web
+  ports:
+  - name: http
+    port: 80
+    protocol: TCP
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+spec:
+  replicas: %REPLICAS%
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.service.justice.gov.uk
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`
+
+const availabilityPDB = `
+---
+apiVersion: policy/v1
+kind: PodDisruptionBudget
+metadata:
+  name: web
+  namespace: default
+spec:
+  minAvailable: %MIN_AVAILABLE%
+  selector:
+    matchLabels:
+      app: web
+`
+
+func TestCheckBackendAvailability(t *testing.T) {
+	tests := []struct {
+		name         string
+		replicas     string
+		minAvailable string
+		production   []string
+		expected     map[string]Severity
+	}{
+		{
+			name:     "no budget",
+			replicas: "2",
+			expected: map[string]Severity{"availability-pdb": SeverityInfo},
+		},
+		{
+			name:       "no budget on a production host",
+			replicas:   "2",
+			production: []string{"*.service.justice.gov.uk"},
+			expected:   map[string]Severity{"availability-pdb": SeverityWarning},
+		},
+		{
+			name:         "single production replica",
+			replicas:     "1",
+			minAvailable: "0",
+			production:   []string{"*.service.justice.gov.uk"},
+			expected:     map[string]Severity{"availability-single-replica": SeverityWarning},
+		},
+		{
+			name:         "budget blocking drains",
+			replicas:     "2",
+			minAvailable: "100%",
+			expected:     map[string]Severity{"availability-pdb-blocks-drain": SeverityWarning},
+		},
+		{
+			name:         "budget allowing a disruption",
+			replicas:     "3",
+			minAvailable: "2",
+			production:   []string{"*.service.justice.gov.uk"},
+			expected:     map[string]Severity{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			manifests := strings.ReplaceAll(availabilityManifests, "%REPLICAS%", tc.replicas)
+			if tc.minAvailable != "" {
+				manifests += strings.ReplaceAll(availabilityPDB, "%MIN_AVAILABLE%", quoteYAML(tc.minAvailable))
+			}
+			n, ingresses, cfg := testConfiguration(t, manifests)
+			n.cfg.ProductionHosts = tc.production
+
+			got := map[string]Severity{}
+			for _, f := range n.checkBackendAvailability(ingresses, cfg) {
+				got[f.Rule] = f.Severity
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+// quoteYAML quotes percentages, which are strings in the budget specs
+func quoteYAML(v string) string {
+	if strings.HasSuffix(v, "%") {
+		return `"` + v + `"`
+	}
+	return v
+}
+
+func TestBlocksEviction(t *testing.T) {
+	value := func(s string) *intstr.IntOrString {
+		v := intstr.Parse(s)
+		return &v
+	}
+
+	tests := []struct {
+		name     string
+		spec     policyv1.PodDisruptionBudgetSpec
+		replicas int32
+		expected bool
+	}{
+		{"maxUnavailable 0", policyv1.PodDisruptionBudgetSpec{MaxUnavailable: value("0")}, 3, true},
+		{"maxUnavailable 1", policyv1.PodDisruptionBudgetSpec{MaxUnavailable: value("1")}, 3, false},
+		{"maxUnavailable 10% rounds up", policyv1.PodDisruptionBudgetSpec{MaxUnavailable: value("10%")}, 3, false},
+		{"minAvailable equal to replicas", policyv1.PodDisruptionBudgetSpec{MinAvailable: value("2")}, 2, true},
+		{"minAvailable below replicas", policyv1.PodDisruptionBudgetSpec{MinAvailable: value("1")}, 2, false},
+		{"minAvailable 100%", policyv1.PodDisruptionBudgetSpec{MinAvailable: value("100%")}, 4, true},
+		{"empty spec", policyv1.PodDisruptionBudgetSpec{}, 1, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := blocksEviction(&policyv1.PodDisruptionBudget{Spec: tc.spec}, tc.replicas); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// checkBackendServices reports the backends of the Ingresses referring to a
+// Service that does not exist: ingress-nginx answers the requests of their
+// paths with 503 until the Service is created
+func (n *NGINXController) checkBackendServices(ingresses []*Ingress, _ *Configuration) []Finding {
+	findings := []Finding{}
+
+	for _, ing := range ingresses {
+		missing := func(backend *networking.IngressBackend) bool {
+			if backend == nil || backend.Service == nil {
+				return false
+			}
+			_, err := n.store.GetService(fmt.Sprintf("%v/%v", ing.Namespace, backend.Service.Name))
+			return err != nil
+		}
+
+		if missing(ing.Spec.DefaultBackend) {
+			findings = append(findings, Finding{
+				Rule:     "backend-service-missing",
+				Severity: SeverityWarning,
+				Ingress:  k8s.MetaNamespaceKey(ing),
+				Message: fmt.Sprintf("the default backend refers to Service %v/%v, which does not exist; its requests are answered with 503",
+					ing.Namespace, ing.Spec.DefaultBackend.Service.Name),
+			})
+		}
+		for _, rule := range ing.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for i := range rule.HTTP.Paths {
+				path := rule.HTTP.Paths[i]
+				if !missing(&path.Backend) {
+					continue
+				}
+				findings = append(findings, Finding{
+					Rule:     "backend-service-missing",
+					Severity: SeverityWarning,
+					Ingress:  k8s.MetaNamespaceKey(ing),
+					Host:     rule.Host,
+					Path:     path.Path,
+					Message: fmt.Sprintf("path %q refers to Service %v/%v, which does not exist; its requests are answered with 503",
+						path.Path, ing.Namespace, path.Backend.Service.Name),
+				})
+			}
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+const backendServicesManifests = `
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: default
+spec:
+  ports:
+  - port: 80
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: api
+  namespace: other
+spec:
+  ports:
+  - port: 80
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+spec:
+  ingressClassName: nginx
+  defaultBackend:
+    service:
+      name: fallback
+      port:
+        number: 80
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+      - path: /api
+        pathType: Prefix
+        backend:
+          service:
+            name: api
+            port:
+              number: 80
+      - path: /static
+        pathType: Prefix
+        backend:
+          resource:
+            apiGroup: k8s.example.com
+            kind: StorageBucket
+            name: static
+`
+
+func TestCheckBackendServices(t *testing.T) {
+	n, ingresses, cfg := testConfiguration(t, backendServicesManifests)
+
+	got := []string{}
+	for _, f := range n.checkBackendServices(ingresses, cfg) {
+		got = append(got, fmt.Sprintf("%v %v%v: %v", f.Severity, f.Host, f.Path, f.Message))
+	}
+	// the Service api of the namespace other is not the one of the Ingress
+	expected := []string{
+		"warning : the default backend refers to Service default/fallback, which does not exist; its requests are answered with 503",
+		`warning web.example.com/api: path "/api" refers to Service default/api, which does not exist; its requests are answered with 503`,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// loadBaseline reads the findings of a baseline file, in the format of
+// validate -output json
+func loadBaseline(path string) ([]Finding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := []Finding{}
+	if err := json.Unmarshal(data, &findings); err != nil {
+		return nil, fmt.Errorf("error parsing baseline %v: %w", path, err)
+	}
+	return findings, nil
+}
+
+// writeBaseline records the findings in a baseline file
+func writeBaseline(path string, findings []Finding) error {
+	data, err := json.MarshalIndent(findings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// baselineKey identifies a finding in the baseline. The message is left out
+// because it contains counts and addresses that change between runs.
+type baselineKey struct {
+	Rule    string
+	Ingress string
+	Host    string
+	Path    string
+}
+
+func newBaselineKey(f Finding) baselineKey {
+	return baselineKey{Rule: f.Rule, Ingress: f.Ingress, Host: f.Host, Path: f.Path}
+}
+
+// applyBaseline removes the findings recorded in the baseline. Every entry
+// of the baseline suppresses one finding of the same rule, Ingress, host and
+// path, so a problem appearing once more is still reported. stale is the
+// number of baseline entries that no longer match any finding and can be
+// removed from the file.
+func applyBaseline(findings, baseline []Finding) (kept []Finding, suppressed, stale int) {
+	known := map[baselineKey]int{}
+	for _, f := range baseline {
+		known[newBaselineKey(f)]++
+	}
+
+	kept = []Finding{}
+	for _, f := range findings {
+		key := newBaselineKey(f)
+		if known[key] > 0 {
+			known[key]--
+			suppressed++
+			continue
+		}
+		kept = append(kept, f)
+	}
+
+	for _, count := range known {
+		stale += count
+	}
+	return kept, suppressed, stale
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyBaseline(t *testing.T) {
+	h2c := Finding{Rule: "h2c-nginx-version", Severity: SeverityError, Ingress: "default/h2c", Host: "h2c.example.com"}
+	cors := Finding{Rule: "cors-permissive", Severity: SeverityWarning, Ingress: "default/web"}
+	otherHost := h2c
+	otherHost.Host = "other.example.com"
+	endpoints := Finding{Rule: "upstream-endpoints", Severity: SeverityWarning, Ingress: "default/app", Host: "app.example.com", Path: "/", Message: "3 endpoints are not ready: 10.0.0.1, 10.0.0.2, 10.0.0.3"}
+	otherMessage := endpoints
+	otherMessage.Message = "1 endpoint is not ready: 10.0.0.7"
+	otherMessage.Source = "app.yaml:1"
+	otherPath := otherMessage
+	otherPath.Path = "/api/"
+
+	tests := []struct {
+		name       string
+		findings   []Finding
+		baseline   []Finding
+		kept       []Finding
+		suppressed int
+		stale      int
+	}{
+		{
+			name:     "no baseline",
+			findings: []Finding{h2c, cors},
+			kept:     []Finding{h2c, cors},
+		},
+		{
+			name:       "known finding",
+			findings:   []Finding{h2c, cors},
+			baseline:   []Finding{h2c},
+			kept:       []Finding{cors},
+			suppressed: 1,
+		},
+		{
+			name:       "finding appearing once more",
+			findings:   []Finding{h2c, h2c},
+			baseline:   []Finding{h2c},
+			kept:       []Finding{h2c},
+			suppressed: 1,
+		},
+		{
+			name:     "different host",
+			findings: []Finding{otherHost},
+			baseline: []Finding{h2c},
+			kept:     []Finding{otherHost},
+			stale:    1,
+		},
+		{
+			name:       "different message and source",
+			findings:   []Finding{otherMessage},
+			baseline:   []Finding{endpoints},
+			kept:       []Finding{},
+			suppressed: 1,
+		},
+		{
+			name:     "different path",
+			findings: []Finding{otherPath},
+			baseline: []Finding{endpoints},
+			kept:     []Finding{otherPath},
+			stale:    1,
+		},
+		{
+			name:       "fixed findings",
+			findings:   []Finding{cors},
+			baseline:   []Finding{h2c, h2c, cors},
+			kept:       []Finding{},
+			suppressed: 1,
+			stale:      2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			kept, suppressed, stale := applyBaseline(tc.findings, tc.baseline)
+			if !reflect.DeepEqual(kept, tc.kept) {
+				t.Errorf("expected %v, got %v", tc.kept, kept)
+			}
+			if suppressed != tc.suppressed || stale != tc.stale {
+				t.Errorf("expected %d suppressed and %d stale, got %d and %d", tc.suppressed, tc.stale, suppressed, stale)
+			}
+		})
+	}
+}
+
+func TestLoadBaseline(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "baseline.json")
+	findings := []Finding{{Rule: "h2c-nginx-version", Severity: SeverityError, Ingress: "default/h2c"}}
+	if err := writeBaseline(path, findings); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, findings) {
+		t.Errorf("expected the baseline to round trip, got %v", loaded)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte("h2c-nginx-version"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadBaseline(invalid); err == nil || !strings.Contains(err.Error(), "error parsing baseline") {
+		t.Errorf("expected a parse error, got %v", err)
+	}
+}
+
+func TestRunValidateBaseline(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "ingress.yaml")
+	if err := os.WriteFile(manifest, []byte(cliManifests), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	baseline := filepath.Join(dir, "baseline.json")
+	args := []string{"validate", "-f", manifest, "--nginx-version", "1.13.9", "--baseline", baseline}
+
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"validate", "-f", manifest, "--update-baseline"}, &stdout, &stderr); code != exitInternal {
+		t.Errorf("expected --update-baseline without --baseline to fail, got exit code %d", code)
+	}
+
+	stderr.Reset()
+	if code := runCLI(append(args, "--update-baseline"), &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d: %v", exitOK, code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "recorded 1 findings") {
+		t.Errorf("unexpected output %q", stderr.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	if code := runCLI(args, &stdout, &stderr); code != exitOK {
+		t.Errorf("expected the known finding to be suppressed, got exit code %d: %v", code, stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "1 findings suppressed by the baseline") {
+		t.Errorf("unexpected output %q", stderr.String())
+	}
+
+	// without --nginx-version the finding is fixed, so its entry is stale
+	stderr.Reset()
+	if code := runCLI([]string{"validate", "-f", manifest, "--baseline", baseline}, &stdout, &stderr); code != exitOK {
+		t.Errorf("unexpected exit code %d: %v", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "1 baseline entries no longer match") {
+		t.Errorf("expected the stale entry to be reported, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+const (
+	authSecretTypeFile = "auth-file"
+	authSecretTypeMap  = "auth-map"
+	// authSecretKey is the key of the htpasswd file in Secrets of type auth-file
+	authSecretKey = "auth"
+)
+
+var (
+	desCryptHash  = regexp.MustCompile(`^[./0-9A-Za-z]{13}$`)
+	md5CryptHash  = regexp.MustCompile(`^\$(1|apr1)\$[./0-9A-Za-z]{1,8}\$[./0-9A-Za-z]{22}$`)
+	shaCryptHash  = regexp.MustCompile(`^\$(5|6)\$(rounds=[0-9]+\$)?[./0-9A-Za-z]{1,16}\$[./0-9A-Za-z]{43,86}$`)
+	bcryptHash    = regexp.MustCompile(`^\$2[abxy]?\$[0-9]{2}\$[./0-9A-Za-z]{53}$`)
+	shaHash       = regexp.MustCompile(`^\{SHA\}[A-Za-z0-9+/]{27}=$`)
+	sshaHash      = regexp.MustCompile(`^\{SSHA\}[A-Za-z0-9+/=]{32,}$`)
+	digestHA1Hash = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+)
+
+// checkBasicAuthSecrets validates the Secrets referenced by the auth-secret
+// annotation. nginx answers 503 (or 500) to every request of the location
+// when the file is missing, and 401 to users whose entries do not parse.
+func (n *NGINXController) checkBasicAuthSecrets(ingresses []*Ingress, _ *Configuration) []Finding {
+	findings := []Finding{}
+
+	for _, ing := range ingresses {
+		anns := ing.GetAnnotations()
+		authType := strings.ToLower(anns[parser.GetAnnotationWithPrefix("auth-type")])
+		if authType != "basic" && authType != "digest" {
+			continue
+		}
+
+		key := k8s.MetaNamespaceKey(ing)
+		report := func(severity Severity, format string, args ...interface{}) {
+			findings = append(findings, Finding{
+				Rule:     "basic-auth-secret",
+				Severity: severity,
+				Ingress:  key,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		}
+
+		secretName := anns[parser.GetAnnotationWithPrefix("auth-secret")]
+		if secretName == "" {
+			report(SeverityError, "auth-type %v requires the auth-secret annotation", authType)
+			continue
+		}
+
+		ns, name, err := cache.SplitMetaNamespaceKey(secretName)
+		if err != nil {
+			report(SeverityError, "invalid auth-secret %q: %v", secretName, err)
+			continue
+		}
+		if ns == "" {
+			ns = ing.Namespace
+		}
+		secretKey := fmt.Sprintf("%v/%v", ns, name)
+
+		secret, err := n.store.GetSecret(secretKey)
+		if err != nil {
+			report(SeverityError, "auth-secret %v does not exist; requests are rejected with 503", secretKey)
+			continue
+		}
+
+		secretType := anns[parser.GetAnnotationWithPrefix("auth-secret-type")]
+		if secretType == "" {
+			secretType = authSecretTypeFile
+		}
+
+		switch secretType {
+		case authSecretTypeFile:
+			data, ok := secret.Data[authSecretKey]
+			if !ok {
+				report(SeverityError, "auth-secret %v does not contain the %q key; requests are rejected with 503", secretKey, authSecretKey)
+				continue
+			}
+			for _, err := range validateHtpasswd(string(data), authType) {
+				report(SeverityError, "auth-secret %v: %v", secretKey, err)
+			}
+		case authSecretTypeMap:
+			if len(secret.Data) == 0 {
+				report(SeverityError, "auth-secret %v is empty; nobody can authenticate", secretKey)
+				continue
+			}
+			for _, user := range sortedKeys(secret.Data) {
+				if err := validateHtpasswdHash(string(secret.Data[user]), authType); err != nil {
+					report(SeverityError, "auth-secret %v: user %q: %v", secretKey, user, err)
+				}
+			}
+		default:
+			report(SeverityError, "invalid auth-secret-type %q, expected %v or %v", secretType, authSecretTypeFile, authSecretTypeMap)
+		}
+	}
+
+	return findings
+}
+
+// validateHtpasswd returns an error for each malformed line of an htpasswd
+// file (basic) or htdigest file (digest)
+func validateHtpasswd(content, authType string) []error {
+	var errs []error
+	users := 0
+
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, ":")
+		var user, hash string
+		switch {
+		case authType == "digest" && len(fields) == 3:
+			user, hash = fields[0], fields[2]
+		case authType == "basic" && len(fields) >= 2:
+			// the comment field after the hash is ignored by nginx
+			user, hash = fields[0], fields[1]
+		default:
+			errs = append(errs, fmt.Errorf("line %d is not a valid %v entry", i+1, authType))
+			continue
+		}
+
+		if user == "" {
+			errs = append(errs, fmt.Errorf("line %d has an empty user name", i+1))
+			continue
+		}
+		if err := validateHtpasswdHash(hash, authType); err != nil {
+			errs = append(errs, fmt.Errorf("line %d (user %q): %v", i+1, user, err))
+			continue
+		}
+		users++
+	}
+
+	if users == 0 && len(errs) == 0 {
+		errs = append(errs, fmt.Errorf("the file does not contain any user; nobody can authenticate"))
+	}
+
+	return errs
+}
+
+// validateHtpasswdHash checks the hash uses an algorithm supported by nginx
+func validateHtpasswdHash(hash, authType string) error {
+	hash = strings.TrimSpace(hash)
+	if authType == "digest" {
+		if !digestHA1Hash.MatchString(hash) {
+			return fmt.Errorf("digest entries must contain the MD5 of user:realm:password in hexadecimal")
+		}
+		return nil
+	}
+
+	switch {
+	case hash == "":
+		return fmt.Errorf("empty password hash")
+	case strings.HasPrefix(hash, "{PLAIN}"):
+		return nil
+	case strings.HasPrefix(hash, "{SHA}"):
+		if !shaHash.MatchString(hash) {
+			return fmt.Errorf("malformed {SHA} hash")
+		}
+	case strings.HasPrefix(hash, "{SSHA}"):
+		if !sshaHash.MatchString(hash) {
+			return fmt.Errorf("malformed {SSHA} hash")
+		}
+	case strings.HasPrefix(hash, "$1$"), strings.HasPrefix(hash, "$apr1$"):
+		if !md5CryptHash.MatchString(hash) {
+			return fmt.Errorf("malformed MD5 hash")
+		}
+	case strings.HasPrefix(hash, "$5$"), strings.HasPrefix(hash, "$6$"):
+		if !shaCryptHash.MatchString(hash) {
+			return fmt.Errorf("malformed SHA-crypt hash")
+		}
+	case strings.HasPrefix(hash, "$2"):
+		if !bcryptHash.MatchString(hash) {
+			return fmt.Errorf("malformed bcrypt hash")
+		}
+	case strings.HasPrefix(hash, "$"):
+		return fmt.Errorf("unsupported hash algorithm %q", strings.SplitN(hash[1:], "$", 2)[0])
+	case !desCryptHash.MatchString(hash):
+		return fmt.Errorf("the password is not hashed with a supported algorithm (use htpasswd to generate the file)")
+	}
+
+	return nil
+}
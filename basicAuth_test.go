@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+const (
+	testBcryptHash = "$2y$05$Y0T9Dbi8CJzxJdXvrfMdYu1pbr5iNqC0ygWp4mWZrTqnGR4qGR4qG"
+	testAPR1Hash   = "$apr1$xyz12345$A1b2C3d4E5f6G7h8I9j0K."
+	testSHAHash    = "{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g="
+	testDESHash    = "rl0r5DYWRxrGg"
+	testDigestHA1  = "939e7578ed9e3c518a452acee763bce9"
+)
+
+func TestValidateHtpasswdHash(t *testing.T) {
+	tests := []struct {
+		hash     string
+		authType string
+		valid    bool
+	}{
+		{testBcryptHash, "basic", true},
+		{testAPR1Hash, "basic", true},
+		{testSHAHash, "basic", true},
+		{testDESHash, "basic", true},
+		{"{PLAIN}secret", "basic", true},
+		{"", "basic", false},
+		{"secret", "basic", false},
+		{"$apr1$xyz$short", "basic", false},
+		{"$2y$05$short", "basic", false},
+		{"{SHA}short=", "basic", false},
+		{"$argon2id$v=19$m=65536", "basic", false},
+		{testDigestHA1, "digest", true},
+		{testBcryptHash, "digest", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%v %v", tc.authType, tc.hash), func(t *testing.T) {
+			if err := validateHtpasswdHash(tc.hash, tc.authType); tc.valid != (err == nil) {
+				t.Errorf("expected valid %v, got %v", tc.valid, err)
+			}
+		})
+	}
+}
+
+func TestValidateHtpasswd(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		authType string
+		expected []string
+	}{
+		{
+			name:     "valid basic file",
+			content:  "# users\nalice:" + testBcryptHash + "\nbob:" + testAPR1Hash + ":comment\r\n\n",
+			authType: "basic",
+		},
+		{
+			name:     "valid digest file",
+			content:  "alice:restricted:" + testDigestHA1,
+			authType: "digest",
+		},
+		{
+			name:     "plain text password",
+			content:  "alice:" + testBcryptHash + "\nbob:secret",
+			authType: "basic",
+			expected: []string{`line 2 (user "bob"): the password is not hashed with a supported algorithm (use htpasswd to generate the file)`},
+		},
+		{
+			name:     "basic entry in a digest file",
+			content:  "alice:" + testDigestHA1,
+			authType: "digest",
+			expected: []string{"line 1 is not a valid digest entry"},
+		},
+		{
+			name:     "empty user",
+			content:  ":" + testBcryptHash,
+			authType: "basic",
+			expected: []string{"line 1 has an empty user name"},
+		},
+		{
+			name:     "no users",
+			content:  "# nobody\n",
+			authType: "basic",
+			expected: []string{"the file does not contain any user; nobody can authenticate"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := []string{}
+			for _, err := range validateHtpasswd(tc.content, tc.authType) {
+				got = append(got, err.Error())
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+const basicAuthManifests = `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: auth-file
+  namespace: default
+data:
+  auth: %v
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: auth-map
+  namespace: default
+data:
+  alice: %v
+  bob: %v
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: no-auth-key
+  namespace: default
+data:
+  users: %v
+`
+
+// basicAuthIngress returns the manifest of an Ingress using the auth annotations
+func basicAuthIngress(name string, annotations map[string]string) string {
+	manifest := fmt.Sprintf(`
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: %v
+  namespace: default
+  annotations:
+`, name)
+	for k, v := range annotations {
+		manifest += fmt.Sprintf("    nginx.ingress.kubernetes.io/%v: %q\n", k, v)
+	}
+	return manifest + "spec:\n  ingressClassName: nginx\n"
+}
+
+func TestCheckBasicAuthSecrets(t *testing.T) {
+	b64 := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	secrets := fmt.Sprintf(basicAuthManifests,
+		b64("alice:"+testBcryptHash+"\n"), b64(testAPR1Hash), b64("secret"), b64("alice:"+testBcryptHash))
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    []string
+	}{
+		{
+			name:        "valid auth file",
+			annotations: map[string]string{"auth-type": "basic", "auth-secret": "auth-file"},
+		},
+		{
+			name:        "secret in another namespace",
+			annotations: map[string]string{"auth-type": "basic", "auth-secret": "other/auth-file"},
+			expected:    []string{"auth-secret other/auth-file does not exist; requests are rejected with 503"},
+		},
+		{
+			name:        "missing auth-secret",
+			annotations: map[string]string{"auth-type": "digest"},
+			expected:    []string{"auth-type digest requires the auth-secret annotation"},
+		},
+		{
+			name:        "missing auth key",
+			annotations: map[string]string{"auth-type": "basic", "auth-secret": "no-auth-key"},
+			expected:    []string{`auth-secret default/no-auth-key does not contain the "auth" key; requests are rejected with 503`},
+		},
+		{
+			name:        "auth map",
+			annotations: map[string]string{"auth-type": "basic", "auth-secret": "auth-map", "auth-secret-type": "auth-map"},
+			expected:    []string{`auth-secret default/auth-map: user "bob": the password is not hashed with a supported algorithm (use htpasswd to generate the file)`},
+		},
+		{
+			name:        "invalid secret type",
+			annotations: map[string]string{"auth-type": "basic", "auth-secret": "auth-file", "auth-secret-type": "auth-list"},
+			expected:    []string{`invalid auth-secret-type "auth-list", expected auth-file or auth-map`},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, secrets+basicAuthIngress("web", tc.annotations))
+
+			got := []string{}
+			for _, f := range findingsWithRule(n.checkBasicAuthSecrets(ingresses, cfg), "basic-auth-secret") {
+				got = append(got, f.Message)
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
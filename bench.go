@@ -0,0 +1,365 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"text/tabwriter"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// benchNamespace is the namespace of the objects of the synthetic workload
+	benchNamespace = "bench"
+	// defaultBenchTolerance is the slowdown or increase of allocations
+	// tolerated before a benchmark is reported as a regression
+	defaultBenchTolerance = 0.2
+	// defaultBenchTime is the minimum time each phase is run for
+	defaultBenchTime = time.Second
+	// perfContractDuration is the longest validation of the workload of the
+	// performance contract
+	perfContractDuration = 2 * time.Second
+)
+
+// perfContractWorkload is the workload of the performance contract of the
+// configuration builder: 10,000 Ingresses, validated in less than
+// perfContractDuration
+var perfContractWorkload = benchWorkload{Hosts: 10000, Paths: 5}
+
+// benchWorkload describes a synthetic set of Ingresses: one Ingress per host
+// with paths routed to the Service of the host, and a canary Ingress routing
+// the same paths to another Service for the first hosts
+type benchWorkload struct {
+	Hosts    int `json:"hosts"`
+	Paths    int `json:"paths"`
+	Canaries int `json:"canaries"`
+}
+
+// benchResult is the measure of a phase of the pipeline for a workload
+type benchResult struct {
+	Name        string  `json:"name"`
+	NsPerOp     int64   `json:"nsPerOp"`
+	AllocsPerOp int64   `json:"allocsPerOp"`
+	BytesPerOp  int64   `json:"bytesPerOp"`
+	OpsPerSec   float64 `json:"opsPerSec"`
+}
+
+// benchReport is the output of the bench command, also used as baseline
+type benchReport struct {
+	Workload benchWorkload `json:"workload"`
+	Results  []benchResult `json:"results"`
+}
+
+// store returns a store containing the objects of the workload
+func (w benchWorkload) store(configMapName string) (*memoryStore, error) {
+	s := newMemoryStore(configMapName)
+	pathType := networking.PathTypePrefix
+	protocol := apiv1.ProtocolTCP
+
+	addService := func(name string) error {
+		svc := &apiv1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: benchNamespace},
+			Spec: apiv1.ServiceSpec{
+				Ports: []apiv1.ServicePort{{Name: "http", Protocol: protocol, Port: 80, TargetPort: intstr.FromInt32(8080)}},
+			},
+		}
+		ready := true
+		port := int32(8080)
+		portName := "http"
+		slice := &discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name + "-1",
+				Namespace: benchNamespace,
+				Labels:    map[string]string{discoveryv1.LabelServiceName: name},
+			},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints: []discoveryv1.Endpoint{
+				{Addresses: []string{"10.0.0.1"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}},
+				{Addresses: []string{"10.0.0.2"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}},
+			},
+			Ports: []discoveryv1.EndpointPort{{Name: &portName, Protocol: &protocol, Port: &port}},
+		}
+		if err := s.Add(svc); err != nil {
+			return err
+		}
+		return s.Add(slice)
+	}
+
+	addIngress := func(name, host, service string, annotations map[string]string) error {
+		paths := make([]networking.HTTPIngressPath, 0, w.Paths)
+		for j := 0; j < w.Paths; j++ {
+			paths = append(paths, networking.HTTPIngressPath{
+				Path:     fmt.Sprintf("/path-%d", j),
+				PathType: &pathType,
+				Backend: networking.IngressBackend{
+					Service: &networking.IngressServiceBackend{
+						Name: service,
+						Port: networking.ServiceBackendPort{Number: 80},
+					},
+				},
+			})
+		}
+		return s.Add(&networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: benchNamespace, Annotations: annotations},
+			Spec: networking.IngressSpec{
+				Rules: []networking.IngressRule{{
+					Host:             host,
+					IngressRuleValue: networking.IngressRuleValue{HTTP: &networking.HTTPIngressRuleValue{Paths: paths}},
+				}},
+			},
+		})
+	}
+
+	for i := 0; i < w.Hosts; i++ {
+		name := fmt.Sprintf("host-%d", i)
+		host := fmt.Sprintf("host-%d.bench.example.com", i)
+		if err := addService(name); err != nil {
+			return nil, err
+		}
+		if err := addIngress(name, host, name, nil); err != nil {
+			return nil, err
+		}
+		if i >= w.Canaries {
+			continue
+		}
+
+		canary := name + "-canary"
+		if err := addService(canary); err != nil {
+			return nil, err
+		}
+		if err := addIngress(canary, host, canary, map[string]string{
+			"nginx.ingress.kubernetes.io/canary":        "true",
+			"nginx.ingress.kubernetes.io/canary-weight": "10",
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// runBenchmarks measures the phases of the pipeline for the workload: the
+// parsing of the annotations, the build of the configuration and the complete
+// validation. The Benchmark functions of bench_test.go measure the same
+// phases for go test.
+func runBenchmarks(cfg *NginxConfiguration, w benchWorkload, benchTime time.Duration) (*benchReport, error) {
+	s, err := w.store(cfg.ConfigMapName)
+	if err != nil {
+		return nil, fmt.Errorf("error creating workload: %w", err)
+	}
+	n := newOfflineController(cfg, s)
+	ingresses := s.ListIngresses()
+
+	phases := []struct {
+		name string
+		run  func()
+	}{
+		{"parse", func() { s.ListIngresses() }},
+		{"build", func() { n.getConfiguration(ingresses) }},
+		{"validate", func() { n.validate(ingresses) }},
+	}
+
+	report := &benchReport{Workload: w}
+	for _, phase := range phases {
+		report.Results = append(report.Results, measure(phase.name, benchTime, phase.run))
+	}
+	return report, nil
+}
+
+// measure runs a phase, doubling the number of runs until they last at least
+// benchTime, and returns the time and the allocations of one run
+func measure(name string, benchTime time.Duration, run func()) benchResult {
+	run()
+
+	var before, after runtime.MemStats
+	for runs := int64(1); ; runs *= 2 {
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		for i := int64(0); i < runs; i++ {
+			run()
+		}
+		elapsed := time.Since(start)
+		runtime.ReadMemStats(&after)
+		if elapsed < benchTime {
+			continue
+		}
+
+		result := benchResult{
+			Name:        name,
+			NsPerOp:     elapsed.Nanoseconds() / runs,
+			AllocsPerOp: int64(after.Mallocs-before.Mallocs) / runs,
+			BytesPerOp:  int64(after.TotalAlloc-before.TotalAlloc) / runs,
+		}
+		if result.NsPerOp > 0 {
+			result.OpsPerSec = 1e9 / float64(result.NsPerOp)
+		}
+		return result
+	}
+}
+
+// benchRegressions compares the results with the baseline and describes the
+// phases slower or allocating more than tolerated
+func benchRegressions(report, baseline *benchReport, tolerance float64) []string {
+	var regressions []string
+	if report.Workload != baseline.Workload {
+		return []string{fmt.Sprintf("the baseline was recorded for the workload %+v, not %+v", baseline.Workload, report.Workload)}
+	}
+
+	previous := map[string]benchResult{}
+	for _, r := range baseline.Results {
+		previous[r.Name] = r
+	}
+	exceeds := func(value, base int64) bool {
+		return base > 0 && float64(value) > float64(base)*(1+tolerance)
+	}
+	for _, r := range report.Results {
+		base, ok := previous[r.Name]
+		if !ok {
+			continue
+		}
+		if exceeds(r.NsPerOp, base.NsPerOp) {
+			regressions = append(regressions, fmt.Sprintf("%v: %d ns/op, baseline %d ns/op (+%.0f%%)",
+				r.Name, r.NsPerOp, base.NsPerOp, 100*(float64(r.NsPerOp)/float64(base.NsPerOp)-1)))
+		}
+		if exceeds(r.AllocsPerOp, base.AllocsPerOp) {
+			regressions = append(regressions, fmt.Sprintf("%v: %d allocs/op, baseline %d allocs/op (+%.0f%%)",
+				r.Name, r.AllocsPerOp, base.AllocsPerOp, 100*(float64(r.AllocsPerOp)/float64(base.AllocsPerOp)-1)))
+		}
+	}
+	return regressions
+}
+
+// loadBenchReport reads a report written with --output json
+func loadBenchReport(path string) (*benchReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &benchReport{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("error parsing benchmark baseline %v: %w", path, err)
+	}
+	return report, nil
+}
+
+// writeBenchReport prints the results in the requested format
+func writeBenchReport(w io.Writer, format string, report *benchReport) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case "text":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "%d hosts x %d paths, %d canaries\n", report.Workload.Hosts, report.Workload.Paths, report.Workload.Canaries)
+		fmt.Fprintln(tw, "phase\tns/op\tops/s\tallocs/op\tB/op")
+		for _, r := range report.Results {
+			fmt.Fprintf(tw, "%v\t%d\t%.1f\t%d\t%d\n", r.Name, r.NsPerOp, r.OpsPerSec, r.AllocsPerOp, r.BytesPerOp)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// runBench measures the throughput and the allocations of the pipeline for a
+// synthetic workload, and compares them with a baseline to catch the
+// performance regressions of the configuration builder
+func runBench(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	cfg := &NginxConfiguration{}
+	addConfigurationFlags(fs, cfg)
+	w := benchWorkload{}
+	fs.IntVar(&w.Hosts, "hosts", 100, "Number of hosts of the workload, each with its own Ingress and Service.")
+	fs.IntVar(&w.Paths, "paths", 10, "Number of paths of each host.")
+	fs.IntVar(&w.Canaries, "canaries", 10, "Number of hosts with a canary Ingress.")
+	output := fs.String("output", "text", "Output format: text or json.")
+	baseline := fs.String("baseline", "", "Results of a previous run, in the format of --output json, to compare with.")
+	updateBaseline := fs.Bool("update-baseline", false, "Record the results in the --baseline file instead of comparing them.")
+	contract := fs.Bool("contract", false,
+		fmt.Sprintf("Run the workload of the performance contract, %d Ingresses, and fail when validating it takes more than %v.", perfContractWorkload.Hosts, perfContractDuration))
+	tolerance := fs.Float64("tolerance", defaultBenchTolerance,
+		"Increase of ns/op or allocs/op over the baseline tolerated, as a fraction (0.2 is 20%).")
+	benchTime := fs.Duration("benchtime", defaultBenchTime, "Minimum time each phase of the pipeline is run for.")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitInternal
+	}
+	if *contract {
+		w = perfContractWorkload
+	}
+	if w.Hosts < 1 || w.Paths < 1 || w.Canaries < 0 || w.Canaries > w.Hosts {
+		fmt.Fprintln(stderr, "--hosts and --paths must be positive and --canaries between 0 and --hosts")
+		return exitInternal
+	}
+	if *updateBaseline && *baseline == "" {
+		fmt.Fprintln(stderr, "--update-baseline requires --baseline")
+		return exitInternal
+	}
+
+	report, err := runBenchmarks(cfg, w, *benchTime)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitInternal
+	}
+	if err := writeBenchReport(stdout, *output, report); err != nil {
+		fmt.Fprintf(stderr, "error writing results: %v\n", err)
+		return exitInternal
+	}
+
+	if *contract {
+		for _, r := range report.Results {
+			if r.Name == "validate" && time.Duration(r.NsPerOp) > perfContractDuration {
+				fmt.Fprintf(stderr, "performance contract not met: validating %d Ingresses took %v, more than %v\n",
+					w.Hosts+w.Canaries, time.Duration(r.NsPerOp), perfContractDuration)
+				return exitErrors
+			}
+		}
+	}
+
+	if *updateBaseline {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = os.WriteFile(*baseline, append(data, '\n'), 0o644)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "error writing baseline: %v\n", err)
+			return exitInternal
+		}
+		fmt.Fprintf(stderr, "recorded the results in %v\n", *baseline)
+		return exitOK
+	}
+
+	if *baseline == "" {
+		return exitOK
+	}
+	previous, err := loadBenchReport(*baseline)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitInternal
+	}
+	regressions := benchRegressions(report, previous, *tolerance)
+	for _, r := range regressions {
+		fmt.Fprintf(stderr, "regression: %v\n", r)
+	}
+	if len(regressions) > 0 {
+		return exitErrors
+	}
+	return exitOK
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBenchWorkloadStore(t *testing.T) {
+	w := benchWorkload{Hosts: 3, Paths: 4, Canaries: 1}
+	s, err := w.store(defaultConfigMapName)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ingresses := s.ListIngresses()
+	if len(ingresses) != 4 {
+		t.Fatalf("expected 3 Ingresses and a canary, got %v", len(ingresses))
+	}
+	canaries := 0
+	for _, ing := range ingresses {
+		if len(ing.Spec.Rules) != 1 || len(ing.Spec.Rules[0].HTTP.Paths) != w.Paths {
+			t.Errorf("expected %v paths for %v, got %+v", w.Paths, ing.Name, ing.Spec.Rules)
+		}
+		if ing.ParsedAnnotations.Canary.Enabled {
+			canaries++
+		}
+	}
+	if canaries != 1 {
+		t.Errorf("expected one canary, got %v", canaries)
+	}
+
+	n := newOfflineController(&NginxConfiguration{ConfigMapName: defaultConfigMapName}, s)
+	_, findings := n.validate(ingresses)
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			t.Errorf("expected the workload to be valid, got %v", f)
+		}
+	}
+	_, _, cfg := n.getConfiguration(ingresses)
+	if len(cfg.Servers) != 4 {
+		t.Errorf("expected the 3 hosts and the default server, got %v servers", len(cfg.Servers))
+	}
+	for _, b := range cfg.Backends {
+		if b.Name != "upstream-default-backend" && len(b.Endpoints) != 2 {
+			t.Errorf("expected the endpoints of %v, got %v", b.Name, b.Endpoints)
+		}
+	}
+}
+
+func TestBenchRegressions(t *testing.T) {
+	workload := benchWorkload{Hosts: 10, Paths: 2, Canaries: 1}
+	baseline := &benchReport{
+		Workload: workload,
+		Results: []benchResult{
+			{Name: "build", NsPerOp: 1000, AllocsPerOp: 100},
+			{Name: "render", NsPerOp: 2000, AllocsPerOp: 0},
+		},
+	}
+
+	testCases := map[string]struct {
+		report   *benchReport
+		expected []string
+	}{
+		"within tolerance": {
+			report: &benchReport{Workload: workload, Results: []benchResult{
+				{Name: "build", NsPerOp: 1200, AllocsPerOp: 120},
+				{Name: "render", NsPerOp: 1000, AllocsPerOp: 50},
+			}},
+		},
+		"slower and allocating more": {
+			report: &benchReport{Workload: workload, Results: []benchResult{
+				{Name: "build", NsPerOp: 1500, AllocsPerOp: 130},
+				{Name: "render", NsPerOp: 2000},
+			}},
+			expected: []string{
+				"build: 1500 ns/op, baseline 1000 ns/op (+50%)",
+				"build: 130 allocs/op, baseline 100 allocs/op (+30%)",
+			},
+		},
+		"phase missing from the baseline": {
+			report: &benchReport{Workload: workload, Results: []benchResult{
+				{Name: "validate", NsPerOp: 1e9},
+			}},
+		},
+		"other workload": {
+			report: &benchReport{Workload: benchWorkload{Hosts: 100, Paths: 2, Canaries: 1}},
+			expected: []string{
+				"the baseline was recorded for the workload {Hosts:10 Paths:2 Canaries:1}, not {Hosts:100 Paths:2 Canaries:1}",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got := benchRegressions(tc.report, baseline, defaultBenchTolerance)
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestWriteBenchReport(t *testing.T) {
+	report := &benchReport{
+		Workload: benchWorkload{Hosts: 2, Paths: 3, Canaries: 1},
+		Results:  []benchResult{{Name: "build", NsPerOp: 2000, AllocsPerOp: 10, BytesPerOp: 512, OpsPerSec: 500000}},
+	}
+
+	var text bytes.Buffer
+	if err := writeBenchReport(&text, "text", report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `2 hosts x 3 paths, 1 canaries
+phase  ns/op  ops/s     allocs/op  B/op
+build  2000   500000.0  10         512
+`
+	if text.String() != expected {
+		t.Errorf("expected\n%v\ngot\n%v", expected, text.String())
+	}
+
+	// the JSON output is read back as a baseline
+	var out bytes.Buffer
+	if err := writeBenchReport(&out, "json", report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := os.WriteFile(path, out.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadBenchReport(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, report) {
+		t.Errorf("expected %+v, got %+v", report, loaded)
+	}
+
+	if err := writeBenchReport(&out, "yaml", report); err == nil || !strings.Contains(err.Error(), `unknown output format "yaml"`) {
+		t.Errorf("expected an unknown format error, got %v", err)
+	}
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadBenchReport(path); err == nil || !strings.Contains(err.Error(), "error parsing benchmark baseline") {
+		t.Errorf("expected a parse error, got %v", err)
+	}
+}
+
+func TestRunBench(t *testing.T) {
+	testCases := map[string]struct {
+		args     []string
+		expected int
+		stderr   string
+	}{
+		"no hosts": {
+			args:     []string{"--hosts", "0"},
+			expected: exitInternal,
+			stderr:   "--hosts and --paths must be positive",
+		},
+		"more canaries than hosts": {
+			args:     []string{"--hosts", "1", "--canaries", "2"},
+			expected: exitInternal,
+			stderr:   "--canaries between 0 and --hosts",
+		},
+		"update without baseline": {
+			args:     []string{"--update-baseline"},
+			expected: exitInternal,
+			stderr:   "--update-baseline requires --baseline",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := runBench(tc.args, &stdout, &stderr); code != tc.expected {
+				t.Errorf("expected exit code %v, got %v (%v)", tc.expected, code, stderr.String())
+			}
+			if !strings.Contains(stderr.String(), tc.stderr) {
+				t.Errorf("expected %q in %q", tc.stderr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunBenchBaseline(t *testing.T) {
+	baseline := filepath.Join(t.TempDir(), "baseline.json")
+	args := []string{"--hosts", "2", "--paths", "1", "--canaries", "1", "--benchtime", "1ms", "--output", "json", "--baseline", baseline}
+
+	var stdout, stderr bytes.Buffer
+	if code := runBench(append(args, "--update-baseline"), &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected exit code %v, got %v (%v)", exitOK, code, stderr.String())
+	}
+	report := &benchReport{}
+	if err := json.Unmarshal(stdout.Bytes(), report); err != nil {
+		t.Fatalf("unexpected error decoding the results: %v", err)
+	}
+	phases := []string{}
+	for _, r := range report.Results {
+		phases = append(phases, r.Name)
+		if r.NsPerOp <= 0 || r.OpsPerSec <= 0 || r.AllocsPerOp <= 0 {
+			t.Errorf("expected a measure for %v, got %+v", r.Name, r)
+		}
+	}
+	if strings.Join(phases, ",") != "parse,build,validate" {
+		t.Errorf("unexpected phases %v", phases)
+	}
+
+	// a baseline recorded for another workload can not be compared
+	stdout.Reset()
+	stderr.Reset()
+	args[1] = "3"
+	if code := runBench(args, &stdout, &stderr); code != exitErrors {
+		t.Errorf("expected exit code %v, got %v", exitErrors, code)
+	}
+	if !strings.Contains(stderr.String(), "regression: the baseline was recorded for the workload") {
+		t.Errorf("expected a workload mismatch, got %q", stderr.String())
+	}
+}
+
+// benchWorkloads are the workloads of the benchmarks of the pipeline, run
+// with go test -bench . -benchmem and compared between commits with benchstat
+var benchWorkloads = []benchWorkload{
+	{Hosts: 100, Paths: 10, Canaries: 10},
+	{Hosts: 1000, Paths: 5, Canaries: 100},
+}
+
+// benchController returns a controller whose store contains the objects of
+// the workload
+func benchController(b *testing.B, w benchWorkload) (*NGINXController, []*Ingress) {
+	b.Helper()
+
+	s, err := w.store(defaultConfigMapName)
+	if err != nil {
+		b.Fatalf("unexpected error creating the workload: %v", err)
+	}
+	n := newOfflineController(&NginxConfiguration{ConfigMapName: defaultConfigMapName}, s)
+	return n, s.ListIngresses()
+}
+
+// runWorkloads runs the benchmark for every workload of benchWorkloads
+func runWorkloads(b *testing.B, run func(b *testing.B, n *NGINXController, ingresses []*Ingress)) {
+	for _, w := range benchWorkloads {
+		b.Run(fmt.Sprintf("hosts=%d/paths=%d/canaries=%d", w.Hosts, w.Paths, w.Canaries), func(b *testing.B) {
+			n, ingresses := benchController(b, w)
+			b.ReportAllocs()
+			b.ResetTimer()
+			run(b, n, ingresses)
+		})
+	}
+}
+
+func BenchmarkParseAnnotations(b *testing.B) {
+	runWorkloads(b, func(b *testing.B, n *NGINXController, _ []*Ingress) {
+		for i := 0; i < b.N; i++ {
+			n.store.ListIngresses()
+		}
+	})
+}
+
+func BenchmarkGetConfiguration(b *testing.B) {
+	runWorkloads(b, func(b *testing.B, n *NGINXController, ingresses []*Ingress) {
+		for i := 0; i < b.N; i++ {
+			n.getConfiguration(ingresses)
+		}
+	})
+}
+
+func BenchmarkValidate(b *testing.B) {
+	runWorkloads(b, func(b *testing.B, n *NGINXController, ingresses []*Ingress) {
+		for i := 0; i < b.N; i++ {
+			n.validate(ingresses)
+		}
+	})
+}
+
+// BenchmarkValidateContract measures the workload of the performance
+// contract, recorded in testdata/bench/contract.txt with
+// go test -run '^$' -bench Contract -benchmem -count 5
+func BenchmarkValidateContract(b *testing.B) {
+	n, ingresses := benchController(b, perfContractWorkload)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n.validate(ingresses)
+	}
+	if elapsed := b.Elapsed() / time.Duration(b.N); elapsed > perfContractDuration {
+		b.Errorf("performance contract not met: validating %d Ingresses took %v, more than %v", perfContractWorkload.Hosts, elapsed, perfContractDuration)
+	}
+}
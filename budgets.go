@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// approximate size of the nginx.conf rendered by ingress-nginx: the http
+// block and default server, and what each server and location adds to it.
+// Used for the budget of the size when the rendered nginx.conf is not given.
+const (
+	estimatedBaseConfigSize     = 40 * 1024
+	estimatedServerConfigSize   = 2560
+	estimatedLocationConfigSize = 3584
+)
+
+// checkBudgets reports configurations exceeding the budgets of the cluster:
+// rendered size, number of servers, locations and regular expressions, and
+// the hash sizes nginx only checks when reloading
+func (n *NGINXController) checkBudgets(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+
+	if n.cfg.MaxConfigSize > 0 {
+		// the size of the nginx.conf rendered for the input when there is
+		// one, an estimate otherwise
+		var message string
+		if info, err := os.Stat(n.cfg.NginxConf); n.cfg.NginxConf != "" && err == nil {
+			if info.Size() > n.cfg.MaxConfigSize {
+				message = fmt.Sprintf("the rendered nginx.conf %v is %v, over the budget of %v",
+					n.cfg.NginxConf, formatNginxSize(info.Size()), formatNginxSize(n.cfg.MaxConfigSize))
+			}
+		} else if size := estimatedConfigSize(cfg); size > n.cfg.MaxConfigSize {
+			message = fmt.Sprintf("the size of the rendered nginx.conf is estimated at %v from its servers, locations and snippets, over the budget of %v; an estimate only, measure it with --nginx-conf",
+				formatNginxSize(size), formatNginxSize(n.cfg.MaxConfigSize))
+		}
+		if message != "" {
+			findings = append(findings, Finding{
+				Rule:     "budget-config-size",
+				Severity: SeverityWarning,
+				Message:  message + "; reloads get slower and use more memory in every worker",
+			})
+		}
+	}
+
+	servers := 0
+	for _, server := range cfg.Servers {
+		if server.Hostname != "_" {
+			servers++
+		}
+	}
+	if n.cfg.MaxServers > 0 && servers > n.cfg.MaxServers {
+		findings = append(findings, Finding{
+			Rule:     "budget-servers",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("the configuration has %d servers, over the budget of %d", servers, n.cfg.MaxServers),
+		})
+	}
+
+	regexLocations := 0
+	for _, server := range cfg.Servers {
+		// the template writes every location of the server as a regular
+		// expression as soon as one of them needs it
+		if enforceRegexModifier(server.Locations) {
+			regexLocations += len(server.Locations)
+		}
+
+		if n.cfg.MaxLocationsPerServer > 0 && len(server.Locations) > n.cfg.MaxLocationsPerServer {
+			findings = append(findings, Finding{
+				Rule:     "budget-locations",
+				Severity: SeverityWarning,
+				Ingress:  serverIngress(server),
+				Host:     server.Hostname,
+				Message: fmt.Sprintf("server %v has %d locations, over the budget of %d per server",
+					server.Hostname, len(server.Locations), n.cfg.MaxLocationsPerServer),
+			})
+		}
+	}
+	if n.cfg.MaxRegexLocations > 0 && regexLocations > n.cfg.MaxRegexLocations {
+		findings = append(findings, Finding{
+			Rule:     "budget-regex-locations",
+			Severity: SeverityWarning,
+			Message: fmt.Sprintf("the configuration has %d regular expression locations, over the budget of %d; nginx evaluates them in order for every request not matching an exact location",
+				regexLocations, n.cfg.MaxRegexLocations),
+		})
+	}
+
+	findings = append(findings, n.checkServerNamesHash(cfg)...)
+	return findings
+}
+
+// checkServerNamesHash reports server names nginx can not store in an
+// optimal server_names_hash. ingress-nginx raises the bucket size and the
+// maximum size of the ConfigMap to fit the longest name and the total length
+// of the names, nginx still logs "could not build optimal server_names_hash"
+// at every reload when the names collide too much for these sizes, and then
+// ignores the bucket size, slowing down the lookup of every request.
+func (n *NGINXController) checkServerNamesHash(cfg *Configuration) []Finding {
+	global := n.store.GetBackendConfiguration()
+	names := serverNames(cfg)
+	bucketSize, maxSize := serverNamesHashSizes(names, global.ServerNameHashBucketSize, global.ServerNameHashMaxSize)
+	if nginxHashFits(names, bucketSize, maxSize) {
+		return []Finding{}
+	}
+	return []Finding{{
+		Rule:     "budget-server-names-hash",
+		Severity: SeverityWarning,
+		Message: fmt.Sprintf("nginx can not build an optimal server_names_hash of %d names with a server-names-hash-max-size of %d and a server-names-hash-bucket-size of %d, it logs a warning at every reload and ignores the bucket size; raise server-names-hash-max-size",
+			len(names), maxSize, bucketSize),
+	}}
+}
+
+// serverNames returns the exact names of the server_names_hash: the host
+// and aliases of every server, and the host of the from-to-www redirect.
+// Wildcard and regular expression names are stored elsewhere.
+func serverNames(cfg *Configuration) []string {
+	names := sets.New[string]()
+	for _, server := range cfg.Servers {
+		hosts := append([]string{server.Hostname}, server.Aliases...)
+		if server.RedirectFromToWWW {
+			if strings.HasPrefix(server.Hostname, "www.") {
+				hosts = append(hosts, strings.TrimPrefix(server.Hostname, "www."))
+			} else {
+				hosts = append(hosts, "www."+server.Hostname)
+			}
+		}
+		for _, host := range hosts {
+			if host != "" && !strings.HasPrefix(host, "*") && !strings.HasPrefix(host, "~") && !strings.HasSuffix(host, ".*") {
+				names.Insert(strings.ToLower(host))
+			}
+		}
+	}
+	return sets.List(names)
+}
+
+// serverNamesHashSizes returns the server_names_hash_bucket_size and
+// server_names_hash_max_size rendered by ingress-nginx, which raises the
+// values of the ConfigMap to fit the longest name and the total length of the
+// names
+func serverNamesHashSizes(names []string, bucketSize, maxSize int) (int, int) {
+	longest, total := 0, 0
+	for _, name := range names {
+		longest = max(longest, len(name))
+		total += len(name)
+	}
+	return max(bucketSize, nginxHashBucketSize(longest)), max(maxSize, nextPowerOf2(total))
+}
+
+// nginxHashFits reports whether nginx finds a hash size up to maxSize storing
+// the names in buckets of bucketSize bytes, as ngx_hash_init does on 64 bit
+// CPUs
+func nginxHashFits(names []string, bucketSize, maxSize int) bool {
+	if len(names) == 0 {
+		return true
+	}
+	wordSize := 8
+	elementSize := func(name string) int {
+		return wordSize + (len(name)+2+wordSize-1)&^(wordSize-1)
+	}
+
+	keys := make([]uint64, len(names))
+	for i, name := range names {
+		for j := 0; j < len(name); j++ {
+			keys[i] = keys[i]*31 + uint64(name[j])
+		}
+	}
+
+	bucketSize -= wordSize
+	start := len(names) / (bucketSize / (2 * wordSize))
+	if start == 0 {
+		start = 1
+	}
+	if maxSize > 10000 && maxSize/len(names) < 100 {
+		start = maxSize - 1000
+	}
+
+next:
+	for size := start; size <= maxSize; size++ {
+		buckets := make([]int, size)
+		for i, name := range names {
+			key := keys[i] % uint64(size)
+			buckets[key] += elementSize(name)
+			if buckets[key] > bucketSize {
+				continue next
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// estimatedConfigSize approximates the size of the rendered nginx.conf from
+// the number of servers and locations and the length of the snippets
+func estimatedConfigSize(cfg *Configuration) int64 {
+	size := int64(estimatedBaseConfigSize)
+	for _, server := range cfg.Servers {
+		size += estimatedServerConfigSize + int64(len(server.ServerSnippet))
+		for _, loc := range server.Locations {
+			size += estimatedLocationConfigSize + int64(len(loc.ConfigurationSnippet))
+		}
+	}
+	return size
+}
+
+// nginxHashBucketSize returns the bucket size a hash needs to store a key of
+// the given length, as computed by nginx on 64 bit CPUs
+func nginxHashBucketSize(length int) int {
+	wordSize := 8
+	aligned := (length + 2 + wordSize - 1) &^ (wordSize - 1)
+	return nextPowerOf2(2*wordSize + aligned)
+}
+
+// nextPowerOf2 returns the smallest power of 2 greater or equal to v
+func nextPowerOf2(v int) int {
+	p := 1
+	for p < v {
+		p *= 2
+	}
+	return p
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const budgetManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+data:
+  server-name-hash-bucket-size: "32"
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: short
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/use-regex: "true"
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: a.io
+    http:
+      paths:
+      - path: /api/.*
+        pathType: ImplementationSpecific
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+      - path: /static/.*
+        pathType: ImplementationSpecific
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: long
+  namespace: default
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: payments.internal.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: payments
+            port:
+              number: 80
+`
+
+func TestCheckBudgets(t *testing.T) {
+	dir := t.TempDir()
+	small := writeTestFile(t, dir, "small.conf", strings.Repeat("#", 512))
+	large := writeTestFile(t, dir, "large.conf", strings.Repeat("#", 2048))
+
+	tests := []struct {
+		name     string
+		cfg      NginxConfiguration
+		expected []string
+		// message is a part of the message of the budget-config-size finding
+		message string
+	}{
+		{
+			name: "no budgets",
+		},
+		{
+			name: "within the budgets",
+			cfg:  NginxConfiguration{MaxConfigSize: 1024 * 1024, MaxServers: 2, MaxLocationsPerServer: 3, MaxRegexLocations: 3},
+		},
+		{
+			name: "over the budgets",
+			cfg:  NginxConfiguration{MaxConfigSize: 1024, MaxServers: 1, MaxLocationsPerServer: 2, MaxRegexLocations: 1},
+			expected: []string{
+				"budget-config-size ",
+				"budget-servers ",
+				"budget-locations a.io",
+				"budget-regex-locations ",
+			},
+			message: "estimated at",
+		},
+		{
+			name: "rendered nginx.conf within the budget",
+			cfg:  NginxConfiguration{MaxConfigSize: 1024, NginxConf: small},
+		},
+		{
+			name:     "rendered nginx.conf over the budget",
+			cfg:      NginxConfiguration{MaxConfigSize: 1024, NginxConf: large},
+			expected: []string{"budget-config-size "},
+			message:  "is 2k, over the budget of 1k",
+		},
+		{
+			name:     "missing rendered nginx.conf",
+			cfg:      NginxConfiguration{MaxConfigSize: 1024, NginxConf: filepath.Join(dir, "missing.conf")},
+			expected: []string{"budget-config-size "},
+			message:  "an estimate only",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n := newTestController(t, budgetManifests)
+			n.cfg.MaxConfigSize = tc.cfg.MaxConfigSize
+			n.cfg.NginxConf = tc.cfg.NginxConf
+			n.cfg.MaxServers = tc.cfg.MaxServers
+			n.cfg.MaxLocationsPerServer = tc.cfg.MaxLocationsPerServer
+			n.cfg.MaxRegexLocations = tc.cfg.MaxRegexLocations
+			ingresses := n.store.ListIngresses()
+			_, _, cfg := n.getConfiguration(ingresses)
+
+			got := []string{}
+			for _, f := range n.checkBudgets(ingresses, cfg) {
+				got = append(got, f.Rule+" "+f.Host)
+				if f.Rule == "budget-config-size" && !strings.Contains(f.Message, tc.message) {
+					t.Errorf("expected a message containing %q, got %q", tc.message, f.Message)
+				}
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestNginxHashBucketSize(t *testing.T) {
+	tests := map[int]int{
+		1:  32,
+		6:  32,
+		14: 32,
+		15: 64,
+		29: 64,
+		46: 64,
+		47: 128,
+	}
+
+	for length, expected := range tests {
+		if size := nginxHashBucketSize(length); size != expected {
+			t.Errorf("length %d: expected %d, got %d", length, expected, size)
+		}
+	}
+}
+
+// rewriteBudgetManifests has a server enforcing regular expressions with a
+// rewrite and a host longer than the bucket size of the ConfigMap
+const rewriteBudgetManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+data:
+  server-name-hash-bucket-size: "32"
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+  namespace: default
+spec:
+  ports:
+  - port: 80
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: rewrite
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/rewrite-target: /$2
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: a-very-long-host-name-of-the-justice-digital-platform.example.com
+    http:
+      paths:
+      - path: /api(/|$)(.*)
+        pathType: ImplementationSpecific
+        backend:
+          service:
+            name: app
+            port:
+              number: 80
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: prefix
+  namespace: default
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: a-very-long-host-name-of-the-justice-digital-platform.example.com
+    http:
+      paths:
+      - path: /static
+        pathType: Prefix
+        backend:
+          service:
+            name: app
+            port:
+              number: 80
+`
+
+func TestCheckBudgetsRegexLocations(t *testing.T) {
+	n, ingresses, cfg := testConfiguration(t, rewriteBudgetManifests)
+	n.cfg.MaxRegexLocations = 3
+
+	findings := n.checkBudgets(ingresses, cfg)
+	if len(findings) != 1 || findings[0].Rule != "budget-regex-locations" {
+		t.Fatalf("expected a budget-regex-locations finding, got %v", findings)
+	}
+	// /api(/|$)(.*), /static/, = /static and / are all regular expressions
+	if !strings.Contains(findings[0].Message, "has 4 regular expression locations") {
+		t.Errorf("expected every location of the server to be counted, got %v", findings[0].Message)
+	}
+}
+
+func TestCheckServerNamesHash(t *testing.T) {
+	n, _, cfg := testConfiguration(t, rewriteBudgetManifests)
+
+	// the controller raises the bucket size of the ConfigMap to fit the host
+	if findings := n.checkServerNamesHash(cfg); len(findings) != 0 {
+		t.Errorf("expected no finding for a host longer than the bucket size of the ConfigMap, got %v", findings)
+	}
+
+	names := serverNames(cfg)
+	bucketSize, maxSize := serverNamesHashSizes(names, 32, 512)
+	if bucketSize != 128 || maxSize != 512 {
+		t.Errorf("expected a bucket size of 128 and a max size of 512, got %d and %d", bucketSize, maxSize)
+	}
+}
+
+func TestNginxHashFits(t *testing.T) {
+	names := make([]string, 0, 500)
+	for i := 0; i < 500; i++ {
+		names = append(names, fmt.Sprintf("app-%d.apps.live.cloud-platform.service.justice.gov.uk", i))
+	}
+
+	if nginxHashFits(names, 64, 64) {
+		t.Errorf("expected 500 names not to fit in 64 buckets of 64 bytes")
+	}
+	bucketSize, maxSize := serverNamesHashSizes(names, 0, 512)
+	if !nginxHashFits(names, bucketSize, maxSize) {
+		t.Errorf("expected the names to fit in the sizes of the controller, %d and %d", bucketSize, maxSize)
+	}
+}
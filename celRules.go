@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// objects the CEL rules are evaluated against
+const (
+	celObjectServer   = "server"
+	celObjectLocation = "location"
+	celObjectBackend  = "backend"
+)
+
+// CELRule is a rule written in the Common Expression Language. The
+// expression is evaluated against every server, location or backend of the
+// configuration, in their JSON form, and a finding is reported when it is
+// false. The location rules also have access to the server of the location.
+type CELRule struct {
+	Name string `json:"name"`
+	// Object is server, location or backend
+	Object     string `json:"object"`
+	Expression string `json:"expression"`
+	// Severity of the findings, warning when empty
+	// +optional
+	Severity Severity `json:"severity,omitempty"`
+	// Message of the findings, the expression when empty
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// celEnvironments declare the variables of the rules of each object
+var celEnvironments = map[string][]cel.EnvOption{
+	celObjectServer:   {cel.Variable("server", cel.DynType)},
+	celObjectLocation: {cel.Variable("server", cel.DynType), cel.Variable("location", cel.DynType)},
+	celObjectBackend:  {cel.Variable("backend", cel.DynType)},
+}
+
+var (
+	// celPrograms caches the compiled rules, by object and expression
+	celPrograms     = map[string]cel.Program{}
+	celProgramsLock sync.Mutex
+)
+
+// program returns the compiled expression of the rule
+func (r *CELRule) program() (cel.Program, error) {
+	key := r.Object + "\x00" + r.Expression
+	celProgramsLock.Lock()
+	defer celProgramsLock.Unlock()
+	if prg, ok := celPrograms[key]; ok {
+		return prg, nil
+	}
+
+	options, ok := celEnvironments[r.Object]
+	if !ok {
+		return nil, fmt.Errorf("unknown object %q, expected server, location or backend", r.Object)
+	}
+	env, err := cel.NewEnv(options...)
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(r.Expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("expression returns %v instead of a bool", ast.OutputType())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	celPrograms[key] = prg
+	return prg, nil
+}
+
+// validateCELRules returns the errors of the rules
+func validateCELRules(rules []CELRule) error {
+	var errs []error
+	names := map[string]bool{}
+	for i := range rules {
+		r := &rules[i]
+		if r.Name == "" {
+			errs = append(errs, fmt.Errorf("CEL rule %d has no name", i))
+		} else if names[r.Name] {
+			errs = append(errs, fmt.Errorf("duplicate CEL rule %v", r.Name))
+		}
+		names[r.Name] = true
+
+		switch r.Severity {
+		case "", SeverityError, SeverityWarning, SeverityInfo:
+		default:
+			errs = append(errs, fmt.Errorf("unknown severity %q for CEL rule %v", r.Severity, r.Name))
+		}
+		if _, err := r.program(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid CEL rule %v: %w", r.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// loadCELRules reads the YAML or JSON list of rules of a file
+func loadCELRules(path string) ([]CELRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rules := []CELRule{}
+	if err := yaml.NewYAMLOrJSONDecoder(f, 4096).Decode(&rules); err != nil {
+		return nil, fmt.Errorf("error parsing %v: %w", path, err)
+	}
+	if err := validateCELRules(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// celValue returns the JSON form of an object, as seen by the expressions
+func celValue(obj interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	value := map[string]interface{}{}
+	return value, json.Unmarshal(raw, &value)
+}
+
+// eval returns true when the object complies with the rule
+func (r *CELRule) eval(vars map[string]interface{}) (bool, error) {
+	prg, err := r.program()
+	if err != nil {
+		return false, err
+	}
+	out, _, err := prg.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+	ok, isBool := out.Value().(bool)
+	if !isBool {
+		return false, fmt.Errorf("expression returned %v instead of a bool", out.Type())
+	}
+	return ok, nil
+}
+
+// checkCELRules evaluates the CEL rules against the servers, locations and
+// backends of the configuration. Rules failing to evaluate, for instance
+// because of a missing field, are reported as warnings.
+func (n *NGINXController) checkCELRules(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	if len(n.cfg.CELRules) == 0 {
+		return findings
+	}
+
+	report := func(r *CELRule, f Finding, object string, ok bool, err error) {
+		f.Rule = r.Name
+		switch {
+		case err != nil:
+			f.Severity = SeverityWarning
+			f.Message = fmt.Sprintf("CEL rule %v could not be evaluated for %v: %v", r.Name, object, err)
+		case ok:
+			return
+		default:
+			f.Severity = r.Severity
+			if f.Severity == "" {
+				f.Severity = SeverityWarning
+			}
+			message := r.Message
+			if message == "" {
+				message = fmt.Sprintf("%q is false", r.Expression)
+			}
+			f.Message = fmt.Sprintf("%v: %v", object, message)
+		}
+		findings = append(findings, f)
+	}
+
+	values := map[interface{}]map[string]interface{}{}
+	value := func(obj interface{}) (map[string]interface{}, error) {
+		if v, ok := values[obj]; ok {
+			return v, nil
+		}
+		v, err := celValue(obj)
+		values[obj] = v
+		return v, err
+	}
+
+	for i := range n.cfg.CELRules {
+		r := &n.cfg.CELRules[i]
+		switch r.Object {
+		case celObjectServer, celObjectLocation:
+			for _, server := range cfg.Servers {
+				if server.Hostname == "_" {
+					continue
+				}
+				sv, err := value(server)
+				if r.Object == celObjectServer {
+					f := Finding{Host: server.Hostname, Ingress: serverIngress(server)}
+					ok := false
+					if err == nil {
+						ok, err = r.eval(map[string]interface{}{"server": sv})
+					}
+					report(r, f, "server "+server.Hostname, ok, err)
+					continue
+				}
+
+				for _, loc := range server.Locations {
+					lv, locErr := value(loc)
+					ok := false
+					if err == nil && locErr == nil {
+						ok, locErr = r.eval(map[string]interface{}{"server": sv, "location": lv})
+					} else if locErr == nil {
+						locErr = err
+					}
+					f := newLocationFinding(r.Name, r.Severity, server, loc, "")
+					report(r, f, fmt.Sprintf("location %v of %v", loc.Path, server.Hostname), ok, locErr)
+				}
+			}
+		case celObjectBackend:
+			for _, backend := range cfg.Backends {
+				bv, err := value(backend)
+				ok := false
+				if err == nil {
+					ok, err = r.eval(map[string]interface{}{"backend": bv})
+				}
+				report(r, Finding{}, "backend "+backend.Name, ok, err)
+			}
+		}
+	}
+	return findings
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateCELRules(t *testing.T) {
+	testCases := map[string]struct {
+		rules    []CELRule
+		expected string
+	}{
+		"valid": {
+			rules: []CELRule{
+				{Name: "tls", Object: celObjectServer, Expression: "has(server.sslCert)", Severity: SeverityError},
+				{Name: "root", Object: celObjectLocation, Expression: `location.path != "/" || server.hostname != ""`},
+				{Name: "backend", Object: celObjectBackend, Expression: "backend.port > 0"},
+			},
+		},
+		"no name": {
+			rules:    []CELRule{{Object: celObjectServer, Expression: "true"}},
+			expected: "CEL rule 0 has no name",
+		},
+		"duplicate name": {
+			rules: []CELRule{
+				{Name: "tls", Object: celObjectServer, Expression: "true"},
+				{Name: "tls", Object: celObjectBackend, Expression: "true"},
+			},
+			expected: "duplicate CEL rule tls",
+		},
+		"unknown severity": {
+			rules:    []CELRule{{Name: "tls", Object: celObjectServer, Expression: "true", Severity: "critical"}},
+			expected: `unknown severity "critical" for CEL rule tls`,
+		},
+		"unknown object": {
+			rules:    []CELRule{{Name: "tls", Object: "ingress", Expression: "true"}},
+			expected: `invalid CEL rule tls: unknown object "ingress"`,
+		},
+		"variable of another object": {
+			rules:    []CELRule{{Name: "tls", Object: celObjectBackend, Expression: "has(server.sslCert)"}},
+			expected: "undeclared reference to 'server'",
+		},
+		"syntax error": {
+			rules:    []CELRule{{Name: "tls", Object: celObjectServer, Expression: "server."}},
+			expected: "invalid CEL rule tls",
+		},
+		"not a bool": {
+			rules:    []CELRule{{Name: "tls", Object: celObjectServer, Expression: "1 + 1"}},
+			expected: "expression returns int instead of a bool",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := validateCELRules(tc.rules)
+			if tc.expected == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("expected an error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestLoadCELRules(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "rules.yaml", `- name: tls
+  object: server
+  expression: has(server.sslCert)
+  severity: error
+  message: the server has no certificate
+`)
+	rules, err := loadCELRules(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := CELRule{Name: "tls", Object: celObjectServer, Expression: "has(server.sslCert)", Severity: SeverityError, Message: "the server has no certificate"}
+	if len(rules) != 1 || rules[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, rules)
+	}
+
+	for content, expected := range map[string]string{
+		"name: tls\n": "error parsing " + dir,
+		"- name: tls\n  object: server\n  expression: 1\n": "expression returns int instead of a bool",
+	} {
+		if _, err := loadCELRules(writeTestFile(t, dir, "invalid.yaml", content)); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected an error containing %q for %q, got %v", expected, content, err)
+		}
+	}
+	if _, err := loadCELRules(dir + "/missing.yaml"); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
+
+func TestCheckCELRules(t *testing.T) {
+	n, ingresses, cfg := testConfiguration(t, isolationManifests)
+	if findings := n.checkCELRules(ingresses, cfg); len(findings) != 0 {
+		t.Errorf("expected no findings without rules, got %+v", findings)
+	}
+
+	testCases := map[string]struct {
+		rule     CELRule
+		expected []Finding
+	}{
+		"server complying": {
+			rule: CELRule{Name: "domain", Object: celObjectServer, Expression: `server.hostname.endsWith(".example.com")`},
+		},
+		"server": {
+			rule: CELRule{Name: "tls", Object: celObjectServer, Expression: `has(server.sslCert) && server.sslCert != null`, Severity: SeverityError},
+			expected: []Finding{{
+				Rule: "tls", Severity: SeverityError, Ingress: "default/api", Host: "web.example.com",
+				Message: `server web.example.com: "has(server.sslCert) && server.sslCert != null" is false`,
+			}},
+		},
+		"location with its server": {
+			rule: CELRule{
+				Name:       "api",
+				Object:     celObjectLocation,
+				Expression: `!location.path.startsWith("/api") || server.hostname == "api.example.com"`,
+				Message:    "the API is served on api.example.com",
+			},
+			expected: []Finding{
+				{Rule: "api", Severity: SeverityWarning, Ingress: "default/api", Host: "web.example.com", Path: "/api/", Message: "location /api/ of web.example.com: the API is served on api.example.com"},
+				// the Exact location added for the Prefix path
+				{Rule: "api", Severity: SeverityWarning, Ingress: "default/api", Host: "web.example.com", Path: "/api", Message: "location /api of web.example.com: the API is served on api.example.com"},
+			},
+		},
+		"backend": {
+			rule: CELRule{Name: "api-backend", Object: celObjectBackend, Expression: `backend.name != "default-api-80"`, Severity: SeverityInfo},
+			expected: []Finding{{
+				Rule: "api-backend", Severity: SeverityInfo,
+				Message: `backend default-api-80: "backend.name != \"default-api-80\"" is false`,
+			}},
+		},
+		"missing field": {
+			rule: CELRule{Name: "missing", Object: celObjectServer, Expression: `server.missing == "x"`, Severity: SeverityError},
+			expected: []Finding{{
+				Rule: "missing", Severity: SeverityWarning, Ingress: "default/api", Host: "web.example.com",
+				Message: "CEL rule missing could not be evaluated for server web.example.com: no such key: missing",
+			}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n.cfg.CELRules = []CELRule{tc.rule}
+			findings := n.checkCELRules(ingresses, cfg)
+			if len(findings) != len(tc.expected) {
+				t.Fatalf("expected %+v, got %+v", tc.expected, findings)
+			}
+			for i := range tc.expected {
+				if findings[i] != tc.expected[i] {
+					t.Errorf("expected %+v, got %+v", tc.expected[i], findings[i])
+				}
+			}
+		})
+	}
+}
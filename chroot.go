@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// defaultChrootDirectory is the directory nginx is chrooted into by the
+// chroot image of the ingress controller
+const defaultChrootDirectory = "/chroot"
+
+// pathMapper translates the paths of the files shared with nginx, such as
+// certificates, CRLs and the configuration being tested, between the
+// filesystem of the validator and the one of nginx. They differ when nginx
+// runs chrooted: the file nginx reads as /etc/ingress-controller/ssl/a.pem
+// is /chroot/etc/ingress-controller/ssl/a.pem for the validator.
+//
+// The file names of the configuration (PemFileName, CAFileName, CRLFileName)
+// are always the paths of nginx, they must go through local before being read
+// or written.
+type pathMapper struct {
+	// root is the directory nginx is chrooted into, empty when it is not
+	root string
+}
+
+// paths returns the path mapping of the nginx the configuration is validated
+// for, selected with --chroot
+func (n *NGINXController) paths() pathMapper {
+	if !n.cfg.IsChroot {
+		return pathMapper{}
+	}
+	if n.cfg.ChrootDirectory == "" {
+		return pathMapper{root: defaultChrootDirectory}
+	}
+	return pathMapper{root: n.cfg.ChrootDirectory}
+}
+
+// local returns the path used by the validator for a path of nginx
+func (m pathMapper) local(path string) string {
+	if m.root == "" || !filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(m.root, path)
+}
+
+// nginx returns the path used by nginx for a path of the validator. It fails
+// if the file is outside of the chroot, and so not visible to nginx.
+func (m pathMapper) nginx(path string) (string, error) {
+	if m.root == "" {
+		return path, nil
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(m.root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%v is outside of the nginx chroot %v", path, m.root)
+	}
+	return filepath.Join("/", rel), nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/util/file"
+)
+
+func TestPaths(t *testing.T) {
+	n := newTestController(t, "")
+	if root := n.paths().root; root != "" {
+		t.Errorf("expected no chroot, got %q", root)
+	}
+	n.cfg.IsChroot = true
+	if root := n.paths().root; root != defaultChrootDirectory {
+		t.Errorf("expected the default chroot, got %q", root)
+	}
+	n.cfg.ChrootDirectory = "/var/chroot"
+	if root := n.paths().root; root != "/var/chroot" {
+		t.Errorf("expected the configured chroot, got %q", root)
+	}
+}
+
+func TestPathMapper(t *testing.T) {
+	chroot := pathMapper{root: "/chroot"}
+	tests := []struct {
+		mapper pathMapper
+		nginx  string
+		local  string
+	}{
+		{pathMapper{}, "/etc/ingress-controller/ssl/a.pem", "/etc/ingress-controller/ssl/a.pem"},
+		{chroot, "/etc/ingress-controller/ssl/a.pem", "/chroot/etc/ingress-controller/ssl/a.pem"},
+		{chroot, "/tmp/nginx-cfg123", "/chroot/tmp/nginx-cfg123"},
+		{chroot, "/", "/chroot"},
+	}
+
+	for _, tc := range tests {
+		if local := tc.mapper.local(tc.nginx); local != tc.local {
+			t.Errorf("%q: expected the local path %q, got %q", tc.nginx, tc.local, local)
+		}
+		if nginx, err := tc.mapper.nginx(tc.local); err != nil || nginx != tc.nginx {
+			t.Errorf("%q: expected the nginx path %q, got %q, %v", tc.local, tc.nginx, nginx, err)
+		}
+	}
+
+	if local := chroot.local("relative.pem"); local != "relative.pem" {
+		t.Errorf("expected relative paths to be kept, got %q", local)
+	}
+	for _, path := range []string{"/etc/ssl/bundle.pem", "/chroot-other/bundle.pem", "/chroot/../etc/bundle.pem"} {
+		if _, err := chroot.nginx(path); err == nil || !strings.Contains(err.Error(), "outside of the nginx chroot") {
+			t.Errorf("%q: expected an error for a file outside of the chroot, got %v", path, err)
+		}
+	}
+}
+
+func TestLoadSPIFFESVIDChroot(t *testing.T) {
+	now := time.Now()
+	ca, caKey, caPEM, _ := testCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "spiffe ca"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	uri, _ := url.Parse("spiffe://example.org/ns/ingress-nginx/sa/controller")
+	_, _, certPEM, keyPEM := testCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		URIs:         []*url.URL{uri},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	root := t.TempDir()
+	paths := pathMapper{root: root}
+	if err := os.MkdirAll(paths.local(file.DefaultSSLDirectory), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	writeSVID := func(dir string) {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			t.Fatal(err)
+		}
+		for name, data := range map[string][]byte{svidCertFileName: certPEM, svidKeyFileName: keyPEM, svidBundleFileName: caPEM} {
+			if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	svidDir := filepath.Join(root, "var", "run", "spiffe")
+	writeSVID(svidDir)
+	cert, err := loadSPIFFESVID(svidDir, "example.org", paths, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cert.CAFileName != "/var/run/spiffe/"+svidBundleFileName {
+		t.Errorf("expected the trust bundle path of nginx, got %q", cert.CAFileName)
+	}
+	if _, err := os.Stat(paths.local(cert.PemFileName)); err != nil {
+		t.Errorf("expected the PEM file to be written in the chroot: %v", err)
+	}
+
+	outside := t.TempDir()
+	writeSVID(outside)
+	if _, err := loadSPIFFESVID(outside, "example.org", paths, now); err == nil || !strings.Contains(err.Error(), "trust bundle not readable by nginx") {
+		t.Errorf("expected an error for a trust bundle outside of the chroot, got %v", err)
+	}
+}
@@ -0,0 +1,547 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/controller/ingressclass"
+)
+
+const defaultConfigMapName = "ingress-nginx/ingress-nginx-controller"
+
+// exit codes of the commands
+const (
+	// exitOK means no finding reached the failure threshold
+	exitOK = 0
+	// exitWarnings means warnings were found and --fail-on=warning or
+	// --strict is set
+	exitWarnings = 1
+	// exitErrors means errors were found
+	exitErrors = 2
+	// exitInternal means the command could not run: invalid flags, unreadable
+	// input or unreachable cluster
+	exitInternal = 3
+)
+
+// thresholds of --fail-on
+const (
+	failOnError   = "error"
+	failOnWarning = "warning"
+	failOnNone    = "none"
+)
+
+// findingsExitCode returns the exit code for the findings: exitErrors when
+// there are errors, exitWarnings when there are warnings and failOn is
+// warning, exitOK otherwise or when failOn is none
+func findingsExitCode(findings []Finding, failOn string) int {
+	if failOn == failOnNone {
+		return exitOK
+	}
+
+	code := exitOK
+	for _, f := range findings {
+		switch {
+		case f.Severity == SeverityError:
+			return exitErrors
+		case f.Severity == SeverityWarning && failOn == failOnWarning:
+			code = exitWarnings
+		}
+	}
+	return code
+}
+
+// stringSliceFlag is a flag that can be repeated
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// labelsFlag parses labels in the key1=value1,key2=value2 format
+type labelsFlag map[string]string
+
+func (l *labelsFlag) String() string {
+	return labels.Set(*l).String()
+}
+
+func (l *labelsFlag) Set(value string) error {
+	m, err := labels.ConvertSelectorToLabelsMap(value)
+	if err != nil {
+		return err
+	}
+	*l = labelsFlag(m)
+	return nil
+}
+
+// addConfigurationFlags registers the flags used to configure the controller
+// that builds and validates the configuration
+func addConfigurationFlags(fs *flag.FlagSet, cfg *NginxConfiguration) {
+	addLoggingFlags(fs)
+	fs.StringVar(&cfg.ConfigMapName, "configmap", defaultConfigMapName,
+		"Namespace/name of the ConfigMap containing the global nginx configuration.")
+	cfg.Namespace = "ingress-nginx"
+	fs.Func("namespace",
+		"Namespace where the ingress controller runs (default \"ingress-nginx\"). When set, also the namespace of the objects of the manifests without metadata.namespace, default otherwise.", func(value string) error {
+			cfg.Namespace = value
+			cfg.ManifestNamespace = value
+			return nil
+		})
+	fs.Func("watch-namespace-selector",
+		"Only validate Ingresses in namespaces matching this label selector.", func(value string) error {
+			selector, err := labels.Parse(value)
+			if err != nil {
+				return err
+			}
+			cfg.WatchNamespaceSelector = selector
+			return nil
+		})
+	fs.Func("ingress-class",
+		"Only validate the Ingresses of this class, as an ingress controller configured with --ingress-class would.", func(value string) error {
+			if cfg.IngressClassConfiguration == nil {
+				cfg.IngressClassConfiguration = &ingressclass.Configuration{Controller: ingressclass.DefaultControllerName}
+			}
+			cfg.IngressClassConfiguration.AnnotationValue = value
+			return nil
+		})
+	fs.Func("controller-class",
+		"Value of spec.controller of the IngressClasses handled by the controller, used with --ingress-class.", func(value string) error {
+			if cfg.IngressClassConfiguration == nil {
+				cfg.IngressClassConfiguration = &ingressclass.Configuration{}
+			}
+			cfg.IngressClassConfiguration.Controller = value
+			return nil
+		})
+	fs.BoolFunc("watch-ingress-without-class",
+		"Validate Ingresses without class, used with --ingress-class.", func(value string) error {
+			if cfg.IngressClassConfiguration == nil {
+				cfg.IngressClassConfiguration = &ingressclass.Configuration{Controller: ingressclass.DefaultControllerName}
+			}
+			b, err := strconv.ParseBool(value)
+			cfg.IngressClassConfiguration.WatchWithoutClass = b
+			return err
+		})
+	fs.Var((*stringSliceFlag)(&cfg.SimulatedIngressClasses), "simulate-ingress-class",
+		"Simulate an ingress controller instance for this class to detect hosts served by several instances. Can be repeated.")
+	fs.StringVar(&cfg.DefaultService, "default-backend-service", "",
+		"Namespace/name of the Service used as default backend.")
+	fs.StringVar(&cfg.TCPConfigMapName, "tcp-services-configmap", "",
+		"Namespace/name of the ConfigMap containing the definition of the TCP services to expose.")
+	fs.StringVar(&cfg.UDPConfigMapName, "udp-services-configmap", "",
+		"Namespace/name of the ConfigMap containing the definition of the UDP services to expose.")
+	fs.StringVar(&cfg.DefaultSSLCertificate, "default-ssl-certificate", "",
+		"Namespace/name of the Secret containing the default SSL certificate.")
+	fs.StringVar(&cfg.NginxVersion, "nginx-version", "",
+		"Version of nginx the configuration is validated against.")
+	fs.StringVar(&cfg.ControllerVersion, "controller-version", "",
+		"Version of the ingress controller the configuration is validated against, like v1.12.1.")
+	fs.BoolVar(&cfg.EnableExternalNameResolution, "enable-externalname-resolution", false,
+		"Resolve the name of Services of type ExternalName using the configured resolvers.")
+	fs.StringVar(&cfg.SPIFFESVIDDirectory, "spiffe-svid-dir", "",
+		"Directory containing the SPIFFE X.509 SVID used as client certificate against backends.")
+	fs.StringVar(&cfg.SPIFFETrustDomain, "spiffe-trust-domain", "",
+		"SPIFFE trust domain the SVID must belong to.")
+	fs.BoolVar(&cfg.IsChroot, "chroot", false,
+		"Validate for nginx running chrooted, as in the chroot image of the ingress controller: the certificates and the tested configuration are written below --chroot-dir.")
+	fs.StringVar(&cfg.ChrootDirectory, "chroot-dir", defaultChrootDirectory,
+		"Directory nginx is chrooted into, used with --chroot.")
+	fs.BoolVar(&cfg.EnableAvailabilityAudit, "enable-availability-audit", false,
+		"Report backends without PodDisruptionBudget and production hosts served by a single replica.")
+	fs.Var((*stringSliceFlag)(&cfg.ProductionHosts), "production-host",
+		"Pattern of the hosts considered production by the availability audit (e.g. *.service.justice.gov.uk). Can be repeated.")
+	fs.Var((*stringSliceFlag)(&cfg.AccessLogHosts), "access-log-host",
+		"Pattern of the hosts whose access log is required by the audit policy. Can be repeated.")
+	fs.Var((*stringSliceFlag)(&cfg.HeaderMergingBackends), "header-merging-backend",
+		"Pattern of the Services (namespace/name) whose servers merge headers differing only in dashes and underscores, such as CGI or WSGI applications. Can be repeated.")
+	fs.BoolVar(&cfg.RequireSecureAuthURL, "require-secure-auth-url", false,
+		"Report auth-url annotations not using HTTPS as errors.")
+	fs.BoolVar(&cfg.ProbeAuthURLs, "probe-auth-url", false,
+		"Send a HEAD request to every auth-url to detect authentication services that do not respond.")
+	fs.DurationVar(&cfg.AuthURLProbeTimeout, "auth-url-probe-timeout", defaultAuthURLProbeTimeout,
+		"Timeout of the auth-url probes.")
+	fs.Func("maxmind-edition-ids",
+		"Comma separated GeoIP2 editions loaded by nginx (e.g. GeoLite2-City,GeoLite2-ASN), whose databases are validated.", func(value string) error {
+			files := []string{}
+			for _, edition := range strings.Split(value, ",") {
+				if edition = strings.TrimSpace(edition); edition != "" {
+					files = append(files, edition+".mmdb")
+				}
+			}
+			cfg.MaxmindEditionFiles = &files
+			return nil
+		})
+	fs.StringVar(&cfg.MaxmindDirectory, "maxmind-dir", defaultMaxmindDirectory,
+		"Directory of the GeoIP2 databases, used with --maxmind-edition-ids.")
+	fs.DurationVar(&cfg.MaxmindMaxAge, "maxmind-max-age", defaultMaxmindMaxAge,
+		"Age after which a GeoIP2 database is reported as stale, 0 to disable the check.")
+	fs.Func("cel-rules",
+		"YAML or JSON file of rules written in the Common Expression Language, evaluated against the servers, locations or backends (e.g. server.sslCert != null).", func(value string) error {
+			rules, err := loadCELRules(value)
+			cfg.CELRules = rules
+			return err
+		})
+	fs.Var((*stringSliceFlag)(&cfg.RulePlugins), "rule-plugin",
+		"go-plugin binary of custom rules, receiving the configuration in JSON, without the private keys of the certificates, and returning findings. WebAssembly plugins are not supported. Can be repeated.")
+	fs.DurationVar(&cfg.RulePluginTimeout, "rule-plugin-timeout", defaultRulePluginTimeout,
+		"Time a rule plugin has to return its findings before it is restarted.")
+	fs.Func("cors-profile",
+		"Security profile used to report permissive CORS configurations (relaxed, default or strict).", func(value string) error {
+			if !containsString(corsProfiles, value) {
+				return fmt.Errorf("unknown CORS profile %q, expected one of %v", value, corsProfiles)
+			}
+			cfg.CORSProfile = value
+			return nil
+		})
+	fs.IntVar(&cfg.TLSSessionsPerHost, "tls-sessions-per-host", defaultTLSSessionsPerHost,
+		"Number of TLS sessions expected per host, used to check the size of ssl-session-cache-size.")
+	fs.StringVar(&cfg.PublishService, "publish-service", "",
+		"Namespace/name of the Service exposing the ingress controller, whose addresses the hosts must resolve to.")
+	fs.StringVar(&cfg.PublishStatusAddress, "publish-status-address", "",
+		"Comma separated addresses the hosts must resolve to. Takes precedence over --publish-service.")
+	fs.BoolVar(&cfg.VerifyDNS, "verify-dns", false,
+		"Look up every host in DNS and report hosts not resolving to the addresses of the ingress controller.")
+	fs.DurationVar(&cfg.DNSTimeout, "dns-timeout", defaultDNSTimeout, "Timeout of the DNS lookups.")
+	fs.BoolVar(&cfg.EnableSecurityHeadersAudit, "enable-security-headers-audit", false,
+		"Report the compliance of every host with the baseline of security headers.")
+	fs.Var((*stringSliceFlag)(&cfg.SecurityHeadersBaseline), "security-header",
+		"Response header required by the security headers audit. Can be repeated. Defaults to "+strings.Join(defaultSecurityHeadersBaseline, ", ")+".")
+	fs.Func("tls-policy",
+		"Policy the TLS protocols and ciphers are validated against ("+strings.Join(tlsPolicyNames(), ", ")+").", func(value string) error {
+			if _, ok := tlsPolicies[value]; !ok {
+				return fmt.Errorf("unknown TLS policy %q, expected one of %v", value, tlsPolicyNames())
+			}
+			cfg.TLSPolicy = value
+			return nil
+		})
+	fs.DurationVar(&cfg.CRLWarningPeriod, "crl-warning-period", defaultCRLWarningPeriod,
+		"Report the CRLs whose NextUpdate time is closer than this period.")
+	fs.StringVar(&cfg.TrustBundle, "trust-bundle", "",
+		"PEM file containing the CAs the server certificates must be issued by. Defaults to the CAs of the system.")
+	fs.Var((*stringSliceFlag)(&cfg.IssuerExemptHosts), "issuer-exempt-host",
+		"Pattern of the hosts allowed to use self-signed or untrusted certificates. Can be repeated.")
+	fs.Var((*stringSliceFlag)(&cfg.IssuerExemptNamespaces), "issuer-exempt-namespace",
+		"Namespace whose Ingresses are allowed to use self-signed or untrusted certificates. Can be repeated.")
+	fs.Var((*stringSliceFlag)(&cfg.SuppressibleRules), "suppressible-rule",
+		"Rule whose errors the Ingresses can suppress with the "+ignoreRulesAnnotation+" annotation, which only suppresses warnings and infos otherwise. Can be repeated.")
+	fs.Func("max-config-size", "Budget of the size of the rendered nginx.conf (e.g. 8Mi), measured on the --nginx-conf of validate when given and estimated from the servers, locations and snippets otherwise. 0 disables the check.", func(value string) error {
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			return err
+		}
+		cfg.MaxConfigSize = q.Value()
+		return nil
+	})
+	fs.IntVar(&cfg.MaxServers, "max-servers", 0, "Budget of servers (hosts) of the configuration. 0 disables the check.")
+	fs.IntVar(&cfg.MaxLocationsPerServer, "max-locations-per-server", 0, "Budget of locations of each server. 0 disables the check.")
+	fs.IntVar(&cfg.MaxRegexLocations, "max-regex-locations", 0, "Budget of regular expression locations of the configuration. 0 disables the check.")
+	fs.DurationVar(&cfg.MaxProxyReadTimeout, "max-proxy-read-timeout", 0, "Maximum proxy-read-timeout of the locations. 0 disables the check.")
+	fs.DurationVar(&cfg.MaxProxySendTimeout, "max-proxy-send-timeout", 0, "Maximum proxy-send-timeout of the locations. 0 disables the check.")
+	addListenPortFlags(fs, cfg)
+	cfg.ControllerPodLabels = map[string]string{}
+	fs.Var((*labelsFlag)(&cfg.ControllerPodLabels), "controller-pod-labels",
+		"Labels of the ingress controller pods (key1=value1,key2=value2), used to evaluate NetworkPolicies and the ports below 1024.")
+}
+
+// runCLI runs the command in args and returns the process exit code
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "usage: nginx-config-validator <command> [flags]")
+		fmt.Fprintln(stderr, "commands: validate, effective, explain, route, snapshot, webhook, proxy, gc, conformance, bench, openapi, argocd, flux")
+		fmt.Fprintln(stderr, "exit codes: 0 ok, 1 warnings with --strict, 2 errors, 3 internal failure")
+		return exitInternal
+	}
+
+	switch args[0] {
+	case "validate":
+		return runValidate(args[1:], stdout, stderr)
+	case "effective":
+		return runEffective(args[1:], stdout, stderr)
+	case "explain":
+		return runExplain(args[1:], stdout, stderr)
+	case "route":
+		return runRoute(args[1:], stdout, stderr)
+	case "snapshot":
+		return runSnapshot(args[1:], stdout, stderr)
+	case "webhook":
+		return runWebhook(args[1:], stdout, stderr)
+	case "proxy":
+		return runProxy(args[1:], stdout, stderr)
+	case "gc":
+		return runGC(args[1:], stdout, stderr)
+	case "conformance":
+		return runConformance(args[1:], stdout, stderr)
+	case "bench":
+		return runBench(args[1:], stdout, stderr)
+	case "openapi":
+		return runOpenAPI(args[1:], stdout, stderr)
+	case "argocd":
+		return runArgoCD(args[1:], stdout, stderr)
+	case "flux":
+		return runFlux(args[1:], os.Stdin, stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+		return exitInternal
+	}
+}
+
+// validateInput describes the sources of the objects to validate
+type validateInput struct {
+	manifests      stringSliceFlag
+	helmChart      string
+	values         stringSliceFlag
+	helmRelease    string
+	helmNamespace  string
+	kustomization  string
+	againstCluster bool
+}
+
+// addInputFlags registers the flags describing the objects to validate
+func addInputFlags(fs *flag.FlagSet, in *validateInput, cfg *NginxConfiguration) {
+	fs.Var(&in.manifests, "f", "Manifest file or directory to validate. Can be repeated.")
+	fs.StringVar(&in.helmChart, "helm-chart", "", "Helm chart to render and validate.")
+	fs.Var(&in.values, "values", "Values file used to render the Helm chart. Can be repeated.")
+	fs.StringVar(&in.helmRelease, "helm-release", "release", "Release name used to render the Helm chart.")
+	fs.StringVar(&in.helmNamespace, "helm-namespace", "default",
+		"Namespace used to render the Helm chart, set on the rendered objects without namespace.")
+	fs.StringVar(&in.kustomization, "kustomize", "", "Kustomize overlay directory to render and validate.")
+	fs.BoolVar(&in.againstCluster, "against-cluster", false,
+		"Validate the manifests on top of the Ingresses, Services and Secrets existing in the cluster.")
+	fs.StringVar(&cfg.KubeConfigFile, "kubeconfig", "", "Path to the kubeconfig file used with --against-cluster.")
+	fs.StringVar(&cfg.APIServerHost, "apiserver-host", "", "Address of the Kubernetes API server used with --against-cluster.")
+}
+
+// check returns an error if no input was configured
+func (in *validateInput) check() error {
+	if len(in.manifests) == 0 && in.helmChart == "" && in.kustomization == "" && !in.againstCluster {
+		return errors.New("at least one of -f, --helm-chart, --kustomize or --against-cluster is required")
+	}
+	return nil
+}
+
+// load creates a store containing the objects of the cluster, when
+// requested, overlaid with the objects of the manifests, Helm chart and
+// kustomize overlay
+func (in *validateInput) load(cfg *NginxConfiguration) (*memoryStore, error) {
+	s := newMemoryStore(cfg.ConfigMapName)
+	if cfg.ManifestNamespace != "" {
+		s.namespace = cfg.ManifestNamespace
+	}
+
+	if in.againstCluster {
+		if cfg.Client == nil {
+			client, err := newKubernetesClient(cfg.APIServerHost, cfg.KubeConfigFile)
+			if err != nil {
+				return nil, fmt.Errorf("error creating Kubernetes client: %w", err)
+			}
+			cfg.Client = client
+		}
+
+		if err := loadClusterState(context.Background(), cfg.Client, s); err != nil {
+			return nil, fmt.Errorf("error reading cluster state: %w", err)
+		}
+	}
+
+	if err := s.LoadManifests(in.manifests...); err != nil {
+		return nil, fmt.Errorf("error loading manifests: %w", err)
+	}
+
+	if in.helmChart != "" {
+		rendered, err := renderHelmChart(in.helmChart, in.helmRelease, in.helmNamespace, in.values)
+		if err != nil {
+			return nil, fmt.Errorf("error rendering Helm chart: %w", err)
+		}
+		if err := s.LoadManifestInNamespace(bytes.NewReader(rendered), "helm chart "+in.helmChart, in.helmNamespace); err != nil {
+			return nil, fmt.Errorf("error loading Helm chart %v: %w", in.helmChart, err)
+		}
+	}
+
+	if in.kustomization != "" {
+		rendered, err := renderKustomization(in.kustomization)
+		if err != nil {
+			return nil, fmt.Errorf("error rendering kustomization: %w", err)
+		}
+		if err := s.LoadManifest(bytes.NewReader(rendered), "kustomization "+in.kustomization); err != nil {
+			return nil, fmt.Errorf("error loading kustomization %v: %w", in.kustomization, err)
+		}
+	}
+
+	return s, nil
+}
+
+// localPaths returns the files and directories used as input
+func (in *validateInput) localPaths() []string {
+	paths := append([]string{}, in.manifests...)
+	paths = append(paths, in.values...)
+	if in.helmChart != "" {
+		paths = append(paths, in.helmChart)
+	}
+	if in.kustomization != "" {
+		paths = append(paths, in.kustomization)
+	}
+	return paths
+}
+
+// runValidate builds the configuration from manifests, Helm charts or
+// kustomize overlays and prints the findings
+func runValidate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	cfg := &NginxConfiguration{}
+	addConfigurationFlags(fs, cfg)
+
+	in := &validateInput{}
+	addInputFlags(fs, in, cfg)
+	output := fs.String("output", "text",
+		"Output format: text, json, html, plan (JSON of the resources validated, the findings and the resulting servers and locations, in a stable schema) or terraform (the plan for the Terraform external data source, use with --fail-on=none).")
+	watch := fs.Bool("watch", false, "Validate again every time the input files change.")
+	tui := fs.Bool("tui", false, "Browse the servers, locations, backends and findings, and the configuration summary of the hosts, in the terminal.")
+	failOn := failOnError
+	fs.Func("fail-on", "Lowest severity making the command fail: error (exit code 2), warning (exit code 1) or none.", func(value string) error {
+		if value != failOnError && value != failOnWarning && value != failOnNone {
+			return fmt.Errorf("expected %v, %v or %v", failOnError, failOnWarning, failOnNone)
+		}
+		failOn = value
+		return nil
+	})
+	strict := fs.Bool("strict", false, "Fail on warnings, same as --fail-on=warning.")
+	baseline := fs.String("baseline", "", "File of known findings, in the format of -output json, that are not reported.")
+	updateBaseline := fs.Bool("update-baseline", false, "Record the current findings in the --baseline file instead of reporting them.")
+	fs.StringVar(&cfg.NginxConf, "nginx-conf", "",
+		"nginx.conf rendered by the ingress controller for the input, tested with nginx -t in a sandbox of the work directory with stub certificates and Lua modules, and measured against --max-config-size.")
+	addWorkDirFlags(fs, cfg)
+	addSecretStorageFlags(fs, cfg)
+	addSandboxFlags(fs, cfg)
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitInternal
+	}
+
+	if err := in.check(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitInternal
+	}
+
+	if *watch && (*output == "html" || *output == "plan" || *output == "terraform") {
+		fmt.Fprintf(stderr, "--output %v can not be used with --watch\n", *output)
+		return exitInternal
+	}
+
+	if *watch && *tui {
+		fmt.Fprintln(stderr, "--tui can not be used with --watch")
+		return exitInternal
+	}
+
+	if *watch {
+		if err := watchValidate(cfg, in, stdout, stderr); err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return exitInternal
+		}
+		return exitOK
+	}
+
+	s, err := in.load(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitInternal
+	}
+
+	n := newOfflineController(cfg, s)
+	ingresses := s.ListIngresses()
+	configuration, findings := n.validate(ingresses)
+
+	if cfg.NginxConf != "" {
+		testFindings, err := n.checkNginxConf(configuration, cfg.NginxConf)
+		if err != nil {
+			fmt.Fprintf(stderr, "error testing %v: %v\n", cfg.NginxConf, err)
+			return exitInternal
+		}
+		findings = append(findings, testFindings...)
+		sortFindings(findings)
+	}
+
+	if *updateBaseline {
+		if *baseline == "" {
+			fmt.Fprintln(stderr, "--update-baseline requires --baseline")
+			return exitInternal
+		}
+		if err := writeBaseline(*baseline, findings); err != nil {
+			fmt.Fprintf(stderr, "error writing baseline: %v\n", err)
+			return exitInternal
+		}
+		fmt.Fprintf(stderr, "recorded %d findings in %v\n", len(findings), *baseline)
+		return exitOK
+	}
+
+	if *baseline != "" {
+		known, err := loadBaseline(*baseline)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return exitInternal
+		}
+		var suppressed, stale int
+		findings, suppressed, stale = applyBaseline(findings, known)
+		fmt.Fprintf(stderr, "%d findings suppressed by the baseline", suppressed)
+		if stale > 0 {
+			fmt.Fprintf(stderr, ", %d baseline entries no longer match and can be removed with --update-baseline", stale)
+		}
+		fmt.Fprintln(stderr)
+	}
+
+	switch {
+	case *tui:
+		if err := runTUI(n, configuration, findings); err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return exitInternal
+		}
+	case *output == "plan" || *output == "terraform":
+		write := writePlan
+		if *output == "terraform" {
+			write = writeTerraformPlan
+		}
+		if err := write(stdout, newValidationPlan(s, ingresses, configuration, findings)); err != nil {
+			fmt.Fprintf(stderr, "error writing plan: %v\n", err)
+			return exitInternal
+		}
+	case *output == "html":
+		var before *Configuration
+		if in.againstCluster {
+			if before, err = clusterConfiguration(cfg); err != nil {
+				fmt.Fprintf(stderr, "%v\n", err)
+				return exitInternal
+			}
+		}
+		if err := writeHTMLReport(stdout, n.newHTMLReport(ingresses, configuration, before, findings, time.Now())); err != nil {
+			fmt.Fprintf(stderr, "error writing report: %v\n", err)
+			return exitInternal
+		}
+	default:
+		if err := writeFindings(stdout, *output, findings); err != nil {
+			fmt.Fprintf(stderr, "error writing findings: %v\n", err)
+			return exitInternal
+		}
+	}
+
+	if *strict {
+		failOn = failOnWarning
+	}
+	return findingsExitCode(findings, failOn)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const cliManifests = `
+apiVersion: v1
+kind: Service
+metadata:
+  name: h2c
+  namespace: default
+spec:
+  ports:
+  - port: 80
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: h2c
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/backend-protocol: H2C
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: h2c.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: h2c
+            port:
+              number: 80
+`
+
+func TestRunValidate(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "ingress.yaml")
+	if err := os.WriteFile(manifest, []byte(cliManifests), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// the Ingress and the Service of the manifest have no namespace
+	namespaceless := filepath.Join(dir, "namespaceless.yaml")
+	if err := os.WriteFile(namespaceless, []byte(strings.ReplaceAll(cliManifests, "  namespace: default\n", "")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missingService := filepath.Join(dir, "missing-service.yaml")
+	if err := os.WriteFile(missingService, []byte(strings.ReplaceAll(cliManifests, "name: h2c\n  namespace: default\nspec:\n  ports", "name: other\n  namespace: default\nspec:\n  ports")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		exitCode int
+		stdout   string
+		stderr   string
+	}{
+		{
+			name:     "no command",
+			exitCode: exitInternal,
+			stderr:   "usage:",
+		},
+		{
+			name:     "unknown command",
+			args:     []string{"lint"},
+			exitCode: exitInternal,
+			stderr:   `unknown command "lint"`,
+		},
+		{
+			name:     "no input",
+			args:     []string{"validate"},
+			exitCode: exitInternal,
+			stderr:   "at least one of -f, --helm-chart, --kustomize or --against-cluster is required",
+		},
+		{
+			name:     "missing manifest",
+			args:     []string{"validate", "-f", filepath.Join(dir, "missing.yaml")},
+			exitCode: exitInternal,
+			stderr:   "error loading manifests",
+		},
+		{
+			name:     "unknown output",
+			args:     []string{"validate", "-f", manifest, "--output", "xml"},
+			exitCode: exitInternal,
+			stderr:   `unknown output format "xml"`,
+		},
+		{
+			name:     "no findings",
+			args:     []string{"validate", "-f", manifest},
+			exitCode: exitOK,
+			stdout:   "no findings",
+		},
+		{
+			name:     "no namespace",
+			args:     []string{"validate", "-f", namespaceless, "--fail-on", "warning"},
+			exitCode: exitOK,
+			stdout:   "no findings",
+		},
+		{
+			name:     "no namespace with --namespace",
+			args:     []string{"validate", "-f", namespaceless, "--namespace", "team-a", "--fail-on", "warning"},
+			exitCode: exitOK,
+			stdout:   "no findings",
+		},
+		{
+			name:     "missing Service",
+			args:     []string{"validate", "-f", missingService, "--fail-on", "warning"},
+			exitCode: exitWarnings,
+			stdout:   "backend-service-missing: path \"/\" refers to Service default/h2c, which does not exist",
+		},
+		{
+			name:     "error finding",
+			args:     []string{"validate", "-f", manifest, "--nginx-version", "1.13.9"},
+			exitCode: exitErrors,
+			stdout:   "h2c-nginx-version",
+		},
+		{
+			name:     "invalid fail-on",
+			args:     []string{"validate", "-f", manifest, "--fail-on", "info"},
+			exitCode: exitInternal,
+			stderr:   "expected error, warning or none",
+		},
+		{
+			name:     "fail on none",
+			args:     []string{"validate", "-f", manifest, "--nginx-version", "1.13.9", "--fail-on", "none"},
+			exitCode: exitOK,
+			stdout:   "h2c-nginx-version",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := runCLI(tc.args, &stdout, &stderr); code != tc.exitCode {
+				t.Errorf("expected exit code %v, got %v (%v)", tc.exitCode, code, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tc.stdout) {
+				t.Errorf("expected stdout to contain %q, got %q", tc.stdout, stdout.String())
+			}
+			if !strings.Contains(stderr.String(), tc.stderr) {
+				t.Errorf("expected stderr to contain %q, got %q", tc.stderr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestWriteFindingsJSON(t *testing.T) {
+	findings := []Finding{{Rule: "h2c-nginx-version", Severity: SeverityError, Host: "h2c.example.com"}}
+
+	var out bytes.Buffer
+	if err := writeFindings(&out, "json", findings); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded []Finding
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", out.String(), err)
+	}
+	if len(decoded) != 1 || decoded[0].Rule != "h2c-nginx-version" || decoded[0].Host != "h2c.example.com" {
+		t.Errorf("expected the finding to round trip, got %v", decoded)
+	}
+}
+
+func TestFindingsExitCode(t *testing.T) {
+	errorFinding := Finding{Rule: "h2c-nginx-version", Severity: SeverityError}
+	warningFinding := Finding{Rule: "cors-permissive", Severity: SeverityWarning}
+	infoFinding := Finding{Rule: "rate-limit-burst", Severity: SeverityInfo}
+
+	tests := []struct {
+		name     string
+		findings []Finding
+		failOn   string
+		exitCode int
+	}{
+		{"no findings", nil, failOnWarning, exitOK},
+		{"info", []Finding{infoFinding}, failOnWarning, exitOK},
+		{"warning", []Finding{warningFinding}, failOnError, exitOK},
+		{"warning with fail on warning", []Finding{infoFinding, warningFinding}, failOnWarning, exitWarnings},
+		{"error", []Finding{errorFinding}, failOnError, exitErrors},
+		{"error after a warning", []Finding{warningFinding, errorFinding}, failOnWarning, exitErrors},
+		{"error with fail on none", []Finding{errorFinding}, failOnNone, exitOK},
+	}
+
+	for _, tc := range tests {
+		if code := findingsExitCode(tc.findings, tc.failOn); code != tc.exitCode {
+			t.Errorf("%v: expected exit code %v, got %v", tc.name, tc.exitCode, code)
+		}
+	}
+}
+
+func TestValidateInputLoadHelmChart(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake helm binary is a shell script")
+	}
+
+	// helm template leaves out the namespace of most charts
+	bin := t.TempDir()
+	helm := "#!/bin/sh\ncat <<'EOF'\n" + strings.ReplaceAll(cliManifests, "  namespace: default\n", "") + "EOF\n"
+	if err := os.WriteFile(filepath.Join(bin, "helm"), []byte(helm), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	in := &validateInput{helmChart: "chart", helmRelease: "release", helmNamespace: "team-a"}
+	s, err := in.load(&NginxConfiguration{ConfigMapName: defaultConfigMapName})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.GetService("team-a/h2c"); err != nil {
+		t.Errorf("expected the Service in the namespace of the release: %v", err)
+	}
+	if ingresses := s.ListIngresses(); len(ingresses) != 1 || ingresses[0].Namespace != "team-a" {
+		t.Errorf("expected the Ingress in the namespace of the release, got %v", ingresses)
+	}
+}
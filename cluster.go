@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+)
+
+// newKubernetesClient creates the client used to read the cluster state,
+// using the kubeconfig file or the in-cluster configuration
+func newKubernetesClient(apiserverHost, kubeConfig string) (clientset.Interface, error) {
+	restConfig, err := clientcmd.BuildConfigFromFlags(apiserverHost, kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return clientset.NewForConfig(restConfig)
+}
+
+// newDynamicClient creates the client used for the custom resources of the
+// validator, using the same configuration as newKubernetesClient
+func newDynamicClient(apiserverHost, kubeConfig string) (dynamic.Interface, error) {
+	restConfig, err := clientcmd.BuildConfigFromFlags(apiserverHost, kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return dynamic.NewForConfig(restConfig)
+}
+
+// loadClusterState copies the objects used to build the configuration from
+// the cluster into the store. Objects added to the store afterwards replace
+// the ones with the same namespace and name, which allows validating
+// candidate manifests on top of the existing resources.
+func loadClusterState(ctx context.Context, client clientset.Interface, s *memoryStore) error {
+	opts := metav1.ListOptions{}
+	var objs []runtime.Object
+
+	ings, err := client.NetworkingV1().Ingresses(metav1.NamespaceAll).List(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("listing Ingresses: %w", err)
+	}
+	for i := range ings.Items {
+		objs = append(objs, &ings.Items[i])
+	}
+
+	svcs, err := client.CoreV1().Services(metav1.NamespaceAll).List(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("listing Services: %w", err)
+	}
+	for i := range svcs.Items {
+		objs = append(objs, &svcs.Items[i])
+	}
+
+	secrets, err := client.CoreV1().Secrets(metav1.NamespaceAll).List(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("listing Secrets: %w", err)
+	}
+	for i := range secrets.Items {
+		objs = append(objs, &secrets.Items[i])
+	}
+
+	cms, err := client.CoreV1().ConfigMaps(metav1.NamespaceAll).List(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("listing ConfigMaps: %w", err)
+	}
+	for i := range cms.Items {
+		objs = append(objs, &cms.Items[i])
+	}
+
+	nss, err := client.CoreV1().Namespaces().List(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("listing Namespaces: %w", err)
+	}
+	for i := range nss.Items {
+		objs = append(objs, &nss.Items[i])
+	}
+
+	slices, err := client.DiscoveryV1().EndpointSlices(metav1.NamespaceAll).List(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("listing EndpointSlices: %w", err)
+	}
+	for i := range slices.Items {
+		objs = append(objs, &slices.Items[i])
+	}
+
+	ics, err := client.NetworkingV1().IngressClasses().List(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("listing IngressClasses: %w", err)
+	}
+	for i := range ics.Items {
+		objs = append(objs, &ics.Items[i])
+	}
+
+	// the remaining objects are only used by optional rules, the validation
+	// can continue without them
+	if nps, err := client.NetworkingV1().NetworkPolicies(metav1.NamespaceAll).List(ctx, opts); err != nil {
+		klog.Warningf("Error listing NetworkPolicies: %v", err)
+	} else {
+		for i := range nps.Items {
+			objs = append(objs, &nps.Items[i])
+		}
+	}
+
+	if deployments, err := client.AppsV1().Deployments(metav1.NamespaceAll).List(ctx, opts); err != nil {
+		klog.Warningf("Error listing Deployments: %v", err)
+	} else {
+		for i := range deployments.Items {
+			objs = append(objs, &deployments.Items[i])
+		}
+	}
+
+	if pdbs, err := client.PolicyV1().PodDisruptionBudgets(metav1.NamespaceAll).List(ctx, opts); err != nil {
+		klog.Warningf("Error listing PodDisruptionBudgets: %v", err)
+	} else {
+		for i := range pdbs.Items {
+			objs = append(objs, &pdbs.Items[i])
+		}
+	}
+
+	for _, obj := range objs {
+		if err := s.Add(obj); err != nil {
+			klog.Warningf("Ignoring object from the cluster: %v", err)
+		}
+	}
+
+	klog.V(2).Infof("Loaded %d objects from the cluster", len(objs))
+	return nil
+}
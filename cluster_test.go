@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestLoadClusterState(t *testing.T) {
+	clusterObjects := []runtime.Object{
+		&apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}},
+		&networking.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}},
+		&networking.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}},
+	}
+
+	t.Run("manifests replace the cluster objects", func(t *testing.T) {
+		client := fake.NewSimpleClientset(clusterObjects...)
+		s := newMemoryStore(defaultConfigMapName)
+		if err := loadClusterState(context.Background(), client, s); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := s.Add(&networking.Ingress{ObjectMeta: metav1.ObjectMeta{
+			Name: "web", Namespace: "default", Labels: map[string]string{"candidate": "true"},
+		}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, err := s.GetService("default/web"); err != nil {
+			t.Errorf("expected the Service of the cluster in the store: %v", err)
+		}
+		ingresses := s.ListIngresses()
+		if len(ingresses) != 2 {
+			t.Fatalf("expected two Ingresses, got %d", len(ingresses))
+		}
+		for _, ing := range ingresses {
+			if ing.Name == "web" && ing.Labels["candidate"] != "true" {
+				t.Errorf("expected the candidate Ingress to replace the one of the cluster")
+			}
+		}
+	})
+
+	t.Run("required objects", func(t *testing.T) {
+		client := fake.NewSimpleClientset(clusterObjects...)
+		client.PrependReactor("list", "services", func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("forbidden")
+		})
+		if err := loadClusterState(context.Background(), client, newMemoryStore(defaultConfigMapName)); err == nil {
+			t.Errorf("expected an error when the Services cannot be listed")
+		}
+	})
+
+	t.Run("optional objects", func(t *testing.T) {
+		client := fake.NewSimpleClientset(clusterObjects...)
+		client.PrependReactor("list", "networkpolicies", func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("forbidden")
+		})
+		s := newMemoryStore(defaultConfigMapName)
+		if err := loadClusterState(context.Background(), client, s); err != nil {
+			t.Errorf("unexpected error when the NetworkPolicies cannot be listed: %v", err)
+		}
+		if len(s.ListIngresses()) != 2 {
+			t.Errorf("expected the Ingresses of the cluster in the store")
+		}
+	})
+}
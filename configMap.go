@@ -0,0 +1,412 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	ngx_config "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/controller/config"
+)
+
+// formats of the values of the ConfigMap
+const (
+	configMapBool    = "bool"
+	configMapInt     = "int"
+	configMapFloat   = "float"
+	configMapString  = "string"
+	configMapSize    = "size"
+	configMapTime    = "time"
+	configMapBuffers = "buffers"
+	configMapEnum    = "enum"
+	// configMapList is a space separated list of values
+	configMapList = "list"
+	// configMapCIDRs is a comma separated list of IP addresses or CIDRs
+	configMapCIDRs = "cidrs"
+	// configMapStatusCodes is a comma separated list of HTTP status codes
+	configMapStatusCodes = "status-codes"
+	configMapURL         = "url"
+)
+
+// configMapFormat describes the values accepted for a key of the ConfigMap
+type configMapFormat struct {
+	kind string
+	// min and max bound the integers, when they are not both 0
+	min, max int64
+	// values are the accepted values of the enums and lists, and the values
+	// accepted besides the integers
+	values []string
+}
+
+// configMapFormats are the formats of the keys whose Go type is not enough
+// to validate the value, because nginx parses it or the controller reads it
+// itself
+var configMapFormats = map[string]configMapFormat{
+	"worker-processes":        {kind: configMapInt, min: 1, max: 1024, values: []string{"auto"}},
+	"max-worker-connections":  {kind: configMapInt, min: 0, max: 1 << 20},
+	"max-worker-open-files":   {kind: configMapInt, min: 0, max: 1 << 30},
+	"worker-shutdown-timeout": {kind: configMapTime},
+	"keep-alive":              {kind: configMapInt, min: 0, max: 1 << 20},
+	"keep-alive-requests":     {kind: configMapInt, min: 0, max: 1 << 30},
+
+	"upstream-keepalive-connections": {kind: configMapInt, min: 0, max: 1 << 20},
+	"upstream-keepalive-requests":    {kind: configMapInt, min: 0, max: 1 << 30},
+	"upstream-keepalive-timeout":     {kind: configMapInt, min: 0, max: 1 << 20},
+	"upstream-keepalive-time":        {kind: configMapTime},
+
+	"client-header-timeout":         {kind: configMapInt, min: 0, max: 1 << 20},
+	"client-body-timeout":           {kind: configMapInt, min: 0, max: 1 << 20},
+	"proxy-connect-timeout":         {kind: configMapInt, min: 0, max: 1 << 20},
+	"proxy-read-timeout":            {kind: configMapInt, min: 0, max: 1 << 20},
+	"proxy-send-timeout":            {kind: configMapInt, min: 0, max: 1 << 20},
+	"proxy-next-upstream-timeout":   {kind: configMapInt, min: 0, max: 1 << 20},
+	"proxy-next-upstream-tries":     {kind: configMapInt, min: 0, max: 1 << 10},
+	"proxy-protocol-header-timeout": {kind: configMapTime},
+	"ssl-session-timeout":           {kind: configMapTime},
+
+	"client-body-buffer-size":     {kind: configMapSize},
+	"client-header-buffer-size":   {kind: configMapSize},
+	"proxy-body-size":             {kind: configMapSize},
+	"proxy-buffer-size":           {kind: configMapSize},
+	"ssl-buffer-size":             {kind: configMapSize},
+	"ssl-session-cache-size":      {kind: configMapSize},
+	"large-client-header-buffers": {kind: configMapBuffers},
+	"proxy-buffers-number":        {kind: configMapInt, min: 1, max: 1 << 10},
+
+	"gzip-level":        {kind: configMapInt, min: 1, max: 9},
+	"gzip-min-length":   {kind: configMapInt, min: 0, max: 1 << 30},
+	"brotli-level":      {kind: configMapInt, min: 0, max: 11},
+	"brotli-min-length": {kind: configMapInt, min: 0, max: 1 << 30},
+	"hsts-max-age":      {kind: configMapInt, min: 0, max: 1 << 40},
+
+	"server-name-hash-bucket-size":   {kind: configMapInt, min: 1, max: 1 << 16},
+	"server-name-hash-max-size":      {kind: configMapInt, min: 1, max: 1 << 24},
+	"proxy-headers-hash-bucket-size": {kind: configMapInt, min: 1, max: 1 << 16},
+	"proxy-headers-hash-max-size":    {kind: configMapInt, min: 1, max: 1 << 24},
+	"map-hash-bucket-size":           {kind: configMapInt, min: 1, max: 1 << 16},
+	"variables-hash-bucket-size":     {kind: configMapInt, min: 1, max: 1 << 16},
+	"variables-hash-max-size":        {kind: configMapInt, min: 1, max: 1 << 24},
+
+	"http-redirect-code":     {kind: configMapEnum, values: []string{"301", "302", "307", "308"}},
+	"limit-req-status-code":  {kind: configMapInt, min: 400, max: 599},
+	"limit-conn-status-code": {kind: configMapInt, min: 400, max: 599},
+	"custom-http-errors":     {kind: configMapStatusCodes},
+
+	"error-log-level":         {kind: configMapEnum, values: errorLogLevels},
+	"load-balance":            {kind: configMapEnum, values: loadBalancingAlgorithms},
+	"proxy-http-version":      {kind: configMapEnum, values: []string{"1.0", "1.1"}},
+	"proxy-buffering":         {kind: configMapEnum, values: []string{"on", "off"}},
+	"proxy-request-buffering": {kind: configMapEnum, values: []string{"on", "off"}},
+	"annotations-risk-level":  {kind: configMapEnum, values: []string{"Low", "Medium", "High", "Critical"}},
+	"ssl-protocols":           {kind: configMapList, values: []string{"SSLv2", "SSLv3", "TLSv1", "TLSv1.1", "TLSv1.2", "TLSv1.3"}},
+
+	"whitelist-source-range":      {kind: configMapCIDRs},
+	"denylist-source-range":       {kind: configMapCIDRs},
+	"proxy-real-ip-cidr":          {kind: configMapCIDRs},
+	"block-cidrs":                 {kind: configMapCIDRs},
+	"bind-address":                {kind: configMapCIDRs},
+	"nginx-status-ipv4-whitelist": {kind: configMapCIDRs},
+	"nginx-status-ipv6-whitelist": {kind: configMapCIDRs},
+	"debug-connections":           {kind: configMapCIDRs},
+
+	// the global authentication is validated by checkGlobalExternalAuth
+	"global-auth-url":                   {kind: configMapString},
+	"global-auth-signin":                {kind: configMapString},
+	"global-auth-method":                {kind: configMapString},
+	"global-auth-always-set-cookie":     {kind: configMapString},
+	"global-auth-signin-redirect-param": {kind: configMapString},
+	"global-auth-response-headers":      {kind: configMapString},
+	"global-auth-request-redirect":      {kind: configMapString},
+	"global-auth-snippet":               {kind: configMapString},
+	"global-auth-cache-key":             {kind: configMapString},
+	"global-auth-cache-duration":        {kind: configMapString},
+
+	"proxy-stream-responses": {kind: configMapInt, min: 1, max: 1 << 20},
+	"skip-access-log-urls":   {kind: configMapString},
+	"block-user-agents":      {kind: configMapString},
+	"block-referers":         {kind: configMapString},
+	"hide-headers":           {kind: configMapString},
+	"lua-shared-dicts":       {kind: configMapString},
+	"plugins":                {kind: configMapString},
+	"enable-serial-reloads":  {kind: configMapBool},
+}
+
+// deprecatedConfigMapKeys are the keys the controller no longer uses, or
+// that have a replacement
+var deprecatedConfigMapKeys = map[string]string{
+	"use-geoip":             "the legacy GeoIP databases are no longer distributed, use use-geoip2",
+	"http2-max-field-size":  "nginx ignores it since 1.19.7, use large-client-header-buffers",
+	"http2-max-header-size": "nginx ignores it since 1.19.7, use large-client-header-buffers",
+	"http2-max-requests":    "nginx ignores it since 1.19.7, use keep-alive-requests",
+	"enable-opentracing":    "OpenTracing was removed in v1.10, use enable-opentelemetry",
+}
+
+// deprecatedConfigMapPrefixes are the prefixes of the keys of the removed
+// tracing modules
+var deprecatedConfigMapPrefixes = map[string]string{
+	"opentracing-": "OpenTracing was removed in v1.10, use the opentelemetry keys",
+	"zipkin-":      "Zipkin was removed with OpenTracing in v1.10, use the opentelemetry keys",
+	"jaeger-":      "Jaeger was removed with OpenTracing in v1.10, use the opentelemetry keys",
+	"datadog-":     "Datadog was removed with OpenTracing in v1.10, use the opentelemetry keys",
+}
+
+// configMapKeys returns the format of every key of the ConfigMap: the JSON
+// fields of the configuration of the controller, decoded with weak typing,
+// and the keys the controller reads itself
+var configMapKeys = sync.OnceValue(func() map[string]configMapFormat {
+	keys := map[string]configMapFormat{}
+	for name, t := range jsonFields(reflect.TypeOf(ngx_config.Configuration{})) {
+		switch t.Kind() {
+		case reflect.Bool:
+			keys[name] = configMapFormat{kind: configMapBool}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			keys[name] = configMapFormat{kind: configMapInt}
+		case reflect.Float32, reflect.Float64:
+			keys[name] = configMapFormat{kind: configMapFloat}
+		default:
+			keys[name] = configMapFormat{kind: configMapString}
+		}
+	}
+	for name, format := range configMapFormats {
+		keys[name] = format
+	}
+	return keys
+})
+
+// describe returns the expected format, for the findings
+func (f configMapFormat) describe() string {
+	switch f.kind {
+	case configMapBool:
+		return "true or false"
+	case configMapInt:
+		description := "an integer"
+		if f.min != 0 || f.max != 0 {
+			description = fmt.Sprintf("an integer between %d and %d", f.min, f.max)
+		}
+		for _, value := range f.values {
+			description += " or " + value
+		}
+		return description
+	case configMapFloat:
+		return "a number"
+	case configMapSize:
+		return "a size such as 1024, 8k or 1m"
+	case configMapTime:
+		return "a time such as 30, 30s, 5m or 1h"
+	case configMapBuffers:
+		return "a number and size of buffers such as 4 8k"
+	case configMapEnum:
+		return "one of " + strings.Join(f.values, ", ")
+	case configMapList:
+		return "a space separated list of " + strings.Join(f.values, ", ")
+	case configMapCIDRs:
+		return "a comma separated list of IP addresses or CIDRs"
+	case configMapStatusCodes:
+		return "a comma separated list of HTTP status codes"
+	case configMapURL:
+		return "an absolute http or https URL"
+	}
+	return "a string"
+}
+
+// check returns an error when the value does not match the format
+func (f configMapFormat) check(value string) error {
+	switch f.kind {
+	case configMapBool:
+		// the controller decodes empty values as false
+		if value == "" {
+			return nil
+		}
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("not a boolean")
+		}
+	case configMapInt:
+		if value == "" || containsString(f.values, value) {
+			return nil
+		}
+		n, err := strconv.ParseInt(value, 0, 64)
+		if err != nil {
+			return fmt.Errorf("not an integer")
+		}
+		if (f.min != 0 || f.max != 0) && (n < f.min || n > f.max) {
+			return fmt.Errorf("%d is out of range", n)
+		}
+	case configMapFloat:
+		if value == "" {
+			return nil
+		}
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("not a number")
+		}
+	case configMapSize:
+		_, err := parseNginxSize(value)
+		return err
+	case configMapTime:
+		_, err := parseNginxTime(value)
+		return err
+	case configMapBuffers:
+		_, _, err := parseNginxBuffers(value)
+		return err
+	case configMapEnum:
+		if !containsString(f.values, value) {
+			return fmt.Errorf("unknown value %q", value)
+		}
+	case configMapList:
+		if len(strings.Fields(value)) == 0 {
+			return fmt.Errorf("empty list")
+		}
+		for _, item := range strings.Fields(value) {
+			if !containsString(f.values, item) {
+				return fmt.Errorf("unknown value %q", item)
+			}
+		}
+	case configMapCIDRs:
+		for _, item := range splitConfigMapList(value) {
+			if net.ParseIP(item) == nil {
+				if _, _, err := net.ParseCIDR(item); err != nil {
+					return err
+				}
+			}
+		}
+	case configMapStatusCodes:
+		// the codes nginx can not intercept are reported by checkCustomErrors
+		for _, item := range splitConfigMapList(value) {
+			if _, err := strconv.Atoi(item); err != nil {
+				return fmt.Errorf("%q is not an HTTP status code", item)
+			}
+		}
+	case configMapURL:
+		if value == "" {
+			return nil
+		}
+		u, err := url.Parse(value)
+		if err != nil {
+			return err
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("missing scheme or host")
+		}
+	}
+	return nil
+}
+
+// splitConfigMapList splits a comma separated list, ignoring the spaces and
+// the empty items as the controller does
+func splitConfigMapList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// deprecatedConfigMapKey returns why a key is deprecated, empty when it is
+// not
+func deprecatedConfigMapKey(key string) string {
+	if reason, ok := deprecatedConfigMapKeys[key]; ok {
+		return reason
+	}
+	for prefix, reason := range deprecatedConfigMapPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return reason
+		}
+	}
+	return ""
+}
+
+// closestConfigMapKey returns the known key closest to an unknown key, empty
+// when none is close enough to be a typo
+func closestConfigMapKey(key string) string {
+	closest, distance := "", 3
+	for known := range configMapKeys() {
+		if d := editDistance(key, known); d < distance || d == distance && known < closest {
+			closest, distance = known, d
+		}
+	}
+	return closest
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// checkConfigMap validates the keys of the ConfigMap of the controller. The
+// controller ignores the unknown keys and the values it can not decode, and
+// nginx fails to reload with the values it can not parse.
+func (n *NGINXController) checkConfigMap(_ []*Ingress, _ *Configuration) []Finding {
+	findings := []Finding{}
+	if n.cfg.ConfigMapName == "" {
+		return findings
+	}
+	configMap, err := n.store.GetConfigMap(n.cfg.ConfigMapName)
+	if err != nil {
+		return findings
+	}
+
+	keys := make([]string, 0, len(configMap.Data))
+	for key := range configMap.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	known := configMapKeys()
+	for _, key := range keys {
+		value := configMap.Data[key]
+		if reason := deprecatedConfigMapKey(key); reason != "" {
+			findings = append(findings, Finding{
+				Rule:     "configmap-deprecated-key",
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("key %v of ConfigMap %v is deprecated: %v", key, n.cfg.ConfigMapName, reason),
+			})
+			continue
+		}
+
+		format, ok := known[key]
+		if !ok {
+			message := fmt.Sprintf("key %v of ConfigMap %v is not a setting of the ingress controller and is ignored", key, n.cfg.ConfigMapName)
+			if closest := closestConfigMapKey(key); closest != "" {
+				message += fmt.Sprintf("; did you mean %v?", closest)
+			}
+			findings = append(findings, Finding{
+				Rule:     "configmap-unknown-key",
+				Severity: SeverityWarning,
+				Message:  message,
+			})
+			continue
+		}
+
+		if err := format.check(strings.TrimSpace(value)); err != nil {
+			findings = append(findings, Finding{
+				Rule:     "configmap-invalid-value",
+				Severity: SeverityError,
+				Message: fmt.Sprintf("key %v of ConfigMap %v is %q, expected %v: %v",
+					key, n.cfg.ConfigMapName, value, format.describe(), err),
+			})
+		}
+	}
+	return findings
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const configMapManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+data:
+  proxy-body-size: 8m
+  worker-processes: auto
+  ssl-protocols: TLSv1.2 TLSv1.3
+  use-forwarded-headers: "true"
+  upstream-keepalive-time: 1h 30m
+  whitelist-source-range: 10.0.0.0/8, 192.168.1.1
+  use-geoip: "true"
+  zipkin-collector-host: zipkin.tracing
+  proxy-body-sise: 16m
+  my-setting: "1"
+  gzip-level: "12"
+  load-balance: random
+  enable-brotli: "yes"
+  upstream-keepalive-timeout: 1x
+`
+
+func TestConfigMapFormatCheck(t *testing.T) {
+	testCases := map[string]struct {
+		format   configMapFormat
+		valid    []string
+		invalid  []string
+		expected string
+	}{
+		"bool": {
+			format:  configMapFormat{kind: configMapBool},
+			valid:   []string{"", "true", "false", "1"},
+			invalid: []string{"yes"},
+		},
+		"bounded int": {
+			format:   configMapFormat{kind: configMapInt, min: 1, max: 9, values: []string{"auto"}},
+			valid:    []string{"", "1", "9", "auto"},
+			invalid:  []string{"0", "10", "one"},
+			expected: "an integer between 1 and 9 or auto",
+		},
+		"float": {
+			format:  configMapFormat{kind: configMapFloat},
+			valid:   []string{"0.5", ""},
+			invalid: []string{"half"},
+		},
+		"size": {
+			format:  configMapFormat{kind: configMapSize},
+			valid:   []string{"1024", "8k", "1m"},
+			invalid: []string{"", "8kb"},
+		},
+		"time": {
+			format:  configMapFormat{kind: configMapTime},
+			valid:   []string{"30", "30s", "1h 30m", "500ms"},
+			invalid: []string{"", "1x", "s"},
+		},
+		"buffers": {
+			format:  configMapFormat{kind: configMapBuffers},
+			valid:   []string{"4 8k"},
+			invalid: []string{"8k"},
+		},
+		"enum": {
+			format:   configMapFormat{kind: configMapEnum, values: []string{"on", "off"}},
+			valid:    []string{"on"},
+			invalid:  []string{"", "yes"},
+			expected: "one of on, off",
+		},
+		"list": {
+			format:  configMapFormat{kind: configMapList, values: []string{"TLSv1.2", "TLSv1.3"}},
+			valid:   []string{"TLSv1.2 TLSv1.3"},
+			invalid: []string{"", "TLSv1.2 TLSv1.4"},
+		},
+		"cidrs": {
+			format:  configMapFormat{kind: configMapCIDRs},
+			valid:   []string{"", "10.0.0.0/8, 192.168.1.1,", "::1"},
+			invalid: []string{"10.0.0.0/33", "localhost"},
+		},
+		"status codes": {
+			format: configMapFormat{kind: configMapStatusCodes},
+			// the range of the codes is checked by checkCustomErrors
+			valid:   []string{"404, 503", "200,600"},
+			invalid: []string{"not-found"},
+		},
+		"url": {
+			format:  configMapFormat{kind: configMapURL},
+			valid:   []string{"", "https://auth.example.com/verify"},
+			invalid: []string{"auth.example.com", "ftp://auth.example.com"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			for _, value := range tc.valid {
+				if err := tc.format.check(value); err != nil {
+					t.Errorf("expected %q to be valid, got %v", value, err)
+				}
+			}
+			for _, value := range tc.invalid {
+				if err := tc.format.check(value); err == nil {
+					t.Errorf("expected %q to be invalid", value)
+				}
+			}
+			if tc.expected != "" && tc.format.describe() != tc.expected {
+				t.Errorf("expected the description %q, got %q", tc.expected, tc.format.describe())
+			}
+		})
+	}
+}
+
+func TestConfigMapKeys(t *testing.T) {
+	keys := configMapKeys()
+	for key, expected := range map[string]string{
+		// the fields of the configuration of the controller
+		"use-forwarded-headers": configMapBool,
+		"enable-brotli":         configMapBool,
+		// the formats overriding the Go type
+		"proxy-body-size":  configMapSize,
+		"worker-processes": configMapInt,
+	} {
+		if keys[key].kind != expected {
+			t.Errorf("expected %v to be %v, got %+v", key, expected, keys[key])
+		}
+	}
+}
+
+func TestClosestConfigMapKey(t *testing.T) {
+	for key, expected := range map[string]string{
+		"proxy-body-sise":      "proxy-body-size",
+		"use-forwarded-header": "use-forwarded-headers",
+		"my-setting":           "",
+	} {
+		if got := closestConfigMapKey(key); got != expected {
+			t.Errorf("expected %q for %v, got %q", expected, key, got)
+		}
+	}
+	if d := editDistance("kitten", "sitting"); d != 3 {
+		t.Errorf("expected a distance of 3, got %v", d)
+	}
+}
+
+func TestCheckConfigMap(t *testing.T) {
+	n := newTestController(t, configMapManifests)
+
+	rules := map[string][]string{}
+	for _, f := range n.checkConfigMap(nil, nil) {
+		key := strings.Fields(f.Message)[1]
+		rules[f.Rule] = append(rules[f.Rule], key)
+		if f.Rule == "configmap-unknown-key" && key == "proxy-body-sise" && !strings.HasSuffix(f.Message, "did you mean proxy-body-size?") {
+			t.Errorf("expected the closest key to be suggested, got %q", f.Message)
+		}
+		if f.Rule == "configmap-invalid-value" && f.Severity != SeverityError {
+			t.Errorf("expected the invalid values to be errors, got %+v", f)
+		}
+	}
+	expected := map[string][]string{
+		"configmap-deprecated-key": {"use-geoip", "zipkin-collector-host"},
+		"configmap-unknown-key":    {"my-setting", "proxy-body-sise"},
+		"configmap-invalid-value":  {"enable-brotli", "gzip-level", "load-balance", "upstream-keepalive-timeout"},
+	}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Errorf("expected %v, got %v", expected, rules)
+	}
+
+	n.cfg.ConfigMapName = ""
+	if findings := n.checkConfigMap(nil, nil); len(findings) != 0 {
+		t.Errorf("expected no findings without ConfigMap, got %+v", findings)
+	}
+}
@@ -0,0 +1,540 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/klog/v2"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// nginxConfPath is the configuration file rendered by ingress-nginx
+const nginxConfPath = "/etc/nginx/nginx.conf"
+
+// conformanceRenderedFile is the nginx.conf rendered by the controller for
+// the Ingresses of a fixture, recorded with --record, so the routes of the
+// fixtures are checked with --offline without a cluster
+const conformanceRenderedFile = "nginx.conf"
+
+var (
+	// ingress-nginx delimits every server block with these comments
+	nginxConfServerStart = regexp.MustCompile(`^\s*## start server (\S+)`)
+	nginxConfServerEnd   = regexp.MustCompile(`^\s*## end server (\S+)`)
+	nginxConfLocation    = regexp.MustCompile(`^\s*location\s+(=|~\*|~)?\s*"?([^"{\s]+)"?\s*\{`)
+)
+
+// conformanceDivergence is a difference between the validator and the
+// ingress controller for one fixture
+type conformanceDivergence struct {
+	Fixture string
+	Ingress string
+	Host    string
+	Message string
+}
+
+func (d conformanceDivergence) String() string {
+	subject := d.Fixture
+	if d.Ingress != "" {
+		subject += " " + d.Ingress
+	}
+	if d.Host != "" {
+		subject += " " + d.Host
+	}
+	return fmt.Sprintf("%v: %v", subject, d.Message)
+}
+
+// conformanceSuite runs fixtures through the validator and a real ingress
+// controller, usually deployed in a kind cluster, and compares the admission
+// decisions and the generated server and location blocks
+type conformanceSuite struct {
+	cfg        *NginxConfiguration
+	client     clientset.Interface
+	restConfig *rest.Config
+
+	// controllerNamespace and controllerSelector select the pods of the
+	// ingress controller the nginx.conf is read from
+	controllerNamespace string
+	controllerSelector  string
+	// syncWait is the time given to the controller to render the Ingresses
+	syncWait time.Duration
+	// record writes the nginx.conf rendered for each fixture in its
+	// directory
+	record bool
+	// offline compares the fixtures with their recorded nginx.conf instead
+	// of applying them to a cluster
+	offline bool
+}
+
+// run executes every fixture of the corpus. Every subdirectory of corpus is a
+// fixture containing the manifests to apply.
+func (c *conformanceSuite) run(ctx context.Context, corpus string) ([]conformanceDivergence, error) {
+	entries, err := os.ReadDir(corpus)
+	if err != nil {
+		return nil, err
+	}
+
+	var divergences []conformanceDivergence
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		klog.Infof("Running conformance fixture %v", e.Name())
+		run := c.runFixture
+		if c.offline {
+			run = c.runRecordedFixture
+		}
+		d, err := run(ctx, e.Name(), filepath.Join(corpus, e.Name()))
+		if err != nil {
+			return divergences, fmt.Errorf("fixture %v: %w", e.Name(), err)
+		}
+		divergences = append(divergences, d...)
+	}
+	return divergences, nil
+}
+
+// runFixture applies the objects of a fixture, compares the decisions and the
+// routing of the validator and the controller, and removes the objects
+func (c *conformanceSuite) runFixture(ctx context.Context, name, dir string) ([]conformanceDivergence, error) {
+	fixture := newMemoryStore(c.cfg.ConfigMapName)
+	if err := fixture.LoadManifests(dir); err != nil {
+		return nil, err
+	}
+
+	local := newMemoryStore(c.cfg.ConfigMapName)
+	if cm, err := c.client.CoreV1().ConfigMaps(c.cfg.Namespace).Get(ctx, configMapName(c.cfg.ConfigMapName), metav1.GetOptions{}); err == nil {
+		if err := local.Add(cm); err != nil {
+			return nil, err
+		}
+	}
+
+	var cleanup []func()
+	defer func() {
+		for i := len(cleanup) - 1; i >= 0; i-- {
+			cleanup[i]()
+		}
+	}()
+
+	for _, obj := range fixtureDependencies(fixture) {
+		undo, err := c.create(ctx, obj)
+		if err != nil {
+			return nil, err
+		}
+		cleanup = append(cleanup, undo)
+		if err := local.Add(obj); err != nil {
+			return nil, err
+		}
+	}
+
+	n := newOfflineController(c.cfg, local)
+
+	var divergences []conformanceDivergence
+	hosts := map[string]bool{}
+	for _, ing := range fixtureIngresses(fixture) {
+		key := k8s.MetaNamespaceKey(ing)
+		_, localErr := n.CheckIngress(ing)
+
+		undo, controllerErr := c.create(ctx, ing)
+		if controllerErr == nil {
+			cleanup = append(cleanup, undo)
+			// the local state follows the decisions of the controller, so
+			// a divergence is not carried to the next Ingresses
+			if err := local.Add(ing); err != nil {
+				return nil, err
+			}
+			for _, rule := range ing.Spec.Rules {
+				hosts[rule.Host] = true
+			}
+		} else if !apierrors.IsInvalid(controllerErr) && !apierrors.IsBadRequest(controllerErr) && !apierrors.IsForbidden(controllerErr) {
+			return nil, controllerErr
+		}
+
+		switch {
+		case localErr == nil && controllerErr != nil:
+			divergences = append(divergences, conformanceDivergence{Fixture: name, Ingress: key,
+				Message: fmt.Sprintf("admitted by the validator, rejected by the controller: %v", controllerErr)})
+		case localErr != nil && controllerErr == nil:
+			divergences = append(divergences, conformanceDivergence{Fixture: name, Ingress: key,
+				Message: fmt.Sprintf("rejected by the validator, admitted by the controller: %v", localErr)})
+		}
+	}
+
+	if len(hosts) == 0 {
+		return divergences, nil
+	}
+
+	time.Sleep(c.syncWait)
+	conf, err := c.readNginxConf(ctx)
+	if err != nil {
+		return divergences, err
+	}
+	if c.record {
+		if err := os.WriteFile(filepath.Join(dir, conformanceRenderedFile), []byte(conf), 0o644); err != nil {
+			return divergences, err
+		}
+	}
+
+	_, _, configuration := n.getConfiguration(local.ListIngresses())
+	return append(divergences, routeDivergences(name, sortedSet(hosts), conf, configuration)...), nil
+}
+
+// runRecordedFixture compares the routing of the validator for the Ingresses
+// of a fixture with the nginx.conf recorded for them
+func (c *conformanceSuite) runRecordedFixture(_ context.Context, name, dir string) ([]conformanceDivergence, error) {
+	conf, err := os.ReadFile(filepath.Join(dir, conformanceRenderedFile))
+	if err != nil {
+		return nil, fmt.Errorf("no recorded nginx.conf, run the fixture against a cluster with --record: %w", err)
+	}
+	fixture := newMemoryStore(c.cfg.ConfigMapName)
+	if err := fixture.LoadManifests(dir); err != nil {
+		return nil, err
+	}
+
+	hosts := map[string]bool{}
+	for _, ing := range fixture.ListIngresses() {
+		for _, rule := range ing.Spec.Rules {
+			hosts[rule.Host] = true
+		}
+	}
+	_, _, configuration := newOfflineController(c.cfg, fixture).getConfiguration(fixture.ListIngresses())
+	return routeDivergences(name, sortedSet(hosts), string(conf), configuration), nil
+}
+
+// routeDivergences compares the locations of the hosts in the configuration
+// built by the validator and in the nginx.conf rendered by the controller
+func routeDivergences(name string, hosts []string, conf string, configuration *Configuration) []conformanceDivergence {
+	controllerRoutes := parseNginxConfRoutes(conf)
+	localRoutes := map[string][]string{}
+	for _, server := range configuration.Servers {
+		enforceRegex := enforceRegexModifier(server.Locations)
+		for _, loc := range server.Locations {
+			localRoutes[server.Hostname] = append(localRoutes[server.Hostname], conformanceLocationKey(loc, enforceRegex))
+		}
+	}
+
+	var divergences []conformanceDivergence
+	for _, host := range hosts {
+		want, got := sortedUnique(localRoutes[host]), sortedUnique(controllerRoutes[host])
+		if missing := difference(want, got); len(missing) > 0 {
+			divergences = append(divergences, conformanceDivergence{Fixture: name, Host: host,
+				Message: fmt.Sprintf("locations %v are generated by the validator only", strings.Join(missing, ", "))})
+		}
+		if extra := difference(got, want); len(extra) > 0 {
+			divergences = append(divergences, conformanceDivergence{Fixture: name, Host: host,
+				Message: fmt.Sprintf("locations %v are generated by the controller only", strings.Join(extra, ", "))})
+		}
+	}
+	return divergences
+}
+
+// create creates an object in the cluster and returns the function deleting it.
+// Objects that already exist are left untouched and are not deleted.
+func (c *conformanceSuite) create(ctx context.Context, obj runtime.Object) (func(), error) {
+	var err error
+	var del func(context.Context) error
+	opts := metav1.CreateOptions{}
+
+	switch o := obj.(type) {
+	case *apiv1.Namespace:
+		_, err = c.client.CoreV1().Namespaces().Create(ctx, o, opts)
+		del = func(ctx context.Context) error {
+			return c.client.CoreV1().Namespaces().Delete(ctx, o.Name, metav1.DeleteOptions{})
+		}
+	case *apiv1.Service:
+		_, err = c.client.CoreV1().Services(o.Namespace).Create(ctx, o, opts)
+		del = func(ctx context.Context) error {
+			return c.client.CoreV1().Services(o.Namespace).Delete(ctx, o.Name, metav1.DeleteOptions{})
+		}
+	case *apiv1.Secret:
+		_, err = c.client.CoreV1().Secrets(o.Namespace).Create(ctx, o, opts)
+		del = func(ctx context.Context) error {
+			return c.client.CoreV1().Secrets(o.Namespace).Delete(ctx, o.Name, metav1.DeleteOptions{})
+		}
+	case *apiv1.ConfigMap:
+		_, err = c.client.CoreV1().ConfigMaps(o.Namespace).Create(ctx, o, opts)
+		del = func(ctx context.Context) error {
+			return c.client.CoreV1().ConfigMaps(o.Namespace).Delete(ctx, o.Name, metav1.DeleteOptions{})
+		}
+	case *networking.Ingress:
+		_, err = c.client.NetworkingV1().Ingresses(o.Namespace).Create(ctx, o, opts)
+		del = func(ctx context.Context) error {
+			return c.client.NetworkingV1().Ingresses(o.Namespace).Delete(ctx, o.Name, metav1.DeleteOptions{})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported object %T in fixture", obj)
+	}
+
+	if apierrors.IsAlreadyExists(err) {
+		return func() {}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		if err := del(context.Background()); err != nil && !apierrors.IsNotFound(err) {
+			klog.Warningf("Error deleting conformance object: %v", err)
+		}
+	}, nil
+}
+
+// readNginxConf returns the nginx.conf of the first running pod of the
+// ingress controller
+func (c *conformanceSuite) readNginxConf(ctx context.Context) (string, error) {
+	pods, err := c.client.CoreV1().Pods(c.controllerNamespace).List(ctx, metav1.ListOptions{LabelSelector: c.controllerSelector})
+	if err != nil {
+		return "", err
+	}
+
+	var pod *apiv1.Pod
+	for i := range pods.Items {
+		if pods.Items[i].Status.Phase == apiv1.PodRunning {
+			pod = &pods.Items[i]
+			break
+		}
+	}
+	if pod == nil {
+		return "", fmt.Errorf("no running ingress controller pod matches %q in namespace %v", c.controllerSelector, c.controllerNamespace)
+	}
+
+	req := c.client.CoreV1().RESTClient().Post().
+		Resource("pods").Namespace(pod.Namespace).Name(pod.Name).SubResource("exec").
+		VersionedParams(&apiv1.PodExecOptions{
+			Command: []string{"cat", nginxConfPath},
+			Stdout:  true,
+			Stderr:  true,
+		}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(c.restConfig, "POST", req.URL())
+	if err != nil {
+		return "", err
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := exec.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: &stdout, Stderr: &stderr}); err != nil {
+		return "", fmt.Errorf("reading %v from %v/%v: %w: %v", nginxConfPath, pod.Namespace, pod.Name, err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// parseNginxConfRoutes returns the locations of every server of a nginx.conf
+// rendered by ingress-nginx. Internal locations are ignored.
+func parseNginxConfRoutes(conf string) map[string][]string {
+	routes := map[string][]string{}
+	server := ""
+
+	scanner := bufio.NewScanner(strings.NewReader(conf))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := nginxConfServerStart.FindStringSubmatch(line); m != nil {
+			server = m[1]
+			continue
+		}
+		if nginxConfServerEnd.MatchString(line) {
+			server = ""
+			continue
+		}
+		if server == "" {
+			continue
+		}
+
+		m := nginxConfLocation.FindStringSubmatch(line)
+		if m == nil || strings.HasPrefix(m[2], "@") || strings.HasPrefix(m[2], "/_") {
+			continue
+		}
+		key := m[2]
+		if m[1] != "" {
+			key = m[1] + " " + m[2]
+		}
+		routes[server] = append(routes[server], key)
+	}
+	return routes
+}
+
+// enforceRegexModifier reports whether the ingress-nginx template writes every
+// location of a server as a regular expression, which it does as soon as one
+// location of the server uses regular expressions or a rewrite
+func enforceRegexModifier(locations []*Location) bool {
+	for _, loc := range locations {
+		if loc.Rewrite.UseRegex || loc.Rewrite.Target != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// conformanceLocationKey returns the location as written by the ingress-nginx
+// template: exact paths use =, regular expressions ~* and are anchored. All
+// the locations of a server are regular expressions when enforceRegex, see
+// enforceRegexModifier.
+func conformanceLocationKey(loc *Location, enforceRegex bool) string {
+	switch {
+	case enforceRegex:
+		return "~* ^" + loc.Path
+	case loc.PathType != nil && *loc.PathType == pathTypeExact:
+		return "= " + loc.Path
+	default:
+		return loc.Path
+	}
+}
+
+// fixtureDependencies returns the objects of the fixture other than the
+// Ingresses, namespaces first
+func fixtureDependencies(s *memoryStore) []runtime.Object {
+	var objs []runtime.Object
+	for _, key := range sortedKeysOf(s.namespaces) {
+		objs = append(objs, s.namespaces[key])
+	}
+	for _, key := range sortedKeysOf(s.configMaps) {
+		objs = append(objs, s.configMaps[key])
+	}
+	for _, key := range sortedKeysOf(s.secrets) {
+		objs = append(objs, s.secrets[key])
+	}
+	for _, key := range sortedKeysOf(s.services) {
+		objs = append(objs, s.services[key])
+	}
+	return objs
+}
+
+// fixtureIngresses returns the Ingresses of the fixture sorted by key
+func fixtureIngresses(s *memoryStore) []*networking.Ingress {
+	ings := make([]*networking.Ingress, 0, len(s.ingresses))
+	for _, key := range sortedKeysOf(s.ingresses) {
+		ings = append(ings, s.ingresses[key])
+	}
+	return ings
+}
+
+func sortedKeysOf[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedUnique(values []string) []string {
+	set := map[string]bool{}
+	for _, v := range values {
+		set[v] = true
+	}
+	return sortedSet(set)
+}
+
+// difference returns the values of a missing from b. Both must be sorted.
+func difference(a, b []string) []string {
+	var diff []string
+	for _, v := range a {
+		i := sort.SearchStrings(b, v)
+		if i == len(b) || b[i] != v {
+			diff = append(diff, v)
+		}
+	}
+	return diff
+}
+
+// configMapName returns the name part of a namespace/name reference
+func configMapName(ref string) string {
+	if _, name, ok := strings.Cut(ref, "/"); ok {
+		return name
+	}
+	return ref
+}
+
+// runConformance runs the conformance suite against the ingress controller of
+// the cluster of the kubeconfig
+func runConformance(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("conformance", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	cfg := &NginxConfiguration{}
+	addConfigurationFlags(fs, cfg)
+	fs.StringVar(&cfg.KubeConfigFile, "kubeconfig", "", "Path to the kubeconfig file of the cluster running the ingress controller.")
+	fs.StringVar(&cfg.APIServerHost, "apiserver-host", "", "Address of the Kubernetes API server.")
+	corpus := fs.String("corpus", "", "Directory containing one subdirectory of manifests per fixture.")
+	suite := &conformanceSuite{cfg: cfg}
+	fs.StringVar(&suite.controllerSelector, "controller-selector", "app.kubernetes.io/component=controller",
+		"Label selector of the ingress controller pods.")
+	fs.DurationVar(&suite.syncWait, "sync-wait", 5*time.Second,
+		"Time given to the ingress controller to render the Ingresses of a fixture.")
+	fs.BoolVar(&suite.record, "record", false,
+		"Write the nginx.conf rendered by the ingress controller for each fixture in its directory, as "+conformanceRenderedFile+".")
+	fs.BoolVar(&suite.offline, "offline", false,
+		"Compare the routing of each fixture with its recorded "+conformanceRenderedFile+" instead of a cluster.")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitInternal
+	}
+	if *corpus == "" {
+		fmt.Fprintln(stderr, "--corpus is required")
+		return exitInternal
+	}
+	suite.controllerNamespace = cfg.Namespace
+
+	if !suite.offline {
+		if code := suite.connect(stderr); code != exitOK {
+			return code
+		}
+	}
+
+	divergences, err := suite.run(context.Background(), *corpus)
+	for _, d := range divergences {
+		fmt.Fprintln(stdout, d)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error running conformance suite: %v\n", err)
+		return exitInternal
+	}
+	if len(divergences) > 0 {
+		fmt.Fprintf(stdout, "%v divergences\n", len(divergences))
+		return exitErrors
+	}
+	fmt.Fprintln(stdout, "validator and ingress controller agree on every fixture")
+	return exitOK
+}
+
+// connect creates the client of the cluster of the kubeconfig
+func (c *conformanceSuite) connect(stderr io.Writer) int {
+	cfg := c.cfg
+	restConfig, err := clientcmd.BuildConfigFromFlags(cfg.APIServerHost, cfg.KubeConfigFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "error creating Kubernetes client: %v\n", err)
+		return exitInternal
+	}
+	client, err := clientset.NewForConfig(restConfig)
+	if err != nil {
+		fmt.Fprintf(stderr, "error creating Kubernetes client: %v\n", err)
+		return exitInternal
+	}
+	cfg.Client = client
+	c.client = client
+	c.restConfig = restConfig
+	return exitOK
+}
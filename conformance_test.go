@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/rewrite"
+)
+
+func TestParseNginxConfRoutes(t *testing.T) {
+	conf := `
+http {
+	## start server _
+	server {
+		location / {
+		}
+		location /_external-auth-Lw {
+		}
+	}
+	## end server _
+
+	## start server web.example.com
+	server {
+		server_name web.example.com ;
+		location ~* "^/api/v[0-9]+" {
+		}
+		location = /healthz {
+		}
+		location @custom_upstream-default-backend_404 {
+		}
+		location / {
+		}
+	}
+	## end server web.example.com
+
+	server {
+		location /nginx_status {
+		}
+	}
+}
+`
+	expected := map[string][]string{
+		"_":               {"/"},
+		"web.example.com": {"~* ^/api/v[0-9]+", "= /healthz", "/"},
+	}
+	if got := parseNginxConfRoutes(conf); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestConformanceLocationKey(t *testing.T) {
+	exact, prefix := pathTypeExact, pathTypePrefix
+
+	regex := &Location{Path: "/api/v[0-9]+", PathType: &prefix, Rewrite: rewrite.Config{UseRegex: true}}
+	rewritten := &Location{Path: "/old", PathType: &prefix, Rewrite: rewrite.Config{Target: "/new"}}
+
+	tests := []struct {
+		loc      *Location
+		server   []*Location
+		expected string
+	}{
+		{&Location{Path: "/", PathType: &prefix}, nil, "/"},
+		{&Location{Path: "/healthz", PathType: &exact}, nil, "= /healthz"},
+		{regex, []*Location{regex}, "~* ^/api/v[0-9]+"},
+		// another location of the server enforces regular expressions
+		{&Location{Path: "/healthz", PathType: &exact}, []*Location{regex}, "~* ^/healthz"},
+		{&Location{Path: "/", PathType: &prefix}, []*Location{rewritten}, "~* ^/"},
+	}
+
+	for _, tc := range tests {
+		if got := conformanceLocationKey(tc.loc, enforceRegexModifier(tc.server)); got != tc.expected {
+			t.Errorf("%v: expected %q, got %q", tc.loc.Path, tc.expected, got)
+		}
+	}
+}
+
+func TestDifference(t *testing.T) {
+	got := difference([]string{"/", "/api", "= /healthz"}, sortedUnique([]string{"/api", "/", "/api"}))
+	if !reflect.DeepEqual(got, []string{"= /healthz"}) {
+		t.Errorf("expected [= /healthz], got %v", got)
+	}
+}
+
+func TestConformanceCreate(t *testing.T) {
+	existing := &apiv1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "existing", Namespace: "default"}}
+	client := fake.NewSimpleClientset(existing)
+	c := &conformanceSuite{client: client}
+	ctx := context.Background()
+
+	undoExisting, err := c.create(ctx, existing.DeepCopy())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	undoNew, err := c.create(ctx, &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.create(ctx, &apiv1.Pod{}); err == nil || !strings.Contains(err.Error(), "unsupported object") {
+		t.Errorf("expected an error for an unsupported object, got %v", err)
+	}
+
+	undoNew()
+	undoExisting()
+	if _, err := client.CoreV1().Services("default").Get(ctx, "web", metav1.GetOptions{}); err == nil {
+		t.Error("expected the Service created by the fixture to be deleted")
+	}
+	if _, err := client.CoreV1().Secrets("default").Get(ctx, "existing", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the existing Secret to be kept: %v", err)
+	}
+}
+
+func TestConformanceCorpus(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runConformance([]string{"--offline", "--corpus", filepath.Join("testdata", "conformance")}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("expected the validator to agree with the recorded nginx.conf, got %v: %v%v", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestConformanceDivergence(t *testing.T) {
+	corpus := t.TempDir()
+	dir := filepath.Join(corpus, "rewrite-target")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"ingresses.yaml", conformanceRenderedFile} {
+		data, err := os.ReadFile(filepath.Join("testdata", "conformance", "rewrite-target", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the controller renders the docs paths without regular expressions
+		if name == conformanceRenderedFile {
+			data = []byte(strings.ReplaceAll(string(data), `location ~* "^/docs/" {`, `location /docs/ {`))
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := runConformance([]string{"--offline", "--corpus", corpus}, &stdout, &stderr)
+	if code != exitErrors {
+		t.Fatalf("expected divergences, got %v: %v%v", code, stdout.String(), stderr.String())
+	}
+	for _, want := range []string{"~* ^/docs/ are generated by the validator only", "/docs/ are generated by the controller only"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("expected %q in %v", want, stdout.String())
+		}
+	}
+
+	if err := os.Remove(filepath.Join(dir, conformanceRenderedFile)); err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	stderr.Reset()
+	if code := runConformance([]string{"--offline", "--corpus", corpus}, &stdout, &stderr); code != exitInternal {
+		t.Errorf("expected a fixture without nginx.conf to fail, got %v: %v", code, stdout.String())
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+)
+
+// connectionHeaderValues are the values accepted by connection-proxy-header
+var connectionHeaderValues = []string{"close", "keep-alive"}
+
+// isWebSocketLocation returns true if the backend of the location speaks
+// WebSocket: its Service port declares the ws or wss appProtocol, or the
+// configuration-snippet forwards the Upgrade header
+func isWebSocketLocation(loc *Location) bool {
+	if loc.Service != nil {
+		switch servicePortAppProtocol(loc.Service, loc.Port) {
+		case "kubernetes.io/ws", "kubernetes.io/wss":
+			return true
+		}
+	}
+	return strings.Contains(loc.ConfigurationSnippet, "$http_upgrade")
+}
+
+// checkConnectionHeader validates the connection-proxy-header annotations.
+// The Connection header otherwise forwards the Upgrade of the WebSocket
+// requests, which the override breaks.
+func (n *NGINXController) checkConnectionHeader(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			if loc.Ingress == nil {
+				continue
+			}
+			value, ok := loc.Ingress.Annotations[parser.GetAnnotationWithPrefix("connection-proxy-header")]
+			if !ok {
+				continue
+			}
+
+			if !containsString(connectionHeaderValues, value) {
+				findings = append(findings, newLocationFinding("connection-header-invalid", SeverityError, server, loc,
+					"connection-proxy-header %q of location %q is not one of %v; the ingress controller ignores it",
+					value, loc.Path, strings.Join(connectionHeaderValues, ", ")))
+				continue
+			}
+
+			if isWebSocketLocation(loc) {
+				findings = append(findings, newLocationFinding("connection-header-websocket", SeverityWarning, server, loc,
+					"connection-proxy-header %q of location %q replaces the Connection: upgrade of the WebSocket requests; the backend can not upgrade the connections",
+					value, loc.Path))
+			}
+
+			httpVersion, where := loc.Proxy.ProxyHTTPVersion, "proxy-http-version"
+			if m := proxyHTTPVersionDirective.FindStringSubmatch(loc.ConfigurationSnippet); m != nil {
+				httpVersion, where = m[1], "the proxy_http_version of the configuration-snippet"
+			}
+			if value == "keep-alive" && httpVersion == "1.0" {
+				findings = append(findings, newLocationFinding("connection-header-conflict", SeverityWarning, server, loc,
+					"connection-proxy-header keep-alive of location %q has no effect with %v 1.0; nginx only reuses the upstream connections with HTTP/1.1",
+					loc.Path, where))
+			}
+
+			for _, m := range requestHeaderDirective.FindAllStringSubmatch(loc.ConfigurationSnippet, -1) {
+				if strings.EqualFold(m[1], "Connection") {
+					findings = append(findings, newLocationFinding("connection-header-conflict", SeverityWarning, server, loc,
+						"the configuration-snippet of location %q sets the Connection header as well as connection-proxy-header; the backend receives both",
+						loc.Path))
+					break
+				}
+			}
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// connectionManifests is completed with the appProtocol of the Service and
+// the annotations of the Ingress
+const connectionManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+data:
+  allow-snippet-annotations: "true"
+  annotations-risk-level: Critical
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: chat
+  namespace: default
+spec:
+  ports:
+  - name: http
+    port: 80
+    appProtocol: %v
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: chat
+  namespace: default
+  annotations:
+%v
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: chat.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: chat
+            port:
+              number: 80
+`
+
+func TestCheckConnectionHeader(t *testing.T) {
+	testCases := map[string]struct {
+		appProtocol string
+		annotations string
+		expected    []string
+	}{
+		"no annotation": {
+			appProtocol: "kubernetes.io/ws",
+			annotations: `    nginx.ingress.kubernetes.io/proxy-http-version: "1.0"`,
+		},
+		"valid": {
+			appProtocol: "http",
+			annotations: `    nginx.ingress.kubernetes.io/connection-proxy-header: keep-alive`,
+		},
+		"invalid": {
+			appProtocol: "http",
+			annotations: `    nginx.ingress.kubernetes.io/connection-proxy-header: upgrade`,
+			expected:    []string{"connection-header-invalid"},
+		},
+		"WebSocket Service": {
+			appProtocol: "kubernetes.io/ws",
+			annotations: `    nginx.ingress.kubernetes.io/connection-proxy-header: close`,
+			expected:    []string{"connection-header-websocket"},
+		},
+		"WebSocket snippet": {
+			appProtocol: "http",
+			annotations: `    nginx.ingress.kubernetes.io/connection-proxy-header: close
+    nginx.ingress.kubernetes.io/configuration-snippet: |
+      proxy_set_header Upgrade $http_upgrade;`,
+			expected: []string{"connection-header-websocket"},
+		},
+		"keep-alive with HTTP/1.0": {
+			appProtocol: "http",
+			annotations: `    nginx.ingress.kubernetes.io/connection-proxy-header: keep-alive
+    nginx.ingress.kubernetes.io/proxy-http-version: "1.0"`,
+			expected: []string{"connection-header-conflict"},
+		},
+		"keep-alive with the HTTP/1.0 of the snippet": {
+			appProtocol: "http",
+			annotations: `    nginx.ingress.kubernetes.io/connection-proxy-header: keep-alive
+    nginx.ingress.kubernetes.io/configuration-snippet: |
+      proxy_http_version 1.0;`,
+			expected: []string{"connection-header-conflict"},
+		},
+		"Connection header of the snippet": {
+			appProtocol: "http",
+			annotations: `    nginx.ingress.kubernetes.io/connection-proxy-header: close
+    nginx.ingress.kubernetes.io/configuration-snippet: |
+      proxy_set_header connection "";`,
+			expected: []string{"connection-header-conflict"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, fmt.Sprintf(connectionManifests, tc.appProtocol, tc.annotations))
+			rules := []string{}
+			for _, f := range n.checkConnectionHeader(ingresses, cfg) {
+				rules = append(rules, f.Rule)
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(rules, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, rules)
+			}
+		})
+	}
+}
@@ -1,8 +1,22 @@
 package main
 
 import (
+	"crypto/tls"
+	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"google.golang.org/grpc"
+	"k8s.io/apimachinery/pkg/labels"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+
+	ngx_config "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/controller/config"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/controller/ingressclass"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/nginx"
 )
 
 // NGINXController describes a NGINX Ingress controller.
@@ -11,11 +25,10 @@ type NGINXController struct {
 
 	recorder record.EventRecorder
 
-	syncQueue *task.Queue
+	// syncQueue coalesces the events requiring a sync of the cluster state
+	syncQueue *syncQueue
 
-	syncStatus status.Syncer
-
-	syncRateLimiter flowcontrol.RateLimiter
+	syncStatus *statusSyncer
 
 	workersReloading bool
 
@@ -24,16 +37,19 @@ type NGINXController struct {
 	// allowing concurrent stoppers leads to stack traces.
 	stopLock *sync.Mutex
 
-	stopCh   chan struct{}
-	updateCh *channels.RingChannel
+	stopCh chan struct{}
+
+	// shutdownDone is closed once the shutdown is complete
+	shutdownDone chan struct{}
 
 	// ngxErrCh is used to detect errors with the NGINX processes
 	ngxErrCh chan error
 
 	// runningConfig contains the running configuration in the Backend
 	runningConfig *Configuration
-
-	t ngx_template.Writer
+	// runningObjects is the version of the objects of the store the running
+	// configuration was validated with
+	runningObjects string
 
 	resolver []net.IP
 
@@ -41,15 +57,47 @@ type NGINXController struct {
 
 	isShuttingDown bool
 
-	Proxy *tcpproxy.TCPProxy
+	// isLeader is set while this replica holds the Lease of the leader
+	// election
+	isLeader atomic.Bool
 
-	store store.Storer
+	Proxy *TCPProxy
 
-	metricCollector metric.Collector
+	store Storer
 
 	validationWebhookServer *http.Server
-
-	command NginxExecTester
+	// grpcServer serves the gRPC validation service, nil when disabled
+	grpcServer *grpc.Server
+	// webhookCertificate is the certificate served by the webhook, nil until
+	// it is loaded
+	webhookCertificate atomic.Pointer[tls.Certificate]
+
+	// preValidation is the result of the last validation of the Ingresses
+	// present in the cluster, used to report the readiness of the webhook
+	preValidation     *PreValidationResult
+	preValidationLock sync.RWMutex
+
+	// lastAudit is the report of the last audit of the cluster state, nil
+	// until the first audit
+	lastAudit     *AuditReport
+	lastAuditLock sync.RWMutex
+
+	// reports publishes the results of the admission requests, nil when
+	// disabled
+	reports *reportPublisher
+
+	// notifications reports the failures of the validation of the cluster
+	// state, nil when disabled
+	notifications *notifications
+	// configLock is held for writing while ConfigFile is applied, and for
+	// reading by the validations
+	configLock sync.RWMutex
+	// flagConfig contains the reloadable settings set by the flags,
+	// overridden by ConfigFile
+	flagConfig *validatorConfig
+
+	// auth authenticates the callers of the webhook, nil when disabled
+	auth *webhookAuth
 }
 
 // Configuration contains all the settings required by an Ingress controller
@@ -67,6 +115,10 @@ type NginxConfiguration struct {
 	DefaultService string
 
 	Namespace string
+	// ManifestNamespace is the namespace of the objects of the manifests
+	// without metadata.namespace, default when empty
+	// +optional
+	ManifestNamespace string
 
 	WatchNamespaceSelector labels.Selector
 
@@ -89,6 +141,12 @@ type NginxConfiguration struct {
 
 	HealthCheckHost string
 	ListenPorts     *ngx_config.ListenPorts
+	// StatusPort, StreamPort and ProfilerPort are the ports of the nginx
+	// status server, the stream configuration socket and the profiler of the
+	// ingress controller validated
+	StatusPort   int
+	StreamPort   int
+	ProfilerPort int
 
 	DisableServiceExternalName bool
 
@@ -98,16 +156,19 @@ type NginxConfiguration struct {
 
 	EnableProfiling bool
 
-	EnableMetrics           bool
+	EnableMetrics bool
+	// MetricsAddress is the address serving the metrics of the webhook,
+	// empty disables them
+	// +optional
+	MetricsAddress          string
 	MetricsPerHost          bool
 	MetricsPerUndefinedHost bool
-	MetricsBuckets          *collectors.HistogramBuckets
 	MetricsBucketFactor     float64
 	MetricsMaxBuckets       uint32
 	ReportStatusClasses     bool
 	ExcludeSocketMetrics    []string
 
-	FakeCertificate *ingress.SSLCert
+	FakeCertificate *SSLCert
 
 	SyncRateLimit float32
 
@@ -118,10 +179,53 @@ type NginxConfiguration struct {
 	ValidationWebhook         string
 	ValidationWebhookCertPath string
 	ValidationWebhookKeyPath  string
+	// ValidationWebhookBootstrap is the name of the
+	// ValidatingWebhookConfiguration whose caBundle is set to the CA of the
+	// certificate generated by the webhook, empty to load the certificate
+	// from ValidationWebhookCertPath
+	// +optional
+	ValidationWebhookBootstrap string
+	// ValidationWebhookService is the namespace/name of the Service of the
+	// webhook, used with ValidationWebhookBootstrap
+	// +optional
+	ValidationWebhookService string
+	// ValidationWebhookCASecret is the namespace/name of the Secret of the
+	// CA shared by the replicas, used with ValidationWebhookBootstrap
+	// +optional
+	ValidationWebhookCASecret string
+	// WebhookClientCA is the PEM file of the CAs of the client certificates
+	// allowed to call the admission endpoint
+	// +optional
+	WebhookClientCA string
+	// WebhookClientCNs are the common names of the client certificates
+	// allowed, any when empty
+	// +optional
+	WebhookClientCNs []string
+	// WebhookTokenFile is the file of the bearer tokens allowed to call the
+	// admission endpoint
+	// +optional
+	WebhookTokenFile string
+	// EnableAPI serves the validation API on the webhook server
+	// +optional
+	EnableAPI bool
+	// GRPCAddress is the address of the gRPC validation service
+	// +optional
+	GRPCAddress string
+
 	DisableFullValidationTest bool
 
+	// GlobalExternalAuth overrides the global external authentication of
+	// the ConfigMap
+	// +optional
 	GlobalExternalAuth  *ngx_config.GlobalExternalAuth
 	MaxmindEditionFiles *[]string
+	// MaxmindDirectory is the directory of the GeoIP2 databases
+	// +optional
+	MaxmindDirectory string
+	// MaxmindMaxAge is the age after which a GeoIP2 database is stale, 0 to
+	// disable the check
+	// +optional
+	MaxmindMaxAge time.Duration
 
 	MonitorMaxBatchSize int
 
@@ -137,4 +241,306 @@ type NginxConfiguration struct {
 	DisableSyncEvents bool
 
 	EnableTopologyAwareRouting bool
+
+	// SPIFFESVIDDirectory is the directory where the SPIFFE helper writes the
+	// X.509 SVID used as client certificate against backends when the
+	// proxy-ssl-secret annotation is not set
+	// +optional
+	SPIFFESVIDDirectory string
+	// SPIFFETrustDomain is the trust domain the SVID must belong to
+	// +optional
+	SPIFFETrustDomain string
+
+	// EnableExternalNameResolution resolves the name of Services of type
+	// ExternalName using the configured resolvers during validation
+	EnableExternalNameResolution bool
+
+	// NginxVersion is the version of the nginx binary the configuration is
+	// validated against, used by the checks depending on nginx features
+	// +optional
+	NginxVersion string
+	// ControllerVersion is the version of the ingress controller the
+	// configuration is validated against, used by the checks depending on
+	// features of the controller
+	// +optional
+	ControllerVersion string
+
+	// ControllerPodLabels are the labels of the ingress controller pods, used
+	// to check NetworkPolicies allow the controller to reach the backends
+	// +optional
+	ControllerPodLabels map[string]string
+
+	// EnableAvailabilityAudit runs the availability rules (disruption budgets,
+	// replicas) in addition to the correctness rules
+	EnableAvailabilityAudit bool
+	// ProductionHosts contains patterns (path.Match syntax) of the hosts
+	// considered production by the availability rules
+	// +optional
+	ProductionHosts []string
+	// AccessLogHosts contains patterns (path.Match syntax) of the hosts whose
+	// access log the audit policy requires
+	// +optional
+	AccessLogHosts []string
+
+	// SimulatedIngressClasses contains the classes of the ingress controller
+	// instances simulated to detect hosts served by more than one of them
+	// +optional
+	SimulatedIngressClasses []string
+
+	// HeaderMergingBackends contains patterns (path.Match syntax) of the
+	// Services, as namespace/name, whose servers treat dashes and underscores
+	// in header names as equivalent
+	// +optional
+	HeaderMergingBackends []string
+
+	// RequireSecureAuthURL rejects auth-url annotations not using HTTPS
+	RequireSecureAuthURL bool
+	// ProbeAuthURLs sends a HEAD request to the auth-url endpoints to detect
+	// authentication services that do not respond
+	ProbeAuthURLs bool
+	// AuthURLProbeTimeout is the timeout of the auth-url probes
+	// +optional
+	AuthURLProbeTimeout time.Duration
+
+	// RulePlugins are the paths of the go-plugin binaries of custom rules
+	// +optional
+	RulePlugins []string
+	// RulePluginTimeout is the time a rule plugin has to return its findings
+	// +optional
+	RulePluginTimeout time.Duration
+
+	// MaxConcurrentValidations is the number of validations the webhook runs
+	// in parallel, 0 means no limit
+	MaxConcurrentValidations int
+	// ValidationQueueDepth is the number of admission requests waiting for a
+	// validation slot before new requests are shed
+	ValidationQueueDepth int
+	// ValidationQueueTimeout is the time an admission request waits for a
+	// validation slot before being shed
+	ValidationQueueTimeout time.Duration
+	// AllowOnOverload admits the Ingresses shed by the webhook instead of
+	// rejecting them with a retriable error
+	AllowOnOverload bool
+	// NamespaceRateLimit is the number of admission requests per second
+	// validated for each namespace, 0 means no limit
+	// +optional
+	NamespaceRateLimit float64
+	// NamespaceRateLimitBurst is the number of admission requests of a
+	// namespace validated at once above NamespaceRateLimit
+	// +optional
+	NamespaceRateLimitBurst int
+
+	// CORSProfile is the security profile used to report permissive CORS
+	// configurations: relaxed, default or strict
+	// +optional
+	CORSProfile string
+
+	// TLSSessionsPerHost is the number of TLS sessions expected per host,
+	// used to check the size of the shared session cache
+	// +optional
+	TLSSessionsPerHost int
+
+	// FreezeWindows are the periods during which the webhook rejects changes
+	// to Ingresses without a new or changed freeze-override annotation
+	// +optional
+	FreezeWindows []string
+	// FreezeTimezone is the timezone of the weekly freeze windows
+	FreezeTimezone string
+	// FreezeMode is reject or warn
+	FreezeMode string
+
+	// VerifyDNS looks up the hosts in DNS to check they resolve to the
+	// addresses of PublishService or PublishStatusAddress
+	VerifyDNS bool
+	// DNSTimeout is the timeout of the DNS lookups
+	// +optional
+	DNSTimeout time.Duration
+
+	// EnableSecurityHeadersAudit reports the hosts whose responses do not
+	// include the headers of SecurityHeadersBaseline
+	EnableSecurityHeadersAudit bool
+	// SecurityHeadersBaseline contains the headers required by the audit
+	// +optional
+	SecurityHeadersBaseline []string
+
+	// TLSPolicy is the name of the policy the TLS protocols and ciphers
+	// are validated against, empty to disable the check
+	// +optional
+	TLSPolicy string
+
+	// CRLWarningPeriod is the time before the NextUpdate of a CRL from which
+	// it is reported as about to become stale
+	// +optional
+	CRLWarningPeriod time.Duration
+
+	// WorkDir contains the sandboxes, rendered configuration snapshots and
+	// certificate files
+	// +optional
+	WorkDir string
+	// WorkDirTTL is the age after which the entries of WorkDir are removed
+	// +optional
+	WorkDirTTL time.Duration
+	// WorkDirMaxBytes caps the disk usage of WorkDir
+	// +optional
+	WorkDirMaxBytes int64
+
+	// TrustBundle is a PEM file containing the CAs the server certificates
+	// must be issued by, empty to use the CAs of the system
+	// +optional
+	TrustBundle string
+	// IssuerExemptHosts contains patterns (path.Match syntax) of the hosts
+	// not checked by the certificate issuer rule
+	// +optional
+	IssuerExemptHosts []string
+	// IssuerExemptNamespaces contains the namespaces whose Ingresses are not
+	// checked by the certificate issuer rule
+	// +optional
+	IssuerExemptNamespaces []string
+
+	// ReportSink is where the webhook publishes the validation reports:
+	// configmap, crd, or empty to disable the publication
+	// +optional
+	ReportSink string
+	// ReportConfigMap is the namespace/name of the ConfigMap used by the
+	// configmap report sink
+	// +optional
+	ReportConfigMap string
+	// ReportInterval is the interval between publications of the reports
+	// +optional
+	ReportInterval time.Duration
+
+	// AuditInterval is the interval between audits of the cluster state,
+	// independent of the admission requests, 0 disables them
+	// +optional
+	AuditInterval time.Duration
+	// AuditDirectory is the directory the audit reports are written to,
+	// empty to only serve the last one
+	// +optional
+	AuditDirectory string
+	// AuditMaxReports is the number of reports kept in AuditDirectory, the
+	// oldest being removed, 0 keeps them all
+	// +optional
+	AuditMaxReports int
+	// AuditCertificatePeriod is the period in which the certificates
+	// expiring are listed by the audits
+	AuditCertificatePeriod time.Duration
+	// RunningConfig is the nginx.conf served by the ingress controller,
+	// a file or an http(s) URL, compared with the desired configuration by
+	// the audits
+	// +optional
+	RunningConfig string
+
+	// NotifySinks contains the endpoints notified when the validation of the
+	// cluster state fails, as <kind>=<url> with kind slack, teams or http,
+	// read from NotifySinksFile or the environment as the URLs are secrets
+	// +optional
+	NotifySinks []string
+	// NotifySinksFile is the file of the NotifySinks, one per line
+	// +optional
+	NotifySinksFile string
+	// NotifyReportURL is the link to the validation report included in the
+	// notifications
+	// +optional
+	NotifyReportURL string
+
+	// MaxConfigSize is the budget of the size of the rendered nginx.conf, in
+	// bytes, 0 disables the check. The size of NginxConf is compared with it
+	// when set, an estimate from the servers and locations otherwise.
+	// +optional
+	MaxConfigSize int64
+	// NginxConf is the nginx.conf rendered by the ingress controller for the
+	// manifests validated offline
+	// +optional
+	NginxConf string
+	// MaxServers is the budget of servers, 0 disables the check
+	// +optional
+	MaxServers int
+	// MaxLocationsPerServer is the budget of locations of each server, 0
+	// disables the check
+	// +optional
+	MaxLocationsPerServer int
+	// MaxRegexLocations is the budget of regular expression locations, 0
+	// disables the check
+	// +optional
+	MaxRegexLocations int
+	// MaxProxyReadTimeout is the maximum proxy-read-timeout of the
+	// locations, 0 disables the check
+	// +optional
+	MaxProxyReadTimeout time.Duration
+	// MaxProxySendTimeout is the maximum proxy-send-timeout of the
+	// locations, 0 disables the check
+	// +optional
+	MaxProxySendTimeout time.Duration
+
+	// SyncDebounce is the time the webhook waits for more events before
+	// synchronizing the cluster state
+	SyncDebounce time.Duration
+	// StateRefreshInterval is the minimum time between two reloads of the
+	// cluster state requested by the validation-generation annotation
+	StateRefreshInterval time.Duration
+
+	// ChrootDirectory is the directory nginx is chrooted into when IsChroot
+	// is set
+	// +optional
+	ChrootDirectory string
+	// NginxBinary is the nginx binary testing the configurations
+	// +optional
+	NginxBinary string
+
+	// SecretStorage is where the files of the Secrets are written for nginx
+	// -t: disk, tmpfs or memfd
+	SecretStorage string
+	// SecretTmpfsDir is the directory used with the tmpfs SecretStorage
+	// +optional
+	SecretTmpfsDir string
+
+	// HealthCheckPort is the port of the health checks of the webhook,
+	// ListenPorts.Health being the one of the ingress controller validated
+	HealthCheckPort int
+
+	// ConfigFile is the file of the reloadable settings of the webhook
+	// +optional
+	ConfigFile string
+	// RuleSeverities overrides the severity of the findings of a rule
+	// +optional
+	RuleSeverities map[string]Severity
+	// MessageTemplates rewrites the message of the findings of a rule, the
+	// template being executed with the finding
+	// +optional
+	MessageTemplates map[string]*template.Template
+	// SuppressibleRules are the rules whose errors the Ingresses can
+	// suppress with the ignore-rules annotation, only warnings and infos
+	// can be suppressed for the other rules
+	// +optional
+	SuppressibleRules []string
+	// CELRules are the rules written in the Common Expression Language
+	// +optional
+	CELRules []CELRule
+}
+
+// newOfflineController returns a controller that builds and validates the
+// configuration using only the objects contained in the store, without
+// running nginx or connecting to a cluster.
+func newOfflineController(cfg *NginxConfiguration, s Storer) *NGINXController {
+	if cfg.ListenPorts == nil {
+		cfg.ListenPorts = &ngx_config.ListenPorts{
+			HTTP:     80,
+			HTTPS:    443,
+			Health:   10254,
+			Default:  8181,
+			SSLProxy: 442,
+		}
+		cfg.StatusPort = nginx.StatusPort
+		cfg.StreamPort = nginx.StreamPort
+		cfg.ProfilerPort = nginx.ProfilerPort
+	}
+
+	return &NGINXController{
+		cfg:          cfg,
+		store:        s,
+		stopLock:     &sync.Mutex{},
+		stopCh:       make(chan struct{}),
+		shutdownDone: make(chan struct{}),
+		ngxErrCh:     make(chan error),
+	}
 }
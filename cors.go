@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// CORS security profiles, from the most to the least permissive
+const (
+	corsProfileRelaxed = "relaxed"
+	corsProfileDefault = "default"
+	corsProfileStrict  = "strict"
+)
+
+var corsProfiles = []string{corsProfileRelaxed, corsProfileDefault, corsProfileStrict}
+
+// corsMaxAgeLimit is the maximum preflight cache duration accepted by the
+// strict profile. Browsers cap the value anyway (Chromium uses 2 hours).
+const corsMaxAgeLimit = 86400
+
+var (
+	// httpToken matches the names of methods and headers (RFC 7230)
+	httpToken = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+	// corsOriginHost matches hosts, optionally with a wildcard subdomain as
+	// supported by the cors-allow-origin annotation
+	corsOriginHost = regexp.MustCompile(`^(\*\.)?([a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?\.)*[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?$`)
+)
+
+var corsKnownMethods = []string{"GET", "HEAD", "POST", "PUT", "DELETE", "CONNECT", "OPTIONS", "TRACE", "PATCH"}
+
+// checkCORS validates the CORS annotations. The ingress-nginx parser
+// silently ignores invalid values and uses the defaults, so the raw
+// annotations are checked.
+func (n *NGINXController) checkCORS(ingresses []*Ingress, _ *Configuration) []Finding {
+	findings := []Finding{}
+
+	profile := n.cfg.CORSProfile
+	if profile == "" {
+		profile = corsProfileDefault
+	}
+
+	for _, ing := range ingresses {
+		if ing.ParsedAnnotations == nil || !ing.ParsedAnnotations.CorsConfig.CorsEnabled {
+			continue
+		}
+
+		key := k8s.MetaNamespaceKey(ing)
+		report := func(rule string, severity Severity, format string, args ...interface{}) {
+			findings = append(findings, Finding{
+				Rule:     rule,
+				Severity: severity,
+				Ingress:  key,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		}
+
+		anns := ing.GetAnnotations()
+		credentials := ing.ParsedAnnotations.CorsConfig.CorsAllowCredentials
+
+		origins := splitCORSList(anns[parser.GetAnnotationWithPrefix("cors-allow-origin")])
+		if len(origins) == 0 {
+			origins = []string{"*"}
+		}
+		for _, origin := range origins {
+			if origin == "*" {
+				switch {
+				case credentials:
+					report("cors-wildcard-credentials", SeverityError,
+						"cors-allow-origin is * and cors-allow-credentials is true; browsers reject credentialed responses allowing any origin, list the allowed origins instead")
+				case profile == corsProfileStrict:
+					report("cors-permissive", SeverityError, "cors-allow-origin allows any origin, which the strict CORS profile forbids")
+				case profile == corsProfileDefault:
+					report("cors-permissive", SeverityInfo, "cors-allow-origin allows any origin")
+				}
+				continue
+			}
+
+			u, err := parseCORSOrigin(origin)
+			if err != nil {
+				report("cors-invalid", SeverityError, "invalid origin %q in cors-allow-origin: %v; ingress-nginx ignores it", origin, err)
+				continue
+			}
+
+			if u.Scheme == "http" && credentials && profile != corsProfileRelaxed {
+				report("cors-permissive", SeverityWarning,
+					"origin %v uses http and credentials are allowed; a network attacker can read credentialed responses", origin)
+			}
+			if strings.HasPrefix(u.Host, "*.") {
+				switch {
+				case profile == corsProfileStrict:
+					report("cors-permissive", SeverityError, "origin %v allows every subdomain, which the strict CORS profile forbids", origin)
+				case credentials && profile == corsProfileDefault:
+					report("cors-permissive", SeverityWarning,
+						"origin %v allows every subdomain with credentials; a vulnerable subdomain can read credentialed responses", origin)
+				}
+			}
+		}
+
+		if v, ok := anns[parser.GetAnnotationWithPrefix("cors-allow-methods")]; ok {
+			for _, method := range splitCORSList(v) {
+				switch {
+				case !httpToken.MatchString(method):
+					report("cors-invalid", SeverityError, "invalid method %q in cors-allow-methods; ingress-nginx uses the default methods", method)
+				case method == "*":
+					if credentials {
+						report("cors-invalid", SeverityWarning, "cors-allow-methods * is treated as a method name by browsers when credentials are allowed")
+					}
+				case !containsString(corsKnownMethods, strings.ToUpper(method)):
+					report("cors-invalid", SeverityWarning, "unknown method %q in cors-allow-methods", method)
+				case profile == corsProfileStrict && (strings.EqualFold(method, "TRACE") || strings.EqualFold(method, "CONNECT")):
+					report("cors-permissive", SeverityError, "cors-allow-methods allows %v, which the strict CORS profile forbids", strings.ToUpper(method))
+				}
+			}
+		}
+
+		for _, name := range []string{"cors-allow-headers", "cors-expose-headers"} {
+			v, ok := anns[parser.GetAnnotationWithPrefix(name)]
+			if !ok {
+				continue
+			}
+			for _, header := range splitCORSList(v) {
+				switch {
+				case header == "*":
+					if credentials {
+						report("cors-invalid", SeverityWarning, "%v * is treated as a header name by browsers when credentials are allowed", name)
+					} else if profile == corsProfileStrict {
+						report("cors-permissive", SeverityError, "%v allows any header, which the strict CORS profile forbids", name)
+					}
+				case !httpToken.MatchString(header):
+					report("cors-invalid", SeverityError, "invalid header %q in %v; ingress-nginx uses the default value", header, name)
+				}
+			}
+		}
+
+		if v, ok := anns[parser.GetAnnotationWithPrefix("cors-max-age")]; ok {
+			maxAge, err := strconv.Atoi(strings.TrimSpace(v))
+			switch {
+			case err != nil || maxAge < 0:
+				report("cors-invalid", SeverityError, "invalid cors-max-age %q", v)
+			case maxAge > corsMaxAgeLimit && profile == corsProfileStrict:
+				report("cors-permissive", SeverityWarning, "cors-max-age %d caches preflight responses for more than %d seconds", maxAge, corsMaxAgeLimit)
+			}
+		}
+	}
+
+	return findings
+}
+
+// parseCORSOrigin checks an origin has the format scheme://host[:port]
+func parseCORSOrigin(origin string) (*url.URL, error) {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("scheme must be http or https")
+	}
+	if u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return nil, fmt.Errorf("origins only contain the scheme, host and port")
+	}
+	if !corsOriginHost.MatchString(u.Hostname()) {
+		return nil, fmt.Errorf("invalid host %q", u.Hostname())
+	}
+	if port := u.Port(); port != "" {
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			return nil, fmt.Errorf("invalid port %q", port)
+		}
+	}
+	return u, nil
+}
+
+func splitCORSList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// corsIngress returns the manifest of an Ingress enabling CORS with the annotations
+func corsIngress(annotations map[string]string) string {
+	manifest := `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/enable-cors: "true"
+`
+	for k, v := range annotations {
+		manifest += fmt.Sprintf("    nginx.ingress.kubernetes.io/%v: %q\n", k, v)
+	}
+	return manifest + "spec:\n  ingressClassName: nginx\n"
+}
+
+func TestCheckCORS(t *testing.T) {
+	tests := []struct {
+		name        string
+		profile     string
+		annotations map[string]string
+		expected    []string
+	}{
+		{
+			name:        "any origin",
+			annotations: map[string]string{"cors-allow-credentials": "false"},
+			expected:    []string{"cors-permissive/info"},
+		},
+		{
+			name:        "any origin with the relaxed profile",
+			profile:     corsProfileRelaxed,
+			annotations: map[string]string{"cors-allow-credentials": "false"},
+			expected:    []string{},
+		},
+		{
+			name:        "any origin with the strict profile",
+			profile:     corsProfileStrict,
+			annotations: map[string]string{"cors-allow-credentials": "false"},
+			expected:    []string{"cors-permissive/error"},
+		},
+		{
+			// ingress-nginx allows credentials by default
+			name:     "any origin with credentials",
+			expected: []string{"cors-wildcard-credentials/error"},
+		},
+		{
+			name:        "listed origins",
+			annotations: map[string]string{"cors-allow-origin": "https://app.example.com, https://admin.example.com:8443"},
+			expected:    []string{},
+		},
+		{
+			name:        "origin with a path",
+			annotations: map[string]string{"cors-allow-origin": "https://app.example.com/login"},
+			expected:    []string{"cors-invalid/error"},
+		},
+		{
+			name:        "http origin with credentials",
+			annotations: map[string]string{"cors-allow-origin": "http://app.example.com", "cors-allow-credentials": "true"},
+			expected:    []string{"cors-permissive/warning"},
+		},
+		{
+			name:        "wildcard subdomain with credentials",
+			annotations: map[string]string{"cors-allow-origin": "https://*.example.com", "cors-allow-credentials": "true"},
+			expected:    []string{"cors-permissive/warning"},
+		},
+		{
+			name:        "wildcard subdomain with the strict profile",
+			profile:     corsProfileStrict,
+			annotations: map[string]string{"cors-allow-origin": "https://*.example.com", "cors-allow-credentials": "false"},
+			expected:    []string{"cors-permissive/error"},
+		},
+		{
+			name:    "methods",
+			profile: corsProfileStrict,
+			annotations: map[string]string{
+				"cors-allow-origin":  "https://app.example.com",
+				"cors-allow-methods": "GET, FETCH, TRACE, GET POST",
+			},
+			expected: []string{"cors-invalid/warning", "cors-permissive/error", "cors-invalid/error"},
+		},
+		{
+			name: "headers with credentials",
+			annotations: map[string]string{
+				"cors-allow-origin":      "https://app.example.com",
+				"cors-allow-credentials": "true",
+				"cors-allow-headers":     "*, X-Request-Id",
+				"cors-expose-headers":    "X Request Id",
+			},
+			expected: []string{"cors-invalid/warning", "cors-invalid/error"},
+		},
+		{
+			name:        "max age",
+			profile:     corsProfileStrict,
+			annotations: map[string]string{"cors-allow-origin": "https://app.example.com", "cors-max-age": "604800"},
+			expected:    []string{"cors-permissive/warning"},
+		},
+		{
+			name:        "invalid max age",
+			annotations: map[string]string{"cors-allow-origin": "https://app.example.com", "cors-max-age": "-1"},
+			expected:    []string{"cors-invalid/error"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, corsIngress(tc.annotations))
+			n.cfg.CORSProfile = tc.profile
+
+			got := []string{}
+			for _, f := range n.checkCORS(ingresses, cfg) {
+				got = append(got, fmt.Sprintf("%v/%v", f.Rule, f.Severity))
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseCORSOrigin(t *testing.T) {
+	tests := map[string]bool{
+		"https://app.example.com":       true,
+		"http://localhost:3000":         true,
+		"https://*.example.com":         true,
+		"app.example.com":               false,
+		"https://app.example.com/":      false,
+		"https://user@app.example.com":  false,
+		"https://app.example.com:70000": false,
+		"https://app_example.com":       false,
+	}
+
+	for origin, valid := range tests {
+		if _, err := parseCORSOrigin(origin); valid != (err == nil) {
+			t.Errorf("%q: expected valid %v, got %v", origin, valid, err)
+		}
+	}
+}
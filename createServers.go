@@ -0,0 +1,835 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/canary"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/log"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/proxy"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+const (
+	defUpstreamName = "upstream-default-backend"
+	defServerName   = "_"
+	// emptyZone disables the topology aware routing
+	emptyZone = ""
+)
+
+// createUpstreams creates the NGINX upstreams (Endpoints) for each Service
+// referenced in Ingress rules.
+//
+//nolint:gocyclo // Ignore function complexity error
+func (n *NGINXController) createUpstreams(data []*Ingress, du *Backend) map[string]*Backend {
+	upstreams := make(map[string]*Backend)
+	upstreams[defUpstreamName] = du
+
+	for _, ing := range data {
+		ingKey := k8s.MetaNamespaceKey(ing)
+		anns := ing.ParsedAnnotations
+
+		if !n.store.GetBackendConfiguration().AllowSnippetAnnotations {
+			dropSnippetDirectives(anns, ingKey)
+		}
+
+		var defBackend string
+		if ing.Spec.DefaultBackend != nil && ing.Spec.DefaultBackend.Service != nil {
+			defBackend = upstreamName(ing.Namespace, ing.Spec.DefaultBackend.Service)
+
+			klog.V(3).Infof("Creating upstream %q", defBackend)
+			upstreams[defBackend] = newUpstream(defBackend)
+
+			upstreams[defBackend].UpstreamHashBy.UpstreamHashBy = anns.UpstreamHashBy.UpstreamHashBy
+			upstreams[defBackend].UpstreamHashBy.UpstreamHashBySubset = anns.UpstreamHashBy.UpstreamHashBySubset
+			upstreams[defBackend].UpstreamHashBy.UpstreamHashBySubsetSize = anns.UpstreamHashBy.UpstreamHashBySubsetSize
+
+			upstreams[defBackend].LoadBalancing = anns.LoadBalancing
+			if upstreams[defBackend].LoadBalancing == "" {
+				upstreams[defBackend].LoadBalancing = n.store.GetBackendConfiguration().LoadBalancing
+			}
+
+			svcKey := fmt.Sprintf("%v/%v", ing.Namespace, ing.Spec.DefaultBackend.Service.Name)
+
+			// add the service ClusterIP as a single Endpoint instead of individual Endpoints
+			if anns.ServiceUpstream {
+				endpoint, err := n.getServiceClusterEndpoint(svcKey, ing.Spec.DefaultBackend)
+				if err != nil {
+					klog.Errorf("Failed to determine a suitable ClusterIP Endpoint for Service %q: %v", svcKey, err)
+				} else {
+					upstreams[defBackend].Endpoints = []Endpoint{endpoint}
+				}
+			}
+
+			// configure traffic shaping for canary
+			if anns.Canary.Enabled {
+				upstreams[defBackend].NoServer = true
+				upstreams[defBackend].TrafficShapingPolicy = newTrafficShapingPolicy(&anns.Canary)
+			}
+
+			if len(upstreams[defBackend].Endpoints) == 0 {
+				_, port := upstreamServiceNameAndPort(ing.Spec.DefaultBackend.Service)
+				endps, err := n.serviceEndpoints(svcKey, port.String())
+				upstreams[defBackend].Endpoints = append(upstreams[defBackend].Endpoints, endps...)
+				if err != nil {
+					klog.Warningf("Error creating upstream %q: %v", defBackend, err)
+				}
+			}
+
+			s, err := n.store.GetService(svcKey)
+			if err != nil {
+				klog.Warningf("Error obtaining Service %q: %v", svcKey, err)
+			}
+			upstreams[defBackend].Service = s
+		}
+
+		for _, rule := range ing.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+
+			for _, path := range rule.HTTP.Paths {
+				if path.Backend.Service == nil {
+					// skip non-service backends
+					klog.V(3).Infof("Ingress %q and path %q does not contain a service backend, using default backend", ingKey, path.Path)
+					continue
+				}
+
+				name := upstreamName(ing.Namespace, path.Backend.Service)
+				svcName, svcPort := upstreamServiceNameAndPort(path.Backend.Service)
+				if _, ok := upstreams[name]; ok {
+					continue
+				}
+
+				klog.V(3).Infof("Creating upstream %q", name)
+				upstreams[name] = newUpstream(name)
+				upstreams[name].Port = svcPort
+
+				upstreams[name].UpstreamHashBy.UpstreamHashBy = anns.UpstreamHashBy.UpstreamHashBy
+				upstreams[name].UpstreamHashBy.UpstreamHashBySubset = anns.UpstreamHashBy.UpstreamHashBySubset
+				upstreams[name].UpstreamHashBy.UpstreamHashBySubsetSize = anns.UpstreamHashBy.UpstreamHashBySubsetSize
+
+				upstreams[name].LoadBalancing = anns.LoadBalancing
+				if upstreams[name].LoadBalancing == "" {
+					upstreams[name].LoadBalancing = n.store.GetBackendConfiguration().LoadBalancing
+				}
+
+				svcKey := fmt.Sprintf("%v/%v", ing.Namespace, svcName)
+
+				// add the service ClusterIP as a single Endpoint instead of individual Endpoints
+				if anns.ServiceUpstream {
+					endpoint, err := n.getServiceClusterEndpoint(svcKey, &path.Backend)
+					if err != nil {
+						klog.Errorf("Failed to determine a suitable ClusterIP Endpoint for Service %q: %v", svcKey, err)
+					} else {
+						upstreams[name].Endpoints = []Endpoint{endpoint}
+					}
+				}
+
+				// configure traffic shaping for canary
+				if anns.Canary.Enabled {
+					upstreams[name].NoServer = true
+					upstreams[name].TrafficShapingPolicy = newTrafficShapingPolicy(&anns.Canary)
+				}
+
+				if len(upstreams[name].Endpoints) == 0 {
+					endp, err := n.serviceEndpoints(svcKey, svcPort.String())
+					if err != nil {
+						klog.Warningf("Error obtaining Endpoints for Service %q: %v", svcKey, err)
+						continue
+					}
+					upstreams[name].Endpoints = endp
+				}
+
+				s, err := n.store.GetService(svcKey)
+				if err != nil {
+					klog.Warningf("Error obtaining Service %q: %v", svcKey, err)
+					continue
+				}
+
+				upstreams[name].Service = s
+			}
+		}
+	}
+
+	return upstreams
+}
+
+// getServiceClusterEndpoint returns an Endpoint corresponding to the ClusterIP
+// field of a Service.
+func (n *NGINXController) getServiceClusterEndpoint(svcKey string, backend *networking.IngressBackend) (endpoint Endpoint, err error) {
+	svc, err := n.store.GetService(svcKey)
+	if err != nil {
+		return endpoint, fmt.Errorf("service %q does not exist", svcKey)
+	}
+
+	if svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == "None" {
+		return endpoint, fmt.Errorf("no ClusterIP found for Service %q", svcKey)
+	}
+
+	endpoint.Address = svc.Spec.ClusterIP
+
+	// if the Service port is referenced by name in the Ingress, lookup the
+	// actual port in the service spec
+	if backend.Service == nil {
+		return endpoint, fmt.Errorf("no backend service port found for service %q", svcKey)
+	}
+
+	var port int32
+	if backend.Service.Port.Name == "" {
+		port = backend.Service.Port.Number
+	} else {
+		for _, svcPort := range svc.Spec.Ports {
+			if svcPort.Name == backend.Service.Port.Name {
+				port = svcPort.Port
+				break
+			}
+		}
+	}
+	if port == 0 {
+		return endpoint, fmt.Errorf("no port mapped for Service %q and port name %q", svc.Name, backend.Service.Port.Name)
+	}
+	endpoint.Port = strconv.Itoa(int(port))
+
+	return endpoint, nil
+}
+
+// serviceEndpoints returns the upstream servers (Endpoints) associated with a Service.
+func (n *NGINXController) serviceEndpoints(svcKey, backendPort string) ([]Endpoint, error) {
+	var upstreams []Endpoint
+
+	svc, err := n.store.GetService(svcKey)
+	if err != nil {
+		return upstreams, err
+	}
+
+	klog.V(3).Infof("Obtaining ports information for Service %q", svcKey)
+
+	zone := emptyZone
+	if n.cfg.EnableTopologyAwareRouting {
+		zone = getIngressPodZone(svc)
+	}
+
+	// Ingress with an ExternalName Service and no port defined for that Service
+	if svc.Spec.Type == apiv1.ServiceTypeExternalName {
+		if n.cfg.DisableServiceExternalName {
+			klog.Warningf("Service %q of type ExternalName not allowed due to Ingress configuration.", svcKey)
+			return upstreams, nil
+		}
+		servicePort := apiv1.ServicePort{
+			Protocol:   "TCP",
+			Port:       8080,
+			TargetPort: intstr.FromString(backendPort),
+		}
+		endps := getEndpointsFromSlices(svc, &servicePort, apiv1.ProtocolTCP, zone, n.store.GetServiceEndpointsSlices)
+		if len(endps) == 0 {
+			klog.Warningf("Service %q does not have any active Endpoint.", svcKey)
+			return upstreams, nil
+		}
+
+		upstreams = append(upstreams, endps...)
+		return upstreams, nil
+	}
+
+	// Ingress with an ClusterIP Service and no port defined for that Service
+	for i := range svc.Spec.Ports {
+		servicePort := svc.Spec.Ports[i]
+		// targetPort could be a string, use either the port name or number (int)
+		if strconv.Itoa(int(servicePort.Port)) == backendPort ||
+			servicePort.TargetPort.String() == backendPort ||
+			servicePort.Name == backendPort {
+			endps := getEndpointsFromSlices(svc, &servicePort, apiv1.ProtocolTCP, zone, n.store.GetServiceEndpointsSlices)
+			if len(endps) == 0 {
+				klog.Warningf("Service %q does not have any active Endpoint.", svcKey)
+			}
+
+			upstreams = append(upstreams, endps...)
+			break
+		}
+	}
+
+	return upstreams, nil
+}
+
+// createServers builds a map of host name to Server structs from a map of
+// already computed Upstream structs. Each Server is configured with at least
+// one root location, which uses a default backend if left unconfigured.
+//
+//nolint:gocyclo // Ignore function complexity error
+func (n *NGINXController) createServers(data []*Ingress, upstreams map[string]*Backend, du *Backend) map[string]*Server {
+	servers := make(map[string]*Server, len(data))
+	allAliases := make(map[string][]string, len(data))
+
+	bdef := n.store.GetDefaultBackend()
+	ngxProxy := proxy.Config{
+		BodySize:             bdef.ProxyBodySize,
+		ConnectTimeout:       bdef.ProxyConnectTimeout,
+		SendTimeout:          bdef.ProxySendTimeout,
+		ReadTimeout:          bdef.ProxyReadTimeout,
+		BuffersNumber:        bdef.ProxyBuffersNumber,
+		BufferSize:           bdef.ProxyBufferSize,
+		CookieDomain:         bdef.ProxyCookieDomain,
+		CookiePath:           bdef.ProxyCookiePath,
+		NextUpstream:         bdef.ProxyNextUpstream,
+		NextUpstreamTimeout:  bdef.ProxyNextUpstreamTimeout,
+		NextUpstreamTries:    bdef.ProxyNextUpstreamTries,
+		RequestBuffering:     bdef.ProxyRequestBuffering,
+		ProxyRedirectFrom:    bdef.ProxyRedirectFrom,
+		ProxyBuffering:       bdef.ProxyBuffering,
+		ProxyHTTPVersion:     bdef.ProxyHTTPVersion,
+		ProxyMaxTempFileSize: bdef.ProxyMaxTempFileSize,
+		ProxyBusyBuffersSize: bdef.ProxyBusyBuffersSize,
+	}
+
+	// initialize default server and root location
+	servers[defServerName] = &Server{
+		Hostname: defServerName,
+		SSLCert:  n.getDefaultSSLCertificate(),
+		Locations: []*Location{
+			{
+				Path:         rootLocation,
+				PathType:     &pathTypePrefix,
+				IsDefBackend: true,
+				Backend:      du.Name,
+				Proxy:        ngxProxy,
+				Service:      du.Service,
+				Logs: log.Config{
+					Access:  n.store.GetBackendConfiguration().EnableAccessLogForDefaultBackend,
+					Rewrite: false,
+				},
+			},
+		},
+	}
+
+	// initialize all other servers
+	for _, ing := range data {
+		ingKey := k8s.MetaNamespaceKey(ing)
+		anns := ing.ParsedAnnotations
+
+		if !n.store.GetBackendConfiguration().AllowSnippetAnnotations {
+			dropSnippetDirectives(anns, ingKey)
+		}
+
+		// default upstream name
+		un := du.Name
+
+		if anns.Canary.Enabled {
+			klog.V(2).Infof("Ingress %v is marked as Canary, ignoring", ingKey)
+			continue
+		}
+
+		if ing.Spec.DefaultBackend != nil && ing.Spec.DefaultBackend.Service != nil {
+			defUpstream := upstreamName(ing.Namespace, ing.Spec.DefaultBackend.Service)
+
+			if backendUpstream, ok := upstreams[defUpstream]; ok {
+				// use backend specified in Ingress as the default backend for all its rules
+				un = backendUpstream.Name
+
+				// special "catch all" case, Ingress with a backend but no rule
+				defLoc := servers[defServerName].Locations[0]
+				defLoc.Backend = backendUpstream.Name
+				defLoc.Service = backendUpstream.Service
+				defLoc.Ingress = ing
+
+				if defLoc.IsDefBackend && len(ing.Spec.Rules) == 0 {
+					klog.V(2).Infof("Ingress %q defines a backend but no rule. Using it to configure the catch-all server %q", ingKey, defServerName)
+
+					defLoc.IsDefBackend = false
+
+					// TODO: Redirect and rewrite can affect the catch all behavior, skip for now
+					originalRedirect := defLoc.Redirect
+					originalRewrite := defLoc.Rewrite
+					locationApplyAnnotations(defLoc, anns)
+					defLoc.Redirect = originalRedirect
+					defLoc.Rewrite = originalRewrite
+				} else {
+					klog.V(3).Infof("Ingress %q defines both a backend and rules. Using its backend as default upstream for all its rules.", ingKey)
+				}
+			}
+		}
+
+		for _, rule := range ing.Spec.Rules {
+			host := rule.Host
+			if host == "" {
+				host = defServerName
+			}
+
+			if _, ok := servers[host]; ok {
+				// server already configured
+				continue
+			}
+
+			loc := &Location{
+				Path:         rootLocation,
+				PathType:     &pathTypePrefix,
+				IsDefBackend: true,
+				Backend:      un,
+				Service:      &apiv1.Service{},
+			}
+			locationApplyAnnotations(loc, anns)
+
+			servers[host] = &Server{
+				Hostname: host,
+				Locations: []*Location{
+					loc,
+				},
+				SSLPassthrough:         anns.SSLPassthrough,
+				SSLCiphers:             anns.SSLCipher.SSLCiphers,
+				SSLPreferServerCiphers: anns.SSLCipher.SSLPreferServerCiphers,
+			}
+		}
+	}
+
+	// configure default location, alias, and SSL
+	for _, ing := range data {
+		ingKey := k8s.MetaNamespaceKey(ing)
+		anns := ing.ParsedAnnotations
+
+		if anns.Canary.Enabled {
+			klog.V(2).Infof("Ingress %v is marked as Canary, ignoring", ingKey)
+			continue
+		}
+
+		for _, rule := range ing.Spec.Rules {
+			host := rule.Host
+			if host == "" {
+				host = defServerName
+			}
+
+			if len(servers[host].Aliases) == 0 {
+				servers[host].Aliases = anns.Aliases
+				if aliases := allAliases[host]; len(aliases) == 0 {
+					allAliases[host] = anns.Aliases
+				}
+			} else {
+				klog.Warningf("Aliases already configured for server %q, skipping (Ingress %q)", host, ingKey)
+			}
+
+			if anns.ServerSnippet != "" {
+				if servers[host].ServerSnippet == "" {
+					servers[host].ServerSnippet = anns.ServerSnippet
+				} else {
+					klog.Warningf("Server snippet already configured for server %q, skipping (Ingress %q)", host, ingKey)
+				}
+			}
+
+			// only add SSL ciphers if the server does not have them previously configured
+			if servers[host].SSLCiphers == "" && anns.SSLCipher.SSLCiphers != "" {
+				servers[host].SSLCiphers = anns.SSLCipher.SSLCiphers
+			}
+
+			// only add SSLPreferServerCiphers if the server does not have them previously configured
+			if servers[host].SSLPreferServerCiphers == "" && anns.SSLCipher.SSLPreferServerCiphers != "" {
+				servers[host].SSLPreferServerCiphers = anns.SSLCipher.SSLPreferServerCiphers
+			}
+
+			// only add a certificate if the server does not have one previously configured
+			if servers[host].SSLCert != nil {
+				continue
+			}
+
+			if len(ing.Spec.TLS) == 0 {
+				klog.V(3).Infof("Ingress %q does not contains a TLS section.", ingKey)
+				continue
+			}
+
+			tlsSecretName := extractTLSSecretName(host, ing, n.store.GetLocalSSLCert)
+			if tlsSecretName == "" {
+				klog.V(3).Infof("Host %q is listed in the TLS section but secretName is empty. Using default certificate", host)
+				servers[host].SSLCert = n.getDefaultSSLCertificate()
+				continue
+			}
+
+			secrKey := fmt.Sprintf("%v/%v", ing.Namespace, tlsSecretName)
+			cert, err := n.store.GetLocalSSLCert(secrKey)
+			if err != nil {
+				klog.Warningf("Error getting SSL certificate %q: %v. Using default certificate", secrKey, err)
+				servers[host].SSLCert = n.getDefaultSSLCertificate()
+				continue
+			}
+
+			if cert.Certificate == nil {
+				klog.Warningf("SSL certificate %q does not contain a valid SSL certificate for server %q, using default certificate", secrKey, host)
+				servers[host].SSLCert = n.getDefaultSSLCertificate()
+				continue
+			}
+
+			if err := cert.Certificate.VerifyHostname(host); err != nil {
+				klog.Warningf("SSL certificate %q does not contain a Common Name or Subject Alternative Name for server %q: %v, using default certificate", secrKey, host, err)
+				servers[host].SSLCert = n.getDefaultSSLCertificate()
+				continue
+			}
+
+			servers[host].SSLCert = cert
+
+			now := time.Now()
+			if cert.ExpireTime.Before(now) {
+				klog.Warningf("SSL certificate for server %q expired (%v)", host, cert.ExpireTime)
+			} else if cert.ExpireTime.Before(now.Add(240 * time.Hour)) {
+				klog.Warningf("SSL certificate for server %q is about to expire (%v)", host, cert.ExpireTime)
+			}
+		}
+	}
+
+	for host, hostAliases := range allAliases {
+		if _, ok := servers[host]; !ok {
+			continue
+		}
+
+		uniqAliases := sets.New[string]()
+		for _, alias := range hostAliases {
+			if alias == host {
+				continue
+			}
+
+			if _, ok := servers[alias]; ok {
+				continue
+			}
+
+			uniqAliases.Insert(alias)
+		}
+
+		servers[host].Aliases = sets.List(uniqAliases)
+	}
+
+	return servers
+}
+
+// extractTLSSecretName returns the name of the Secret containing a SSL
+// certificate for the given host name, or an empty string.
+func extractTLSSecretName(host string, ing *Ingress, getLocalSSLCert func(string) (*SSLCert, error)) string {
+	if ing == nil {
+		return ""
+	}
+
+	// naively return Secret name from TLS spec if host name matches
+	lowercaseHost := strings.ToLower(host)
+	for _, tls := range ing.Spec.TLS {
+		for _, tlsHost := range tls.Hosts {
+			if strings.ToLower(tlsHost) == lowercaseHost {
+				return tls.SecretName
+			}
+		}
+	}
+
+	// no TLS host matching host name, try each TLS host for matching SAN or CN
+	for _, tls := range ing.Spec.TLS {
+		if tls.SecretName == "" {
+			// There's no secretName specified, so it will never be available
+			continue
+		}
+
+		secrKey := fmt.Sprintf("%v/%v", ing.Namespace, tls.SecretName)
+
+		cert, err := getLocalSSLCert(secrKey)
+		if err != nil {
+			klog.Warningf("Error getting SSL certificate %q: %v", secrKey, err)
+			continue
+		}
+
+		if cert == nil || cert.Certificate == nil {
+			continue
+		}
+
+		if err := cert.Certificate.VerifyHostname(host); err != nil {
+			continue
+		}
+		klog.V(3).InfoS("Found SSL certificate matching host", "host", host, "secret", secrKey)
+		return tls.SecretName
+	}
+
+	return ""
+}
+
+// locationApplyAnnotations sets the settings of the annotations of an
+// Ingress on a location
+func locationApplyAnnotations(loc *Location, anns *AnnotationsIngress) {
+	loc.BasicDigestAuth = anns.BasicDigestAuth
+	loc.ClientBodyBufferSize = anns.ClientBodyBufferSize
+	loc.CustomHeaders = anns.CustomHeaders
+	loc.ConfigurationSnippet = anns.ConfigurationSnippet
+	loc.CorsConfig = anns.CorsConfig
+	loc.ExternalAuth = anns.ExternalAuth
+	loc.EnableGlobalAuth = anns.EnableGlobalAuth
+	loc.HTTP2PushPreload = anns.HTTP2PushPreload
+	loc.Opentelemetry = anns.Opentelemetry
+	loc.Proxy = anns.Proxy
+	loc.ProxySSL = anns.ProxySSL
+	loc.RateLimit = anns.RateLimit
+	loc.Redirect = anns.Redirect
+	loc.Rewrite = anns.Rewrite
+	loc.UpstreamVhost = anns.UpstreamVhost
+	loc.Denylist = anns.Denylist
+	loc.Allowlist = anns.Allowlist
+	loc.Denied = anns.Denied
+	loc.XForwardedPrefix = anns.XForwardedPrefix
+	loc.UsePortInRedirects = anns.UsePortInRedirects
+	loc.Connection = anns.Connection
+	loc.Logs = anns.Logs
+	loc.DefaultBackend = anns.DefaultBackend
+	loc.BackendProtocol = anns.BackendProtocol
+	loc.FastCGI = anns.FastCGI
+	loc.CustomHTTPErrors = anns.CustomHTTPErrors
+	loc.DisableProxyInterceptErrors = anns.DisableProxyInterceptErrors
+	loc.ModSecurity = anns.ModSecurity
+	loc.Satisfy = anns.Satisfy
+	loc.Mirror = anns.Mirror
+	loc.SubFilter = anns.SubFilter
+
+	loc.DefaultBackendUpstreamName = defUpstreamName
+}
+
+// dropSnippetDirectives removes the snippets of the annotations when the
+// snippet annotations are disabled
+func dropSnippetDirectives(anns *AnnotationsIngress, ingKey string) {
+	if anns == nil {
+		return
+	}
+
+	if anns.ConfigurationSnippet != "" {
+		klog.V(3).Infof("Ingress %q tried to use configuration-snippet and the annotation is disabled by the admin. Removing the annotation", ingKey)
+		anns.ConfigurationSnippet = ""
+	}
+	if anns.ServerSnippet != "" {
+		klog.V(3).Infof("Ingress %q tried to use server-snippet and the annotation is disabled by the admin. Removing the annotation", ingKey)
+		anns.ServerSnippet = ""
+	}
+	if anns.ModSecurity.Snippet != "" {
+		klog.V(3).Infof("Ingress %q tried to use modsecurity-snippet and the annotation is disabled by the admin. Removing the annotation", ingKey)
+		anns.ModSecurity.Snippet = ""
+	}
+	if anns.ExternalAuth.AuthSnippet != "" {
+		klog.V(3).Infof("Ingress %q tried to use auth-snippet and the annotation is disabled by the admin. Removing the annotation", ingKey)
+		anns.ExternalAuth.AuthSnippet = ""
+	}
+	if anns.StreamSnippet != "" {
+		klog.V(3).Infof("Ingress %q tried to use stream-snippet and the annotation is disabled by the admin. Removing the annotation", ingKey)
+		anns.StreamSnippet = ""
+	}
+}
+
+// mergeAlternativeBackends adds the canary backends of an Ingress as
+// alternative backends of the upstreams of the same paths
+//
+//nolint:gocyclo // Ignore function complexity error
+func mergeAlternativeBackends(ing *Ingress, upstreams map[string]*Backend, servers map[string]*Server) {
+	// merge catch-all alternative backends
+	if ing.Spec.DefaultBackend != nil && ing.Spec.DefaultBackend.Service != nil {
+		upsName := upstreamName(ing.Namespace, ing.Spec.DefaultBackend.Service)
+
+		altUps := upstreams[upsName]
+
+		if altUps == nil {
+			klog.Warningf("alternative backend %s has already been removed", upsName)
+		} else {
+			merged := false
+			altEqualsPri := false
+
+			for _, loc := range servers[defServerName].Locations {
+				priUps := upstreams[loc.Backend]
+				altEqualsPri = altUps.Name == priUps.Name
+				if altEqualsPri {
+					klog.Warningf("alternative upstream %s in Ingress %s/%s is primary upstream in Other Ingress for location %s%s!",
+						altUps.Name, ing.Namespace, ing.Name, servers[defServerName].Hostname, loc.Path)
+					break
+				}
+
+				if canMergeBackend(priUps, altUps) {
+					klog.V(2).Infof("matching backend %v found for alternative backend %v",
+						priUps.Name, altUps.Name)
+
+					merged = mergeAlternativeBackend(ing, priUps, altUps)
+				}
+			}
+
+			if !altEqualsPri && !merged {
+				klog.Warningf("unable to find real backend for alternative backend %v. Deleting.", altUps.Name)
+				delete(upstreams, altUps.Name)
+			}
+		}
+	}
+
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+
+		host := rule.Host
+		if host == "" {
+			host = defServerName
+		}
+
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service == nil {
+				// skip non-service backends
+				klog.V(3).Infof("Ingress %q and path %q does not contain a service backend, using default backend", k8s.MetaNamespaceKey(ing), path.Path)
+				continue
+			}
+
+			upsName := upstreamName(ing.Namespace, path.Backend.Service)
+
+			altUps := upstreams[upsName]
+
+			if altUps == nil {
+				klog.Warningf("alternative backend %s has already been removed", upsName)
+				continue
+			}
+
+			merged := false
+			altEqualsPri := false
+
+			server, ok := servers[host]
+			if !ok {
+				klog.Errorf("cannot merge alternative backend %s into hostname %s that does not exist",
+					altUps.Name,
+					host)
+
+				continue
+			}
+
+			// find matching paths
+			for _, loc := range server.Locations {
+				priUps := upstreams[loc.Backend]
+				altEqualsPri = altUps.Name == priUps.Name
+				if altEqualsPri {
+					klog.Warningf("alternative upstream %s in Ingress %s/%s is primary upstream in Other Ingress for location %s%s!",
+						altUps.Name, ing.Namespace, ing.Name, server.Hostname, loc.Path)
+					break
+				}
+
+				if canMergeBackend(priUps, altUps) && loc.Path == path.Path && path.PathType != nil &&
+					loc.PathType != nil && *loc.PathType == *path.PathType {
+					klog.V(2).Infof("matching backend %v found for alternative backend %v",
+						priUps.Name, altUps.Name)
+
+					merged = mergeAlternativeBackend(ing, priUps, altUps)
+				}
+			}
+
+			if !altEqualsPri && !merged {
+				klog.Warningf("unable to find real backend for alternative backend %v. Deleting.", altUps.Name)
+				delete(upstreams, altUps.Name)
+			}
+		}
+	}
+}
+
+// canMergeBackend reports whether alternative can be an alternative backend
+// of primary
+func canMergeBackend(primary, alternative *Backend) bool {
+	return alternative != nil && primary.Name != alternative.Name && primary.Name != defUpstreamName && !primary.NoServer
+}
+
+// mergeAlternativeBackend adds altUps as an alternative backend of priUps
+func mergeAlternativeBackend(ing *Ingress, priUps, altUps *Backend) bool {
+	if priUps.NoServer {
+		klog.Warningf("unable to merge alternative backend %v into primary backend %v because the primary backend is a canary",
+			altUps.Name, priUps.Name)
+		return false
+	}
+
+	for _, ab := range priUps.AlternativeBackends {
+		if ab == altUps.Name {
+			klog.V(2).Infof("skip merge alternative backend %v into %v, it's already present", altUps.Name, priUps.Name)
+			return true
+		}
+	}
+
+	if ing.ParsedAnnotations != nil && ing.ParsedAnnotations.SessionAffinity.CanaryBehavior != "legacy" {
+		priUps.SessionAffinity.DeepCopyInto(&altUps.SessionAffinity)
+	}
+
+	priUps.AlternativeBackends = append(priUps.AlternativeBackends, altUps.Name)
+
+	return true
+}
+
+// nonCanaryIngressExists reports whether some Ingresses are not canaries
+func nonCanaryIngressExists(ingresses, canaryIngresses []*Ingress) bool {
+	return len(ingresses)-len(canaryIngresses) > 0
+}
+
+// shouldCreateUpstreamForLocationDefaultBackend reports whether the custom
+// default backend of a location needs its own upstream: the upstream of the
+// location has no endpoints or intercepts errors
+func shouldCreateUpstreamForLocationDefaultBackend(upstream *Backend, location *Location) bool {
+	return (upstream.Name == location.Backend) &&
+		(len(upstream.Endpoints) == 0 || len(location.CustomHTTPErrors) != 0) &&
+		location.DefaultBackend != nil
+}
+
+// newUpstream returns an empty upstream named name
+func newUpstream(name string) *Backend {
+	return &Backend{
+		Name:      name,
+		Endpoints: []Endpoint{},
+		Service:   &apiv1.Service{},
+		SessionAffinity: SessionAffinityConfig{
+			CookieSessionAffinity: CookieSessionAffinity{
+				Locations: make(map[string][]string),
+			},
+		},
+	}
+}
+
+// upstreamName returns a formatted upstream name based on namespace, service, and port
+func upstreamName(namespace string, service *networking.IngressServiceBackend) string {
+	if service != nil {
+		if service.Port.Number > 0 {
+			return fmt.Sprintf("%s-%s-%d", namespace, service.Name, service.Port.Number)
+		}
+		if service.Port.Name != "" {
+			return fmt.Sprintf("%s-%s-%s", namespace, service.Name, service.Port.Name)
+		}
+	}
+	return fmt.Sprintf("%s-INVALID", namespace)
+}
+
+// upstreamServiceNameAndPort verifies the service is not nil, and also returns the service name and port
+func upstreamServiceNameAndPort(service *networking.IngressServiceBackend) (string, intstr.IntOrString) {
+	if service != nil {
+		if service.Port.Number > 0 {
+			return service.Name, intstr.FromInt32(service.Port.Number)
+		}
+		if service.Port.Name != "" {
+			return service.Name, intstr.FromString(service.Port.Name)
+		}
+	}
+	return "", intstr.IntOrString{}
+}
+
+// newTrafficShapingPolicy returns the traffic shaping policy of a canary
+func newTrafficShapingPolicy(cfg *canary.Config) TrafficShapingPolicy {
+	return TrafficShapingPolicy{
+		Weight:        cfg.Weight,
+		WeightTotal:   cfg.WeightTotal,
+		Header:        cfg.Header,
+		HeaderValue:   cfg.HeaderValue,
+		HeaderPattern: cfg.HeaderPattern,
+		Cookie:        cfg.Cookie,
+	}
+}
+
+// DefaultEndpoint returns the default endpoint to be use as default server that returns 404.
+func (n *NGINXController) DefaultEndpoint() Endpoint {
+	return Endpoint{
+		Address: "127.0.0.1",
+		Port:    strconv.Itoa(n.cfg.ListenPorts.Default),
+		Target:  &apiv1.ObjectReference{},
+	}
+}
+
+// getIngressPodZone returns the zone of the ingress controller pod used for
+// the topology aware routing of svc. The validator does not run on the nodes
+// of the ingress controller, so the endpoints are never filtered by zone.
+func getIngressPodZone(_ *apiv1.Service) string {
+	return emptyZone
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultCRLWarningPeriod is the time before the NextUpdate of a CRL from
+// which the CRL is reported as about to become stale
+const defaultCRLWarningPeriod = 72 * time.Hour
+
+// crlSource is a Secret containing a ca.crl used by a server
+type crlSource struct {
+	secret string
+	hosts  []string
+	server *Server
+}
+
+// checkCRLs validates the certificate revocation lists used by the servers,
+// for TLS and for mutual authentication. A CRL must parse, be signed by the
+// CA of the same Secret and not be past its NextUpdate: nginx rejects every
+// client certificate once the CRL has expired.
+func (n *NGINXController) checkCRLs(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	now := time.Now()
+
+	sources := map[string]*crlSource{}
+	add := func(secret string, server *Server) {
+		if src, ok := sources[secret]; ok {
+			src.hosts = append(src.hosts, server.Hostname)
+			return
+		}
+		sources[secret] = &crlSource{secret: secret, hosts: []string{server.Hostname}, server: server}
+	}
+	for _, server := range cfg.Servers {
+		if server.SSLCert != nil && server.SSLCert.CRLFileName != "" {
+			add(fmt.Sprintf("%v/%v", server.SSLCert.Namespace, server.SSLCert.Name), server)
+		}
+		if auth := server.CertificateAuth.AuthSSLCert; auth.CRLFileName != "" && auth.Secret != "" {
+			add(auth.Secret, server)
+		}
+	}
+
+	secrets := make([]string, 0, len(sources))
+	for secret := range sources {
+		secrets = append(secrets, secret)
+	}
+	sort.Strings(secrets)
+
+	for _, secretKey := range secrets {
+		src := sources[secretKey]
+		report := func(severity Severity, format string, args ...interface{}) {
+			findings = append(findings, Finding{
+				Rule:     "crl",
+				Severity: severity,
+				Ingress:  serverIngress(src.server),
+				Host:     src.server.Hostname,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		}
+
+		secret, err := n.store.GetSecret(secretKey)
+		if err != nil {
+			report(SeverityError, "secret %v containing the CRL does not exist", secretKey)
+			continue
+		}
+
+		cas, err := parsePEMCertificates(secret.Data["ca.crt"])
+		if err != nil || len(cas) == 0 {
+			report(SeverityError, "ca.crl of %v can not be verified without a valid ca.crt", secretKey)
+			continue
+		}
+
+		crl, err := parseCRL(secret.Data["ca.crl"], cas)
+		if err != nil {
+			report(SeverityError, "ca.crl of %v is not valid: %v; nginx fails to load the configuration", secretKey, err)
+			continue
+		}
+
+		switch {
+		case crl.NextUpdate.IsZero():
+			report(SeverityWarning, "ca.crl of %v has no NextUpdate time, its freshness can not be checked", secretKey)
+		case now.After(crl.NextUpdate):
+			report(SeverityError, "ca.crl of %v expired on %v; nginx rejects every client certificate of %v",
+				secretKey, crl.NextUpdate.Format(time.RFC3339), strings.Join(src.hosts, ", "))
+		case crl.NextUpdate.Sub(now) < n.cfg.CRLWarningPeriod:
+			report(SeverityWarning, "ca.crl of %v expires on %v, publish a new CRL before nginx starts rejecting the client certificates",
+				secretKey, crl.NextUpdate.Format(time.RFC3339))
+		}
+	}
+
+	return findings
+}
+
+// parseCRL parses a PEM or DER encoded certificate revocation list and checks
+// it is signed by one of the CAs
+func parseCRL(data []byte, cas []*x509.Certificate) (*x509.RevocationList, error) {
+	der := data
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "X509 CRL" {
+			return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+		}
+		der = block.Bytes
+	}
+
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ca := range cas {
+		if crl.CheckSignatureFrom(ca) == nil {
+			return crl, nil
+		}
+	}
+	return crl, fmt.Errorf("the CRL issued by %q is not signed by any CA of ca.crt", crl.Issuer.CommonName)
+}
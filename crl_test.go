@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// testCRL returns an empty PEM revocation list signed by the CA
+func testCRL(t *testing.T, ca *x509.Certificate, key *ecdsa.PrivateKey, nextUpdate time.Time) []byte {
+	t.Helper()
+
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: nextUpdate.Add(-7 * 24 * time.Hour),
+		NextUpdate: nextUpdate,
+	}, ca, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+}
+
+func TestCheckCRLs(t *testing.T) {
+	now := time.Now()
+	caTemplate := func(serial int64, name string) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             now.Add(-30 * 24 * time.Hour),
+			NotAfter:              now.Add(365 * 24 * time.Hour),
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		}
+	}
+	ca, caKey, caPEM, _ := testCertificate(t, caTemplate(1, "client ca"), nil, nil)
+	other, otherKey, _, _ := testCertificate(t, caTemplate(2, "other ca"), nil, nil)
+
+	tests := []struct {
+		name string
+		crl  []byte
+		// expected are the beginnings of the findings
+		expected []string
+	}{
+		{
+			name:     "fresh CRL",
+			crl:      testCRL(t, ca, caKey, now.Add(7*24*time.Hour)),
+			expected: []string{},
+		},
+		{
+			name:     "CRL about to expire",
+			crl:      testCRL(t, ca, caKey, now.Add(time.Hour)),
+			expected: []string{"warning: ca.crl of default/client-ca expires on " + now.Add(time.Hour).UTC().Format(time.RFC3339)},
+		},
+		{
+			name:     "expired CRL",
+			crl:      testCRL(t, ca, caKey, now.Add(-time.Hour)),
+			expected: []string{"error: ca.crl of default/client-ca expired on " + now.Add(-time.Hour).UTC().Format(time.RFC3339)},
+		},
+		{
+			name:     "CRL of another CA",
+			crl:      testCRL(t, other, otherKey, now.Add(7*24*time.Hour)),
+			expected: []string{`error: ca.crl of default/client-ca is not valid: the CRL issued by "other ca" is not signed by any CA of ca.crt`},
+		},
+		{
+			name:     "invalid CRL",
+			crl:      caPEM,
+			expected: []string{`error: ca.crl of default/client-ca is not valid: unexpected PEM block "CERTIFICATE"`},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n := newTestController(t, authTLSIngress(map[string]string{"auth-tls-secret": "default/client-ca"}))
+			if err := n.store.(*memoryStore).Add(&apiv1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "client-ca", Namespace: "default"},
+				Data:       map[string][]byte{"ca.crt": caPEM, "ca.crl": tc.crl},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			n.cfg.CRLWarningPeriod = defaultCRLWarningPeriod
+			ingresses := n.store.ListIngresses()
+			_, _, cfg := n.getConfiguration(ingresses)
+
+			got := []string{}
+			for _, f := range n.checkCRLs(ingresses, cfg) {
+				if f.Rule != "crl" || f.Host != "web.example.com" {
+					t.Errorf("unexpected finding %+v", f)
+				}
+				got = append(got, fmt.Sprintf("%v: %v", f.Severity, f.Message))
+			}
+			if len(got) != len(tc.expected) {
+				t.Fatalf("expected %q, got %q", tc.expected, got)
+			}
+			for i := range got {
+				if !strings.HasPrefix(got[i], tc.expected[i]) {
+					t.Errorf("expected %q, got %q", tc.expected[i], got[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// customHTTPErrorCode returns the problem of a code of custom-http-errors,
+// empty when nginx intercepts it. error_page only accepts the codes between
+// 300 and 599, and intercepting redirects replaces them by the error page.
+func customHTTPErrorCode(code int) (Severity, string) {
+	switch {
+	case code < 100 || code > 599:
+		return SeverityError, fmt.Sprintf("%d is not an HTTP status code; nginx fails to reload", code)
+	case code < 300:
+		return SeverityError, fmt.Sprintf("%d can not be intercepted, error_page only accepts 300 to 599; nginx fails to reload", code)
+	case code < 400:
+		return SeverityWarning, fmt.Sprintf("%d is a redirect; the clients receive the error page instead of being redirected", code)
+	}
+	return "", ""
+}
+
+// checkCustomErrors validates the custom-http-errors of the ConfigMap and
+// of the locations, the default-backend annotations serving them and the
+// disable-proxy-intercept-errors annotations, which the ingress controller
+// otherwise only logs
+func (n *NGINXController) checkCustomErrors(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	global := n.store.GetBackendConfiguration()
+
+	for _, code := range global.CustomHTTPErrors {
+		if severity, problem := customHTTPErrorCode(code); problem != "" {
+			findings = append(findings, Finding{
+				Rule:     "custom-http-errors-invalid",
+				Severity: severity,
+				Message:  fmt.Sprintf("custom-http-errors of ConfigMap %v: %v", n.cfg.ConfigMapName, problem),
+			})
+		}
+	}
+
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			for _, code := range loc.CustomHTTPErrors {
+				if severity, problem := customHTTPErrorCode(code); problem != "" {
+					findings = append(findings, newLocationFinding("custom-http-errors-invalid", severity, server, loc,
+						"custom-http-errors of location %q: %v", loc.Path, problem))
+				}
+			}
+
+			if loc.DisableProxyInterceptErrors && len(loc.CustomHTTPErrors) == 0 && len(global.CustomHTTPErrors) == 0 {
+				findings = append(findings, newLocationFinding("disable-proxy-intercept-errors-ineffective", SeverityWarning, server, loc,
+					"location %q sets disable-proxy-intercept-errors but no custom-http-errors is configured for it nor in the ConfigMap; the annotation has no effect",
+					loc.Path))
+			}
+
+			if loc.Ingress == nil {
+				continue
+			}
+			name, ok := loc.Ingress.Annotations[parser.GetAnnotationWithPrefix("default-backend")]
+			switch {
+			case !ok:
+			case loc.DefaultBackend == nil:
+				// the ingress controller ignores the annotation when the
+				// Service can not be read
+				findings = append(findings, newLocationFinding("default-backend-missing", SeverityError, server, loc,
+					"default-backend %v/%v of location %q does not exist; the errors are served by the default backend of the ingress controller",
+					loc.Ingress.Namespace, name, loc.Path))
+			case len(loc.DefaultBackend.Spec.Ports) == 0:
+				findings = append(findings, newLocationFinding("default-backend-no-ports", SeverityError, server, loc,
+					"default-backend %v of location %q has no ports; the ingress controller ignores it",
+					k8s.MetaNamespaceKey(loc.DefaultBackend), loc.Path))
+			case loc.DefaultBackendUpstreamName == defUpstreamName:
+				findings = append(findings, newLocationFinding("default-backend-no-endpoints", SeverityWarning, server, loc,
+					"default-backend %v of location %q has no ready endpoints; the errors are served by the default backend of the ingress controller",
+					k8s.MetaNamespaceKey(loc.DefaultBackend), loc.Path))
+			}
+		}
+	}
+
+	return findings
+}
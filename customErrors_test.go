@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCustomHTTPErrorCode(t *testing.T) {
+	for code, expected := range map[int]Severity{
+		99:  SeverityError,
+		600: SeverityError,
+		200: SeverityError,
+		302: SeverityWarning,
+		404: "",
+		503: "",
+	} {
+		if severity, problem := customHTTPErrorCode(code); severity != expected || (problem == "") != (expected == "") {
+			t.Errorf("expected %q for %v, got %q %q", expected, code, severity, problem)
+		}
+	}
+}
+
+// customErrorsManifests is completed with the custom-http-errors of the
+// ConfigMap and the annotations of the Ingress
+const customErrorsManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+data:
+  custom-http-errors: "%v"
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: errors
+  namespace: default
+spec:
+  ports:
+  - name: http
+    port: 80
+---
+apiVersion: discovery.k8s.io/v1
+kind: EndpointSlice
+metadata:
+  name: errors-1
+  namespace: default
+  labels:
+    kubernetes.io/service-name: errors
+addressType: IPv4
+endpoints:
+- addresses: [10.0.0.9]
+ports:
+- name: http
+  port: 8080
+  protocol: TCP
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: errors-idle
+  namespace: default
+spec:
+  ports:
+  - name: http
+    port: 80
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: errors-no-ports
+  namespace: default
+spec:
+  type: ExternalName
+  externalName: errors.example.com
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+  annotations:
+%v
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`
+
+func TestCheckCustomErrors(t *testing.T) {
+	testCases := map[string]struct {
+		global      string
+		annotations string
+		expected    []string
+	}{
+		"valid": {
+			global: "404,503",
+			annotations: `    nginx.ingress.kubernetes.io/custom-http-errors: "502"
+    nginx.ingress.kubernetes.io/default-backend: errors`,
+		},
+		"invalid codes": {
+			global:      "404,200",
+			annotations: `    nginx.ingress.kubernetes.io/custom-http-errors: "301,503"`,
+			expected: []string{
+				"custom-http-errors-invalid error ",
+				"custom-http-errors-invalid warning web.example.com",
+			},
+		},
+		"proxy intercept errors without custom errors": {
+			annotations: `    nginx.ingress.kubernetes.io/disable-proxy-intercept-errors: "true"`,
+			expected:    []string{"disable-proxy-intercept-errors-ineffective warning web.example.com"},
+		},
+		"proxy intercept errors with the custom errors of the ConfigMap": {
+			global:      "404",
+			annotations: `    nginx.ingress.kubernetes.io/disable-proxy-intercept-errors: "true"`,
+		},
+		"missing default backend": {
+			annotations: `    nginx.ingress.kubernetes.io/default-backend: errors-missing`,
+			expected:    []string{"default-backend-missing error web.example.com"},
+		},
+		"default backend without ports": {
+			annotations: `    nginx.ingress.kubernetes.io/default-backend: errors-no-ports`,
+			expected:    []string{"default-backend-no-ports error web.example.com"},
+		},
+		"default backend without endpoints": {
+			annotations: `    nginx.ingress.kubernetes.io/default-backend: errors-idle`,
+			expected:    []string{"default-backend-no-endpoints warning web.example.com"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, fmt.Sprintf(customErrorsManifests, tc.global, tc.annotations))
+			findings := []string{}
+			for _, f := range n.checkCustomErrors(ingresses, cfg) {
+				findings = append(findings, fmt.Sprintf("%v %v %v", f.Rule, f.Severity, f.Host))
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(findings, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, findings)
+			}
+		})
+	}
+}
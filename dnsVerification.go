@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+const (
+	// defaultDNSTimeout is used when DNSTimeout is not set
+	defaultDNSTimeout = 5 * time.Second
+	// dnsLookupWorkers is the number of hosts looked up in parallel
+	dnsLookupWorkers = 8
+	// wildcardProbeLabel replaces the * of wildcard hosts in the lookups
+	wildcardProbeLabel = "nginx-config-validator-probe"
+)
+
+// dnsLookup is the result of the lookup of a host
+type dnsLookup struct {
+	addresses []string
+	cname     string
+	err       error
+}
+
+// publishedAddresses returns the addresses of the ingress controller:
+// PublishStatusAddress when set, the addresses of the PublishService otherwise
+func (n *NGINXController) publishedAddresses() ([]string, error) {
+	var addresses []string
+	switch {
+	case n.cfg.PublishStatusAddress != "":
+		for _, addr := range strings.Split(n.cfg.PublishStatusAddress, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				addresses = append(addresses, addr)
+			}
+		}
+	case n.cfg.PublishService != "":
+		svc, err := n.store.GetService(n.cfg.PublishService)
+		if err != nil {
+			return nil, fmt.Errorf("getting publish service %v: %w", n.cfg.PublishService, err)
+		}
+		addresses = publishServiceAddresses(svc)
+	default:
+		return nil, fmt.Errorf("neither --publish-service nor --publish-status-address is set")
+	}
+
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("the controller has no published address")
+	}
+	return addresses, nil
+}
+
+// controllerAddresses returns the addresses clients must resolve the hosts
+// to. The hostnames of cloud load balancers are resolved too.
+func (n *NGINXController) controllerAddresses(ctx context.Context, resolver *net.Resolver) (map[string]bool, error) {
+	addresses, err := n.publishedAddresses()
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]bool{}
+	for _, addr := range addresses {
+		result[strings.ToLower(addr)] = true
+		if net.ParseIP(addr) != nil {
+			continue
+		}
+		ips, err := resolver.LookupHost(ctx, addr)
+		if err != nil {
+			klog.Warningf("Error resolving controller address %v: %v", addr, err)
+			continue
+		}
+		for _, ip := range ips {
+			result[ip] = true
+		}
+	}
+
+	return result, nil
+}
+
+// publishServiceAddresses returns the addresses of the load balancer of the
+// Service, or its external IPs
+func publishServiceAddresses(svc *apiv1.Service) []string {
+	var addresses []string
+	for _, lb := range svc.Status.LoadBalancer.Ingress {
+		if lb.IP != "" {
+			addresses = append(addresses, lb.IP)
+		}
+		if lb.Hostname != "" {
+			addresses = append(addresses, lb.Hostname)
+		}
+	}
+	return append(addresses, svc.Spec.ExternalIPs...)
+}
+
+// checkDNSRecords looks up every host in DNS and reports the hosts that do
+// not resolve, or resolve to addresses that are not the controller's. nginx
+// is configured correctly for them, but the traffic never reaches it.
+func (n *NGINXController) checkDNSRecords(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	if !n.cfg.VerifyDNS {
+		return findings
+	}
+
+	timeout := n.cfg.DNSTimeout
+	if timeout == 0 {
+		timeout = defaultDNSTimeout
+	}
+	resolver := net.DefaultResolver
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	controller, err := n.controllerAddresses(ctx, resolver)
+	cancel()
+	if err != nil {
+		findings = append(findings, Finding{
+			Rule:     "dns-verification",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("unable to verify DNS records: %v", err),
+		})
+		return findings
+	}
+
+	hosts := []string{}
+	for _, server := range cfg.Servers {
+		if server.Hostname == "_" || server.Hostname == "" {
+			continue
+		}
+		hosts = append(hosts, server.Hostname)
+		hosts = append(hosts, server.Aliases...)
+	}
+
+	lookups := lookupHosts(hosts, resolver, timeout)
+
+	for _, server := range cfg.Servers {
+		if server.Hostname == "_" || server.Hostname == "" {
+			continue
+		}
+		for _, host := range append([]string{server.Hostname}, server.Aliases...) {
+			lookup := lookups[host]
+			if lookup.err != nil {
+				findings = append(findings, Finding{
+					Rule:     "dns-unresolved",
+					Severity: SeverityWarning,
+					Ingress:  serverIngress(server),
+					Host:     server.Hostname,
+					Message:  fmt.Sprintf("%v does not resolve: %v", host, lookup.err),
+				})
+				continue
+			}
+
+			if controller[strings.ToLower(strings.TrimSuffix(lookup.cname, "."))] {
+				continue
+			}
+			matched := false
+			for _, addr := range lookup.addresses {
+				if controller[addr] {
+					matched = true
+					break
+				}
+			}
+			if matched {
+				continue
+			}
+
+			findings = append(findings, Finding{
+				Rule:     "dns-mismatch",
+				Severity: SeverityWarning,
+				Ingress:  serverIngress(server),
+				Host:     server.Hostname,
+				Message: fmt.Sprintf("%v resolves to %v, which is not an address of the ingress controller (%v); requests do not reach this configuration",
+					host, strings.Join(lookup.addresses, ", "), strings.Join(sortedSet(controller), ", ")),
+			})
+		}
+	}
+
+	return findings
+}
+
+// lookupHosts resolves the hosts in parallel
+func lookupHosts(hosts []string, resolver *net.Resolver, timeout time.Duration) map[string]dnsLookup {
+	results := map[string]dnsLookup{}
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, dnsLookupWorkers)
+
+	for _, host := range hosts {
+		lock.Lock()
+		_, done := results[host]
+		results[host] = dnsLookup{}
+		lock.Unlock()
+		if done {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(host string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			name := host
+			if strings.HasPrefix(name, "*.") {
+				name = wildcardProbeLabel + name[1:]
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			lookup := dnsLookup{}
+			lookup.addresses, lookup.err = resolver.LookupHost(ctx, name)
+			if lookup.err == nil {
+				// errors are ignored, hosts without CNAME return their own name
+				lookup.cname, _ = resolver.LookupCNAME(ctx, name)
+			}
+			sort.Strings(lookup.addresses)
+
+			lock.Lock()
+			results[host] = lookup
+			lock.Unlock()
+		}(host)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// serverIngress returns the Ingress of the first location of the server
+func serverIngress(server *Server) string {
+	for _, loc := range server.Locations {
+		if loc.Ingress != nil {
+			return k8s.MetaNamespaceKey(loc.Ingress)
+		}
+	}
+	return ""
+}
+
+func sortedSet(set map[string]bool) []string {
+	items := make([]string, 0, len(set))
+	for item := range set {
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	return items
+}
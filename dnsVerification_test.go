@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// hostIngress returns the manifest of an Ingress serving host
+func hostIngress(host string) string {
+	return fmt.Sprintf(`
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: %v
+`, host)
+}
+
+func TestCheckDNSRecords(t *testing.T) {
+	tests := []struct {
+		name          string
+		host          string
+		statusAddress string
+		verify        bool
+		expected      []string
+	}{
+		{
+			name:          "disabled",
+			host:          "web.invalid",
+			statusAddress: "10.0.0.1",
+			expected:      []string{},
+		},
+		{
+			name:          "controller address",
+			host:          "localhost",
+			statusAddress: "127.0.0.1, ::1",
+			verify:        true,
+			expected:      []string{},
+		},
+		{
+			name:          "other address",
+			host:          "localhost",
+			statusAddress: "10.0.0.1",
+			verify:        true,
+			expected:      []string{"dns-mismatch"},
+		},
+		{
+			name:          "unresolved host",
+			host:          "web.invalid",
+			statusAddress: "10.0.0.1",
+			verify:        true,
+			expected:      []string{"dns-unresolved"},
+		},
+		{
+			name:     "no published address",
+			host:     "localhost",
+			verify:   true,
+			expected: []string{"dns-verification"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, hostIngress(tc.host))
+			n.cfg.VerifyDNS = tc.verify
+			n.cfg.PublishStatusAddress = tc.statusAddress
+			n.cfg.DNSTimeout = 2 * time.Second
+
+			got := []string{}
+			for _, f := range n.checkDNSRecords(ingresses, cfg) {
+				got = append(got, f.Rule)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestPublishServiceAddresses(t *testing.T) {
+	svc := &apiv1.Service{
+		Spec: apiv1.ServiceSpec{ExternalIPs: []string{"192.0.2.10"}},
+		Status: apiv1.ServiceStatus{LoadBalancer: apiv1.LoadBalancerStatus{Ingress: []apiv1.LoadBalancerIngress{
+			{IP: "203.0.113.1"},
+			{Hostname: "lb.example.com"},
+		}}},
+	}
+
+	expected := []string{"203.0.113.1", "lb.example.com", "192.0.2.10"}
+	if got := publishServiceAddresses(svc); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
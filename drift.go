@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// runningConfigTimeout limits the time spent fetching the running
+	// nginx.conf from a status endpoint
+	runningConfigTimeout = 10 * time.Second
+	// maxRunningConfigSize limits the size of the running nginx.conf read
+	maxRunningConfigSize = 64 << 20
+)
+
+// readRunningConfig returns the nginx.conf served by the ingress controller,
+// read from a file, usually the nginx directory of a controller pod shared
+// through a volume, or from an http(s) status endpoint
+func readRunningConfig(ctx context.Context, source string) (string, error) {
+	if u, err := url.Parse(source); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		ctx, cancel := context.WithTimeout(ctx, runningConfigTimeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("%v answered %v", u.Host, resp.Status)
+		}
+		raw, err := io.ReadAll(io.LimitReader(resp.Body, maxRunningConfigSize))
+		if err != nil {
+			return "", err
+		}
+		return string(raw), nil
+	}
+
+	raw, err := os.ReadFile(source)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+// configDrift compares the servers and locations of the nginx.conf served by
+// the ingress controller with the desired configuration. Servers missing
+// from nginx.conf are usually left by a failed reload, servers or locations
+// nginx serves but no Ingress defines by a manual edit.
+func configDrift(running string, cfg *Configuration) ([]Finding, error) {
+	served := parseNginxConfRoutes(running)
+	if len(served) == 0 {
+		return nil, fmt.Errorf("no server found, the running configuration is not a nginx.conf rendered by ingress-nginx")
+	}
+
+	desired := map[string][]string{}
+	ingresses := map[string]string{}
+	for _, server := range cfg.Servers {
+		enforceRegex := enforceRegexModifier(server.Locations)
+		for _, loc := range server.Locations {
+			desired[server.Hostname] = append(desired[server.Hostname], conformanceLocationKey(loc, enforceRegex))
+		}
+		ingresses[server.Hostname] = serverIngress(server)
+	}
+
+	hosts := map[string]bool{}
+	for host := range desired {
+		hosts[host] = true
+	}
+	for host := range served {
+		hosts[host] = true
+	}
+
+	findings := []Finding{}
+	for _, host := range sortedSet(hosts) {
+		// the default server only contains the internal locations
+		if host == "_" {
+			continue
+		}
+		want, got := sortedUnique(desired[host]), sortedUnique(served[host])
+		switch {
+		case len(got) == 0:
+			findings = append(findings, Finding{
+				Rule:     "config-drift",
+				Severity: SeverityError,
+				Ingress:  ingresses[host],
+				Host:     host,
+				Message:  fmt.Sprintf("server %v is not in the nginx.conf served by the ingress controller; the last reload failed or did not happen", host),
+			})
+			continue
+		case len(want) == 0:
+			findings = append(findings, Finding{
+				Rule:     "config-drift",
+				Severity: SeverityWarning,
+				Host:     host,
+				Message:  fmt.Sprintf("server %v is served by nginx but no Ingress defines it; nginx.conf was edited by hand or the reload removing it failed", host),
+			})
+			continue
+		}
+
+		if missing := difference(want, got); len(missing) > 0 {
+			findings = append(findings, Finding{
+				Rule:     "config-drift",
+				Severity: SeverityError,
+				Ingress:  ingresses[host],
+				Host:     host,
+				Message: fmt.Sprintf("locations %v of server %v are not in the nginx.conf served by the ingress controller; the last reload failed or did not happen",
+					strings.Join(missing, ", "), host),
+			})
+		}
+		if extra := difference(got, want); len(extra) > 0 {
+			findings = append(findings, Finding{
+				Rule:     "config-drift",
+				Severity: SeverityWarning,
+				Ingress:  ingresses[host],
+				Host:     host,
+				Message: fmt.Sprintf("locations %v of server %v are served by nginx but no Ingress defines them; nginx.conf was edited by hand or the reload removing them failed",
+					strings.Join(extra, ", "), host),
+			})
+		}
+	}
+
+	return findings, nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// runningConfig returns an nginx.conf rendered by ingress-nginx with the
+// locations of web.example.com
+func runningConfig(locations ...string) string {
+	var b strings.Builder
+	b.WriteString("http {\n\t## start server _\n\tserver {\n\t\tlocation / {\n\t\t}\n\t}\n\t## end server _\n\n")
+	b.WriteString("\t## start server web.example.com\n\tserver {\n\t\tserver_name web.example.com ;\n")
+	for _, loc := range locations {
+		fmt.Fprintf(&b, "\t\tlocation %v {\n\t\t}\n", loc)
+	}
+	b.WriteString("\t}\n\t## end server web.example.com\n}\n")
+	return b.String()
+}
+
+func TestReadRunningConfig(t *testing.T) {
+	conf := runningConfig("/")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/nginx.conf" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, conf)
+	}))
+	t.Cleanup(server.Close)
+	dir := t.TempDir()
+
+	testCases := map[string]struct {
+		source    string
+		expectErr string
+	}{
+		"file":          {source: writeTestFile(t, dir, "nginx.conf", conf)},
+		"URL":           {source: server.URL + "/nginx.conf"},
+		"missing file":  {source: filepath.Join(dir, "missing.conf"), expectErr: "no such file"},
+		"URL not found": {source: server.URL + "/missing", expectErr: "answered 404 Not Found"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			running, err := readRunningConfig(context.Background(), tc.source)
+			if tc.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectErr) {
+					t.Errorf("expected an error containing %q, got %v", tc.expectErr, err)
+				}
+				return
+			}
+			if err != nil || running != conf {
+				t.Errorf("expected the configuration, got %q %v", running, err)
+			}
+		})
+	}
+}
+
+func TestConfigDrift(t *testing.T) {
+	_, _, cfg := testConfiguration(t, isolationManifests)
+
+	testCases := map[string]struct {
+		running  string
+		expected []string
+	}{
+		"in sync": {
+			running: runningConfig("/", "/api/", "= /api", "@custom_upstream-default-backend_404"),
+		},
+		"missing location": {
+			running: runningConfig("/", "/api/"),
+			expected: []string{
+				"error default/api: locations = /api of server web.example.com are not in the nginx.conf served by the ingress controller; the last reload failed or did not happen",
+			},
+		},
+		"extra location": {
+			running: runningConfig("/", "/api/", "= /api", "/debug"),
+			expected: []string{
+				"warning default/api: locations /debug of server web.example.com are served by nginx but no Ingress defines them; nginx.conf was edited by hand or the reload removing them failed",
+			},
+		},
+		"missing server": {
+			running: strings.ReplaceAll(runningConfig("/"), "web.example.com", "old.example.com"),
+			expected: []string{
+				"warning : server old.example.com is served by nginx but no Ingress defines it; nginx.conf was edited by hand or the reload removing it failed",
+				"error default/api: server web.example.com is not in the nginx.conf served by the ingress controller; the last reload failed or did not happen",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			drift, err := configDrift(tc.running, cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			findings := []string{}
+			for _, f := range drift {
+				if f.Rule != "config-drift" {
+					t.Errorf("unexpected rule %v", f.Rule)
+				}
+				findings = append(findings, fmt.Sprintf("%v %v: %v", f.Severity, f.Ingress, f.Message))
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(findings, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, findings)
+			}
+		})
+	}
+
+	if _, err := configDrift("<html>status</html>", cfg); err == nil || !strings.Contains(err.Error(), "not a nginx.conf rendered by ingress-nginx") {
+		t.Errorf("expected the configuration to be rejected, got %v", err)
+	}
+}
+
+func TestPersistentDrift(t *testing.T) {
+	a := Finding{Rule: "config-drift", Host: "a.example.com"}
+	b := Finding{Rule: "config-drift", Host: "b.example.com"}
+	if got := persistentDrift([]Finding{a}, []Finding{a, b}); !reflect.DeepEqual(got, []Finding{a}) {
+		t.Errorf("expected only the difference found twice, got %+v", got)
+	}
+	if got := persistentDrift(nil, []Finding{a}); got == nil || len(got) != 0 {
+		t.Errorf("expected no difference after a single audit, got %+v", got)
+	}
+}
+
+func TestAuditConfigDrift(t *testing.T) {
+	endpoint := newNotificationServer(t)
+	n := newTestController(t, isolationManifests)
+	n.cfg.DisableLeaderElection = true
+	n.cfg.RunningConfig = writeTestFile(t, t.TempDir(), "nginx.conf", runningConfig("/", "/api/"))
+	t.Setenv(notifySinksEnv, "http="+endpoint.URL)
+	var err error
+	if n.notifications, err = newNotifications(&NginxConfiguration{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a reload in progress looks like a drift, it is notified when found
+	// again by the next audit, and only once
+	if report := n.audit(); len(report.ConfigDrift) != 1 || len(endpoint.received()) != 0 {
+		t.Fatalf("expected a difference without notification, got %+v and %q", report.ConfigDrift, endpoint.received())
+	}
+	n.audit()
+	n.audit()
+	got := endpoint.received()
+	if len(got) != 1 {
+		t.Fatalf("expected one notification, got %q", got)
+	}
+	m := notification{}
+	if err := json.Unmarshal([]byte(got[0]), &m); err != nil {
+		t.Fatalf("invalid notification %q: %v", got[0], err)
+	}
+	if !strings.Contains(m.Title, "drifted from the Ingresses (1 differences)") || len(m.Findings) != 1 {
+		t.Errorf("unexpected notification %+v", m)
+	}
+
+	// the running configuration can not be read
+	n.cfg.RunningConfig = filepath.Join(t.TempDir(), "missing.conf")
+	if report := n.audit(); report.ConfigDrift == nil || len(report.ConfigDrift) != 0 {
+		t.Errorf("expected no difference, got %+v", report.ConfigDrift)
+	}
+}
+
+// driftFixture returns the configuration of the Ingresses of a fixture of
+// testdata/drift and its rendered nginx.conf
+func driftFixture(t *testing.T, name string) (*Configuration, string) {
+	t.Helper()
+
+	dir := filepath.Join("testdata", "drift", name)
+	manifests, err := os.ReadFile(filepath.Join(dir, "ingresses.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := os.ReadFile(filepath.Join(dir, "nginx.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, cfg := testConfiguration(t, string(manifests))
+	return cfg, string(conf)
+}
+
+func TestConfigDriftEnforceRegex(t *testing.T) {
+	cfg, conf := driftFixture(t, "enforce-regex")
+
+	findings, err := configDrift(conf, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(findings) != 0 {
+		t.Errorf("expected no drift between the Ingresses and their nginx.conf, got %v", findings)
+	}
+
+	// a location missing from nginx.conf is reported
+	start := strings.Index(conf, `location ~* "^/healthz" {`)
+	end := start + strings.Index(conf[start:], "\n\t\t}\n") + len("\n\t\t}\n")
+	findings, err = configDrift(conf[:start]+conf[end:], cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(findings) != 1 || findings[0].Severity != SeverityError || !strings.Contains(findings[0].Message, "~* ^/healthz") {
+		t.Errorf("expected the missing location to be reported, got %v", findings)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"reflect"
+
+	"k8s.io/klog/v2"
+)
+
+// counters of the updates of the cluster state
+var (
+	dynamicUpdatesTotal  = expvar.NewInt("dynamic_updates_total")
+	fullValidationsTotal = expvar.NewInt("full_validations_total")
+)
+
+// withoutEndpoints returns a copy of the configuration without the endpoints
+// of its backends and stream services, which the ingress controller applies
+// through its Lua endpoint instead of reloading nginx
+func withoutEndpoints(cfg *Configuration) Configuration {
+	c := *cfg
+	c.Backends = make([]*Backend, 0, len(cfg.Backends))
+	for _, b := range cfg.Backends {
+		backend := *b
+		backend.Endpoints = nil
+		c.Backends = append(c.Backends, &backend)
+	}
+
+	c.TCPEndpoints = clearL4ServiceEndpoints(cfg.TCPEndpoints)
+	c.UDPEndpoints = clearL4ServiceEndpoints(cfg.UDPEndpoints)
+	return c
+}
+
+func clearL4ServiceEndpoints(services []L4Service) []L4Service {
+	cleared := make([]L4Service, 0, len(services))
+	for _, svc := range services {
+		svc.Endpoints = nil
+		cleared = append(cleared, svc)
+	}
+	return cleared
+}
+
+// IsDynamicConfigurationEnough returns true if the configuration only differs
+// from the running one in the endpoints of the backends, so it can be applied
+// without being validated again
+func (n *NGINXController) IsDynamicConfigurationEnough(pcfg *Configuration) bool {
+	if n.runningConfig == nil {
+		return false
+	}
+
+	running, err := json.Marshal(withoutEndpoints(n.runningConfig))
+	if err != nil {
+		return false
+	}
+	candidate, err := json.Marshal(withoutEndpoints(pcfg))
+	if err != nil {
+		return false
+	}
+	return string(running) == string(candidate)
+}
+
+// changedBackends returns the names of the backends whose endpoints differ
+// between the two configurations
+func changedBackends(running, pcfg *Configuration) []string {
+	endpoints := map[string][]Endpoint{}
+	for _, b := range running.Backends {
+		endpoints[b.Name] = b.Endpoints
+	}
+
+	changed := []string{}
+	for _, b := range pcfg.Backends {
+		if !reflect.DeepEqual(endpoints[b.Name], b.Endpoints) {
+			changed = append(changed, b.Name)
+		}
+	}
+	return changed
+}
+
+// ConfigureDynamically applies a configuration differing from the running one
+// only in the endpoints of its backends. The validation rules do not look at
+// the endpoints, so the previous validation result still holds.
+func (n *NGINXController) ConfigureDynamically(pcfg *Configuration) error {
+	if !n.IsDynamicConfigurationEnough(pcfg) {
+		return errors.New("the configuration changed in more than the endpoints of the backends")
+	}
+
+	changed := changedBackends(n.runningConfig, pcfg)
+	n.runningConfig = pcfg
+	dynamicUpdatesTotal.Add(1)
+	if len(changed) > 0 {
+		klog.V(2).Infof("Dynamically updated the endpoints of backends %v", changed)
+	}
+	return nil
+}
+
+// syncConfiguration builds the configuration from the store and validates it
+// again, unless only the endpoints of the backends changed since the last
+// validation
+func (n *NGINXController) syncConfiguration(s *memoryStore) {
+	version := s.objectsVersion()
+	_, _, pcfg := n.getConfiguration(s.ListIngresses())
+
+	if version == n.runningObjects && n.ConfigureDynamically(pcfg) == nil {
+		return
+	}
+
+	klog.V(2).Info("Configuration changed, validating the cluster state")
+	n.preValidate()
+	n.runningConfig = pcfg
+	n.runningObjects = version
+	fullValidationsTotal.Add(1)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsDynamicConfigurationEnough(t *testing.T) {
+	running := &Configuration{
+		Backends: []*Backend{
+			{Name: "default-web-80", Endpoints: []Endpoint{{Address: "10.0.0.1", Port: "8080"}}},
+			{Name: "default-api-80", Endpoints: []Endpoint{{Address: "10.0.0.2", Port: "8080"}}},
+		},
+	}
+	n := &NGINXController{}
+	if n.IsDynamicConfigurationEnough(running) {
+		t.Errorf("expected a full validation without running configuration")
+	}
+	n.runningConfig = running
+
+	endpoints := &Configuration{
+		Backends: []*Backend{
+			{Name: "default-web-80", Endpoints: []Endpoint{{Address: "10.0.0.1", Port: "8080"}, {Address: "10.0.0.3", Port: "8080"}}},
+			{Name: "default-api-80", Endpoints: []Endpoint{{Address: "10.0.0.2", Port: "8080"}}},
+		},
+	}
+	if !n.IsDynamicConfigurationEnough(endpoints) {
+		t.Errorf("expected a change of the endpoints to be applied dynamically")
+	}
+	if changed := changedBackends(running, endpoints); !reflect.DeepEqual(changed, []string{"default-web-80"}) {
+		t.Errorf("expected default-web-80 to change, got %v", changed)
+	}
+
+	balancing := &Configuration{
+		Backends: []*Backend{
+			{Name: "default-web-80", LoadBalancing: "ewma", Endpoints: []Endpoint{{Address: "10.0.0.1", Port: "8080"}}},
+			{Name: "default-api-80", Endpoints: []Endpoint{{Address: "10.0.0.2", Port: "8080"}}},
+		},
+	}
+	if n.IsDynamicConfigurationEnough(balancing) {
+		t.Errorf("expected a change of the backends to need a full validation")
+	}
+	if err := n.ConfigureDynamically(balancing); err == nil {
+		t.Errorf("expected the configuration not to be applied dynamically")
+	}
+}
+
+func TestSyncConfiguration(t *testing.T) {
+	n := newTestController(t, storeManifests)
+	s := n.store.(*memoryStore)
+	full, dynamic := fullValidationsTotal.Value(), dynamicUpdatesTotal.Value()
+	expect := func(step string, fullValidations, dynamicUpdates int64) {
+		t.Helper()
+		if got := fullValidationsTotal.Value() - full; got != fullValidations {
+			t.Errorf("%v: expected %d full validations, got %d", step, fullValidations, got)
+		}
+		if got := dynamicUpdatesTotal.Value() - dynamic; got != dynamicUpdates {
+			t.Errorf("%v: expected %d dynamic updates, got %d", step, dynamicUpdates, got)
+		}
+	}
+
+	n.syncConfiguration(s)
+	expect("first sync", 1, 0)
+
+	err := s.LoadManifest(strings.NewReader(`
+apiVersion: discovery.k8s.io/v1
+kind: EndpointSlice
+metadata:
+  name: web-2
+  namespace: default
+  labels:
+    kubernetes.io/service-name: web
+addressType: IPv4
+endpoints:
+- addresses: [10.0.0.2]
+ports:
+- name: http
+  port: 8080
+  protocol: TCP
+`), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.syncConfiguration(s)
+	expect("new endpoint", 1, 1)
+	for _, b := range n.runningConfig.Backends {
+		if b.Name == "default-web-80" && len(b.Endpoints) != 2 {
+			t.Errorf("expected the running configuration to have the new endpoint, got %v", b.Endpoints)
+		}
+	}
+
+	if err := s.Add(&apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}}); err != nil {
+		t.Fatal(err)
+	}
+	n.syncConfiguration(s)
+	expect("new Service", 2, 1)
+}
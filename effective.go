@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// sources of the values of the effective configuration
+const (
+	sourceGlobal  = "global"
+	sourceServer  = "server"
+	sourceIngress = "ingress"
+	sourceDefault = "default"
+)
+
+// EffectiveSetting is the final value of a setting and where it comes from
+type EffectiveSetting struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// Source is global when the value comes from the ConfigMap, server or
+	// ingress when it was set by an annotation and default otherwise
+	Source string `json:"source"`
+}
+
+// EffectiveLocation is the resolved configuration of a location
+type EffectiveLocation struct {
+	Path     string             `json:"path"`
+	PathType string             `json:"pathType"`
+	Ingress  string             `json:"ingress,omitempty"`
+	Backend  string             `json:"backend"`
+	Settings []EffectiveSetting `json:"settings"`
+}
+
+// EffectiveHostConfiguration is the resolved configuration of a host, after
+// merging the global settings, the server settings and the annotations of
+// each location
+type EffectiveHostConfiguration struct {
+	Hostname  string              `json:"hostname"`
+	Aliases   []string            `json:"aliases,omitempty"`
+	Server    []EffectiveSetting  `json:"server"`
+	Locations []EffectiveLocation `json:"locations"`
+}
+
+// effectiveHostConfiguration returns the resolved configuration of the server
+// matching host, or one of its aliases
+func (n *NGINXController) effectiveHostConfiguration(cfg *Configuration, host string) (*EffectiveHostConfiguration, error) {
+	server := findServer(cfg, host)
+	if server == nil {
+		return nil, fmt.Errorf("host %q is not defined by any Ingress", host)
+	}
+
+	global := n.store.GetBackendConfiguration()
+	ehc := &EffectiveHostConfiguration{
+		Hostname: server.Hostname,
+		Aliases:  server.Aliases,
+	}
+
+	certificate := "default (fake) certificate"
+	if server.SSLCert != nil {
+		certificate = fmt.Sprintf("%v/%v", server.SSLCert.Namespace, server.SSLCert.Name)
+	}
+	ehc.Server = []EffectiveSetting{
+		{Key: "ssl-certificate", Value: certificate, Source: sourceServer},
+		overridable("ssl-ciphers", server.SSLCiphers, global.SSLCiphers, sourceServer),
+		overridable("ssl-prefer-server-ciphers", server.SSLPreferServerCiphers,
+			boolToOnOff(global.SSLPreferServerCiphers), sourceServer),
+		{Key: "ssl-protocols", Value: global.SSLProtocols, Source: sourceGlobal},
+		{Key: "hsts", Value: strconv.FormatBool(global.HSTS), Source: sourceGlobal},
+		{Key: "hsts-max-age", Value: global.HSTSMaxAge, Source: sourceGlobal},
+		annotated("ssl-passthrough", strconv.FormatBool(server.SSLPassthrough), server.SSLPassthrough),
+		annotated("from-to-www-redirect", strconv.FormatBool(server.RedirectFromToWWW), server.RedirectFromToWWW),
+		annotated("auth-tls-secret", server.CertificateAuth.Secret, server.CertificateAuth.Secret != ""),
+		annotated("auth-tls-verify-client", server.CertificateAuth.VerifyClient, server.CertificateAuth.VerifyClient != ""),
+		annotated("proxy-ssl-secret", server.ProxySSL.Secret, server.ProxySSL.Secret != ""),
+		annotated("server-snippet", strconv.FormatBool(server.ServerSnippet != ""), server.ServerSnippet != ""),
+	}
+
+	for _, loc := range server.Locations {
+		el := EffectiveLocation{
+			Path:    loc.Path,
+			Backend: loc.Backend,
+		}
+		if loc.PathType != nil {
+			el.PathType = string(*loc.PathType)
+		}
+		if loc.Ingress != nil {
+			el.Ingress = k8s.MetaNamespaceKey(loc.Ingress)
+		}
+
+		backendProtocol := loc.BackendProtocol
+		if backendProtocol == "" {
+			backendProtocol = "HTTP"
+		}
+
+		el.Settings = []EffectiveSetting{
+			annotated("backend-protocol", backendProtocol, loc.BackendProtocol != "" && loc.BackendProtocol != "HTTP"),
+			overridable("proxy-body-size", loc.Proxy.BodySize, global.ProxyBodySize, sourceIngress),
+			overridable("proxy-connect-timeout", strconv.Itoa(loc.Proxy.ConnectTimeout), strconv.Itoa(global.ProxyConnectTimeout), sourceIngress),
+			overridable("proxy-send-timeout", strconv.Itoa(loc.Proxy.SendTimeout), strconv.Itoa(global.ProxySendTimeout), sourceIngress),
+			overridable("proxy-read-timeout", strconv.Itoa(loc.Proxy.ReadTimeout), strconv.Itoa(global.ProxyReadTimeout), sourceIngress),
+			overridable("proxy-buffer-size", loc.Proxy.BufferSize, global.ProxyBufferSize, sourceIngress),
+			overridable("proxy-buffering", loc.Proxy.ProxyBuffering, global.ProxyBuffering, sourceIngress),
+			overridable("proxy-request-buffering", loc.Proxy.RequestBuffering, global.ProxyRequestBuffering, sourceIngress),
+			overridable("proxy-http-version", loc.Proxy.ProxyHTTPVersion, global.ProxyHTTPVersion, sourceIngress),
+			overridable("proxy-next-upstream", loc.Proxy.NextUpstream, global.ProxyNextUpstream, sourceIngress),
+			overridable("client-body-buffer-size", loc.ClientBodyBufferSize, global.ClientBodyBufferSize, sourceIngress),
+			overridable("ssl-redirect", strconv.FormatBool(loc.Rewrite.SSLRedirect), strconv.FormatBool(global.SSLRedirect), sourceIngress),
+			overridable("force-ssl-redirect", strconv.FormatBool(loc.Rewrite.ForceSSLRedirect), strconv.FormatBool(global.ForceSSLRedirect), sourceIngress),
+			annotated("rewrite-target", loc.Rewrite.Target, loc.Rewrite.Target != ""),
+			annotated("use-regex", strconv.FormatBool(loc.Rewrite.UseRegex), loc.Rewrite.UseRegex),
+			annotated("upstream-vhost", loc.UpstreamVhost, loc.UpstreamVhost != ""),
+			overridable("allowlist-source-range", strings.Join(loc.Allowlist.CIDR, ","), strings.Join(global.WhitelistSourceRange, ","), sourceIngress),
+			overridable("denylist-source-range", strings.Join(loc.Denylist.CIDR, ","), strings.Join(global.DenylistSourceRange, ","), sourceIngress),
+			annotated("limit-connections", strconv.Itoa(loc.RateLimit.Connections.Limit), loc.RateLimit.Connections.Limit > 0),
+			annotated("limit-rps", strconv.Itoa(loc.RateLimit.RPS.Limit), loc.RateLimit.RPS.Limit > 0),
+			annotated("limit-rpm", strconv.Itoa(loc.RateLimit.RPM.Limit), loc.RateLimit.RPM.Limit > 0),
+			annotated("enable-cors", strconv.FormatBool(loc.CorsConfig.CorsEnabled), loc.CorsConfig.CorsEnabled),
+			annotated("auth-type", loc.BasicDigestAuth.Type, loc.BasicDigestAuth.Secured),
+			annotated("auth-url", loc.ExternalAuth.URL, loc.ExternalAuth.URL != ""),
+			annotated("enable-global-auth", strconv.FormatBool(loc.EnableGlobalAuth), !loc.EnableGlobalAuth),
+			annotated("permanent-redirect", loc.Redirect.URL, loc.Redirect.URL != ""),
+			annotated("custom-headers", formatHeaders(loc.CustomHeaders.Headers), len(loc.CustomHeaders.Headers) > 0),
+			annotated("enable-access-log", strconv.FormatBool(loc.Logs.Access), !loc.Logs.Access),
+			annotated("configuration-snippet", strconv.FormatBool(loc.ConfigurationSnippet != ""), loc.ConfigurationSnippet != ""),
+		}
+
+		ehc.Locations = append(ehc.Locations, el)
+	}
+
+	return ehc, nil
+}
+
+// findServer returns the server whose hostname or alias matches host
+func findServer(cfg *Configuration, host string) *Server {
+	for _, server := range cfg.Servers {
+		if server.Hostname == host {
+			return server
+		}
+	}
+	for _, server := range cfg.Servers {
+		for _, alias := range server.Aliases {
+			if alias == host {
+				return server
+			}
+		}
+	}
+	return nil
+}
+
+// overridable returns a setting with a global default that can be
+// overridden by an annotation. Empty values mean the global value is used.
+func overridable(key, value, global, source string) EffectiveSetting {
+	if value == "" || value == global {
+		return EffectiveSetting{Key: key, Value: global, Source: sourceGlobal}
+	}
+	return EffectiveSetting{Key: key, Value: value, Source: source}
+}
+
+// annotated returns a setting without global default
+func annotated(key, value string, set bool) EffectiveSetting {
+	if set {
+		return EffectiveSetting{Key: key, Value: value, Source: sourceIngress}
+	}
+	return EffectiveSetting{Key: key, Value: value, Source: sourceDefault}
+}
+
+func boolToOnOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+func formatHeaders(headers map[string]string) string {
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%v: %v", k, headers[k]))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// runEffective prints the effective configuration of one host
+func runEffective(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("effective", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	cfg := &NginxConfiguration{}
+	addConfigurationFlags(fs, cfg)
+
+	in := &validateInput{}
+	addInputFlags(fs, in, cfg)
+	host := fs.String("host", "", "Host to print the effective configuration of.")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitInternal
+	}
+
+	if *host == "" {
+		fmt.Fprintln(stderr, "--host is required")
+		return exitInternal
+	}
+	if err := in.check(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitInternal
+	}
+
+	s, err := in.load(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitInternal
+	}
+
+	n := newOfflineController(cfg, s)
+	_, _, configuration := n.getConfiguration(s.ListIngresses())
+
+	ehc, err := n.effectiveHostConfiguration(configuration, *host)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitErrors
+	}
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(ehc); err != nil {
+		fmt.Fprintf(stderr, "error writing configuration: %v\n", err)
+		return exitInternal
+	}
+	return exitOK
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+)
+
+const effectiveManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+data:
+  proxy-read-timeout: "120"
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/proxy-body-size: 16m
+    nginx.ingress.kubernetes.io/proxy-read-timeout: "120"
+    nginx.ingress.kubernetes.io/rewrite-target: /
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /app
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`
+
+func TestEffectiveHostConfiguration(t *testing.T) {
+	n, _, cfg := testConfiguration(t, effectiveManifests)
+
+	if _, err := n.effectiveHostConfiguration(cfg, "missing.example.com"); err == nil {
+		t.Errorf("expected an error for a host without Ingress")
+	}
+
+	ehc, err := n.effectiveHostConfiguration(cfg, "web.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var location *EffectiveLocation
+	for i := range ehc.Locations {
+		if ehc.Locations[i].Path == "/app" {
+			location = &ehc.Locations[i]
+		}
+	}
+	if location == nil {
+		t.Fatalf("expected the /app location, got %v", ehc.Locations)
+	}
+	if location.Ingress != "default/web" {
+		t.Errorf("expected the location of Ingress default/web, got %q", location.Ingress)
+	}
+
+	settings := map[string]EffectiveSetting{}
+	for _, s := range location.Settings {
+		settings[s.Key] = s
+	}
+
+	tests := []EffectiveSetting{
+		{Key: "proxy-body-size", Value: "16m", Source: sourceIngress},
+		// same value as the ConfigMap
+		{Key: "proxy-read-timeout", Value: "120", Source: sourceGlobal},
+		{Key: "proxy-send-timeout", Value: "60", Source: sourceGlobal},
+		{Key: "rewrite-target", Value: "/", Source: sourceIngress},
+		{Key: "upstream-vhost", Value: "", Source: sourceDefault},
+	}
+	for _, expected := range tests {
+		if got := settings[expected.Key]; got != expected {
+			t.Errorf("expected %v, got %v", expected, got)
+		}
+	}
+}
+
+func TestOverridable(t *testing.T) {
+	tests := []struct {
+		value    string
+		global   string
+		expected EffectiveSetting
+	}{
+		{"", "1m", EffectiveSetting{Key: "k", Value: "1m", Source: sourceGlobal}},
+		{"1m", "1m", EffectiveSetting{Key: "k", Value: "1m", Source: sourceGlobal}},
+		{"8m", "1m", EffectiveSetting{Key: "k", Value: "8m", Source: sourceServer}},
+	}
+
+	for _, tc := range tests {
+		if got := overridable("k", tc.value, tc.global, sourceServer); got != tc.expected {
+			t.Errorf("value %q, global %q: expected %v, got %v", tc.value, tc.global, tc.expected, got)
+		}
+	}
+}
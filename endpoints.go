@@ -2,6 +2,18 @@ package main
 
 import (
 	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
 )
 
 // getEndpointsFromSlices returns a list of Endpoint structs for a given service/target port combination.
@@ -0,0 +1,73 @@
+package main
+
+import (
+	admissionv1 "k8s.io/api/admission/v1"
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+)
+
+// eventComponent is the source of the Events emitted by the validator
+const eventComponent = "nginx-config-validator"
+
+// reasons of the Events emitted for the findings
+const (
+	reasonValidationFailed  = "ValidationFailed"
+	reasonValidationWarning = "ValidationWarning"
+)
+
+// newEventRecorder returns the recorder of the Events of the Ingresses.
+// With disableSyncEvents the Events are only logged.
+func newEventRecorder(client clientset.Interface, disableSyncEvents bool) record.EventRecorder {
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(klog.Infof)
+	if !disableSyncEvents {
+		eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+			Interface: client.CoreV1().Events(""),
+		})
+	}
+
+	return eventBroadcaster.NewRecorder(scheme.Scheme, apiv1.EventSource{Component: eventComponent})
+}
+
+// recordAdmissionEvents emits the Events of the validation of an admission
+// request. Dry runs are not persisted and a rejected CREATE leaves no object,
+// so neither gets Events.
+func (n *NGINXController) recordAdmissionEvents(req *admissionv1.AdmissionRequest, ing *networking.Ingress, findings []Finding, err error) {
+	if req.DryRun != nil && *req.DryRun {
+		return
+	}
+	if err != nil && req.Operation == admissionv1.Create {
+		return
+	}
+	n.recordFindingEvents(ing, findings)
+}
+
+// recordFindingEvents emits a Warning Event on the Ingress for each error
+// and warning finding, visible with kubectl describe ingress
+func (n *NGINXController) recordFindingEvents(ing *networking.Ingress, findings []Finding) {
+	if n.recorder == nil || n.cfg.DisableSyncEvents {
+		return
+	}
+
+	for _, f := range findings {
+		reason := reasonValidationWarning
+		switch f.Severity {
+		case SeverityError:
+			reason = reasonValidationFailed
+		case SeverityWarning:
+		default:
+			continue
+		}
+
+		if f.Host != "" {
+			n.recorder.Eventf(ing, apiv1.EventTypeWarning, reason, "%v (%v): %v", f.Rule, f.Host, f.Message)
+		} else {
+			n.recorder.Eventf(ing, apiv1.EventTypeWarning, reason, "%v: %v", f.Rule, f.Message)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// recordedEvents returns the Events of the fake recorder
+func recordedEvents(recorder *record.FakeRecorder) []string {
+	events := []string{}
+	for {
+		select {
+		case e := <-recorder.Events:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestRecordFindingEvents(t *testing.T) {
+	findings := []Finding{
+		{Rule: "h2c-nginx-version", Severity: SeverityError, Message: "h2c requires nginx 1.13.10"},
+		{Rule: "cors-permissive", Severity: SeverityWarning, Host: "web.example.com", Message: "any origin"},
+		{Rule: "security-headers", Severity: SeverityInfo, Message: "compliant"},
+	}
+	ing := &networking.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+
+	tests := []struct {
+		name     string
+		disabled bool
+		expected []string
+	}{
+		{
+			name: "errors and warnings",
+			expected: []string{
+				"Warning ValidationFailed h2c-nginx-version: h2c requires nginx 1.13.10",
+				"Warning ValidationWarning cors-permissive (web.example.com): any origin",
+			},
+		},
+		{
+			name:     "disabled",
+			disabled: true,
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n := newTestController(t, "")
+			recorder := record.NewFakeRecorder(10)
+			n.recorder = recorder
+			n.cfg.DisableSyncEvents = tc.disabled
+
+			n.recordFindingEvents(ing, findings)
+			if got := recordedEvents(recorder); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+
+	// the offline commands have no recorder
+	newTestController(t, "").recordFindingEvents(ing, findings)
+}
+
+func TestCheckIngressEvents(t *testing.T) {
+	n := newTestController(t, `
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: default
+`)
+	n.cfg.NginxVersion = "1.13.9"
+	recorder := record.NewFakeRecorder(10)
+	n.recorder = recorder
+
+	ing := &networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "web",
+			Namespace:   "default",
+			Annotations: map[string]string{"nginx.ingress.kubernetes.io/backend-protocol": "H2C"},
+		},
+		Spec: networking.IngressSpec{
+			Rules: []networking.IngressRule{{
+				Host: "web.example.com",
+				IngressRuleValue: networking.IngressRuleValue{HTTP: &networking.HTTPIngressRuleValue{
+					Paths: []networking.HTTPIngressPath{{
+						Path:     "/",
+						PathType: &pathTypePrefix,
+						Backend: networking.IngressBackend{Service: &networking.IngressServiceBackend{
+							Name: "web", Port: networking.ServiceBackendPort{Number: 80},
+						}},
+					}},
+				}},
+			}},
+		},
+	}
+	findings, err := n.CheckIngress(ing)
+	if err == nil {
+		t.Fatal("expected the Ingress to be rejected")
+	}
+	// the Events depend on the admission request
+	if events := recordedEvents(recorder); len(events) != 0 {
+		t.Errorf("expected no Event from CheckIngress, got %q", events)
+	}
+
+	n.recordAdmissionEvents(&admissionv1.AdmissionRequest{Operation: admissionv1.Update}, ing, findings, err)
+	events := recordedEvents(recorder)
+	if len(events) != 1 || !strings.HasPrefix(events[0], "Warning ValidationFailed h2c-nginx-version") {
+		t.Errorf("expected a ValidationFailed Event, got %q", events)
+	}
+}
+
+func TestRecordAdmissionEvents(t *testing.T) {
+	dryRun := true
+	findings := []Finding{
+		{Rule: "rule", Severity: SeverityError, Message: "error"},
+		{Rule: "rule", Severity: SeverityWarning, Message: "warning"},
+		{Rule: "rule", Severity: SeverityInfo, Message: "info"},
+	}
+
+	tests := []struct {
+		name   string
+		req    *admissionv1.AdmissionRequest
+		err    error
+		events int
+	}{
+		{
+			name:   "accepted create",
+			req:    &admissionv1.AdmissionRequest{Operation: admissionv1.Create},
+			events: 2,
+		},
+		{
+			name:   "rejected update",
+			req:    &admissionv1.AdmissionRequest{Operation: admissionv1.Update},
+			err:    errors.New("rejected"),
+			events: 2,
+		},
+		{
+			name: "rejected create",
+			req:  &admissionv1.AdmissionRequest{Operation: admissionv1.Create},
+			err:  errors.New("rejected"),
+		},
+		{
+			name: "dry run",
+			req:  &admissionv1.AdmissionRequest{Operation: admissionv1.Update, DryRun: &dryRun},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			n := newOfflineController(&NginxConfiguration{}, newMemoryStore(""))
+			n.recorder = recorder
+
+			ing := &networking.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"}}
+			n.recordAdmissionEvents(tc.req, ing, findings, tc.err)
+
+			if len(recorder.Events) != tc.events {
+				t.Errorf("expected %d events, got %d", tc.events, len(recorder.Events))
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// nginxConfDirective matches the name of the directive of a line
+var nginxConfDirective = regexp.MustCompile(`^\s*([a-z_0-9]+)\s`)
+
+// nginxConfPosition is what a line of a rendered nginx.conf belongs to
+type nginxConfPosition struct {
+	// server is the hostname of the enclosing server, empty in the http block
+	server string
+	// location is the enclosing location, as written in the file
+	location string
+	// directive is the name of the directive of the line
+	directive string
+}
+
+// locateNginxConfLine returns the server, location and directive of a line
+// of a nginx.conf rendered by ingress-nginx
+func locateNginxConfLine(r io.Reader, line int) (nginxConfPosition, error) {
+	pos := nginxConfPosition{}
+	// depth is the nesting of the braces inside the current location
+	depth := 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for i := 1; scanner.Scan(); i++ {
+		text := scanner.Text()
+
+		if m := nginxConfServerStart.FindStringSubmatch(text); m != nil {
+			pos.server, pos.location, depth = m[1], "", 0
+		} else if nginxConfServerEnd.MatchString(text) {
+			pos.server, pos.location, depth = "", "", 0
+		} else if m := nginxConfLocation.FindStringSubmatch(text); m != nil && pos.location == "" {
+			pos.location = m[2]
+			if m[1] != "" {
+				pos.location = m[1] + " " + m[2]
+			}
+			depth = 0
+		}
+
+		if i == line {
+			if m := nginxConfDirective.FindStringSubmatch(text); m != nil {
+				pos.directive = m[1]
+			}
+			return pos, nil
+		}
+
+		if pos.location != "" {
+			depth += strings.Count(text, "{") - strings.Count(text, "}")
+			if depth <= 0 {
+				pos.location = ""
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return pos, err
+	}
+	return pos, fmt.Errorf("line %d is past the end of the file", line)
+}
+
+// matchLocation returns the location nginx uses for the path: the exact
+// location, else the first regular expression matching, else the longest
+// prefix
+func matchLocation(server *Server, path string) *Location {
+	var prefix *Location
+	var regexes []*Location
+	enforceRegex := enforceRegexModifier(server.Locations)
+	for _, loc := range server.Locations {
+		key := conformanceLocationKey(loc, enforceRegex)
+		switch {
+		case strings.HasPrefix(key, "= "):
+			if loc.Path == path {
+				return loc
+			}
+		case strings.HasPrefix(key, "~"):
+			regexes = append(regexes, loc)
+		case strings.HasPrefix(path, loc.Path):
+			if prefix == nil || len(loc.Path) > len(prefix.Path) {
+				prefix = loc
+			}
+		}
+	}
+
+	for _, loc := range regexes {
+		re, err := regexp.Compile("(?i)^" + loc.Path)
+		if err == nil && re.MatchString(path) {
+			return loc
+		}
+	}
+	return prefix
+}
+
+// explanation traces the settings of a host, or of one of its locations,
+// back to the Ingresses, annotations and ConfigMap entries producing them
+type explanation struct {
+	n         *NGINXController
+	server    *Server
+	ehc       *EffectiveHostConfiguration
+	location  *EffectiveLocation
+	directive string
+}
+
+// provenance describes where a setting comes from
+func (e *explanation) provenance(s EffectiveSetting, ingress string) string {
+	switch s.Source {
+	case sourceIngress:
+		return fmt.Sprintf("annotation %v of Ingress %v", parser.GetAnnotationWithPrefix(s.Key), ingress)
+	case sourceServer:
+		return fmt.Sprintf("annotation %v of Ingress %v", parser.GetAnnotationWithPrefix(s.Key), serverIngress(e.server))
+	case sourceGlobal:
+		if cm, err := e.n.store.GetConfigMap(e.n.cfg.ConfigMapName); err == nil {
+			if _, ok := cm.Data[s.Key]; ok {
+				return fmt.Sprintf("key %v of ConfigMap %v", s.Key, e.n.cfg.ConfigMapName)
+			}
+		}
+		return "default of the ingress controller"
+	default:
+		return "default"
+	}
+}
+
+func (e *explanation) write(w io.Writer) {
+	ingresses := map[string]bool{}
+	for _, loc := range e.server.Locations {
+		if loc.Ingress != nil {
+			ingresses[k8s.MetaNamespaceKey(loc.Ingress)] = true
+		}
+	}
+	fmt.Fprintf(w, "server %v: defined by Ingresses %v\n", e.ehc.Hostname, strings.Join(sortedSet(ingresses), ", "))
+
+	settings := e.ehc.Server
+	ingress := serverIngress(e.server)
+	if e.location != nil {
+		fmt.Fprintf(w, "location %v (%v): Ingress %v, backend %v\n",
+			e.location.Path, e.location.PathType, e.location.Ingress, e.location.Backend)
+		settings = e.location.Settings
+		ingress = e.location.Ingress
+	}
+
+	if e.directive != "" {
+		key := strings.ReplaceAll(e.directive, "_", "-")
+		for _, s := range append(e.ehc.Server, settings...) {
+			if s.Key == key {
+				fmt.Fprintf(w, "%v %v: %v\n", e.directive, s.Value, e.provenance(s, ingress))
+				return
+			}
+		}
+		fmt.Fprintf(w, "%v: generated by the template of the ingress controller\n", e.directive)
+		return
+	}
+
+	for _, s := range settings {
+		if s.Source == sourceDefault {
+			continue
+		}
+		fmt.Fprintf(w, "  %v = %v: %v\n", s.Key, s.Value, e.provenance(s, ingress))
+	}
+}
+
+// runExplain prints the Ingresses, annotations and ConfigMap entries that
+// produce the configuration of a host and path, or of a line of nginx.conf
+func runExplain(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	cfg := &NginxConfiguration{}
+	addConfigurationFlags(fs, cfg)
+
+	in := &validateInput{}
+	addInputFlags(fs, in, cfg)
+	host := fs.String("host", "", "Host to explain.")
+	path := fs.String("path", "", "Path of the request to explain, the location nginx would use is explained.")
+	nginxConf := fs.String("nginx-conf", "", "nginx.conf rendered by the ingress controller, used with --line.")
+	line := fs.Int("line", 0, "Line of --nginx-conf to explain.")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitInternal
+	}
+
+	if (*host == "") == (*nginxConf == "") {
+		fmt.Fprintln(stderr, "exactly one of --host or --nginx-conf is required")
+		return exitInternal
+	}
+	if err := in.check(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitInternal
+	}
+
+	e := &explanation{}
+	locationKey := ""
+	if *nginxConf != "" {
+		f, err := os.Open(*nginxConf)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return exitInternal
+		}
+		pos, err := locateNginxConfLine(f, *line)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return exitInternal
+		}
+		if pos.server == "" {
+			fmt.Fprintf(stdout, "line %d is in the http block, generated from the ConfigMap %v and the defaults of the ingress controller\n",
+				*line, cfg.ConfigMapName)
+			return exitOK
+		}
+		*host, locationKey, e.directive = pos.server, pos.location, pos.directive
+	}
+
+	s, err := in.load(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitInternal
+	}
+
+	e.n = newOfflineController(cfg, s)
+	_, _, configuration := e.n.getConfiguration(s.ListIngresses())
+	if err := e.locate(configuration, *host, locationKey, *path); err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitErrors
+	}
+
+	e.write(stdout)
+	return exitOK
+}
+
+// locate finds the server of the host and the location of the key, as written
+// in nginx.conf, or the location nginx uses for the path
+func (e *explanation) locate(configuration *Configuration, host, locationKey, path string) error {
+	e.server = findServer(configuration, host)
+	if e.server == nil {
+		return fmt.Errorf("host %q is not defined by any Ingress", host)
+	}
+	var err error
+	if e.ehc, err = e.n.effectiveHostConfiguration(configuration, host); err != nil {
+		return err
+	}
+
+	var loc *Location
+	switch {
+	case locationKey != "":
+		enforceRegex := enforceRegexModifier(e.server.Locations)
+		for _, l := range e.server.Locations {
+			if conformanceLocationKey(l, enforceRegex) == locationKey {
+				loc = l
+				break
+			}
+		}
+	case path != "":
+		loc = matchLocation(e.server, path)
+	}
+	if (locationKey != "" || path != "") && loc == nil {
+		return fmt.Errorf("no location of %v matches", host)
+	}
+
+	if loc != nil {
+		for i, l := range e.server.Locations {
+			if l == loc {
+				e.location = &e.ehc.Locations[i]
+			}
+		}
+	}
+	return nil
+}
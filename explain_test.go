@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const explainNginxConf = `http {
+	proxy_read_timeout 60s;
+
+	## start server web.example.com
+	server {
+		server_name web.example.com ;
+
+		location ~* "^/app" {
+			set $service_name "web";
+
+			if ($scheme = http) {
+				return 308 https://$host$request_uri;
+			}
+
+			proxy_read_timeout 120s;
+		}
+
+		location = /healthz {
+			access_log off;
+		}
+
+		listen 80;
+	}
+	## end server web.example.com
+}
+`
+
+func TestLocateNginxConfLine(t *testing.T) {
+	tests := []struct {
+		line     int
+		expected nginxConfPosition
+	}{
+		{2, nginxConfPosition{directive: "proxy_read_timeout"}},
+		{6, nginxConfPosition{server: "web.example.com", directive: "server_name"}},
+		{9, nginxConfPosition{server: "web.example.com", location: "~* ^/app", directive: "set"}},
+		// the nested if block does not end the location
+		{15, nginxConfPosition{server: "web.example.com", location: "~* ^/app", directive: "proxy_read_timeout"}},
+		{19, nginxConfPosition{server: "web.example.com", location: "= /healthz", directive: "access_log"}},
+		{22, nginxConfPosition{server: "web.example.com", directive: "listen"}},
+	}
+
+	for _, tc := range tests {
+		pos, err := locateNginxConfLine(strings.NewReader(explainNginxConf), tc.line)
+		if err != nil {
+			t.Errorf("line %d: unexpected error: %v", tc.line, err)
+			continue
+		}
+		if pos != tc.expected {
+			t.Errorf("line %d: expected %+v, got %+v", tc.line, tc.expected, pos)
+		}
+	}
+
+	if _, err := locateNginxConfLine(strings.NewReader(explainNginxConf), 100); err == nil {
+		t.Errorf("expected an error for a line past the end of the file")
+	}
+}
+
+func TestRunExplain(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "ingress.yaml")
+	if err := os.WriteFile(manifest, []byte(effectiveManifests), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	nginxConf := filepath.Join(dir, "nginx.conf")
+	if err := os.WriteFile(nginxConf, []byte(explainNginxConf), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		exitCode int
+		stdout   []string
+		stderr   string
+	}{
+		{
+			name:     "no host",
+			args:     []string{"-f", manifest},
+			exitCode: exitInternal,
+			stderr:   "exactly one of --host or --nginx-conf is required",
+		},
+		{
+			name:     "unknown host",
+			args:     []string{"-f", manifest, "--host", "missing.example.com"},
+			exitCode: exitErrors,
+			stderr:   `host "missing.example.com" is not defined by any Ingress`,
+		},
+		{
+			name:     "path",
+			args:     []string{"-f", manifest, "--host", "web.example.com", "--path", "/app/users"},
+			exitCode: exitOK,
+			stdout: []string{
+				"server web.example.com: defined by Ingresses default/web",
+				"location /app (Prefix): Ingress default/web",
+				"proxy-body-size = 16m: annotation nginx.ingress.kubernetes.io/proxy-body-size of Ingress default/web",
+				"proxy-read-timeout = 120: key proxy-read-timeout of ConfigMap ingress-nginx/ingress-nginx-controller",
+				"proxy-send-timeout = 60: default of the ingress controller",
+			},
+		},
+		{
+			name:     "http block line",
+			args:     []string{"-f", manifest, "--nginx-conf", nginxConf, "--line", "2"},
+			exitCode: exitOK,
+			stdout:   []string{"line 2 is in the http block"},
+		},
+		{
+			name:     "location line",
+			args:     []string{"-f", manifest, "--nginx-conf", nginxConf, "--line", "15"},
+			exitCode: exitOK,
+			stdout:   []string{"proxy_read_timeout 120: key proxy-read-timeout of ConfigMap ingress-nginx/ingress-nginx-controller"},
+		},
+		{
+			name:     "location of the ingress controller",
+			args:     []string{"-f", manifest, "--nginx-conf", nginxConf, "--line", "19"},
+			exitCode: exitErrors,
+			stderr:   "no location of web.example.com matches",
+		},
+		{
+			name:     "template line",
+			args:     []string{"-f", manifest, "--nginx-conf", nginxConf, "--line", "9"},
+			exitCode: exitOK,
+			stdout:   []string{"set: generated by the template of the ingress controller"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := runCLI(append([]string{"explain"}, tc.args...), &stdout, &stderr); code != tc.exitCode {
+				t.Errorf("expected exit code %v, got %v (%v)", tc.exitCode, code, stderr.String())
+			}
+			for _, expected := range tc.stdout {
+				if !strings.Contains(stdout.String(), expected) {
+					t.Errorf("expected stdout to contain %q, got %q", expected, stdout.String())
+				}
+			}
+			if !strings.Contains(stderr.String(), tc.stderr) {
+				t.Errorf("expected stderr to contain %q, got %q", tc.stderr, stderr.String())
+			}
+		})
+	}
+}
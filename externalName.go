@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+const externalNameResolutionTimeout = 5 * time.Second
+
+// externalNameResolver returns a resolver that sends the queries to the
+// resolvers configured for nginx, falling back to the system configuration
+// when none is configured
+func (n *NGINXController) externalNameResolver() *net.Resolver {
+	if len(n.resolver) == 0 {
+		return net.DefaultResolver
+	}
+
+	servers := make([]string, 0, len(n.resolver))
+	for _, ip := range n.resolver {
+		servers = append(servers, net.JoinHostPort(ip.String(), strconv.Itoa(53)))
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			var err error
+			for _, server := range servers {
+				var conn net.Conn
+				conn, err = d.DialContext(ctx, network, server)
+				if err == nil {
+					return conn, nil
+				}
+			}
+			return nil, err
+		},
+	}
+}
+
+// resolveExternalName checks the name resolves using the nginx resolvers and
+// does not point to a loopback address
+func resolveExternalName(r *net.Resolver, name string) error {
+	if ip := net.ParseIP(name); ip != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), externalNameResolutionTimeout)
+	defer cancel()
+
+	addrs, err := r.LookupIPAddr(ctx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return fmt.Errorf("%v does not exist (NXDOMAIN)", name)
+		}
+		return fmt.Errorf("error resolving %v: %w", name, err)
+	}
+
+	for _, addr := range addrs {
+		if addr.IP.IsLoopback() {
+			return fmt.Errorf("%v resolves to loopback address %v", name, addr.IP)
+		}
+	}
+
+	return nil
+}
+
+// checkExternalNameResolution resolves the external name of every Service of
+// type ExternalName used as backend and reports the names nginx will not be
+// able to use
+func (n *NGINXController) checkExternalNameResolution(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	if !n.cfg.EnableExternalNameResolution {
+		return findings
+	}
+
+	r := n.externalNameResolver()
+	resolved := map[string]error{}
+
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			if loc.Service == nil || loc.Service.Spec.Type != apiv1.ServiceTypeExternalName {
+				continue
+			}
+
+			name := loc.Service.Spec.ExternalName
+			err, ok := resolved[name]
+			if !ok {
+				err = resolveExternalName(r, name)
+				resolved[name] = err
+			}
+			if err == nil {
+				continue
+			}
+
+			findings = append(findings, newLocationFinding("externalname-resolution", SeverityError, server, loc,
+				"Service %v/%v of type ExternalName used by location %q cannot be used: %v",
+				loc.Service.Namespace, loc.Service.Name, loc.Path, err))
+		}
+	}
+
+	return findings
+}
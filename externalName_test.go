@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResolveExternalName(t *testing.T) {
+	unreachable := &net.Resolver{
+		PreferGo: true,
+		Dial: func(context.Context, string, string) (net.Conn, error) {
+			return nil, errors.New("unreachable")
+		},
+	}
+
+	tests := []struct {
+		name     string
+		resolver *net.Resolver
+		err      string
+	}{
+		{name: "10.0.0.1", resolver: unreachable},
+		{name: "localhost", resolver: net.DefaultResolver, err: "resolves to loopback address"},
+		{name: "backend.invalid", resolver: unreachable, err: "error resolving backend.invalid"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := resolveExternalName(tc.resolver, tc.name)
+			if tc.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestCheckExternalNameResolution(t *testing.T) {
+	cfg := &Configuration{Servers: []*Server{{
+		Hostname: "app.example.com",
+		Locations: []*Location{{
+			Path: "/",
+			Service: &apiv1.Service{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "local"},
+				Spec:       apiv1.ServiceSpec{Type: apiv1.ServiceTypeExternalName, ExternalName: "localhost"},
+			},
+		}},
+	}}}
+
+	n := &NGINXController{cfg: &NginxConfiguration{}}
+	if findings := n.checkExternalNameResolution(nil, cfg); len(findings) != 0 {
+		t.Errorf("expected no findings when the resolution is disabled, got %v", findings)
+	}
+
+	n.cfg.EnableExternalNameResolution = true
+	findings := n.checkExternalNameResolution(nil, cfg)
+	if len(findings) != 1 || findings[0].Rule != "externalname-resolution" {
+		t.Errorf("expected one externalname-resolution finding, got %v", findings)
+	}
+}
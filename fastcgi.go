@@ -0,0 +1,143 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+)
+
+// fastCGIParamRegex matches the names of the FastCGI params, which are
+// written unquoted in the fastcgi_param directives
+var fastCGIParamRegex = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// fastCGIIgnoredAnnotations configure proxy_* directives, which nginx ignores
+// in the locations using fastcgi_pass
+var fastCGIIgnoredAnnotations = []string{
+	"connection-proxy-header",
+	"custom-headers",
+	"proxy-buffer-size",
+	"proxy-buffering",
+	"proxy-buffers-number",
+	"proxy-cookie-domain",
+	"proxy-cookie-path",
+	"proxy-http-version",
+	"proxy-redirect-from",
+	"proxy-redirect-to",
+	"proxy-request-buffering",
+	"proxy-ssl-secret",
+	"proxy-ssl-verify",
+	"upstream-vhost",
+	"x-forwarded-prefix",
+}
+
+// checkFastCGI validates the FastCGI configuration of the locations: the
+// ConfigMap of the params, the params themselves, the index and the
+// annotations with no effect on FastCGI backends
+func (n *NGINXController) checkFastCGI(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	checked := map[*Ingress]bool{}
+
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			if loc.Ingress == nil {
+				continue
+			}
+			anns := loc.Ingress.Annotations
+			fcgi := strings.EqualFold(loc.BackendProtocol, "FCGI")
+			configMap, hasConfigMap := anns[parser.GetAnnotationWithPrefix("fastcgi-params-configmap")]
+			_, hasIndex := anns[parser.GetAnnotationWithPrefix("fastcgi-index")]
+
+			if !fcgi {
+				if (hasConfigMap || hasIndex) && !checked[loc.Ingress] {
+					checked[loc.Ingress] = true
+					findings = append(findings, newLocationFinding("fastcgi-ineffective", SeverityWarning, server, loc,
+						"the fastcgi annotations have no effect because backend-protocol is %v instead of FCGI", loc.BackendProtocol))
+				}
+				continue
+			}
+
+			// the script is found from the path of the request, the
+			// directories need an index
+			if loc.FastCGI.Index == "" && strings.HasSuffix(loc.Path, "/") &&
+				strings.Contains(loc.FastCGI.Params["SCRIPT_FILENAME"], "$fastcgi_script_name") {
+				findings = append(findings, newLocationFinding("fastcgi-index-missing", SeverityWarning, server, loc,
+					"location %q serves a directory with FastCGI but fastcgi-index is not set; the requests ending with / have no script to execute",
+					loc.Path))
+			}
+
+			if checked[loc.Ingress] {
+				continue
+			}
+			checked[loc.Ingress] = true
+
+			if hasConfigMap {
+				findings = append(findings, n.checkFastCGIParams(server, loc, configMap)...)
+			} else {
+				findings = append(findings, newLocationFinding("fastcgi-params-missing", SeverityWarning, server, loc,
+					"backend-protocol is FCGI but fastcgi-params-configmap is not set; without SCRIPT_FILENAME the FastCGI server does not know the script to execute"))
+			}
+
+			ignored := []string{}
+			for _, name := range fastCGIIgnoredAnnotations {
+				if _, ok := anns[parser.GetAnnotationWithPrefix(name)]; ok {
+					ignored = append(ignored, name)
+				}
+			}
+			if len(ignored) > 0 {
+				findings = append(findings, newLocationFinding("fastcgi-incompatible-annotation", SeverityWarning, server, loc,
+					"annotations %v configure the proxied requests and have no effect with backend-protocol FCGI, which uses fastcgi_pass",
+					strings.Join(ignored, ", ")))
+			}
+		}
+	}
+
+	return findings
+}
+
+// checkFastCGIParams validates the ConfigMap of the FastCGI params of the
+// Ingress of the location
+func (n *NGINXController) checkFastCGIParams(server *Server, loc *Location, configMap string) []Finding {
+	findings := []Finding{}
+	namespace, name, ok := strings.Cut(configMap, "/")
+	if !ok {
+		findings = append(findings, newLocationFinding("fastcgi-params-invalid", SeverityError, server, loc,
+			"fastcgi-params-configmap %v is not namespace/name; the ingress controller ignores the FastCGI configuration",
+			configMap))
+		return findings
+	}
+
+	if namespace != loc.Ingress.Namespace {
+		findings = append(findings, newLocationFinding("fastcgi-params-invalid", SeverityError, server, loc,
+			"fastcgi-params-configmap %v is not in the namespace of the Ingress; the ingress controller ignores the FastCGI configuration",
+			configMap))
+		return findings
+	}
+	cm, err := n.store.GetConfigMap(namespace + "/" + name)
+	if err != nil {
+		findings = append(findings, newLocationFinding("fastcgi-params-invalid", SeverityError, server, loc,
+			"fastcgi-params-configmap %v/%v does not exist; the ingress controller ignores the FastCGI configuration",
+			namespace, name))
+		return findings
+	}
+
+	keys := make([]string, 0, len(cm.Data))
+	for key := range cm.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !fastCGIParamRegex.MatchString(key) {
+			findings = append(findings, newLocationFinding("fastcgi-params-invalid", SeverityError, server, loc,
+				"FastCGI param %q of ConfigMap %v/%v contains characters other than letters, digits and _; nginx fails to reload",
+				key, namespace, name))
+		}
+	}
+	if _, ok := cm.Data["SCRIPT_FILENAME"]; !ok {
+		findings = append(findings, newLocationFinding("fastcgi-params-missing", SeverityWarning, server, loc,
+			"ConfigMap %v/%v does not set the FastCGI param SCRIPT_FILENAME; FastCGI servers like PHP-FPM answer \"Primary script unknown\"",
+			namespace, name))
+	}
+	return findings
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// fastCGIManifests is completed with the annotations of the Ingress
+const fastCGIManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: fcgi-params
+  namespace: default
+data:
+  SCRIPT_FILENAME: /var/www/html$fastcgi_script_name
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: fcgi-invalid
+  namespace: default
+data:
+  SCRIPT-NAME: index.php
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: php
+  namespace: default
+  annotations:
+%v
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: php.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: php-fpm
+            port:
+              number: 9000
+`
+
+func TestCheckFastCGI(t *testing.T) {
+	testCases := map[string]struct {
+		annotations string
+		expected    []string
+	}{
+		"valid": {
+			annotations: `    nginx.ingress.kubernetes.io/backend-protocol: FCGI
+    nginx.ingress.kubernetes.io/fastcgi-index: index.php
+    nginx.ingress.kubernetes.io/fastcgi-params-configmap: default/fcgi-params`,
+		},
+		"missing index": {
+			annotations: `    nginx.ingress.kubernetes.io/backend-protocol: FCGI
+    nginx.ingress.kubernetes.io/fastcgi-params-configmap: default/fcgi-params`,
+			expected: []string{"fastcgi-index-missing"},
+		},
+		"missing params": {
+			annotations: `    nginx.ingress.kubernetes.io/backend-protocol: FCGI`,
+			expected:    []string{"fastcgi-params-missing"},
+		},
+		"params of another namespace": {
+			annotations: `    nginx.ingress.kubernetes.io/backend-protocol: FCGI
+    nginx.ingress.kubernetes.io/fastcgi-index: index.php
+    nginx.ingress.kubernetes.io/fastcgi-params-configmap: kube-system/fcgi-params`,
+			expected: []string{"fastcgi-params-invalid"},
+		},
+		"params without namespace": {
+			annotations: `    nginx.ingress.kubernetes.io/backend-protocol: FCGI
+    nginx.ingress.kubernetes.io/fastcgi-index: index.php
+    nginx.ingress.kubernetes.io/fastcgi-params-configmap: fcgi-params`,
+			expected: []string{"fastcgi-params-invalid"},
+		},
+		"params not found": {
+			annotations: `    nginx.ingress.kubernetes.io/backend-protocol: FCGI
+    nginx.ingress.kubernetes.io/fastcgi-index: index.php
+    nginx.ingress.kubernetes.io/fastcgi-params-configmap: default/fcgi-missing`,
+			expected: []string{"fastcgi-params-invalid"},
+		},
+		"invalid params": {
+			annotations: `    nginx.ingress.kubernetes.io/backend-protocol: FCGI
+    nginx.ingress.kubernetes.io/fastcgi-index: index.php
+    nginx.ingress.kubernetes.io/fastcgi-params-configmap: default/fcgi-invalid`,
+			expected: []string{"fastcgi-params-invalid", "fastcgi-params-missing"},
+		},
+		"proxy annotations": {
+			annotations: `    nginx.ingress.kubernetes.io/backend-protocol: FCGI
+    nginx.ingress.kubernetes.io/fastcgi-index: index.php
+    nginx.ingress.kubernetes.io/fastcgi-params-configmap: default/fcgi-params
+    nginx.ingress.kubernetes.io/proxy-buffering: "on"
+    nginx.ingress.kubernetes.io/upstream-vhost: php.internal`,
+			expected: []string{"fastcgi-incompatible-annotation"},
+		},
+		"fastcgi annotations without FCGI": {
+			annotations: `    nginx.ingress.kubernetes.io/fastcgi-index: index.php`,
+			expected:    []string{"fastcgi-ineffective"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, fmt.Sprintf(fastCGIManifests, tc.annotations))
+			rules := []string{}
+			for _, f := range n.checkFastCGI(ingresses, cfg) {
+				rules = append(rules, f.Rule)
+				if f.Ingress != "default/php" || f.Host != "php.example.com" {
+					t.Errorf("expected the finding of default/php, got %+v", f)
+				}
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(rules, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, rules)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// Severity describes how serious a Finding is
+type Severity string
+
+const (
+	// SeverityInfo is used for findings that do not require any action
+	SeverityInfo Severity = "info"
+	// SeverityWarning is used for configurations that work but are likely wrong
+	SeverityWarning Severity = "warning"
+	// SeverityError is used for configurations that will not work as expected
+	SeverityError Severity = "error"
+)
+
+// Finding describes a problem detected while validating a configuration
+type Finding struct {
+	// Rule is the name of the check that produced the finding
+	Rule string `json:"rule"`
+	// Severity indicates how serious the problem is
+	Severity Severity `json:"severity"`
+	// Ingress is the namespace/name of the Ingress that caused the finding
+	// +optional
+	Ingress string `json:"ingress,omitempty"`
+	// Host is the server affected by the finding
+	// +optional
+	Host string `json:"host,omitempty"`
+	// Path is the location of the server affected by the finding
+	// +optional
+	Path string `json:"path,omitempty"`
+	// Message explains the problem
+	Message string `json:"message"`
+	// Source is the file, line and document index of the Ingress, when it
+	// was loaded from a manifest
+	// +optional
+	Source string `json:"source,omitempty"`
+}
+
+func (f Finding) String() string {
+	if f.Source != "" {
+		return fmt.Sprintf("[%v] %v: %v (ingress=%q host=%q source=%q)", f.Severity, f.Rule, f.Message, f.Ingress, f.Host, f.Source)
+	}
+	return fmt.Sprintf("[%v] %v: %v (ingress=%q host=%q)", f.Severity, f.Rule, f.Message, f.Ingress, f.Host)
+}
+
+// withoutSource returns the finding without its position in the manifests,
+// which changes whenever lines are added before the Ingress
+func (f Finding) withoutSource() Finding {
+	f.Source = ""
+	return f
+}
+
+// newLocationFinding returns a Finding for a location of a server
+func newLocationFinding(rule string, severity Severity, server *Server, loc *Location, format string, args ...interface{}) Finding {
+	f := Finding{
+		Rule:     rule,
+		Severity: severity,
+		Host:     server.Hostname,
+		Path:     loc.Path,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	if loc.Ingress != nil {
+		f.Ingress = k8s.MetaNamespaceKey(loc.Ingress)
+	}
+	return f
+}
+
+// sortFindings orders findings by severity, ingress, host and rule so the
+// output of the validation is stable
+func sortFindings(findings []Finding) {
+	rank := map[Severity]int{SeverityError: 0, SeverityWarning: 1, SeverityInfo: 2}
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if rank[a.Severity] != rank[b.Severity] {
+			return rank[a.Severity] < rank[b.Severity]
+		}
+		if a.Ingress != b.Ingress {
+			return a.Ingress < b.Ingress
+		}
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		return a.Rule < b.Rule
+	})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+const (
+	// labels set by the kustomize-controller of Flux on the objects of a
+	// Kustomization
+	fluxNameLabel      = "kustomize.toolkit.fluxcd.io/name"
+	fluxNamespaceLabel = "kustomize.toolkit.fluxcd.io/namespace"
+	// kustomizeOriginAnnotation is the annotation kustomize sets with the
+	// file an object comes from when buildMetadata contains originAnnotations
+	kustomizeOriginAnnotation = "config.kubernetes.io/origin"
+
+	// fluxStdinSource names the manifests read from the standard input
+	fluxStdinSource = "stdin"
+)
+
+// kustomizeOrigin is the value of the origin annotation
+type kustomizeOrigin struct {
+	Path string `json:"path"`
+	Repo string `json:"repo,omitempty"`
+	Ref  string `json:"ref,omitempty"`
+}
+
+// fluxLocation describes where the manifests come from in the Git repository
+type fluxLocation struct {
+	// source is the Flux source, <kind>/<namespace>/<name>
+	source string
+	// path is the path of the Kustomization in the source
+	path string
+	// kustomization is the namespace/name of the Kustomization, used when
+	// the objects do not have the labels of the kustomize-controller
+	kustomization string
+}
+
+// ingressSource returns the position of the Ingress in the Git repository:
+// the Flux source and the file of the Ingress, from its origin annotation,
+// followed by its Kustomization. position is used when the Ingress has no
+// origin annotation.
+func (l *fluxLocation) ingressSource(ing *Ingress, position string) string {
+	file := position
+	if value, ok := ing.Annotations[kustomizeOriginAnnotation]; ok {
+		origin := &kustomizeOrigin{}
+		if err := yaml.Unmarshal([]byte(value), origin); err == nil && origin.Path != "" {
+			file = path.Join(l.path, origin.Path)
+			if origin.Repo != "" {
+				// remote bases are not part of the source
+				file = origin.Repo + "//" + origin.Path
+				if origin.Ref != "" {
+					file = fmt.Sprintf("%v//%v?ref=%v", origin.Repo, origin.Path, origin.Ref)
+				}
+			}
+		}
+	}
+
+	source := file
+	if l.source != "" {
+		source = l.source + ":" + file
+	}
+
+	kustomization := l.kustomization
+	if name, ok := ing.Labels[fluxNameLabel]; ok {
+		kustomization = ing.Labels[fluxNamespaceLabel] + "/" + name
+	}
+	if kustomization != "" {
+		source += fmt.Sprintf(" (Kustomization %v)", kustomization)
+	}
+	return source
+}
+
+// runFlux validates the manifests of a Flux Kustomization after the
+// post-build substitutions, as printed by flux build kustomization, read from
+// the standard input. The findings refer to the files of the Ingresses in the
+// Git repository.
+func runFlux(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("flux", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	cfg := &NginxConfiguration{}
+	addConfigurationFlags(fs, cfg)
+
+	in := &validateInput{}
+	addInputFlags(fs, in, cfg)
+	location := &fluxLocation{}
+	fs.StringVar(&location.source, "flux-source", "",
+		"Source of the Kustomization, as <kind>/<namespace>/<name> (e.g. GitRepository/flux-system/flux-system).")
+	fs.StringVar(&location.path, "flux-path", "",
+		"Path of the Kustomization in its source, prefixed to the paths of the origin annotations.")
+	fs.StringVar(&location.kustomization, "flux-kustomization", "",
+		"Namespace/name of the Kustomization, used for the objects without the labels of the kustomize-controller.")
+	output := fs.String("output", "text", "Output format: text or json.")
+	strict := fs.Bool("strict", false, "Fail on warnings.")
+	addWorkDirFlags(fs, cfg)
+	addSecretStorageFlags(fs, cfg)
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitInternal
+	}
+
+	// the manifests of the standard input are added to the other inputs,
+	// when any
+	s, err := in.load(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitInternal
+	}
+	if err := s.LoadManifest(stdin, fluxStdinSource); err != nil {
+		fmt.Fprintf(stderr, "error loading manifests: %v\n", err)
+		return exitInternal
+	}
+
+	ingresses := s.ListIngresses()
+	for _, ing := range ingresses {
+		key := k8s.MetaNamespaceKey(ing)
+		if position := s.GetIngressSource(key); strings.HasPrefix(position, fluxStdinSource+":") {
+			s.setIngressSource(key, location.ingressSource(ing, position))
+		}
+	}
+
+	_, findings := newOfflineController(cfg, s).validate(ingresses)
+	if err := writeFindings(stdout, *output, findings); err != nil {
+		fmt.Fprintf(stderr, "error writing findings: %v\n", err)
+		return exitInternal
+	}
+
+	failOn := failOnError
+	if *strict {
+		failOn = failOnWarning
+	}
+	return findingsExitCode(findings, failOn)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFluxIngressSource(t *testing.T) {
+	testCases := map[string]struct {
+		location    fluxLocation
+		annotations map[string]string
+		labels      map[string]string
+		expected    string
+	}{
+		"origin annotation": {
+			location:    fluxLocation{source: "GitRepository/flux-system/apps", path: "clusters/live"},
+			annotations: map[string]string{kustomizeOriginAnnotation: "path: web/ingress.yaml\n"},
+			labels:      map[string]string{fluxNameLabel: "web", fluxNamespaceLabel: "flux-system"},
+			expected:    "GitRepository/flux-system/apps:clusters/live/web/ingress.yaml (Kustomization flux-system/web)",
+		},
+		"remote base": {
+			location: fluxLocation{path: "clusters/live"},
+			annotations: map[string]string{
+				kustomizeOriginAnnotation: "path: ingress.yaml\nrepo: https://github.com/example/base\nref: v1.2.0\n",
+			},
+			expected: "https://github.com/example/base//ingress.yaml?ref=v1.2.0",
+		},
+		"remote base without ref": {
+			annotations: map[string]string{kustomizeOriginAnnotation: "path: ingress.yaml\nrepo: https://github.com/example/base\n"},
+			expected:    "https://github.com/example/base//ingress.yaml",
+		},
+		"no origin annotation": {
+			location: fluxLocation{source: "GitRepository/flux-system/apps", kustomization: "flux-system/apps"},
+			expected: "GitRepository/flux-system/apps:stdin:12 (document 1) (Kustomization flux-system/apps)",
+		},
+		"invalid origin annotation": {
+			annotations: map[string]string{kustomizeOriginAnnotation: "[web"},
+			expected:    "stdin:12 (document 1)",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ing := &Ingress{}
+			ing.ObjectMeta = metav1.ObjectMeta{Annotations: tc.annotations, Labels: tc.labels}
+			if got := tc.location.ingressSource(ing, "stdin:12 (document 1)"); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestRunFlux(t *testing.T) {
+	manifest := strings.Replace(cliManifests, `  annotations:
+`, `  labels:
+    kustomize.toolkit.fluxcd.io/name: h2c
+    kustomize.toolkit.fluxcd.io/namespace: flux-system
+  annotations:
+    config.kubernetes.io/origin: |
+      path: h2c/ingress.yaml
+`, 1)
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"--nginx-version", "1.13.9", "--output", "json", "--flux-source", "GitRepository/flux-system/apps", "--flux-path", "apps"}
+	if code := runFlux(args, strings.NewReader(manifest), &stdout, &stderr); code != exitErrors {
+		t.Fatalf("expected exit code %v, got %v (%v)", exitErrors, code, stderr.String())
+	}
+
+	var findings []Finding
+	if err := json.Unmarshal(stdout.Bytes(), &findings); err != nil {
+		t.Fatalf("invalid findings %q: %v", stdout.String(), err)
+	}
+	h2c := findingsWithRule(findings, "h2c-nginx-version")
+	expected := "GitRepository/flux-system/apps:apps/h2c/ingress.yaml (Kustomization flux-system/h2c)"
+	if len(h2c) != 1 || h2c[0].Source != expected {
+		t.Errorf("expected a finding with the source %q, got %+v", expected, h2c)
+	}
+
+	stderr.Reset()
+	if code := runFlux(nil, strings.NewReader("kind: [Ingress\n"), &stdout, &stderr); code != exitInternal {
+		t.Errorf("expected exit code %v, got %v", exitInternal, code)
+	}
+	if !strings.Contains(stderr.String(), "error loading manifests: stdin") {
+		t.Errorf("expected the invalid manifest to be reported, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// validatorAnnotationPrefix is the prefix of the annotations read by the
+// validator only
+const validatorAnnotationPrefix = "nginx-config-validator"
+
+// validatorAnnotation returns the name of an annotation of the validator
+func validatorAnnotation(name string) string {
+	return validatorAnnotationPrefix + "/" + name
+}
+
+// freezeOverrideAnnotation allows changes during a freeze window. The value
+// must explain the emergency, and is logged. It is honoured only by the
+// request setting or changing it, so that an override left on the Ingress does
+// not admit the changes of the next freeze windows.
+var freezeOverrideAnnotation = validatorAnnotation("freeze-override")
+
+// modes of the change freeze
+const (
+	freezeModeReject = "reject"
+	freezeModeWarn   = "warn"
+)
+
+// admissionPolicy checks an admission request in addition to the validation
+// of the configuration. Error findings reject the request.
+type admissionPolicy func(req *admissionv1.AdmissionRequest, ing *networking.Ingress) []Finding
+
+// freezeWindow is a period during which Ingresses must not change. Windows
+// are either absolute (<RFC3339>/<RFC3339>) or weekly (<day> <HH:MM>/<day> <HH:MM>).
+type freezeWindow struct {
+	raw string
+
+	start, end time.Time
+
+	weekly         bool
+	weeklyStart    time.Duration
+	weeklyEnd      time.Duration
+	weeklyTimezone *time.Location
+}
+
+// parseFreezeWindow parses a window. Weekly windows use the timezone tz.
+func parseFreezeWindow(s string, tz *time.Location) (freezeWindow, error) {
+	w := freezeWindow{raw: s}
+
+	from, to, ok := strings.Cut(s, "/")
+	if !ok {
+		return w, fmt.Errorf("freeze window %q must use the format <start>/<end>", s)
+	}
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+
+	if start, err := time.Parse(time.RFC3339, from); err == nil {
+		end, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return w, fmt.Errorf("invalid end of freeze window %q: %w", s, err)
+		}
+		if !end.After(start) {
+			return w, fmt.Errorf("freeze window %q ends before it starts", s)
+		}
+		w.start, w.end = start, end
+		return w, nil
+	}
+
+	start, err := parseWeekTime(from)
+	if err != nil {
+		return w, fmt.Errorf("invalid start of freeze window %q: %w", s, err)
+	}
+	end, err := parseWeekTime(to)
+	if err != nil {
+		return w, fmt.Errorf("invalid end of freeze window %q: %w", s, err)
+	}
+	if start == end {
+		return w, fmt.Errorf("freeze window %q is empty", s)
+	}
+
+	w.weekly = true
+	w.weeklyStart, w.weeklyEnd = start, end
+	w.weeklyTimezone = tz
+	return w, nil
+}
+
+// parseWeekTime parses "<day> <HH:MM>" and returns the time since the start
+// of the week (Sunday 00:00)
+func parseWeekTime(s string) (time.Duration, error) {
+	day, clock, ok := strings.Cut(s, " ")
+	if !ok {
+		return 0, fmt.Errorf("%q must use the format <day> <HH:MM>", s)
+	}
+
+	weekday := -1
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := d.String()
+		if strings.EqualFold(day, name) || strings.EqualFold(day, name[:3]) {
+			weekday = int(d)
+		}
+	}
+	if weekday < 0 {
+		return 0, fmt.Errorf("unknown day %q", day)
+	}
+
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", clock)
+	}
+
+	return time.Duration(weekday)*24*time.Hour + time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// contains returns true if t is inside the window
+func (w freezeWindow) contains(t time.Time) bool {
+	if !w.weekly {
+		return !t.Before(w.start) && t.Before(w.end)
+	}
+
+	t = t.In(w.weeklyTimezone)
+	offset := time.Duration(t.Weekday())*24*time.Hour +
+		time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.weeklyStart < w.weeklyEnd {
+		return offset >= w.weeklyStart && offset < w.weeklyEnd
+	}
+	// the window wraps around the end of the week
+	return offset >= w.weeklyStart || offset < w.weeklyEnd
+}
+
+// changeFreeze rejects, or warns about, changes to Ingresses during the
+// freeze windows
+type changeFreeze struct {
+	windows []freezeWindow
+	mode    string
+	now     func() time.Time
+}
+
+// newChangeFreeze parses the freeze windows of the configuration, and returns
+// nil when there are none
+func newChangeFreeze(cfg *NginxConfiguration) (*changeFreeze, error) {
+	if len(cfg.FreezeWindows) == 0 {
+		return nil, nil
+	}
+
+	tz := time.UTC
+	if cfg.FreezeTimezone != "" {
+		var err error
+		tz, err = time.LoadLocation(cfg.FreezeTimezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid freeze timezone: %w", err)
+		}
+	}
+
+	mode := cfg.FreezeMode
+	if mode == "" {
+		mode = freezeModeReject
+	}
+	if mode != freezeModeReject && mode != freezeModeWarn {
+		return nil, fmt.Errorf("unknown freeze mode %q, expected %v or %v", mode, freezeModeReject, freezeModeWarn)
+	}
+
+	cf := &changeFreeze{mode: mode, now: time.Now}
+	for _, s := range cfg.FreezeWindows {
+		w, err := parseFreezeWindow(s, tz)
+		if err != nil {
+			return nil, err
+		}
+		cf.windows = append(cf.windows, w)
+	}
+	return cf, nil
+}
+
+// check is an admissionPolicy
+func (cf *changeFreeze) check(req *admissionv1.AdmissionRequest, ing *networking.Ingress) []Finding {
+	now := cf.now()
+	var active *freezeWindow
+	for i := range cf.windows {
+		if cf.windows[i].contains(now) {
+			active = &cf.windows[i]
+			break
+		}
+	}
+	if active == nil || !ingressChanged(req, ing) {
+		return nil
+	}
+
+	key := k8s.MetaNamespaceKey(ing)
+	if reason := freezeOverride(req, ing); reason != "" {
+		klog.Warningf("Ingress %v changed during freeze window %v by %v with override: %v", key, active.raw, req.UserInfo.Username, reason)
+		return []Finding{{
+			Rule:     "change-freeze",
+			Severity: SeverityWarning,
+			Ingress:  key,
+			Message:  fmt.Sprintf("change admitted during freeze window %v with %v: %v", active.raw, freezeOverrideAnnotation, reason),
+		}}
+	}
+
+	severity := SeverityError
+	if cf.mode == freezeModeWarn {
+		severity = SeverityWarning
+	}
+	message := fmt.Sprintf("Ingresses must not change during freeze window %v; for emergencies set the %v annotation to the reason of the change",
+		active.raw, freezeOverrideAnnotation)
+	if strings.TrimSpace(ing.Annotations[freezeOverrideAnnotation]) != "" {
+		message += fmt.Sprintf("; the %v annotation of a previous change is ignored, update it with the reason of this one", freezeOverrideAnnotation)
+	}
+	return []Finding{{
+		Rule:     "change-freeze",
+		Severity: severity,
+		Ingress:  key,
+		Message:  message,
+	}}
+}
+
+// freezeOverride returns the reason of the freeze override when the request
+// creates an Ingress with the annotation or changes its value
+func freezeOverride(req *admissionv1.AdmissionRequest, ing *networking.Ingress) string {
+	reason := strings.TrimSpace(ing.Annotations[freezeOverrideAnnotation])
+	if reason == "" || req.Operation != admissionv1.Update || len(req.OldObject.Raw) == 0 {
+		return reason
+	}
+
+	old := &networking.Ingress{}
+	if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+		return reason
+	}
+	if strings.TrimSpace(old.Annotations[freezeOverrideAnnotation]) == reason {
+		return ""
+	}
+	return reason
+}
+
+// ingressChanged returns false for updates that do not change the spec or
+// the annotations of the Ingress, such as status updates by the controller.
+// Changes of the validation-generation annotation only are ignored.
+func ingressChanged(req *admissionv1.AdmissionRequest, ing *networking.Ingress) bool {
+	if req.Operation != admissionv1.Update || len(req.OldObject.Raw) == 0 {
+		return true
+	}
+
+	old := &networking.Ingress{}
+	if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+		return true
+	}
+
+	return !reflect.DeepEqual(old.Spec, ing.Spec) ||
+		!reflect.DeepEqual(withoutValidationGeneration(old.Annotations), withoutValidationGeneration(ing.Annotations))
+}
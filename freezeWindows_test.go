@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestFreezeWindowContains(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skipf("timezone database not available: %v", err)
+	}
+
+	tests := []struct {
+		window   string
+		tz       *time.Location
+		time     string
+		expected bool
+	}{
+		{"2024-12-20T00:00:00Z/2025-01-02T00:00:00Z", time.UTC, "2024-12-25T12:00:00Z", true},
+		{"2024-12-20T00:00:00Z/2025-01-02T00:00:00Z", time.UTC, "2025-01-02T00:00:00Z", false},
+		{"Fri 16:00/Mon 08:00", time.UTC, "2024-06-07T16:00:00Z", true},
+		{"Fri 16:00/Mon 08:00", time.UTC, "2024-06-09T23:00:00Z", true},
+		{"Fri 16:00/Mon 08:00", time.UTC, "2024-06-10T08:00:00Z", false},
+		{"Fri 16:00/Mon 08:00", time.UTC, "2024-06-05T12:00:00Z", false},
+		{"mon 09:00/monday 10:00", time.UTC, "2024-06-10T09:30:00Z", true},
+		// 15:30 UTC is 16:30 in London during summer time
+		{"Fri 16:00/Mon 08:00", london, "2024-06-07T15:30:00Z", true},
+	}
+
+	for _, tc := range tests {
+		w, err := parseFreezeWindow(tc.window, tc.tz)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", tc.window, err)
+		}
+		now, err := time.Parse(time.RFC3339, tc.time)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := w.contains(now); got != tc.expected {
+			t.Errorf("%v contains %v: expected %v, got %v", tc.window, tc.time, tc.expected, got)
+		}
+	}
+}
+
+func TestParseFreezeWindowErrors(t *testing.T) {
+	for _, window := range []string{
+		"Fri 16:00",
+		"2025-01-02T00:00:00Z/2024-12-20T00:00:00Z",
+		"2024-12-20T00:00:00Z/tomorrow",
+		"Funday 16:00/Mon 08:00",
+		"Fri 25:00/Mon 08:00",
+		"Fri/Mon",
+		"Fri 16:00/Fri 16:00",
+	} {
+		if _, err := parseFreezeWindow(window, time.UTC); err == nil {
+			t.Errorf("%q: expected an error", window)
+		}
+	}
+}
+
+func TestNewChangeFreeze(t *testing.T) {
+	if cf, err := newChangeFreeze(&NginxConfiguration{}); cf != nil || err != nil {
+		t.Errorf("expected no change freeze without windows, got %v, %v", cf, err)
+	}
+	if _, err := newChangeFreeze(&NginxConfiguration{FreezeWindows: []string{"Fri 16:00/Mon 08:00"}, FreezeMode: "ignore"}); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+	if _, err := newChangeFreeze(&NginxConfiguration{FreezeWindows: []string{"Fri 16:00/Mon 08:00"}, FreezeTimezone: "Nowhere/City"}); err == nil {
+		t.Error("expected an error for an unknown timezone")
+	}
+}
+
+// freezeRequest returns an admission request changing an Ingress from old to ing
+func freezeRequest(t *testing.T, operation admissionv1.Operation, old, ing *networking.Ingress) *admissionv1.AdmissionRequest {
+	t.Helper()
+
+	req := &admissionv1.AdmissionRequest{Resource: ingressResource, Operation: operation, Namespace: "default"}
+	raw, err := json.Marshal(ing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Object = runtime.RawExtension{Raw: raw}
+	if old != nil {
+		raw, err := json.Marshal(old)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.OldObject = runtime.RawExtension{Raw: raw}
+	}
+	return req
+}
+
+func TestChangeFreezeWebhook(t *testing.T) {
+	frozen := func(mode string) *changeFreeze {
+		cf, err := newChangeFreeze(&NginxConfiguration{FreezeWindows: []string{"Fri 16:00/Mon 08:00"}, FreezeMode: mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a Saturday
+		cf.now = func() time.Time { return time.Date(2024, 6, 8, 12, 0, 0, 0, time.UTC) }
+		return cf
+	}
+	ingress := func(class string, annotations map[string]string) *networking.Ingress {
+		return &networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: annotations},
+			Spec:       networking.IngressSpec{IngressClassName: &class},
+		}
+	}
+	override := map[string]string{freezeOverrideAnnotation: "INC-42 certificate expired"}
+	newOverride := map[string]string{freezeOverrideAnnotation: "INC-43 backend moved"}
+
+	tests := []struct {
+		name     string
+		freeze   *changeFreeze
+		req      *admissionv1.AdmissionRequest
+		allowed  bool
+		warnings int
+		checked  bool
+		message  string
+	}{
+		{
+			name:    "outside the window",
+			freeze:  &changeFreeze{windows: frozen(freezeModeReject).windows, now: func() time.Time { return time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC) }},
+			req:     freezeRequest(t, admissionv1.Create, nil, ingress("nginx", nil)),
+			allowed: true,
+			checked: true,
+		},
+		{
+			name:    "create",
+			freeze:  frozen(freezeModeReject),
+			req:     freezeRequest(t, admissionv1.Create, nil, ingress("nginx", nil)),
+			allowed: false,
+		},
+		{
+			name:    "update",
+			freeze:  frozen(freezeModeReject),
+			req:     freezeRequest(t, admissionv1.Update, ingress("nginx", nil), ingress("internal", nil)),
+			allowed: false,
+		},
+		{
+			name:    "status update",
+			freeze:  frozen(freezeModeReject),
+			req:     freezeRequest(t, admissionv1.Update, ingress("nginx", nil), ingress("nginx", nil)),
+			allowed: true,
+			checked: true,
+		},
+		{
+			name:     "warn mode",
+			freeze:   frozen(freezeModeWarn),
+			req:      freezeRequest(t, admissionv1.Update, ingress("nginx", nil), ingress("internal", nil)),
+			allowed:  true,
+			warnings: 1,
+			checked:  true,
+		},
+		{
+			name:     "override",
+			freeze:   frozen(freezeModeReject),
+			req:      freezeRequest(t, admissionv1.Update, ingress("nginx", nil), ingress("internal", override)),
+			allowed:  true,
+			warnings: 1,
+			checked:  true,
+		},
+		{
+			name:     "override on create",
+			freeze:   frozen(freezeModeReject),
+			req:      freezeRequest(t, admissionv1.Create, nil, ingress("nginx", override)),
+			allowed:  true,
+			warnings: 1,
+			checked:  true,
+		},
+		{
+			name:    "no override",
+			freeze:  frozen(freezeModeReject),
+			req:     freezeRequest(t, admissionv1.Update, ingress("nginx", nil), ingress("internal", map[string]string{"team": "web"})),
+			allowed: false,
+		},
+		{
+			name:    "stale override",
+			freeze:  frozen(freezeModeReject),
+			req:     freezeRequest(t, admissionv1.Update, ingress("nginx", override), ingress("internal", override)),
+			allowed: false,
+			message: "annotation of a previous change is ignored",
+		},
+		{
+			name:     "stale override in warn mode",
+			freeze:   frozen(freezeModeWarn),
+			req:      freezeRequest(t, admissionv1.Update, ingress("nginx", override), ingress("internal", override)),
+			allowed:  true,
+			warnings: 1,
+			checked:  true,
+		},
+		{
+			name:     "changed override",
+			freeze:   frozen(freezeModeReject),
+			req:      freezeRequest(t, admissionv1.Update, ingress("nginx", override), ingress("internal", newOverride)),
+			allowed:  true,
+			warnings: 1,
+			checked:  true,
+		},
+		{
+			name:    "empty override",
+			freeze:  frozen(freezeModeReject),
+			req:     freezeRequest(t, admissionv1.Update, ingress("nginx", nil), ingress("internal", map[string]string{freezeOverrideAnnotation: " "})),
+			allowed: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			checked := false
+			h := &admissionHandler{
+				checkIngress: func(ing *networking.Ingress) ([]Finding, error) {
+					checked = true
+					return nil, nil
+				},
+				policies: []admissionPolicy{tc.freeze.check},
+			}
+
+			resp := h.review(context.Background(), tc.req)
+			if resp.Allowed != tc.allowed {
+				t.Errorf("expected allowed %v, got %v (%v)", tc.allowed, resp.Allowed, resp.Result)
+			}
+			if len(resp.Warnings) != tc.warnings {
+				t.Errorf("expected %d warnings, got %v", tc.warnings, resp.Warnings)
+			}
+			if tc.message != "" && (resp.Result == nil || !strings.Contains(resp.Result.Message, tc.message)) {
+				t.Errorf("expected a message containing %q, got %v", tc.message, resp.Result)
+			}
+			if checked != tc.checked {
+				t.Errorf("expected the configuration to be validated: %v", tc.checked)
+			}
+		})
+	}
+}
@@ -1,300 +1,497 @@
 package main
 
 import (
+	"expvar"
 	"fmt"
+	"runtime/debug"
+	"sort"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/klog/v2"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
 )
 
+// recoveredPanicsTotal counts the Ingresses left out of the configuration
+// after a panic
+var recoveredPanicsTotal = expvar.NewInt("recovered_panics_total")
+
 // getBackendServers returns a list of Upstream and Server to be used by the
 // backend.  An upstream can be used in multiple servers if the namespace,
 // service name and port are the same.
 //
 //nolint:gocyclo // Ignore function complexity error
-func (n *NGINXController) getBackendServers(ingresses []*Ingress) ([]*Backend, []*Server) {
+func (n *NGINXController) getBackendServers(ingresses []*Ingress) ([]*Backend, []*Server, []Finding) {
 	du := n.getDefaultUpstream()
 	upstreams := n.createUpstreams(ingresses, du)
 	servers := n.createServers(ingresses, upstreams, du)
 
 	var canaryIngresses []*Ingress
+	var findings []Finding
+
+	svid := n.getSPIFFEClientCert()
+	names := upstreamNames{}
 
 	for _, ing := range ingresses {
-		ingKey := k8s.MetaNamespaceKey(ing)
-		anns := ing.ParsedAnnotations
+		finding := isolateIngress(ing, servers, upstreams, func() error {
+			return n.addIngressLocations(ing, servers, upstreams, names, svid)
+		})
+		if finding != nil {
+			findings = append(findings, *finding)
+			continue
+		}
 
-		if !n.store.GetBackendConfiguration().AllowSnippetAnnotations {
-			dropSnippetDirectives(anns, ingKey)
+		// set aside canary ingresses to merge later
+		if ing.ParsedAnnotations.Canary.Enabled {
+			canaryIngresses = append(canaryIngresses, ing)
 		}
+	}
 
-		for _, rule := range ing.Spec.Rules {
-			host := rule.Host
-			if host == "" {
-				host = defServerName
+	if nonCanaryIngressExists(ingresses, canaryIngresses) {
+		for _, canaryIng := range canaryIngresses {
+			finding := isolateIngress(canaryIng, servers, upstreams, func() error {
+				mergeAlternativeBackends(canaryIng, upstreams, servers)
+				return nil
+			})
+			if finding != nil {
+				findings = append(findings, *finding)
 			}
+		}
+	}
 
-			server := servers[host]
-			if server == nil {
-				server = servers[defServerName]
-			}
+	// locations of each upstream, instead of going through every location of
+	// every server for each upstream
+	type serverLocation struct {
+		server   *Server
+		location *Location
+	}
+	upstreamLocations := make(map[string][]serverLocation, len(upstreams))
+	for _, server := range servers {
+		for _, location := range server.Locations {
+			upstreamLocations[location.Backend] = append(upstreamLocations[location.Backend], serverLocation{server, location})
+		}
+	}
 
-			if rule.HTTP == nil &&
-				host != defServerName {
-				klog.V(3).Infof("Ingress %q does not contain any HTTP rule, using default backend", ingKey)
+	aUpstreams := make([]*Backend, 0, len(upstreams))
+
+	for _, upstream := range upstreams {
+		aUpstreams = append(aUpstreams, upstream)
+
+		if upstream.Name == defUpstreamName {
+			continue
+		}
+
+		isHTTPSfrom := []*Server{}
+		for _, sl := range upstreamLocations[upstream.Name] {
+			server, location := sl.server, sl.location
+			// use default backend
+			if !shouldCreateUpstreamForLocationDefaultBackend(upstream, location) {
 				continue
 			}
 
-			if server.AuthTLSError == "" && anns.CertificateAuth.AuthTLSError != "" {
-				server.AuthTLSError = anns.CertificateAuth.AuthTLSError
+			if len(location.DefaultBackend.Spec.Ports) == 0 {
+				// reported by checkCustomErrors
+				klog.V(3).Infof("Custom default backend service %v/%v has no ports. Ignoring", location.DefaultBackend.Namespace, location.DefaultBackend.Name)
+				continue
 			}
 
-			if server.CertificateAuth.CAFileName == "" {
-				server.CertificateAuth = anns.CertificateAuth
-				if server.CertificateAuth.Secret != "" && server.CertificateAuth.CAFileName == "" {
-					klog.V(3).Infof("Secret %q has no 'ca.crt' key, mutual authentication disabled for Ingress %q",
-						server.CertificateAuth.Secret, ingKey)
-				}
+			sp := location.DefaultBackend.Spec.Ports[0]
+			var zone string
+			if n.cfg.EnableTopologyAwareRouting {
+				zone = getIngressPodZone(location.DefaultBackend)
 			} else {
-				klog.V(3).Infof("Server %q is already configured for mutual authentication (Ingress %q)",
-					server.Hostname, ingKey)
+				zone = emptyZone
+			}
+			endps := getEndpointsFromSlices(location.DefaultBackend, &sp, apiv1.ProtocolTCP, zone, n.store.GetServiceEndpointsSlices)
+			// custom backend is valid only if contains at least one endpoint
+			if len(endps) > 0 {
+				name := fmt.Sprintf("custom-default-backend-%v-%v", location.DefaultBackend.GetNamespace(), location.DefaultBackend.GetName())
+				klog.V(3).Infof("Creating \"%v\" upstream based on default backend annotation", name)
+
+				nb := upstream.DeepCopy()
+				nb.Name = name
+				nb.Endpoints = endps
+				aUpstreams = append(aUpstreams, nb)
+				location.DefaultBackendUpstreamName = name
+
+				if len(upstream.Endpoints) == 0 {
+					klog.V(3).Infof("Upstream %q has no active Endpoint, so using custom default backend for location %q in server %q (Service \"%v/%v\")",
+						upstream.Name, location.Path, server.Hostname, location.DefaultBackend.Namespace, location.DefaultBackend.Name)
+
+					location.Backend = name
+				}
 			}
 
-			if !n.store.GetBackendConfiguration().ProxySSLLocationOnly {
-				if server.ProxySSL.CAFileName == "" {
-					server.ProxySSL = anns.ProxySSL
-					if server.ProxySSL.Secret != "" && server.ProxySSL.CAFileName == "" {
-						klog.V(3).Infof("Secret %q has no 'ca.crt' key, client cert authentication disabled for Ingress %q",
-							server.ProxySSL.Secret, ingKey)
+			if server.SSLPassthrough {
+				if location.Path == rootLocation {
+					if location.Backend == defUpstreamName {
+						klog.Warningf("Server %q has no default backend, ignoring SSL Passthrough.", server.Hostname)
+						continue
 					}
-				} else {
-					klog.V(3).Infof("Server %q is already configured for client cert authentication (Ingress %q)",
-						server.Hostname, ingKey)
+					isHTTPSfrom = append(isHTTPSfrom, server)
 				}
 			}
+		}
 
-			if rule.HTTP == nil {
-				klog.V(3).Infof("Ingress %q does not contain any HTTP rule, using default backend", ingKey)
-				continue
+		if len(isHTTPSfrom) > 0 {
+			upstream.SSLPassthrough = true
+		}
+	}
+
+	aServers := make([]*Server, 0, len(servers))
+	for _, value := range servers {
+		// longest paths first, in reverse lexical order for the same length
+		sort.SliceStable(value.Locations, func(i, j int) bool {
+			pi, pj := value.Locations[i].Path, value.Locations[j].Path
+			if len(pi) != len(pj) {
+				return len(pi) > len(pj)
 			}
+			return pi > pj
+		})
+		aServers = append(aServers, value)
+	}
 
-			for _, path := range rule.HTTP.Paths {
-				if path.Backend.Service == nil {
-					// skip non-service backends
-					klog.V(3).Infof("Ingress %q and path %q does not contain a service backend, using default backend", ingKey, path.Path)
-					continue
-				}
+	sort.SliceStable(aUpstreams, func(a, b int) bool {
+		return aUpstreams[a].Name < aUpstreams[b].Name
+	})
 
-				upsName := upstreamName(ing.Namespace, path.Backend.Service)
+	sort.SliceStable(aServers, func(i, j int) bool {
+		return aServers[i].Hostname < aServers[j].Hostname
+	})
 
-				ups := upstreams[upsName]
+	return aUpstreams, aServers, findings
+}
 
-				// Backend is not referenced to by a server
-				if ups.NoServer {
-					continue
-				}
+// isolateIngress processes an Ingress with fn, recovering from the panics
+// caused by malformed Ingresses. When fn fails, the servers and upstreams the
+// Ingress can modify are restored as they were before fn, and a finding
+// reports the Ingress left out of the configuration.
+func isolateIngress(ing *Ingress, servers map[string]*Server, upstreams map[string]*Backend, fn func() error) (finding *Finding) {
+	ingKey := k8s.MetaNamespaceKey(ing)
+	snapshot := newIngressSnapshot(ing, servers, upstreams)
+	fail := func(err interface{}) {
+		snapshot.restore(upstreams)
+		finding = &Finding{
+			Rule:     "ingress-error",
+			Severity: SeverityError,
+			Ingress:  ingKey,
+			Message:  fmt.Sprintf("error processing the Ingress, it is left out of the configuration: %v", err),
+		}
+	}
 
-				nginxPath := rootLocation
-				if path.Path != "" {
-					nginxPath = path.Path
-				}
+	defer func() {
+		if r := recover(); r != nil {
+			recoveredPanicsTotal.Add(1)
+			klog.Errorf("Recovered from panic processing Ingress %q: %v\n%s", ingKey, r, debug.Stack())
+			fail(r)
+		}
+	}()
 
-				addLoc := true
-				for _, loc := range server.Locations {
-					if loc.Path != nginxPath {
-						continue
-					}
+	if err := fn(); err != nil {
+		fail(err)
+	}
+	return finding
+}
 
-					// Same paths but different types are allowed
-					// (same type means overlap in the path definition)
-					if !apiequality.Semantic.DeepEqual(loc.PathType, path.PathType) {
-						break
-					}
+// ingressSnapshot is the state of the servers and upstreams an Ingress can
+// modify: the servers of its hosts and the default server, their locations,
+// the upstreams of these locations and the upstreams of the Ingress
+type ingressSnapshot struct {
+	servers   map[*Server]Server
+	locations map[*Location]Location
+	upstreams map[string]*Backend
+	backends  map[*Backend]Backend
+}
 
-					addLoc = false
+// newIngressSnapshot copies the state the Ingress can modify
+func newIngressSnapshot(ing *Ingress, servers map[string]*Server, upstreams map[string]*Backend) *ingressSnapshot {
+	snapshot := &ingressSnapshot{
+		servers:   map[*Server]Server{},
+		locations: map[*Location]Location{},
+		upstreams: map[string]*Backend{},
+		backends:  map[*Backend]Backend{},
+	}
 
-					if !loc.IsDefBackend {
-						klog.V(3).Infof("Location %q already configured for server %q with upstream %q (Ingress %q)",
-							loc.Path, server.Hostname, loc.Backend, ingKey)
-						break
-					}
+	addUpstream := func(name string) {
+		ups := upstreams[name]
+		if ups == nil || snapshot.upstreams[name] != nil {
+			return
+		}
+		saved := *ups
+		saved.SessionAffinity = *ups.SessionAffinity.DeepCopy()
+		snapshot.upstreams[name] = ups
+		snapshot.backends[ups] = saved
+	}
+	addServer := func(host string) {
+		server := servers[host]
+		if server == nil {
+			return
+		}
+		if _, ok := snapshot.servers[server]; ok {
+			return
+		}
+		snapshot.servers[server] = *server
+		for _, loc := range server.Locations {
+			snapshot.locations[loc] = *loc
+			addUpstream(loc.Backend)
+		}
+	}
 
-					klog.V(3).Infof("Replacing location %q for server %q with upstream %q to use upstream %q (Ingress %q)",
-						loc.Path, server.Hostname, loc.Backend, ups.Name, ingKey)
+	addServer(defServerName)
+	if ing.Spec.DefaultBackend != nil && ing.Spec.DefaultBackend.Service != nil {
+		addUpstream(upstreamName(ing.Namespace, ing.Spec.DefaultBackend.Service))
+	}
+	for _, rule := range ing.Spec.Rules {
+		addServer(rule.Host)
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service != nil {
+				addUpstream(upstreamName(ing.Namespace, path.Backend.Service))
+			}
+		}
+	}
+	return snapshot
+}
 
-					loc.Backend = ups.Name
-					loc.IsDefBackend = false
-					loc.Port = ups.Port
-					loc.Service = ups.Service
-					loc.Ingress = ing
+// restore puts the servers, their locations and the upstreams back in the
+// state of the snapshot, including the upstreams removed from upstreams
+func (s *ingressSnapshot) restore(upstreams map[string]*Backend) {
+	for server, saved := range s.servers {
+		*server = saved
+	}
+	for loc, saved := range s.locations {
+		*loc = saved
+	}
+	for name, ups := range s.upstreams {
+		*ups = s.backends[ups]
+		upstreams[name] = ups
+	}
+}
 
-					locationApplyAnnotations(loc, anns)
+// upstreamNameKey identifies the Service port of an upstream
+type upstreamNameKey struct {
+	namespace string
+	service   string
+	port      networking.ServiceBackendPort
+}
 
-					if loc.Redirect.FromToWWW {
-						server.RedirectFromToWWW = true
-					}
+// upstreamNames interns the names of the upstreams, computed once for all
+// the paths of the Ingresses using the same Service port
+type upstreamNames map[upstreamNameKey]string
 
-					break
-				}
+func (u upstreamNames) get(namespace string, service *networking.IngressServiceBackend) string {
+	key := upstreamNameKey{namespace: namespace, service: service.Name, port: service.Port}
+	name, ok := u[key]
+	if !ok {
+		name = upstreamName(namespace, service)
+		u[key] = name
+	}
+	return name
+}
 
-				// new location
-				if addLoc {
-					klog.V(3).Infof("Adding location %q for server %q with upstream %q (Ingress %q)",
-						nginxPath, server.Hostname, ups.Name, ingKey)
-					loc := &Location{
-						Path:         nginxPath,
-						PathType:     path.PathType,
-						Backend:      ups.Name,
-						IsDefBackend: false,
-						Service:      ups.Service,
-						Port:         ups.Port,
-						Ingress:      ing,
-					}
-					locationApplyAnnotations(loc, anns)
+// addIngressLocations adds the locations of the rules of an Ingress to the
+// servers of their hosts
+//
+//nolint:gocyclo // Ignore function complexity error
+func (n *NGINXController) addIngressLocations(ing *Ingress, servers map[string]*Server, upstreams map[string]*Backend,
+	names upstreamNames, svid *resolver.AuthSSLCert,
+) error {
+	ingKey := k8s.MetaNamespaceKey(ing)
+	anns := ing.ParsedAnnotations
+
+	if !n.store.GetBackendConfiguration().AllowSnippetAnnotations {
+		dropSnippetDirectives(anns, ingKey)
+	}
 
-					if loc.Redirect.FromToWWW {
-						server.RedirectFromToWWW = true
-					}
-					server.Locations = append(server.Locations, loc)
-				}
+	for _, rule := range ing.Spec.Rules {
+		host := rule.Host
+		if host == "" {
+			host = defServerName
+		}
 
-				if ups.SessionAffinity.AffinityType == "" {
-					ups.SessionAffinity.AffinityType = anns.SessionAffinity.Type
-				}
+		server := servers[host]
+		if server == nil {
+			server = servers[defServerName]
+		}
 
-				if ups.SessionAffinity.AffinityMode == "" {
-					ups.SessionAffinity.AffinityMode = anns.SessionAffinity.Mode
-				}
+		if rule.HTTP == nil &&
+			host != defServerName {
+			klog.V(3).Infof("Ingress %q does not contain any HTTP rule, using default backend", ingKey)
+			continue
+		}
 
-				if anns.SessionAffinity.Type == "cookie" {
-					cookiePath := anns.SessionAffinity.Cookie.Path
-					if anns.Rewrite.UseRegex && cookiePath == "" {
-						klog.Warningf("session-cookie-path should be set when use-regex is true")
-					}
+		if server.AuthTLSError == "" && anns.CertificateAuth.AuthTLSError != "" {
+			server.AuthTLSError = anns.CertificateAuth.AuthTLSError
+		}
 
-					ups.SessionAffinity.CookieSessionAffinity.Name = anns.SessionAffinity.Cookie.Name
-					ups.SessionAffinity.CookieSessionAffinity.Expires = anns.SessionAffinity.Cookie.Expires
-					ups.SessionAffinity.CookieSessionAffinity.MaxAge = anns.SessionAffinity.Cookie.MaxAge
-					ups.SessionAffinity.CookieSessionAffinity.Secure = anns.SessionAffinity.Cookie.Secure
-					ups.SessionAffinity.CookieSessionAffinity.Path = cookiePath
-					ups.SessionAffinity.CookieSessionAffinity.Domain = anns.SessionAffinity.Cookie.Domain
-					ups.SessionAffinity.CookieSessionAffinity.SameSite = anns.SessionAffinity.Cookie.SameSite
-					ups.SessionAffinity.CookieSessionAffinity.ConditionalSameSiteNone = anns.SessionAffinity.Cookie.ConditionalSameSiteNone
-					ups.SessionAffinity.CookieSessionAffinity.ChangeOnFailure = anns.SessionAffinity.Cookie.ChangeOnFailure
-
-					locs := ups.SessionAffinity.CookieSessionAffinity.Locations
-					if _, ok := locs[host]; !ok {
-						locs[host] = []string{}
-					}
-					locs[host] = append(locs[host], path.Path)
-
-					if len(server.Aliases) > 0 {
-						for _, alias := range server.Aliases {
-							if _, ok := locs[alias]; !ok {
-								locs[alias] = []string{}
-							}
-							locs[alias] = append(locs[alias], path.Path)
-						}
-					}
-				}
+		if server.CertificateAuth.CAFileName == "" {
+			server.CertificateAuth = anns.CertificateAuth
+			if server.CertificateAuth.Secret != "" && server.CertificateAuth.CAFileName == "" {
+				klog.V(3).Infof("Secret %q has no 'ca.crt' key, mutual authentication disabled for Ingress %q",
+					server.CertificateAuth.Secret, ingKey)
 			}
+		} else {
+			klog.V(3).Infof("Server %q is already configured for mutual authentication (Ingress %q)",
+				server.Hostname, ingKey)
 		}
 
-		// set aside canary ingresses to merge later
-		if anns.Canary.Enabled {
-			canaryIngresses = append(canaryIngresses, ing)
+		if !n.store.GetBackendConfiguration().ProxySSLLocationOnly {
+			if server.ProxySSL.CAFileName == "" {
+				server.ProxySSL = anns.ProxySSL
+				if server.ProxySSL.Secret == "" && svid != nil {
+					klog.V(3).Infof("Using SPIFFE SVID for client cert authentication against backends (Ingress %q)", ingKey)
+					server.ProxySSL.AuthSSLCert = *svid
+				}
+				if server.ProxySSL.Secret != "" && server.ProxySSL.CAFileName == "" {
+					klog.V(3).Infof("Secret %q has no 'ca.crt' key, client cert authentication disabled for Ingress %q",
+						server.ProxySSL.Secret, ingKey)
+				}
+			} else {
+				klog.V(3).Infof("Server %q is already configured for client cert authentication (Ingress %q)",
+					server.Hostname, ingKey)
+			}
 		}
-	}
 
-	if nonCanaryIngressExists(ingresses, canaryIngresses) {
-		for _, canaryIng := range canaryIngresses {
-			mergeAlternativeBackends(canaryIng, upstreams, servers)
+		if rule.HTTP == nil {
+			klog.V(3).Infof("Ingress %q does not contain any HTTP rule, using default backend", ingKey)
+			continue
 		}
-	}
 
-	aUpstreams := make([]*Backend, 0, len(upstreams))
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service == nil {
+				// skip non-service backends
+				klog.V(3).Infof("Ingress %q and path %q does not contain a service backend, using default backend", ingKey, path.Path)
+				continue
+			}
 
-	for _, upstream := range upstreams {
-		aUpstreams = append(aUpstreams, upstream)
+			if path.PathType == nil {
+				return fmt.Errorf("path %q of host %q has no pathType", path.Path, host)
+			}
 
-		if upstream.Name == defUpstreamName {
-			continue
-		}
+			upsName := names.get(ing.Namespace, path.Backend.Service)
 
-		isHTTPSfrom := []*Server{}
-		for _, server := range servers {
-			for _, location := range server.Locations {
-				// use default backend
-				if !shouldCreateUpstreamForLocationDefaultBackend(upstream, location) {
-					continue
-				}
+			ups, ok := upstreams[upsName]
+			if !ok {
+				return fmt.Errorf("no upstream %q for path %q of host %q", upsName, path.Path, host)
+			}
+
+			// Backend is not referenced to by a server
+			if ups.NoServer {
+				continue
+			}
+
+			nginxPath := rootLocation
+			if path.Path != "" {
+				nginxPath = path.Path
+			}
 
-				if len(location.DefaultBackend.Spec.Ports) == 0 {
-					klog.Errorf("Custom default backend service %v/%v has no ports. Ignoring", location.DefaultBackend.Namespace, location.DefaultBackend.Name)
+			addLoc := true
+			for _, loc := range server.Locations {
+				if loc.Path != nginxPath {
 					continue
 				}
 
-				sp := location.DefaultBackend.Spec.Ports[0]
-				var zone string
-				if n.cfg.EnableTopologyAwareRouting {
-					zone = getIngressPodZone(location.DefaultBackend)
-				} else {
-					zone = emptyZone
+				// Same paths but different types are allowed
+				// (same type means overlap in the path definition)
+				if !apiequality.Semantic.DeepEqual(loc.PathType, path.PathType) {
+					break
 				}
-				endps := getEndpointsFromSlices(location.DefaultBackend, &sp, apiv1.ProtocolTCP, zone, n.store.GetServiceEndpointsSlices)
-				// custom backend is valid only if contains at least one endpoint
-				if len(endps) > 0 {
-					name := fmt.Sprintf("custom-default-backend-%v-%v", location.DefaultBackend.GetNamespace(), location.DefaultBackend.GetName())
-					klog.V(3).Infof("Creating \"%v\" upstream based on default backend annotation", name)
-
-					nb := upstream.DeepCopy()
-					nb.Name = name
-					nb.Endpoints = endps
-					aUpstreams = append(aUpstreams, nb)
-					location.DefaultBackendUpstreamName = name
-
-					if len(upstream.Endpoints) == 0 {
-						klog.V(3).Infof("Upstream %q has no active Endpoint, so using custom default backend for location %q in server %q (Service \"%v/%v\")",
-							upstream.Name, location.Path, server.Hostname, location.DefaultBackend.Namespace, location.DefaultBackend.Name)
-
-						location.Backend = name
-					}
+
+				addLoc = false
+
+				if !loc.IsDefBackend {
+					klog.V(3).Infof("Location %q already configured for server %q with upstream %q (Ingress %q)",
+						loc.Path, server.Hostname, loc.Backend, ingKey)
+					break
 				}
 
-				if server.SSLPassthrough {
-					if location.Path == rootLocation {
-						if location.Backend == defUpstreamName {
-							klog.Warningf("Server %q has no default backend, ignoring SSL Passthrough.", server.Hostname)
-							continue
-						}
-						isHTTPSfrom = append(isHTTPSfrom, server)
-					}
+				klog.V(3).Infof("Replacing location %q for server %q with upstream %q to use upstream %q (Ingress %q)",
+					loc.Path, server.Hostname, loc.Backend, ups.Name, ingKey)
+
+				loc.Backend = ups.Name
+				loc.IsDefBackend = false
+				loc.Port = ups.Port
+				loc.Service = ups.Service
+				loc.Ingress = ing
+
+				locationApplyAnnotations(loc, anns)
+
+				if loc.Redirect.FromToWWW {
+					server.RedirectFromToWWW = true
 				}
+
+				break
 			}
-		}
 
-		if len(isHTTPSfrom) > 0 {
-			upstream.SSLPassthrough = true
-		}
-	}
+			// new location
+			if addLoc {
+				klog.V(3).Infof("Adding location %q for server %q with upstream %q (Ingress %q)",
+					nginxPath, server.Hostname, ups.Name, ingKey)
+				loc := &Location{
+					Path:         nginxPath,
+					PathType:     path.PathType,
+					Backend:      ups.Name,
+					IsDefBackend: false,
+					Service:      ups.Service,
+					Port:         ups.Port,
+					Ingress:      ing,
+				}
+				locationApplyAnnotations(loc, anns)
 
-	aServers := make([]*Server, 0, len(servers))
-	for _, value := range servers {
-		sort.SliceStable(value.Locations, func(i, j int) bool {
-			return value.Locations[i].Path > value.Locations[j].Path
-		})
+				if loc.Redirect.FromToWWW {
+					server.RedirectFromToWWW = true
+				}
+				server.Locations = append(server.Locations, loc)
+			}
 
-		sort.SliceStable(value.Locations, func(i, j int) bool {
-			return len(value.Locations[i].Path) > len(value.Locations[j].Path)
-		})
-		aServers = append(aServers, value)
-	}
+			if ups.SessionAffinity.AffinityType == "" {
+				ups.SessionAffinity.AffinityType = anns.SessionAffinity.Type
+			}
 
-	sort.SliceStable(aUpstreams, func(a, b int) bool {
-		return aUpstreams[a].Name < aUpstreams[b].Name
-	})
+			if ups.SessionAffinity.AffinityMode == "" {
+				ups.SessionAffinity.AffinityMode = anns.SessionAffinity.Mode
+			}
 
-	sort.SliceStable(aServers, func(i, j int) bool {
-		return aServers[i].Hostname < aServers[j].Hostname
-	})
+			if anns.SessionAffinity.Type == "cookie" {
+				cookiePath := anns.SessionAffinity.Cookie.Path
+				if anns.Rewrite.UseRegex && cookiePath == "" {
+					// reported by checkSessionAffinity
+					klog.V(3).Infof("session-cookie-path should be set when use-regex is true")
+				}
+
+				ups.SessionAffinity.CookieSessionAffinity.Name = anns.SessionAffinity.Cookie.Name
+				ups.SessionAffinity.CookieSessionAffinity.Expires = anns.SessionAffinity.Cookie.Expires
+				ups.SessionAffinity.CookieSessionAffinity.MaxAge = anns.SessionAffinity.Cookie.MaxAge
+				ups.SessionAffinity.CookieSessionAffinity.Secure = anns.SessionAffinity.Cookie.Secure
+				ups.SessionAffinity.CookieSessionAffinity.Path = cookiePath
+				ups.SessionAffinity.CookieSessionAffinity.Domain = anns.SessionAffinity.Cookie.Domain
+				ups.SessionAffinity.CookieSessionAffinity.SameSite = anns.SessionAffinity.Cookie.SameSite
+				ups.SessionAffinity.CookieSessionAffinity.ConditionalSameSiteNone = anns.SessionAffinity.Cookie.ConditionalSameSiteNone
+				ups.SessionAffinity.CookieSessionAffinity.ChangeOnFailure = anns.SessionAffinity.Cookie.ChangeOnFailure
+
+				locs := ups.SessionAffinity.CookieSessionAffinity.Locations
+				if _, ok := locs[host]; !ok {
+					locs[host] = []string{}
+				}
+				locs[host] = append(locs[host], path.Path)
 
-	return aUpstreams, aServers
+				if len(server.Aliases) > 0 {
+					for _, alias := range server.Aliases {
+						if _, ok := locs[alias]; !ok {
+							locs[alias] = []string{}
+						}
+						locs[alias] = append(locs[alias], path.Path)
+					}
+				}
+			}
+		}
+	}
+	return nil
 }
 
 // getDefaultUpstream returns the upstream associated with the default backend.
@@ -332,3 +529,9 @@ func (n *NGINXController) getDefaultUpstream() *Backend {
 	upstream.Endpoints = append(upstream.Endpoints, endps...)
 	return upstream
 }
+
+// checkIngressErrors reports the Ingresses left out of the configuration
+// because processing them failed
+func (n *NGINXController) checkIngressErrors(_ []*Ingress, cfg *Configuration) []Finding {
+	return cfg.IngressErrors
+}
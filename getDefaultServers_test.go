@@ -0,0 +1,281 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const isolationManifests = `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: api
+  namespace: default
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /api
+        pathType: Prefix
+        backend:
+          service:
+            name: api
+            port:
+              number: 80
+`
+
+func TestGetBackendServersIngressError(t *testing.T) {
+	n := newTestController(t, isolationManifests)
+	ingresses := n.store.ListIngresses()
+	for _, ing := range ingresses {
+		if ing.Name == "api" {
+			ing.Spec.Rules[0].HTTP.Paths[0].PathType = nil
+		}
+	}
+
+	_, _, cfg := n.getConfiguration(ingresses)
+	findings := n.checkIngressErrors(ingresses, cfg)
+	if len(findings) != 1 {
+		t.Fatalf("expected one finding, got %+v", findings)
+	}
+	expected := `error processing the Ingress, it is left out of the configuration: path "/api" of host "web.example.com" has no pathType`
+	if f := findings[0]; f.Rule != "ingress-error" || f.Severity != SeverityError || f.Ingress != "default/api" || f.Message != expected {
+		t.Errorf("unexpected finding %+v", f)
+	}
+
+	// the other Ingress of the host is still served
+	server := findServer(cfg, "web.example.com")
+	if server == nil {
+		t.Fatal("expected a server for web.example.com")
+	}
+	paths := []string{}
+	for _, loc := range server.Locations {
+		paths = append(paths, loc.Path)
+	}
+	if strings.Join(paths, ",") != "/" {
+		t.Errorf("expected only the location of default/web, got %v", paths)
+	}
+}
+
+func TestIsolateIngress(t *testing.T) {
+	testCases := map[string]struct {
+		fn       func() error
+		expected string
+		panics   int64
+	}{
+		"processed": {
+			fn: func() error { return nil },
+		},
+		"error": {
+			fn:       func() error { return errors.New("broken") },
+			expected: "error processing the Ingress, it is left out of the configuration: broken",
+		},
+		"panic": {
+			fn: func() error {
+				var loc *Location
+				_ = loc.Path
+				return nil
+			},
+			expected: "error processing the Ingress, it is left out of the configuration: runtime error: invalid memory address or nil pointer dereference",
+			panics:   1,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n := newTestController(t, isolationManifests)
+			ing := testIngress(t, n, "default/api")
+			other := testIngress(t, n, "default/web")
+			server := &Server{Hostname: "web.example.com", Locations: []*Location{{Path: "/", Ingress: other}}}
+			servers := map[string]*Server{"web.example.com": server}
+
+			panics := recoveredPanicsTotal.Value()
+			f := isolateIngress(ing, servers, map[string]*Backend{}, func() error {
+				// the locations of the Ingress are added before it fails
+				server.Locations = append(server.Locations, &Location{Path: "/api", Ingress: ing}, &Location{Path: "/api/v2", Ingress: ing})
+				return tc.fn()
+			})
+			if got := recoveredPanicsTotal.Value() - panics; got != tc.panics {
+				t.Errorf("expected %v recovered panics, got %v", tc.panics, got)
+			}
+
+			locations := server.Locations
+			if tc.expected == "" {
+				if f != nil {
+					t.Errorf("expected no finding, got %+v", f)
+				}
+				if len(locations) != 3 {
+					t.Errorf("expected the locations to be kept, got %+v", locations)
+				}
+				return
+			}
+			if f == nil || f.Rule != "ingress-error" || f.Ingress != "default/api" || f.Message != tc.expected {
+				t.Errorf("expected a finding for default/api with %q, got %+v", tc.expected, f)
+			}
+			if len(locations) != 1 || locations[0].Ingress != other {
+				t.Errorf("expected only the location of default/web, got %+v", locations)
+			}
+		})
+	}
+}
+
+func TestUpstreamNames(t *testing.T) {
+	names := upstreamNames{}
+	testCases := []struct {
+		namespace string
+		service   *networking.IngressServiceBackend
+		expected  string
+	}{
+		{"default", &networking.IngressServiceBackend{Name: "web", Port: networking.ServiceBackendPort{Number: 80}}, "default-web-80"},
+		{"default", &networking.IngressServiceBackend{Name: "web", Port: networking.ServiceBackendPort{Name: "http"}}, "default-web-http"},
+		{"other", &networking.IngressServiceBackend{Name: "web", Port: networking.ServiceBackendPort{Number: 80}}, "other-web-80"},
+		{"default", &networking.IngressServiceBackend{Name: "web", Port: networking.ServiceBackendPort{Number: 80}}, "default-web-80"},
+	}
+	for _, tc := range testCases {
+		if got := names.get(tc.namespace, tc.service); got != tc.expected {
+			t.Errorf("expected %q for %v/%+v, got %q", tc.expected, tc.namespace, tc.service, got)
+		}
+	}
+	if len(names) != 3 {
+		t.Errorf("expected the names of 3 Service ports, got %v", names)
+	}
+}
+
+func TestGetBackendServersLocationOrder(t *testing.T) {
+	manifest := strings.Replace(isolationManifests, `      - path: /api
+        pathType: Prefix`, `      - path: /api
+        pathType: Prefix
+        backend:
+          service:
+            name: api
+            port:
+              number: 80
+      - path: /b
+        pathType: Exact
+        backend:
+          service:
+            name: api
+            port:
+              number: 80
+      - path: /a
+        pathType: Exact
+        backend:
+          service:
+            name: api
+            port:
+              number: 80
+      - path: /api/v1
+        pathType: Prefix`, 1)
+	_, _, cfg := testConfiguration(t, manifest)
+
+	server := findServer(cfg, "web.example.com")
+	if server == nil {
+		t.Fatal("expected a server for web.example.com")
+	}
+	paths := []string{}
+	for _, loc := range server.Locations {
+		paths = append(paths, loc.Path)
+	}
+	// longest paths first, then in reverse lexical order
+	expected := "/api/v1/,/api/v1,/api/,/api,/b,/a,/"
+	if strings.Join(paths, ",") != expected {
+		t.Errorf("expected the locations %v, got %v", expected, strings.Join(paths, ","))
+	}
+}
+
+func TestIsolateIngressRestores(t *testing.T) {
+	root := &Location{Path: "/", PathType: &pathTypePrefix, Backend: "default-app-80"}
+	defaultRoot := &Location{Path: "/", PathType: &pathTypePrefix, Backend: defUpstreamName}
+	server := &Server{Hostname: "app.example.com", Locations: []*Location{root}}
+	servers := map[string]*Server{
+		defServerName:     {Hostname: defServerName, Locations: []*Location{defaultRoot}},
+		"app.example.com": server,
+	}
+	app := newUpstream("default-app-80")
+	canary := newUpstream("default-canary-80")
+	upstreams := map[string]*Backend{
+		defUpstreamName:     newUpstream(defUpstreamName),
+		"default-app-80":    app,
+		"default-canary-80": canary,
+	}
+
+	ing := &Ingress{Ingress: networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "canary"},
+		Spec: networking.IngressSpec{Rules: []networking.IngressRule{{
+			Host: "app.example.com",
+			IngressRuleValue: networking.IngressRuleValue{HTTP: &networking.HTTPIngressRuleValue{Paths: []networking.HTTPIngressPath{{
+				Path:     "/",
+				PathType: &pathTypePrefix,
+				Backend: networking.IngressBackend{Service: &networking.IngressServiceBackend{
+					Name: "canary", Port: networking.ServiceBackendPort{Number: 80},
+				}},
+			}}}},
+		}}},
+	}}
+
+	for name, fail := range map[string]func(){
+		"panic": func() { panic("malformed Ingress") },
+		"error": nil,
+	} {
+		t.Run(name, func(t *testing.T) {
+			finding := isolateIngress(ing, servers, upstreams, func() error {
+				server.CertificateAuth.CAFileName = "/etc/ingress-controller/ssl/ca.pem"
+				server.ProxySSL.CAFileName = "/etc/ingress-controller/ssl/proxy.pem"
+				server.AuthTLSError = "secret not found"
+				server.RedirectFromToWWW = true
+				root.Backend = "default-canary-80"
+				root.Ingress = ing
+				server.Locations = append(server.Locations, &Location{Path: "/canary", Ingress: ing})
+				app.SessionAffinity.AffinityType = "cookie"
+				app.SessionAffinity.CookieSessionAffinity.Locations = map[string][]string{"app.example.com": {"/"}}
+				app.AlternativeBackends = append(app.AlternativeBackends, "default-canary-80")
+				delete(upstreams, "default-canary-80")
+				if fail != nil {
+					fail()
+				}
+				return errors.New("no upstream")
+			})
+			if finding == nil {
+				t.Fatalf("expected a finding for the Ingress")
+			}
+
+			if server.CertificateAuth.CAFileName != "" || server.ProxySSL.CAFileName != "" || server.AuthTLSError != "" || server.RedirectFromToWWW {
+				t.Errorf("expected the server settings to be restored, got %+v", server)
+			}
+			if len(server.Locations) != 1 || server.Locations[0] != root || root.Backend != "default-app-80" || root.Ingress != nil {
+				t.Errorf("expected the replaced location to be restored, got %+v", server.Locations)
+			}
+			if app.SessionAffinity.AffinityType != "" || len(app.SessionAffinity.CookieSessionAffinity.Locations) != 0 || len(app.AlternativeBackends) != 0 {
+				t.Errorf("expected the upstream to be restored, got %+v", app)
+			}
+			if upstreams["default-canary-80"] != canary {
+				t.Errorf("expected the removed canary upstream to be restored")
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	ngx_config "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/controller/config"
+)
+
+// keys of the ConfigMap configuring the global external authentication
+const (
+	globalAuthURLKey                 = "global-auth-url"
+	globalAuthMethodKey              = "global-auth-method"
+	globalAuthSigninKey              = "global-auth-signin"
+	globalAuthSigninRedirectParamKey = "global-auth-signin-redirect-param"
+	globalAuthResponseHeadersKey     = "global-auth-response-headers"
+	globalAuthRequestRedirectKey     = "global-auth-request-redirect"
+	globalAuthSnippetKey             = "global-auth-snippet"
+	globalAuthCacheKeyKey            = "global-auth-cache-key"
+	globalAuthCacheDurationKey       = "global-auth-cache-duration"
+	globalAuthAlwaysSetCookieKey     = "global-auth-always-set-cookie"
+)
+
+// globalAuthHeaderRegex matches the headers the controller accepts in
+// global-auth-response-headers
+var globalAuthHeaderRegex = regexp.MustCompile(`^[a-zA-Z\d\-_]+$`)
+
+// globalAuthMethods are the methods accepted for the authentication subrequest
+var globalAuthMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+// globalAuthInvalidKey is an invalid key of the global external authentication
+type globalAuthInvalidKey struct {
+	key   string
+	value string
+	err   error
+}
+
+// parseGlobalExternalAuth parses the global external authentication of the
+// ConfigMap data. The controller ignores the invalid values, and the whole
+// global authentication when the URL is invalid, so the authentication is
+// returned without them.
+func parseGlobalExternalAuth(data map[string]string) (*ngx_config.GlobalExternalAuth, []*globalAuthInvalidKey) {
+	auth := &ngx_config.GlobalExternalAuth{}
+	errs := []*globalAuthInvalidKey{}
+	invalid := func(key string, err error) {
+		errs = append(errs, &globalAuthInvalidKey{key: key, value: data[key], err: err})
+	}
+
+	if value := strings.TrimSpace(data[globalAuthURLKey]); value != "" {
+		u, err := parseAuthURL(value)
+		if err != nil {
+			invalid(globalAuthURLKey, err)
+		} else {
+			auth.URL = value
+			auth.Host = u.Hostname()
+		}
+	}
+
+	if value := strings.TrimSpace(data[globalAuthMethodKey]); value != "" {
+		if containsString(globalAuthMethods, value) {
+			auth.Method = value
+		} else {
+			invalid(globalAuthMethodKey, fmt.Errorf("expected one of %v", strings.Join(globalAuthMethods, ", ")))
+		}
+	}
+
+	if value := strings.TrimSpace(data[globalAuthSigninKey]); value != "" {
+		// the URL can contain nginx variables, e.g. https://$host/oauth2/start
+		u, err := url.Parse(value)
+		switch {
+		case err != nil:
+			invalid(globalAuthSigninKey, err)
+		case u.Scheme != "http" && u.Scheme != "https" || u.Host == "":
+			invalid(globalAuthSigninKey, fmt.Errorf("expected an absolute http or https URL"))
+		default:
+			auth.SigninURL = value
+		}
+	}
+	auth.SigninURLRedirectParam = strings.TrimSpace(data[globalAuthSigninRedirectParamKey])
+
+	if value := strings.TrimSpace(data[globalAuthResponseHeadersKey]); value != "" {
+		headers := []string{}
+		for _, header := range strings.Split(value, ",") {
+			header = strings.TrimSpace(header)
+			if !globalAuthHeaderRegex.MatchString(header) {
+				// the controller drops all the headers when one is invalid
+				invalid(globalAuthResponseHeadersKey, fmt.Errorf("header %q contains characters other than letters, digits, - and _", header))
+				headers = nil
+				break
+			}
+			headers = append(headers, header)
+		}
+		auth.ResponseHeaders = headers
+	}
+
+	auth.RequestRedirect = strings.TrimSpace(data[globalAuthRequestRedirectKey])
+	auth.AuthSnippet = data[globalAuthSnippetKey]
+	auth.AuthCacheKey = strings.TrimSpace(data[globalAuthCacheKeyKey])
+
+	if value := strings.TrimSpace(data[globalAuthCacheDurationKey]); value != "" {
+		durations, err := parseAuthCacheDurations(value)
+		if err != nil {
+			invalid(globalAuthCacheDurationKey, err)
+		} else {
+			auth.AuthCacheDuration = durations
+		}
+	}
+
+	if value := strings.TrimSpace(data[globalAuthAlwaysSetCookieKey]); value != "" {
+		alwaysSetCookie, err := strconv.ParseBool(value)
+		if err != nil {
+			invalid(globalAuthAlwaysSetCookieKey, fmt.Errorf("expected true or false"))
+		}
+		auth.AlwaysSetCookie = alwaysSetCookie
+	}
+
+	return auth, errs
+}
+
+// parseAuthCacheDurations parses the comma separated proxy_cache_valid
+// values of the authentication cache, e.g. 200 202 10m, 401 5m
+func parseAuthCacheDurations(value string) ([]string, error) {
+	durations := []string{}
+	for _, item := range strings.Split(value, ",") {
+		fields := strings.Fields(item)
+		if len(fields) == 0 {
+			continue
+		}
+		for _, code := range fields[:len(fields)-1] {
+			if n, err := strconv.Atoi(code); code != "any" && (err != nil || n < 100 || n > 599) {
+				return nil, fmt.Errorf("%q is not an HTTP status code", code)
+			}
+		}
+		if _, err := parseNginxTime(fields[len(fields)-1]); err != nil {
+			return nil, err
+		}
+		durations = append(durations, strings.Join(fields, " "))
+	}
+	return durations, nil
+}
+
+// globalExternalAuth returns the global external authentication applied by
+// the ingress controller: the override of the configuration when set, the one
+// of the ConfigMap otherwise, and the invalid keys of the ConfigMap
+func (n *NGINXController) globalExternalAuth() (*ngx_config.GlobalExternalAuth, []*globalAuthInvalidKey) {
+	data := map[string]string{}
+	if configMap, err := n.store.GetConfigMap(n.cfg.ConfigMapName); err == nil {
+		data = configMap.Data
+	}
+
+	auth, errs := parseGlobalExternalAuth(data)
+	if n.cfg.GlobalExternalAuth != nil {
+		auth = n.cfg.GlobalExternalAuth
+	}
+	return auth, errs
+}
+
+// checkGlobalExternalAuth validates the global external authentication of
+// the ConfigMap and the locations relying on it: the locations of a host
+// whose global authentication is invalid are not protected at all, and the
+// authentication service can not be protected by itself
+func (n *NGINXController) checkGlobalExternalAuth(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	auth, errs := n.globalExternalAuth()
+	for _, invalid := range errs {
+		findings = append(findings, Finding{
+			Rule:     "global-auth-invalid",
+			Severity: SeverityError,
+			Message: fmt.Sprintf("key %v of ConfigMap %v is %q: %v; the ingress controller ignores it",
+				invalid.key, n.cfg.ConfigMapName, invalid.value, invalid.err),
+		})
+	}
+	if auth.URL != "" {
+		if auth.SigninURLRedirectParam != "" && auth.SigninURL == "" {
+			findings = append(findings, Finding{
+				Rule:     "global-auth-ineffective",
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("%v is set without %v and has no effect", globalAuthSigninRedirectParamKey, globalAuthSigninKey),
+			})
+		}
+		if len(auth.AuthCacheDuration) > 0 && auth.AuthCacheKey == "" {
+			findings = append(findings, Finding{
+				Rule:     "global-auth-ineffective",
+				Severity: SeverityWarning,
+				Message: fmt.Sprintf("%v is set without %v; the authentication responses are not cached",
+					globalAuthCacheDurationKey, globalAuthCacheKeyKey),
+			})
+		}
+		if u, err := url.Parse(auth.URL); err == nil && n.cfg.RequireSecureAuthURL && u.Scheme != "https" {
+			findings = append(findings, Finding{
+				Rule:     "global-auth-insecure",
+				Severity: SeverityError,
+				Message: fmt.Sprintf("%v %q does not use HTTPS; credentials and session cookies are sent in clear text",
+					globalAuthURLKey, auth.URL),
+			})
+		}
+		if n.cfg.ProbeAuthURLs && !strings.Contains(auth.URL, "$") {
+			if err := n.probeAuthURL(auth.URL); err != nil {
+				findings = append(findings, Finding{
+					Rule:     "global-auth-unreachable",
+					Severity: SeverityError,
+					Message: fmt.Sprintf("%v %q does not respond: %v; nginx rejects every request of the locations using the global authentication while the service is down",
+						globalAuthURLKey, auth.URL, err),
+				})
+			}
+		}
+	}
+
+	// the URL of the ConfigMap is invalid and dropped
+	urlDropped := false
+	for _, invalid := range errs {
+		urlDropped = urlDropped || (auth.URL == "" && invalid.key == globalAuthURLKey)
+	}
+	for _, server := range cfg.Servers {
+		unprotected := 0
+		for _, loc := range server.Locations {
+			if !loc.EnableGlobalAuth || loc.ExternalAuth.URL != "" {
+				continue
+			}
+			switch {
+			case urlDropped:
+				unprotected++
+			case auth.URL == "" && loc.Ingress != nil &&
+				loc.Ingress.Annotations[parser.GetAnnotationWithPrefix("enable-global-auth")] == "true":
+				findings = append(findings, newLocationFinding("global-auth-missing", SeverityWarning, server, loc,
+					"location %q enables the global authentication but %v is not set in ConfigMap %v; the location is not authenticated",
+					loc.Path, globalAuthURLKey, n.cfg.ConfigMapName))
+			}
+		}
+		if unprotected > 0 {
+			findings = append(findings, Finding{
+				Rule:     "global-auth-unprotected",
+				Severity: SeverityError,
+				Ingress:  serverIngress(server),
+				Host:     server.Hostname,
+				Message: fmt.Sprintf("%d locations of %v rely on the global authentication, which the ingress controller ignores because %v is invalid; they are not authenticated",
+					unprotected, server.Hostname, globalAuthURLKey),
+			})
+		}
+	}
+
+	// the authentication subrequests to a host of the configuration go
+	// through its locations, which must not require the authentication
+	if auth.URL != "" {
+		if u, err := url.Parse(auth.URL); err == nil {
+			if server, _ := routeServer(cfg, u.Hostname()); server != nil && server.Hostname != "_" {
+				path := u.Path
+				if path == "" {
+					path = "/"
+				}
+				if loc := matchLocation(server, path); loc != nil && loc.EnableGlobalAuth && loc.ExternalAuth.URL == "" {
+					findings = append(findings, newLocationFinding("global-auth-loop", SeverityError, server, loc,
+						"%v %v is served by location %q, which requires the global authentication itself; every authentication subrequest loops until nginx fails it, set the annotation enable-global-auth to false on this Ingress",
+						globalAuthURLKey, auth.URL, loc.Path))
+				}
+			}
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	ngx_config "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/controller/config"
+)
+
+// globalAuthManifests is completed with the data of the ConfigMap and the
+// annotations of the Ingress of auth.example.com
+const globalAuthManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+data:
+%v
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+      - path: /app
+        pathType: Exact
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: auth
+  namespace: default
+  annotations:
+%v
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: auth.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: auth
+            port:
+              number: 80
+`
+
+func TestParseGlobalExternalAuth(t *testing.T) {
+	auth, errs := parseGlobalExternalAuth(map[string]string{
+		globalAuthURLKey:                 " https://auth.example.com/verify ",
+		globalAuthMethodKey:              "POST",
+		globalAuthSigninKey:              "https://$host/oauth2/start",
+		globalAuthSigninRedirectParamKey: "rd",
+		globalAuthResponseHeadersKey:     "X-User, X_Email",
+		globalAuthCacheKeyKey:            "$remote_user",
+		globalAuthCacheDurationKey:       "200 202 10m, 401 5m",
+		globalAuthAlwaysSetCookieKey:     "true",
+	})
+	if len(errs) != 0 {
+		t.Errorf("unexpected errors %+v", errs)
+	}
+	if auth.URL != "https://auth.example.com/verify" || auth.Host != "auth.example.com" || auth.Method != "POST" ||
+		auth.SigninURL != "https://$host/oauth2/start" || auth.SigninURLRedirectParam != "rd" || !auth.AlwaysSetCookie {
+		t.Errorf("unexpected authentication %+v", auth)
+	}
+	if !reflect.DeepEqual(auth.ResponseHeaders, []string{"X-User", "X_Email"}) {
+		t.Errorf("unexpected response headers %v", auth.ResponseHeaders)
+	}
+	if !reflect.DeepEqual(auth.AuthCacheDuration, []string{"200 202 10m", "401 5m"}) {
+		t.Errorf("unexpected cache durations %v", auth.AuthCacheDuration)
+	}
+
+	auth, errs = parseGlobalExternalAuth(map[string]string{
+		globalAuthURLKey:             "auth.example.com/verify",
+		globalAuthMethodKey:          "get",
+		globalAuthSigninKey:          "/oauth2/start",
+		globalAuthResponseHeadersKey: "X-User, X User",
+		globalAuthCacheDurationKey:   "200 10x",
+		globalAuthAlwaysSetCookieKey: "yes",
+	})
+	keys := []string{}
+	for _, invalid := range errs {
+		keys = append(keys, invalid.key)
+	}
+	expected := []string{globalAuthURLKey, globalAuthMethodKey, globalAuthSigninKey, globalAuthResponseHeadersKey, globalAuthCacheDurationKey, globalAuthAlwaysSetCookieKey}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected the invalid keys %v, got %v", expected, keys)
+	}
+	// the invalid values are dropped, as the controller does
+	if auth.URL != "" || auth.Method != "" || auth.SigninURL != "" || auth.ResponseHeaders != nil || auth.AuthCacheDuration != nil {
+		t.Errorf("expected the invalid values to be ignored, got %+v", auth)
+	}
+}
+
+func TestParseAuthCacheDurations(t *testing.T) {
+	durations, err := parseAuthCacheDurations("200  202 10m,, any 1h")
+	if err != nil || !reflect.DeepEqual(durations, []string{"200 202 10m", "any 1h"}) {
+		t.Errorf("unexpected durations %v %v", durations, err)
+	}
+	for _, value := range []string{"200 10x", "600 10m", "ok 10m"} {
+		if _, err := parseAuthCacheDurations(value); err == nil {
+			t.Errorf("expected %q to be invalid", value)
+		}
+	}
+}
+
+func TestCheckGlobalExternalAuth(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	testCases := []struct {
+		name        string
+		data        string
+		annotations string
+		configure   func(n *NGINXController)
+		expected    []string
+	}{
+		{
+			name:        "no global authentication",
+			data:        "  proxy-body-size: 8m",
+			annotations: "    nginx.ingress.kubernetes.io/ssl-redirect: \"false\"",
+		},
+		{
+			name:        "location enabling the missing global authentication",
+			data:        "  proxy-body-size: 8m",
+			annotations: "    nginx.ingress.kubernetes.io/enable-global-auth: \"true\"",
+			expected:    []string{"global-auth-missing auth.example.com"},
+		},
+		{
+			name:        "invalid URL",
+			data:        "  global-auth-url: auth.example.com/verify",
+			annotations: "    nginx.ingress.kubernetes.io/ssl-redirect: \"false\"",
+			expected: []string{
+				"global-auth-invalid ",
+				"global-auth-unprotected auth.example.com",
+				"global-auth-unprotected web.example.com",
+			},
+		},
+		{
+			name: "ineffective settings",
+			data: `  global-auth-url: https://login.example.com/verify
+  global-auth-signin-redirect-param: rd
+  global-auth-cache-duration: 200 10m`,
+			annotations: "    nginx.ingress.kubernetes.io/ssl-redirect: \"false\"",
+			expected:    []string{"global-auth-ineffective ", "global-auth-ineffective "},
+		},
+		{
+			name:        "insecure URL",
+			data:        "  global-auth-url: http://login.example.com/verify",
+			annotations: "    nginx.ingress.kubernetes.io/ssl-redirect: \"false\"",
+			configure:   func(n *NGINXController) { n.cfg.RequireSecureAuthURL = true },
+			expected:    []string{"global-auth-insecure "},
+		},
+		{
+			name:        "unreachable URL",
+			data:        "  global-auth-url: " + failing.URL + "/verify",
+			annotations: "    nginx.ingress.kubernetes.io/ssl-redirect: \"false\"",
+			configure: func(n *NGINXController) {
+				n.cfg.ProbeAuthURLs = true
+				n.cfg.AuthURLProbeTimeout = time.Second
+			},
+			expected: []string{"global-auth-unreachable "},
+		},
+		{
+			name:        "authentication service protected by itself",
+			data:        "  global-auth-url: https://auth.example.com/verify",
+			annotations: "    nginx.ingress.kubernetes.io/ssl-redirect: \"false\"",
+			expected:    []string{"global-auth-loop auth.example.com"},
+		},
+		{
+			name:        "authentication service excluded",
+			data:        "  global-auth-url: https://auth.example.com/verify",
+			annotations: "    nginx.ingress.kubernetes.io/enable-global-auth: \"false\"",
+		},
+		{
+			name:        "authentication set by the flags",
+			data:        "  global-auth-url: auth.example.com/verify",
+			annotations: "    nginx.ingress.kubernetes.io/enable-global-auth: \"false\"",
+			configure: func(n *NGINXController) {
+				n.cfg.GlobalExternalAuth = &ngx_config.GlobalExternalAuth{URL: "https://auth.example.com/verify"}
+			},
+			// the URL of the ConfigMap is reported, and the locations are
+			// protected by the authentication of the flags
+			expected: []string{"global-auth-invalid "},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, fmt.Sprintf(globalAuthManifests, tc.data, tc.annotations))
+			if tc.configure != nil {
+				tc.configure(n)
+			}
+
+			findings := []string{}
+			for _, f := range n.checkGlobalExternalAuth(ingresses, cfg) {
+				findings = append(findings, f.Rule+" "+f.Host)
+			}
+			if strings.Join(findings, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("expected %v, got %v", tc.expected, findings)
+			}
+		})
+	}
+}
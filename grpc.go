@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"expvar"
+	"net"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+
+	validatorv1 "github.com/jaskaransarkaria/nginx-ingress-validator/pkg/api/validator/v1"
+)
+
+const (
+	// grpcSummaryChunkSize is the size of the chunks of the configuration
+	// summary streamed by SummarizeConfig
+	grpcSummaryChunkSize = 64 << 10
+	// maxGRPCMessageSize limits the size of the requests, as for the API
+	maxGRPCMessageSize = maxAPIRequestSize
+)
+
+// grpcRequestsTotal counts the gRPC requests by method and status code
+var grpcRequestsTotal = expvar.NewMap("grpc_requests_total")
+
+// grpcServer serves the gRPC service of pkg/api/validator/v1 from the state
+// of the cluster of the webhook
+type grpcServer struct {
+	validatorv1.UnimplementedValidatorServer
+
+	n *NGINXController
+	// namespaceLimiter bounds the rate of the validations of the Ingresses
+	// of each namespace, as for the admission requests
+	namespaceLimiter *namespaceRateLimiter
+}
+
+// store returns the state of the cluster, with the objects of the manifests
+// of the request
+func (g *grpcServer) store(manifests string) (*memoryStore, error) {
+	if manifests != "" {
+		return g.n.requestStore(strings.NewReader(manifests))
+	}
+	s, ok := g.n.store.(*memoryStore)
+	if !ok {
+		return nil, errors.New("the state of the cluster can not be read")
+	}
+	return s, nil
+}
+
+// configuration builds the configuration of the state of the cluster, with
+// the objects of the manifests of the request
+func (g *grpcServer) configuration(manifests string) (*NGINXController, *Configuration, error) {
+	s, err := g.store(manifests)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the settings of the webhook can be reloaded during the build
+	g.n.configLock.RLock()
+	defer g.n.configLock.RUnlock()
+	n := newOfflineController(g.n.cfg, s)
+	_, _, cfg := n.getConfiguration(s.ListIngresses())
+	return n, cfg, nil
+}
+
+// ValidateIngresses streams the findings of the Ingresses of the manifests,
+// which must be in the namespaces of the caller, or the findings of the
+// Ingresses of the namespaces of the caller without manifests
+func (g *grpcServer) ValidateIngresses(req *validatorv1.ValidateIngressesRequest, stream validatorv1.Validator_ValidateIngressesServer) error {
+	caller := callerFromContext(stream.Context())
+
+	var findings []Finding
+	if req.GetManifests() != "" {
+		var err error
+		findings, err = g.n.apiValidateManifests(strings.NewReader(req.GetManifests()), caller, g.namespaceLimiter)
+		if err != nil {
+			return grpcError(err)
+		}
+	} else {
+		_, all := g.n.validate(g.n.store.ListIngresses())
+		findings = g.n.callerReport(&PreValidationResult{Findings: all}, caller).Findings
+	}
+
+	for i := range findings {
+		if err := stream.Send(grpcFinding(&findings[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SummarizeConfig streams the configuration summary of the snapshot command,
+// which covers every namespace
+func (g *grpcServer) SummarizeConfig(req *validatorv1.SummarizeConfigRequest, stream validatorv1.Validator_SummarizeConfigServer) error {
+	if !callerFromContext(stream.Context()).allowsAll() {
+		return status.Error(codes.PermissionDenied, "the configuration summary requires a caller allowed every namespace")
+	}
+	n, cfg, err := g.configuration(req.GetManifests())
+	if err != nil {
+		return grpcError(err)
+	}
+
+	w := bufio.NewWriterSize(&grpcChunkWriter{stream: stream}, grpcSummaryChunkSize)
+	if err := n.writeConfigSummary(w, cfg); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// ExplainRoute explains the route of a host and path, which can involve the
+// Ingresses of every namespace
+func (g *grpcServer) ExplainRoute(ctx context.Context, req *validatorv1.ExplainRouteRequest) (*validatorv1.ExplainRouteResponse, error) {
+	if !callerFromContext(ctx).allowsAll() {
+		return nil, status.Error(codes.PermissionDenied, "explaining routes requires a caller allowed every namespace")
+	}
+	if req.GetHost() == "" {
+		return nil, status.Error(codes.InvalidArgument, "host is required")
+	}
+	n, cfg, err := g.configuration(req.GetManifests())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	e := &explanation{n: n}
+	if err := e.locate(cfg, req.GetHost(), "", req.GetPath()); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	explanation := &bytes.Buffer{}
+	e.write(explanation)
+
+	resp := &validatorv1.ExplainRouteResponse{
+		Host:        e.ehc.Hostname,
+		Explanation: explanation.String(),
+	}
+	if e.location != nil {
+		resp.Location = &validatorv1.Location{
+			Path:     e.location.Path,
+			PathType: e.location.PathType,
+			Ingress:  e.location.Ingress,
+			Backend:  e.location.Backend,
+		}
+		for _, s := range e.location.Settings {
+			resp.Location.Settings = append(resp.Location.Settings, &validatorv1.Setting{Key: s.Key, Value: s.Value, Source: s.Source})
+		}
+	}
+	return resp, nil
+}
+
+// grpcFinding returns the message of a finding
+func grpcFinding(f *Finding) *validatorv1.Finding {
+	return &validatorv1.Finding{
+		Rule:     f.Rule,
+		Severity: string(f.Severity),
+		Ingress:  f.Ingress,
+		Host:     f.Host,
+		Path:     f.Path,
+		Message:  f.Message,
+		Source:   f.Source,
+	}
+}
+
+// grpcChunkWriter sends the writes as chunks of the configuration summary
+type grpcChunkWriter struct {
+	stream validatorv1.Validator_SummarizeConfigServer
+}
+
+func (w *grpcChunkWriter) Write(p []byte) (int, error) {
+	// the chunk is marshaled before Send returns, p is not retained
+	if err := w.stream.Send(&validatorv1.SummarizeConfigChunk{Data: p}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// grpcError returns the gRPC status of an error of the validation
+func grpcError(err error) error {
+	var badRequest *apiBadRequestError
+	var forbidden *apiForbiddenError
+	var rateLimited *namespaceRateLimitError
+	switch {
+	case errors.As(err, &badRequest):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &forbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.As(err, &rateLimited):
+		admissionShedTotal.Add(shedReason(err), 1)
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	klog.Errorf("Error serving gRPC request: %v", err)
+	return status.Error(codes.Internal, err.Error())
+}
+
+// grpcAuthenticate returns the caller allowed by the authentication of the
+// webhook, the bearer token being read from the authorization metadata
+func grpcAuthenticate(ctx context.Context, auth *webhookAuth) (*webhookCaller, error) {
+	var state *tls.ConnectionState
+	var addr net.Addr
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			state = &info.State
+		}
+	}
+	authorization := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			authorization = values[0]
+		}
+	}
+
+	caller, code, reason := auth.authenticate(state, authorization)
+	if code == 0 {
+		return caller, nil
+	}
+	admissionUnauthorizedTotal.Add(1)
+	klog.Warningf("Rejecting gRPC request from %v: %v", addr, reason)
+	if code == http.StatusUnauthorized {
+		return nil, status.Error(codes.Unauthenticated, reason)
+	}
+	return nil, status.Error(codes.PermissionDenied, reason)
+}
+
+// grpcAdmit authenticates a request and waits for a validation slot of the
+// limiter. It returns the context of the request, with its caller, and the
+// function releasing the slot.
+func grpcAdmit(ctx context.Context, auth *webhookAuth, limiter *validationLimiter) (context.Context, func(), error) {
+	caller, err := grpcAuthenticate(ctx, auth)
+	if err != nil {
+		return nil, nil, err
+	}
+	release, err := limiter.acquire(ctx)
+	if err != nil {
+		admissionShedTotal.Add(shedReason(err), 1)
+		return nil, nil, status.Errorf(codes.ResourceExhausted, "the ingress validation webhook is overloaded: %v", err)
+	}
+	return context.WithValue(ctx, webhookCallerKey{}, caller), release, nil
+}
+
+// grpcCallerStream is a server stream with the context of grpcAdmit
+type grpcCallerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcCallerStream) Context() context.Context {
+	return s.ctx
+}
+
+// newGRPCServer returns the gRPC server of the validator, using the
+// certificate and the authentication of the webhook, which is required, and
+// its validation limits
+func (n *NGINXController) newGRPCServer(tlsConfig *tls.Config, auth *webhookAuth, limiter *validationLimiter, namespaceLimiter *namespaceRateLimiter) (*grpc.Server, error) {
+	if auth == nil {
+		return nil, errors.New("--grpc-address requires the authentication of the webhook, set --webhook-client-ca or --webhook-token-file")
+	}
+
+	count := func(method string, err error) {
+		grpcRequestsTotal.Add(method[strings.LastIndex(method, "/")+1:]+" "+status.Code(err).String(), 1)
+	}
+
+	server := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(tlsConfig)),
+		grpc.MaxRecvMsgSize(maxGRPCMessageSize),
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			ctx, release, err := grpcAdmit(ctx, auth, limiter)
+			if err != nil {
+				count(info.FullMethod, err)
+				return nil, err
+			}
+			defer release()
+			resp, err := handler(ctx, req)
+			count(info.FullMethod, err)
+			return resp, err
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, release, err := grpcAdmit(stream.Context(), auth, limiter)
+			if err != nil {
+				count(info.FullMethod, err)
+				return err
+			}
+			defer release()
+			err = handler(srv, &grpcCallerStream{ServerStream: stream, ctx: ctx})
+			count(info.FullMethod, err)
+			return err
+		}),
+	)
+	validatorv1.RegisterValidatorServer(server, &grpcServer{n: n, namespaceLimiter: namespaceLimiter})
+	return server, nil
+}
+
+// startGRPCServer serves the gRPC service on GRPCAddress until the server
+// is stopped
+func (n *NGINXController) startGRPCServer(tlsConfig *tls.Config, auth *webhookAuth, limiter *validationLimiter, namespaceLimiter *namespaceRateLimiter) error {
+	server, err := n.newGRPCServer(tlsConfig, auth, limiter, namespaceLimiter)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", n.cfg.GRPCAddress)
+	if err != nil {
+		return err
+	}
+	n.grpcServer = server
+
+	go func() {
+		klog.Infof("Starting gRPC server on %v", n.cfg.GRPCAddress)
+		if err := n.grpcServer.Serve(listener); err != nil {
+			klog.Errorf("Error serving gRPC: %v", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	validatorv1 "github.com/jaskaransarkaria/nginx-ingress-validator/pkg/api/validator/v1"
+)
+
+// newGRPCTestClient serves the gRPC service of a controller of the manifests
+// whose token file allows the token "team-a" in the team-a namespace and the
+// token "admin" in all, and returns the controller and a client of the
+// service
+func newGRPCTestClient(t *testing.T, manifests string, namespaceLimiter *namespaceRateLimiter) (*NGINXController, validatorv1.ValidatorClient) {
+	t.Helper()
+
+	tokenFile := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(tokenFile, []byte("team-a team-a\nadmin\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	n := newTestController(t, manifests)
+	n.cfg.WebhookTokenFile = tokenFile
+	auth, err := newWebhookAuth(n.cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ca, err := newWebhookCA(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ca.issue([]string{"localhost", "nginx-config-validator.ingress-nginx.svc"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := n.newGRPCServer(&tls.Config{Certificates: []tls.Certificate{*cert}, MinVersion: tls.VersionTLS12}, auth, newValidationLimiter(1, 0, time.Second), namespaceLimiter)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.pem)
+	conn, err := grpc.NewClient("passthrough:///localhost",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: roots, ServerName: "localhost", MinVersion: tls.VersionTLS12})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return n, validatorv1.NewValidatorClient(conn)
+}
+
+// grpcTestContext returns a context sending the bearer token, if any
+func grpcTestContext(token string) context.Context {
+	if token == "" {
+		return context.Background()
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+// drain reads a server stream until its end
+func drain[T any](stream grpc.ServerStreamingClient[T], err error) (int, error) {
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return count, nil
+			}
+			return count, err
+		}
+		count++
+	}
+}
+
+func TestGRPCServerRequiresAuth(t *testing.T) {
+	n := newTestController(t, "")
+	if _, err := n.newGRPCServer(&tls.Config{MinVersion: tls.VersionTLS12}, nil, nil, nil); err == nil {
+		t.Errorf("expected the gRPC server to be refused without authentication")
+	}
+}
+
+func TestGRPCValidateIngresses(t *testing.T) {
+	_, client := newGRPCTestClient(t, "", nil)
+
+	tests := []struct {
+		name      string
+		token     string
+		namespace string
+		code      codes.Code
+	}{
+		{name: "namespace of the token", token: "team-a", namespace: "team-a", code: codes.OK},
+		{name: "other namespace", token: "team-a", namespace: "team-b", code: codes.PermissionDenied},
+		{name: "token of all namespaces", token: "admin", namespace: "team-b", code: codes.OK},
+		{name: "without token", namespace: "team-a", code: codes.Unauthenticated},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &validatorv1.ValidateIngressesRequest{Manifests: fmt.Sprintf(apiIngressManifest, tc.namespace, tc.namespace)}
+			_, err := drain(client.ValidateIngresses(grpcTestContext(tc.token), req))
+			if code := status.Code(err); code != tc.code {
+				t.Errorf("expected %v, got %v (%v)", tc.code, code, err)
+			}
+		})
+	}
+}
+
+func TestGRPCValidateIngressesFindings(t *testing.T) {
+	n, client := newGRPCTestClient(t, strings.ReplaceAll(preValidationManifests, "h2c", "cluster"), nil)
+	n.cfg.NginxVersion = "1.13.9"
+	ctx := grpcTestContext("admin")
+
+	receive := func(manifests string) ([]*validatorv1.Finding, error) {
+		stream, err := client.ValidateIngresses(ctx, &validatorv1.ValidateIngressesRequest{Manifests: manifests})
+		if err != nil {
+			return nil, err
+		}
+		var findings []*validatorv1.Finding
+		for {
+			f, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return findings, nil
+			}
+			if err != nil {
+				return findings, err
+			}
+			findings = append(findings, f)
+		}
+	}
+	h2c := func(findings []*validatorv1.Finding) []string {
+		ingresses := []string{}
+		for _, f := range findings {
+			if f.GetRule() == "h2c-nginx-version" {
+				ingresses = append(ingresses, f.GetIngress())
+			}
+		}
+		return ingresses
+	}
+
+	// the Ingresses of the manifests only
+	findings, err := receive(preValidationManifests)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := h2c(findings); len(got) != 1 || got[0] != "default/h2c" {
+		t.Errorf("expected the h2c finding of the request, got %v", findings)
+	}
+	for _, f := range findings {
+		if f.GetIngress() != "default/h2c" {
+			t.Errorf("expected only the findings of default/h2c, got %v", f)
+		}
+	}
+
+	// the Ingresses of the cluster
+	findings, err = receive("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := h2c(findings); len(got) != 1 || got[0] != "default/cluster" {
+		t.Errorf("expected the h2c finding of the cluster, got %v", findings)
+	}
+
+	if _, err := receive("kind: [Ingress\n"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected an invalid argument error, got %v", err)
+	}
+}
+
+func TestGRPCNamespaceRateLimit(t *testing.T) {
+	_, client := newGRPCTestClient(t, "", newNamespaceRateLimiter(0.001, 1))
+
+	req := &validatorv1.ValidateIngressesRequest{Manifests: fmt.Sprintf(apiIngressManifest, "team-a", "team-a")}
+	if _, err := drain(client.ValidateIngresses(grpcTestContext("team-a"), req)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err := drain(client.ValidateIngresses(grpcTestContext("team-a"), req))
+	if code := status.Code(err); code != codes.ResourceExhausted {
+		t.Errorf("expected %v once the namespace exceeded its rate, got %v (%v)", codes.ResourceExhausted, code, err)
+	}
+}
+
+func TestGRPCSummarizeConfig(t *testing.T) {
+	n, client := newGRPCTestClient(t, preValidationManifests, nil)
+	req := &validatorv1.SummarizeConfigRequest{Manifests: isolationManifests}
+
+	_, err := drain(client.SummarizeConfig(grpcTestContext("team-a"), req))
+	if code := status.Code(err); code != codes.PermissionDenied {
+		t.Errorf("expected the summary to be refused to a namespaced caller, got %v (%v)", code, err)
+	}
+
+	stream, err := client.SummarizeConfig(grpcTestContext("admin"), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var summary strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		summary.Write(chunk.GetData())
+	}
+
+	// the summary of the cluster with the objects of the request
+	var expected strings.Builder
+	s, err := n.requestStore(strings.NewReader(isolationManifests))
+	if err != nil {
+		t.Fatal(err)
+	}
+	offline := newOfflineController(n.cfg, s)
+	_, _, cfg := offline.getConfiguration(s.ListIngresses())
+	if err := offline.writeConfigSummary(&expected, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if summary.String() != expected.String() {
+		t.Errorf("expected\n%v\ngot\n%v", expected.String(), summary.String())
+	}
+	for _, server := range []string{"server_name h2c.example.com;", "server_name web.example.com;"} {
+		if !strings.Contains(summary.String(), server) {
+			t.Errorf("expected %q in the summary", server)
+		}
+	}
+}
+
+func TestGRPCExplainRoute(t *testing.T) {
+	_, client := newGRPCTestClient(t, isolationManifests, nil)
+	ctx := grpcTestContext("admin")
+
+	resp, err := client.ExplainRoute(ctx, &validatorv1.ExplainRouteRequest{Host: "web.example.com", Path: "/api/users"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.GetHost() != "web.example.com" || resp.GetLocation().GetPath() != "/api/" || resp.GetLocation().GetIngress() != "default/api" {
+		t.Errorf("expected the location /api/ of default/api, got %v", resp.GetLocation())
+	}
+	if !strings.Contains(resp.GetExplanation(), "web.example.com") {
+		t.Errorf("expected the explanation of the host, got %q", resp.GetExplanation())
+	}
+
+	testCases := map[string]struct {
+		token    string
+		req      *validatorv1.ExplainRouteRequest
+		expected codes.Code
+	}{
+		"no host":      {token: "admin", req: &validatorv1.ExplainRouteRequest{}, expected: codes.InvalidArgument},
+		"unknown host": {token: "admin", req: &validatorv1.ExplainRouteRequest{Host: "other.example.com"}, expected: codes.NotFound},
+		"invalid manifests": {
+			token:    "admin",
+			req:      &validatorv1.ExplainRouteRequest{Host: "web.example.com", Manifests: "kind: [Ingress\n"},
+			expected: codes.InvalidArgument,
+		},
+		"namespaced caller": {token: "team-a", req: &validatorv1.ExplainRouteRequest{Host: "web.example.com"}, expected: codes.PermissionDenied},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := client.ExplainRoute(grpcTestContext(tc.token), tc.req)
+			if status.Code(err) != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestGRPCAuthentication(t *testing.T) {
+	_, client := newGRPCTestClient(t, isolationManifests, nil)
+	req := &validatorv1.ExplainRouteRequest{Host: "web.example.com"}
+
+	testCases := map[string]struct {
+		token    string
+		expected codes.Code
+	}{
+		"no token":      {expected: codes.Unauthenticated},
+		"invalid token": {token: "other", expected: codes.PermissionDenied},
+		"token":         {token: "admin", expected: codes.OK},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := grpcTestContext(tc.token)
+
+			requests := expvarCount(grpcRequestsTotal, "ExplainRoute "+tc.expected.String())
+			_, err := client.ExplainRoute(ctx, req)
+			if status.Code(err) != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, err)
+			}
+			if got := expvarCount(grpcRequestsTotal, "ExplainRoute "+tc.expected.String()) - requests; got != 1 {
+				t.Errorf("expected the request to be counted, got %v", got)
+			}
+
+			// the streams are authenticated as well
+			_, err = drain(client.ValidateIngresses(ctx, &validatorv1.ValidateIngressesRequest{}))
+			if status.Code(err) != tc.expected {
+				t.Errorf("expected %v for the stream, got %v", tc.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+)
+
+const backendProtocolH2C = "H2C"
+
+// minGRPCNginxVersion is the first nginx version containing the grpc module,
+// required to talk HTTP/2 without TLS to upstreams
+var minGRPCNginxVersion = nginxVersion{Major: 1, Minor: 13, Patch: 10}
+
+// checkH2CBackends validates locations using cleartext HTTP/2 backends, and
+// locations sending HTTP/1.1 to Service ports that only accept h2c
+func (n *NGINXController) checkH2CBackends(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	version, hasVersion := n.targetNginxVersion()
+
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			protocol := strings.ToUpper(loc.BackendProtocol)
+
+			if protocol == backendProtocolH2C {
+				if hasVersion && !version.AtLeast(minGRPCNginxVersion) {
+					findings = append(findings, newLocationFinding("h2c-nginx-version", SeverityError, server, loc,
+						"location %q uses backend protocol H2C which requires nginx %v or newer (grpc_pass), target version is %v",
+						loc.Path, minGRPCNginxVersion, version))
+				}
+				if loc.ProxySSL.Secret != "" {
+					findings = append(findings, newLocationFinding("h2c-proxy-ssl", SeverityError, server, loc,
+						"location %q uses backend protocol H2C (cleartext) but also configures proxy-ssl-secret %q; use GRPCS for TLS backends",
+						loc.Path, loc.ProxySSL.Secret))
+				}
+				continue
+			}
+
+			if loc.Service == nil || (protocol != "" && protocol != "HTTP") {
+				continue
+			}
+			if servicePortAppProtocol(loc.Service, loc.Port) == "kubernetes.io/h2c" {
+				findings = append(findings, newLocationFinding("h2c-app-protocol", SeverityWarning, server, loc,
+					"port %v of Service %v/%v declares appProtocol kubernetes.io/h2c but location %q sends HTTP/1.1; set the backend-protocol annotation to H2C or GRPC",
+					loc.Port.String(), loc.Service.Namespace, loc.Service.Name, loc.Path))
+			}
+		}
+	}
+
+	return findings
+}
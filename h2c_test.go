@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+)
+
+const h2cManifests = `
+apiVersion: v1
+kind: Service
+metadata:
+  name: h2c
+  namespace: default
+spec:
+  ports:
+  - port: 80
+    appProtocol: kubernetes.io/h2c
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: http
+  namespace: default
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: http.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: h2c
+            port:
+              number: 80
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: h2c
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/backend-protocol: h2c
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: h2c.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: h2c
+            port:
+              number: 80
+`
+
+func TestCheckH2CBackends(t *testing.T) {
+	n, ingresses, cfg := testConfiguration(t, h2cManifests)
+	n.cfg.NginxVersion = "1.13.9"
+
+	findings := n.checkH2CBackends(ingresses, cfg)
+
+	appProtocol := findingsWithRule(findings, "h2c-app-protocol")
+	if len(appProtocol) != 1 || appProtocol[0].Host != "http.example.com" {
+		t.Errorf("expected one h2c-app-protocol finding for http.example.com, got %v", appProtocol)
+	}
+	version := findingsWithRule(findings, "h2c-nginx-version")
+	if len(version) != 1 || version[0].Host != "h2c.example.com" {
+		t.Errorf("expected one h2c-nginx-version finding for h2c.example.com, got %v", version)
+	}
+}
+
+func TestParseNginxVersion(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected nginxVersion
+		err      bool
+	}{
+		{version: "1.25.3", expected: nginxVersion{1, 25, 3}},
+		{version: "nginx/1.21.6", expected: nginxVersion{1, 21, 6}},
+		{version: "1.19", expected: nginxVersion{1, 19, 0}},
+		{version: "1", err: true},
+		{version: "1.x.0", err: true},
+		{version: "1.2.3.4", err: true},
+	}
+
+	for _, tc := range tests {
+		v, err := parseNginxVersion(tc.version)
+		if tc.err != (err != nil) {
+			t.Errorf("%q: unexpected error %v", tc.version, err)
+			continue
+		}
+		if v != tc.expected {
+			t.Errorf("%q: expected %v, got %v", tc.version, tc.expected, v)
+		}
+	}
+
+	if !(nginxVersion{1, 13, 10}).AtLeast(minGRPCNginxVersion) || (nginxVersion{1, 13, 9}).AtLeast(minGRPCNginxVersion) {
+		t.Errorf("expected 1.13.10 to be the first version supporting grpc_pass")
+	}
+}
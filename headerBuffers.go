@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+)
+
+// sizes of the headers usually sent on hosts using external authentication
+// (session cookies and JWTs) or client certificate pass-through
+const (
+	authHeaderBufferSize = 16 * 1024
+	authProxyBufferSize  = 16 * 1024
+	clientCertHeaderSize = 8 * 1024
+)
+
+// checkHeaderBuffers reports hosts carrying big authentication headers whose
+// buffers are too small, which makes nginx reject the requests with 400
+// (431 over HTTP/2) or fail reading the response of the authentication service
+func (n *NGINXController) checkHeaderBuffers(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	global := n.store.GetBackendConfiguration()
+
+	_, largeBufferSize, err := parseNginxBuffers(global.LargeClientHeaderBuffers)
+	if err != nil {
+		findings = append(findings, Finding{
+			Rule:     "header-buffers-invalid",
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("invalid large-client-header-buffers value: %v", err),
+		})
+		return findings
+	}
+
+	for _, server := range cfg.Servers {
+		reportedClient := false
+		for _, loc := range server.Locations {
+			if loc.ExternalAuth.URL != "" && !reportedClient && largeBufferSize < authHeaderBufferSize {
+				reportedClient = true
+				findings = append(findings, newLocationFinding("header-buffers-client", SeverityWarning, server, loc,
+					"location %q uses external authentication, whose session cookies and tokens usually exceed the large-client-header-buffers size of %v; requests will fail with 400 (431 over HTTP/2), consider at least %v",
+					loc.Path, formatNginxSize(largeBufferSize), formatNginxSize(authHeaderBufferSize)))
+			}
+
+			if loc.ExternalAuth.URL != "" && len(loc.ExternalAuth.ResponseHeaders) > 0 {
+				bufferSize := loc.Proxy.BufferSize
+				if bufferSize == "" {
+					bufferSize = global.ProxyBufferSize
+				}
+				size, err := parseNginxSize(bufferSize)
+				if err == nil && size < authProxyBufferSize {
+					findings = append(findings, newLocationFinding("header-buffers-auth-response", SeverityWarning, server, loc,
+						"location %q copies headers %v from the authentication response but proxy-buffer-size is %v; responses with tokens bigger than the buffer fail with \"upstream sent too big header\"",
+						loc.Path, loc.ExternalAuth.ResponseHeaders, bufferSize))
+				}
+			}
+		}
+
+		if server.CertificateAuth.PassCertToUpstream {
+			findings = append(findings, Finding{
+				Rule:     "header-buffers-client-cert",
+				Severity: SeverityInfo,
+				Host:     server.Hostname,
+				Message: fmt.Sprintf("the client certificate is passed to the backends in the ssl-client-cert header, which can exceed %v with intermediate certificates; backends with smaller header limits answer 431",
+					formatNginxSize(clientCertHeaderSize)),
+			})
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+const headerBuffersManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+data:
+  large-client-header-buffers: %q
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/auth-url: http://auth.default.svc.cluster.local/verify
+    nginx.ingress.kubernetes.io/auth-response-headers: X-Auth-Token
+    nginx.ingress.kubernetes.io/proxy-buffer-size: %v
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`
+
+func TestCheckHeaderBuffers(t *testing.T) {
+	tests := []struct {
+		name            string
+		headerBuffers   string
+		proxyBufferSize string
+		expected        []string
+	}{
+		{
+			name:            "default buffers",
+			headerBuffers:   "4 8k",
+			proxyBufferSize: "4k",
+			expected:        []string{"header-buffers-client", "header-buffers-auth-response"},
+		},
+		{
+			name:            "large buffers",
+			headerBuffers:   "4 16k",
+			proxyBufferSize: "16k",
+		},
+		{
+			name:            "invalid buffers",
+			headerBuffers:   "16k",
+			proxyBufferSize: "16k",
+			expected:        []string{"header-buffers-invalid"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, fmt.Sprintf(headerBuffersManifests, tc.headerBuffers, tc.proxyBufferSize))
+
+			rules := []string{}
+			for _, f := range n.checkHeaderBuffers(ingresses, cfg) {
+				rules = append(rules, f.Rule)
+			}
+			if fmt.Sprint(rules) != fmt.Sprint(tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, rules)
+			}
+		})
+	}
+}
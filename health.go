@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// healthzPath answers 200 while the process is running
+const healthzPath = "/healthz"
+
+// loadWebhookCertificate loads the certificate of the webhook and records it
+// for the readiness checks
+func (n *NGINXController) loadWebhookCertificate() (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(n.cfg.ValidationWebhookCertPath, n.cfg.ValidationWebhookKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading webhook certificate: %w", err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, fmt.Errorf("error parsing webhook certificate: %w", err)
+		}
+	}
+
+	n.webhookCertificate.Store(&cert)
+	return &cert, nil
+}
+
+// webhookCertificateError returns why the webhook certificate can not be
+// used, nil when it is loaded and valid
+func (n *NGINXController) webhookCertificateError(now time.Time) error {
+	cert := n.webhookCertificate.Load()
+	switch {
+	case cert == nil:
+		return errors.New("webhook certificate not loaded")
+	case now.After(cert.Leaf.NotAfter):
+		return fmt.Errorf("webhook certificate expired on %v", cert.Leaf.NotAfter.Format(time.RFC3339))
+	case now.Before(cert.Leaf.NotBefore):
+		return fmt.Errorf("webhook certificate is not valid before %v", cert.Leaf.NotBefore.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// addHealthCheckFlags registers the flags of the health checks of the
+// webhook. They are distinct from --controller-healthz-port, the port of the
+// ingress controller being validated.
+func addHealthCheckFlags(fs *flag.FlagSet, cfg *NginxConfiguration) {
+	fs.StringVar(&cfg.HealthCheckHost, "healthz-host", "", "Address the health checks listen on, all addresses when empty.")
+	fs.IntVar(&cfg.HealthCheckPort, "healthz-port", 10254, "Port of the plain HTTP health checks ("+healthzPath+" and "+readyzPath+"). 0 disables them.")
+}
+
+// healthzHandler reports the process as alive
+func (n *NGINXController) healthzHandler(w http.ResponseWriter, _ *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// startHealthServer serves healthzPath and readyzPath over plain HTTP on
+// HealthCheckHost and HealthCheckPort, for the probes of the kubelet
+func (n *NGINXController) startHealthServer() {
+	mux := http.NewServeMux()
+	mux.HandleFunc(healthzPath, n.healthzHandler)
+	mux.HandleFunc(readyzPath, n.readyzHandler)
+
+	server := &http.Server{
+		Addr:              net.JoinHostPort(n.cfg.HealthCheckHost, strconv.Itoa(n.cfg.HealthCheckPort)),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-n.stopCh
+		_ = server.Close()
+	}()
+
+	klog.Infof("Starting health checks on %v", server.Addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		klog.Errorf("Error serving health checks: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"flag"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadTestWebhookCertificate loads a webhook certificate valid between
+// notBefore and notAfter
+func loadTestWebhookCertificate(t *testing.T, n *NGINXController, notBefore, notAfter time.Time) {
+	t.Helper()
+
+	_, _, certPEM, keyPEM := testCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "validator.ingress-nginx.svc"},
+		DNSNames:     []string{"validator.ingress-nginx.svc"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}, nil, nil)
+
+	dir := t.TempDir()
+	n.cfg.ValidationWebhookCertPath = filepath.Join(dir, "tls.crt")
+	n.cfg.ValidationWebhookKeyPath = filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(n.cfg.ValidationWebhookCertPath, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(n.cfg.ValidationWebhookKeyPath, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.loadWebhookCertificate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebhookCertificateError(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name                string
+		notBefore, notAfter time.Time
+		expected            string
+	}{
+		{"valid", now.Add(-time.Hour), now.Add(time.Hour), ""},
+		{"expired", now.Add(-2 * time.Hour), now.Add(-time.Hour), "webhook certificate expired on"},
+		{"not yet valid", now.Add(time.Hour), now.Add(2 * time.Hour), "webhook certificate is not valid before"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n := newTestController(t, preValidationManifests)
+			n.preValidate()
+			loadTestWebhookCertificate(t, n, tc.notBefore, tc.notAfter)
+
+			err := n.webhookCertificateError(now)
+			if (tc.expected == "") != (err == nil) || (err != nil && !strings.HasPrefix(err.Error(), tc.expected)) {
+				t.Errorf("expected %q, got %v", tc.expected, err)
+			}
+
+			rec := httptest.NewRecorder()
+			n.readyzHandler(rec, httptest.NewRequest(http.MethodGet, readyzPath, nil))
+			expected := http.StatusOK
+			if tc.expected != "" {
+				expected = http.StatusServiceUnavailable
+			}
+			if rec.Code != expected || !strings.Contains(rec.Body.String(), tc.expected) {
+				t.Errorf("expected readyz to answer %d, got %d %q", expected, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestWebhookCertificateNotLoaded(t *testing.T) {
+	n := newTestController(t, preValidationManifests)
+	n.preValidate()
+	n.cfg.ValidationWebhookCertPath = filepath.Join(t.TempDir(), "missing.crt")
+
+	if _, err := n.loadWebhookCertificate(); err == nil {
+		t.Errorf("expected an error loading a missing certificate")
+	}
+	rec := httptest.NewRecorder()
+	n.readyzHandler(rec, httptest.NewRequest(http.MethodGet, readyzPath, nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "webhook certificate not loaded") {
+		t.Errorf("expected the webhook not to be ready, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	n.healthzHandler(rec, httptest.NewRequest(http.MethodGet, healthzPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the process to be alive, got %d", rec.Code)
+	}
+}
+
+func TestHealthCheckFlags(t *testing.T) {
+	fs := flag.NewFlagSet("webhook", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg := &NginxConfiguration{}
+	addConfigurationFlags(fs, cfg)
+	addHealthCheckFlags(fs, cfg)
+
+	if err := fs.Parse([]string{"--healthz-port=8080", "--controller-healthz-port=10254"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.HealthCheckPort != 8080 {
+		t.Errorf("expected the health checks of the webhook on 8080, got %d", cfg.HealthCheckPort)
+	}
+	if cfg.ListenPorts.Health != 10254 {
+		t.Errorf("expected the validated health port of the ingress controller to be kept, got %d", cfg.ListenPorts.Health)
+	}
+}
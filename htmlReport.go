@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// certificateExpiryWarning is the remaining validity under which a
+// certificate is highlighted in the HTML report
+const certificateExpiryWarning = 30 * 24 * time.Hour
+
+// htmlReport is the data of the HTML report of a validation
+type htmlReport struct {
+	Generated    time.Time
+	Hosts        []htmlHost
+	Certificates []htmlCertificate
+	Groups       []htmlFindingGroup
+	// Compared is set when the input was validated against the cluster and
+	// Diff lists the route changes
+	Compared bool
+	Diff     []htmlRouteChange
+}
+
+// htmlHost summarizes a server of the configuration
+type htmlHost struct {
+	Hostname  string
+	Ingresses string
+	Locations int
+	TLSSecret string
+	Errors    int
+	Warnings  int
+}
+
+// htmlCertificate describes the certificate served for a host
+type htmlCertificate struct {
+	Host     string
+	Secret   string
+	Subject  string
+	Issuer   string
+	NotAfter string
+	DaysLeft int
+	// Status is ok, expiring, expired or the reason no certificate is served
+	Status string
+}
+
+// htmlFindingGroup lists the findings of one severity
+type htmlFindingGroup struct {
+	Severity Severity
+	Findings []Finding
+}
+
+// htmlRouteChange is a location added, removed or routed to another backend
+// compared to the cluster
+type htmlRouteChange struct {
+	Host     string
+	Location string
+	Change   string
+	Before   string
+	After    string
+}
+
+// newHTMLReport gathers the data of the HTML report. before is the
+// configuration built from the cluster alone, nil when not available.
+func (n *NGINXController) newHTMLReport(ingresses []*Ingress, cfg, before *Configuration, findings []Finding, now time.Time) *htmlReport {
+	r := &htmlReport{Generated: now.UTC()}
+
+	perHost := map[string]map[Severity]int{}
+	groups := map[Severity][]Finding{}
+	for _, f := range findings {
+		groups[f.Severity] = append(groups[f.Severity], f)
+		if f.Host == "" {
+			continue
+		}
+		if perHost[f.Host] == nil {
+			perHost[f.Host] = map[Severity]int{}
+		}
+		perHost[f.Host][f.Severity]++
+	}
+	for _, severity := range []Severity{SeverityError, SeverityWarning, SeverityInfo} {
+		if len(groups[severity]) > 0 {
+			r.Groups = append(r.Groups, htmlFindingGroup{Severity: severity, Findings: groups[severity]})
+		}
+	}
+
+	secrets := tlsSecretsByHost(ingresses)
+	for _, server := range cfg.Servers {
+		if server.Hostname == "_" {
+			continue
+		}
+
+		names := map[string]bool{}
+		for _, loc := range server.Locations {
+			if loc.Ingress != nil {
+				names[k8s.MetaNamespaceKey(loc.Ingress)] = true
+			}
+		}
+		r.Hosts = append(r.Hosts, htmlHost{
+			Hostname:  server.Hostname,
+			Ingresses: strings.Join(sortedSet(names), ", "),
+			Locations: len(server.Locations),
+			TLSSecret: secrets[server.Hostname],
+			Errors:    perHost[server.Hostname][SeverityError],
+			Warnings:  perHost[server.Hostname][SeverityWarning],
+		})
+
+		secretKey, ok := secrets[server.Hostname]
+		if !ok {
+			continue
+		}
+		c := htmlCertificate{Host: server.Hostname, Secret: secretKey}
+		chain, reason := n.servedCertificate(secretKey, server.Hostname)
+		if chain == nil {
+			c.Status = reason
+		} else {
+			leaf := chain[0]
+			c.Subject = leaf.Subject.String()
+			c.Issuer = leaf.Issuer.String()
+			c.NotAfter = leaf.NotAfter.UTC().Format(time.RFC3339)
+			c.DaysLeft = int(leaf.NotAfter.Sub(now).Hours() / 24)
+			switch {
+			case now.After(leaf.NotAfter):
+				c.Status = "expired"
+			case leaf.NotAfter.Sub(now) < certificateExpiryWarning:
+				c.Status = "expiring"
+			default:
+				c.Status = "ok"
+			}
+		}
+		r.Certificates = append(r.Certificates, c)
+	}
+	sort.Slice(r.Hosts, func(i, j int) bool { return r.Hosts[i].Hostname < r.Hosts[j].Hostname })
+	sort.Slice(r.Certificates, func(i, j int) bool { return r.Certificates[i].Host < r.Certificates[j].Host })
+
+	if before != nil {
+		r.Compared = true
+		r.Diff = routeChanges(before, cfg)
+	}
+	return r
+}
+
+// routeChanges compares the locations and backends of two configurations
+func routeChanges(before, after *Configuration) []htmlRouteChange {
+	routes := func(cfg *Configuration) map[string]map[string]string {
+		hosts := map[string]map[string]string{}
+		for _, server := range cfg.Servers {
+			if server.Hostname == "_" {
+				continue
+			}
+			hosts[server.Hostname] = map[string]string{}
+			enforceRegex := enforceRegexModifier(server.Locations)
+			for _, loc := range server.Locations {
+				hosts[server.Hostname][conformanceLocationKey(loc, enforceRegex)] = loc.Backend
+			}
+		}
+		return hosts
+	}
+	old, current := routes(before), routes(after)
+
+	hosts := map[string]bool{}
+	for host := range old {
+		hosts[host] = true
+	}
+	for host := range current {
+		hosts[host] = true
+	}
+
+	changes := []htmlRouteChange{}
+	for _, host := range sortedSet(hosts) {
+		locations := map[string]bool{}
+		for key := range old[host] {
+			locations[key] = true
+		}
+		for key := range current[host] {
+			locations[key] = true
+		}
+
+		for _, key := range sortedSet(locations) {
+			was, hadIt := old[host][key]
+			is, hasIt := current[host][key]
+			change := htmlRouteChange{Host: host, Location: key, Before: was, After: is}
+			switch {
+			case !hadIt:
+				change.Change = "added"
+			case !hasIt:
+				change.Change = "removed"
+			case was != is:
+				change.Change = "changed"
+			default:
+				continue
+			}
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// clusterConfiguration builds the configuration of the cluster state alone,
+// to compare the input with
+func clusterConfiguration(cfg *NginxConfiguration) (*Configuration, error) {
+	s := newMemoryStore(cfg.ConfigMapName)
+	if err := loadClusterState(context.Background(), cfg.Client, s); err != nil {
+		return nil, fmt.Errorf("error reading cluster state: %w", err)
+	}
+	_, _, configuration := newOfflineController(cfg, s).getConfiguration(s.ListIngresses())
+	return configuration, nil
+}
+
+// writeHTMLReport writes the report as a self-contained HTML page
+func writeHTMLReport(w io.Writer, r *htmlReport) error {
+	return htmlReportTemplate.Execute(w, r)
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>nginx-config-validator report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+.error, .expired, .removed { background: #fdd; }
+.warning, .expiring, .changed { background: #ffd; }
+.added { background: #dfd; }
+code { font-size: 90%; }
+</style>
+</head>
+<body>
+<h1>nginx-config-validator report</h1>
+<p>Generated {{ .Generated.Format "2006-01-02 15:04:05 MST" }}</p>
+
+<h2>Hosts</h2>
+{{ if .Hosts }}<table>
+<tr><th>Host</th><th>Ingresses</th><th>Locations</th><th>TLS Secret</th><th>Errors</th><th>Warnings</th></tr>
+{{ range .Hosts }}<tr{{ if .Errors }} class="error"{{ else if .Warnings }} class="warning"{{ end }}>
+<td>{{ .Hostname }}</td><td>{{ .Ingresses }}</td><td>{{ .Locations }}</td><td>{{ .TLSSecret }}</td><td>{{ .Errors }}</td><td>{{ .Warnings }}</td>
+</tr>
+{{ end }}</table>{{ else }}<p>No hosts.</p>{{ end }}
+
+<h2>Certificates</h2>
+{{ if .Certificates }}<table>
+<tr><th>Host</th><th>Secret</th><th>Subject</th><th>Issuer</th><th>Expires</th><th>Days left</th><th>Status</th></tr>
+{{ range .Certificates }}<tr class="{{ .Status }}">
+<td>{{ .Host }}</td><td>{{ .Secret }}</td><td>{{ .Subject }}</td><td>{{ .Issuer }}</td><td>{{ .NotAfter }}</td><td>{{ if .NotAfter }}{{ .DaysLeft }}{{ end }}</td><td>{{ .Status }}</td>
+</tr>
+{{ end }}</table>{{ else }}<p>No host uses a TLS Secret.</p>{{ end }}
+
+<h2>Findings</h2>
+{{ range .Groups }}<h3>{{ .Severity }} ({{ len .Findings }})</h3>
+<table>
+<tr><th>Rule</th><th>Ingress</th><th>Host</th><th>Message</th></tr>
+{{ range .Findings }}<tr class="{{ .Severity }}">
+<td><code>{{ .Rule }}</code></td><td>{{ .Ingress }}</td><td>{{ .Host }}</td><td>{{ .Message }}</td>
+</tr>
+{{ end }}</table>
+{{ else }}<p>No findings.</p>
+{{ end }}
+<h2>Changes compared to the cluster</h2>
+{{ if not .Compared }}<p>Run with --against-cluster to compare the routes with the cluster.</p>
+{{ else if .Diff }}<table>
+<tr><th>Host</th><th>Location</th><th>Change</th><th>Backend before</th><th>Backend after</th></tr>
+{{ range .Diff }}<tr class="{{ .Change }}">
+<td>{{ .Host }}</td><td><code>{{ .Location }}</code></td><td>{{ .Change }}</td><td>{{ .Before }}</td><td>{{ .After }}</td>
+</tr>
+{{ end }}</table>
+{{ else }}<p>No route changes.</p>
+{{ end }}</body>
+</html>
+`))
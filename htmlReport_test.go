@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const htmlReportManifests = issuerIngress + `
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: api
+  namespace: default
+spec:
+  ingressClassName: nginx
+  tls:
+  - hosts: [api.example.com]
+    secretName: api-tls
+  rules:
+  - host: api.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: api
+            port:
+              number: 80
+`
+
+func TestNewHTMLReport(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	_, _, certPEM, keyPEM := testCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "web.example.com"},
+		DNSNames:     []string{"web.example.com"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(10*24*time.Hour + time.Hour),
+	}, nil, nil)
+
+	n := newTestController(t, htmlReportManifests)
+	if err := n.store.(*memoryStore).Add(&apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-tls", Namespace: "default"},
+		Type:       apiv1.SecretTypeTLS,
+		Data:       map[string][]byte{apiv1.TLSCertKey: certPEM, apiv1.TLSPrivateKeyKey: keyPEM},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ingresses := n.store.ListIngresses()
+	_, _, cfg := n.getConfiguration(ingresses)
+
+	findings := []Finding{
+		{Rule: "cors-permissive", Severity: SeverityWarning, Ingress: "default/web", Host: "web.example.com"},
+		{Rule: "h2c-nginx-version", Severity: SeverityError, Ingress: "default/api", Host: "api.example.com"},
+		{Rule: "configmap-invalid", Severity: SeverityError},
+	}
+	r := n.newHTMLReport(ingresses, cfg, nil, findings, now)
+
+	expectedHosts := []htmlHost{
+		{Hostname: "api.example.com", Ingresses: "default/api", Locations: 1, TLSSecret: "default/api-tls", Errors: 1},
+		{Hostname: "web.example.com", Ingresses: "default/web", Locations: 1, TLSSecret: "default/web-tls", Warnings: 1},
+	}
+	if !reflect.DeepEqual(r.Hosts, expectedHosts) {
+		t.Errorf("expected hosts %+v, got %+v", expectedHosts, r.Hosts)
+	}
+
+	expectedCertificates := []htmlCertificate{
+		{Host: "api.example.com", Secret: "default/api-tls", Status: "TLS Secret default/api-tls does not exist"},
+		{
+			Host:     "web.example.com",
+			Secret:   "default/web-tls",
+			Subject:  "CN=web.example.com",
+			Issuer:   "CN=web.example.com",
+			NotAfter: "2026-03-11T13:00:00Z",
+			DaysLeft: 10,
+			Status:   "expiring",
+		},
+	}
+	if !reflect.DeepEqual(r.Certificates, expectedCertificates) {
+		t.Errorf("expected certificates %+v, got %+v", expectedCertificates, r.Certificates)
+	}
+
+	if len(r.Groups) != 2 || r.Groups[0].Severity != SeverityError || len(r.Groups[0].Findings) != 2 || r.Groups[1].Severity != SeverityWarning {
+		t.Errorf("expected the findings grouped by severity, got %+v", r.Groups)
+	}
+	if r.Compared {
+		t.Errorf("expected no comparison without the cluster configuration")
+	}
+}
+
+func TestRouteChanges(t *testing.T) {
+	_, _, before := testConfiguration(t, issuerIngress)
+	_, _, after := testConfiguration(t, strings.ReplaceAll(htmlReportManifests, "name: web\n            port", "name: web-v2\n            port"))
+
+	expected := []htmlRouteChange{
+		{Host: "api.example.com", Location: "/", Change: "added", After: "default-api-80"},
+		{Host: "web.example.com", Location: "/", Change: "changed", Before: "default-web-80", After: "default-web-v2-80"},
+	}
+	if changes := routeChanges(before, after); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected %+v, got %+v", expected, changes)
+	}
+
+	expected = []htmlRouteChange{
+		{Host: "api.example.com", Location: "/", Change: "removed", Before: "default-api-80"},
+		{Host: "web.example.com", Location: "/", Change: "changed", Before: "default-web-v2-80", After: "default-web-80"},
+	}
+	if changes := routeChanges(after, before); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected %+v, got %+v", expected, changes)
+	}
+}
+
+func TestWriteHTMLReport(t *testing.T) {
+	r := &htmlReport{
+		Generated: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Groups: []htmlFindingGroup{{Severity: SeverityError, Findings: []Finding{
+			{Rule: "snippet-risk", Severity: SeverityError, Ingress: "default/web", Message: "snippet contains <script>"},
+		}}},
+		Compared: true,
+	}
+
+	var out bytes.Buffer
+	if err := writeHTMLReport(&out, r); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"Generated 2026-03-01 12:00:00 UTC",
+		"<p>No hosts.</p>",
+		"<h3>error (1)</h3>",
+		"snippet contains &lt;script&gt;",
+		"<p>No route changes.</p>",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected the report to contain %q", expected)
+		}
+	}
+}
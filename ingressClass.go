@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/controller/ingressclass"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+const defaultIngressClassAnnotation = "ingressclass.kubernetes.io/is-default-class"
+
+// ingressClassMatches returns true if the Ingress is handled by a controller
+// using the class configuration, using spec.ingressClassName or the legacy
+// kubernetes.io/ingress.class annotation
+func (n *NGINXController) ingressClassMatches(ing *Ingress, icConfig *ingressclass.Configuration) bool {
+	if ing.Spec.IngressClassName != nil {
+		name := *ing.Spec.IngressClassName
+		if icConfig.IngressClassByName && name == icConfig.AnnotationValue {
+			return true
+		}
+		ic, err := n.store.GetIngressClass(name)
+		if err != nil {
+			return false
+		}
+		return ic.Spec.Controller == icConfig.Controller
+	}
+
+	if class, ok := ing.GetAnnotations()[ingressclass.IngressKey]; ok {
+		return !icConfig.IgnoreIngressClass && class == icConfig.AnnotationValue
+	}
+
+	return icConfig.WatchWithoutClass
+}
+
+// hasIngressClass returns true if the Ingress defines a class
+func hasIngressClass(ing *Ingress) bool {
+	if ing.Spec.IngressClassName != nil {
+		return true
+	}
+	_, ok := ing.GetAnnotations()[ingressclass.IngressKey]
+	return ok
+}
+
+// defaultIngressClass returns the IngressClass marked as default, which the
+// API server assigns to new Ingresses without class
+func (n *NGINXController) defaultIngressClass() *networking.IngressClass {
+	for _, ic := range n.store.ListIngressClasses() {
+		if ic.Annotations[defaultIngressClassAnnotation] == "true" {
+			return ic
+		}
+	}
+	return nil
+}
+
+// filterIngressesByClass returns the Ingresses handled by this controller
+// according to IngressClassConfiguration, and findings for the Ingresses
+// without class that no controller will handle
+func (n *NGINXController) filterIngressesByClass(ingresses []*Ingress) ([]*Ingress, []Finding) {
+	findings := []Finding{}
+	icConfig := n.cfg.IngressClassConfiguration
+	if icConfig == nil {
+		return ingresses, findings
+	}
+
+	defaultClass := n.defaultIngressClass()
+	filtered := make([]*Ingress, 0, len(ingresses))
+	for _, ing := range ingresses {
+		if n.ingressClassMatches(ing, icConfig) {
+			filtered = append(filtered, ing)
+			continue
+		}
+
+		if hasIngressClass(ing) || icConfig.WatchWithoutClass {
+			continue
+		}
+
+		if defaultClass != nil {
+			if defaultClass.Spec.Controller == icConfig.Controller {
+				// the API server sets the default class when the Ingress is created
+				filtered = append(filtered, ing)
+			}
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Rule:     "ingress-class-missing",
+			Severity: SeverityWarning,
+			Ingress:  k8s.MetaNamespaceKey(ing),
+			Message: fmt.Sprintf("Ingress has no class and there is no default IngressClass; it is ignored by the controller (set spec.ingressClassName to %q)",
+				icConfig.AnnotationValue),
+		})
+	}
+
+	return filtered, findings
+}
+
+// simulateIngressClasses builds the list of hosts handled by a controller
+// instance for each of the simulated classes, and reports hosts served by
+// more than one instance: only one of them receives the traffic, depending on
+// which load balancer the DNS record points to
+func (n *NGINXController) simulateIngressClasses(ingresses []*Ingress) []Finding {
+	findings := []Finding{}
+	if len(n.cfg.SimulatedIngressClasses) == 0 {
+		return findings
+	}
+
+	hostClasses := map[string]map[string][]string{}
+	for _, class := range n.cfg.SimulatedIngressClasses {
+		icConfig := &ingressclass.Configuration{
+			Controller:         ingressclass.DefaultControllerName,
+			AnnotationValue:    class,
+			IngressClassByName: true,
+		}
+		if ic, err := n.store.GetIngressClass(class); err == nil {
+			icConfig.Controller = ic.Spec.Controller
+		}
+
+		for _, ing := range ingresses {
+			if !n.ingressClassMatches(ing, icConfig) {
+				continue
+			}
+			for _, rule := range ing.Spec.Rules {
+				if rule.Host == "" {
+					continue
+				}
+				if hostClasses[rule.Host] == nil {
+					hostClasses[rule.Host] = map[string][]string{}
+				}
+				hostClasses[rule.Host][class] = append(hostClasses[rule.Host][class], k8s.MetaNamespaceKey(ing))
+			}
+		}
+	}
+
+	for host, classes := range hostClasses {
+		if len(classes) < 2 {
+			continue
+		}
+
+		names := make([]string, 0, len(classes))
+		for class := range classes {
+			names = append(names, class)
+		}
+		sort.Strings(names)
+
+		details := make([]string, 0, len(names))
+		for _, class := range names {
+			details = append(details, fmt.Sprintf("%v (%v)", class, strings.Join(classes[class], ", ")))
+		}
+
+		findings = append(findings, Finding{
+			Rule:     "ingress-class-host-collision",
+			Severity: SeverityError,
+			Ingress:  classes[names[0]][0],
+			Host:     host,
+			Message: fmt.Sprintf("host is served by several ingress controllers: %v; only the one the DNS record points to receives traffic",
+				strings.Join(details, ", ")),
+		})
+	}
+
+	return findings
+}
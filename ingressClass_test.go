@@ -0,0 +1,136 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/controller/ingressclass"
+)
+
+const ingressClassManifests = `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: nginx
+  namespace: default
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: internal
+  namespace: default
+  annotations:
+    kubernetes.io/ingress.class: internal
+spec:
+  rules:
+  - host: web.example.com
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: no-class
+  namespace: default
+spec:
+  rules:
+  - host: other.example.com
+`
+
+// ingressClass returns an IngressClass of the controller
+func ingressClass(name, controller string, isDefault bool) *networking.IngressClass {
+	ic := &networking.IngressClass{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       networking.IngressClassSpec{Controller: controller},
+	}
+	if isDefault {
+		ic.Annotations = map[string]string{defaultIngressClassAnnotation: "true"}
+	}
+	return ic
+}
+
+func TestFilterIngressesByClass(t *testing.T) {
+	tests := []struct {
+		name     string
+		classes  []*networking.IngressClass
+		config   ingressclass.Configuration
+		expected []string
+		findings int
+	}{
+		{
+			name:     "class name",
+			config:   ingressclass.Configuration{Controller: ingressclass.DefaultControllerName, AnnotationValue: "nginx", IngressClassByName: true},
+			expected: []string{"default/nginx"},
+			findings: 1,
+		},
+		{
+			name:     "IngressClass controller",
+			classes:  []*networking.IngressClass{ingressClass("nginx", ingressclass.DefaultControllerName, false)},
+			config:   ingressclass.Configuration{Controller: ingressclass.DefaultControllerName, AnnotationValue: "nginx"},
+			expected: []string{"default/nginx"},
+			findings: 1,
+		},
+		{
+			name:     "legacy annotation",
+			config:   ingressclass.Configuration{Controller: ingressclass.DefaultControllerName, AnnotationValue: "internal"},
+			expected: []string{"default/internal"},
+			findings: 1,
+		},
+		{
+			name:     "watch without class",
+			config:   ingressclass.Configuration{Controller: ingressclass.DefaultControllerName, AnnotationValue: "internal", WatchWithoutClass: true},
+			expected: []string{"default/internal", "default/no-class"},
+		},
+		{
+			name:     "default class of the controller",
+			classes:  []*networking.IngressClass{ingressClass("nginx", ingressclass.DefaultControllerName, true)},
+			config:   ingressclass.Configuration{Controller: ingressclass.DefaultControllerName, AnnotationValue: "nginx"},
+			expected: []string{"default/nginx", "default/no-class"},
+		},
+		{
+			name:     "default class of another controller",
+			classes:  []*networking.IngressClass{ingressClass("traefik", "traefik.io/ingress-controller", true)},
+			config:   ingressclass.Configuration{Controller: ingressclass.DefaultControllerName, AnnotationValue: "nginx", IngressClassByName: true},
+			expected: []string{"default/nginx"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n := newTestController(t, ingressClassManifests)
+			for _, ic := range tc.classes {
+				if err := n.store.(*memoryStore).Add(ic); err != nil {
+					t.Fatal(err)
+				}
+			}
+			n.cfg.IngressClassConfiguration = &tc.config
+
+			filtered, findings := n.filterIngressesByClass(n.store.ListIngresses())
+			got := []string{}
+			for _, ing := range filtered {
+				got = append(got, ing.Namespace+"/"+ing.Name)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+			if missing := findingsWithRule(findings, "ingress-class-missing"); len(missing) != tc.findings {
+				t.Errorf("expected %d ingress-class-missing findings, got %v", tc.findings, missing)
+			}
+		})
+	}
+}
+
+func TestSimulateIngressClasses(t *testing.T) {
+	n := newTestController(t, ingressClassManifests)
+	n.cfg.SimulatedIngressClasses = []string{"nginx", "internal"}
+
+	findings := findingsWithRule(n.simulateIngressClasses(n.store.ListIngresses()), "ingress-class-host-collision")
+	if len(findings) != 1 || findings[0].Host != "web.example.com" || findings[0].Ingress != "default/internal" {
+		t.Errorf("expected a collision on web.example.com, got %v", findings)
+	}
+}
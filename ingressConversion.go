@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+
+	networking "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// deprecatedIngressAPIVersions are the Ingress API versions removed in
+// Kubernetes 1.22, converted to networking.k8s.io/v1 when loading manifests.
+// extensions/v1beta1 Ingresses have the same schema as the
+// networking.k8s.io/v1beta1 ones.
+var deprecatedIngressAPIVersions = map[string]bool{
+	"networking.k8s.io/v1beta1": true,
+	"extensions/v1beta1":        true,
+}
+
+// convertIngress decodes a v1beta1 Ingress and converts it to
+// networking.k8s.io/v1 as the API server did
+func convertIngress(raw []byte) (*networking.Ingress, error) {
+	old := &networkingv1beta1.Ingress{}
+	if err := checkSchema(raw, old, "Ingress"); err != nil {
+		return nil, err
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: old.ObjectMeta,
+	}
+	ing.APIVersion = networking.SchemeGroupVersion.String()
+	ing.Kind = "Ingress"
+	ing.Spec.IngressClassName = old.Spec.IngressClassName
+	ing.Spec.DefaultBackend = convertIngressBackend(old.Spec.Backend)
+
+	for _, tls := range old.Spec.TLS {
+		ing.Spec.TLS = append(ing.Spec.TLS, networking.IngressTLS{Hosts: tls.Hosts, SecretName: tls.SecretName})
+	}
+
+	for _, oldRule := range old.Spec.Rules {
+		rule := networking.IngressRule{Host: oldRule.Host}
+		if oldRule.HTTP != nil {
+			rule.HTTP = &networking.HTTPIngressRuleValue{}
+			for _, oldPath := range oldRule.HTTP.Paths {
+				// pathType was optional in v1beta1
+				pathType := networking.PathTypeImplementationSpecific
+				if oldPath.PathType != nil {
+					pathType = networking.PathType(*oldPath.PathType)
+				}
+				rule.HTTP.Paths = append(rule.HTTP.Paths, networking.HTTPIngressPath{
+					Path:     oldPath.Path,
+					PathType: &pathType,
+					Backend:  *convertIngressBackend(&oldPath.Backend),
+				})
+			}
+		}
+		ing.Spec.Rules = append(ing.Spec.Rules, rule)
+	}
+
+	if errs := requiredFieldErrors(ing); len(errs) > 0 {
+		return nil, &schemaError{kind: "Ingress", errs: errs}
+	}
+	return ing, nil
+}
+
+func convertIngressBackend(old *networkingv1beta1.IngressBackend) *networking.IngressBackend {
+	if old == nil {
+		return nil
+	}
+
+	backend := &networking.IngressBackend{Resource: old.Resource}
+	if old.ServiceName != "" {
+		backend.Service = &networking.IngressServiceBackend{Name: old.ServiceName}
+		if old.ServicePort.Type == intstr.Int {
+			backend.Service.Port.Number = old.ServicePort.IntVal
+		} else {
+			backend.Service.Port.Name = old.ServicePort.StrVal
+		}
+	}
+	return backend
+}
+
+// checkDeprecatedIngressAPIs reports the Ingresses loaded from manifests
+// using an API version no longer served by Kubernetes
+func (n *NGINXController) checkDeprecatedIngressAPIs(ingresses []*Ingress, _ *Configuration) []Finding {
+	findings := []Finding{}
+	for _, ing := range ingresses {
+		key := k8s.MetaNamespaceKey(ing)
+		apiVersion := n.store.GetIngressAPIVersion(key)
+		if !deprecatedIngressAPIVersions[apiVersion] {
+			continue
+		}
+		findings = append(findings, Finding{
+			Rule:     "deprecated-api",
+			Severity: SeverityWarning,
+			Ingress:  key,
+			Message: fmt.Sprintf("Ingress uses %v, which is not served since Kubernetes 1.22 and was converted to %v for the validation; the API server rejects the manifest",
+				apiVersion, networking.SchemeGroupVersion.String()),
+		})
+	}
+	return findings
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	networking "k8s.io/api/networking/v1"
+)
+
+func TestConvertIngress(t *testing.T) {
+	pathType := func(p networking.PathType) *networking.PathType { return &p }
+	className := "nginx"
+
+	testCases := map[string]struct {
+		manifest string
+		expected networking.IngressSpec
+	}{
+		"service backends": {
+			manifest: `{
+  "apiVersion": "networking.k8s.io/v1beta1",
+  "kind": "Ingress",
+  "metadata": {"name": "web", "namespace": "default"},
+  "spec": {
+    "ingressClassName": "nginx",
+    "tls": [{"hosts": ["web.example.com"], "secretName": "web-tls"}],
+    "rules": [{"host": "web.example.com", "http": {"paths": [
+      {"path": "/", "pathType": "Prefix", "backend": {"serviceName": "web", "servicePort": 80}},
+      {"path": "/api", "backend": {"serviceName": "api", "servicePort": "http"}}
+    ]}}]
+  }
+}`,
+			expected: networking.IngressSpec{
+				IngressClassName: &className,
+				TLS:              []networking.IngressTLS{{Hosts: []string{"web.example.com"}, SecretName: "web-tls"}},
+				Rules: []networking.IngressRule{{
+					Host: "web.example.com",
+					IngressRuleValue: networking.IngressRuleValue{HTTP: &networking.HTTPIngressRuleValue{Paths: []networking.HTTPIngressPath{
+						{
+							Path:     "/",
+							PathType: pathType(networking.PathTypePrefix),
+							Backend: networking.IngressBackend{Service: &networking.IngressServiceBackend{
+								Name: "web", Port: networking.ServiceBackendPort{Number: 80},
+							}},
+						},
+						{
+							Path:     "/api",
+							PathType: pathType(networking.PathTypeImplementationSpecific),
+							Backend: networking.IngressBackend{Service: &networking.IngressServiceBackend{
+								Name: "api", Port: networking.ServiceBackendPort{Name: "http"},
+							}},
+						},
+					}}},
+				}},
+			},
+		},
+		"default backend and rule without paths": {
+			manifest: `{
+  "apiVersion": "extensions/v1beta1",
+  "kind": "Ingress",
+  "metadata": {"name": "web", "namespace": "default"},
+  "spec": {
+    "backend": {"serviceName": "default-http-backend", "servicePort": 8080},
+    "rules": [{"host": "web.example.com"}]
+  }
+}`,
+			expected: networking.IngressSpec{
+				DefaultBackend: &networking.IngressBackend{Service: &networking.IngressServiceBackend{
+					Name: "default-http-backend", Port: networking.ServiceBackendPort{Number: 8080},
+				}},
+				Rules: []networking.IngressRule{{Host: "web.example.com"}},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ing, err := convertIngress([]byte(tc.manifest))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ing.APIVersion != "networking.k8s.io/v1" || ing.Kind != "Ingress" || ing.Name != "web" || ing.Namespace != "default" {
+				t.Errorf("unexpected object %v %v %v/%v", ing.APIVersion, ing.Kind, ing.Namespace, ing.Name)
+			}
+			if !reflect.DeepEqual(ing.Spec, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, ing.Spec)
+			}
+		})
+	}
+
+	if _, err := convertIngress([]byte(`{"spec": []}`)); err == nil {
+		t.Errorf("expected an error for an invalid Ingress")
+	}
+}
+
+func TestCheckDeprecatedIngressAPIs(t *testing.T) {
+	ingress := func(apiVersion, name string) string {
+		manifest := `
+apiVersion: ` + apiVersion + `
+kind: Ingress
+metadata:
+  name: ` + name + `
+  namespace: default
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: ` + name + `.example.com
+    http:
+      paths:
+      - path: /
+`
+		if apiVersion == "networking.k8s.io/v1" {
+			return manifest + `        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+---`
+		}
+		return manifest + `        backend:
+          serviceName: web
+          servicePort: 80
+---`
+	}
+
+	n, ingresses, cfg := testConfiguration(t, ingress("networking.k8s.io/v1", "current")+
+		ingress("networking.k8s.io/v1beta1", "beta")+ingress("extensions/v1beta1", "extensions"))
+	if len(ingresses) != 3 {
+		t.Fatalf("expected the converted Ingresses to be loaded, got %v", ingresses)
+	}
+	if findServer(cfg, "beta.example.com") == nil || findServer(cfg, "extensions.example.com") == nil {
+		t.Errorf("expected servers for the converted Ingresses")
+	}
+
+	expected := []string{
+		"default/beta: Ingress uses networking.k8s.io/v1beta1, which is not served since Kubernetes 1.22",
+		"default/extensions: Ingress uses extensions/v1beta1, which is not served since Kubernetes 1.22",
+	}
+	got := []string{}
+	for _, f := range findingsWithRule(n.checkDeprecatedIngressAPIs(ingresses, cfg), "deprecated-api") {
+		if f.Severity != SeverityWarning {
+			t.Errorf("expected a warning, got %+v", f)
+		}
+		got = append(got, f.Ingress+": "+f.Message)
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	for i := range got {
+		if !strings.HasPrefix(got[i], expected[i]) {
+			t.Errorf("expected %q, got %q", expected[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,433 @@
+// Package annotations extracts the configuration of the annotations of an
+// Ingress. It is a trimmed copy of
+// k8s.io/ingress-nginx/internal/ingress/annotations, whose packages are
+// internal to the ingress-nginx module.
+package annotations
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/auth"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/authreq"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/authtls"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/canary"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/connection"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/cors"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/customheaders"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/fastcgi"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/ipallowlist"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/ipdenylist"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/log"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/mirror"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/modsecurity"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/opentelemetry"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/proxy"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/proxyssl"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/ratelimit"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/redirect"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/rewrite"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/sessionaffinity"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/sslcipher"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/upstreamhashby"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/errors"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// Ingress defines the valid annotations present in one NGINX Ingress rule
+type Ingress struct {
+	metav1.ObjectMeta
+	BackendProtocol             string
+	Aliases                     []string
+	BasicDigestAuth             auth.Config
+	Canary                      canary.Config
+	CertificateAuth             authtls.Config
+	ClientBodyBufferSize        string
+	CustomHeaders               customheaders.Config
+	ConfigurationSnippet        string
+	Connection                  connection.Config
+	CorsConfig                  cors.Config
+	CustomHTTPErrors            []int
+	DisableProxyInterceptErrors bool
+	DefaultBackend              *apiv1.Service
+	FastCGI                     fastcgi.Config
+	Denied                      *string
+	ExternalAuth                authreq.Config
+	EnableGlobalAuth            bool
+	HTTP2PushPreload            bool
+	Opentelemetry               opentelemetry.Config
+	Proxy                       proxy.Config
+	ProxySSL                    proxyssl.Config
+	RateLimit                   ratelimit.Config
+	Redirect                    redirect.Config
+	Rewrite                     rewrite.Config
+	Satisfy                     string
+	ServerSnippet               string
+	ServiceUpstream             bool
+	SessionAffinity             sessionaffinity.Config
+	SSLPassthrough              bool
+	UsePortInRedirects          bool
+	UpstreamHashBy              upstreamhashby.Config
+	LoadBalancing               string
+	UpstreamVhost               string
+	Denylist                    ipdenylist.SourceRange
+	XForwardedPrefix            string
+	SSLCipher                   sslcipher.Config
+	Logs                        log.Config
+	ModSecurity                 modsecurity.Config
+	Mirror                      mirror.Config
+	StreamSnippet               string
+	Allowlist                   ipallowlist.SourceRange
+	// Errors contains the errors of the annotations ignored because of an
+	// invalid value, by name of the annotation parser
+	Errors map[string]error
+}
+
+// annotation binds a parser to the field of Ingress it sets
+type annotation struct {
+	name   string
+	parser parser.IngressAnnotation
+	set    func(pia *Ingress, val interface{})
+}
+
+// Extractor defines the annotation parsers to be used in the extraction of annotations
+type Extractor struct {
+	annotations []annotation
+}
+
+// NewAnnotationExtractor creates a new annotations extractor
+func NewAnnotationExtractor(cfg resolver.Resolver) Extractor {
+	return Extractor{
+		annotations: []annotation{
+			{"Aliases", stringParser("server-alias"), func(pia *Ingress, val interface{}) {
+				pia.Aliases = splitAliases(val.(string))
+			}},
+			{"BackendProtocol", backendProtocolParser{cfg}, func(pia *Ingress, val interface{}) {
+				pia.BackendProtocol = val.(string)
+			}},
+			{"BasicDigestAuth", auth.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.BasicDigestAuth = *val.(*auth.Config)
+			}},
+			{"Canary", canary.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.Canary = *val.(*canary.Config)
+			}},
+			{"CertificateAuth", authtls.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.CertificateAuth = *val.(*authtls.Config)
+			}},
+			{"ClientBodyBufferSize", stringParser("client-body-buffer-size"), func(pia *Ingress, val interface{}) {
+				pia.ClientBodyBufferSize = val.(string)
+			}},
+			{"CustomHeaders", customheaders.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.CustomHeaders = *val.(*customheaders.Config)
+			}},
+			{"ConfigurationSnippet", stringParser("configuration-snippet"), func(pia *Ingress, val interface{}) {
+				pia.ConfigurationSnippet = val.(string)
+			}},
+			{"Connection", connection.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.Connection = *val.(*connection.Config)
+			}},
+			{"CorsConfig", cors.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.CorsConfig = *val.(*cors.Config)
+			}},
+			{"CustomHTTPErrors", customHTTPErrorsParser{cfg}, func(pia *Ingress, val interface{}) {
+				pia.CustomHTTPErrors = val.([]int)
+			}},
+			{"DisableProxyInterceptErrors", boolParser{"disable-proxy-intercept-errors", func() bool {
+				return cfg.GetDefaultBackend().DisableProxyInterceptErrors
+			}}, func(pia *Ingress, val interface{}) {
+				pia.DisableProxyInterceptErrors = val.(bool)
+			}},
+			{"DefaultBackend", defaultBackendParser{cfg}, func(pia *Ingress, val interface{}) {
+				pia.DefaultBackend = val.(*apiv1.Service)
+			}},
+			{"FastCGI", fastcgi.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.FastCGI = val.(fastcgi.Config)
+			}},
+			{"ExternalAuth", authreq.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.ExternalAuth = *val.(*authreq.Config)
+			}},
+			{"EnableGlobalAuth", boolParser{"enable-global-auth", func() bool { return true }}, func(pia *Ingress, val interface{}) {
+				pia.EnableGlobalAuth = val.(bool)
+			}},
+			{"HTTP2PushPreload", boolParser{"http2-push-preload", func() bool { return false }}, func(pia *Ingress, val interface{}) {
+				pia.HTTP2PushPreload = val.(bool)
+			}},
+			{"Opentelemetry", opentelemetry.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.Opentelemetry = *val.(*opentelemetry.Config)
+			}},
+			{"Proxy", proxy.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.Proxy = *val.(*proxy.Config)
+			}},
+			{"ProxySSL", proxyssl.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.ProxySSL = *val.(*proxyssl.Config)
+			}},
+			{"RateLimit", ratelimit.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.RateLimit = *val.(*ratelimit.Config)
+			}},
+			{"Redirect", redirect.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.Redirect = *val.(*redirect.Config)
+			}},
+			{"Rewrite", rewrite.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.Rewrite = *val.(*rewrite.Config)
+			}},
+			{"Satisfy", satisfyParser{}, func(pia *Ingress, val interface{}) {
+				pia.Satisfy = val.(string)
+			}},
+			{"ServerSnippet", stringParser("server-snippet"), func(pia *Ingress, val interface{}) {
+				pia.ServerSnippet = val.(string)
+			}},
+			{"ServiceUpstream", boolParser{"service-upstream", func() bool {
+				return cfg.GetDefaultBackend().ServiceUpstream
+			}}, func(pia *Ingress, val interface{}) {
+				pia.ServiceUpstream = val.(bool)
+			}},
+			{"SessionAffinity", sessionaffinity.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.SessionAffinity = *val.(*sessionaffinity.Config)
+			}},
+			{"SSLPassthrough", boolParser{"ssl-passthrough", func() bool { return false }}, func(pia *Ingress, val interface{}) {
+				pia.SSLPassthrough = val.(bool)
+			}},
+			{"UsePortInRedirects", boolParser{"use-port-in-redirects", func() bool {
+				return cfg.GetDefaultBackend().UsePortInRedirects
+			}}, func(pia *Ingress, val interface{}) {
+				pia.UsePortInRedirects = val.(bool)
+			}},
+			{"UpstreamHashBy", upstreamhashby.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.UpstreamHashBy = *val.(*upstreamhashby.Config)
+			}},
+			{"LoadBalancing", loadBalancingParser{cfg}, func(pia *Ingress, val interface{}) {
+				pia.LoadBalancing = val.(string)
+			}},
+			{"UpstreamVhost", stringParser("upstream-vhost"), func(pia *Ingress, val interface{}) {
+				pia.UpstreamVhost = val.(string)
+			}},
+			{"Allowlist", ipallowlist.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.Allowlist = *val.(*ipallowlist.SourceRange)
+			}},
+			{"Denylist", ipdenylist.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.Denylist = *val.(*ipdenylist.SourceRange)
+			}},
+			{"XForwardedPrefix", stringParser("x-forwarded-prefix"), func(pia *Ingress, val interface{}) {
+				pia.XForwardedPrefix = val.(string)
+			}},
+			{"SSLCipher", sslcipher.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.SSLCipher = *val.(*sslcipher.Config)
+			}},
+			{"Logs", log.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.Logs = *val.(*log.Config)
+			}},
+			{"ModSecurity", modsecurity.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.ModSecurity = *val.(*modsecurity.Config)
+			}},
+			{"Mirror", mirror.NewParser(cfg), func(pia *Ingress, val interface{}) {
+				pia.Mirror = *val.(*mirror.Config)
+			}},
+			{"StreamSnippet", stringParser("stream-snippet"), func(pia *Ingress, val interface{}) {
+				pia.StreamSnippet = val.(string)
+			}},
+		},
+	}
+}
+
+// Extract extracts the annotations from an Ingress
+func (e Extractor) Extract(ing *networking.Ingress) (*Ingress, error) {
+	pia := &Ingress{
+		ObjectMeta: ing.ObjectMeta,
+	}
+
+	denied := false
+	certificateAuthSet := false
+	for _, a := range e.annotations {
+		val, err := a.parser.Parse(ing)
+		klog.V(5).InfoS("Parsing Ingress annotation", "name", a.name, "ingress", klog.KObj(ing), "value", val)
+		if err != nil {
+			if errors.IsMissingAnnotations(err) {
+				continue
+			}
+
+			if !errors.IsLocationDenied(err) {
+				if pia.Errors == nil {
+					pia.Errors = map[string]error{}
+				}
+				pia.Errors[a.name] = err
+				continue
+			}
+
+			if a.name == "CertificateAuth" && !certificateAuthSet {
+				pia.CertificateAuth = authtls.Config{
+					AuthTLSError: err.Error(),
+				}
+				certificateAuthSet = true
+				continue
+			}
+
+			if !denied {
+				errString := err.Error()
+				pia.Denied = &errString
+				denied = true
+				klog.ErrorS(err, "error reading Ingress annotation", "name", a.name, "ingress", klog.KObj(ing))
+				continue
+			}
+
+			klog.V(5).ErrorS(err, "error reading Ingress annotation", "name", a.name, "ingress", klog.KObj(ing))
+		}
+
+		if val != nil {
+			a.set(pia, val)
+			if a.name == "CertificateAuth" {
+				certificateAuthSet = true
+			}
+		}
+	}
+
+	return pia, nil
+}
+
+// stringParser reads a string annotation
+type stringParser string
+
+func (s stringParser) Parse(ing *networking.Ingress) (interface{}, error) {
+	return parser.GetStringAnnotation(string(s), ing)
+}
+
+// boolParser reads a boolean annotation, falling back to a default value
+type boolParser struct {
+	name string
+	def  func() bool
+}
+
+func (b boolParser) Parse(ing *networking.Ingress) (interface{}, error) {
+	val, err := parser.GetBoolAnnotation(b.name, ing)
+	if err != nil {
+		return b.def(), nil
+	}
+	return val, nil
+}
+
+func splitAliases(val string) []string {
+	aliases := map[string]bool{}
+	list := []string{}
+	for _, alias := range strings.Split(val, ",") {
+		alias = strings.TrimSpace(alias)
+		if alias == "" || aliases[alias] {
+			continue
+		}
+		aliases[alias] = true
+		list = append(list, alias)
+	}
+	return list
+}
+
+var validProtocols = regexp.MustCompile(`^(AUTO_HTTP|HTTP|HTTPS|AJP|GRPC|GRPCS|FCGI)$`)
+
+type backendProtocolParser struct {
+	r resolver.Resolver
+}
+
+// Parse returns the protocol of the backends, HTTP unless backend-protocol
+// names a valid protocol
+func (b backendProtocolParser) Parse(ing *networking.Ingress) (interface{}, error) {
+	if ing.GetAnnotations() == nil {
+		return "HTTP", nil
+	}
+
+	proto, err := parser.GetStringAnnotation("backend-protocol", ing)
+	if err != nil {
+		return "HTTP", nil
+	}
+
+	proto = strings.TrimSpace(strings.ToUpper(proto))
+	if !validProtocols.MatchString(proto) {
+		klog.Warningf("Protocol %v is not a valid value for the backend-protocol annotation. Using HTTP as protocol", proto)
+		return "HTTP", nil
+	}
+
+	return proto, nil
+}
+
+type customHTTPErrorsParser struct {
+	r resolver.Resolver
+}
+
+// Parse returns the status codes listed by custom-http-errors, or those of
+// the configuration
+func (e customHTTPErrorsParser) Parse(ing *networking.Ingress) (interface{}, error) {
+	c, err := parser.GetStringAnnotation("custom-http-errors", ing)
+	if err != nil {
+		if errors.IsMissingAnnotations(err) {
+			return e.r.GetDefaultBackend().CustomHTTPErrors, nil
+		}
+		return nil, err
+	}
+
+	cSplit := strings.Split(c, ",")
+	codes := make([]int, 0, len(cSplit))
+	for _, i := range cSplit {
+		num, err := strconv.Atoi(strings.TrimSpace(i))
+		if err != nil {
+			return nil, errors.NewInvalidAnnotationContent("custom-http-errors", c)
+		}
+		codes = append(codes, num)
+	}
+
+	return codes, nil
+}
+
+type defaultBackendParser struct {
+	r resolver.Resolver
+}
+
+// Parse returns the Service named by default-backend
+func (db defaultBackendParser) Parse(ing *networking.Ingress) (interface{}, error) {
+	s, err := parser.GetStringAnnotation("default-backend", ing)
+	if err != nil {
+		return nil, err
+	}
+
+	name := s
+	if ns, _, err := k8s.ParseNameNS(s); err != nil || ns == "" {
+		name = ing.Namespace + "/" + s
+	}
+	svc, err := db.r.GetService(name)
+	if err != nil {
+		return nil, errors.NewLocationDenied("unexpected error reading service " + name + ": " + err.Error())
+	}
+
+	return svc, nil
+}
+
+type satisfyParser struct{}
+
+// Parse returns the satisfy directive, all or any
+func (s satisfyParser) Parse(ing *networking.Ingress) (interface{}, error) {
+	satisfy, err := parser.GetStringAnnotation("satisfy", ing)
+	if err != nil || (satisfy != "any" && satisfy != "all") {
+		satisfy = ""
+	}
+
+	return satisfy, nil
+}
+
+var validLoadBalancing = regexp.MustCompile(`^(round_robin|ewma)$`)
+
+type loadBalancingParser struct {
+	r resolver.Resolver
+}
+
+// Parse returns the load balancing algorithm of the backends
+func (a loadBalancingParser) Parse(ing *networking.Ingress) (interface{}, error) {
+	s, err := parser.GetStringAnnotation("load-balance", ing)
+	if err != nil || !validLoadBalancing.MatchString(s) {
+		s = a.r.GetDefaultBackend().LoadBalancing
+	}
+
+	return s, nil
+}
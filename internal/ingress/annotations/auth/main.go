@@ -0,0 +1,140 @@
+// Package auth parses the basic and digest authentication annotations.
+package auth
+
+import (
+	"fmt"
+	"regexp"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	ing_errors "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/errors"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+var (
+	authTypeRegex = regexp.MustCompile(`basic|digest`)
+	// AuthDirectory default directory used to store files
+	// to authenticate request
+	AuthDirectory = "/etc/ingress-controller/auth"
+)
+
+const (
+	fileAuth = "auth-file"
+	mapAuth  = "auth-map"
+)
+
+// Config returns authentication configuration for an Ingress rule
+type Config struct {
+	Type       string `json:"type"`
+	Realm      string `json:"realm"`
+	File       string `json:"file"`
+	Secured    bool   `json:"secured"`
+	FileSHA    string `json:"fileSha"`
+	Secret     string `json:"secret"`
+	SecretType string `json:"secretType"`
+}
+
+// Equal tests for equality between two Config types
+func (bd1 *Config) Equal(bd2 *Config) bool {
+	if bd1 == bd2 {
+		return true
+	}
+	if bd1 == nil || bd2 == nil {
+		return false
+	}
+	return *bd1 == *bd2
+}
+
+type auth struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new authentication annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return auth{r}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to add authentication in the paths defined in the rule
+// and generated an htpasswd compatible file to be used as source
+// during the authentication process
+func (a auth) Parse(ing *networking.Ingress) (interface{}, error) {
+	at, err := parser.GetStringAnnotation("auth-type", ing)
+	if err != nil {
+		return nil, err
+	}
+
+	if !authTypeRegex.MatchString(at) {
+		return nil, ing_errors.NewLocationDenied("invalid authentication type")
+	}
+
+	secretType, err := parser.GetStringAnnotation("auth-secret-type", ing)
+	if err != nil {
+		secretType = fileAuth
+	}
+	if secretType != fileAuth && secretType != mapAuth {
+		return nil, ing_errors.NewLocationDenied(fmt.Sprintf("invalid auth-secret-type in annotation, must be '%s' or '%s'", fileAuth, mapAuth))
+	}
+
+	s, err := parser.GetStringAnnotation("auth-secret", ing)
+	if err != nil {
+		return nil, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("error reading secret name from annotation: %w", err),
+		}
+	}
+
+	sns, sname, err := k8s.ParseNameNS(s)
+	if err != nil {
+		return nil, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("error reading secret name from annotation: %w", err),
+		}
+	}
+
+	if sns == "" {
+		sns = ing.Namespace
+	}
+	secCfg := a.r.GetSecurityConfiguration()
+	// We don't accept different namespaces for secrets.
+	if !secCfg.AllowCrossNamespaceResources && sns != ing.Namespace {
+		return nil, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("cross namespace usage of secrets is not allowed"),
+		}
+	}
+
+	name := fmt.Sprintf("%v/%v", sns, sname)
+	secret, err := a.r.GetSecret(name)
+	if err != nil {
+		return nil, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("unexpected error reading secret %s: %w", name, err),
+		}
+	}
+
+	switch secretType {
+	case fileAuth:
+		if _, ok := secret.Data["auth"]; !ok {
+			return nil, ing_errors.LocationDeniedError{
+				Reason: fmt.Errorf("the secret %s does not contain a key with value auth", name),
+			}
+		}
+	case mapAuth:
+		if len(secret.Data) == 0 {
+			return nil, ing_errors.LocationDeniedError{
+				Reason: fmt.Errorf("the secret %s does not contain any data", name),
+			}
+		}
+	}
+
+	realm, _ := parser.GetStringAnnotation("auth-realm", ing) //nolint:errcheck // Don't care if it's empty
+
+	return &Config{
+		Type:       at,
+		Realm:      realm,
+		File:       fmt.Sprintf("%v/%v-%v-%v.passwd", AuthDirectory, ing.GetNamespace(), ing.UID, secret.UID),
+		Secured:    true,
+		FileSHA:    string(secret.ResourceVersion),
+		Secret:     name,
+		SecretType: secretType,
+	}, nil
+}
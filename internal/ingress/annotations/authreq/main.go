@@ -0,0 +1,243 @@
+// Package authreq parses the external authentication annotations.
+package authreq
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	ing_errors "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/errors"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+const (
+	// DefaultCacheDuration is the fallback value if no cache duration is provided
+	DefaultCacheDuration = "200 202 401 5m"
+)
+
+var (
+	methods         = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace}
+	headerRegexp    = regexp.MustCompile(`^[a-zA-Z\d\-_]+$`)
+	statusCodeRegex = regexp.MustCompile(`^\d{3}$`)
+	durationRegex   = regexp.MustCompile(`^\d+(ms|s|m|h|d|w|M|y)$`) // see http://nginx.org/en/docs/syntax.html
+)
+
+// Config returns external authentication configuration for an Ingress rule
+type Config struct {
+	URL string `json:"url"`
+	// Host contains the hostname defined in the URL
+	Host                   string            `json:"host"`
+	SigninURL              string            `json:"signinUrl"`
+	SigninURLRedirectParam string            `json:"signinUrlRedirectParam,omitempty"`
+	Method                 string            `json:"method"`
+	ResponseHeaders        []string          `json:"responseHeaders,omitempty"`
+	RequestRedirect        string            `json:"requestRedirect"`
+	AuthSnippet            string            `json:"authSnippet"`
+	AuthCacheKey           string            `json:"authCacheKey"`
+	AuthCacheDuration      []string          `json:"authCacheDuration"`
+	ProxySetHeaders        map[string]string `json:"proxySetHeaders,omitempty"`
+	AlwaysSetCookie        bool              `json:"alwaysSetCookie,omitempty"`
+}
+
+// ValidMethod checks is the provided string a valid HTTP method
+func ValidMethod(method string) bool {
+	if method == "" {
+		return false
+	}
+
+	for _, m := range methods {
+		if method == m {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidHeader checks is the provided string satisfies the header's name regex
+func ValidHeader(header string) bool {
+	return headerRegexp.MatchString(header)
+}
+
+// ValidCacheDuration checks if the provided string is a valid cache duration
+// spec: [code ...] [time ...];
+// with: code is an http status code
+//
+//	time must match the time regex and may only appear once at the end
+func ValidCacheDuration(duration string) bool {
+	elements := strings.Split(duration, " ")
+	seenDuration := false
+
+	for _, element := range elements {
+		if element == "" {
+			continue
+		}
+		if statusCodeRegex.MatchString(element) {
+			if seenDuration {
+				return false // code after duration
+			}
+			continue
+		}
+		if durationRegex.MatchString(element) {
+			if seenDuration {
+				return false // duration after duration
+			}
+			seenDuration = true
+		}
+	}
+	return seenDuration
+}
+
+type authReq struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new authentication request annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return authReq{r}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to use an Config URL as source for authentication
+func (a authReq) Parse(ing *networking.Ingress) (interface{}, error) {
+	// Required Parameters
+	urlString, err := parser.GetStringAnnotation("auth-url", ing)
+	if err != nil {
+		return nil, err
+	}
+
+	authURL, err := parser.StringToURL(urlString)
+	if err != nil {
+		return nil, ing_errors.LocationDeniedError{Reason: fmt.Errorf("could not parse auth-url annotation: %v", err)}
+	}
+
+	authMethod, err := parser.GetStringAnnotation("auth-method", ing)
+	if err != nil {
+		if ing_errors.IsInvalidContent(err) {
+			return nil, ing_errors.NewLocationDenied("invalid HTTP method")
+		}
+	}
+	if authMethod != "" && !ValidMethod(authMethod) {
+		return nil, ing_errors.NewLocationDenied("invalid HTTP method")
+	}
+
+	// Optional Parameters
+	signIn, err := parser.GetStringAnnotation("auth-signin", ing)
+	if err != nil {
+		if ing_errors.IsInvalidContent(err) {
+			return nil, ing_errors.NewLocationDenied("invalid auth-signin")
+		}
+	}
+
+	signInRedirectParam, _ := parser.GetStringAnnotation("auth-signin-redirect-param", ing) //nolint:errcheck // Don't care if it's empty
+
+	authSnippet, _ := parser.GetStringAnnotation("auth-snippet", ing) //nolint:errcheck // Don't care if it's empty
+
+	authCacheKey, _ := parser.GetStringAnnotation("auth-cache-key", ing) //nolint:errcheck // Don't care if it's empty
+
+	durstr, _ := parser.GetStringAnnotation("auth-cache-duration", ing) //nolint:errcheck // Don't care if it's empty
+	authCacheDuration, err := ParseStringToCacheDurations(durstr)
+	if err != nil {
+		return nil, err
+	}
+
+	responseHeaders := []string{}
+	hstr, _ := parser.GetStringAnnotation("auth-response-headers", ing) //nolint:errcheck // Don't care if it's empty
+	if hstr != "" {
+		harr := strings.Split(hstr, ",")
+		for _, header := range harr {
+			header = strings.TrimSpace(header)
+			if header == "" {
+				continue
+			}
+			if !ValidHeader(header) {
+				return nil, ing_errors.NewLocationDenied("invalid headers list")
+			}
+			responseHeaders = append(responseHeaders, header)
+		}
+	}
+
+	proxySetHeaderMap, err := parser.GetStringAnnotation("auth-proxy-set-headers", ing)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return nil, err
+	}
+
+	var proxySetHeaders map[string]string
+
+	if proxySetHeaderMap != "" {
+		cmns, cmn, err := k8s.ParseNameNS(proxySetHeaderMap)
+		if err != nil {
+			return nil, ing_errors.NewLocationDenied(err.Error())
+		}
+		if cmns == "" {
+			cmns = ing.Namespace
+		}
+		secCfg := a.r.GetSecurityConfiguration()
+		// We don't accept different namespaces for configmaps.
+		if !secCfg.AllowCrossNamespaceResources && cmns != ing.Namespace {
+			return nil, ing_errors.NewLocationDenied("cross namespace usage of configmaps is not allowed")
+		}
+
+		proxySetHeadersMapContents, err := a.r.GetConfigMap(cmns + "/" + cmn)
+		if err != nil {
+			return nil, ing_errors.NewLocationDenied(fmt.Sprintf("unable to find configMap %q", proxySetHeaderMap))
+		}
+
+		for header := range proxySetHeadersMapContents.Data {
+			if !ValidHeader(header) {
+				return nil, ing_errors.NewLocationDenied("invalid proxy-set-headers in configmap")
+			}
+		}
+
+		proxySetHeaders = proxySetHeadersMapContents.Data
+	}
+
+	requestRedirect, _ := parser.GetStringAnnotation("auth-request-redirect", ing) //nolint:errcheck // Don't care if it's empty
+
+	alwaysSetCookie, _ := parser.GetBoolAnnotation("auth-always-set-cookie", ing) //nolint:errcheck // Don't care if it's empty
+
+	return &Config{
+		URL:                    urlString,
+		Host:                   authURL.Hostname(),
+		SigninURL:              signIn,
+		SigninURLRedirectParam: signInRedirectParam,
+		Method:                 authMethod,
+		ResponseHeaders:        responseHeaders,
+		RequestRedirect:        requestRedirect,
+		AuthSnippet:            authSnippet,
+		AuthCacheKey:           authCacheKey,
+		AuthCacheDuration:      authCacheDuration,
+		ProxySetHeaders:        proxySetHeaders,
+		AlwaysSetCookie:        alwaysSetCookie,
+	}, nil
+}
+
+// ParseStringToCacheDurations parses and validates the provided string
+// into a list of cache durations.
+// It will always return at least one duration (the default duration)
+func ParseStringToCacheDurations(input string) ([]string, error) {
+	authCacheDuration := []string{}
+	if input != "" {
+		arr := strings.Split(input, ",")
+		for _, duration := range arr {
+			duration = strings.TrimSpace(duration)
+			if duration == "" {
+				continue
+			}
+			if !ValidCacheDuration(duration) {
+				authCacheDuration = []string{DefaultCacheDuration}
+				return authCacheDuration, ing_errors.NewLocationDenied(fmt.Sprintf("invalid cache duration: %s", duration))
+			}
+			authCacheDuration = append(authCacheDuration, duration)
+		}
+	}
+
+	if len(authCacheDuration) == 0 {
+		authCacheDuration = append(authCacheDuration, DefaultCacheDuration)
+	}
+	return authCacheDuration, nil
+}
@@ -0,0 +1,114 @@
+// Package authtls parses the client certificate authentication annotations.
+package authtls
+
+import (
+	"fmt"
+	"regexp"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	ing_errors "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/errors"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+const (
+	defaultAuthTLSDepth     = 1
+	defaultAuthVerifyClient = "on"
+)
+
+var (
+	authVerifyClientRegex = regexp.MustCompile(`^(on|off|optional|optional_no_ca)$`)
+	redirectRegex         = regexp.MustCompile(`^((https?://)?[A-Za-z0-9\-.]*(:\d+)?/[A-Za-z0-9\-.]*)?$`)
+	commonNameRegex       = regexp.MustCompile(`^CN=`)
+)
+
+// Config contains the AuthSSLCert used for mutual authentication
+// and the configured ValidationDepth
+type Config struct {
+	resolver.AuthSSLCert
+	VerifyClient       string `json:"verify_client"`
+	ValidationDepth    int    `json:"validationDepth"`
+	ErrorPage          string `json:"errorPage"`
+	PassCertToUpstream bool   `json:"passCertToUpstream"`
+	MatchCN            string `json:"matchCN"`
+	AuthTLSError       string
+}
+
+// Equal tests for equality between two Config types
+func (assl1 *Config) Equal(assl2 *Config) bool {
+	if assl1 == assl2 {
+		return true
+	}
+	if assl1 == nil || assl2 == nil {
+		return false
+	}
+	return *assl1 == *assl2
+}
+
+type authTLS struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new TLS authentication annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return authTLS{r}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to use a Certificate as authentication method
+func (a authTLS) Parse(ing *networking.Ingress) (interface{}, error) {
+	var err error
+	config := &Config{}
+
+	tlsauthsecret, err := parser.GetStringAnnotation("auth-tls-secret", ing)
+	if err != nil {
+		return &Config{}, err
+	}
+
+	ns, _, err := k8s.ParseNameNS(tlsauthsecret)
+	if err != nil {
+		return &Config{}, ing_errors.NewLocationDenied(err.Error())
+	}
+
+	secCfg := a.r.GetSecurityConfiguration()
+	// We don't accept different namespaces for secrets.
+	if !secCfg.AllowCrossNamespaceResources && ns != ing.Namespace {
+		return &Config{}, ing_errors.NewLocationDenied("cross namespace secrets are not supported")
+	}
+
+	authCert, err := a.r.GetAuthCertificate(tlsauthsecret)
+	if err != nil {
+		e := fmt.Errorf("error obtaining certificate: %w", err)
+		return &Config{}, ing_errors.LocationDeniedError{Reason: e}
+	}
+	config.AuthSSLCert = *authCert
+
+	config.VerifyClient, err = parser.GetStringAnnotation("auth-tls-verify-client", ing)
+	if err != nil || !authVerifyClientRegex.MatchString(config.VerifyClient) {
+		config.VerifyClient = defaultAuthVerifyClient
+	}
+
+	config.ValidationDepth, err = parser.GetIntAnnotation("auth-tls-verify-depth", ing)
+	if err != nil || config.ValidationDepth == 0 {
+		config.ValidationDepth = defaultAuthTLSDepth
+	}
+
+	config.ErrorPage, err = parser.GetStringAnnotation("auth-tls-error-page", ing)
+	if err != nil || !redirectRegex.MatchString(config.ErrorPage) {
+		config.ErrorPage = ""
+	}
+
+	config.PassCertToUpstream, err = parser.GetBoolAnnotation("auth-tls-pass-certificate-to-upstream", ing)
+	if err != nil {
+		config.PassCertToUpstream = false
+	}
+
+	config.MatchCN, err = parser.GetStringAnnotation("auth-tls-match-cn", ing)
+	if err != nil || !commonNameRegex.MatchString(config.MatchCN) {
+		config.MatchCN = ""
+	}
+
+	return config, nil
+}
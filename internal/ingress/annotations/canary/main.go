@@ -0,0 +1,79 @@
+// Package canary parses the canary annotations.
+package canary
+
+import (
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/errors"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+)
+
+type canary struct {
+	r resolver.Resolver
+}
+
+// Config returns the configuration rules for setting up the Canary
+type Config struct {
+	Enabled       bool
+	Weight        int
+	WeightTotal   int
+	Header        string
+	HeaderValue   string
+	HeaderPattern string
+	Cookie        string
+}
+
+// NewParser parses the ingress for canary related annotations
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return canary{r}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to indicate if the canary should be enabled and with what config
+func (c canary) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{}
+	var err error
+
+	config.Enabled, err = parser.GetBoolAnnotation("canary", ing)
+	if err != nil {
+		config.Enabled = false
+	}
+
+	config.Weight, err = parser.GetIntAnnotation("canary-weight", ing)
+	if err != nil {
+		config.Weight = 0
+	}
+
+	config.WeightTotal, err = parser.GetIntAnnotation("canary-weight-total", ing)
+	if err != nil {
+		config.WeightTotal = 100
+	}
+
+	config.Header, err = parser.GetStringAnnotation("canary-by-header", ing)
+	if err != nil {
+		config.Header = ""
+	}
+
+	config.HeaderValue, err = parser.GetStringAnnotation("canary-by-header-value", ing)
+	if err != nil {
+		config.HeaderValue = ""
+	}
+
+	config.HeaderPattern, err = parser.GetStringAnnotation("canary-by-header-pattern", ing)
+	if err != nil {
+		config.HeaderPattern = ""
+	}
+
+	config.Cookie, err = parser.GetStringAnnotation("canary-by-cookie", ing)
+	if err != nil {
+		config.Cookie = ""
+	}
+
+	if !config.Enabled && (config.Weight > 0 || config.Header != "" || config.HeaderValue != "" || config.Cookie != "" ||
+		config.HeaderPattern != "") {
+		return nil, errors.NewInvalidAnnotationConfiguration("canary", "configured but not enabled")
+	}
+
+	return config, nil
+}
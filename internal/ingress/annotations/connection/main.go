@@ -0,0 +1,50 @@
+// Package connection parses the connection-proxy-header annotation.
+package connection
+
+import (
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+)
+
+// Config returns the connection header configuration for an Ingress rule
+type Config struct {
+	Header  string `json:"header"`
+	Enabled bool   `json:"enabled"`
+}
+
+type connection struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new port in redirect annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return connection{r}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to indicate if the connection header should be overridden.
+func (a connection) Parse(ing *networking.Ingress) (interface{}, error) {
+	cp, err := parser.GetStringAnnotation("connection-proxy-header", ing)
+	if err != nil {
+		return &Config{
+			Enabled: false,
+		}, err
+	}
+	return &Config{
+		Enabled: true,
+		Header:  cp,
+	}, nil
+}
+
+// Equal tests for equality between two Config types
+func (r1 *Config) Equal(r2 *Config) bool {
+	if r1 == r2 {
+		return true
+	}
+	if r1 == nil || r2 == nil {
+		return false
+	}
+	return *r1 == *r2
+}
@@ -0,0 +1,118 @@
+// Package cors parses the CORS annotations.
+package cors
+
+import (
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+)
+
+const (
+	// Default values
+	defaultCorsMethods = "GET, PUT, POST, DELETE, PATCH, OPTIONS"
+	defaultCorsHeaders = "DNT,Keep-Alive,User-Agent,X-Requested-With,If-Modified-Since,Cache-Control,Content-Type,Range,Authorization"
+	defaultCorsMaxAge  = 1728000
+)
+
+var (
+	// Regex are defined here to prevent information leak, if user tries to set anything not valid
+	// that could cause the Response to contain some internal value/variable (like returning $pid, $upstream_addr, etc)
+	// Origin must contain a http/s Origin (including or not the port) or the value '*'
+	// This Regex is composed of the following:
+	// * Sets a group that can be (https?://)?*?.something.com:port?
+	// * Allows this to be repeated as much as possible, and separated by comma
+	// Otherwise it should be '*'
+	corsOriginRegexValidator = regexp.MustCompile(`^((((https?://)?(\*\.)?[A-Za-z0-9\-.]*(:\d+)?,?)+)|\*)?$`)
+	// corsOriginRegex defines the regex for validation inside Parse
+	corsOriginRegex = regexp.MustCompile(`^(https?://(\*\.)?[A-Za-z0-9\-.]*(:\d+)?|\*)?$`)
+	// Method must contain valid methods list (PUT, GET, POST, BLA)
+	// May contain or not spaces between each verb
+	corsMethodsRegex = regexp.MustCompile(`^([A-Za-z]+,?\s?)+$`)
+	// Expose Headers must contain valid values only (*, X-HEADER12, X-ABC)
+	// May contain or not spaces between each Header
+	corsExposeHeadersRegex = regexp.MustCompile(`^(([A-Za-z0-9\-_]+|\*),?\s?)+$`)
+	// Headers must contain valid values only (X-HEADER12, X-ABC)
+	corsHeadersRegex = regexp.MustCompile(`^([A-Za-z0-9\-_]+,?\s?)+$`)
+)
+
+type cors struct {
+	r resolver.Resolver
+}
+
+// Config contains the Cors configuration to be used in the Ingress
+type Config struct {
+	CorsEnabled          bool     `json:"corsEnabled"`
+	CorsAllowOrigin      []string `json:"corsAllowOrigin"`
+	CorsAllowMethods     string   `json:"corsAllowMethods"`
+	CorsAllowHeaders     string   `json:"corsAllowHeaders"`
+	CorsAllowCredentials bool     `json:"corsAllowCredentials"`
+	CorsExposeHeaders    string   `json:"corsExposeHeaders"`
+	CorsMaxAge           int      `json:"corsMaxAge"`
+}
+
+// NewParser creates a new CORS annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return cors{r}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to indicate if the location/s should allows CORS
+func (c cors) Parse(ing *networking.Ingress) (interface{}, error) {
+	var err error
+	config := &Config{}
+
+	config.CorsEnabled, err = parser.GetBoolAnnotation("enable-cors", ing)
+	if err != nil {
+		config.CorsEnabled = false
+	}
+
+	config.CorsAllowOrigin = []string{}
+	unparsedOrigins, err := parser.GetStringAnnotation("cors-allow-origin", ing)
+	if err == nil && corsOriginRegexValidator.MatchString(unparsedOrigins) {
+		origins := strings.Split(unparsedOrigins, ",")
+		for _, origin := range origins {
+			origin = strings.TrimSpace(origin)
+			if origin == "*" {
+				config.CorsAllowOrigin = []string{"*"}
+				break
+			}
+			if corsOriginRegex.MatchString(origin) {
+				config.CorsAllowOrigin = append(config.CorsAllowOrigin, origin)
+			}
+		}
+	}
+	if len(config.CorsAllowOrigin) == 0 {
+		config.CorsAllowOrigin = []string{"*"}
+	}
+
+	config.CorsAllowHeaders, err = parser.GetStringAnnotation("cors-allow-headers", ing)
+	if err != nil || !corsHeadersRegex.MatchString(config.CorsAllowHeaders) {
+		config.CorsAllowHeaders = defaultCorsHeaders
+	}
+
+	config.CorsAllowMethods, err = parser.GetStringAnnotation("cors-allow-methods", ing)
+	if err != nil || !corsMethodsRegex.MatchString(config.CorsAllowMethods) {
+		config.CorsAllowMethods = defaultCorsMethods
+	}
+
+	config.CorsAllowCredentials, err = parser.GetBoolAnnotation("cors-allow-credentials", ing)
+	if err != nil {
+		config.CorsAllowCredentials = true
+	}
+
+	config.CorsExposeHeaders, err = parser.GetStringAnnotation("cors-expose-headers", ing)
+	if err != nil || !corsExposeHeadersRegex.MatchString(config.CorsExposeHeaders) {
+		config.CorsExposeHeaders = ""
+	}
+
+	config.CorsMaxAge, err = parser.GetIntAnnotation("cors-max-age", ing)
+	if err != nil {
+		config.CorsMaxAge = defaultCorsMaxAge
+	}
+
+	return config, nil
+}
@@ -0,0 +1,67 @@
+// Package customheaders parses the custom-headers annotation.
+package customheaders
+
+import (
+	"fmt"
+	"regexp"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	ing_errors "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/errors"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// Config returns the custom response headers for an Ingress rule
+type Config struct {
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+var headerRegexp = regexp.MustCompile(`^[a-zA-Z\d\-_]+$`)
+
+type customHeaders struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new custom response headers annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return customHeaders{r}
+}
+
+// Parse parses the annotations contained in the ingress to use
+// custom response headers
+func (a customHeaders) Parse(ing *networking.Ingress) (interface{}, error) {
+	cm, err := parser.GetStringAnnotation("custom-headers", ing)
+	if err != nil {
+		return nil, err
+	}
+
+	ns, name, err := k8s.ParseNameNS(cm)
+	if err != nil {
+		return nil, ing_errors.NewLocationDenied(err.Error())
+	}
+	if ns == "" {
+		ns = ing.Namespace
+	}
+	secCfg := a.r.GetSecurityConfiguration()
+	// We don't accept different namespaces for configmaps.
+	if !secCfg.AllowCrossNamespaceResources && ns != ing.Namespace {
+		return nil, ing_errors.NewLocationDenied("cross namespace usage of configmaps is not allowed")
+	}
+
+	headersMapContents, err := a.r.GetConfigMap(ns + "/" + name)
+	if err != nil {
+		return nil, ing_errors.NewLocationDenied(fmt.Sprintf("unable to find configMap %q", cm))
+	}
+
+	for header := range headersMapContents.Data {
+		if !headerRegexp.MatchString(header) {
+			return nil, ing_errors.NewLocationDenied("invalid header name in configmap")
+		}
+	}
+
+	return &Config{
+		Headers: headersMapContents.Data,
+	}, nil
+}
@@ -0,0 +1,111 @@
+// Package fastcgi parses the FastCGI annotations.
+package fastcgi
+
+import (
+	"fmt"
+	"regexp"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	ing_errors "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/errors"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+var (
+	// fast-cgi valid parameters is just a single file name (like index.php)
+	regexValidIndexAnnotationAndKey = regexp.MustCompile(`^[A-Za-z0-9.\-\_]+$`)
+)
+
+type fastcgi struct {
+	r resolver.Resolver
+}
+
+// Config describes the per location fastcgi config
+type Config struct {
+	Index  string            `json:"index"`
+	Params map[string]string `json:"params"`
+}
+
+// Equal tests for equality between two Configuration types
+func (l1 *Config) Equal(l2 *Config) bool {
+	if l1 == l2 {
+		return true
+	}
+	if l1 == nil || l2 == nil {
+		return false
+	}
+	if l1.Index != l2.Index || len(l1.Params) != len(l2.Params) {
+		return false
+	}
+	for k, v := range l1.Params {
+		if l2.Params[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// NewParser creates a new fastcgiConfig protocol annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return fastcgi{r}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to indicate the fastcgiConfig.
+func (a fastcgi) Parse(ing *networking.Ingress) (interface{}, error) {
+	fcgiConfig := Config{}
+
+	if ing.GetAnnotations() == nil {
+		return fcgiConfig, nil
+	}
+
+	index, err := parser.GetStringAnnotation("fastcgi-index", ing)
+	if err != nil {
+		index = ""
+	}
+	if index != "" && !regexValidIndexAnnotationAndKey.MatchString(index) {
+		return fcgiConfig, ing_errors.NewLocationDenied(fmt.Sprintf("invalid fastcgi-index %q", index))
+	}
+	fcgiConfig.Index = index
+
+	cm, err := parser.GetStringAnnotation("fastcgi-params-configmap", ing)
+	if err != nil {
+		return fcgiConfig, nil
+	}
+
+	cmns, cmn, err := k8s.ParseNameNS(cm)
+	if err != nil {
+		return fcgiConfig, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("error reading configmap name from annotation: %w", err),
+		}
+	}
+	if cmns == "" {
+		cmns = ing.Namespace
+	}
+	secCfg := a.r.GetSecurityConfiguration()
+	// We don't accept different namespaces for configmaps.
+	if !secCfg.AllowCrossNamespaceResources && cmns != ing.Namespace {
+		return fcgiConfig, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("different namespace is not supported on fast_cgi param configmap"),
+		}
+	}
+
+	cm = fmt.Sprintf("%v/%v", cmns, cmn)
+	cmap, err := a.r.GetConfigMap(cm)
+	if err != nil {
+		return fcgiConfig, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("unexpected error reading configmap %s: %w", cm, err),
+		}
+	}
+
+	for k := range cmap.Data {
+		if !regexValidIndexAnnotationAndKey.MatchString(k) {
+			return fcgiConfig, ing_errors.NewLocationDenied(fmt.Sprintf("invalid fastcgi param %q in configmap %v", k, cm))
+		}
+	}
+	fcgiConfig.Params = cmap.Data
+
+	return fcgiConfig, nil
+}
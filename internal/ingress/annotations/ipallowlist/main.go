@@ -0,0 +1,114 @@
+// Package ipallowlist parses the allowlist-source-range annotation.
+package ipallowlist
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	ing_errors "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/errors"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+)
+
+// SourceRange returns the CIDR
+type SourceRange struct {
+	CIDR []string `json:"cidr,omitempty"`
+}
+
+// Equal tests for equality between two SourceRange types
+func (sr1 *SourceRange) Equal(sr2 *SourceRange) bool {
+	if sr1 == sr2 {
+		return true
+	}
+	if sr1 == nil || sr2 == nil || len(sr1.CIDR) != len(sr2.CIDR) {
+		return false
+	}
+	for i := range sr1.CIDR {
+		if sr1.CIDR[i] != sr2.CIDR[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type allowlist struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new allowlist annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return allowlist{r}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to limit access to certain client addresses or networks.
+// Multiple ranges can specified using commas as separator
+// e.g. `18.0.0.0/8,56.0.0.0/8`
+func (a allowlist) Parse(ing *networking.Ingress) (interface{}, error) {
+	defBackend := a.r.GetDefaultBackend()
+
+	defaultList := make([]string, len(defBackend.WhitelistSourceRange))
+	copy(defaultList, defBackend.WhitelistSourceRange)
+
+	var (
+		val string
+		err error
+	)
+	for _, name := range []string{"allowlist-source-range", "whitelist-source-range"} {
+		val, err = parser.GetStringAnnotation(name, ing)
+		if err == nil || !ing_errors.IsMissingAnnotations(err) {
+			break
+		}
+	}
+	// A missing annotation is not a problem, just use the default
+	if ing_errors.IsMissingAnnotations(err) {
+		return &SourceRange{CIDR: defaultList}, nil // nothing was configured
+	}
+
+	values := strings.Split(val, ",")
+	ipnets, ips, err := parseIPNets(values...)
+	if err != nil {
+		return &SourceRange{CIDR: defaultList}, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("the annotation does not contain a valid IP address or network: %w", err),
+		}
+	}
+
+	cidrs := []string{}
+	for k := range ipnets {
+		cidrs = append(cidrs, k)
+	}
+	for k := range ips {
+		cidrs = append(cidrs, k)
+	}
+
+	sort.Strings(cidrs)
+
+	return &SourceRange{CIDR: cidrs}, nil
+}
+
+// parseIPNets parses a list of IP addresses and networks. The addresses
+// are returned separately from the networks.
+func parseIPNets(specs ...string) (ipnets map[string]*net.IPNet, ips map[string]net.IP, err error) {
+	ipnets = map[string]*net.IPNet{}
+	ips = map[string]net.IP{}
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		if _, ipnet, perr := net.ParseCIDR(spec); perr == nil {
+			ipnets[ipnet.String()] = ipnet
+			continue
+		}
+		ip := net.ParseIP(spec)
+		if ip == nil {
+			return nil, nil, fmt.Errorf("invalid IP address or network %q", spec)
+		}
+		ips[ip.String()] = ip
+	}
+	return ipnets, ips, nil
+}
@@ -0,0 +1,54 @@
+// Package log parses the access and rewrite log annotations.
+package log
+
+import (
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+)
+
+type log struct {
+	r resolver.Resolver
+}
+
+// Config contains the configuration to be used in the Ingress
+type Config struct {
+	Access  bool `json:"accessLog"`
+	Rewrite bool `json:"rewriteLog"`
+}
+
+// Equal tests for equality between two Config types
+func (bd1 *Config) Equal(bd2 *Config) bool {
+	if bd1 == bd2 {
+		return true
+	}
+	if bd1 == nil || bd2 == nil {
+		return false
+	}
+	return *bd1 == *bd2
+}
+
+// NewParser creates a new access log annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return log{r}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to indicate if the access and rewrite logs are enabled
+func (l log) Parse(ing *networking.Ingress) (interface{}, error) {
+	var err error
+	config := &Config{}
+
+	config.Access, err = parser.GetBoolAnnotation("enable-access-log", ing)
+	if err != nil {
+		config.Access = true
+	}
+
+	config.Rewrite, err = parser.GetBoolAnnotation("enable-rewrite-log", ing)
+	if err != nil {
+		config.Rewrite = false
+	}
+
+	return config, nil
+}
@@ -0,0 +1,82 @@
+// Package mirror parses the mirror annotations.
+package mirror
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	ing_errors "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/errors"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+)
+
+var onOffRegex = regexp.MustCompile(`^(on|off)$`)
+
+// Config returns the mirror to use in a given location
+type Config struct {
+	Source      string `json:"source"`
+	RequestBody string `json:"requestBody"`
+	Target      string `json:"target"`
+	Host        string `json:"host"`
+}
+
+// Equal tests for equality between two Configuration types
+func (m1 *Config) Equal(m2 *Config) bool {
+	if m1 == m2 {
+		return true
+	}
+	if m1 == nil || m2 == nil {
+		return false
+	}
+	return *m1 == *m2
+}
+
+type mirror struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new mirror configuration annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return mirror{r}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to configure mirror
+func (a mirror) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{
+		Source: fmt.Sprintf("/_mirror-%v", ing.UID),
+	}
+
+	var err error
+	config.RequestBody, err = parser.GetStringAnnotation("mirror-request-body", ing)
+	if err != nil || !onOffRegex.MatchString(config.RequestBody) {
+		config.RequestBody = "on"
+	}
+
+	config.Target, err = parser.GetStringAnnotation("mirror-target", ing)
+	if err != nil {
+		if ing_errors.IsInvalidContent(err) {
+			return nil, ing_errors.NewLocationDenied("invalid mirror-target")
+		}
+		config.Target = ""
+		config.Source = ""
+	}
+
+	config.Host, err = parser.GetStringAnnotation("mirror-host", ing)
+	if err != nil {
+		config.Host = ""
+		if config.Target != "" {
+			target := strings.Split(config.Target, "$")
+			u, err := url.Parse(target[0])
+			if err == nil {
+				config.Host = u.Hostname()
+			}
+		}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,69 @@
+// Package modsecurity parses the ModSecurity annotations.
+package modsecurity
+
+import (
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+)
+
+// Config contains ModSecurity Configuration items
+type Config struct {
+	Enable        bool   `json:"enable-modsecurity"`
+	EnableSet     bool   `json:"enable-modsecurity-set"`
+	OWASPRules    bool   `json:"enable-owasp-core-rules"`
+	TransactionID string `json:"modsecurity-transaction-id"`
+	Snippet       string `json:"modsecurity-snippet"`
+}
+
+// Equal tests for equality between two Config types
+func (modsec1 *Config) Equal(modsec2 *Config) bool {
+	if modsec1 == modsec2 {
+		return true
+	}
+	if modsec1 == nil || modsec2 == nil {
+		return false
+	}
+	return *modsec1 == *modsec2
+}
+
+// NewParser creates a new ModSecurity annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return modSecurity{r}
+}
+
+type modSecurity struct {
+	r resolver.Resolver
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to indicate if ModSecurity is enabled and its configuration
+func (a modSecurity) Parse(ing *networking.Ingress) (interface{}, error) {
+	var err error
+	config := &Config{}
+
+	config.EnableSet = true
+	config.Enable, err = parser.GetBoolAnnotation("enable-modsecurity", ing)
+	if err != nil {
+		config.Enable = false
+		config.EnableSet = false
+	}
+
+	config.OWASPRules, err = parser.GetBoolAnnotation("enable-owasp-core-rules", ing)
+	if err != nil {
+		config.OWASPRules = false
+	}
+
+	config.TransactionID, err = parser.GetStringAnnotation("modsecurity-transaction-id", ing)
+	if err != nil {
+		config.TransactionID = ""
+	}
+
+	config.Snippet, err = parser.GetStringAnnotation("modsecurity-snippet", ing)
+	if err != nil {
+		config.Snippet = ""
+	}
+
+	return config, nil
+}
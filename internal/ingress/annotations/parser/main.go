@@ -0,0 +1,157 @@
+// Package parser contains the helpers used to read the annotations of an
+// Ingress, from k8s.io/ingress-nginx/internal/ingress/annotations/parser.
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	ing_errors "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/errors"
+)
+
+// DefaultAnnotationsPrefix defines the common prefix used in the nginx ingress controller
+const DefaultAnnotationsPrefix = "nginx.ingress.kubernetes.io"
+
+// AnnotationsPrefix is the mutable attribute that the controller explicitly refers to
+var AnnotationsPrefix = DefaultAnnotationsPrefix
+
+// IngressAnnotation has a method to parse annotations located in Ingress
+type IngressAnnotation interface {
+	Parse(ing *networking.Ingress) (interface{}, error)
+}
+
+type ingAnnotations map[string]string
+
+func (a ingAnnotations) parseBool(name string) (bool, error) {
+	val, ok := a[name]
+	if ok {
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return false, ing_errors.NewInvalidAnnotationContent(name, val)
+		}
+		return b, nil
+	}
+	return false, ing_errors.ErrMissingAnnotations
+}
+
+func (a ingAnnotations) parseString(name string) (string, error) {
+	val, ok := a[name]
+	if ok {
+		s := normalizeString(val)
+		if s == "" {
+			return "", ing_errors.NewInvalidAnnotationContent(name, val)
+		}
+
+		return s, nil
+	}
+	return "", ing_errors.ErrMissingAnnotations
+}
+
+func (a ingAnnotations) parseInt(name string) (int, error) {
+	val, ok := a[name]
+	if ok {
+		i, err := strconv.Atoi(val)
+		if err != nil {
+			return 0, ing_errors.NewInvalidAnnotationContent(name, val)
+		}
+		return i, nil
+	}
+	return 0, ing_errors.ErrMissingAnnotations
+}
+
+func (a ingAnnotations) parseFloat32(name string) (float32, error) {
+	val, ok := a[name]
+	if ok {
+		i, err := strconv.ParseFloat(val, 32)
+		if err != nil {
+			return 0, ing_errors.NewInvalidAnnotationContent(name, val)
+		}
+		return float32(i), nil
+	}
+	return 0, ing_errors.ErrMissingAnnotations
+}
+
+func checkAnnotation(name string, ing *networking.Ingress) (string, error) {
+	if ing == nil || len(ing.GetAnnotations()) == 0 {
+		return "", ing_errors.ErrMissingAnnotations
+	}
+	if name == "" {
+		return "", ing_errors.ErrInvalidAnnotationName
+	}
+
+	return GetAnnotationWithPrefix(name), nil
+}
+
+// GetBoolAnnotation extracts a boolean from an Ingress annotation
+func GetBoolAnnotation(name string, ing *networking.Ingress) (bool, error) {
+	v, err := checkAnnotation(name, ing)
+	if err != nil {
+		return false, err
+	}
+	return ingAnnotations(ing.GetAnnotations()).parseBool(v)
+}
+
+// GetStringAnnotation extracts a string from an Ingress annotation
+func GetStringAnnotation(name string, ing *networking.Ingress) (string, error) {
+	v, err := checkAnnotation(name, ing)
+	if err != nil {
+		return "", err
+	}
+
+	return ingAnnotations(ing.GetAnnotations()).parseString(v)
+}
+
+// GetIntAnnotation extracts an int from an Ingress annotation
+func GetIntAnnotation(name string, ing *networking.Ingress) (int, error) {
+	v, err := checkAnnotation(name, ing)
+	if err != nil {
+		return 0, err
+	}
+	return ingAnnotations(ing.GetAnnotations()).parseInt(v)
+}
+
+// GetFloatAnnotation extracts a float32 from an Ingress annotation
+func GetFloatAnnotation(name string, ing *networking.Ingress) (float32, error) {
+	v, err := checkAnnotation(name, ing)
+	if err != nil {
+		return 0, err
+	}
+	return ingAnnotations(ing.GetAnnotations()).parseFloat32(v)
+}
+
+// GetAnnotationWithPrefix returns the prefix of ingress annotations
+func GetAnnotationWithPrefix(suffix string) string {
+	return fmt.Sprintf("%v/%v", AnnotationsPrefix, suffix)
+}
+
+func normalizeString(input string) string {
+	trimmedContent := []string{}
+	for _, line := range strings.Split(input, "\n") {
+		trimmedContent = append(trimmedContent, strings.TrimSpace(line))
+	}
+
+	return strings.Join(trimmedContent, "\n")
+}
+
+// StringToURL parses the provided string into URL and returns error
+// message in case of failure
+func StringToURL(input string) (*url.URL, error) {
+	parsedURL, err := url.Parse(input)
+	if err != nil {
+		return nil, fmt.Errorf("%v is not a valid URL: %v", input, err)
+	}
+
+	if parsedURL.Scheme == "" {
+		return nil, fmt.Errorf("url scheme is empty")
+	} else if parsedURL.Host == "" {
+		return nil, fmt.Errorf("url host is empty")
+	} else if strings.Contains(parsedURL.Host, "..") {
+		return nil, fmt.Errorf("invalid url host")
+	}
+
+	return parsedURL, nil
+}
@@ -0,0 +1,160 @@
+// Package proxy parses the proxy timeout and buffer annotations.
+package proxy
+
+import (
+	"regexp"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+)
+
+var (
+	validUpstreamAnnotation = regexp.MustCompile(`^((error|timeout|invalid_header|http_500|http_502|http_503|http_504|http_403|http_404|http_429|non_idempotent|off)\s?)+$`)
+	validHTTPVersion        = regexp.MustCompile(`^(1\.0|1\.1)$`)
+	validOnOff              = regexp.MustCompile(`^(on|off)$`)
+)
+
+// Config returns the proxy timeout to use in the upstream server/s
+type Config struct {
+	BodySize             string `json:"bodySize"`
+	ConnectTimeout       int    `json:"connectTimeout"`
+	SendTimeout          int    `json:"sendTimeout"`
+	ReadTimeout          int    `json:"readTimeout"`
+	BuffersNumber        int    `json:"buffersNumber"`
+	BufferSize           string `json:"bufferSize"`
+	CookieDomain         string `json:"cookieDomain"`
+	CookiePath           string `json:"cookiePath"`
+	NextUpstream         string `json:"nextUpstream"`
+	NextUpstreamTimeout  int    `json:"nextUpstreamTimeout"`
+	NextUpstreamTries    int    `json:"nextUpstreamTries"`
+	ProxyRedirectFrom    string `json:"proxyRedirectFrom"`
+	ProxyRedirectTo      string `json:"proxyRedirectTo"`
+	RequestBuffering     string `json:"requestBuffering"`
+	ProxyBuffering       string `json:"proxyBuffering"`
+	ProxyHTTPVersion     string `json:"proxyHTTPVersion"`
+	ProxyMaxTempFileSize string `json:"proxyMaxTempFileSize"`
+	ProxyBusyBuffersSize string `json:"proxyBusyBuffersSize"`
+}
+
+// Equal tests for equality between two Configuration types
+func (l1 *Config) Equal(l2 *Config) bool {
+	if l1 == l2 {
+		return true
+	}
+	if l1 == nil || l2 == nil {
+		return false
+	}
+	return *l1 == *l2
+}
+
+type proxy struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new reverse proxy configuration annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return proxy{r}
+}
+
+// ParseAnnotations parses the annotations contained in the ingress
+// rule used to configure upstream check parameters
+func (a proxy) Parse(ing *networking.Ingress) (interface{}, error) {
+	defBackend := a.r.GetDefaultBackend()
+	config := &Config{}
+
+	var err error
+
+	config.ConnectTimeout, err = parser.GetIntAnnotation("proxy-connect-timeout", ing)
+	if err != nil {
+		config.ConnectTimeout = defBackend.ProxyConnectTimeout
+	}
+
+	config.SendTimeout, err = parser.GetIntAnnotation("proxy-send-timeout", ing)
+	if err != nil {
+		config.SendTimeout = defBackend.ProxySendTimeout
+	}
+
+	config.ReadTimeout, err = parser.GetIntAnnotation("proxy-read-timeout", ing)
+	if err != nil {
+		config.ReadTimeout = defBackend.ProxyReadTimeout
+	}
+
+	config.BuffersNumber, err = parser.GetIntAnnotation("proxy-buffers-number", ing)
+	if err != nil {
+		config.BuffersNumber = defBackend.ProxyBuffersNumber
+	}
+
+	config.BufferSize, err = parser.GetStringAnnotation("proxy-buffer-size", ing)
+	if err != nil {
+		config.BufferSize = defBackend.ProxyBufferSize
+	}
+
+	config.ProxyBusyBuffersSize, err = parser.GetStringAnnotation("proxy-busy-buffers-size", ing)
+	if err != nil {
+		config.ProxyBusyBuffersSize = defBackend.ProxyBusyBuffersSize
+	}
+
+	config.CookiePath, err = parser.GetStringAnnotation("proxy-cookie-path", ing)
+	if err != nil {
+		config.CookiePath = defBackend.ProxyCookiePath
+	}
+
+	config.CookieDomain, err = parser.GetStringAnnotation("proxy-cookie-domain", ing)
+	if err != nil {
+		config.CookieDomain = defBackend.ProxyCookieDomain
+	}
+
+	config.BodySize, err = parser.GetStringAnnotation("proxy-body-size", ing)
+	if err != nil {
+		config.BodySize = defBackend.ProxyBodySize
+	}
+
+	config.NextUpstream, err = parser.GetStringAnnotation("proxy-next-upstream", ing)
+	if err != nil || !validUpstreamAnnotation.MatchString(config.NextUpstream) {
+		config.NextUpstream = defBackend.ProxyNextUpstream
+	}
+
+	config.NextUpstreamTimeout, err = parser.GetIntAnnotation("proxy-next-upstream-timeout", ing)
+	if err != nil {
+		config.NextUpstreamTimeout = defBackend.ProxyNextUpstreamTimeout
+	}
+
+	config.NextUpstreamTries, err = parser.GetIntAnnotation("proxy-next-upstream-tries", ing)
+	if err != nil {
+		config.NextUpstreamTries = defBackend.ProxyNextUpstreamTries
+	}
+
+	config.RequestBuffering, err = parser.GetStringAnnotation("proxy-request-buffering", ing)
+	if err != nil || !validOnOff.MatchString(config.RequestBuffering) {
+		config.RequestBuffering = defBackend.ProxyRequestBuffering
+	}
+
+	config.ProxyRedirectFrom, err = parser.GetStringAnnotation("proxy-redirect-from", ing)
+	if err != nil {
+		config.ProxyRedirectFrom = defBackend.ProxyRedirectFrom
+	}
+
+	config.ProxyRedirectTo, err = parser.GetStringAnnotation("proxy-redirect-to", ing)
+	if err != nil {
+		config.ProxyRedirectTo = defBackend.ProxyRedirectTo
+	}
+
+	config.ProxyBuffering, err = parser.GetStringAnnotation("proxy-buffering", ing)
+	if err != nil || !validOnOff.MatchString(config.ProxyBuffering) {
+		config.ProxyBuffering = defBackend.ProxyBuffering
+	}
+
+	config.ProxyHTTPVersion, err = parser.GetStringAnnotation("proxy-http-version", ing)
+	if err != nil || !validHTTPVersion.MatchString(config.ProxyHTTPVersion) {
+		config.ProxyHTTPVersion = defBackend.ProxyHTTPVersion
+	}
+
+	config.ProxyMaxTempFileSize, err = parser.GetStringAnnotation("proxy-max-temp-file-size", ing)
+	if err != nil {
+		config.ProxyMaxTempFileSize = defBackend.ProxyMaxTempFileSize
+	}
+
+	return config, nil
+}
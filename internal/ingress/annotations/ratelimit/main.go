@@ -0,0 +1,194 @@
+// Package ratelimit parses the rate limit annotations.
+package ratelimit
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	ing_errors "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/errors"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+)
+
+const (
+	defBurst      = 5
+	defSharedSize = 5
+)
+
+// Config returns rate limit configuration for an Ingress rule limiting the
+// number of connections per IP address and/or connections per second.
+// If you both annotations are specified in a single Ingress rule, RPS limits
+// takes precedence
+type Config struct {
+	// Connections indicates a limit with the number of connections per IP address
+	Connections Zone `json:"connections"`
+	// RPS indicates a limit with the number of connections per second
+	RPS Zone `json:"rps"`
+
+	RPM Zone `json:"rpm"`
+
+	LimitRate int `json:"limit-rate"`
+
+	LimitRateAfter int `json:"limit-rate-after"`
+
+	Name string `json:"name"`
+
+	ID string `json:"id"`
+
+	Allowlist []string `json:"allowlist"`
+}
+
+// Equal tests for equality between two RateLimit types
+func (rt1 *Config) Equal(rt2 *Config) bool {
+	if rt1 == rt2 {
+		return true
+	}
+	if rt1 == nil || rt2 == nil {
+		return false
+	}
+	if rt1.Connections != rt2.Connections || rt1.RPS != rt2.RPS || rt1.RPM != rt2.RPM {
+		return false
+	}
+	if rt1.LimitRate != rt2.LimitRate || rt1.LimitRateAfter != rt2.LimitRateAfter {
+		return false
+	}
+	if rt1.Name != rt2.Name || rt1.ID != rt2.ID || len(rt1.Allowlist) != len(rt2.Allowlist) {
+		return false
+	}
+	for i := range rt1.Allowlist {
+		if rt1.Allowlist[i] != rt2.Allowlist[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Zone returns information about the NGINX rate limit (limit_req_zone)
+// http://nginx.org/en/docs/http/ngx_http_limit_req_module.html#limit_req_zone
+type Zone struct {
+	Name  string `json:"name"`
+	Limit int    `json:"limit"`
+	Burst int    `json:"burst"`
+	// SharedSize amount of shared memory for the zone
+	SharedSize int `json:"sharedSize"`
+}
+
+type ratelimit struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new ratelimit annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return ratelimit{r}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to rewrite the defined paths
+func (a ratelimit) Parse(ing *networking.Ingress) (interface{}, error) {
+	defBackend := a.r.GetDefaultBackend()
+	lr, err := parser.GetIntAnnotation("limit-rate", ing)
+	if err != nil {
+		lr = defBackend.LimitRate
+	}
+	lra, err := parser.GetIntAnnotation("limit-rate-after", ing)
+	if err != nil {
+		lra = defBackend.LimitRateAfter
+	}
+
+	rpm, err := parser.GetIntAnnotation("limit-rpm", ing)
+	if ing_errors.IsInvalidContent(err) {
+		return nil, err
+	}
+	rps, err := parser.GetIntAnnotation("limit-rps", ing)
+	if ing_errors.IsInvalidContent(err) {
+		return nil, err
+	}
+	conn, err := parser.GetIntAnnotation("limit-connections", ing)
+	if ing_errors.IsInvalidContent(err) {
+		return nil, err
+	}
+
+	var val string
+	for _, name := range []string{"limit-allowlist", "limit-whitelist"} {
+		val, err = parser.GetStringAnnotation(name, ing)
+		if err == nil || !ing_errors.IsMissingAnnotations(err) {
+			break
+		}
+	}
+	if err != nil && ing_errors.IsInvalidContent(err) {
+		return nil, err
+	}
+
+	cidrs := []string{}
+	for _, cidr := range strings.Split(val, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		if _, ipnet, perr := net.ParseCIDR(cidr); perr == nil {
+			cidrs = append(cidrs, ipnet.String())
+			continue
+		}
+		if ip := net.ParseIP(cidr); ip != nil {
+			cidrs = append(cidrs, ip.String())
+			continue
+		}
+		return nil, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("the annotation does not contain a valid IP address or network: %v", cidr),
+		}
+	}
+	sort.Strings(cidrs)
+
+	if rpm == 0 && rps == 0 && conn == 0 {
+		return &Config{
+			Connections:    Zone{},
+			RPS:            Zone{},
+			RPM:            Zone{},
+			LimitRate:      lr,
+			LimitRateAfter: lra,
+		}, nil
+	}
+
+	burstMultiplier, err := parser.GetIntAnnotation("limit-burst-multiplier", ing)
+	if err != nil {
+		burstMultiplier = defBurst
+	}
+
+	zoneName := fmt.Sprintf("%v_%v_%v", ing.GetNamespace(), ing.GetName(), ing.UID)
+
+	return &Config{
+		Connections: Zone{
+			Name:       fmt.Sprintf("%v_conn", zoneName),
+			Limit:      conn,
+			Burst:      conn * burstMultiplier,
+			SharedSize: defSharedSize,
+		},
+		RPS: Zone{
+			Name:       fmt.Sprintf("%v_rps", zoneName),
+			Limit:      rps,
+			Burst:      rps * burstMultiplier,
+			SharedSize: defSharedSize,
+		},
+		RPM: Zone{
+			Name:       fmt.Sprintf("%v_rpm", zoneName),
+			Limit:      rpm,
+			Burst:      rpm * burstMultiplier,
+			SharedSize: defSharedSize,
+		},
+		LimitRate:      lr,
+		LimitRateAfter: lra,
+		Name:           zoneName,
+		ID:             encode(zoneName),
+		Allowlist:      cidrs,
+	}, nil
+}
+
+func encode(s string) string {
+	str := base64.URLEncoding.EncodeToString([]byte(s))
+	return strings.ReplaceAll(str, "=", "")
+}
@@ -0,0 +1,123 @@
+// Package redirect parses the redirect annotations.
+package redirect
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	ing_errors "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/errors"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+)
+
+// Config returns the redirect configuration for an Ingress rule
+type Config struct {
+	URL       string `json:"url"`
+	Code      int    `json:"code"`
+	FromToWWW bool   `json:"fromToWWW"`
+}
+
+const defaultPermanentRedirectCode = http.StatusMovedPermanently
+
+type redirect struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new redirect annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return redirect{r}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to create a redirect in the paths defined in the rule.
+// If the Ingress contains both annotations the execution order is
+// temporal and then permanent
+func (r redirect) Parse(ing *networking.Ingress) (interface{}, error) {
+	r3w, err := parser.GetBoolAnnotation("from-to-www-redirect", ing)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return nil, err
+	}
+
+	tr, err := parser.GetStringAnnotation("temporal-redirect", ing)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return nil, err
+	}
+
+	if tr != "" {
+		if err := isValidURL(tr); err != nil {
+			return nil, err
+		}
+
+		trc, err := parser.GetIntAnnotation("temporal-redirect-code", ing)
+		if err != nil && !ing_errors.IsMissingAnnotations(err) {
+			return nil, err
+		}
+
+		if trc < http.StatusMultipleChoices || trc > http.StatusPermanentRedirect {
+			trc = http.StatusFound
+		}
+
+		return &Config{
+			URL:       tr,
+			Code:      trc,
+			FromToWWW: r3w,
+		}, nil
+	}
+
+	pr, err := parser.GetStringAnnotation("permanent-redirect", ing)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return nil, err
+	}
+
+	prc, err := parser.GetIntAnnotation("permanent-redirect-code", ing)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return nil, err
+	}
+
+	if prc < http.StatusMultipleChoices || prc > http.StatusPermanentRedirect {
+		prc = defaultPermanentRedirectCode
+	}
+
+	if pr != "" || r3w {
+		if pr != "" {
+			if err := isValidURL(pr); err != nil {
+				return nil, err
+			}
+		}
+		return &Config{
+			URL:       pr,
+			Code:      prc,
+			FromToWWW: r3w,
+		}, nil
+	}
+
+	return nil, ing_errors.ErrMissingAnnotations
+}
+
+// Equal tests for equality between two Redirect types
+func (r1 *Config) Equal(r2 *Config) bool {
+	if r1 == r2 {
+		return true
+	}
+	if r1 == nil || r2 == nil {
+		return false
+	}
+	return *r1 == *r2
+}
+
+func isValidURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(u.Scheme, "http") {
+		return ing_errors.NewLocationDenied(fmt.Sprintf("only http and https are valid protocols (%v)", u.Scheme))
+	}
+
+	return nil
+}
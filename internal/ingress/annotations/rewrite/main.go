@@ -0,0 +1,111 @@
+// Package rewrite parses the rewrite and TLS redirect annotations.
+package rewrite
+
+import (
+	"net/url"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/errors"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+)
+
+// Config describes the per location redirect config
+type Config struct {
+	// Target URI where the traffic must be redirected
+	Target string `json:"target"`
+	// SSLRedirect indicates if the location section is accessible SSL only
+	SSLRedirect bool `json:"sslRedirect"`
+	// ForceSSLRedirect indicates if the location section is accessible SSL only
+	ForceSSLRedirect bool `json:"forceSSLRedirect"`
+	// PreserveTrailingSlash indicates if the trailing slash should be kept during a tls redirect
+	PreserveTrailingSlash bool `json:"preserveTrailingSlash"`
+	// AppRoot defines the Application Root that the Controller must redirect if it's in '/' context
+	AppRoot string `json:"appRoot"`
+	// UseRegex indicates whether or not the locations use regex paths
+	UseRegex bool `json:"useRegex"`
+}
+
+// Equal tests for equality between two Redirect types
+func (r1 *Config) Equal(r2 *Config) bool {
+	if r1 == r2 {
+		return true
+	}
+	if r1 == nil || r2 == nil {
+		return false
+	}
+	return *r1 == *r2
+}
+
+type rewrite struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new rewrite annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return rewrite{r}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to rewrite the defined paths
+func (a rewrite) Parse(ing *networking.Ingress) (interface{}, error) {
+	var err error
+	config := &Config{}
+
+	config.Target, err = parser.GetStringAnnotation("rewrite-target", ing)
+	if err != nil {
+		if errors.IsInvalidContent(err) {
+			return nil, err
+		}
+	}
+	config.SSLRedirect, err = parser.GetBoolAnnotation("ssl-redirect", ing)
+	if err != nil {
+		if errors.IsInvalidContent(err) {
+			return nil, err
+		}
+		config.SSLRedirect = a.r.GetDefaultBackend().SSLRedirect
+	}
+	config.PreserveTrailingSlash, err = parser.GetBoolAnnotation("preserve-trailing-slash", ing)
+	if err != nil {
+		if errors.IsInvalidContent(err) {
+			return nil, err
+		}
+		config.PreserveTrailingSlash = a.r.GetDefaultBackend().PreserveTrailingSlash
+	}
+
+	config.ForceSSLRedirect, err = parser.GetBoolAnnotation("force-ssl-redirect", ing)
+	if err != nil {
+		if errors.IsInvalidContent(err) {
+			return nil, err
+		}
+		config.ForceSSLRedirect = a.r.GetDefaultBackend().ForceSSLRedirect
+	}
+
+	config.UseRegex, err = parser.GetBoolAnnotation("use-regex", ing)
+	if err != nil {
+		if errors.IsInvalidContent(err) {
+			return nil, err
+		}
+	}
+
+	config.AppRoot, err = parser.GetStringAnnotation("app-root", ing)
+	if err != nil {
+		if errors.IsInvalidContent(err) {
+			return nil, err
+		}
+		return config, nil
+	}
+
+	u, err := url.ParseRequestURI(config.AppRoot)
+	if err != nil {
+		config.AppRoot = ""
+		return config, nil
+	}
+	if u.IsAbs() {
+		config.AppRoot = ""
+		return config, nil
+	}
+
+	return config, nil
+}
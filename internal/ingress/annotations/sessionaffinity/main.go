@@ -0,0 +1,156 @@
+// Package sessionaffinity parses the session affinity annotations.
+package sessionaffinity
+
+import (
+	"regexp"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+)
+
+const (
+	// If a cookie with this name exists,
+	// its value is used as an index into the list of available backends.
+	defaultAffinityCookieName = "INGRESSCOOKIE"
+)
+
+var (
+	affinityCookieExpiresRegex = regexp.MustCompile(`(^0|-?[1-9]\d*$)`)
+)
+
+// Config describes the per ingress session affinity config
+type Config struct {
+	// The type of affinity that will be used
+	Type string `json:"type"`
+	// The affinity mode, i.e. how sticky a session is
+	Mode string `json:"mode"`
+	// Affinity behavior for canaries (sticky or legacy)
+	CanaryBehavior string `json:"canaryBehavior"`
+	Cookie
+}
+
+// Cookie describes the Config of cookie type affinity
+type Cookie struct {
+	// The name of the cookie that will be used in case of cookie affinity type.
+	Name string `json:"name"`
+	// The time duration to control cookie expires
+	Expires string `json:"expires"`
+	// The number of seconds until the cookie expires
+	MaxAge string `json:"maxage"`
+	// The path that a cookie will be set on
+	Path string `json:"path"`
+	// The domain that a cookie will be set on
+	Domain string `json:"domain"`
+	// Flag that allows cookie regeneration on request failure
+	ChangeOnFailure bool `json:"changeonfailure"`
+	// Secure flag to be set
+	Secure bool `json:"secure"`
+	// SameSite attribute value
+	SameSite string `json:"samesite"`
+	// Flag that conditionally applies SameSite=None attribute on cookie if user agent accepts it.
+	ConditionalSameSiteNone bool `json:"conditional-samesite-none"`
+}
+
+type affinity struct {
+	r resolver.Resolver
+}
+
+// cookieAffinityParse gets the annotation values related to Cookie Affinity
+// It also sets default values when no value or incorrect value is found
+func (a affinity) cookieAffinityParse(ing *networking.Ingress) *Cookie {
+	var err error
+
+	cookie := &Cookie{}
+
+	cookie.Name, err = parser.GetStringAnnotation("session-cookie-name", ing)
+	if err != nil {
+		klog.V(3).InfoS("Invalid or no annotation value found. Ignoring", "ingress", klog.KObj(ing), "annotation", "session-cookie-name", "default", defaultAffinityCookieName)
+		cookie.Name = defaultAffinityCookieName
+	}
+
+	cookie.Expires, err = parser.GetStringAnnotation("session-cookie-expires", ing)
+	if err != nil || !affinityCookieExpiresRegex.MatchString(cookie.Expires) {
+		cookie.Expires = ""
+	}
+
+	cookie.MaxAge, err = parser.GetStringAnnotation("session-cookie-max-age", ing)
+	if err != nil || !affinityCookieExpiresRegex.MatchString(cookie.MaxAge) {
+		cookie.MaxAge = ""
+	}
+
+	cookie.Path, err = parser.GetStringAnnotation("session-cookie-path", ing)
+	if err != nil {
+		cookie.Path = ""
+	}
+
+	cookie.Domain, err = parser.GetStringAnnotation("session-cookie-domain", ing)
+	if err != nil {
+		cookie.Domain = ""
+	}
+
+	cookie.SameSite, err = parser.GetStringAnnotation("session-cookie-samesite", ing)
+	if err != nil {
+		cookie.SameSite = ""
+	}
+
+	cookie.Secure, err = parser.GetBoolAnnotation("session-cookie-secure", ing)
+	if err != nil {
+		cookie.Secure = false
+	}
+
+	cookie.ConditionalSameSiteNone, err = parser.GetBoolAnnotation("session-cookie-conditional-samesite-none", ing)
+	if err != nil {
+		cookie.ConditionalSameSiteNone = false
+	}
+
+	cookie.ChangeOnFailure, err = parser.GetBoolAnnotation("session-cookie-change-on-failure", ing)
+	if err != nil {
+		cookie.ChangeOnFailure = false
+	}
+
+	return cookie
+}
+
+// NewParser creates a new Affinity annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return affinity{r}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to configure the affinity directives
+func (a affinity) Parse(ing *networking.Ingress) (interface{}, error) {
+	cookie := &Cookie{}
+	// Check the type of affinity that will be used
+	at, err := parser.GetStringAnnotation("affinity", ing)
+	if err != nil {
+		at = ""
+	}
+
+	// Check the affinity mode that will be used
+	am, err := parser.GetStringAnnotation("affinity-mode", ing)
+	if err != nil {
+		am = ""
+	}
+
+	cb, err := parser.GetStringAnnotation("affinity-canary-behavior", ing)
+	if err != nil {
+		cb = ""
+	}
+
+	switch at {
+	case "cookie":
+		cookie = a.cookieAffinityParse(ing)
+	default:
+		klog.V(3).InfoS("No default affinity found", "ingress", ing.Name)
+	}
+
+	return &Config{
+		Type:           at,
+		Mode:           am,
+		CanaryBehavior: cb,
+		Cookie:         *cookie,
+	}, nil
+}
@@ -0,0 +1,62 @@
+// Package sslcipher parses the ssl-ciphers and ssl-prefer-server-ciphers
+// annotations.
+package sslcipher
+
+import (
+	"regexp"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	ing_errors "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/errors"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+)
+
+// Should cover something like "ALL:!aNULL:!EXPORT56:RC4+RSA:+HIGH:+MEDIUM:+LOW:+SSLv2:+EXP"
+// (?:@STRENGTH) is included twice so it can appear before or after @SECLEVEL=n
+var regexValidSSLCipher = regexp.MustCompile(`^(?:(?:[A-Za-z0-9!:+\-])*(?:@STRENGTH)*(?:@SECLEVEL=[0-7])*(?:@STRENGTH)*)*$`)
+
+type sslCipher struct {
+	r resolver.Resolver
+}
+
+// Config contains the ssl-ciphers & ssl-prefer-server-ciphers configuration
+type Config struct {
+	SSLCiphers             string
+	SSLPreferServerCiphers string
+}
+
+// NewParser creates a new sslCipher annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return sslCipher{r}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to add ssl-ciphers & ssl-prefer-server-ciphers to the server name
+func (sc sslCipher) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{}
+	var err error
+	var s bool
+
+	s, err = parser.GetBoolAnnotation("ssl-prefer-server-ciphers", ing)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return config, err
+	}
+	if err == nil {
+		if s {
+			config.SSLPreferServerCiphers = "on"
+		} else {
+			config.SSLPreferServerCiphers = "off"
+		}
+	}
+
+	config.SSLCiphers, err = parser.GetStringAnnotation("ssl-ciphers", ing)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return config, err
+	}
+	if config.SSLCiphers != "" && !regexValidSSLCipher.MatchString(config.SSLCiphers) {
+		return config, ing_errors.NewInvalidAnnotationContent("ssl-ciphers", config.SSLCiphers)
+	}
+
+	return config, nil
+}
@@ -0,0 +1,48 @@
+// Package upstreamhashby parses the upstream-hash-by annotations.
+package upstreamhashby
+
+import (
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+)
+
+type upstreamhashby struct {
+	r resolver.Resolver
+}
+
+// Config contains the Consistent hash configuration to be used in the Ingress
+type Config struct {
+	UpstreamHashBy           string `json:"upstream-hash-by,omitempty"`
+	UpstreamHashBySubset     bool   `json:"upstream-hash-by-subset,omitempty"`
+	UpstreamHashBySubsetSize int    `json:"upstream-hash-by-subset-size,omitempty"`
+}
+
+// NewParser creates a new UpstreamHashBy annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return upstreamhashby{r}
+}
+
+// Parse parses the annotations contained in the ingress rule
+func (a upstreamhashby) Parse(ing *networking.Ingress) (interface{}, error) {
+	defBackend := a.r.GetDefaultBackend()
+
+	upstreamHashBy, err := parser.GetStringAnnotation("upstream-hash-by", ing)
+	if err != nil {
+		upstreamHashBy = defBackend.UpstreamHashBy
+	}
+	upstreamHashBySubset, err := parser.GetBoolAnnotation("upstream-hash-by-subset", ing)
+	if err != nil {
+		upstreamHashBySubset = defBackend.UpstreamHashBySubset
+	}
+	upstreamHashbySubsetSize, err := parser.GetIntAnnotation("upstream-hash-by-subset-size", ing)
+	if err != nil {
+		upstreamHashbySubsetSize = defBackend.UpstreamHashBySubsetSize
+	}
+	if upstreamHashbySubsetSize == 0 {
+		upstreamHashbySubsetSize = 3
+	}
+
+	return &Config{upstreamHashBy, upstreamHashBySubset, upstreamHashbySubsetSize}, nil
+}
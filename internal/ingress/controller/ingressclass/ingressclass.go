@@ -0,0 +1,38 @@
+// Package ingressclass contains the IngressClass configuration of the
+// ingress controller, from
+// k8s.io/ingress-nginx/internal/ingress/controller/ingressclass.
+package ingressclass
+
+const (
+	// DefaultControllerName defines the default controller name for Ingress NGINX
+	DefaultControllerName = "k8s.io/ingress-nginx"
+
+	// DefaultAnnotationValue defines the default annotation value for the ingress-nginx controller
+	DefaultAnnotationValue = "nginx"
+
+	// IngressKey picks a specific "class" for the Ingress.
+	// The controller only processes Ingresses with this annotation either
+	// unset, or set to either the configured value or the empty string.
+	IngressKey = "kubernetes.io/ingress.class"
+)
+
+// Configuration defines the various aspects of IngressClass parsing
+// and how the controller should behave in each case
+type Configuration struct {
+	// Controller defines the controller value this daemon watch to.
+	// Defaults to "k8s.io/ingress-nginx" defined in flags
+	Controller string
+	// AnnotationValue defines the annotation value this Controller watch to, in case of the
+	// ingressSpecName is not found but the annotation is.
+	// The Annotation is deprecated and should not be used in future releases
+	AnnotationValue string
+	// WatchWithoutClass defines if Controller should watch to Ingress Objects that does
+	// not contain an IngressClass configuration
+	WatchWithoutClass bool
+	// IgnoreIngressClass defines if Controller should ignore the IngressClass Object if no permissions are
+	// granted on IngressClass
+	IgnoreIngressClass bool
+	// IngressClassByName defines if the Controller should watch for Ingress Classes by
+	// .metadata.name together with .spec.Controller
+	IngressClassByName bool
+}
@@ -0,0 +1,312 @@
+// Package template reads the ConfigMap of the ingress controller into its
+// configuration. It is a trimmed copy of
+// k8s.io/ingress-nginx/internal/ingress/controller/template.
+package template
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/authreq"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/controller/config"
+)
+
+const (
+	customHTTPErrors          = "custom-http-errors"
+	skipAccessLogUrls         = "skip-access-log-urls"
+	whitelistSourceRange      = "whitelist-source-range"
+	denylistSourceRange       = "denylist-source-range"
+	proxyRealIPCIDR           = "proxy-real-ip-cidr"
+	bindAddress               = "bind-address"
+	httpRedirectCode          = "http-redirect-code"
+	blockCIDRs                = "block-cidrs"
+	blockUserAgents           = "block-user-agents"
+	blockReferers             = "block-referers"
+	proxyStreamResponses      = "proxy-stream-responses"
+	hideHeaders               = "hide-headers"
+	nginxStatusIpv4Whitelist  = "nginx-status-ipv4-whitelist"
+	nginxStatusIpv6Whitelist  = "nginx-status-ipv6-whitelist"
+	plugins                   = "plugins"
+	luaSharedDictsKey         = "lua-shared-dicts"
+	globalAuthURL             = "global-auth-url"
+	globalAuthMethod          = "global-auth-method"
+	globalAuthSignin          = "global-auth-signin"
+	globalAuthSigninRedirect  = "global-auth-signin-redirect-param"
+	globalAuthResponseHeaders = "global-auth-response-headers"
+	globalAuthRequestRedirect = "global-auth-request-redirect"
+	globalAuthSnippet         = "global-auth-snippet"
+	globalAuthCacheKey        = "global-auth-cache-key"
+	globalAuthCacheDuration   = "global-auth-cache-duration"
+	globalAuthAlwaysSetCookie = "global-auth-always-set-cookie"
+)
+
+var validRedirectCodes = map[int]bool{301: true, 302: true, 307: true, 308: true}
+
+// ReadConfig obtains the configuration defined by the user merged with the defaults.
+func ReadConfig(src map[string]string) config.Configuration {
+	conf := make(map[string]string, len(src))
+	for k, v := range src {
+		conf[k] = v
+	}
+
+	to := config.NewDefault()
+
+	if val, ok := conf[customHTTPErrors]; ok {
+		delete(conf, customHTTPErrors)
+		codes := []int{}
+		for _, i := range splitList(val) {
+			j, err := strconv.Atoi(i)
+			if err != nil {
+				klog.Warningf("%v is not a valid http code: %v", i, err)
+				continue
+			}
+			codes = append(codes, j)
+		}
+		to.CustomHTTPErrors = codes
+	}
+
+	if val, ok := conf[bindAddress]; ok {
+		delete(conf, bindAddress)
+		bindAddressIpv4List, bindAddressIpv6List := []string{}, []string{}
+		for _, i := range splitList(val) {
+			ns := net.ParseIP(i)
+			switch {
+			case ns == nil:
+				klog.Warningf("%v is not a valid textual representation of an IP address", i)
+			case ns.To4() != nil:
+				bindAddressIpv4List = append(bindAddressIpv4List, i)
+			default:
+				bindAddressIpv6List = append(bindAddressIpv6List, i)
+			}
+		}
+		to.BindAddressIpv4 = bindAddressIpv4List
+		to.BindAddressIpv6 = bindAddressIpv6List
+	}
+
+	if val, ok := conf[httpRedirectCode]; ok {
+		delete(conf, httpRedirectCode)
+		j, err := strconv.Atoi(val)
+		if err != nil || !validRedirectCodes[j] {
+			klog.Warningf("The code %v is not valid as HTTP redirect code. Using the default.", val)
+		} else {
+			to.HTTPRedirectCode = j
+		}
+	}
+
+	if val, ok := conf[luaSharedDictsKey]; ok {
+		delete(conf, luaSharedDictsKey)
+		to.LuaSharedDicts = map[string]int{}
+		for _, v := range splitList(val) {
+			name, size, found := strings.Cut(v, ":")
+			if !found {
+				klog.Warningf("invalid lua shared dict %q", v)
+				continue
+			}
+			s, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(strings.ToLower(size)), "k"))
+			if err != nil {
+				klog.Warningf("invalid size of lua shared dict %q", v)
+				continue
+			}
+			to.LuaSharedDicts[strings.TrimSpace(name)] = s
+		}
+	}
+
+	readGlobalExternalAuth(conf, &to.GlobalExternalAuth)
+
+	decode(conf, reflect.ValueOf(&to).Elem())
+
+	if to.ProxyStreamResponses <= 0 {
+		to.ProxyStreamResponses = 1
+	}
+
+	return to
+}
+
+// readGlobalExternalAuth reads the global-auth-* keys, removing them from conf
+func readGlobalExternalAuth(conf map[string]string, to *config.GlobalExternalAuth) {
+	if val, ok := conf[globalAuthURL]; ok {
+		delete(conf, globalAuthURL)
+		authURL, err := parseURL(val)
+		if err != nil {
+			klog.Warningf("Global auth location denied - %v.", err)
+		} else {
+			to.URL = val
+			to.Host = authURL.Hostname()
+		}
+	}
+
+	// Verify that the configured global external authorization method is a valid HTTP method
+	if val, ok := conf[globalAuthMethod]; ok {
+		delete(conf, globalAuthMethod)
+		if !authreq.ValidMethod(val) {
+			klog.Warningf("Global auth location denied - %v.", "invalid HTTP method")
+		} else {
+			to.Method = val
+		}
+	}
+
+	// Verify that the configured global external authorization error page is set and valid. if not, set the default value
+	if val, ok := conf[globalAuthSignin]; ok {
+		delete(conf, globalAuthSignin)
+		if _, err := parseURL(val); err != nil {
+			klog.Warningf("Global auth location denied - %v.", "global-auth-signin setting is undefined and will not be set")
+		} else {
+			to.SigninURL = val
+		}
+	}
+
+	if val, ok := conf[globalAuthSigninRedirect]; ok {
+		delete(conf, globalAuthSigninRedirect)
+		to.SigninURLRedirectParam = val
+	}
+
+	// Verify that the configured global external authorization response headers are valid. if not, set the default value
+	if val, ok := conf[globalAuthResponseHeaders]; ok {
+		delete(conf, globalAuthResponseHeaders)
+		responseHeaders := []string{}
+		for _, header := range splitList(val) {
+			if !authreq.ValidHeader(header) {
+				klog.Warningf("Global auth location denied - %v.", "invalid headers list")
+				responseHeaders = []string{}
+				break
+			}
+			responseHeaders = append(responseHeaders, header)
+		}
+		to.ResponseHeaders = responseHeaders
+	}
+
+	if val, ok := conf[globalAuthRequestRedirect]; ok {
+		delete(conf, globalAuthRequestRedirect)
+		to.RequestRedirect = val
+	}
+
+	if val, ok := conf[globalAuthSnippet]; ok {
+		delete(conf, globalAuthSnippet)
+		to.AuthSnippet = val
+	}
+
+	if val, ok := conf[globalAuthCacheKey]; ok {
+		delete(conf, globalAuthCacheKey)
+		to.AuthCacheKey = val
+	}
+
+	// Verify that the configured global external authorization cache duration is valid
+	if val, ok := conf[globalAuthCacheDuration]; ok {
+		delete(conf, globalAuthCacheDuration)
+		cacheDurations, err := authreq.ParseStringToCacheDurations(val)
+		if err != nil {
+			klog.Warningf("Global auth location denied - %s", err)
+		}
+		to.AuthCacheDuration = cacheDurations
+	}
+
+	if val, ok := conf[globalAuthAlwaysSetCookie]; ok {
+		delete(conf, globalAuthAlwaysSetCookie)
+		alwaysSetCookie, err := strconv.ParseBool(val)
+		if err != nil {
+			klog.Warningf("Global auth location denied - %s", err)
+		}
+		to.AlwaysSetCookie = alwaysSetCookie
+	}
+}
+
+// decode sets the fields of v from the values of conf matching their json
+// tags, converting the strings the way mapstructure does with weakly typed
+// input. Anonymous fields are squashed.
+func decode(conf map[string]string, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			decode(conf, v.Field(i))
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		val, ok := conf[name]
+		if !ok {
+			continue
+		}
+
+		if err := setValue(v.Field(i), val); err != nil {
+			klog.Warningf("unexpected error merging defaults: %v is not a valid value of %v: %v", val, name, err)
+		}
+	}
+}
+
+func setValue(f reflect.Value, val string) error {
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(val)
+	case reflect.Bool:
+		if val == "" {
+			f.SetBool(false)
+			return nil
+		}
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int:
+		if val == "" {
+			f.SetInt(0)
+			return nil
+		}
+		i, err := strconv.Atoi(val)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(i))
+	case reflect.Float32, reflect.Float64:
+		if val == "" {
+			f.SetFloat(0)
+			return nil
+		}
+		fl, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return err
+		}
+		f.SetFloat(fl)
+	case reflect.Slice:
+		if f.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		f.Set(reflect.ValueOf(splitList(val)))
+	}
+	return nil
+}
+
+// splitList splits a comma separated list, dropping the empty values
+func splitList(val string) []string {
+	list := []string{}
+	for _, v := range strings.Split(val, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func parseURL(val string) (*url.URL, error) {
+	u, err := url.Parse(val)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("%v is not a valid URL: the scheme or the host is empty", val)
+	}
+	return u, nil
+}
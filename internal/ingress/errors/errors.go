@@ -0,0 +1,90 @@
+// Package errors contains the errors of the annotation parsers, from
+// k8s.io/ingress-nginx/internal/ingress/errors.
+package errors
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrMissingAnnotations the ingress rule does not contain annotations
+	// This is an error only when annotations are being parsed
+	ErrMissingAnnotations = errors.New("ingress rule without annotations")
+
+	// ErrInvalidAnnotationName the ingress rule does contains an invalid
+	// annotation name
+	ErrInvalidAnnotationName = errors.New("invalid annotation name")
+)
+
+// NewInvalidAnnotationContent returns a new InvalidContent error
+func NewInvalidAnnotationContent(name string, val interface{}) error {
+	return InvalidContentError{
+		Name: fmt.Sprintf("the annotation %v does not contain a valid value (%v)", name, val),
+	}
+}
+
+// NewInvalidAnnotationConfiguration returns a new InvalidConfiguration error for use when
+// annotations are not correctly configured
+func NewInvalidAnnotationConfiguration(name, reason string) error {
+	return InvalidConfigurationError{
+		Name: fmt.Sprintf("the annotation %v does not contain a valid configuration: %v", name, reason),
+	}
+}
+
+// NewLocationDenied returns a new LocationDenied error
+func NewLocationDenied(reason string) error {
+	return LocationDeniedError{
+		Reason: fmt.Errorf("location denied, reason: %v", reason),
+	}
+}
+
+// InvalidConfigurationError error
+type InvalidConfigurationError struct {
+	Name string
+}
+
+func (e InvalidConfigurationError) Error() string {
+	return e.Name
+}
+
+// InvalidContentError error
+type InvalidContentError struct {
+	Name string
+}
+
+func (e InvalidContentError) Error() string {
+	return e.Name
+}
+
+// LocationDeniedError error
+type LocationDeniedError struct {
+	Reason error
+}
+
+func (e LocationDeniedError) Error() string {
+	return e.Reason.Error()
+}
+
+// IsLocationDenied checks if the err is an error which
+// indicates a location should return HTTP code 503
+func IsLocationDenied(e error) bool {
+	_, ok := e.(LocationDeniedError)
+	return ok
+}
+
+// IsMissingAnnotations checks if the err is an error which
+// indicates the ingress does not contain annotations
+func IsMissingAnnotations(e error) bool {
+	return errors.Is(e, ErrMissingAnnotations)
+}
+
+// IsInvalidContent checks if the err is an error which
+// indicates an annotations value is not valid
+func IsInvalidContent(e error) bool {
+	switch e.(type) {
+	case InvalidContentError, InvalidConfigurationError:
+		return true
+	}
+	return false
+}
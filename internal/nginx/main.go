@@ -0,0 +1,13 @@
+// Package nginx contains the ports of the internal servers of ingress-nginx,
+// from k8s.io/ingress-nginx/internal/nginx.
+package nginx
+
+// ProfilerPort port used by the ingress controller to expose the Go Profiler
+// when it is enabled.
+var ProfilerPort = 10245
+
+// StatusPort port used by NGINX for the status server
+var StatusPort = 10246
+
+// StreamPort defines the port used by NGINX for the NGINX stream configuration socket
+var StreamPort = 10247
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// checkCertificateIssuers reports hosts served with a certificate that is
+// self-signed or not issued by a CA of the trust bundle, which includes the
+// fake certificate generated by the controller for hosts without a valid
+// TLS Secret. Production hosts are reported as errors. When no production
+// host is configured, the hosts with a TLS Secret are reported as warnings.
+func (n *NGINXController) checkCertificateIssuers(ingresses []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+
+	roots, err := n.trustBundle()
+	if err != nil {
+		return append(findings, Finding{
+			Rule:     "certificate-issuer",
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("unable to load the trust bundle: %v", err),
+		})
+	}
+
+	tlsSecrets := tlsSecretsByHost(ingresses)
+
+	for _, server := range cfg.Servers {
+		if server.Hostname == "_" || server.SSLPassthrough {
+			continue
+		}
+
+		severity := SeverityWarning
+		if len(n.cfg.ProductionHosts) > 0 {
+			if !n.isProductionHost(server.Hostname) {
+				continue
+			}
+			severity = SeverityError
+		}
+		if n.issuerExempt(server) {
+			continue
+		}
+
+		report := func(format string, args ...interface{}) {
+			findings = append(findings, Finding{
+				Rule:     "certificate-issuer",
+				Severity: severity,
+				Ingress:  serverIngress(server),
+				Host:     server.Hostname,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		}
+
+		secretKey := ""
+		if server.SSLCert != nil {
+			secretKey = fmt.Sprintf("%v/%v", server.SSLCert.Namespace, server.SSLCert.Name)
+		} else if key, ok := tlsSecrets[server.Hostname]; ok {
+			secretKey = key
+		}
+		if secretKey == "" && severity != SeverityError {
+			// only production hosts are required to use TLS
+			continue
+		}
+
+		chain, reason := n.servedCertificate(secretKey, server.Hostname)
+		if chain == nil {
+			if n.cfg.DefaultSSLCertificate == "" {
+				report("%v, https://%v serves the fake certificate generated by the controller", reason, server.Hostname)
+				continue
+			}
+			secretKey = n.cfg.DefaultSSLCertificate
+			if chain, _ = n.servedCertificate(secretKey, server.Hostname); chain == nil {
+				report("%v and the default certificate %v does not cover the host, https://%v serves an invalid certificate",
+					reason, secretKey, server.Hostname)
+				continue
+			}
+		}
+
+		leaf := chain[0]
+		if isSelfSigned(leaf) {
+			report("certificate of %v is self-signed (%q)", secretKey, leaf.Subject.String())
+			continue
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range chain[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := leaf.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			// expiry is checked by other rules, only the issuer matters here
+			CurrentTime: leaf.NotBefore,
+			KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		})
+		if err != nil {
+			report("certificate of %v is issued by %q, which is not trusted: %v", secretKey, leaf.Issuer.String(), err)
+		}
+	}
+
+	return findings
+}
+
+// servedCertificate returns the certificate chain of the TLS Secret when it
+// covers host, or the reason the controller falls back to the default
+// certificate
+func (n *NGINXController) servedCertificate(secretKey, host string) ([]*x509.Certificate, string) {
+	if secretKey == "" {
+		return nil, "no TLS Secret is configured for the host"
+	}
+
+	secret, err := n.store.GetSecret(secretKey)
+	if err != nil {
+		return nil, fmt.Sprintf("TLS Secret %v does not exist", secretKey)
+	}
+
+	chain, err := parsePEMCertificates(secret.Data[apiv1.TLSCertKey])
+	if err != nil || len(chain) == 0 {
+		return nil, fmt.Sprintf("TLS Secret %v does not contain a valid certificate", secretKey)
+	}
+	if err := chain[0].VerifyHostname(host); err != nil {
+		return nil, fmt.Sprintf("certificate of %v is not valid for the host", secretKey)
+	}
+	return chain, ""
+}
+
+// trustBundle returns the CAs the certificates must be issued by: the
+// certificates of TrustBundle, or the CAs of the system
+func (n *NGINXController) trustBundle() (*x509.CertPool, error) {
+	if n.cfg.TrustBundle == "" {
+		return x509.SystemCertPool()
+	}
+	return loadTrustBundle(n.cfg.TrustBundle)
+}
+
+// loadTrustBundle returns the certificates of a PEM file
+func loadTrustBundle(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cas, err := parsePEMCertificates(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(cas) == 0 {
+		return nil, fmt.Errorf("%v does not contain certificates", path)
+	}
+
+	pool := x509.NewCertPool()
+	for _, ca := range cas {
+		pool.AddCert(ca)
+	}
+	return pool, nil
+}
+
+// issuerExempt returns true if the host or the namespace of the Ingress
+// defining it is exempted from the issuer check
+func (n *NGINXController) issuerExempt(server *Server) bool {
+	for _, pattern := range n.cfg.IssuerExemptHosts {
+		if ok, err := path.Match(pattern, server.Hostname); err == nil && ok {
+			return true
+		}
+	}
+
+	for _, loc := range server.Locations {
+		if loc.Ingress != nil && containsString(n.cfg.IssuerExemptNamespaces, loc.Ingress.Namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// tlsSecretsByHost returns the TLS Secret used for each host: the first one
+// listed in the TLS section of the Ingresses
+func tlsSecretsByHost(ingresses []*Ingress) map[string]string {
+	secrets := map[string]string{}
+	for _, ing := range ingresses {
+		for _, tls := range ing.Spec.TLS {
+			if tls.SecretName == "" {
+				continue
+			}
+			for _, host := range tls.Hosts {
+				if _, ok := secrets[host]; !ok {
+					secrets[host] = fmt.Sprintf("%v/%v", ing.Namespace, tls.SecretName)
+				}
+			}
+		}
+	}
+	return secrets
+}
+
+// isSelfSigned returns true if the certificate is signed by its own key.
+// CheckSignatureFrom is not used because it rejects parents that are not CAs.
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawSubject, cert.RawIssuer) &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const issuerIngress = `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+spec:
+  ingressClassName: nginx
+  tls:
+  - hosts: [web.example.com]
+    secretName: web-tls
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`
+
+func TestCheckCertificateIssuers(t *testing.T) {
+	now := time.Now()
+	caTemplate := func(serial int64, name string) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             now.Add(-time.Hour),
+			NotAfter:              now.Add(time.Hour),
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign,
+		}
+	}
+	serverTemplate := func(serial int64) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "web.example.com"},
+			DNSNames:     []string{"web.example.com"},
+			NotBefore:    now.Add(-time.Hour),
+			NotAfter:     now.Add(time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+	}
+
+	ca, caKey, caPEM, _ := testCertificate(t, caTemplate(1, "trusted ca"), nil, nil)
+	other, otherKey, _, _ := testCertificate(t, caTemplate(2, "other ca"), nil, nil)
+	_, _, trustedPEM, trustedKeyPEM := testCertificate(t, serverTemplate(3), ca, caKey)
+	_, _, untrustedPEM, untrustedKeyPEM := testCertificate(t, serverTemplate(4), other, otherKey)
+	_, _, selfSignedPEM, selfSignedKeyPEM := testCertificate(t, serverTemplate(5), nil, nil)
+
+	bundle := filepath.Join(t.TempDir(), "bundle.pem")
+	if err := os.WriteFile(bundle, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		cert, key  []byte
+		production []string
+		exempt     []string
+		namespaces []string
+		expected   []string
+	}{
+		{
+			name:     "trusted certificate",
+			cert:     trustedPEM,
+			key:      trustedKeyPEM,
+			expected: []string{},
+		},
+		{
+			name:     "untrusted certificate",
+			cert:     untrustedPEM,
+			key:      untrustedKeyPEM,
+			expected: []string{`warning: certificate of default/web-tls is issued by "CN=other ca", which is not trusted`},
+		},
+		{
+			name:     "self-signed certificate",
+			cert:     selfSignedPEM,
+			key:      selfSignedKeyPEM,
+			expected: []string{`warning: certificate of default/web-tls is self-signed ("CN=web.example.com")`},
+		},
+		{
+			name:     "missing secret",
+			expected: []string{"warning: TLS Secret default/web-tls does not exist, https://web.example.com serves the fake certificate generated by the controller"},
+		},
+		{
+			name:       "production host",
+			cert:       selfSignedPEM,
+			key:        selfSignedKeyPEM,
+			production: []string{"*.example.com"},
+			expected:   []string{`error: certificate of default/web-tls is self-signed ("CN=web.example.com")`},
+		},
+		{
+			name:       "other host than production",
+			cert:       selfSignedPEM,
+			key:        selfSignedKeyPEM,
+			production: []string{"*.example.org"},
+			expected:   []string{},
+		},
+		{
+			name:     "exempt host",
+			cert:     selfSignedPEM,
+			key:      selfSignedKeyPEM,
+			exempt:   []string{"web.*"},
+			expected: []string{},
+		},
+		{
+			name:       "exempt namespace",
+			cert:       selfSignedPEM,
+			key:        selfSignedKeyPEM,
+			namespaces: []string{"default"},
+			expected:   []string{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n := newTestController(t, issuerIngress)
+			if tc.cert != nil {
+				if err := n.store.(*memoryStore).Add(&apiv1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "web-tls", Namespace: "default"},
+					Type:       apiv1.SecretTypeTLS,
+					Data:       map[string][]byte{apiv1.TLSCertKey: tc.cert, apiv1.TLSPrivateKeyKey: tc.key},
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			n.cfg.TrustBundle = bundle
+			n.cfg.ProductionHosts = tc.production
+			n.cfg.IssuerExemptHosts = tc.exempt
+			n.cfg.IssuerExemptNamespaces = tc.namespaces
+			ingresses := n.store.ListIngresses()
+			_, _, cfg := n.getConfiguration(ingresses)
+
+			got := []string{}
+			for _, f := range n.checkCertificateIssuers(ingresses, cfg) {
+				message, _, _ := strings.Cut(f.Message, ": x509")
+				got = append(got, string(f.Severity)+": "+message)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestTrustBundleErrors(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(empty, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bundle := range []string{filepath.Join(dir, "missing.pem"), empty} {
+		n := newTestController(t, "")
+		n.cfg.TrustBundle = bundle
+		findings := n.checkCertificateIssuers(nil, &Configuration{})
+		if len(findings) != 1 || findings[0].Severity != SeverityError {
+			t.Errorf("%v: expected an error finding, got %v", bundle, findings)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultElectionID  = "nginx-config-validator-leader"
+	defaultElectionTTL = 30 * time.Second
+)
+
+// addLeaderElectionFlags registers the flags of the leader election of the
+// webhook replicas
+func addLeaderElectionFlags(fs *flag.FlagSet, cfg *NginxConfiguration) {
+	fs.StringVar(&cfg.ElectionID, "election-id", defaultElectionID,
+		"Name of the Lease used to elect the replica publishing the reports, events and status of the Ingresses.")
+	fs.DurationVar(&cfg.ElectionTTL, "election-ttl", defaultElectionTTL,
+		"Duration of the Lease, the time the other replicas wait before taking over from a leader that stopped renewing it.")
+	fs.BoolVar(&cfg.DisableLeaderElection, "disable-leader-election", false,
+		"Publish the reports, events and status of the Ingresses from every replica.")
+}
+
+// leading returns true if this replica publishes the cluster-wide reports,
+// events and status updates: when it holds the Lease, or when the leader
+// election is disabled
+func (n *NGINXController) leading() bool {
+	return n.cfg.DisableLeaderElection || n.isLeader.Load()
+}
+
+// runLeaderElection competes for the Lease ElectionID, in the namespace of
+// the pod, until the stop channel is closed. The Lease is released on stop
+// so another replica takes over immediately.
+func (n *NGINXController) runLeaderElection() error {
+	if n.cfg.DisableLeaderElection {
+		return nil
+	}
+
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+		identity = hostname
+	}
+	namespace := os.Getenv("POD_NAMESPACE")
+	if namespace == "" {
+		namespace = n.cfg.Namespace
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Namespace: namespace, Name: n.cfg.ElectionID},
+		Client:     n.cfg.Client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   n.cfg.ElectionTTL,
+		RenewDeadline:   n.cfg.ElectionTTL / 2,
+		RetryPeriod:     n.cfg.ElectionTTL / 4,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) {
+				klog.Infof("Elected leader, publishing the reports, events and status of the Ingresses")
+				n.isLeader.Store(true)
+			},
+			OnStoppedLeading: func() {
+				klog.Infof("Stopped leading")
+				n.isLeader.Store(false)
+			},
+			OnNewLeader: func(leader string) {
+				if leader != identity {
+					klog.Infof("New leader elected: %v", leader)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-n.stopCh
+		cancel()
+	}()
+
+	go func() {
+		// Run returns when the leadership is lost, compete again until the
+		// controller stops
+		for ctx.Err() == nil {
+			elector.Run(ctx)
+		}
+	}()
+	return nil
+}
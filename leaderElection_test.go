@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestLeading(t *testing.T) {
+	n := newTestController(t, "")
+	if n.leading() {
+		t.Errorf("expected a replica without the Lease not to lead")
+	}
+	n.isLeader.Store(true)
+	if !n.leading() {
+		t.Errorf("expected the replica holding the Lease to lead")
+	}
+	n.isLeader.Store(false)
+	n.cfg.DisableLeaderElection = true
+	if !n.leading() {
+		t.Errorf("expected every replica to lead with the leader election disabled")
+	}
+}
+
+func TestPreValidateLeader(t *testing.T) {
+	for _, leader := range []bool{false, true} {
+		n := newTestController(t, preValidationManifests)
+		n.cfg.NginxVersion = "1.13.9"
+		recorder := record.NewFakeRecorder(10)
+		n.recorder = recorder
+		n.isLeader.Store(leader)
+
+		if result := n.preValidate(); result.Valid {
+			t.Fatalf("expected the h2c Ingress to be invalid")
+		}
+		if events := recordedEvents(recorder); (len(events) > 0) != leader {
+			t.Errorf("leader %v: unexpected events %v", leader, events)
+		}
+	}
+}
+
+func TestRunLeaderElection(t *testing.T) {
+	t.Setenv("POD_NAME", "validator-0")
+	t.Setenv("POD_NAMESPACE", "ingress-nginx")
+
+	client := fake.NewSimpleClientset()
+	n := newTestController(t, "")
+	n.cfg.Client = client
+	n.cfg.ElectionID = defaultElectionID
+	n.cfg.ElectionTTL = time.Second
+	if err := n.runLeaderElection(); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !n.leading() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the only replica to be elected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	lease, err := client.CoordinationV1().Leases("ingress-nginx").Get(context.Background(), defaultElectionID, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the Lease to be created: %v", err)
+	}
+	if holder := lease.Spec.HolderIdentity; holder == nil || *holder != "validator-0" {
+		t.Errorf("expected the Lease to be held by validator-0, got %v", holder)
+	}
+
+	// the Lease is released on stop so another replica takes over
+	close(n.stopCh)
+	for n.leading() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the replica to stop leading")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	ngx_config "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/controller/config"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/nginx"
+)
+
+const (
+	// netBindServiceCapability allows binding the ports below 1024
+	netBindServiceCapability = "NET_BIND_SERVICE"
+	// unprivilegedPortStartSysctl lowers the first port bindable without
+	// NET_BIND_SERVICE
+	unprivilegedPortStartSysctl = "net.ipv4.ip_unprivileged_port_start"
+	// defaultUnprivilegedPortStart is the first port bindable without
+	// NET_BIND_SERVICE
+	defaultUnprivilegedPortStart = 1024
+)
+
+// listenPort is a port nginx or the ingress controller listens on, named
+// after the flag of the ingress controller setting it
+type listenPort struct {
+	name string
+	port int
+}
+
+// addListenPortFlags registers the flags of the ports of the ingress
+// controller, named as in the ingress controller
+func addListenPortFlags(fs *flag.FlagSet, cfg *NginxConfiguration) {
+	cfg.ListenPorts = &ngx_config.ListenPorts{}
+	fs.IntVar(&cfg.ListenPorts.HTTP, "http-port", 80, "Port of the HTTP servers of the ingress controller.")
+	fs.IntVar(&cfg.ListenPorts.HTTPS, "https-port", 443, "Port of the HTTPS servers of the ingress controller.")
+	fs.IntVar(&cfg.ListenPorts.SSLProxy, "ssl-passthrough-proxy-port", 442, "Port of the SSL passthrough proxy of the ingress controller.")
+	fs.IntVar(&cfg.ListenPorts.Default, "default-server-port", 8181, "Port of the default server of the ingress controller.")
+	fs.IntVar(&cfg.ListenPorts.Health, "controller-healthz-port", 10254, "Port of the health checks and metrics of the ingress controller.")
+	fs.IntVar(&cfg.StatusPort, "status-port", nginx.StatusPort, "Port of the nginx status server of the ingress controller.")
+	fs.IntVar(&cfg.StreamPort, "stream-port", nginx.StreamPort, "Port of the nginx stream server of the ingress controller.")
+	fs.IntVar(&cfg.ProfilerPort, "profiler-port", nginx.ProfilerPort, "Port of the profiler of the ingress controller.")
+}
+
+// listenPorts returns the ports of the ingress controller, also reserved for
+// the TCP and UDP services
+func (n *NGINXController) listenPorts() []listenPort {
+	return []listenPort{
+		{"http-port", n.cfg.ListenPorts.HTTP},
+		{"https-port", n.cfg.ListenPorts.HTTPS},
+		{"ssl-passthrough-proxy-port", n.cfg.ListenPorts.SSLProxy},
+		{"default-server-port", n.cfg.ListenPorts.Default},
+		{"healthz-port", n.cfg.ListenPorts.Health},
+		{"status-port", n.cfg.StatusPort},
+		{"stream-port", n.cfg.StreamPort},
+		{"profiler-port", n.cfg.ProfilerPort},
+	}
+}
+
+// checkListenPorts validates the ports of the ingress controller: they must
+// be valid and distinct, the ports below 1024 need NET_BIND_SERVICE, and the
+// TCP and UDP services using them are ignored
+func (n *NGINXController) checkListenPorts(_ []*Ingress, _ *Configuration) []Finding {
+	findings := []Finding{}
+	report := func(severity Severity, format string, args ...interface{}) {
+		findings = append(findings, Finding{
+			Rule:     "listen-ports",
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	ports := n.listenPorts()
+	byPort := map[int][]string{}
+	for _, p := range ports {
+		if p.port < 1 || p.port > 65535 {
+			report(SeverityError, "%v %d is not a valid port", p.name, p.port)
+			continue
+		}
+		byPort[p.port] = append(byPort[p.port], p.name)
+	}
+
+	numbers := make([]int, 0, len(byPort))
+	for port := range byPort {
+		numbers = append(numbers, port)
+	}
+	sort.Ints(numbers)
+	for _, port := range numbers {
+		if names := byPort[port]; len(names) > 1 {
+			report(SeverityError, "%v all use port %d; nginx fails to bind the port and does not start", strings.Join(names, ", "), port)
+		}
+	}
+
+	if d := n.controllerDeployment(); d != nil {
+		start := unprivilegedPortStart(&d.Spec.Template.Spec)
+		if !canBindPrivilegedPorts(&d.Spec.Template.Spec) {
+			for _, port := range numbers {
+				if port < start {
+					report(SeverityError, "%v %d is below %d but the containers of Deployment %v/%v neither run as root nor add the %v capability; nginx fails to bind the port",
+						strings.Join(byPort[port], ", "), port, start, d.Namespace, d.Name, netBindServiceCapability)
+				}
+			}
+		}
+	}
+
+	for _, stream := range []struct {
+		configMap string
+		proto     apiv1.Protocol
+	}{
+		{n.cfg.TCPConfigMapName, apiv1.ProtocolTCP},
+		{n.cfg.UDPConfigMapName, apiv1.ProtocolUDP},
+	} {
+		if stream.configMap == "" {
+			continue
+		}
+		configMap, err := n.store.GetConfigMap(stream.configMap)
+		if err != nil {
+			continue
+		}
+
+		keys := make([]string, 0, len(configMap.Data))
+		for key := range configMap.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			port, err := strconv.Atoi(key)
+			if err != nil || port < 1 || port > 65535 {
+				report(SeverityError, "%q of the %v services ConfigMap %v is not a valid port; the service %v is ignored",
+					key, stream.proto, stream.configMap, configMap.Data[key])
+				continue
+			}
+			if names, ok := byPort[port]; ok {
+				report(SeverityWarning, "port %d of the %v services ConfigMap %v is reserved for the %v of the ingress controller; the service %v is ignored",
+					port, stream.proto, stream.configMap, strings.Join(names, ", "), configMap.Data[key])
+			}
+		}
+	}
+
+	return findings
+}
+
+// controllerDeployment returns the Deployment of the ingress controller pods,
+// nil when it is not known
+func (n *NGINXController) controllerDeployment() *appsv1.Deployment {
+	if len(n.cfg.ControllerPodLabels) == 0 {
+		return nil
+	}
+
+	selector := labels.SelectorFromSet(n.cfg.ControllerPodLabels)
+	for _, d := range n.store.ListDeployments(n.cfg.Namespace) {
+		if selector.Matches(labels.Set(d.Spec.Template.Labels)) {
+			return d
+		}
+	}
+	return nil
+}
+
+// canBindPrivilegedPorts returns true if the containers of the pod binding
+// ports, the ones declaring ports or all of them when none does, run as root
+// or add the NET_BIND_SERVICE capability
+func canBindPrivilegedPorts(spec *apiv1.PodSpec) bool {
+	containers := []apiv1.Container{}
+	for _, c := range spec.Containers {
+		if len(c.Ports) > 0 {
+			containers = append(containers, c)
+		}
+	}
+	if len(containers) == 0 {
+		containers = spec.Containers
+	}
+	if len(containers) == 0 {
+		return false
+	}
+
+	for i := range containers {
+		if !containerCanBindPrivilegedPorts(spec, &containers[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// containerCanBindPrivilegedPorts returns true if the container runs as root
+// or adds the NET_BIND_SERVICE capability
+func containerCanBindPrivilegedPorts(spec *apiv1.PodSpec, container *apiv1.Container) bool {
+	sc := container.SecurityContext
+	runAsUser := (*int64)(nil)
+	if spec.SecurityContext != nil {
+		runAsUser = spec.SecurityContext.RunAsUser
+	}
+	if sc != nil && sc.RunAsUser != nil {
+		runAsUser = sc.RunAsUser
+	}
+	if runAsUser != nil && *runAsUser == 0 {
+		return true
+	}
+
+	if sc == nil || sc.Capabilities == nil {
+		return false
+	}
+	for _, c := range sc.Capabilities.Add {
+		if string(c) == netBindServiceCapability || string(c) == "CAP_"+netBindServiceCapability {
+			return true
+		}
+	}
+	return false
+}
+
+// unprivilegedPortStart returns the first port the pod can bind without
+// NET_BIND_SERVICE
+func unprivilegedPortStart(spec *apiv1.PodSpec) int {
+	if spec.SecurityContext == nil {
+		return defaultUnprivilegedPortStart
+	}
+	for _, s := range spec.SecurityContext.Sysctls {
+		if s.Name != unprivilegedPortStartSysctl {
+			continue
+		}
+		if start, err := strconv.Atoi(s.Value); err == nil {
+			return start
+		}
+	}
+	return defaultUnprivilegedPortStart
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/nginx"
+)
+
+// controllerDeploymentManifest is the Deployment of the ingress controller,
+// %SECURITY% being replaced by the security context of the pod
+const controllerDeploymentManifest = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+spec:
+  selector:
+    matchLabels:
+      app.kubernetes.io/name: ingress-nginx
+  template:
+    metadata:
+      labels:
+        app.kubernetes.io/name: ingress-nginx
+    spec:
+%SECURITY%
+      containers:
+      - name: controller
+        image: registry.k8s.io/ingress-nginx/controller:v1.12.1
+%CONTAINER%
+`
+
+func TestCheckListenPorts(t *testing.T) {
+	deployment := func(podSecurity, containerSecurity string) string {
+		return strings.NewReplacer("%SECURITY%", podSecurity, "%CONTAINER%", containerSecurity).Replace(controllerDeploymentManifest)
+	}
+	tcpServices := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: tcp-services
+  namespace: ingress-nginx
+data:
+  "443": default/web:443
+  "5432": default/postgres:5432
+  "ssh": default/git:22
+`
+
+	testCases := map[string]struct {
+		manifests string
+		configure func(n *NGINXController)
+		expected  []string
+	}{
+		"defaults": {},
+		"invalid port": {
+			configure: func(n *NGINXController) { n.cfg.ListenPorts.HTTPS = 0 },
+			expected:  []string{"error https-port 0 is not a valid port"},
+		},
+		"port used twice": {
+			configure: func(n *NGINXController) { n.cfg.ListenPorts.Default = 80 },
+			expected:  []string{"error http-port, default-server-port all use port 80; nginx fails to bind the port and does not start"},
+		},
+		"privileged ports without NET_BIND_SERVICE": {
+			manifests: deployment("", `        securityContext:
+          runAsUser: 101`),
+			expected: []string{
+				"error http-port 80 is below 1024 but the containers of Deployment ingress-nginx/ingress-nginx-controller neither run as root nor add the NET_BIND_SERVICE capability; nginx fails to bind the port",
+				"error ssl-passthrough-proxy-port 442 is below 1024",
+				"error https-port 443 is below 1024",
+			},
+		},
+		"NET_BIND_SERVICE added": {
+			manifests: deployment("", `        securityContext:
+          runAsUser: 101
+          capabilities:
+            drop: [ALL]
+            add: [NET_BIND_SERVICE]`),
+		},
+		"pod running as root": {
+			manifests: deployment(`      securityContext:
+        runAsUser: 0`, ""),
+		},
+		"container user overriding root": {
+			manifests: deployment(`      securityContext:
+        runAsUser: 0`, `        securityContext:
+          runAsUser: 101`),
+			expected: []string{"error http-port 80 is below 1024", "error ssl-passthrough-proxy-port 442 is below 1024", "error https-port 443 is below 1024"},
+		},
+		"unprivileged port start lowered": {
+			manifests: deployment(`      securityContext:
+        sysctls:
+        - name: net.ipv4.ip_unprivileged_port_start
+          value: "443"`, ""),
+			expected: []string{"error http-port 80 is below 443", "error ssl-passthrough-proxy-port 442 is below 443"},
+		},
+		"Deployment of other pods": {
+			manifests: strings.ReplaceAll(deployment("", ""), "app.kubernetes.io/name: ingress-nginx", "app: web"),
+		},
+		"TCP services": {
+			manifests: tcpServices,
+			configure: func(n *NGINXController) { n.cfg.TCPConfigMapName = "ingress-nginx/tcp-services" },
+			expected: []string{
+				"warning port 443 of the TCP services ConfigMap ingress-nginx/tcp-services is reserved for the https-port of the ingress controller; the service default/web:443 is ignored",
+				`error "ssh" of the TCP services ConfigMap ingress-nginx/tcp-services is not a valid port; the service default/git:22 is ignored`,
+			},
+		},
+		"UDP services ConfigMap missing": {
+			configure: func(n *NGINXController) { n.cfg.UDPConfigMapName = "ingress-nginx/udp-services" },
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n := newTestController(t, tc.manifests)
+			n.cfg.Namespace = "ingress-nginx"
+			n.cfg.ControllerPodLabels = map[string]string{"app.kubernetes.io/name": "ingress-nginx"}
+			if tc.configure != nil {
+				tc.configure(n)
+			}
+
+			findings := n.checkListenPorts(nil, nil)
+			if len(findings) != len(tc.expected) {
+				t.Fatalf("expected %v findings, got %+v", len(tc.expected), findings)
+			}
+			for i, f := range findings {
+				if f.Rule != "listen-ports" {
+					t.Errorf("unexpected finding %+v", f)
+				}
+				if got := fmt.Sprintf("%v %v", f.Severity, f.Message); !strings.HasPrefix(got, tc.expected[i]) {
+					t.Errorf("expected a finding starting with %q, got %q", tc.expected[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestListenPortFlags(t *testing.T) {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg := &NginxConfiguration{}
+	addListenPortFlags(fs, cfg)
+
+	profilerPort := nginx.ProfilerPort
+	if err := fs.Parse([]string{"--profiler-port=20245", "--status-port=20246"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ProfilerPort != 20245 || cfg.StatusPort != 20246 || cfg.StreamPort != nginx.StreamPort {
+		t.Errorf("unexpected ports %d, %d and %d", cfg.ProfilerPort, cfg.StatusPort, cfg.StreamPort)
+	}
+	if nginx.ProfilerPort != profilerPort {
+		t.Errorf("expected the profiler port of the validator to be kept, got %d", nginx.ProfilerPort)
+	}
+}
+
+func TestCanBindPrivilegedPorts(t *testing.T) {
+	root := int64(0)
+	user := int64(101)
+	netBindService := &apiv1.SecurityContext{
+		RunAsUser:    &user,
+		Capabilities: &apiv1.Capabilities{Add: []apiv1.Capability{netBindServiceCapability}},
+	}
+	ports := []apiv1.ContainerPort{{ContainerPort: 80}}
+
+	tests := []struct {
+		name       string
+		containers []apiv1.Container
+		expected   bool
+	}{
+		{
+			name:       "controller with the capability",
+			containers: []apiv1.Container{{Name: "controller", Ports: ports, SecurityContext: netBindService}},
+			expected:   true,
+		},
+		{
+			name: "sidecar running as root",
+			containers: []apiv1.Container{
+				{Name: "controller", Ports: ports, SecurityContext: &apiv1.SecurityContext{RunAsUser: &user}},
+				{Name: "sidecar", SecurityContext: &apiv1.SecurityContext{RunAsUser: &root}},
+			},
+			expected: false,
+		},
+		{
+			name: "containers without ports",
+			containers: []apiv1.Container{
+				{Name: "controller", SecurityContext: netBindService},
+				{Name: "sidecar", SecurityContext: &apiv1.SecurityContext{RunAsUser: &user}},
+			},
+			expected: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := canBindPrivilegedPorts(&apiv1.PodSpec{Containers: tc.containers}); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
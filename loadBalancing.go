@@ -0,0 +1,130 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+)
+
+// loadBalancingAlgorithms are the values of load-balance supported by the
+// balancer of the ingress controller
+var loadBalancingAlgorithms = []string{"round_robin", "ewma"}
+
+// luaBalancerControllerVersion is the first version of the ingress controller
+// balancing every upstream with the Lua balancer, the nginx upstreams of the
+// previous versions also supporting the algorithms of
+// removedLoadBalancingAlgorithms
+var luaBalancerControllerVersion = nginxVersion{Major: 0, Minor: 21, Patch: 0}
+
+// removedLoadBalancingAlgorithms were supported by the nginx upstreams used
+// before the Lua balancer, with the way to get the same behavior
+var removedLoadBalancingAlgorithms = map[string]string{
+	"least_conn": "use ewma, which prefers the endpoints answering faster",
+	"ip_hash":    "use the annotation upstream-hash-by: \"$binary_remote_addr\"",
+}
+
+// supportedLoadBalancingAlgorithms returns the values of load-balance
+// supported by the target version of the ingress controller, the ones of the
+// current versions when it is not configured
+func (n *NGINXController) supportedLoadBalancingAlgorithms() []string {
+	version, ok := n.targetControllerVersion()
+	if !ok || version.AtLeast(luaBalancerControllerVersion) {
+		return loadBalancingAlgorithms
+	}
+	return append(loadBalancingAlgorithms[:len(loadBalancingAlgorithms):len(loadBalancingAlgorithms)],
+		sortedKeys(removedLoadBalancingAlgorithms)...)
+}
+
+// defaultLoadBalancingAlgorithm is used by the balancer when neither the
+// ConfigMap nor the Ingress set load-balance
+const defaultLoadBalancingAlgorithm = "round_robin"
+
+// checkLoadBalancing validates the load-balance annotations and reports the
+// ones the balancer ignores: session affinity and upstream-hash-by take
+// precedence over the algorithm, and a backend shared by several Ingresses
+// uses the algorithm of the oldest one, the order the ingress controller
+// processes the Ingresses in. The annotations repeating or overriding the
+// load-balance of the ConfigMap are reported as infos.
+func (n *NGINXController) checkLoadBalancing(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	checked := map[*Ingress]bool{}
+	supported := n.supportedLoadBalancingAlgorithms()
+	global := n.store.GetBackendConfiguration().LoadBalancing
+
+	// the Ingress setting the algorithm of each backend
+	type backendAlgorithm struct {
+		algorithm string
+		ing       *Ingress
+	}
+	backends := map[string]backendAlgorithm{}
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			if loc.Ingress == nil || loc.Ingress.ParsedAnnotations == nil {
+				continue
+			}
+			algorithm, ok := loc.Ingress.Annotations[parser.GetAnnotationWithPrefix("load-balance")]
+			if !ok {
+				continue
+			}
+			if first, ok := backends[loc.Backend]; !ok || ingressBefore(&loc.Ingress.Ingress, &first.ing.Ingress) {
+				backends[loc.Backend] = backendAlgorithm{algorithm, loc.Ingress}
+			}
+		}
+	}
+
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			if loc.Ingress == nil || loc.Ingress.ParsedAnnotations == nil {
+				continue
+			}
+			algorithm, ok := loc.Ingress.Annotations[parser.GetAnnotationWithPrefix("load-balance")]
+			if !ok {
+				continue
+			}
+			anns := loc.Ingress.ParsedAnnotations
+
+			if first := backends[loc.Backend]; first.ing != loc.Ingress && first.algorithm != algorithm {
+				findings = append(findings, newLocationFinding("load-balance-conflict", SeverityWarning, server, loc,
+					"load-balance %q of location %q is ignored: backend %v is shared with Ingress %v/%v, whose load-balance %q is used",
+					algorithm, loc.Path, loc.Backend, first.ing.Namespace, first.ing.Name, first.algorithm))
+			}
+
+			if checked[loc.Ingress] {
+				continue
+			}
+			checked[loc.Ingress] = true
+
+			switch {
+			case removedLoadBalancingAlgorithms[algorithm] != "" && !containsString(supported, algorithm):
+				findings = append(findings, newLocationFinding("load-balance-invalid", SeverityError, server, loc,
+					"load-balance %q is no longer supported by the ingress controller, %v; the balancer falls back to round_robin",
+					algorithm, removedLoadBalancingAlgorithms[algorithm]))
+			case !containsString(supported, algorithm):
+				findings = append(findings, newLocationFinding("load-balance-invalid", SeverityError, server, loc,
+					"load-balance %q is not one of %v; the balancer falls back to round_robin",
+					algorithm, strings.Join(supported, ", ")))
+			case anns.SessionAffinity.Type == "cookie":
+				findings = append(findings, newLocationFinding("load-balance-ineffective", SeverityWarning, server, loc,
+					"load-balance %q has no effect: the cookie session affinity of the Ingress balances the requests", algorithm))
+			case anns.UpstreamHashBy.UpstreamHashBy != "":
+				findings = append(findings, newLocationFinding("load-balance-ineffective", SeverityWarning, server, loc,
+					"load-balance %q has no effect: upstream-hash-by %q of the Ingress balances the requests with consistent hashing",
+					algorithm, anns.UpstreamHashBy.UpstreamHashBy))
+			case global == "" && algorithm == defaultLoadBalancingAlgorithm:
+				findings = append(findings, newLocationFinding("load-balance-redundant", SeverityInfo, server, loc,
+					"load-balance %q is the default of the balancer, the ConfigMap %v not setting load-balance; the annotation can be removed",
+					algorithm, n.cfg.ConfigMapName))
+			case algorithm == global:
+				findings = append(findings, newLocationFinding("load-balance-redundant", SeverityInfo, server, loc,
+					"load-balance %q repeats the load-balance of the ConfigMap %v; the annotation can be removed",
+					algorithm, n.cfg.ConfigMapName))
+			case global != "":
+				findings = append(findings, newLocationFinding("load-balance-override", SeverityInfo, server, loc,
+					"load-balance %q overrides the load-balance %q of the ConfigMap %v for the backends of the Ingress",
+					algorithm, global, n.cfg.ConfigMapName))
+			}
+		}
+	}
+
+	return findings
+}
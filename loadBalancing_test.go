@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// loadBalancingIngress returns an Ingress of host with the annotations,
+// sending path to the Service web
+func loadBalancingIngress(name, host, path, annotations string) string {
+	return fmt.Sprintf(`
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: %v
+  namespace: default
+  annotations:
+%v
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: %v
+    http:
+      paths:
+      - path: %v
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`, name, annotations, host, path)
+}
+
+// loadBalancingConfigMap is the ConfigMap of the ingress controller setting
+// load-balance to algorithm
+func loadBalancingConfigMap(algorithm string) string {
+	return fmt.Sprintf(`
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+data:
+  load-balance: %v
+`, algorithm)
+}
+
+func TestCheckLoadBalancing(t *testing.T) {
+	testCases := map[string]struct {
+		manifests string
+		expected  []string
+	}{
+		"valid": {
+			manifests: loadBalancingIngress("web", "web.example.com", "/", `    nginx.ingress.kubernetes.io/load-balance: ewma`),
+		},
+		"unknown algorithm": {
+			manifests: loadBalancingIngress("web", "web.example.com", "/", `    nginx.ingress.kubernetes.io/load-balance: random`),
+			expected:  []string{"load-balance-invalid default/web"},
+		},
+		"removed algorithm": {
+			manifests: loadBalancingIngress("web", "web.example.com", "/", `    nginx.ingress.kubernetes.io/load-balance: least_conn`),
+			expected:  []string{"load-balance-invalid default/web"},
+		},
+		"cookie affinity": {
+			manifests: loadBalancingIngress("web", "web.example.com", "/", `    nginx.ingress.kubernetes.io/load-balance: ewma
+    nginx.ingress.kubernetes.io/affinity: cookie`),
+			expected: []string{"load-balance-ineffective default/web"},
+		},
+		"consistent hashing": {
+			manifests: loadBalancingIngress("web", "web.example.com", "/", `    nginx.ingress.kubernetes.io/load-balance: ewma
+    nginx.ingress.kubernetes.io/upstream-hash-by: $request_uri`),
+			expected: []string{"load-balance-ineffective default/web"},
+		},
+		"shared backend": {
+			manifests: loadBalancingIngress("web", "web.example.com", "/", `    nginx.ingress.kubernetes.io/load-balance: ewma`) +
+				loadBalancingIngress("admin", "admin.example.com", "/", `    nginx.ingress.kubernetes.io/load-balance: round_robin`),
+			// the servers are sorted by hostname
+			expected: []string{"load-balance-redundant default/admin", "load-balance-conflict default/web"},
+		},
+		"default algorithm": {
+			manifests: loadBalancingIngress("web", "web.example.com", "/", `    nginx.ingress.kubernetes.io/load-balance: round_robin`),
+			expected:  []string{"load-balance-redundant default/web"},
+		},
+		"repeats the ConfigMap": {
+			manifests: loadBalancingConfigMap("ewma") +
+				loadBalancingIngress("web", "web.example.com", "/", `    nginx.ingress.kubernetes.io/load-balance: ewma`),
+			expected: []string{"load-balance-redundant default/web"},
+		},
+		"overrides the ConfigMap": {
+			manifests: loadBalancingConfigMap("ewma") +
+				loadBalancingIngress("web", "web.example.com", "/", `    nginx.ingress.kubernetes.io/load-balance: round_robin`),
+			expected: []string{"load-balance-override default/web"},
+		},
+		"ConfigMap without annotation": {
+			manifests: loadBalancingConfigMap("ewma") +
+				loadBalancingIngress("web", "web.example.com", "/", `    nginx.ingress.kubernetes.io/ssl-redirect: "false"`),
+		},
+		"invalid algorithm overriding the ConfigMap": {
+			manifests: loadBalancingConfigMap("ewma") +
+				loadBalancingIngress("web", "web.example.com", "/", `    nginx.ingress.kubernetes.io/load-balance: random`),
+			expected: []string{"load-balance-invalid default/web"},
+		},
+		"shared backend with the same algorithm": {
+			manifests: loadBalancingIngress("web", "web.example.com", "/", `    nginx.ingress.kubernetes.io/load-balance: ewma`) +
+				loadBalancingIngress("admin", "admin.example.com", "/", `    nginx.ingress.kubernetes.io/load-balance: ewma`),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, tc.manifests)
+			findings := []string{}
+			for _, f := range n.checkLoadBalancing(ingresses, cfg) {
+				findings = append(findings, f.Rule+" "+f.Ingress)
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(findings, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, findings)
+			}
+		})
+	}
+}
+
+const loadBalancingManifests = `
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+  namespace: default
+spec:
+  ports:
+  - port: 80
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: newer
+  namespace: default
+  creationTimestamp: "2024-06-01T00:00:00Z"
+  annotations:
+    nginx.ingress.kubernetes.io/load-balance: ewma
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: a.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: app
+            port:
+              number: 80
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: older
+  namespace: default
+  creationTimestamp: "2024-01-01T00:00:00Z"
+  annotations:
+    nginx.ingress.kubernetes.io/load-balance: round_robin
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: z.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: app
+            port:
+              number: 80
+`
+
+func TestCheckLoadBalancingOldestIngressWins(t *testing.T) {
+	n, ingresses, cfg := testConfiguration(t, loadBalancingManifests)
+
+	conflicts := findingsWithRule(n.checkLoadBalancing(ingresses, cfg), "load-balance-conflict")
+	if len(conflicts) != 1 || conflicts[0].Host != "a.example.com" {
+		t.Errorf("expected one load-balance-conflict finding for the newer Ingress, got %v", conflicts)
+	}
+}
+
+func TestCheckLoadBalancingControllerVersion(t *testing.T) {
+	manifests := `
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+  namespace: default
+spec:
+  ports:
+  - port: 80
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: app
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/load-balance: least_conn
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: app.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: app
+            port:
+              number: 80
+`
+	tests := []struct {
+		version  string
+		expected int
+	}{
+		{version: "", expected: 1},
+		{version: "v1.12.1", expected: 1},
+		{version: "controller-v1.12.1", expected: 1},
+		{version: "0.20.0", expected: 0},
+		// the Lua balancer is used from 0.21.0
+		{version: "0.20.9", expected: 0},
+		{version: "v0.21.0", expected: 1},
+		{version: "0.21.0", expected: 1},
+		{version: "0.21.1", expected: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.version, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, manifests)
+			n.cfg.ControllerVersion = tc.version
+
+			invalid := findingsWithRule(n.checkLoadBalancing(ingresses, cfg), "load-balance-invalid")
+			if len(invalid) != tc.expected {
+				t.Errorf("expected %d load-balance-invalid findings, got %v", tc.expected, invalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+
+	"github.com/go-logr/logr"
+	"k8s.io/klog/v2"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// addLoggingFlags registers the verbosity flags of klog and --log-format
+func addLoggingFlags(fs *flag.FlagSet) {
+	klogFlags := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(klogFlags)
+	for _, name := range []string{"v", "vmodule"} {
+		f := klogFlags.Lookup(name)
+		fs.Var(f.Value, f.Name, f.Usage)
+	}
+
+	fs.Func("log-format", "Format of the logs: text, or json for one JSON object per line.", setLogFormat)
+}
+
+// setLogFormat configures the output of klog. Verbosity, including the
+// per-file levels of -vmodule, is still enforced by klog.
+func setLogFormat(format string) error {
+	switch format {
+	case logFormatText:
+		klog.ClearLogger()
+	case logFormatJSON:
+		handler := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+			// klog only calls the logger for the enabled levels
+			Level: slog.Level(math.MinInt32),
+		})
+		klog.SetLogger(logr.FromSlogHandler(handler))
+	default:
+		return fmt.Errorf("unknown log format %q, expected %v or %v", format, logFormatText, logFormatJSON)
+	}
+	return nil
+}
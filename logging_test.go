@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+
+	"k8s.io/klog/v2"
+)
+
+func TestLoggingFlags(t *testing.T) {
+	defer klog.ClearLogger()
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	addLoggingFlags(fs)
+
+	for _, name := range []string{"v", "vmodule", "log-format"} {
+		if fs.Lookup(name) == nil {
+			t.Errorf("expected the flag %v", name)
+		}
+	}
+
+	if err := fs.Parse([]string{"-v", "4", "--log-format", "json"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() {
+		if err := fs.Set("v", "0"); err != nil {
+			t.Error(err)
+		}
+	}()
+	if !klog.V(4).Enabled() || klog.V(5).Enabled() {
+		t.Error("expected the verbosity 4")
+	}
+
+	if err := fs.Parse([]string{"--log-format", "xml"}); err == nil {
+		t.Error("expected an error for an unknown log format")
+	}
+}
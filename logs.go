@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"path"
+)
+
+// errorLogLevels are the levels of error_log, from the most verbose
+var errorLogLevels = []string{"debug", "info", "notice", "warn", "error", "crit", "alert", "emerg"}
+
+// logsErrorLevel returns true if the error log level writes the messages of
+// the level
+func logsErrorLevel(errorLogLevel, level string) bool {
+	for _, l := range errorLogLevels {
+		switch l {
+		case errorLogLevel:
+			return true
+		case level:
+			// unknown levels are reported by checkConfigMap
+			return !containsString(errorLogLevels, errorLogLevel)
+		}
+	}
+	return true
+}
+
+// requiresAccessLog returns true if the host matches one of the patterns of
+// the hosts whose access log the audit policy requires
+func (n *NGINXController) requiresAccessLog(host string) bool {
+	for _, pattern := range n.cfg.AccessLogHosts {
+		if ok, err := path.Match(pattern, host); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLogs validates the log annotations of the locations: the rewrite log
+// is written at the notice level of the error log, and the access log of the
+// hosts of the audit policy can not be disabled
+func (n *NGINXController) checkLogs(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	global := n.store.GetBackendConfiguration()
+	rewriteLogged := logsErrorLevel(global.ErrorLogLevel, "notice")
+
+	for _, server := range cfg.Servers {
+		required := n.requiresAccessLog(server.Hostname)
+		if required && global.DisableAccessLog {
+			findings = append(findings, Finding{
+				Rule:     "access-log-required",
+				Severity: SeverityError,
+				Ingress:  serverIngress(server),
+				Host:     server.Hostname,
+				Message: fmt.Sprintf("the audit policy requires the access log of %v but disable-access-log is true in ConfigMap %v",
+					server.Hostname, n.cfg.ConfigMapName),
+			})
+		}
+
+		for _, loc := range server.Locations {
+			if loc.Logs.Rewrite && !rewriteLogged {
+				findings = append(findings, newLocationFinding("rewrite-log-ineffective", SeverityError, server, loc,
+					"location %q enables the rewrite log but error-log-level is %v; nginx writes it at the notice level, so nothing is logged",
+					loc.Path, global.ErrorLogLevel))
+			}
+			if required && !global.DisableAccessLog && !loc.Logs.Access {
+				findings = append(findings, newLocationFinding("access-log-required", SeverityError, server, loc,
+					"location %q disables the access log but the audit policy requires it for %v", loc.Path, server.Hostname))
+			}
+		}
+	}
+
+	return findings
+}
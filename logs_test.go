@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestLogsErrorLevel(t *testing.T) {
+	for _, tc := range []struct {
+		errorLogLevel string
+		expected      bool
+	}{
+		{errorLogLevel: "debug", expected: true},
+		{errorLogLevel: "notice", expected: true},
+		{errorLogLevel: "warn"},
+		{errorLogLevel: "emerg"},
+		// reported by checkConfigMap
+		{errorLogLevel: "verbose", expected: true},
+	} {
+		if got := logsErrorLevel(tc.errorLogLevel, "notice"); got != tc.expected {
+			t.Errorf("expected %v for error-log-level %v, got %v", tc.expected, tc.errorLogLevel, got)
+		}
+	}
+}
+
+// logsManifests is completed with the data of the ConfigMap and the
+// annotations of the Ingress
+const logsManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+data:
+%v
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+  annotations:
+%v
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.service.justice.gov.uk
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`
+
+func TestCheckLogs(t *testing.T) {
+	testCases := map[string]struct {
+		data           string
+		annotations    string
+		accessLogHosts []string
+		expected       []string
+	}{
+		"rewrite log at the notice level": {
+			data:        `  error-log-level: notice`,
+			annotations: `    nginx.ingress.kubernetes.io/enable-rewrite-log: "true"`,
+		},
+		"rewrite log at the error level": {
+			data:        `  error-log-level: error`,
+			annotations: `    nginx.ingress.kubernetes.io/enable-rewrite-log: "true"`,
+			expected: []string{
+				`rewrite-log-ineffective: location "/" enables the rewrite log but error-log-level is error; nginx writes it at the notice level, so nothing is logged`,
+			},
+		},
+		"access log disabled without policy": {
+			data:        `  error-log-level: notice`,
+			annotations: `    nginx.ingress.kubernetes.io/enable-access-log: "false"`,
+		},
+		"access log disabled by the location": {
+			data:           `  error-log-level: notice`,
+			annotations:    `    nginx.ingress.kubernetes.io/enable-access-log: "false"`,
+			accessLogHosts: []string{"*.service.justice.gov.uk"},
+			expected: []string{
+				`access-log-required: location "/" disables the access log but the audit policy requires it for web.service.justice.gov.uk`,
+			},
+		},
+		"access log disabled globally": {
+			data:           `  disable-access-log: "true"`,
+			annotations:    `    nginx.ingress.kubernetes.io/enable-access-log: "false"`,
+			accessLogHosts: []string{"*.service.justice.gov.uk"},
+			expected: []string{
+				"access-log-required: the audit policy requires the access log of web.service.justice.gov.uk but disable-access-log is true in ConfigMap ingress-nginx/ingress-nginx-controller",
+			},
+		},
+		"access log of another host": {
+			data:           `  error-log-level: notice`,
+			annotations:    `    nginx.ingress.kubernetes.io/enable-access-log: "false"`,
+			accessLogHosts: []string{"*.example.com"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, fmt.Sprintf(logsManifests, tc.data, tc.annotations))
+			n.cfg.AccessLogHosts = tc.accessLogHosts
+			findings := []string{}
+			for _, f := range n.checkLogs(ingresses, cfg) {
+				findings = append(findings, f.Rule+": "+f.Message)
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(findings, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, findings)
+			}
+		})
+	}
+}
@@ -1,15 +1,19 @@
 package main
 
 import (
-	"fmt"
-	"log"
+	"os"
 	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
 
 	apiv1 "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
 )
 
 const (
@@ -21,9 +25,15 @@ var (
 	pathTypePrefix = networking.PathTypePrefix
 )
 
+func main() {
+	code := runCLI(os.Args[1:], os.Stdout, os.Stderr)
+	stopRulePlugins()
+	os.Exit(code)
+}
+
 // getConfiguration returns the configuration matching the standard kubernetes ingress
 func (n *NGINXController) getConfiguration(ingresses []*Ingress) (sets.Set[string], []*Server, *Configuration) {
-	upstreams, servers := n.getBackendServers(ingresses)
+	upstreams, servers, ingressErrors := n.getBackendServers(ingresses)
 	var passUpstreams []*SSLPassthroughBackend
 
 	hosts := sets.New[string]()
@@ -59,7 +69,7 @@ func (n *NGINXController) getConfiguration(ingresses []*Ingress) (sets.Set[strin
 
 		for _, loc := range server.Locations {
 			if loc.Path != rootLocation {
-				log.Println("Ignoring SSL Passthrough for location %q in server %q", loc.Path, server.Hostname)
+				klog.Warningf("Ignoring SSL Passthrough for location %q in server %q", loc.Path, server.Hostname)
 				continue
 			}
 			passUpstreams = append(passUpstreams, &SSLPassthroughBackend{
@@ -81,18 +91,20 @@ func (n *NGINXController) getConfiguration(ingresses []*Ingress) (sets.Set[strin
 		BackendConfigChecksum: n.store.GetBackendConfiguration().Checksum,
 		DefaultSSLCertificate: n.getDefaultSSLCertificate(),
 		StreamSnippets:        n.getStreamSnippets(ingresses),
+		IngressErrors:         ingressErrors,
 	}
 }
 
 // updateServerLocations inspects the generated locations configuration for a server
 // normalizing the path and adding an additional exact location when is possible
 func updateServerLocations(locations []*Location) []*Location {
-	newLocations := []*Location{}
+	// every Prefix location may need an additional Exact location
+	newLocations := make([]*Location, 0, 2*len(locations))
 
 	// get Exact locations to check if one already exists
-	exactLocations := map[string]*Location{}
+	exactLocations := make(map[string]*Location, len(locations))
 	for _, location := range locations {
-		if *location.PathType == pathTypeExact {
+		if location.PathType != nil && *location.PathType == pathTypeExact {
 			exactLocations[location.Path] = location
 		}
 	}
@@ -107,7 +119,7 @@ func updateServerLocations(locations []*Location) []*Location {
 		location.IngressPath = location.Path
 
 		// only Prefix locations could require an additional location block
-		if *location.PathType != pathTypePrefix {
+		if location.PathType == nil || *location.PathType != pathTypePrefix {
 			newLocations = append(newLocations, location)
 			continue
 		}
@@ -148,7 +160,7 @@ func normalizePrefixPath(path string) string {
 	}
 
 	if !strings.HasSuffix(path, "/") {
-		return fmt.Sprintf("%v/", path)
+		return path + "/"
 	}
 
 	return path
@@ -162,30 +174,34 @@ func needsRewrite(location *Location) bool {
 	return false
 }
 
-// Test checks if config file is a syntax valid nginx configuration
-func Test(cfg string) ([]byte, error) {
+// Test checks if config file is a syntax valid nginx configuration using the
+// nginx binary. cfg is the path seen by nginx, see sandbox to test a
+// configuration with the files it references.
+func Test(binary, cfg string) ([]byte, error) {
+	defer profileExec("nginx")()
+
 	//nolint:gosec // Ignore G204 error
-	return exec.Command("nc.Binary", "-c", cfg, "-t").CombinedOutput() // TODO: use right binary location
+	return exec.Command(binary, "-c", cfg, "-t").CombinedOutput()
 }
 
 func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Protocol) []L4Service {
 	if configmapName == "" {
 		return []L4Service{}
 	}
-	log.Println("Obtaining information about %v stream services from ConfigMap %q", proto, configmapName)
+	klog.V(3).Infof("Obtaining information about %v stream services from ConfigMap %q", proto, configmapName)
 	_, _, err := k8s.ParseNameNS(configmapName)
 	if err != nil {
-		log.Println("Error parsing ConfigMap reference %q: %v", configmapName, err)
+		klog.Warningf("Error parsing ConfigMap reference %q: %v", configmapName, err)
 		return []L4Service{}
 	}
 	configmap, err := n.store.GetConfigMap(configmapName)
 	if err != nil {
-		log.Println("Error getting ConfigMap %q: %v", configmapName, err)
+		klog.Warningf("Error getting ConfigMap %q: %v", configmapName, err)
 		return []L4Service{}
 	}
 
 	svcs := make([]L4Service, 0, len(configmap.Data))
-	var svcProxyProtocol ingress.ProxyProtocol
+	var svcProxyProtocol ProxyProtocol
 
 	rp := []int{
 		n.cfg.ListenPorts.HTTP,
@@ -193,9 +209,9 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 		n.cfg.ListenPorts.SSLProxy,
 		n.cfg.ListenPorts.Health,
 		n.cfg.ListenPorts.Default,
-		nginx.ProfilerPort,
-		nginx.StatusPort,
-		nginx.StreamPort,
+		n.cfg.ProfilerPort,
+		n.cfg.StatusPort,
+		n.cfg.StreamPort,
 	}
 
 	reservedPorts := sets.NewInt(rp...)
@@ -203,16 +219,16 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 	for port, svcRef := range configmap.Data {
 		externalPort, err := strconv.Atoi(port) // #nosec
 		if err != nil {
-			log.Println("%q is not a valid %v port number", port, proto)
+			klog.Warningf("%q is not a valid %v port number", port, proto)
 			continue
 		}
 		if reservedPorts.Has(externalPort) {
-			log.Println("Port %d cannot be used for %v stream services. It is reserved for the Ingress controller.", externalPort, proto)
+			klog.Warningf("Port %d cannot be used for %v stream services. It is reserved for the Ingress controller.", externalPort, proto)
 			continue
 		}
 		nsSvcPort := strings.Split(svcRef, ":")
 		if len(nsSvcPort) < 2 {
-			log.Println("Invalid Service reference %q for %v port %d", svcRef, proto, externalPort)
+			klog.Warningf("Invalid Service reference %q for %v port %d", svcRef, proto, externalPort)
 			continue
 		}
 		nsName := nsSvcPort[0]
@@ -230,12 +246,12 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 		}
 		svcNs, svcName, err := k8s.ParseNameNS(nsName)
 		if err != nil {
-			log.Println("%v", err)
+			klog.Warning(err)
 			continue
 		}
 		svc, err := n.store.GetService(nsName)
 		if err != nil {
-			log.Println("Error getting Service %q: %v", nsName, err)
+			klog.Warningf("Error getting Service %q: %v", nsName, err)
 			continue
 		}
 		var endps []Endpoint
@@ -250,7 +266,7 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 
 		if err != nil {
 			// not a port number, fall back to using port name
-			log.Println("Searching Endpoints with %v port name %q for Service %q", proto, svcPort, nsName)
+			klog.V(3).Infof("Searching Endpoints with %v port name %q for Service %q", proto, svcPort, nsName)
 			for i := range svc.Spec.Ports {
 				sp := svc.Spec.Ports[i]
 				if sp.Name == svcPort {
@@ -261,7 +277,7 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 				}
 			}
 		} else {
-			log.Println("Searching Endpoints with %v port number %d for Service %q", proto, targetPort, nsName)
+			klog.V(3).Infof("Searching Endpoints with %v port number %d for Service %q", proto, targetPort, nsName)
 			for i := range svc.Spec.Ports {
 				sp := svc.Spec.Ports[i]
 				//nolint:gosec // Ignore G109 error
@@ -276,7 +292,7 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 		// stream services cannot contain empty upstreams and there is
 		// no default backend equivalent
 		if len(endps) == 0 {
-			log.Println("Service %q does not have any active Endpoint for %v port %v", nsName, proto, svcPort)
+			klog.Warningf("Service %q does not have any active Endpoint for %v port %v", nsName, proto, svcPort)
 			continue
 		}
 		svcs = append(svcs, L4Service{
@@ -307,7 +323,7 @@ func (n *NGINXController) getDefaultSSLCertificate() *SSLCert {
 			return certificate
 		}
 
-		log.Println("Error loading custom default certificate, falling back to generated default:\n%v", err)
+		klog.Warningf("Error loading custom default certificate, falling back to generated default:\n%v", err)
 	}
 
 	return n.cfg.FakeCertificate
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// newTestController returns a controller whose store contains the objects of
+// the YAML manifests
+func newTestController(t *testing.T, manifests string) *NGINXController {
+	t.Helper()
+
+	s := newMemoryStore(defaultConfigMapName)
+	if err := s.LoadManifest(strings.NewReader(manifests), "test"); err != nil {
+		t.Fatalf("unexpected error loading the manifests: %v", err)
+	}
+	return newOfflineController(&NginxConfiguration{ConfigMapName: defaultConfigMapName}, s)
+}
+
+// testConfiguration returns the configuration built from the YAML manifests
+func testConfiguration(t *testing.T, manifests string) (*NGINXController, []*Ingress, *Configuration) {
+	t.Helper()
+
+	n := newTestController(t, manifests)
+	ingresses := n.store.ListIngresses()
+	_, _, cfg := n.getConfiguration(ingresses)
+	return n, ingresses, cfg
+}
+
+// testIngress returns the Ingress of the store matching key
+func testIngress(t *testing.T, n *NGINXController, key string) *Ingress {
+	t.Helper()
+
+	for _, ing := range n.store.ListIngresses() {
+		if k8s.MetaNamespaceKey(ing) == key {
+			return ing
+		}
+	}
+	t.Fatalf("Ingress %v not found", key)
+	return nil
+}
+
+// findingsWithRule returns the findings of a rule
+func findingsWithRule(findings []Finding, rule string) []Finding {
+	filtered := []Finding{}
+	for _, f := range findings {
+		if f.Rule == rule {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
+}
+
+func TestUpdateServerLocations(t *testing.T) {
+	locations := updateServerLocations([]*Location{
+		{Path: "/"},
+		{Path: "/api", PathType: &pathTypePrefix},
+		{Path: "/legacy"},
+	})
+
+	got := make([]string, 0, len(locations))
+	for _, loc := range locations {
+		key := loc.Path
+		if loc.PathType != nil && *loc.PathType == pathTypeExact {
+			key = "= " + key
+		}
+		got = append(got, key)
+	}
+	if want := "/, /api/, = /api, /legacy"; strings.Join(got, ", ") != want {
+		t.Errorf("expected the locations %v, got %v", want, strings.Join(got, ", "))
+	}
+}
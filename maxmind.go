@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// defaultMaxmindDirectory is the directory the ingress controller downloads
+// the GeoIP2 databases to
+const defaultMaxmindDirectory = "/etc/ingress-controller/geoip"
+
+// defaultMaxmindMaxAge is the age after which a database is reported as
+// stale. MaxMind releases the GeoLite2 databases twice a week.
+const defaultMaxmindMaxAge = 30 * 24 * time.Hour
+
+// mmdbMetadataMarker starts the metadata section at the end of the MaxMind
+// DB files, which is at most 128KiB long
+var mmdbMetadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+const mmdbMetadataMaxSize = 128 * 1024
+
+// geoIP2Editions are the editions defining the $geoip2_* variables, by the
+// prefix of the variables
+var geoIP2Editions = []struct {
+	prefix   string
+	editions []string
+}{
+	{"geoip2_city", []string{"GeoLite2-City.mmdb", "GeoIP2-City.mmdb"}},
+	{"geoip2_country", []string{"GeoLite2-Country.mmdb", "GeoIP2-Country.mmdb"}},
+	{"geoip2_continent", []string{"GeoLite2-Country.mmdb", "GeoIP2-Country.mmdb"}},
+	{"geoip2_postal", []string{"GeoLite2-City.mmdb", "GeoIP2-City.mmdb"}},
+	{"geoip2_dma", []string{"GeoLite2-City.mmdb", "GeoIP2-City.mmdb"}},
+	{"geoip2_latitude", []string{"GeoLite2-City.mmdb", "GeoIP2-City.mmdb"}},
+	{"geoip2_longitude", []string{"GeoLite2-City.mmdb", "GeoIP2-City.mmdb"}},
+	{"geoip2_time_zone", []string{"GeoLite2-City.mmdb", "GeoIP2-City.mmdb"}},
+	{"geoip2_region", []string{"GeoLite2-City.mmdb", "GeoIP2-City.mmdb"}},
+	{"geoip2_subregion", []string{"GeoLite2-City.mmdb", "GeoIP2-City.mmdb"}},
+	{"geoip2_asn", []string{"GeoLite2-ASN.mmdb"}},
+	{"geoip2_org", []string{"GeoLite2-ASN.mmdb"}},
+	{"geoip2_isp", []string{"GeoIP2-ISP.mmdb"}},
+	{"geoip2_connection_type", []string{"GeoIP2-Connection-Type.mmdb"}},
+	{"geoip2_is_", []string{"GeoIP2-Anonymous-IP.mmdb"}},
+}
+
+// geoIP2VariableRegex matches the $geoip2_* variables of the snippets
+var geoIP2VariableRegex = regexp.MustCompile(`\$\{?(geoip2_[a-z_]+)`)
+
+// mmdbMetadata is the part of the metadata of a MaxMind DB used to validate it
+type mmdbMetadata struct {
+	DatabaseType string
+	BuildEpoch   time.Time
+}
+
+// readMMDBMetadata reads the metadata of a MaxMind DB file, which fails when
+// the file is not a MaxMind DB
+func readMMDBMetadata(path string) (*mmdbMetadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := min(info.Size(), mmdbMetadataMaxSize)
+	tail := make([]byte, size)
+	if _, err := f.ReadAt(tail, info.Size()-size); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	i := bytes.LastIndex(tail, mmdbMetadataMarker)
+	if i < 0 {
+		return nil, errors.New("not a MaxMind DB file, the metadata marker is missing")
+	}
+	value, _, err := decodeMMDBValue(tail[i+len(mmdbMetadataMarker):])
+	if err != nil {
+		return nil, fmt.Errorf("invalid MaxMind DB metadata: %w", err)
+	}
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid MaxMind DB metadata: not a map")
+	}
+
+	metadata := &mmdbMetadata{}
+	metadata.DatabaseType, _ = fields["database_type"].(string)
+	if epoch, ok := fields["build_epoch"].(uint64); ok && epoch <= math.MaxInt64 {
+		metadata.BuildEpoch = time.Unix(int64(epoch), 0)
+	}
+	if metadata.DatabaseType == "" || metadata.BuildEpoch.IsZero() {
+		return nil, errors.New("invalid MaxMind DB metadata: database_type or build_epoch is missing")
+	}
+	return metadata, nil
+}
+
+// decodeMMDBValue decodes a value of the MaxMind DB data section format and
+// returns the bytes following it. Only the types used by the metadata are
+// supported.
+func decodeMMDBValue(data []byte) (interface{}, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	control := data[0]
+	data = data[1:]
+
+	kind := int(control >> 5)
+	if kind == 0 {
+		if len(data) == 0 {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		kind = 7 + int(data[0])
+		data = data[1:]
+	}
+
+	size := int(control & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if len(data) < n {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		extra := 0
+		for _, b := range data[:n] {
+			extra = extra<<8 | int(b)
+		}
+		size = map[int]int{1: 29, 2: 285, 3: 65821}[n] + extra
+		data = data[n:]
+	}
+
+	switch kind {
+	case 7:
+		// map
+		m := map[string]interface{}{}
+		for range size {
+			key, rest, err := decodeMMDBValue(data)
+			if err != nil {
+				return nil, nil, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, nil, errors.New("map key is not a string")
+			}
+			value, rest, err := decodeMMDBValue(rest)
+			if err != nil {
+				return nil, nil, err
+			}
+			m[name] = value
+			data = rest
+		}
+		return m, data, nil
+	case 11:
+		// array
+		a := make([]interface{}, 0, size)
+		for range size {
+			value, rest, err := decodeMMDBValue(data)
+			if err != nil {
+				return nil, nil, err
+			}
+			a = append(a, value)
+			data = rest
+		}
+		return a, data, nil
+	case 14:
+		// boolean, whose value is the size
+		return size != 0, data, nil
+	}
+
+	if len(data) < size {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	value, data := data[:size], data[size:]
+	switch kind {
+	case 2:
+		// UTF-8 string
+		return string(value), data, nil
+	case 3:
+		// double
+		if size != 8 {
+			return nil, nil, errors.New("invalid double")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(value)), data, nil
+	case 4, 10:
+		// bytes and uint128, kept as bytes
+		return value, data, nil
+	case 5, 6, 8, 9:
+		// uint16, uint32, int32 and uint64
+		if size > 8 {
+			return nil, nil, errors.New("invalid integer")
+		}
+		n := uint64(0)
+		for _, b := range value {
+			n = n<<8 | uint64(b)
+		}
+		return n, data, nil
+	case 15:
+		// float
+		if size != 4 {
+			return nil, nil, errors.New("invalid float")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(value))), data, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported type %d", kind)
+}
+
+// geoIP2Variables returns the $geoip2_* variables used in a snippet
+func geoIP2Variables(snippet string) []string {
+	variables := map[string]bool{}
+	for _, match := range geoIP2VariableRegex.FindAllStringSubmatch(snippet, -1) {
+		variables[match[1]] = true
+	}
+	return sortedSet(variables)
+}
+
+// geoIP2VariableEditions returns the database files defining a variable,
+// nil when it is unknown
+func geoIP2VariableEditions(variable string) []string {
+	for _, e := range geoIP2Editions {
+		if strings.HasPrefix(variable, e.prefix) {
+			return e.editions
+		}
+	}
+	return nil
+}
+
+// checkMaxmindDatabases reports the GeoIP2 databases of --maxmind-edition-ids
+// that are missing, invalid or stale when use-geoip2 is enabled, as nginx
+// does not load them otherwise, and the snippets using $geoip2_*
+// variables that nginx does not define: nginx fails to load a configuration
+// using an unknown variable.
+func (n *NGINXController) checkMaxmindDatabases(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	global := n.store.GetBackendConfiguration()
+
+	// the databases nginx loads, by file name
+	available := map[string]bool{}
+	if global.UseGeoIP2 && n.cfg.MaxmindEditionFiles != nil {
+		directory := n.cfg.MaxmindDirectory
+		if directory == "" {
+			directory = defaultMaxmindDirectory
+		}
+		for _, file := range *n.cfg.MaxmindEditionFiles {
+			path := filepath.Join(directory, file)
+			metadata, err := readMMDBMetadata(path)
+			switch {
+			case errors.Is(err, os.ErrNotExist):
+				findings = append(findings, Finding{
+					Rule:     "geoip-database-missing",
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("GeoIP2 database %v does not exist; nginx fails to start with use-geoip2 enabled", path),
+				})
+			case err != nil:
+				findings = append(findings, Finding{
+					Rule:     "geoip-database-invalid",
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("GeoIP2 database %v is not valid: %v", path, err),
+				})
+			default:
+				available[file] = true
+				if n.cfg.MaxmindMaxAge > 0 && time.Since(metadata.BuildEpoch) > n.cfg.MaxmindMaxAge {
+					findings = append(findings, Finding{
+						Rule:     "geoip-database-stale",
+						Severity: SeverityWarning,
+						Message: fmt.Sprintf("GeoIP2 database %v (%v) was built on %v, more than %v ago; the locations of the addresses may be wrong",
+							path, metadata.DatabaseType, metadata.BuildEpoch.UTC().Format(time.DateOnly), n.cfg.MaxmindMaxAge),
+					})
+				}
+			}
+		}
+	}
+
+	// unavailable returns why nginx does not define the variable, empty when
+	// it does
+	unavailable := func(variable string) string {
+		if !global.UseGeoIP2 {
+			return "use-geoip2 is not enabled in the ConfigMap"
+		}
+		if n.cfg.MaxmindEditionFiles == nil {
+			// the databases are not known
+			return ""
+		}
+		editions := geoIP2VariableEditions(variable)
+		if editions == nil {
+			return "no GeoIP2 database defines it"
+		}
+		for _, edition := range editions {
+			if available[edition] {
+				return ""
+			}
+		}
+		return fmt.Sprintf("it requires the database %v", strings.Join(editions, " or "))
+	}
+
+	report := func(f Finding, snippet, where string) {
+		for _, variable := range geoIP2Variables(snippet) {
+			if reason := unavailable(variable); reason != "" {
+				f.Rule = "geoip-variable-undefined"
+				f.Severity = SeverityError
+				f.Message = fmt.Sprintf("%v uses $%v but %v; nginx fails to load the configuration with an unknown variable",
+					where, variable, reason)
+				findings = append(findings, f)
+			}
+		}
+	}
+
+	for _, s := range []struct {
+		key     string
+		snippet string
+	}{
+		{"http-snippet", global.HTTPSnippet},
+		{"server-snippet", global.ServerSnippet},
+		{"location-snippet", global.LocationSnippet},
+		{"log-format-upstream", global.LogFormatUpstream},
+	} {
+		report(Finding{}, s.snippet, fmt.Sprintf("%v of ConfigMap %v", s.key, n.cfg.ConfigMapName))
+	}
+	for _, server := range cfg.Servers {
+		report(Finding{Ingress: serverIngress(server), Host: server.Hostname},
+			server.ServerSnippet, "the server-snippet of "+server.Hostname)
+		for _, loc := range server.Locations {
+			report(newLocationFinding("", "", server, loc, ""),
+				loc.ConfigurationSnippet, fmt.Sprintf("the configuration-snippet of location %q", loc.Path))
+		}
+	}
+	return findings
+}
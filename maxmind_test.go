@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mmdbString encodes a string of less than 29 bytes in the MaxMind DB
+// data section format
+func mmdbString(s string) []byte {
+	return append([]byte{2<<5 | byte(len(s))}, s...)
+}
+
+// mmdbUint64 encodes an uint64, an extended type
+func mmdbUint64(n uint64) []byte {
+	b := []byte{8, 9 - 7, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint64(b[2:], n)
+	return b
+}
+
+// writeTestMMDB writes a MaxMind DB file made of a data section and the
+// metadata with the type and the build time of the database
+func writeTestMMDB(t *testing.T, dir, name, databaseType string, built time.Time) string {
+	t.Helper()
+	data := append([]byte(strings.Repeat("\x00", 64)), mmdbMetadataMarker...)
+	data = append(data, 7<<5|2)
+	data = append(data, mmdbString("database_type")...)
+	data = append(data, mmdbString(databaseType)...)
+	data = append(data, mmdbString("build_epoch")...)
+	data = append(data, mmdbUint64(uint64(built.Unix()))...)
+	return writeTestFile(t, dir, name, string(data))
+}
+
+func TestReadMMDBMetadata(t *testing.T) {
+	dir := t.TempDir()
+	built := time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC)
+
+	metadata, err := readMMDBMetadata(writeTestMMDB(t, dir, "GeoLite2-City.mmdb", "GeoLite2-City", built))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if metadata.DatabaseType != "GeoLite2-City" || !metadata.BuildEpoch.Equal(built) {
+		t.Errorf("unexpected metadata %+v", metadata)
+	}
+
+	testCases := map[string]struct {
+		content  string
+		expected string
+	}{
+		"not a MaxMind DB": {
+			content:  "GeoIP legacy database",
+			expected: "the metadata marker is missing",
+		},
+		"truncated metadata": {
+			content:  string(mmdbMetadataMarker) + "\xe2",
+			expected: "invalid MaxMind DB metadata",
+		},
+		"metadata not a map": {
+			content:  string(mmdbMetadataMarker) + string(mmdbString("City")),
+			expected: "invalid MaxMind DB metadata: not a map",
+		},
+		"missing build epoch": {
+			content:  string(mmdbMetadataMarker) + "\xe1" + string(mmdbString("database_type")) + string(mmdbString("City")),
+			expected: "database_type or build_epoch is missing",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := readMMDBMetadata(writeTestFile(t, dir, "invalid.mmdb", tc.content))
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("expected an error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+
+	if _, err := readMMDBMetadata(dir + "/missing.mmdb"); !os.IsNotExist(err) {
+		t.Errorf("expected the file not to exist, got %v", err)
+	}
+}
+
+func TestDecodeMMDBValue(t *testing.T) {
+	double := make([]byte, 9)
+	double[0] = 3<<5 | 8
+	binary.BigEndian.PutUint64(double[1:], math.Float64bits(1.5))
+	long := strings.Repeat("x", 40)
+
+	testCases := map[string]struct {
+		data     []byte
+		expected interface{}
+	}{
+		"string":      {data: mmdbString("en"), expected: "en"},
+		"long string": {data: append([]byte{2<<5 | 29, 40 - 29}, long...), expected: long},
+		"double":      {data: double, expected: 1.5},
+		"uint16":      {data: []byte{5<<5 | 2, 0x01, 0x00}, expected: uint64(256)},
+		"boolean":     {data: []byte{1, 14 - 7}, expected: true},
+		"array": {
+			data:     append(append([]byte{2, 11 - 7}, mmdbString("en")...), mmdbString("fr")...),
+			expected: []interface{}{"en", "fr"},
+		},
+		"map": {
+			data:     append(append([]byte{7<<5 | 1}, mmdbString("ip_version")...), 5<<5|1, 6),
+			expected: map[string]interface{}{"ip_version": uint64(6)},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			value, rest, err := decodeMMDBValue(append(tc.data, 0xff))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(value, tc.expected) {
+				t.Errorf("expected %#v, got %#v", tc.expected, value)
+			}
+			if len(rest) != 1 || rest[0] != 0xff {
+				t.Errorf("expected the bytes following the value, got %v", rest)
+			}
+		})
+	}
+
+	for name, data := range map[string][]byte{
+		"empty":          {},
+		"truncated":      {2<<5 | 4, 'e'},
+		"pointer":        {1<<5 | 1, 0},
+		"map key number": {7<<5 | 1, 5<<5 | 1, 6, 5<<5 | 1, 6},
+	} {
+		if _, _, err := decodeMMDBValue(data); err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
+
+func TestGeoIP2Variables(t *testing.T) {
+	snippet := `if ($geoip2_city_country_code = "FR") { return 403; }
+add_header X-ASN "${geoip2_asn}"; add_header X-Country $geoip2_city_country_code;`
+	if got := geoIP2Variables(snippet); !reflect.DeepEqual(got, []string{"geoip2_asn", "geoip2_city_country_code"}) {
+		t.Errorf("unexpected variables %v", got)
+	}
+	if editions := geoIP2VariableEditions("geoip2_is_anonymous_vpn"); !reflect.DeepEqual(editions, []string{"GeoIP2-Anonymous-IP.mmdb"}) {
+		t.Errorf("unexpected editions %v", editions)
+	}
+	if editions := geoIP2VariableEditions("geoip2_weather"); editions != nil {
+		t.Errorf("expected no editions, got %v", editions)
+	}
+}
+
+const maxmindManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+data:
+  allow-snippet-annotations: "true"
+  annotations-risk-level: Critical
+  use-geoip2: "%v"
+  http-snippet: map $geoip2_city_country_code $blocked { default 0; CN 1; }
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/server-snippet: add_header X-ASN $geoip2_asn;
+    nginx.ingress.kubernetes.io/configuration-snippet: add_header X-Weather $geoip2_weather;
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`
+
+func TestCheckMaxmindDatabases(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMMDB(t, dir, "GeoLite2-City.mmdb", "GeoLite2-City", time.Now().Add(-60*24*time.Hour))
+	writeTestFile(t, dir, "GeoIP2-ISP.mmdb", "not a database")
+
+	testCases := map[string]struct {
+		useGeoIP2 bool
+		editions  []string
+		expected  []string
+	}{
+		"databases": {
+			useGeoIP2: true,
+			editions:  []string{"GeoLite2-City.mmdb", "GeoLite2-ASN.mmdb", "GeoIP2-ISP.mmdb"},
+			expected: []string{
+				"geoip-database-stale: GeoIP2 database " + dir + "/GeoLite2-City.mmdb",
+				"geoip-database-missing: GeoIP2 database " + dir + "/GeoLite2-ASN.mmdb",
+				"geoip-database-invalid: GeoIP2 database " + dir + "/GeoIP2-ISP.mmdb",
+				"geoip-variable-undefined: the server-snippet of web.example.com uses $geoip2_asn but it requires the database GeoLite2-ASN.mmdb",
+				`geoip-variable-undefined: the configuration-snippet of location "/" uses $geoip2_weather but no GeoIP2 database defines it`,
+			},
+		},
+		"databases not known": {
+			useGeoIP2: true,
+		},
+		"use-geoip2 disabled": {
+			// the databases are not loaded, so they are not validated
+			editions: []string{"GeoLite2-City.mmdb", "GeoLite2-ASN.mmdb", "GeoIP2-ISP.mmdb"},
+			expected: []string{
+				"geoip-variable-undefined: http-snippet of ConfigMap ingress-nginx/ingress-nginx-controller uses $geoip2_city_country_code but use-geoip2 is not enabled in the ConfigMap",
+				"geoip-variable-undefined: the server-snippet of web.example.com uses $geoip2_asn but use-geoip2 is not enabled in the ConfigMap",
+				`geoip-variable-undefined: the configuration-snippet of location "/" uses $geoip2_weather but use-geoip2 is not enabled in the ConfigMap`,
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, fmt.Sprintf(maxmindManifests, tc.useGeoIP2))
+			n.cfg.MaxmindDirectory = dir
+			n.cfg.MaxmindMaxAge = defaultMaxmindMaxAge
+			if tc.editions != nil {
+				n.cfg.MaxmindEditionFiles = &tc.editions
+			}
+
+			findings := []string{}
+			for _, f := range n.checkMaxmindDatabases(ingresses, cfg) {
+				findings = append(findings, f.Rule+": "+f.Message)
+			}
+			if len(findings) != len(tc.expected) {
+				t.Fatalf("expected %q, got %q", tc.expected, findings)
+			}
+			for i := range tc.expected {
+				if !strings.HasPrefix(findings[i], tc.expected[i]) {
+					t.Errorf("expected %q, got %q", tc.expected[i], findings[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+)
+
+const (
+	meshIstio   = "istio"
+	meshLinkerd = "linkerd"
+)
+
+// backendProtocolAppProtocols contains, for each backend-protocol annotation
+// value, the Service appProtocol values a sidecar can handle without
+// returning 503
+var backendProtocolAppProtocols = map[string][]string{
+	"HTTP":  {"http", "http2", "kubernetes.io/h2c"},
+	"HTTPS": {"https", "tls"},
+	"GRPC":  {"grpc", "http2", "kubernetes.io/h2c"},
+	"GRPCS": {"https", "tls"},
+	"H2C":   {"grpc", "http2", "kubernetes.io/h2c"},
+}
+
+// istioPortPrefixes contains the port name prefixes used by istio to select
+// the protocol when appProtocol is not set
+var istioPortPrefixes = []string{"grpc", "http", "http2", "https", "mongo", "mysql", "redis", "tcp", "tls", "udp"}
+
+// meshInjection returns the name of the service mesh injecting sidecars in
+// the namespace, or an empty string if the namespace is not meshed
+func meshInjection(ns *apiv1.Namespace) string {
+	if ns == nil {
+		return ""
+	}
+
+	if ns.Labels["istio-injection"] == "enabled" || ns.Labels["istio.io/rev"] != "" {
+		return meshIstio
+	}
+
+	if ns.Annotations["linkerd.io/inject"] == "enabled" || ns.Labels["linkerd.io/inject"] == "enabled" {
+		return meshLinkerd
+	}
+
+	return ""
+}
+
+// servicePortAppProtocol returns the application protocol of the service port
+// referenced by port, using the istio port naming convention when appProtocol
+// is not set
+func servicePortAppProtocol(svc *apiv1.Service, port intstr.IntOrString) string {
+	for i := range svc.Spec.Ports {
+		sp := svc.Spec.Ports[i]
+		if port.Type == intstr.Int && sp.Port != port.IntVal {
+			continue
+		}
+		if port.Type == intstr.String && sp.Name != port.StrVal {
+			continue
+		}
+
+		if sp.AppProtocol != nil {
+			return strings.ToLower(*sp.AppProtocol)
+		}
+		name, _, _ := strings.Cut(strings.ToLower(sp.Name), "-")
+		if containsString(istioPortPrefixes, name) {
+			return name
+		}
+		return ""
+	}
+
+	return ""
+}
+
+// checkServiceMesh detects backends running in namespaces with sidecar
+// injection enabled and reports configurations known to conflict with the
+// sidecar proxy
+func (n *NGINXController) checkServiceMesh(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	meshes := map[string]string{}
+
+	meshOf := func(namespace string) string {
+		if mesh, ok := meshes[namespace]; ok {
+			return mesh
+		}
+		ns, err := n.store.GetNamespace(namespace)
+		if err != nil {
+			klog.V(3).Infof("Error getting Namespace %q: %v", namespace, err)
+		}
+		meshes[namespace] = meshInjection(ns)
+		return meshes[namespace]
+	}
+
+	for _, l4 := range cfg.TCPEndpoints {
+		if !l4.Backend.ProxyProtocol.Encode {
+			continue
+		}
+		if mesh := meshOf(l4.Backend.Namespace); mesh != "" {
+			findings = append(findings, Finding{
+				Rule:     "mesh-proxy-protocol",
+				Severity: SeverityError,
+				Message: fmt.Sprintf("TCP service on port %v sends the PROXY protocol header to %v/%v but the %v sidecar does not understand it and will reset the connections",
+					l4.Port, l4.Backend.Namespace, l4.Backend.Name, mesh),
+			})
+		}
+	}
+
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			if loc.Service == nil || loc.IsDefBackend {
+				continue
+			}
+
+			mesh := meshOf(loc.Service.Namespace)
+			if mesh == "" {
+				continue
+			}
+
+			protocol := strings.ToUpper(loc.BackendProtocol)
+			if protocol == "" {
+				protocol = "HTTP"
+			}
+			if protocol == "HTTPS" || protocol == "GRPCS" || loc.ProxySSL.Secret != "" {
+				findings = append(findings, newLocationFinding("mesh-double-tls", SeverityWarning, server, loc,
+					"location %q terminates TLS against Service %v/%v which is part of the %v mesh; the sidecar already provides mTLS and will not be able to inspect the traffic",
+					loc.Path, loc.Service.Namespace, loc.Service.Name, mesh))
+			}
+
+			appProtocol := servicePortAppProtocol(loc.Service, loc.Port)
+			expected, ok := backendProtocolAppProtocols[protocol]
+			if appProtocol == "" || !ok {
+				continue
+			}
+			if !containsString(expected, appProtocol) {
+				findings = append(findings, newLocationFinding("mesh-app-protocol", SeverityError, server, loc,
+					"location %q uses backend protocol %v but port %v of Service %v/%v declares %q; the %v sidecar will answer with 503",
+					loc.Path, protocol, loc.Port.String(), loc.Service.Namespace, loc.Service.Name, appProtocol, mesh))
+			}
+		}
+	}
+
+	return findings
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// namespaceStore is a Storer knowing only the namespaces
+type namespaceStore struct {
+	Storer
+	namespaces map[string]*apiv1.Namespace
+}
+
+func (s namespaceStore) GetNamespace(name string) (*apiv1.Namespace, error) {
+	if ns, ok := s.namespaces[name]; ok {
+		return ns, nil
+	}
+	return nil, fmt.Errorf("namespace %v not found", name)
+}
+
+func TestMeshInjection(t *testing.T) {
+	tests := []struct {
+		name     string
+		ns       *apiv1.Namespace
+		expected string
+	}{
+		{name: "no namespace"},
+		{name: "not meshed", ns: &apiv1.Namespace{}},
+		{name: "istio label", ns: &apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"istio-injection": "enabled"}}}, expected: meshIstio},
+		{name: "istio revision", ns: &apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"istio.io/rev": "canary"}}}, expected: meshIstio},
+		{name: "linkerd annotation", ns: &apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"linkerd.io/inject": "enabled"}}}, expected: meshLinkerd},
+		{name: "istio disabled", ns: &apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"istio-injection": "disabled"}}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if mesh := meshInjection(tc.ns); mesh != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, mesh)
+			}
+		})
+	}
+}
+
+func TestCheckServiceMesh(t *testing.T) {
+	grpc := "grpc"
+	service := func(namespace string, port apiv1.ServicePort) *apiv1.Service {
+		return &apiv1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "app"},
+			Spec:       apiv1.ServiceSpec{Ports: []apiv1.ServicePort{port}},
+		}
+	}
+	n := &NGINXController{store: namespaceStore{namespaces: map[string]*apiv1.Namespace{
+		"meshed": {ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"istio-injection": "enabled"}}},
+		"plain":  {},
+	}}}
+
+	tests := []struct {
+		name     string
+		loc      *Location
+		tcp      []L4Service
+		expected []string
+	}{
+		{
+			name: "http to a meshed http port",
+			loc:  &Location{Path: "/", Service: service("meshed", apiv1.ServicePort{Name: "http-web", Port: 80}), Port: intstr.FromInt(80)},
+		},
+		{
+			name:     "https to a meshed service",
+			loc:      &Location{Path: "/", BackendProtocol: "HTTPS", Service: service("meshed", apiv1.ServicePort{Name: "https", Port: 443}), Port: intstr.FromInt(443)},
+			expected: []string{"mesh-double-tls"},
+		},
+		{
+			name:     "http to a meshed grpc port",
+			loc:      &Location{Path: "/", Service: service("meshed", apiv1.ServicePort{Port: 80, AppProtocol: &grpc}), Port: intstr.FromInt(80)},
+			expected: []string{"mesh-app-protocol"},
+		},
+		{
+			name: "https to a service outside the mesh",
+			loc:  &Location{Path: "/", BackendProtocol: "HTTPS", Service: service("plain", apiv1.ServicePort{Name: "https", Port: 443}), Port: intstr.FromInt(443)},
+		},
+		{
+			name:     "PROXY protocol to a meshed TCP service",
+			tcp:      []L4Service{{Port: 9000, Backend: L4Backend{Namespace: "meshed", Name: "db", ProxyProtocol: ProxyProtocol{Encode: true}}}},
+			expected: []string{"mesh-proxy-protocol"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &Configuration{TCPEndpoints: tc.tcp}
+			if tc.loc != nil {
+				cfg.Servers = []*Server{{Hostname: "app.example.com", Locations: []*Location{tc.loc}}}
+			}
+
+			findings := n.checkServiceMesh(nil, cfg)
+			if len(findings) != len(tc.expected) {
+				t.Fatalf("expected the findings %v, got %v", tc.expected, findings)
+			}
+			for i, f := range findings {
+				if f.Rule != tc.expected[i] {
+					t.Errorf("expected the finding %v, got %v", tc.expected[i], f)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// clusterServiceKey returns the namespace/name of the Service of a cluster
+// DNS name (<name>.<namespace>.svc[.<cluster domain>]), false for other names
+func clusterServiceKey(host string) (string, bool) {
+	labels := strings.Split(host, ".")
+	if len(labels) < 3 || labels[2] != "svc" {
+		return "", false
+	}
+	return labels[1] + "/" + labels[0], true
+}
+
+// checkMirrors validates the mirror-target of the locations and detects the
+// mirrors sending the traffic back to the hosts of the configuration: the
+// mirrored requests are served again by the ingress controller, and mirrored
+// again when the location they reach mirrors as well
+func (n *NGINXController) checkMirrors(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	resolved := map[string]error{}
+
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			if loc.Mirror.Target == "" {
+				continue
+			}
+
+			target, err := parseNginxURL(loc.Mirror.Target)
+			if err != nil {
+				findings = append(findings, newLocationFinding("mirror-invalid", SeverityError, server, loc,
+					"mirror-target %q of location %q is not valid: %v", loc.Mirror.Target, loc.Path, err))
+				continue
+			}
+			if target.dynamicHost {
+				continue
+			}
+			host := target.url.Hostname()
+
+			if key, ok := clusterServiceKey(host); ok {
+				if _, err := n.store.GetService(key); err != nil {
+					findings = append(findings, newLocationFinding("mirror-service-missing", SeverityError, server, loc,
+						"mirror-target %q of location %q refers to Service %v, which does not exist; the mirrored requests fail",
+						loc.Mirror.Target, loc.Path, key))
+				}
+			} else if n.cfg.VerifyDNS && findServer(cfg, host) == nil {
+				err, ok := resolved[host]
+				if !ok {
+					err = resolveExternalName(n.externalNameResolver(), host)
+					resolved[host] = err
+				}
+				if err != nil {
+					findings = append(findings, newLocationFinding("mirror-host-unresolved", SeverityError, server, loc,
+						"mirror-target %q of location %q can not be used: %v", loc.Mirror.Target, loc.Path, err))
+				}
+			}
+
+			if f := mirrorLoop(cfg, server, loc, target); f != nil {
+				findings = append(findings, *f)
+			}
+		}
+	}
+
+	return findings
+}
+
+// mirrorLoop follows the mirrors from a location through the hosts of the
+// configuration and returns a finding when the mirrored traffic comes back
+// to the ingress controller
+func mirrorLoop(cfg *Configuration, server *Server, loc *Location, target *nginxURL) *Finding {
+	chain := []string{fmt.Sprintf("%v%v", server.Hostname, loc.Path)}
+	visited := map[*Location]bool{loc: true}
+
+	current, currentTarget := loc, target
+	for {
+		next, _ := routeServer(cfg, currentTarget.url.Hostname())
+		if next == nil || next.Hostname == "_" {
+			break
+		}
+		nextLoc := matchLocation(next, nginxURLPath(current.Mirror.Target, currentTarget.url, current))
+		if nextLoc == nil {
+			break
+		}
+		chain = append(chain, fmt.Sprintf("%v%v", next.Hostname, nextLoc.Path))
+
+		if visited[nextLoc] {
+			f := newLocationFinding("mirror-loop", SeverityError, server, loc,
+				"mirror-target %q of location %q sends the mirrored requests into a loop (%v); every request is mirrored endlessly",
+				loc.Mirror.Target, loc.Path, strings.Join(chain, " -> "))
+			return &f
+		}
+		visited[nextLoc] = true
+
+		if nextLoc.Mirror.Target == "" {
+			break
+		}
+		nextTarget, err := parseNginxURL(nextLoc.Mirror.Target)
+		if err != nil || nextTarget.dynamicHost {
+			break
+		}
+		current, currentTarget = nextLoc, nextTarget
+	}
+
+	if len(chain) == 1 {
+		return nil
+	}
+	f := newLocationFinding("mirror-self", SeverityWarning, server, loc,
+		"mirror-target %q of location %q is served by the same ingress controller (%v); every mirrored request is processed twice, mirror to the Service instead",
+		loc.Mirror.Target, loc.Path, strings.Join(chain, " -> "))
+	return &f
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClusterServiceKey(t *testing.T) {
+	for host, expected := range map[string]string{
+		"shadow.default.svc":               "default/shadow",
+		"shadow.default.svc.cluster.local": "default/shadow",
+		"shadow.default":                   "",
+		"shadow.default.example.com":       "",
+	} {
+		if key, ok := clusterServiceKey(host); key != expected || ok != (expected != "") {
+			t.Errorf("expected %q for %v, got %q %v", expected, host, key, ok)
+		}
+	}
+}
+
+// mirrorIngress returns an Ingress of host mirroring / to target
+func mirrorIngress(name, host, target string) string {
+	return fmt.Sprintf(`
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: %v
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/mirror-target: %q
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: %v
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: %v
+            port:
+              number: 80
+`, name, target, host, name)
+}
+
+func TestCheckMirrors(t *testing.T) {
+	const shadowService = `
+apiVersion: v1
+kind: Service
+metadata:
+  name: shadow
+  namespace: default
+spec:
+  ports:
+  - port: 80
+`
+
+	testCases := map[string]struct {
+		manifests string
+		verifyDNS bool
+		expected  []string
+	}{
+		"Service": {
+			manifests: shadowService + mirrorIngress("web", "web.example.com", "http://shadow.default.svc.cluster.local$request_uri"),
+		},
+		"missing Service": {
+			manifests: mirrorIngress("web", "web.example.com", "http://shadow.default.svc.cluster.local$request_uri"),
+			expected:  []string{"mirror-service-missing web.example.com"},
+		},
+		"invalid target": {
+			manifests: mirrorIngress("web", "web.example.com", "ftp://shadow.example.com"),
+			expected:  []string{"mirror-invalid web.example.com"},
+		},
+		"dynamic host": {
+			manifests: mirrorIngress("web", "web.example.com", "https://$host$request_uri"),
+			verifyDNS: true,
+		},
+		"unresolved host": {
+			manifests: mirrorIngress("web", "web.example.com", "http://localhost$request_uri"),
+			verifyDNS: true,
+			expected:  []string{"mirror-host-unresolved web.example.com"},
+		},
+		"host of the configuration": {
+			manifests: mirrorIngress("web", "web.example.com", "https://shadow.example.com$request_uri") +
+				mirrorIngress("shadow", "shadow.example.com", ""),
+			// the hosts of the configuration are not resolved
+			verifyDNS: true,
+			expected:  []string{"mirror-self web.example.com"},
+		},
+		"loop": {
+			manifests: mirrorIngress("web", "web.example.com", "https://shadow.example.com$request_uri") +
+				mirrorIngress("shadow", "shadow.example.com", "https://web.example.com$request_uri"),
+			expected: []string{"mirror-loop shadow.example.com", "mirror-loop web.example.com"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, tc.manifests)
+			n.cfg.VerifyDNS = tc.verifyDNS
+
+			findings := []string{}
+			for _, f := range n.checkMirrors(ingresses, cfg) {
+				findings = append(findings, f.Rule+" "+f.Host)
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(findings, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, findings)
+			}
+		})
+	}
+
+	// the chain of the loop is reported
+	n, ingresses, cfg := testConfiguration(t, mirrorIngress("web", "web.example.com", "https://web.example.com/shadow"))
+	findings := n.checkMirrors(ingresses, cfg)
+	if len(findings) != 1 || !strings.Contains(findings[0].Message, "(web.example.com/ -> web.example.com/)") {
+		t.Errorf("expected the location mirroring to itself, got %+v", findings)
+	}
+}
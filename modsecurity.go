@@ -0,0 +1,429 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// modSecurityDirectives contains the directives accepted in
+// modsecurity-snippet, with the number of arguments they take (-1 means the
+// arguments are checked by a specific parser)
+var modSecurityDirectives = map[string]int{
+	"secrule":                        -1,
+	"secaction":                      -1,
+	"secruleengine":                  1,
+	"secrequestbodyaccess":           1,
+	"secresponsebodyaccess":          1,
+	"secrequestbodylimit":            1,
+	"secrequestbodynofileslimit":     1,
+	"secrequestbodylimitaction":      1,
+	"secresponsebodylimit":           1,
+	"secresponsebodylimitaction":     1,
+	"secresponsebodymimetype":        -1,
+	"secauditengine":                 1,
+	"secauditlog":                    1,
+	"secauditlogparts":               1,
+	"secauditlogtype":                1,
+	"secauditlogformat":              1,
+	"secauditlogrelevantstatus":      1,
+	"secauditlogstoragedir":          1,
+	"secdebuglog":                    1,
+	"secdebugloglevel":               1,
+	"secdefaultaction":               1,
+	"secmarker":                      1,
+	"secruleremovebyid":              -1,
+	"secruleremovebytag":             1,
+	"secruleremovebymsg":             1,
+	"secruleupdatetargetbyid":        -1,
+	"secruleupdatetargetbytag":       -1,
+	"secruleupdateactionbyid":        2,
+	"secargumentseparator":           1,
+	"seccookieformat":                1,
+	"secpcrematchlimit":              1,
+	"secpcrematchlimitrecursion":     1,
+	"seccollectiontimeout":           1,
+	"sectmpdir":                      1,
+	"secdatadir":                     1,
+	"secunicodemapfile":              2,
+	"secstatusengine":                1,
+	"seccomponentsignature":          1,
+	"secwebappid":                    1,
+	"secruleengineoverride":          1,
+	"secuploaddir":                   1,
+	"secuploadkeepfiles":             1,
+	"secuploadfilelimit":             1,
+	"secuploadfilemode":              1,
+	"include":                        1,
+	"secauditlogdirmode":             1,
+	"secauditlogfilemode":            1,
+	"secrequestbodyinmemorylimit":    1,
+	"secrequestbodyjsondepthlimit":   1,
+	"secargumentslimit":              1,
+	"secremoterules":                 2,
+	"secremoterulesfailaction":       1,
+	"sechttpblkey":                   1,
+	"secxmlexternalentity":           1,
+	"secconnengine":                  1,
+	"secgeolookupdb":                 1,
+	"secruleperftime":                1,
+	"secstreaminbodyinspection":      1,
+	"secstreamoutbodyinspection":     1,
+	"secinterceptonerror":            1,
+	"secauditlogrelevantstatusregex": 1,
+}
+
+var modSecurityOnOff = map[string][]string{
+	"secruleengine":         {"on", "off", "detectiononly"},
+	"secrequestbodyaccess":  {"on", "off"},
+	"secresponsebodyaccess": {"on", "off"},
+	"secauditengine":        {"on", "off", "relevantonly"},
+}
+
+// modSecurityVariables contains the collections that can be used as targets
+var modSecurityVariables = []string{
+	"args", "args_combined_size", "args_get", "args_get_names", "args_names", "args_post", "args_post_names",
+	"auth_type", "duration", "env", "files", "files_combined_size", "files_names", "files_sizes",
+	"files_tmpnames", "files_tmp_content", "full_request", "full_request_length", "geo", "highest_severity",
+	"inbound_data_error", "ip", "matched_var", "matched_var_name", "matched_vars", "matched_vars_names",
+	"modsec_build", "msc_pcre_limits_exceeded", "multipart_crlf_lf_lines", "multipart_filename",
+	"multipart_name", "multipart_part_headers", "multipart_strict_error", "multipart_unmatched_boundary",
+	"outbound_data_error", "path_info", "query_string", "remote_addr", "remote_host", "remote_port",
+	"remote_user", "reqbody_error", "reqbody_error_msg", "reqbody_processor", "request_basename",
+	"request_body", "request_body_length", "request_cookies", "request_cookies_names", "request_filename",
+	"request_headers", "request_headers_names", "request_line", "request_method", "request_protocol",
+	"request_uri", "request_uri_raw", "resource", "response_body", "response_content_length",
+	"response_content_type", "response_headers", "response_headers_names", "response_protocol",
+	"response_status", "rule", "server_addr", "server_name", "server_port", "session", "sessionid",
+	"status_line", "time", "time_day", "time_epoch", "time_hour", "time_min", "time_mon", "time_sec",
+	"time_wday", "time_year", "tx", "unique_id", "urlencoded_error", "user", "userid", "webappid",
+	"xml", "global", "multipart_boundary_quoted", "multipart_boundary_whitespace", "multipart_data_after",
+	"multipart_data_before", "multipart_file_limit_exceeded", "multipart_header_folding",
+	"multipart_invalid_header_folding", "multipart_invalid_part", "multipart_invalid_quoting",
+	"multipart_lf_line", "multipart_missing_semicolon", "reqbody_processor_error",
+	"reqbody_processor_error_msg", "status",
+}
+
+// modSecurityOperators contains the operators supported by libmodsecurity
+var modSecurityOperators = []string{
+	"beginswith", "contains", "containsword", "detectsqli", "detectxss", "endswith", "eq", "fuzzyhash",
+	"ge", "geolookup", "gsblookup", "gt", "inspectfile", "ipmatch", "ipmatchf", "ipmatchfromfile", "le",
+	"lt", "nomatch", "pm", "pmf", "pmfromfile", "rbl", "rsub", "rx", "rxglobal", "streq", "strmatch",
+	"unconditionalmatch", "validatebyterange", "validatedtd", "validatehash", "validateschema",
+	"validateurlencoding", "validateutf8encoding", "verifycc", "verifycpf", "verifyssn", "verifysvnr",
+	"within",
+}
+
+// modSecurityActions contains the actions supported by libmodsecurity
+var modSecurityActions = []string{
+	"accuracy", "allow", "append", "auditlog", "block", "capture", "chain", "ctl", "deny", "deprecatevar",
+	"drop", "exec", "expirevar", "id", "initcol", "log", "logdata", "maturity", "msg", "multimatch",
+	"noauditlog", "nolog", "pass", "pause", "phase", "prepend", "proxy", "redirect", "rev", "sanitisearg",
+	"sanitisematched", "sanitisematchedbytes", "sanitiserequestheader", "sanitiseresponseheader",
+	"setenv", "setrsc", "setsid", "setuid", "setvar", "severity", "skip", "skipafter", "status", "t",
+	"tag", "ver", "xmlns",
+}
+
+// modSecurityError is a syntax error in a modsecurity snippet
+type modSecurityError struct {
+	Line    int
+	Message string
+}
+
+func (e modSecurityError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Message)
+}
+
+// modSecurityStatement is a directive with its arguments
+type modSecurityStatement struct {
+	line      int
+	directive string
+	args      []string
+}
+
+// validateModSecuritySnippet checks the syntax of the directives contained in
+// a modsecurity-snippet annotation and returns all the errors found
+func validateModSecuritySnippet(snippet string) []modSecurityError {
+	statements, errs := tokenizeModSecurity(snippet)
+
+	ids := map[int]int{}
+	chained := false
+	for _, st := range statements {
+		expected, ok := modSecurityDirectives[strings.ToLower(st.directive)]
+		if !ok {
+			errs = append(errs, modSecurityError{st.line, fmt.Sprintf("unknown directive %q", st.directive)})
+			continue
+		}
+
+		directive := strings.ToLower(st.directive)
+		if expected >= 0 && len(st.args) != expected {
+			errs = append(errs, modSecurityError{st.line, fmt.Sprintf("%v expects %d argument(s), found %d", st.directive, expected, len(st.args))})
+			continue
+		}
+
+		if values, ok := modSecurityOnOff[directive]; ok && !containsString(values, strings.ToLower(st.args[0])) {
+			errs = append(errs, modSecurityError{st.line, fmt.Sprintf("invalid value %q for %v, expected one of %v", st.args[0], st.directive, values)})
+			continue
+		}
+
+		switch directive {
+		case "secrule":
+			if len(st.args) < 2 || len(st.args) > 3 {
+				errs = append(errs, modSecurityError{st.line, fmt.Sprintf("SecRule expects variables, operator and actions, found %d argument(s)", len(st.args))})
+				chained = false
+				continue
+			}
+			if err := checkModSecurityVariables(st.args[0]); err != nil {
+				errs = append(errs, modSecurityError{st.line, err.Error()})
+			}
+			if err := checkModSecurityOperator(st.args[1]); err != nil {
+				errs = append(errs, modSecurityError{st.line, err.Error()})
+			}
+			actions := ""
+			if len(st.args) == 3 {
+				actions = st.args[2]
+			}
+			isChained, err := checkModSecurityActions(actions, !chained, ids, st.line)
+			if err != nil {
+				errs = append(errs, modSecurityError{st.line, err.Error()})
+			}
+			chained = isChained
+		case "secaction":
+			if len(st.args) != 1 {
+				errs = append(errs, modSecurityError{st.line, fmt.Sprintf("SecAction expects 1 argument, found %d", len(st.args))})
+				continue
+			}
+			if _, err := checkModSecurityActions(st.args[0], true, ids, st.line); err != nil {
+				errs = append(errs, modSecurityError{st.line, err.Error()})
+			}
+		case "secruleremovebyid":
+			for _, arg := range st.args {
+				for _, id := range strings.Split(arg, "-") {
+					if _, err := strconv.Atoi(id); err != nil {
+						errs = append(errs, modSecurityError{st.line, fmt.Sprintf("invalid rule id or range %q", arg)})
+						break
+					}
+				}
+			}
+		case "secruleupdatetargetbyid", "secruleupdatetargetbytag":
+			if len(st.args) < 2 {
+				errs = append(errs, modSecurityError{st.line, fmt.Sprintf("%v expects at least 2 arguments", st.directive)})
+				continue
+			}
+			if err := checkModSecurityVariables(st.args[1]); err != nil {
+				errs = append(errs, modSecurityError{st.line, err.Error()})
+			}
+		case "secdefaultaction":
+			if err := checkModSecurityActionList(st.args[0]); err != nil {
+				errs = append(errs, modSecurityError{st.line, err.Error()})
+			}
+		}
+	}
+
+	if chained {
+		errs = append(errs, modSecurityError{statements[len(statements)-1].line, "the last rule uses chain but there is no rule after it"})
+	}
+
+	return errs
+}
+
+// tokenizeModSecurity splits the snippet in statements, handling quotes,
+// escaped characters, comments and line continuations
+func tokenizeModSecurity(snippet string) ([]modSecurityStatement, []modSecurityError) {
+	var statements []modSecurityStatement
+	var errs []modSecurityError
+
+	lines := strings.Split(snippet, "\n")
+	for i := 0; i < len(lines); i++ {
+		startLine := i + 1
+		line := strings.TrimRight(lines[i], " \t\r")
+		for strings.HasSuffix(line, "\\") && i+1 < len(lines) {
+			i++
+			line = strings.TrimSuffix(line, "\\") + " " + strings.TrimSpace(strings.TrimRight(lines[i], "\r"))
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		var tokens []string
+		var current strings.Builder
+		inQuotes, hasToken := false, false
+		for j := 0; j < len(trimmed); j++ {
+			c := trimmed[j]
+			switch {
+			case c == '\\' && j+1 < len(trimmed):
+				current.WriteByte(c)
+				current.WriteByte(trimmed[j+1])
+				hasToken = true
+				j++
+			case c == '"':
+				inQuotes = !inQuotes
+				hasToken = true
+			case (c == ' ' || c == '\t') && !inQuotes:
+				if hasToken {
+					tokens = append(tokens, current.String())
+					current.Reset()
+					hasToken = false
+				}
+			default:
+				current.WriteByte(c)
+				hasToken = true
+			}
+		}
+		if inQuotes {
+			errs = append(errs, modSecurityError{startLine, "unterminated quoted string"})
+			continue
+		}
+		if hasToken {
+			tokens = append(tokens, current.String())
+		}
+
+		statements = append(statements, modSecurityStatement{line: startLine, directive: tokens[0], args: tokens[1:]})
+	}
+
+	return statements, errs
+}
+
+func checkModSecurityVariables(vars string) error {
+	if vars == "" {
+		return fmt.Errorf("SecRule without variables")
+	}
+
+	for _, v := range strings.Split(vars, "|") {
+		v = strings.TrimLeft(strings.TrimSpace(v), "!&")
+		name, _, _ := strings.Cut(v, ":")
+		if !containsString(modSecurityVariables, strings.ToLower(name)) {
+			return fmt.Errorf("unknown variable %q", name)
+		}
+	}
+
+	return nil
+}
+
+func checkModSecurityOperator(op string) error {
+	op = strings.TrimPrefix(strings.TrimSpace(op), "!")
+	if !strings.HasPrefix(op, "@") {
+		// operators without @ are regular expressions
+		return nil
+	}
+
+	name, _, _ := strings.Cut(op[1:], " ")
+	if !containsString(modSecurityOperators, strings.ToLower(name)) {
+		return fmt.Errorf("unknown operator @%v", name)
+	}
+
+	return nil
+}
+
+// checkModSecurityActions validates the actions of a rule. The id action is
+// required unless the rule is part of a chain, and must be unique.
+func checkModSecurityActions(actions string, requireID bool, ids map[int]int, line int) (bool, error) {
+	if err := checkModSecurityActionList(actions); err != nil {
+		return false, err
+	}
+
+	chained, hasID := false, false
+	for _, action := range splitModSecurityActions(actions) {
+		name, value, _ := strings.Cut(action, ":")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "chain":
+			chained = true
+		case "id":
+			hasID = true
+			id, err := strconv.Atoi(strings.Trim(strings.TrimSpace(value), "'"))
+			if err != nil || id <= 0 {
+				return chained, fmt.Errorf("invalid rule id %q", value)
+			}
+			if previous, ok := ids[id]; ok {
+				return chained, fmt.Errorf("rule id %d is already used on line %d", id, previous)
+			}
+			ids[id] = line
+		case "phase":
+			phase := strings.ToLower(strings.Trim(strings.TrimSpace(value), "'"))
+			if !containsString([]string{"1", "2", "3", "4", "5", "request", "response", "logging"}, phase) {
+				return chained, fmt.Errorf("invalid phase %q", value)
+			}
+		}
+	}
+
+	if requireID && !hasID {
+		return chained, fmt.Errorf("rule without id action")
+	}
+
+	return chained, nil
+}
+
+func checkModSecurityActionList(actions string) error {
+	for _, action := range splitModSecurityActions(actions) {
+		name, _, _ := strings.Cut(action, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			return fmt.Errorf("empty action in %q", actions)
+		}
+		if !containsString(modSecurityActions, name) {
+			return fmt.Errorf("unknown action %q", name)
+		}
+	}
+	return nil
+}
+
+// splitModSecurityActions splits a list of actions by commas not contained
+// in single quotes
+func splitModSecurityActions(actions string) []string {
+	if strings.TrimSpace(actions) == "" {
+		return nil
+	}
+
+	var parts []string
+	var current strings.Builder
+	inQuotes := false
+	for i := 0; i < len(actions); i++ {
+		c := actions[i]
+		switch {
+		case c == '\\' && i+1 < len(actions):
+			current.WriteByte(c)
+			current.WriteByte(actions[i+1])
+			i++
+		case c == '\'':
+			inQuotes = !inQuotes
+			current.WriteByte(c)
+		case c == ',' && !inQuotes:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	return append(parts, current.String())
+}
+
+// checkModSecuritySnippets validates the syntax of the modsecurity-snippet
+// annotations, which otherwise only fail when nginx loads the rules
+func (n *NGINXController) checkModSecuritySnippets(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	global := n.store.GetBackendConfiguration()
+	checked := map[*Ingress]bool{}
+
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			if loc.ModSecurity.Snippet == "" || loc.Ingress == nil || checked[loc.Ingress] {
+				continue
+			}
+			checked[loc.Ingress] = true
+
+			if !global.EnableModsecurity && !loc.ModSecurity.Enable {
+				findings = append(findings, newLocationFinding("modsecurity-disabled", SeverityWarning, server, loc,
+					"modsecurity-snippet is ignored because ModSecurity is not enabled globally nor with the enable-modsecurity annotation"))
+			}
+
+			for _, err := range validateModSecuritySnippet(loc.ModSecurity.Snippet) {
+				findings = append(findings, newLocationFinding("modsecurity-snippet", SeverityError, server, loc,
+					"invalid modsecurity-snippet: %v", err))
+			}
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestValidateModSecuritySnippet(t *testing.T) {
+	tests := []struct {
+		name     string
+		snippet  string
+		expected []string
+	}{
+		{
+			name: "valid rules",
+			snippet: `SecRuleEngine On
+# block the admin area
+SecRule REQUEST_URI "@beginsWith /admin" \
+    "id:1001,phase:1,deny,status:403,msg:'admin, blocked'"
+SecRule REQUEST_METHOD "@streq POST" "id:1002,phase:2,chain,pass"
+    SecRule ARGS:token|!ARGS:name "@rx ^$" "deny"
+SecRuleRemoveById 920350 930000-930999
+SecAction "id:1003,phase:1,nolog,pass,setvar:tx.paranoia_level=2"`,
+		},
+		{
+			name:     "unknown directive",
+			snippet:  "SecRuleEngin On",
+			expected: []string{`line 1: unknown directive "SecRuleEngin"`},
+		},
+		{
+			name:     "invalid engine value",
+			snippet:  "SecRuleEngine Enabled",
+			expected: []string{`line 1: invalid value "Enabled" for SecRuleEngine, expected one of [on off detectiononly]`},
+		},
+		{
+			name:     "wrong number of arguments",
+			snippet:  "SecAuditLog",
+			expected: []string{"line 1: SecAuditLog expects 1 argument(s), found 0"},
+		},
+		{
+			name:     "unterminated quote",
+			snippet:  `SecRule ARGS "@rx foo "id:1,deny"`,
+			expected: []string{"line 1: unterminated quoted string"},
+		},
+		{
+			name:     "unknown variable",
+			snippet:  `SecRule REQUEST_URL "@rx foo" "id:1,deny"`,
+			expected: []string{`line 1: unknown variable "REQUEST_URL"`},
+		},
+		{
+			name:     "unknown operator",
+			snippet:  `SecRule ARGS "@regex foo" "id:1,deny"`,
+			expected: []string{"line 1: unknown operator @regex"},
+		},
+		{
+			name:     "unknown action",
+			snippet:  `SecRule ARGS "@rx foo" "id:1,reject"`,
+			expected: []string{`line 1: unknown action "reject"`},
+		},
+		{
+			name:     "rule without id",
+			snippet:  `SecRule ARGS "@rx foo" "phase:2,deny"`,
+			expected: []string{"line 1: rule without id action"},
+		},
+		{
+			name:     "invalid phase",
+			snippet:  `SecRule ARGS "@rx foo" "id:1,phase:6,deny"`,
+			expected: []string{`line 1: invalid phase "6"`},
+		},
+		{
+			name: "duplicated id",
+			snippet: `SecRule ARGS "@rx foo" "id:1,deny"
+SecRule ARGS "@rx bar" "id:1,deny"`,
+			expected: []string{"line 2: rule id 1 is already used on line 1"},
+		},
+		{
+			name:     "chain without rule",
+			snippet:  `SecRule ARGS "@rx foo" "id:1,chain,deny"`,
+			expected: []string{"line 1: the last rule uses chain but there is no rule after it"},
+		},
+		{
+			name:     "invalid rule range",
+			snippet:  "SecRuleRemoveById 100-abc",
+			expected: []string{`line 1: invalid rule id or range "100-abc"`},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := []string{}
+			for _, err := range validateModSecuritySnippet(tc.snippet) {
+				got = append(got, err.Error())
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+const modSecurityManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+data:
+  allow-snippet-annotations: "true"
+  annotations-risk-level: Critical
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/enable-modsecurity: "%v"
+    nginx.ingress.kubernetes.io/modsecurity-snippet: |
+      SecRuleEngine Maybe
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`
+
+func TestCheckModSecuritySnippets(t *testing.T) {
+	tests := []struct {
+		enabled  bool
+		expected []string
+	}{
+		{enabled: true, expected: []string{"modsecurity-snippet"}},
+		{enabled: false, expected: []string{"modsecurity-disabled", "modsecurity-snippet"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("enabled %v", tc.enabled), func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, fmt.Sprintf(modSecurityManifests, tc.enabled))
+
+			rules := []string{}
+			for _, f := range n.checkModSecuritySnippets(ingresses, cfg) {
+				rules = append(rules, f.Rule)
+			}
+			if !reflect.DeepEqual(rules, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, rules)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// namespaceInScope returns true if the namespace matches the
+// WatchNamespaceSelector. Namespaces not present in the store only have the
+// kubernetes.io/metadata.name label.
+func (n *NGINXController) namespaceInScope(namespace string) bool {
+	if n.cfg.WatchNamespaceSelector == nil || n.cfg.WatchNamespaceSelector.Empty() {
+		return true
+	}
+
+	nsLabels := labels.Set{apiv1.LabelMetadataName: namespace}
+	if ns, err := n.store.GetNamespace(namespace); err == nil {
+		for k, v := range ns.Labels {
+			nsLabels[k] = v
+		}
+	}
+
+	return n.cfg.WatchNamespaceSelector.Matches(nsLabels)
+}
+
+// filterIngressesByNamespace returns the Ingresses in namespaces matching the
+// WatchNamespaceSelector, and findings for the out of scope Ingresses
+// defining hosts also served by in scope Ingresses, which the controller
+// silently ignores
+func (n *NGINXController) filterIngressesByNamespace(ingresses []*Ingress) ([]*Ingress, []Finding) {
+	findings := []Finding{}
+	if n.cfg.WatchNamespaceSelector == nil || n.cfg.WatchNamespaceSelector.Empty() {
+		return ingresses, findings
+	}
+
+	inScope := make([]*Ingress, 0, len(ingresses))
+	outOfScope := []*Ingress{}
+	hosts := map[string]string{}
+	for _, ing := range ingresses {
+		if !n.namespaceInScope(ing.Namespace) {
+			outOfScope = append(outOfScope, ing)
+			continue
+		}
+
+		inScope = append(inScope, ing)
+		for _, rule := range ing.Spec.Rules {
+			if _, ok := hosts[rule.Host]; !ok && rule.Host != "" {
+				hosts[rule.Host] = k8s.MetaNamespaceKey(ing)
+			}
+		}
+	}
+
+	for _, ing := range outOfScope {
+		for _, rule := range ing.Spec.Rules {
+			owner, ok := hosts[rule.Host]
+			if !ok {
+				continue
+			}
+			findings = append(findings, Finding{
+				Rule:     "namespace-out-of-scope",
+				Severity: SeverityWarning,
+				Ingress:  k8s.MetaNamespaceKey(ing),
+				Host:     rule.Host,
+				Message: fmt.Sprintf("namespace %v does not match the namespace selector %q, the rules of this Ingress are ignored and the host is only served by Ingress %v",
+					ing.Namespace, n.cfg.WatchNamespaceSelector.String(), owner),
+			})
+		}
+	}
+
+	return inScope, findings
+}
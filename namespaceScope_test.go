@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const namespaceScopeManifests = `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-a
+  labels:
+    ingress: enabled
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: team-a
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: team-b
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+  - host: other.example.com
+`
+
+func TestFilterIngressesByNamespace(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		inScope  int
+		findings int
+	}{
+		{
+			name:    "no selector",
+			inScope: 2,
+		},
+		{
+			name:     "namespace labels",
+			selector: "ingress=enabled",
+			inScope:  1,
+			findings: 1,
+		},
+		{
+			name:     "namespace name of namespaces not in the store",
+			selector: "kubernetes.io/metadata.name in (team-a, team-b)",
+			inScope:  2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n := newTestController(t, namespaceScopeManifests)
+			if tc.selector != "" {
+				selector, err := labels.Parse(tc.selector)
+				if err != nil {
+					t.Fatal(err)
+				}
+				n.cfg.WatchNamespaceSelector = selector
+			}
+
+			inScope, findings := n.filterIngressesByNamespace(n.store.ListIngresses())
+			if len(inScope) != tc.inScope {
+				t.Errorf("expected %d Ingresses in scope, got %d", tc.inScope, len(inScope))
+			}
+			findings = findingsWithRule(findings, "namespace-out-of-scope")
+			if len(findings) != tc.findings {
+				t.Errorf("expected %d findings, got %v", tc.findings, findings)
+			}
+			for _, f := range findings {
+				if f.Ingress != "team-b/web" || f.Host != "web.example.com" {
+					t.Errorf("expected a finding for web.example.com of team-b/web, got %v", f)
+				}
+			}
+		})
+	}
+}
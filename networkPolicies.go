@@ -0,0 +1,196 @@
+package main
+
+import (
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+)
+
+// checkNetworkPolicies reports backends whose pods are isolated by a
+// NetworkPolicy that does not allow traffic from the ingress controller pods.
+// The pods of a Service are identified using the Service selector.
+func (n *NGINXController) checkNetworkPolicies(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	if len(n.cfg.ControllerPodLabels) == 0 {
+		return findings
+	}
+
+	controllerPod := labels.Set(n.cfg.ControllerPodLabels)
+	controllerNs := labels.Set{apiv1.LabelMetadataName: n.cfg.Namespace}
+	if ns, err := n.store.GetNamespace(n.cfg.Namespace); err == nil {
+		for k, v := range ns.Labels {
+			controllerNs[k] = v
+		}
+	}
+
+	type result struct {
+		blocked bool
+		policy  string
+	}
+	checked := map[string]result{}
+
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			svc := loc.Service
+			if loc.IsDefBackend || svc == nil || len(svc.Spec.Selector) == 0 {
+				continue
+			}
+
+			key := svc.Namespace + "/" + svc.Name + ":" + loc.Port.String()
+			r, ok := checked[key]
+			if !ok {
+				r.policy, r.blocked = n.isBlockedByNetworkPolicy(svc, loc.Port, controllerNs, controllerPod)
+				checked[key] = r
+			}
+			if !r.blocked {
+				continue
+			}
+
+			findings = append(findings, newLocationFinding("networkpolicy-blocked", SeverityError, server, loc,
+				"NetworkPolicy %v/%v isolates the pods of Service %v/%v and does not allow traffic from the ingress controller on port %v; requests to location %q will time out",
+				svc.Namespace, r.policy, svc.Namespace, svc.Name, loc.Port.String(), loc.Path))
+		}
+	}
+
+	return findings
+}
+
+// isBlockedByNetworkPolicy returns true, and the name of one of the isolating
+// policies, when the pods selected by the service are isolated for ingress
+// traffic and no policy allows the controller pods to reach the service port
+func (n *NGINXController) isBlockedByNetworkPolicy(svc *apiv1.Service, port intstr.IntOrString,
+	controllerNs, controllerPod labels.Set,
+) (string, bool) {
+	podLabels := labels.Set(svc.Spec.Selector)
+	targetPort := serviceTargetPort(svc, port)
+
+	isolatedBy := ""
+	for _, np := range n.store.ListNetworkPolicies(svc.Namespace) {
+		if !policyAppliesToIngress(np) {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(&np.Spec.PodSelector)
+		if err != nil {
+			klog.Warningf("Invalid pod selector in NetworkPolicy %v/%v: %v", np.Namespace, np.Name, err)
+			continue
+		}
+		if !selector.Matches(podLabels) {
+			continue
+		}
+
+		isolatedBy = np.Name
+		for i := range np.Spec.Ingress {
+			rule := np.Spec.Ingress[i]
+			if ruleAllows(rule, svc.Namespace, controllerNs, controllerPod, targetPort) {
+				return "", false
+			}
+		}
+	}
+
+	return isolatedBy, isolatedBy != ""
+}
+
+func policyAppliesToIngress(np *networking.NetworkPolicy) bool {
+	if len(np.Spec.PolicyTypes) == 0 {
+		// policies without types always apply to ingress traffic
+		return true
+	}
+	for _, t := range np.Spec.PolicyTypes {
+		if t == networking.PolicyTypeIngress {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleAllows returns true if the rule allows traffic from the controller pods
+// to the target port. Peers and ports that cannot be evaluated without the
+// pod IPs or container ports are considered as allowed.
+func ruleAllows(rule networking.NetworkPolicyIngressRule, policyNs string,
+	controllerNs, controllerPod labels.Set, targetPort intstr.IntOrString,
+) bool {
+	if !portAllowed(rule.Ports, targetPort) {
+		return false
+	}
+
+	if len(rule.From) == 0 {
+		return true
+	}
+
+	for _, peer := range rule.From {
+		if peer.IPBlock != nil {
+			return true
+		}
+
+		if peer.NamespaceSelector == nil {
+			// the peer only selects pods from the policy namespace
+			if controllerNs[apiv1.LabelMetadataName] != policyNs {
+				continue
+			}
+		} else {
+			nsSelector, err := metav1.LabelSelectorAsSelector(peer.NamespaceSelector)
+			if err != nil || !nsSelector.Matches(controllerNs) {
+				continue
+			}
+		}
+
+		if peer.PodSelector == nil {
+			return true
+		}
+		podSelector, err := metav1.LabelSelectorAsSelector(peer.PodSelector)
+		if err == nil && podSelector.Matches(controllerPod) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func portAllowed(ports []networking.NetworkPolicyPort, targetPort intstr.IntOrString) bool {
+	if len(ports) == 0 {
+		return true
+	}
+
+	for _, p := range ports {
+		if p.Protocol != nil && *p.Protocol != apiv1.ProtocolTCP {
+			continue
+		}
+		if p.Port == nil || p.Port.Type != targetPort.Type {
+			return true
+		}
+		if p.Port.Type == intstr.String {
+			if p.Port.StrVal == targetPort.StrVal {
+				return true
+			}
+			continue
+		}
+
+		end := p.Port.IntVal
+		if p.EndPort != nil {
+			end = *p.EndPort
+		}
+		if targetPort.IntVal >= p.Port.IntVal && targetPort.IntVal <= end {
+			return true
+		}
+	}
+
+	return false
+}
+
+// serviceTargetPort returns the port of the pods the service port references
+func serviceTargetPort(svc *apiv1.Service, port intstr.IntOrString) intstr.IntOrString {
+	for _, sp := range svc.Spec.Ports {
+		if (port.Type == intstr.Int && sp.Port == port.IntVal) ||
+			(port.Type == intstr.String && sp.Name == port.StrVal) {
+			if sp.TargetPort.Type == intstr.Int && sp.TargetPort.IntVal == 0 {
+				return intstr.FromInt32(sp.Port)
+			}
+			return sp.TargetPort
+		}
+	}
+
+	return port
+}
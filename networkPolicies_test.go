@@ -0,0 +1,125 @@
+package main
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const networkPolicyManifests = `
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: default
+spec:
+  selector:
+    app: web
+  ports:
+  - name: http
+    port: 80
+    protocol: TCP
+    targetPort: 8080
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`
+
+func TestCheckNetworkPolicies(t *testing.T) {
+	tcp := apiv1.ProtocolTCP
+	port := func(p int) *intstr.IntOrString {
+		v := intstr.FromInt32(int32(p))
+		return &v
+	}
+	isolateWeb := func(rules ...networking.NetworkPolicyIngressRule) *networking.NetworkPolicy {
+		return &networking.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "isolate", Namespace: "default"},
+			Spec: networking.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				Ingress:     rules,
+			},
+		}
+	}
+	fromController := networking.NetworkPolicyPeer{
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{apiv1.LabelMetadataName: "ingress-nginx"}},
+		PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app.kubernetes.io/name": "ingress-nginx"}},
+	}
+
+	tests := []struct {
+		name    string
+		policy  *networking.NetworkPolicy
+		blocked bool
+	}{
+		{
+			name: "no policy",
+		},
+		{
+			name:    "deny all",
+			policy:  isolateWeb(),
+			blocked: true,
+		},
+		{
+			name:   "allow the controller",
+			policy: isolateWeb(networking.NetworkPolicyIngressRule{From: []networking.NetworkPolicyPeer{fromController}}),
+		},
+		{
+			name: "allow the controller on the target port",
+			policy: isolateWeb(networking.NetworkPolicyIngressRule{
+				From:  []networking.NetworkPolicyPeer{fromController},
+				Ports: []networking.NetworkPolicyPort{{Protocol: &tcp, Port: port(8080)}},
+			}),
+		},
+		{
+			name: "allow the controller on another port",
+			policy: isolateWeb(networking.NetworkPolicyIngressRule{
+				From:  []networking.NetworkPolicyPeer{fromController},
+				Ports: []networking.NetworkPolicyPort{{Protocol: &tcp, Port: port(9090)}},
+			}),
+			blocked: true,
+		},
+		{
+			name: "allow pods of the same namespace",
+			policy: isolateWeb(networking.NetworkPolicyIngressRule{
+				From: []networking.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}},
+			}),
+			blocked: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, networkPolicyManifests)
+			n.cfg.Namespace = "ingress-nginx"
+			n.cfg.ControllerPodLabels = map[string]string{"app.kubernetes.io/name": "ingress-nginx"}
+			if tc.policy != nil {
+				if err := n.store.(*memoryStore).Add(tc.policy); err != nil {
+					t.Fatalf("unexpected error adding the NetworkPolicy: %v", err)
+				}
+			}
+
+			findings := findingsWithRule(n.checkNetworkPolicies(ingresses, cfg), "networkpolicy-blocked")
+			if tc.blocked != (len(findings) == 1) {
+				t.Errorf("expected blocked to be %v, got %v", tc.blocked, findings)
+			}
+		})
+	}
+}
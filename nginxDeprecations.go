@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// nginxRemovedDirective is a directive nginx no longer supports from a
+// version on
+type nginxRemovedDirective struct {
+	directive string
+	// annotation is the annotation generating the directive, if any, and
+	// annotated tells if a location uses it
+	annotation string
+	annotated  func(loc *Location) bool
+	removed    nginxVersion
+	// fatal is true when nginx fails to load the configuration using the
+	// directive, instead of ignoring it with a warning
+	fatal  bool
+	advice string
+}
+
+// nginxRemovedDirectives are the directives removed from nginx which the
+// annotations or the snippets can still use
+var nginxRemovedDirectives = []nginxRemovedDirective{
+	{directive: "http2_push_preload", removed: nginxVersion{Major: 1, Minor: 25, Patch: 1},
+		annotation: "http2-push-preload", annotated: func(loc *Location) bool { return loc.HTTP2PushPreload },
+		advice: "HTTP/2 server push was removed, send 103 Early Hints from the backend instead"},
+	{directive: "http2_push", removed: nginxVersion{Major: 1, Minor: 25, Patch: 1},
+		advice: "HTTP/2 server push was removed, send 103 Early Hints from the backend instead"},
+	{directive: "http2_max_concurrent_pushes", removed: nginxVersion{Major: 1, Minor: 25, Patch: 1},
+		advice: "HTTP/2 server push was removed"},
+	{directive: "ssl", removed: nginxVersion{Major: 1, Minor: 25, Patch: 1}, fatal: true,
+		advice: "use the ssl parameter of the listen directive"},
+	{directive: "http2_max_field_size", removed: nginxVersion{Major: 1, Minor: 19, Patch: 7},
+		advice: "use large_client_header_buffers"},
+	{directive: "http2_max_header_size", removed: nginxVersion{Major: 1, Minor: 19, Patch: 7},
+		advice: "use large_client_header_buffers"},
+	{directive: "http2_max_requests", removed: nginxVersion{Major: 1, Minor: 19, Patch: 7},
+		advice: "use keepalive_requests"},
+	{directive: "http2_idle_timeout", removed: nginxVersion{Major: 1, Minor: 19, Patch: 7},
+		advice: "use keepalive_timeout"},
+	{directive: "http2_recv_timeout", removed: nginxVersion{Major: 1, Minor: 19, Patch: 7},
+		advice: "use client_header_timeout"},
+}
+
+// directiveRegex returns the regular expression matching the uses of the
+// directive in a snippet
+func (d nginxRemovedDirective) directiveRegex() *regexp.Regexp {
+	return regexp.MustCompile(`(?m)(?:^|[;{}])\s*` + regexp.QuoteMeta(d.directive) + `\s`)
+}
+
+// problem describes what nginx does with the directive
+func (d nginxRemovedDirective) problem(version nginxVersion) (Severity, string) {
+	if d.fatal {
+		return SeverityError, fmt.Sprintf("nginx %v no longer supports %v since %v and fails to load the configuration; %v",
+			version, d.directive, d.removed, d.advice)
+	}
+	return SeverityWarning, fmt.Sprintf("nginx %v ignores %v, which is obsolete since %v; %v",
+		version, d.directive, d.removed, d.advice)
+}
+
+// checkNginxDeprecations reports the annotations and the snippets using
+// directives the targeted nginx version no longer supports
+func (n *NGINXController) checkNginxDeprecations(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	version, ok := n.targetNginxVersion()
+	if !ok {
+		return findings
+	}
+	global := n.store.GetBackendConfiguration()
+
+	for _, d := range nginxRemovedDirectives {
+		if !version.AtLeast(d.removed) {
+			continue
+		}
+		severity, problem := d.problem(version)
+		re := d.directiveRegex()
+
+		report := func(f Finding, snippet, where string) {
+			if re.MatchString(snippet) {
+				f.Rule = "nginx-directive-removed"
+				f.Severity = severity
+				f.Message = fmt.Sprintf("%v uses %v: %v", where, d.directive, problem)
+				findings = append(findings, f)
+			}
+		}
+
+		for _, s := range []struct {
+			key     string
+			snippet string
+		}{
+			{"http-snippet", global.HTTPSnippet},
+			{"server-snippet", global.ServerSnippet},
+			{"location-snippet", global.LocationSnippet},
+		} {
+			report(Finding{}, s.snippet, fmt.Sprintf("%v of ConfigMap %v", s.key, n.cfg.ConfigMapName))
+		}
+		for _, server := range cfg.Servers {
+			report(Finding{Ingress: serverIngress(server), Host: server.Hostname},
+				server.ServerSnippet, "the server-snippet of "+server.Hostname)
+
+			reported := map[*Ingress]bool{}
+			for _, loc := range server.Locations {
+				report(newLocationFinding("", "", server, loc, ""),
+					loc.ConfigurationSnippet, fmt.Sprintf("the configuration-snippet of location %q", loc.Path))
+
+				if d.annotated != nil && d.annotated(loc) && !reported[loc.Ingress] {
+					reported[loc.Ingress] = true
+					findings = append(findings, newLocationFinding("nginx-directive-removed", severity, server, loc,
+						"annotation %v generates %v: %v", d.annotation, d.directive, problem))
+				}
+			}
+		}
+	}
+
+	return findings
+}
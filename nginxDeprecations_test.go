@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDirectiveRegex(t *testing.T) {
+	re := nginxRemovedDirective{directive: "http2_push"}.directiveRegex()
+	for snippet, expected := range map[string]bool{
+		"http2_push /style.css;":               true,
+		"  http2_push /style.css;":             true,
+		"add_header X-A 1; http2_push /a.css;": true,
+		"location /a { http2_push /a.css; }":   true,
+		"http2_push_preload on;":               false,
+		"# see http2_push /a.css":              false,
+		"add_header X-Push http2_push;":        false,
+	} {
+		if got := re.MatchString(snippet); got != expected {
+			t.Errorf("expected %v for %q, got %v", expected, snippet, got)
+		}
+	}
+}
+
+// nginxDeprecationsManifests is completed with the data of the ConfigMap and
+// the annotations of the Ingress
+const nginxDeprecationsManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+data:
+  allow-snippet-annotations: "true"
+  annotations-risk-level: Critical
+%v
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+  annotations:
+%v
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`
+
+func TestCheckNginxDeprecations(t *testing.T) {
+	testCases := map[string]struct {
+		version     string
+		data        string
+		annotations string
+		expected    []string
+	}{
+		"no nginx version": {
+			annotations: `    nginx.ingress.kubernetes.io/http2-push-preload: "true"`,
+		},
+		"annotation before the removal": {
+			version:     "1.25.0",
+			annotations: `    nginx.ingress.kubernetes.io/http2-push-preload: "true"`,
+		},
+		"annotation": {
+			version:     "1.25.1",
+			annotations: `    nginx.ingress.kubernetes.io/http2-push-preload: "true"`,
+			expected: []string{
+				"warning default/web: annotation http2-push-preload generates http2_push_preload: nginx 1.25.1 ignores http2_push_preload, which is obsolete since 1.25.1; " +
+					"HTTP/2 server push was removed, send 103 Early Hints from the backend instead",
+			},
+		},
+		"configuration snippet": {
+			version: "1.21.6",
+			annotations: `    nginx.ingress.kubernetes.io/configuration-snippet: |
+      http2_max_requests 1000;`,
+			expected: []string{
+				`warning default/web: the configuration-snippet of location "/" uses http2_max_requests: nginx 1.21.6 ignores http2_max_requests, which is obsolete since 1.19.7; use keepalive_requests`,
+			},
+		},
+		"server snippet": {
+			version: "1.25.3",
+			annotations: `    nginx.ingress.kubernetes.io/server-snippet: |
+      ssl on;`,
+			expected: []string{
+				"error default/web: the server-snippet of web.example.com uses ssl: nginx 1.25.3 no longer supports ssl since 1.25.1 and fails to load the configuration; " +
+					"use the ssl parameter of the listen directive",
+			},
+		},
+		"ConfigMap snippet": {
+			version: "1.25.3",
+			data: `  http-snippet: |
+    http2_max_concurrent_pushes 10;`,
+			annotations: `    nginx.ingress.kubernetes.io/ssl-redirect: "false"`,
+			expected: []string{
+				"warning : http-snippet of ConfigMap ingress-nginx/ingress-nginx-controller uses http2_max_concurrent_pushes: " +
+					"nginx 1.25.3 ignores http2_max_concurrent_pushes, which is obsolete since 1.25.1; HTTP/2 server push was removed",
+			},
+		},
+		"supported directives": {
+			version: "1.25.3",
+			annotations: `    nginx.ingress.kubernetes.io/configuration-snippet: |
+      keepalive_requests 1000;
+      add_header Link "</style.css>; rel=preload";`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, fmt.Sprintf(nginxDeprecationsManifests, tc.data, tc.annotations))
+			n.cfg.NginxVersion = tc.version
+			findings := []string{}
+			for _, f := range n.checkNginxDeprecations(ingresses, cfg) {
+				if f.Rule != "nginx-directive-removed" {
+					t.Errorf("unexpected rule %v", f.Rule)
+				}
+				findings = append(findings, fmt.Sprintf("%v %v: %v", f.Severity, f.Ingress, f.Message))
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(findings, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, findings)
+			}
+		})
+	}
+}
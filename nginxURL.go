@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// nginxVariableRegex matches the nginx variables of a value
+var nginxVariableRegex = regexp.MustCompile(`\$\{?[A-Za-z0-9_]+\}?`)
+
+// uriVariables are the variables of the URI of the request
+var uriVariables = []string{"request_uri", "uri", "args", "is_args", "query_string"}
+
+// nginxURL is an URL of the configuration, like a mirror-target or a
+// permanent-redirect, which can contain nginx variables
+type nginxURL struct {
+	url *url.URL
+	// dynamicHost is true when the host contains nginx variables and is
+	// only known at request time
+	dynamicHost bool
+}
+
+// parseNginxURL parses an http or https URL containing nginx variables such
+// as $request_uri
+func parseNginxURL(s string) (*nginxURL, error) {
+	u, err := url.Parse(nginxVariableRegex.ReplaceAllString(s, ""))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("scheme must be http or https")
+	}
+
+	// the variables of the URI often follow the host directly, e.g.
+	// https://test.example.com$request_uri
+	authority := strings.TrimPrefix(s, u.Scheme+"://")
+	if i := strings.IndexAny(authority, "/?"); i >= 0 {
+		authority = authority[:i]
+	}
+	dynamic := false
+	for _, variable := range nginxVariableRegex.FindAllString(authority, -1) {
+		name := strings.Trim(variable, "${}")
+		if !containsString(uriVariables, name) {
+			dynamic = true
+		}
+	}
+	if u.Host == "" && !dynamic {
+		return nil, fmt.Errorf("host is missing")
+	}
+	return &nginxURL{url: u, dynamicHost: dynamic}, nil
+}
+
+// nginxURLPath returns the path of the requests sent to an URL of a location:
+// the path of the URL, followed by the path of the location when the URL
+// forwards the URI of the request
+func nginxURLPath(s string, u *url.URL, loc *Location) string {
+	if strings.Contains(s, "$request_uri") || strings.Contains(s, "$uri") {
+		return strings.TrimSuffix(u.Path, "/") + loc.Path
+	}
+	if u.Path == "" {
+		return "/"
+	}
+	return u.Path
+}
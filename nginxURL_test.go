@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseNginxURL(t *testing.T) {
+	testCases := map[string]struct {
+		host        string
+		dynamicHost bool
+		err         string
+	}{
+		"https://test.example.com$request_uri":                {host: "test.example.com"},
+		"http://shadow.default.svc.cluster.local:8080/v1$uri": {host: "shadow.default.svc.cluster.local"},
+		"https://${request_uri}":                              {err: "host is missing"},
+		"https://$host$request_uri":                           {dynamicHost: true},
+		"https://shadow-$namespace.example.com/":              {dynamicHost: true},
+		"ftp://test.example.com":                              {err: "scheme must be http or https"},
+		"test.example.com$request_uri":                        {err: "scheme must be http or https"},
+		"https://test.example.com/%zz":                        {err: "invalid URL escape"},
+	}
+
+	for target, tc := range testCases {
+		t.Run(target, func(t *testing.T) {
+			got, err := parseNginxURL(target)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Errorf("expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.dynamicHost != tc.dynamicHost || (!tc.dynamicHost && got.url.Hostname() != tc.host) {
+				t.Errorf("expected the host %q (dynamic %v), got %q (dynamic %v)", tc.host, tc.dynamicHost, got.url.Hostname(), got.dynamicHost)
+			}
+		})
+	}
+}
+
+func TestNginxURLPath(t *testing.T) {
+	loc := &Location{Path: "/api/"}
+	for target, expected := range map[string]string{
+		"https://test.example.com$request_uri":     "/api/",
+		"https://test.example.com/shadow/$uri":     "/shadow/api/",
+		"https://test.example.com":                 "/",
+		"https://test.example.com/shadow?mirror=1": "/shadow",
+	} {
+		u, err := url.Parse(nginxVariableRegex.ReplaceAllString(target, ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := nginxURLPath(target, u, loc); got != expected {
+			t.Errorf("expected %q for %v, got %q", expected, target, got)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseNginxSize parses a size using the nginx syntax (1024, 8k, 1m, 1g)
+// and returns the number of bytes
+func parseNginxSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	multiplier := int64(1)
+	switch s[len(s)-1] {
+	case 'k', 'K':
+		multiplier = 1024
+	case 'm', 'M':
+		multiplier = 1024 * 1024
+	case 'g', 'G':
+		multiplier = 1024 * 1024 * 1024
+	}
+	if multiplier != 1 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	return n * multiplier, nil
+}
+
+// nginxTimeUnits are the units of the nginx time syntax
+var nginxTimeUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"M":  30 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+// parseNginxTime parses a time using the nginx syntax (30, 30s, 1m, 1h 30m,
+// 500ms). Values without unit are seconds.
+func parseNginxTime(s string) (time.Duration, error) {
+	value := strings.ReplaceAll(strings.TrimSpace(s), " ", "")
+	if value == "" {
+		return 0, fmt.Errorf("empty time")
+	}
+
+	total := time.Duration(0)
+	for value != "" {
+		i := 0
+		for i < len(value) && value[i] >= '0' && value[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		n, err := strconv.ParseInt(value[:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		value = value[i:]
+
+		j := 0
+		for j < len(value) && (value[j] < '0' || value[j] > '9') {
+			j++
+		}
+		unit := time.Second
+		if j > 0 {
+			var ok bool
+			if unit, ok = nginxTimeUnits[value[:j]]; !ok {
+				return 0, fmt.Errorf("invalid time %q, unknown unit %q", s, value[:j])
+			}
+		}
+		value = value[j:]
+		total += time.Duration(n) * unit
+	}
+
+	return total, nil
+}
+
+// parseNginxBuffers parses the number and size of buffers (e.g. 4 8k), as
+// used by large_client_header_buffers and proxy_buffers
+func parseNginxBuffers(s string) (int, int64, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("invalid buffers %q, expected <number> <size>", s)
+	}
+
+	number, err := strconv.Atoi(fields[0])
+	if err != nil || number <= 0 {
+		return 0, 0, fmt.Errorf("invalid number of buffers in %q", s)
+	}
+
+	size, err := parseNginxSize(fields[1])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return number, size, nil
+}
+
+// formatNginxSize returns the size using the largest nginx unit that
+// represents it exactly
+func formatNginxSize(size int64) string {
+	switch {
+	case size != 0 && size%(1024*1024) == 0:
+		return fmt.Sprintf("%dm", size/(1024*1024))
+	case size != 0 && size%1024 == 0:
+		return fmt.Sprintf("%dk", size/1024)
+	default:
+		return strconv.FormatInt(size, 10)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNginxSize(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int64
+		err      bool
+	}{
+		{value: "1024", expected: 1024},
+		{value: " 8k ", expected: 8 * 1024},
+		{value: "1M", expected: 1024 * 1024},
+		{value: "2g", expected: 2 * 1024 * 1024 * 1024},
+		{value: "", err: true},
+		{value: "k", err: true},
+		{value: "-1k", err: true},
+		{value: "1.5m", err: true},
+		{value: "8kb", err: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			size, err := parseNginxSize(tc.value)
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected an error, got %v", size)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if size != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, size)
+			}
+		})
+	}
+}
+
+func TestParseNginxTime(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		err      bool
+	}{
+		{value: "30", expected: 30 * time.Second},
+		{value: "30s", expected: 30 * time.Second},
+		{value: "500ms", expected: 500 * time.Millisecond},
+		{value: "1h 30m", expected: 90 * time.Minute},
+		{value: "1d12h", expected: 36 * time.Hour},
+		{value: "1M", expected: 30 * 24 * time.Hour},
+		{value: "", err: true},
+		{value: "s", err: true},
+		{value: "10x", err: true},
+		{value: "-5s", err: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			d, err := parseNginxTime(tc.value)
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected an error, got %v", d)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if d != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, d)
+			}
+		})
+	}
+}
+
+func TestParseNginxBuffers(t *testing.T) {
+	tests := []struct {
+		value  string
+		number int
+		size   int64
+		err    bool
+	}{
+		{value: "4 8k", number: 4, size: 8 * 1024},
+		{value: " 2  1m ", number: 2, size: 1024 * 1024},
+		{value: "8k", err: true},
+		{value: "0 8k", err: true},
+		{value: "4 8x", err: true},
+		{value: "4 8k 1", err: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			number, size, err := parseNginxBuffers(tc.value)
+			if tc.err != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if number != tc.number || size != tc.size {
+				t.Errorf("expected %v %v, got %v %v", tc.number, tc.size, number, size)
+			}
+		})
+	}
+}
+
+func TestFormatNginxSize(t *testing.T) {
+	tests := map[int64]string{
+		0:               "0",
+		1000:            "1000",
+		8 * 1024:        "8k",
+		1536 * 1024:     "1536k",
+		2 * 1024 * 1024: "2m",
+	}
+
+	for size, expected := range tests {
+		if got := formatNginxSize(size); got != expected {
+			t.Errorf("%v: expected %q, got %q", size, expected, got)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// nginxVersion is the version of the nginx binary the configuration targets
+type nginxVersion struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+func (v nginxVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// AtLeast returns true if v is equal or newer than other
+func (v nginxVersion) AtLeast(other nginxVersion) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor > other.Minor
+	}
+	return v.Patch >= other.Patch
+}
+
+// parseNginxVersion parses versions like 1.25.3 or nginx/1.25.3
+func parseNginxVersion(s string) (nginxVersion, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "nginx/")
+
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nginxVersion{}, fmt.Errorf("invalid nginx version %q", s)
+	}
+
+	nums := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nginxVersion{}, fmt.Errorf("invalid nginx version %q", s)
+		}
+		nums[i] = n
+	}
+
+	return nginxVersion{Major: nums[0], Minor: nums[1], Patch: nums[2]}, nil
+}
+
+// targetNginxVersion returns the nginx version the configuration is validated
+// against. It returns false when no version is configured.
+func (n *NGINXController) targetNginxVersion() (nginxVersion, bool) {
+	if n.cfg.NginxVersion == "" {
+		return nginxVersion{}, false
+	}
+
+	v, err := parseNginxVersion(n.cfg.NginxVersion)
+	if err != nil {
+		return nginxVersion{}, false
+	}
+
+	return v, true
+}
+
+// targetControllerVersion returns the version of the ingress controller the
+// configuration is validated against, like v1.12.1 or controller-v1.12.1. It
+// returns false when no version is configured.
+func (n *NGINXController) targetControllerVersion() (nginxVersion, bool) {
+	if n.cfg.ControllerVersion == "" {
+		return nginxVersion{}, false
+	}
+
+	s := strings.TrimPrefix(strings.TrimSpace(n.cfg.ControllerVersion), "controller-")
+	v, err := parseNginxVersion(strings.TrimPrefix(s, "v"))
+	if err != nil {
+		return nginxVersion{}, false
+	}
+
+	return v, true
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// notifySinksEnv is the environment variable of the notification sinks
+	// used when --notify-file is not set, separated by spaces or new lines
+	notifySinksEnv = "NGINX_CONFIG_VALIDATOR_NOTIFY"
+	// notificationTimeout limits the time spent posting one notification
+	notificationTimeout = 10 * time.Second
+	// maxNotificationFindings limits the findings listed in a message
+	maxNotificationFindings = 20
+)
+
+// notification summarizes a failed validation of the cluster state
+type notification struct {
+	Title     string    `json:"title"`
+	Time      time.Time `json:"time"`
+	Findings  []Finding `json:"findings"`
+	ReportURL string    `json:"reportURL,omitempty"`
+}
+
+// lines returns one line per finding, at most maxNotificationFindings
+func (m notification) lines() []string {
+	lines := []string{}
+	for i, f := range m.Findings {
+		if i == maxNotificationFindings {
+			lines = append(lines, fmt.Sprintf("... and %d more", len(m.Findings)-i))
+			break
+		}
+		subject := f.Ingress
+		if f.Host != "" {
+			subject = fmt.Sprintf("%v (%v)", subject, f.Host)
+		}
+		lines = append(lines, fmt.Sprintf("%v: %v: %v", subject, f.Rule, f.Message))
+	}
+	return lines
+}
+
+// notifier posts notifications to a chat or HTTP endpoint
+type notifier interface {
+	// Notify sends the notification
+	Notify(ctx context.Context, m notification) error
+	// String returns the kind and host of the endpoint, without secrets
+	String() string
+}
+
+// newNotifier returns the notifier described by kind=url, kind being slack,
+// teams or http
+func newNotifier(spec string) (notifier, error) {
+	kind, rawURL, ok := strings.Cut(spec, "=")
+	if !ok {
+		return nil, fmt.Errorf("notification sink %q must use the format <kind>=<url>", spec)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("notification sink %v: invalid URL", kind)
+	}
+
+	sink := webhookSink{url: rawURL, host: u.Host}
+	switch kind {
+	case "slack":
+		return &slackNotifier{sink}, nil
+	case "teams":
+		return &teamsNotifier{sink}, nil
+	case "http":
+		return &httpNotifier{sink}, nil
+	default:
+		return nil, fmt.Errorf("unknown notification sink %q, expected slack, teams or http", kind)
+	}
+}
+
+// webhookSink posts JSON payloads to an incoming webhook URL
+type webhookSink struct {
+	url  string
+	host string
+}
+
+func (s webhookSink) post(ctx context.Context, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%v answered %v", s.host, resp.Status)
+	}
+	return nil
+}
+
+// slackNotifier posts to a Slack incoming webhook
+type slackNotifier struct{ webhookSink }
+
+func (s *slackNotifier) String() string { return "slack " + s.host }
+
+func (s *slackNotifier) Notify(ctx context.Context, m notification) error {
+	text := fmt.Sprintf("*%v*\n```\n%v\n```", m.Title, strings.Join(m.lines(), "\n"))
+	if m.ReportURL != "" {
+		text += fmt.Sprintf("\n<%v|View the report>", m.ReportURL)
+	}
+	return s.post(ctx, map[string]string{"text": text})
+}
+
+// teamsNotifier posts a MessageCard to a Microsoft Teams incoming webhook
+type teamsNotifier struct{ webhookSink }
+
+func (s *teamsNotifier) String() string { return "teams " + s.host }
+
+func (s *teamsNotifier) Notify(ctx context.Context, m notification) error {
+	card := map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    m.Title,
+		"title":      m.Title,
+		"themeColor": "D70000",
+		"text":       strings.Join(m.lines(), "<br>"),
+	}
+	if m.ReportURL != "" {
+		card["potentialAction"] = []map[string]interface{}{{
+			"@type":   "OpenUri",
+			"name":    "View the report",
+			"targets": []map[string]string{{"os": "default", "uri": m.ReportURL}},
+		}}
+	}
+	return s.post(ctx, card)
+}
+
+// httpNotifier posts the notification as JSON to any endpoint
+type httpNotifier struct{ webhookSink }
+
+func (s *httpNotifier) String() string { return "http " + s.host }
+
+func (s *httpNotifier) Notify(ctx context.Context, m notification) error {
+	return s.post(ctx, m)
+}
+
+// notifications sends the failures of the validation of the cluster state to
+// the configured sinks. The same failures are only notified once.
+type notifications struct {
+	sinks     []notifier
+	reportURL string
+
+	lock sync.Mutex
+	last string
+	// lastDrift is the fingerprint of the last notified configuration drift
+	lastDrift string
+}
+
+// loadNotifySinks reads the sinks of NotifySinksFile, or of the notifySinksEnv
+// environment variable when it is not set. The URLs of the incoming webhooks
+// are secrets, they must not be visible in the command line.
+func loadNotifySinks(cfg *NginxConfiguration) error {
+	data := os.Getenv(notifySinksEnv)
+	if cfg.NotifySinksFile != "" {
+		raw, err := os.ReadFile(cfg.NotifySinksFile)
+		if err != nil {
+			return fmt.Errorf("error reading notification sinks: %w", err)
+		}
+		data = string(raw)
+	}
+
+	cfg.NotifySinks = nil
+	for _, line := range strings.Split(data, "\n") {
+		for _, spec := range strings.Fields(line) {
+			if strings.HasPrefix(spec, "#") {
+				break
+			}
+			cfg.NotifySinks = append(cfg.NotifySinks, spec)
+		}
+	}
+	return nil
+}
+
+// newNotifications returns the notifications configured with NotifySinks,
+// NotifySinksFile or the environment, or nil when there are no sinks
+func newNotifications(cfg *NginxConfiguration) (*notifications, error) {
+	if err := loadNotifySinks(cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.NotifySinks) == 0 {
+		return nil, nil
+	}
+
+	ns := &notifications{reportURL: cfg.NotifyReportURL}
+	for _, spec := range cfg.NotifySinks {
+		sink, err := newNotifier(spec)
+		if err != nil {
+			return nil, err
+		}
+		ns.sinks = append(ns.sinks, sink)
+	}
+	return ns, nil
+}
+
+// validationFailed notifies the error findings of a validation of the
+// cluster state, unless they were already notified. A successful validation
+// resets the state, so a new failure is notified again.
+func (ns *notifications) validationFailed(findings []Finding) {
+	if ns == nil {
+		return
+	}
+
+	failures := []Finding{}
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			failures = append(failures, f)
+		}
+	}
+
+	if !ns.changed(&ns.last, failures) || len(failures) == 0 {
+		return
+	}
+	ns.send(notification{
+		Title:     fmt.Sprintf("nginx-config-validator: the Ingresses of the cluster fail validation (%d errors)", len(failures)),
+		Time:      time.Now().UTC(),
+		Findings:  failures,
+		ReportURL: ns.reportURL,
+	})
+}
+
+// configDrifted notifies the differences between the configuration nginx
+// serves and the desired one, unless they were already notified
+func (ns *notifications) configDrifted(findings []Finding) {
+	if ns == nil {
+		return
+	}
+	if !ns.changed(&ns.lastDrift, findings) || len(findings) == 0 {
+		return
+	}
+	ns.send(notification{
+		Title:     fmt.Sprintf("nginx-config-validator: the configuration served by nginx drifted from the Ingresses (%d differences)", len(findings)),
+		Time:      time.Now().UTC(),
+		Findings:  findings,
+		ReportURL: ns.reportURL,
+	})
+}
+
+// changed stores the fingerprint of the findings in last and tells if it
+// differs from the previous one
+func (ns *notifications) changed(last *string, findings []Finding) bool {
+	fingerprint := ""
+	if len(findings) > 0 {
+		raw, _ := json.Marshal(findings)
+		fingerprint = sha1Hex(raw)
+	}
+
+	ns.lock.Lock()
+	defer ns.lock.Unlock()
+	if fingerprint == *last {
+		return false
+	}
+	*last = fingerprint
+	return true
+}
+
+// send posts the notification to every sink
+func (ns *notifications) send(m notification) {
+	for _, sink := range ns.sinks {
+		ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+		if err := sink.Notify(ctx, m); err != nil {
+			klog.Warningf("Error sending notification to %v: %v", sink, err)
+		} else {
+			klog.V(2).Infof("Sent notification to %v", sink)
+		}
+		cancel()
+	}
+}
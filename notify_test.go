@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// notificationServer records the bodies of the requests it receives
+type notificationServer struct {
+	*httptest.Server
+
+	lock   sync.Mutex
+	bodies []string
+}
+
+func newNotificationServer(t *testing.T) *notificationServer {
+	s := &notificationServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.lock.Lock()
+		s.bodies = append(s.bodies, string(body))
+		s.lock.Unlock()
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *notificationServer) received() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string{}, s.bodies...)
+}
+
+func TestNewNotifier(t *testing.T) {
+	tests := map[string]string{
+		"slack=https://hooks.slack.com/services/T0/B0/secret": "slack hooks.slack.com",
+		"teams=https://example.webhook.office.com/webhook":    "teams example.webhook.office.com",
+		"http=http://alerts.internal:8080/notify":             "http alerts.internal:8080",
+		"https://hooks.slack.com/services/T0/B0/secret":       "",
+		"slack=hooks.slack.com/services":                      "",
+		"pagerduty=https://events.pagerduty.com":              "",
+	}
+
+	for spec, expected := range tests {
+		sink, err := newNotifier(spec)
+		switch {
+		case expected == "" && err == nil:
+			t.Errorf("%q: expected an error", spec)
+		case expected != "" && err != nil:
+			t.Errorf("%q: unexpected error: %v", spec, err)
+		case expected != "" && sink.String() != expected:
+			t.Errorf("%q: expected %q, got %q", spec, expected, sink.String())
+		}
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	slack, teams, endpoint := newNotificationServer(t), newNotificationServer(t), newNotificationServer(t)
+	t.Setenv(notifySinksEnv, "slack="+slack.URL+" teams="+teams.URL+"\nhttp="+endpoint.URL)
+	ns, err := newNotifications(&NginxConfiguration{NotifyReportURL: "https://reports.example.com/cluster"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failure := []Finding{
+		{Rule: "h2c-nginx-version", Severity: SeverityError, Ingress: "default/web", Host: "web.example.com", Message: "h2c requires nginx 1.13.10"},
+		{Rule: "cors-permissive", Severity: SeverityWarning, Ingress: "default/web", Message: "any origin"},
+	}
+	ns.validationFailed(failure)
+	// the same failure is notified once
+	ns.validationFailed(failure)
+
+	got := slack.received()
+	if len(got) != 1 {
+		t.Fatalf("expected one Slack notification, got %q", got)
+	}
+	message := struct{ Text string }{}
+	if err := json.Unmarshal([]byte(got[0]), &message); err != nil {
+		t.Fatalf("invalid Slack message %q: %v", got[0], err)
+	}
+	if !strings.Contains(message.Text, "default/web (web.example.com): h2c-nginx-version: h2c requires nginx 1.13.10") ||
+		!strings.Contains(message.Text, "<https://reports.example.com/cluster|View the report>") ||
+		strings.Contains(message.Text, "cors-permissive") {
+		t.Errorf("unexpected Slack message %q", message.Text)
+	}
+	if got := teams.received(); len(got) != 1 || !strings.Contains(got[0], `"@type":"MessageCard"`) {
+		t.Errorf("unexpected Teams notifications %q", got)
+	}
+	got = endpoint.received()
+	if len(got) != 1 {
+		t.Fatalf("expected one HTTP notification, got %q", got)
+	}
+	m := notification{}
+	if err := json.Unmarshal([]byte(got[0]), &m); err != nil {
+		t.Fatalf("invalid notification %q: %v", got[0], err)
+	}
+	if len(m.Findings) != 1 || m.ReportURL != "https://reports.example.com/cluster" {
+		t.Errorf("unexpected notification %+v", m)
+	}
+
+	// a successful validation resets the state
+	ns.validationFailed(nil)
+	ns.validationFailed(failure)
+	if got := endpoint.received(); len(got) != 2 {
+		t.Errorf("expected the failure to be notified again after a success, got %d notifications", len(got))
+	}
+}
+
+func TestNotificationLines(t *testing.T) {
+	m := notification{}
+	for i := 0; i < maxNotificationFindings+5; i++ {
+		m.Findings = append(m.Findings, Finding{Rule: fmt.Sprintf("rule-%d", i), Ingress: "default/web"})
+	}
+
+	lines := m.lines()
+	if len(lines) != maxNotificationFindings+1 || lines[len(lines)-1] != "... and 5 more" {
+		t.Errorf("expected the findings to be truncated, got %q", lines)
+	}
+}
+
+func TestWebhookSinkStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such webhook", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	sink := webhookSink{url: server.URL, host: "hooks.example.com"}
+	err := sink.post(context.Background(), map[string]string{"text": "test"})
+	if err == nil || !strings.Contains(err.Error(), "hooks.example.com answered 404") {
+		t.Errorf("expected the status to be reported, got %v", err)
+	}
+}
+
+func TestLoadNotifySinks(t *testing.T) {
+	t.Setenv(notifySinksEnv, "slack=https://hooks.slack.com/env")
+
+	cfg := &NginxConfiguration{}
+	if err := loadNotifySinks(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{"slack=https://hooks.slack.com/env"}; !reflect.DeepEqual(cfg.NotifySinks, expected) {
+		t.Errorf("expected the sinks of the environment %v, got %v", expected, cfg.NotifySinks)
+	}
+
+	file := filepath.Join(t.TempDir(), "sinks")
+	data := "# incoming webhooks\nslack=https://hooks.slack.com/file\n\nteams=https://example.webhook.office.com/file # ops\n"
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg = &NginxConfiguration{NotifySinksFile: file}
+	if err := loadNotifySinks(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"slack=https://hooks.slack.com/file", "teams=https://example.webhook.office.com/file"}
+	if !reflect.DeepEqual(cfg.NotifySinks, expected) {
+		t.Errorf("expected the sinks of the file %v, got %v", expected, cfg.NotifySinks)
+	}
+
+	cfg = &NginxConfiguration{NotifySinksFile: filepath.Join(t.TempDir(), "missing")}
+	if err := loadNotifySinks(cfg); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+)
+
+// otelOperationNameInvalid are the characters ending the value of
+// opentelemetry_operation_name in the generated configuration
+const otelOperationNameInvalid = "\";{}\\\n"
+
+// otelAnnotationOperationNameRegex is the operation names the ingress
+// controller accepts in the opentelemetry-operation-name annotation
+var otelAnnotationOperationNameRegex = regexp.MustCompile(`^[A-Za-z0-9_\-]*$`)
+
+// otelOperationName returns the problem of an operation name, empty when it
+// is valid. The name can contain nginx variables, e.g. HTTP $request_method.
+func otelOperationName(name string) string {
+	variables := nginxVariableRegex.FindAllString(name, -1)
+	for _, variable := range variables {
+		if strings.HasPrefix(variable, "${") && !strings.HasSuffix(variable, "}") {
+			return fmt.Sprintf("variable %v is not closed", variable)
+		}
+	}
+	// the braces of the variables are part of their names
+	text := nginxVariableRegex.ReplaceAllString(name, "")
+	if i := strings.IndexAny(text, otelOperationNameInvalid); i >= 0 {
+		return fmt.Sprintf("it contains %q, which breaks the generated configuration", text[i])
+	}
+	if strings.Count(name, "$") != len(variables) {
+		return "it contains a $ which does not start a variable"
+	}
+	return ""
+}
+
+// checkOpentelemetry validates the opentelemetry annotations of the locations
+// and reports the locations enabling it without the global configuration the
+// spans need to be exported
+func (n *NGINXController) checkOpentelemetry(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	global := n.store.GetBackendConfiguration()
+	checked := map[*Ingress]bool{}
+
+	if global.EnableOpentelemetry && global.OtlpCollectorHost == "" {
+		findings = append(findings, Finding{
+			Rule:     "otel-collector-missing",
+			Severity: SeverityWarning,
+			Message: fmt.Sprintf("enable-opentelemetry is true but otlp-collector-host is not set in ConfigMap %v; no span is exported",
+				n.cfg.ConfigMapName),
+		})
+	}
+	if problem := otelOperationName(global.OpentelemetryOperationName); problem != "" {
+		findings = append(findings, Finding{
+			Rule:     "otel-invalid",
+			Severity: SeverityError,
+			Message: fmt.Sprintf("opentelemetry-operation-name %q of ConfigMap %v is not valid: %v",
+				global.OpentelemetryOperationName, n.cfg.ConfigMapName, problem),
+		})
+	}
+
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			if loc.Ingress == nil || checked[loc.Ingress] {
+				continue
+			}
+			checked[loc.Ingress] = true
+			anns := loc.Ingress.Annotations
+
+			for _, name := range []string{"enable-opentelemetry", "opentelemetry-trust-incoming-span"} {
+				if value, ok := anns[parser.GetAnnotationWithPrefix(name)]; ok {
+					if _, err := strconv.ParseBool(value); err != nil {
+						findings = append(findings, newLocationFinding("otel-invalid", SeverityError, server, loc,
+							"%v %q is not true or false; the ingress controller ignores it", name, value))
+					}
+				}
+			}
+			operationName, operationNameSet := anns[parser.GetAnnotationWithPrefix("opentelemetry-operation-name")]
+			if operationNameSet && !otelAnnotationOperationNameRegex.MatchString(operationName) {
+				findings = append(findings, newLocationFinding("otel-invalid", SeverityError, server, loc,
+					"opentelemetry-operation-name %q is not valid: the ingress controller only accepts letters, digits, _ and - and ignores the opentelemetry annotations",
+					operationName))
+			}
+			_, trustSet := anns[parser.GetAnnotationWithPrefix("opentelemetry-trust-incoming-span")]
+
+			otel := loc.Opentelemetry
+			enabled := global.EnableOpentelemetry
+			if otel.Set {
+				enabled = otel.Enabled
+			}
+			switch {
+			case !enabled && (trustSet || operationNameSet):
+				findings = append(findings, newLocationFinding("otel-ineffective", SeverityWarning, server, loc,
+					"opentelemetry-trust-incoming-span and opentelemetry-operation-name have no effect: opentelemetry is not enabled for location %q",
+					loc.Path))
+			case enabled && !global.EnableOpentelemetry:
+				detail := "only the locations enabling it are traced, the traces of the requests crossing other locations are incomplete"
+				if global.OtlpCollectorHost == "" {
+					detail = "otlp-collector-host is not set either, so no span is exported"
+				}
+				findings = append(findings, newLocationFinding("otel-disabled-globally", SeverityWarning, server, loc,
+					"location %q enables opentelemetry but enable-opentelemetry is false in ConfigMap %v; %v",
+					loc.Path, n.cfg.ConfigMapName, detail))
+			}
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestOtelOperationName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"":                                 "",
+		"HTTP $request_method $uri":        "",
+		"${request_method}_${uri}":         "",
+		"HTTP ${request_method":            "variable ${request_method is not closed",
+		`HTTP "$uri"`:                      `it contains '"', which breaks the generated configuration`,
+		"HTTP $uri; return 200":            `it contains ';', which breaks the generated configuration`,
+		"costs 5$":                         "it contains a $ which does not start a variable",
+		"HTTP $request_method {server}":    `it contains '{', which breaks the generated configuration`,
+		"HTTP $request_method\nreturn 200": `it contains '\n', which breaks the generated configuration`,
+	} {
+		if got := otelOperationName(name); got != expected {
+			t.Errorf("expected %q for %q, got %q", expected, name, got)
+		}
+	}
+}
+
+// opentelemetryManifests is completed with the data of the ConfigMap and the
+// annotations of the Ingress
+const opentelemetryManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+data:
+%v
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+  annotations:
+%v
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`
+
+func TestCheckOpentelemetry(t *testing.T) {
+	testCases := map[string]struct {
+		data        string
+		annotations string
+		expected    []string
+	}{
+		"enabled globally": {
+			data: `  enable-opentelemetry: "true"
+  otlp-collector-host: otel-collector.observability
+  opentelemetry-operation-name: HTTP ${request_method} $uri`,
+			annotations: `    nginx.ingress.kubernetes.io/opentelemetry-operation-name: web-requests`,
+		},
+		"collector missing": {
+			data:        `  enable-opentelemetry: "true"`,
+			annotations: `    nginx.ingress.kubernetes.io/ssl-redirect: "false"`,
+			expected:    []string{"otel-collector-missing"},
+		},
+		"invalid global operation name": {
+			data:        `  opentelemetry-operation-name: HTTP ${uri`,
+			annotations: `    nginx.ingress.kubernetes.io/ssl-redirect: "false"`,
+			expected:    []string{"otel-invalid"},
+		},
+		"invalid annotations": {
+			data: `  enable-opentelemetry: "true"
+  otlp-collector-host: otel-collector.observability`,
+			annotations: `    nginx.ingress.kubernetes.io/opentelemetry-trust-incoming-span: "yes please"
+    nginx.ingress.kubernetes.io/opentelemetry-operation-name: HTTP "$uri"`,
+			expected: []string{"otel-invalid", "otel-invalid"},
+		},
+		"variables in the annotation": {
+			data: `  enable-opentelemetry: "true"
+  otlp-collector-host: otel-collector.observability`,
+			annotations: `    nginx.ingress.kubernetes.io/opentelemetry-operation-name: HTTP $request_method`,
+			expected:    []string{"otel-invalid"},
+		},
+		"ineffective annotations": {
+			annotations: `    nginx.ingress.kubernetes.io/opentelemetry-trust-incoming-span: "false"
+    nginx.ingress.kubernetes.io/opentelemetry-operation-name: web-requests`,
+			expected: []string{"otel-ineffective"},
+		},
+		"disabled by the location": {
+			data: `  enable-opentelemetry: "true"
+  otlp-collector-host: otel-collector.observability`,
+			annotations: `    nginx.ingress.kubernetes.io/enable-opentelemetry: "false"
+    nginx.ingress.kubernetes.io/opentelemetry-operation-name: web-requests`,
+			expected: []string{"otel-ineffective"},
+		},
+		"enabled by the location only": {
+			data:        `  otlp-collector-host: otel-collector.observability`,
+			annotations: `    nginx.ingress.kubernetes.io/enable-opentelemetry: "true"`,
+			expected:    []string{"otel-disabled-globally"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, fmt.Sprintf(opentelemetryManifests, tc.data, tc.annotations))
+			rules := []string{}
+			for _, f := range n.checkOpentelemetry(ingresses, cfg) {
+				rules = append(rules, f.Rule)
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(rules, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, rules)
+			}
+		})
+	}
+}
+
+func TestCheckOpentelemetryMessage(t *testing.T) {
+	n, ingresses, cfg := testConfiguration(t, fmt.Sprintf(opentelemetryManifests, `  enable-opentelemetry: "false"`,
+		`    nginx.ingress.kubernetes.io/enable-opentelemetry: "true"`))
+	findings := n.checkOpentelemetry(ingresses, cfg)
+	expected := `location "/" enables opentelemetry but enable-opentelemetry is false in ConfigMap ingress-nginx/ingress-nginx-controller; ` +
+		"otlp-collector-host is not set either, so no span is exported"
+	if len(findings) != 1 || findings[0].Ingress != "default/web" || findings[0].Message != expected {
+		t.Errorf("expected %q on default/web, got %+v", expected, findings)
+	}
+}
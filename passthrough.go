@@ -0,0 +1,137 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// checkSSLPassthrough explains how hosts using ssl-passthrough behave on each
+// port. On 443 the connection is routed by SNI to the backend of the root
+// path without being decrypted, so nginx ignores the paths and most
+// annotations. On 80 the same host is routed by nginx using the Host header
+// and the paths of the Ingresses, like any other host.
+func (n *NGINXController) checkSSLPassthrough(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+
+	for _, server := range cfg.Servers {
+		if !server.SSLPassthrough || len(server.Locations) == 0 {
+			continue
+		}
+
+		var root *Location
+		for _, loc := range server.Locations {
+			if loc.Path == rootLocation {
+				root = loc
+				break
+			}
+		}
+		if root == nil {
+			root = server.Locations[0]
+		}
+
+		if !n.cfg.EnableSSLPassthrough {
+			findings = append(findings, newLocationFinding("passthrough-disabled", SeverityError, server, root,
+				"ssl-passthrough requires the controller to run with --enable-ssl-passthrough; nginx terminates TLS for %v with the default certificate",
+				server.Hostname))
+			continue
+		}
+
+		if strings.HasPrefix(server.Hostname, "*.") {
+			findings = append(findings, newLocationFinding("passthrough-wildcard", SeverityError, server, root,
+				"SNI routing only matches exact hostnames, connections to %v on port 443 are terminated by nginx instead of being passed through",
+				server.Hostname))
+		}
+
+		if root.Path != rootLocation || root.Backend == defUpstreamName {
+			findings = append(findings, newLocationFinding("passthrough-no-backend", SeverityError, server, root,
+				"ssl-passthrough uses the backend of the / path, which %v does not define; TLS is terminated by nginx on port 443",
+				server.Hostname))
+			continue
+		}
+
+		var paths []string
+		for _, loc := range server.Locations {
+			if loc != root {
+				paths = append(paths, loc.Path)
+			}
+		}
+		if len(paths) > 0 {
+			findings = append(findings, newLocationFinding("passthrough-paths", SeverityWarning, server, root,
+				"paths %v are only routed on port 80 (by Host header); on port 443 every request of %v is sent to the backend of / (%v) without looking at the path",
+				strings.Join(paths, ", "), server.Hostname, root.Backend))
+		}
+
+		mixed := map[string]bool{}
+		for _, loc := range server.Locations {
+			if loc.Ingress == nil || loc.Ingress.ParsedAnnotations == nil || loc.Ingress.ParsedAnnotations.SSLPassthrough {
+				continue
+			}
+			mixed[k8s.MetaNamespaceKey(loc.Ingress)] = true
+		}
+		if len(mixed) > 0 {
+			findings = append(findings, newLocationFinding("passthrough-mixed-ingresses", SeverityWarning, server, root,
+				"Ingresses %v define %v without ssl-passthrough, but the whole host is passed through on port 443; their rules only apply on port 80",
+				strings.Join(sortedSet(mixed), ", "), server.Hostname))
+		}
+
+		if ignored := terminatingAnnotations(root); len(ignored) > 0 {
+			findings = append(findings, newLocationFinding("passthrough-annotations", SeverityWarning, server, root,
+				"%v are applied on port 80 only; nginx does not decrypt the requests to %v on port 443",
+				strings.Join(ignored, ", "), server.Hostname))
+		}
+
+		if len(server.Aliases) > 0 {
+			findings = append(findings, newLocationFinding("passthrough-alias", SeverityWarning, server, root,
+				"SNI routing only uses the hostname; connections to the aliases %v on port 443 are terminated by nginx",
+				strings.Join(server.Aliases, ", ")))
+		}
+
+		if !root.Rewrite.ForceSSLRedirect && !strings.EqualFold(root.BackendProtocol, "HTTPS") {
+			findings = append(findings, newLocationFinding("passthrough-plain-http", SeverityWarning, server, root,
+				"on port 80 nginx proxies plain HTTP requests for %v to %v, which receives TLS on port 443; unless the backend also accepts HTTP the requests fail, consider force-ssl-redirect",
+				server.Hostname, root.Backend))
+		}
+	}
+
+	return findings
+}
+
+// terminatingAnnotations returns the annotations of the location that need
+// nginx to decrypt the requests
+func terminatingAnnotations(loc *Location) []string {
+	var anns []string
+	if loc.BasicDigestAuth.Secured {
+		anns = append(anns, "auth-type")
+	}
+	if loc.ExternalAuth.URL != "" {
+		anns = append(anns, "auth-url")
+	}
+	if len(loc.Allowlist.CIDR) > 0 {
+		anns = append(anns, "allowlist-source-range")
+	}
+	if len(loc.Denylist.CIDR) > 0 {
+		anns = append(anns, "denylist-source-range")
+	}
+	if loc.RateLimit.Connections.Limit > 0 || loc.RateLimit.RPS.Limit > 0 || loc.RateLimit.RPM.Limit > 0 {
+		anns = append(anns, "limit-*")
+	}
+	if loc.Rewrite.Target != "" {
+		anns = append(anns, "rewrite-target")
+	}
+	if loc.CorsConfig.CorsEnabled {
+		anns = append(anns, "enable-cors")
+	}
+	if len(loc.CustomHeaders.Headers) > 0 {
+		anns = append(anns, "custom-headers")
+	}
+	if loc.ModSecurity.Enable {
+		anns = append(anns, "enable-modsecurity")
+	}
+	if loc.ConfigurationSnippet != "" {
+		anns = append(anns, "configuration-snippet")
+	}
+	sort.Strings(anns)
+	return anns
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// passthroughIngress returns the manifest of an Ingress routing the paths of
+// host to the Service web
+func passthroughIngress(name, host string, annotations map[string]string, paths ...string) string {
+	manifest := fmt.Sprintf(`
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: %v
+  namespace: default
+  annotations:
+`, name)
+	for k, v := range annotations {
+		manifest += fmt.Sprintf("    nginx.ingress.kubernetes.io/%v: %q\n", k, v)
+	}
+	manifest += fmt.Sprintf(`spec:
+  ingressClassName: nginx
+  rules:
+  - host: %q
+    http:
+      paths:
+`, host)
+	for _, path := range paths {
+		manifest += fmt.Sprintf(`      - path: %v
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 443
+`, path)
+	}
+	return manifest
+}
+
+const passthroughService = `
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: default
+spec:
+  ports:
+  - name: https
+    port: 443
+    protocol: TCP
+`
+
+func TestCheckSSLPassthrough(t *testing.T) {
+	passthrough := map[string]string{"ssl-passthrough": "true", "force-ssl-redirect": "true"}
+
+	tests := []struct {
+		name      string
+		disabled  bool
+		manifests string
+		expected  []string
+	}{
+		{
+			name:      "passthrough",
+			manifests: passthroughIngress("web", "web.example.com", passthrough, "/"),
+			expected:  []string{},
+		},
+		{
+			name:      "controller without passthrough",
+			disabled:  true,
+			manifests: passthroughIngress("web", "web.example.com", passthrough, "/"),
+			expected:  []string{"passthrough-disabled"},
+		},
+		{
+			name:      "wildcard host",
+			manifests: passthroughIngress("web", "*.example.com", passthrough, "/"),
+			expected:  []string{"passthrough-wildcard"},
+		},
+		{
+			name:      "no root path",
+			manifests: passthroughIngress("web", "web.example.com", passthrough, "/api"),
+			expected:  []string{"passthrough-no-backend"},
+		},
+		{
+			name:      "paths",
+			manifests: passthroughIngress("web", "web.example.com", passthrough, "/", "/api"),
+			expected:  []string{"passthrough-paths"},
+		},
+		{
+			// the first Ingress of the host enables ssl-passthrough on the server
+			name: "Ingresses without passthrough",
+			manifests: passthroughIngress("web", "web.example.com", passthrough, "/") +
+				passthroughIngress("web-api", "web.example.com", nil, "/api"),
+			expected: []string{"passthrough-mixed-ingresses", "passthrough-paths"},
+		},
+		{
+			name: "annotations terminating TLS",
+			manifests: passthroughIngress("web", "web.example.com",
+				map[string]string{"ssl-passthrough": "true", "force-ssl-redirect": "true", "limit-rps": "10", "whitelist-source-range": "10.0.0.0/8"}, "/"),
+			expected: []string{"passthrough-annotations"},
+		},
+		{
+			name:      "plain HTTP on port 80",
+			manifests: passthroughIngress("web", "web.example.com", map[string]string{"ssl-passthrough": "true"}, "/"),
+			expected:  []string{"passthrough-plain-http"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, passthroughService+tc.manifests)
+			n.cfg.EnableSSLPassthrough = !tc.disabled
+
+			got := []string{}
+			for _, f := range n.checkSSLPassthrough(ingresses, cfg) {
+				got = append(got, f.Rule)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+// Validator is the gRPC service of the ingress validation webhook, served on
+// --grpc-address with the certificate and the authentication of the webhook.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: validator.proto
+
+package validatorv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Validator_ValidateIngresses_FullMethodName = "/nginxconfigvalidator.v1.Validator/ValidateIngresses"
+	Validator_SummarizeConfig_FullMethodName   = "/nginxconfigvalidator.v1.Validator/SummarizeConfig"
+	Validator_ExplainRoute_FullMethodName      = "/nginxconfigvalidator.v1.Validator/ExplainRoute"
+)
+
+// ValidatorClient is the client API for Validator service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ValidatorClient interface {
+	// ValidateIngresses streams the findings of the Ingresses of the
+	// manifests, validated on top of the state of the cluster, or of all the
+	// Ingresses of the namespaces of the caller when the request has no
+	// manifests
+	ValidateIngresses(ctx context.Context, in *ValidateIngressesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Finding], error)
+	// SummarizeConfig streams the summary of the configuration built for the
+	// state of the cluster, with the objects of the manifests, in chunks. The
+	// summary is not the nginx.conf rendered by ingress-nginx.
+	SummarizeConfig(ctx context.Context, in *SummarizeConfigRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SummarizeConfigChunk], error)
+	// ExplainRoute returns the location nginx uses for a host and path and
+	// where its settings come from
+	ExplainRoute(ctx context.Context, in *ExplainRouteRequest, opts ...grpc.CallOption) (*ExplainRouteResponse, error)
+}
+
+type validatorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewValidatorClient(cc grpc.ClientConnInterface) ValidatorClient {
+	return &validatorClient{cc}
+}
+
+func (c *validatorClient) ValidateIngresses(ctx context.Context, in *ValidateIngressesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Finding], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Validator_ServiceDesc.Streams[0], Validator_ValidateIngresses_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ValidateIngressesRequest, Finding]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Validator_ValidateIngressesClient = grpc.ServerStreamingClient[Finding]
+
+func (c *validatorClient) SummarizeConfig(ctx context.Context, in *SummarizeConfigRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SummarizeConfigChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Validator_ServiceDesc.Streams[1], Validator_SummarizeConfig_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SummarizeConfigRequest, SummarizeConfigChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Validator_SummarizeConfigClient = grpc.ServerStreamingClient[SummarizeConfigChunk]
+
+func (c *validatorClient) ExplainRoute(ctx context.Context, in *ExplainRouteRequest, opts ...grpc.CallOption) (*ExplainRouteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExplainRouteResponse)
+	err := c.cc.Invoke(ctx, Validator_ExplainRoute_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ValidatorServer is the server API for Validator service.
+// All implementations must embed UnimplementedValidatorServer
+// for forward compatibility.
+type ValidatorServer interface {
+	// ValidateIngresses streams the findings of the Ingresses of the
+	// manifests, validated on top of the state of the cluster, or of all the
+	// Ingresses of the namespaces of the caller when the request has no
+	// manifests
+	ValidateIngresses(*ValidateIngressesRequest, grpc.ServerStreamingServer[Finding]) error
+	// SummarizeConfig streams the summary of the configuration built for the
+	// state of the cluster, with the objects of the manifests, in chunks. The
+	// summary is not the nginx.conf rendered by ingress-nginx.
+	SummarizeConfig(*SummarizeConfigRequest, grpc.ServerStreamingServer[SummarizeConfigChunk]) error
+	// ExplainRoute returns the location nginx uses for a host and path and
+	// where its settings come from
+	ExplainRoute(context.Context, *ExplainRouteRequest) (*ExplainRouteResponse, error)
+	mustEmbedUnimplementedValidatorServer()
+}
+
+// UnimplementedValidatorServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedValidatorServer struct{}
+
+func (UnimplementedValidatorServer) ValidateIngresses(*ValidateIngressesRequest, grpc.ServerStreamingServer[Finding]) error {
+	return status.Errorf(codes.Unimplemented, "method ValidateIngresses not implemented")
+}
+func (UnimplementedValidatorServer) SummarizeConfig(*SummarizeConfigRequest, grpc.ServerStreamingServer[SummarizeConfigChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method SummarizeConfig not implemented")
+}
+func (UnimplementedValidatorServer) ExplainRoute(context.Context, *ExplainRouteRequest) (*ExplainRouteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExplainRoute not implemented")
+}
+func (UnimplementedValidatorServer) mustEmbedUnimplementedValidatorServer() {}
+func (UnimplementedValidatorServer) testEmbeddedByValue()                   {}
+
+// UnsafeValidatorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ValidatorServer will
+// result in compilation errors.
+type UnsafeValidatorServer interface {
+	mustEmbedUnimplementedValidatorServer()
+}
+
+func RegisterValidatorServer(s grpc.ServiceRegistrar, srv ValidatorServer) {
+	// If the following call pancis, it indicates UnimplementedValidatorServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Validator_ServiceDesc, srv)
+}
+
+func _Validator_ValidateIngresses_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ValidateIngressesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ValidatorServer).ValidateIngresses(m, &grpc.GenericServerStream[ValidateIngressesRequest, Finding]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Validator_ValidateIngressesServer = grpc.ServerStreamingServer[Finding]
+
+func _Validator_SummarizeConfig_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SummarizeConfigRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ValidatorServer).SummarizeConfig(m, &grpc.GenericServerStream[SummarizeConfigRequest, SummarizeConfigChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Validator_SummarizeConfigServer = grpc.ServerStreamingServer[SummarizeConfigChunk]
+
+func _Validator_ExplainRoute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExplainRouteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValidatorServer).ExplainRoute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Validator_ExplainRoute_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValidatorServer).ExplainRoute(ctx, req.(*ExplainRouteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Validator_ServiceDesc is the grpc.ServiceDesc for Validator service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Validator_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "nginxconfigvalidator.v1.Validator",
+	HandlerType: (*ValidatorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ExplainRoute",
+			Handler:    _Validator_ExplainRoute_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ValidateIngresses",
+			Handler:       _Validator_ValidateIngresses_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SummarizeConfig",
+			Handler:       _Validator_SummarizeConfig_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "validator.proto",
+}
@@ -0,0 +1,35 @@
+// Package k8s contains the helpers of k8s.io/ingress-nginx/pkg/k8s used by
+// the validator.
+package k8s
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/klog/v2"
+)
+
+// ParseNameNS parses a string searching a namespace and name
+func ParseNameNS(input string) (ns, name string, err error) {
+	nsName := strings.Split(input, "/")
+	if len(nsName) != 2 {
+		return "", "", fmt.Errorf("invalid format (namespace/name) found in '%v'", input)
+	}
+
+	return nsName[0], nsName[1], nil
+}
+
+// MetaNamespaceKey knows how to make keys for API objects which implement
+// meta.Interface: namespace/name, or name for the objects without namespace
+func MetaNamespaceKey(obj interface{}) string {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		klog.Warning(err)
+		return ""
+	}
+	if accessor.GetNamespace() == "" {
+		return accessor.GetName()
+	}
+	return accessor.GetNamespace() + "/" + accessor.GetName()
+}
@@ -0,0 +1,9 @@
+// Package file contains the paths of the files written by ingress-nginx,
+// from k8s.io/ingress-nginx/pkg/util/file.
+package file
+
+const (
+	// DefaultSSLDirectory defines the location where the SSL certificates
+	// will be generated
+	DefaultSSLDirectory = "/etc/ingress-controller/ssl"
+)
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// planFormatVersion is the version of the schema of the plan. It changes
+// only when fields are removed or change meaning.
+const planFormatVersion = "1"
+
+// validationPlan is the machine-readable result of a validation: the
+// resources validated, the findings and the servers and locations nginx
+// would be configured with
+type validationPlan struct {
+	FormatVersion string `json:"format_version"`
+	// Valid is false when a finding is an error
+	Valid     bool           `json:"valid"`
+	Summary   planSummary    `json:"summary"`
+	Resources []planResource `json:"resources"`
+	Findings  []Finding      `json:"findings"`
+	Servers   []planServer   `json:"servers"`
+}
+
+// planSummary counts the findings by severity
+type planSummary struct {
+	Errors   int `json:"errors"`
+	Warnings int `json:"warnings"`
+	Info     int `json:"info"`
+}
+
+// planResource is a resource validated
+type planResource struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Source is the position of the resource in the manifests
+	Source   string `json:"source,omitempty"`
+	Errors   int    `json:"errors"`
+	Warnings int    `json:"warnings"`
+}
+
+// planServer is a server of the resulting configuration
+type planServer struct {
+	Hostname  string         `json:"hostname"`
+	Aliases   []string       `json:"aliases"`
+	TLSSecret string         `json:"tls_secret,omitempty"`
+	Locations []planLocation `json:"locations"`
+}
+
+// planLocation is a location of a server, Location being the location as
+// written in nginx.conf
+type planLocation struct {
+	Location string `json:"location"`
+	Ingress  string `json:"ingress,omitempty"`
+	Backend  string `json:"backend"`
+}
+
+// newValidationPlan builds the plan of the validation of the Ingresses. The
+// lists are sorted so the plan only changes when the result does.
+func newValidationPlan(s Storer, ingresses []*Ingress, cfg *Configuration, findings []Finding) *validationPlan {
+	p := &validationPlan{
+		FormatVersion: planFormatVersion,
+		Valid:         true,
+		Resources:     []planResource{},
+		Findings:      findings,
+		Servers:       []planServer{},
+	}
+	if p.Findings == nil {
+		p.Findings = []Finding{}
+	}
+
+	perIngress := map[string]map[Severity]int{}
+	for _, f := range findings {
+		switch f.Severity {
+		case SeverityError:
+			p.Valid = false
+			p.Summary.Errors++
+		case SeverityWarning:
+			p.Summary.Warnings++
+		default:
+			p.Summary.Info++
+		}
+		if f.Ingress != "" {
+			if perIngress[f.Ingress] == nil {
+				perIngress[f.Ingress] = map[Severity]int{}
+			}
+			perIngress[f.Ingress][f.Severity]++
+		}
+	}
+
+	for _, ing := range ingresses {
+		key := k8s.MetaNamespaceKey(ing)
+		p.Resources = append(p.Resources, planResource{
+			Kind:      "Ingress",
+			Namespace: ing.Namespace,
+			Name:      ing.Name,
+			Source:    s.GetIngressSource(key),
+			Errors:    perIngress[key][SeverityError],
+			Warnings:  perIngress[key][SeverityWarning],
+		})
+	}
+	sort.Slice(p.Resources, func(i, j int) bool {
+		a, b := p.Resources[i], p.Resources[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	secrets := tlsSecretsByHost(ingresses)
+	for _, server := range cfg.Servers {
+		if server.Hostname == "_" {
+			continue
+		}
+		ps := planServer{
+			Hostname:  server.Hostname,
+			Aliases:   append([]string{}, server.Aliases...),
+			TLSSecret: secrets[server.Hostname],
+			Locations: []planLocation{},
+		}
+		sort.Strings(ps.Aliases)
+		enforceRegex := enforceRegexModifier(server.Locations)
+		for _, loc := range server.Locations {
+			pl := planLocation{Location: conformanceLocationKey(loc, enforceRegex), Backend: loc.Backend}
+			if loc.Ingress != nil {
+				pl.Ingress = k8s.MetaNamespaceKey(loc.Ingress)
+			}
+			ps.Locations = append(ps.Locations, pl)
+		}
+		sort.Slice(ps.Locations, func(i, j int) bool { return ps.Locations[i].Location < ps.Locations[j].Location })
+		p.Servers = append(p.Servers, ps)
+	}
+	sort.Slice(p.Servers, func(i, j int) bool { return p.Servers[i].Hostname < p.Servers[j].Hostname })
+	return p
+}
+
+// writePlan prints the plan as JSON
+func writePlan(w io.Writer, p *validationPlan) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// writeTerraformPlan prints the plan as the flat object of strings expected
+// from the programs of the Terraform external data source, the plan itself
+// being encoded in the plan key
+func writeTerraformPlan(w io.Writer, p *validationPlan) error {
+	plan, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(map[string]string{
+		"format_version": p.FormatVersion,
+		"valid":          strconv.FormatBool(p.Valid),
+		"errors":         strconv.Itoa(p.Summary.Errors),
+		"warnings":       strconv.Itoa(p.Summary.Warnings),
+		"servers":        strconv.Itoa(len(p.Servers)),
+		"plan":           string(plan),
+	})
+}
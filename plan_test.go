@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewValidationPlan(t *testing.T) {
+	manifests := strings.Replace(isolationManifests, `spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /api`, `spec:
+  ingressClassName: nginx
+  tls:
+  - hosts: [web.example.com]
+    secretName: web-tls
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /api`, 1)
+	n, ingresses, cfg := testConfiguration(t, manifests)
+	findings := []Finding{
+		{Rule: "cors-permissive", Severity: SeverityWarning, Ingress: "default/web"},
+		{Rule: "tls-policy", Severity: SeverityError, Ingress: "default/api"},
+		{Rule: "snapshot", Severity: SeverityInfo},
+	}
+
+	p := newValidationPlan(n.store, ingresses, cfg, findings)
+	if p.FormatVersion != planFormatVersion || p.Valid {
+		t.Errorf("expected an invalid plan of version %v, got %v valid %v", planFormatVersion, p.FormatVersion, p.Valid)
+	}
+	if p.Summary != (planSummary{Errors: 1, Warnings: 1, Info: 1}) {
+		t.Errorf("unexpected summary %+v", p.Summary)
+	}
+
+	expectedResources := []planResource{
+		{Kind: "Ingress", Namespace: "default", Name: "api", Source: "test:21 (document 1)", Errors: 1},
+		{Kind: "Ingress", Namespace: "default", Name: "web", Source: "test:1 (document 0)", Warnings: 1},
+	}
+	if !reflect.DeepEqual(p.Resources, expectedResources) {
+		t.Errorf("expected the resources %+v, got %+v", expectedResources, p.Resources)
+	}
+
+	// the default server is left out
+	expectedServers := []planServer{{
+		Hostname:  "web.example.com",
+		Aliases:   []string{},
+		TLSSecret: "default/web-tls",
+		Locations: []planLocation{
+			{Location: "/", Ingress: "default/web", Backend: "default-web-80"},
+			{Location: "/api/", Ingress: "default/api", Backend: "default-api-80"},
+			// the Exact location added for the Prefix path
+			{Location: "= /api", Ingress: "default/api", Backend: "default-api-80"},
+		},
+	}}
+	if !reflect.DeepEqual(p.Servers, expectedServers) {
+		t.Errorf("expected the servers %+v, got %+v", expectedServers, p.Servers)
+	}
+
+	// the lists are never null
+	empty := newValidationPlan(n.store, nil, &Configuration{}, nil)
+	if !empty.Valid || empty.Findings == nil || empty.Resources == nil || empty.Servers == nil {
+		t.Errorf("expected a valid plan with empty lists, got %+v", empty)
+	}
+}
+
+func TestWriteTerraformPlan(t *testing.T) {
+	p := &validationPlan{
+		FormatVersion: planFormatVersion,
+		Summary:       planSummary{Errors: 2, Warnings: 1},
+		Resources:     []planResource{},
+		Findings:      []Finding{},
+		Servers:       []planServer{{Hostname: "web.example.com"}},
+	}
+
+	var out bytes.Buffer
+	if err := writeTerraformPlan(&out, p); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the external data source only accepts strings
+	result := map[string]string{}
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatalf("expected an object of strings, got %q: %v", out.String(), err)
+	}
+	for key, expected := range map[string]string{"format_version": "1", "valid": "false", "errors": "2", "warnings": "1", "servers": "1"} {
+		if result[key] != expected {
+			t.Errorf("expected %v to be %q, got %q", key, expected, result[key])
+		}
+	}
+
+	decoded := &validationPlan{}
+	if err := json.Unmarshal([]byte(result["plan"]), decoded); err != nil {
+		t.Fatalf("expected the plan in the plan key: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, p) {
+		t.Errorf("expected %+v, got %+v", p, decoded)
+	}
+}
+
+func TestRunValidatePlan(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "ingress.yaml")
+	if err := os.WriteFile(manifest, []byte(cliManifests), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"validate", "-f", manifest, "--nginx-version", "1.13.9", "--output", "plan"}
+	if code := runCLI(args, &stdout, &stderr); code != exitErrors {
+		t.Fatalf("expected exit code %v, got %v (%v)", exitErrors, code, stderr.String())
+	}
+	p := &validationPlan{}
+	if err := json.Unmarshal(stdout.Bytes(), p); err != nil {
+		t.Fatalf("invalid plan %q: %v", stdout.String(), err)
+	}
+	if p.Valid || len(findingsWithRule(p.Findings, "h2c-nginx-version")) != 1 || len(p.Servers) != 1 || p.Servers[0].Hostname != "h2c.example.com" {
+		t.Errorf("unexpected plan %+v", p)
+	}
+	if len(p.Resources) != 1 || !strings.HasPrefix(p.Resources[0].Source, manifest) {
+		t.Errorf("expected the source of the Ingress, got %+v", p.Resources)
+	}
+
+	stdout.Reset()
+	args = []string{"validate", "-f", manifest, "--nginx-version", "1.13.9", "--output", "terraform", "--fail-on", "none"}
+	if code := runCLI(args, &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected exit code %v, got %v (%v)", exitOK, code, stderr.String())
+	}
+	result := map[string]string{}
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil || result["valid"] != "false" {
+		t.Errorf("expected an invalid Terraform plan, got %q", stdout.String())
+	}
+
+	stderr.Reset()
+	args = []string{"validate", "-f", manifest, "--output", "plan", "--watch"}
+	if code := runCLI(args, &stdout, &stderr); code != exitInternal || !strings.Contains(stderr.String(), "--output plan can not be used with --watch") {
+		t.Errorf("expected --watch to be rejected, got %v %q", code, stderr.String())
+	}
+}
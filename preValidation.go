@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// readyzPath answers 200 once the existing cluster state was validated
+	readyzPath = "/readyz"
+	// preValidationPath runs the validation of the cluster state on demand
+	preValidationPath = "/prevalidate"
+)
+
+// PreValidationResult is the outcome of the validation of the Ingresses
+// already present in the cluster
+type PreValidationResult struct {
+	Time      time.Time `json:"time"`
+	Ingresses int       `json:"ingresses"`
+	Valid     bool      `json:"valid"`
+	Findings  []Finding `json:"findings"`
+}
+
+// preValidate validates the configuration generated from the Ingresses in
+// the store. Errors mean this version of the validator disagrees with the
+// configuration the controllers are running, so the webhook would reject
+// changes to Ingresses that already work.
+func (n *NGINXController) preValidate() *PreValidationResult {
+	ingresses := n.store.ListIngresses()
+	_, findings := n.validate(ingresses)
+
+	result := &PreValidationResult{
+		Time:      time.Now(),
+		Ingresses: len(ingresses),
+		Valid:     true,
+		Findings:  []Finding{},
+	}
+	for _, f := range findings {
+		if f.Severity != SeverityError {
+			continue
+		}
+		result.Valid = false
+		result.Findings = append(result.Findings, f)
+	}
+
+	// the replicas validate the same cluster state, only the leader reports it
+	if n.leading() {
+		for _, ing := range ingresses {
+			n.recordFindingEvents(&ing.Ingress, findingsForIngress(result.Findings, ing))
+			n.recordClusterReport(&ing.Ingress, findingsForIngress(findings, ing))
+		}
+		n.notifications.validationFailed(result.Findings)
+	}
+
+	if result.Valid {
+		klog.Infof("Validated %d existing Ingresses", result.Ingresses)
+	} else {
+		klog.Errorf("The existing Ingresses do not produce a valid configuration (%d errors), the webhook will not be ready", len(result.Findings))
+		for _, f := range result.Findings {
+			klog.Errorf("Pre-validation: %v", f)
+		}
+	}
+
+	n.preValidationLock.Lock()
+	n.preValidation = result
+	n.preValidationLock.Unlock()
+
+	return result
+}
+
+// isReady returns true once the existing cluster state was validated
+// without errors
+func (n *NGINXController) isReady() bool {
+	n.preValidationLock.RLock()
+	defer n.preValidationLock.RUnlock()
+	return n.preValidation != nil && n.preValidation.Valid
+}
+
+// readyzHandler reports the webhook as ready when its certificate is loaded
+// and the last pre-validation succeeded, and lists the offending resources
+// otherwise
+func (n *NGINXController) readyzHandler(w http.ResponseWriter, _ *http.Request) {
+	n.preValidationLock.RLock()
+	result := n.preValidation
+	n.preValidationLock.RUnlock()
+
+	switch {
+	case n.shuttingDown():
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+	case n.webhookCertificateError(time.Now()) != nil:
+		http.Error(w, n.webhookCertificateError(time.Now()).Error(), http.StatusServiceUnavailable)
+	case result == nil:
+		http.Error(w, "existing Ingresses not validated yet", http.StatusServiceUnavailable)
+	case !result.Valid:
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "existing Ingresses do not produce a valid configuration:\n")
+		for _, f := range result.Findings {
+			fmt.Fprintln(w, f)
+		}
+	default:
+		fmt.Fprintln(w, "ok")
+	}
+}
+
+// preValidationHandler runs the validation of the cluster state and returns
+// the result. The readiness of the webhook is updated accordingly.
+func (n *NGINXController) preValidationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	result := n.preValidate()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !result.Valid {
+		w.WriteHeader(http.StatusConflict)
+	}
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		klog.Errorf("Error writing pre-validation result: %v", err)
+	}
+}
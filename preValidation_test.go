@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const preValidationManifests = `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: h2c
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/backend-protocol: H2C
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: h2c.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: h2c
+            port:
+              number: 80
+`
+
+func TestPreValidation(t *testing.T) {
+	readyz := func(n *NGINXController) int {
+		rec := httptest.NewRecorder()
+		n.readyzHandler(rec, httptest.NewRequest(http.MethodGet, readyzPath, nil))
+		return rec.Code
+	}
+	prevalidate := func(n *NGINXController, method string) (int, *PreValidationResult) {
+		rec := httptest.NewRecorder()
+		n.preValidationHandler(rec, httptest.NewRequest(method, preValidationPath, nil))
+		result := &PreValidationResult{}
+		if rec.Code != http.StatusMethodNotAllowed {
+			if err := json.Unmarshal(rec.Body.Bytes(), result); err != nil {
+				t.Fatalf("invalid pre-validation result %q: %v", rec.Body.String(), err)
+			}
+		}
+		return rec.Code, result
+	}
+
+	n := newTestController(t, preValidationManifests)
+	loadTestWebhookCertificate(t, n, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if code := readyz(n); code != http.StatusServiceUnavailable {
+		t.Errorf("expected the webhook not to be ready before the pre-validation, got %d", code)
+	}
+
+	if code, result := prevalidate(n, http.MethodPost); code != http.StatusOK || !result.Valid || result.Ingresses != 1 {
+		t.Errorf("expected a valid pre-validation of one Ingress, got %d %+v", code, result)
+	}
+	if code := readyz(n); code != http.StatusOK {
+		t.Errorf("expected the webhook to be ready, got %d", code)
+	}
+
+	// the existing Ingress does not work with the validated nginx version
+	n.cfg.NginxVersion = "1.13.9"
+	code, result := prevalidate(n, http.MethodPost)
+	if code != http.StatusConflict || result.Valid || len(findingsWithRule(result.Findings, "h2c-nginx-version")) != 1 {
+		t.Errorf("expected an invalid pre-validation, got %d %+v", code, result)
+	}
+	if code := readyz(n); code != http.StatusServiceUnavailable {
+		t.Errorf("expected the webhook not to be ready, got %d", code)
+	}
+
+	if code, _ := prevalidate(n, http.MethodGet); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected GET to be rejected, got %d", code)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"reflect"
+	"runtime"
+	"runtime/metrics"
+	runtimepprof "runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/nginx"
+)
+
+// profilerAddress is the address of the pprof server, only reachable from
+// the pod
+const profilerAddress = "127.0.0.1"
+
+// profiling is set when the profiler runs, the pipeline is only instrumented
+// then
+var profiling atomic.Bool
+
+// profiles listing the stacks of the validations and external commands in
+// flight, next to the standard profiles of /debug/pprof
+var (
+	validationsProfile = runtimepprof.NewProfile("nginx-config-validator/validations")
+	execProfile        = runtimepprof.NewProfile("nginx-config-validator/exec")
+)
+
+// timings and allocations of the phases of the validation pipeline and of
+// the external commands, by name
+var (
+	pipelineCalls      = expvar.NewMap("pipeline_calls_total")
+	pipelineSeconds    = expvar.NewMap("pipeline_seconds_total")
+	pipelineAllocBytes = expvar.NewMap("pipeline_alloc_bytes_total")
+	execCalls          = expvar.NewMap("exec_calls_total")
+	execSeconds        = expvar.NewMap("exec_seconds_total")
+)
+
+// heapAllocsMetric is the cumulative size of the heap allocations
+const heapAllocsMetric = "/gc/heap/allocs:bytes"
+
+func heapAllocBytes() uint64 {
+	sample := []metrics.Sample{{Name: heapAllocsMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// startValidationProfile starts the trace task of a validation. The returned
+// function ends it.
+func startValidationProfile() (context.Context, func()) {
+	if !profiling.Load() {
+		return context.Background(), func() {}
+	}
+
+	ctx, task := trace.NewTask(context.Background(), "validate")
+	key := new(byte)
+	validationsProfile.Add(key, 1)
+	return ctx, func() {
+		validationsProfile.Remove(key)
+		task.End()
+	}
+}
+
+// profilePhase runs a phase of the validation pipeline, recording its
+// duration and the bytes allocated meanwhile. Allocations are process wide,
+// so they are only accurate for validations not running concurrently.
+func profilePhase(ctx context.Context, name string, phase func()) {
+	if !profiling.Load() {
+		phase()
+		return
+	}
+
+	region := trace.StartRegion(ctx, name)
+	start := time.Now()
+	allocs := heapAllocBytes()
+
+	phase()
+
+	region.End()
+	pipelineCalls.Add(name, 1)
+	pipelineSeconds.AddFloat(name, time.Since(start).Seconds())
+	pipelineAllocBytes.Add(name, int64(heapAllocBytes()-allocs))
+}
+
+// ruleName returns the name of the method implementing a rule, such as
+// checkCORS
+func ruleName(rule validationRule) string {
+	name := runtime.FuncForPC(reflect.ValueOf(rule).Pointer()).Name()
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// profileExec records the latency of an external command. The returned
+// function must be called once the command exits.
+func profileExec(name string) func() {
+	if !profiling.Load() {
+		return func() {}
+	}
+
+	start := time.Now()
+	key := new(byte)
+	execProfile.Add(key, 1)
+	return func() {
+		execProfile.Remove(key)
+		execCalls.Add(name, 1)
+		execSeconds.AddFloat(name, time.Since(start).Seconds())
+	}
+}
+
+// defaultMetricsAddress serves the metrics only to the pod, as they include
+// the command line of the webhook
+const defaultMetricsAddress = profilerAddress + ":10249"
+
+// startMetricsServer serves the metrics on MetricsAddress, apart from the
+// webhook port reachable from the cluster
+func (n *NGINXController) startMetricsServer() {
+	if n.cfg.MetricsAddress == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, expvar.Handler())
+	server := &http.Server{
+		Addr:              n.cfg.MetricsAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-n.stopCh
+		_ = server.Close()
+	}()
+
+	klog.Infof("Starting metrics on %v", server.Addr)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			klog.Errorf("Error serving metrics on %v: %v", server.Addr, err)
+		}
+	}()
+}
+
+// startProfiler serves pprof and the metrics on the profiler port of nginx
+// when EnableProfiling is set
+func (n *NGINXController) startProfiler() {
+	if !n.cfg.EnableProfiling {
+		return
+	}
+	profiling.Store(true)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle(metricsPath, expvar.Handler())
+
+	server := &http.Server{
+		Addr:              net.JoinHostPort(profilerAddress, strconv.Itoa(nginx.ProfilerPort)),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-n.stopCh
+		_ = server.Close()
+	}()
+
+	klog.Infof("Starting profiler on %v", server.Addr)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			klog.Errorf("Error serving profiler on %v: %v", server.Addr, err)
+		}
+	}()
+}
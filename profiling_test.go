@@ -0,0 +1,90 @@
+package main
+
+import (
+	"expvar"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// expvarCount returns the value of a counter of the map, 0 when missing
+func expvarCount(m *expvar.Map, key string) int64 {
+	v, ok := m.Get(key).(*expvar.Int)
+	if !ok {
+		return 0
+	}
+	return v.Value()
+}
+
+func TestRuleName(t *testing.T) {
+	if name := ruleName((*NGINXController).checkCORS); name != "checkCORS" {
+		t.Errorf("expected checkCORS, got %q", name)
+	}
+}
+
+func TestProfileValidation(t *testing.T) {
+	n := newTestController(t, preValidationManifests)
+
+	// without profiling the pipeline is not instrumented
+	build := expvarCount(pipelineCalls, "build")
+	n.validate(n.store.ListIngresses())
+	if got := expvarCount(pipelineCalls, "build"); got != build {
+		t.Errorf("expected no timings without profiling, got %d builds", got-build)
+	}
+
+	profiling.Store(true)
+	defer profiling.Store(false)
+
+	cors := expvarCount(pipelineCalls, "checkCORS")
+	n.validate(n.store.ListIngresses())
+	for name, expected := range map[string]int64{"build": build + 1, "checkCORS": cors + 1} {
+		if got := expvarCount(pipelineCalls, name); got != expected {
+			t.Errorf("expected %d calls of %v, got %d", expected, name, got)
+		}
+	}
+	if count := validationsProfile.Count(); count != 0 {
+		t.Errorf("expected no validation in flight, got %d", count)
+	}
+
+	done := profileExec("helm")
+	if count := execProfile.Count(); count != 1 {
+		t.Errorf("expected the command in flight in the profile, got %d", count)
+	}
+	calls := expvarCount(execCalls, "helm")
+	done()
+	if count := execProfile.Count(); count != 0 {
+		t.Errorf("expected no command in flight, got %d", count)
+	}
+	if got := expvarCount(execCalls, "helm"); got != calls+1 {
+		t.Errorf("expected the helm call to be counted, got %d", got-calls)
+	}
+}
+
+func TestStartMetricsServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	l.Close()
+
+	n := newOfflineController(&NginxConfiguration{MetricsAddress: address}, newMemoryStore(""))
+	n.startMetricsServer()
+	defer close(n.stopCh)
+
+	var resp *http.Response
+	for range 50 {
+		if resp, err = http.Get("http://" + address + metricsPath); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the metrics to be served, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// proxyTimeoutPolicy are the maximum proxy timeouts of the locations allowed
+// in the cluster, 0 disabling the check. A nil field keeps the maximum set by
+// the flags.
+type proxyTimeoutPolicy struct {
+	// MaxReadTimeout is the maximum proxy-read-timeout
+	// +optional
+	MaxReadTimeout *metav1.Duration `json:"maxReadTimeout,omitempty"`
+	// MaxSendTimeout is the maximum proxy-send-timeout
+	// +optional
+	MaxSendTimeout *metav1.Duration `json:"maxSendTimeout,omitempty"`
+}
+
+// checkProxyTimeouts reports the proxy timeouts over the policy of the
+// cluster, the connect timeouts failing every connection and the retries of
+// non idempotent requests, which the backends can process twice
+func (n *NGINXController) checkProxyTimeouts(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	checked := map[*Ingress]bool{}
+
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			if loc.Ingress == nil || checked[loc.Ingress] {
+				continue
+			}
+			checked[loc.Ingress] = true
+			proxy := loc.Proxy
+
+			for _, t := range []struct {
+				name    string
+				timeout int
+				max     time.Duration
+			}{
+				{"proxy-read-timeout", proxy.ReadTimeout, n.cfg.MaxProxyReadTimeout},
+				{"proxy-send-timeout", proxy.SendTimeout, n.cfg.MaxProxySendTimeout},
+			} {
+				timeout := time.Duration(t.timeout) * time.Second
+				if t.max > 0 && timeout > t.max {
+					findings = append(findings, newLocationFinding("proxy-timeout-policy", SeverityWarning, server, loc,
+						"%v of location %q is %v, over the maximum of %v of the cluster; slow backends hold the connections and the workers of nginx",
+						t.name, loc.Path, timeout, t.max))
+				}
+			}
+
+			if proxy.ConnectTimeout == 0 {
+				findings = append(findings, newLocationFinding("proxy-connect-timeout-zero", SeverityWarning, server, loc,
+					"proxy-connect-timeout of location %q is 0; nginx gives up the connections to the backend before they are established", loc.Path))
+			}
+
+			nextUpstream := strings.Fields(proxy.NextUpstream)
+			if containsString(nextUpstream, "non_idempotent") && !containsString(nextUpstream, "off") && proxy.NextUpstreamTries != 1 {
+				findings = append(findings, newLocationFinding("proxy-next-upstream-non-idempotent", SeverityWarning, server, loc,
+					"proxy-next-upstream %q of location %q retries the POST, PATCH and LOCK requests on another endpoint; a request that failed after reaching the backend is processed twice",
+					proxy.NextUpstream, loc.Path))
+			}
+		}
+	}
+
+	return findings
+}
+
+// check returns an error if a maximum is negative
+func (p *proxyTimeoutPolicy) check() error {
+	for _, max := range []*metav1.Duration{p.MaxReadTimeout, p.MaxSendTimeout} {
+		if max != nil && max.Duration < 0 {
+			return fmt.Errorf("the maximum proxy timeouts can not be negative")
+		}
+	}
+	return nil
+}
+
+// merge returns the maximums of p overridden by the ones set in other
+func (p *proxyTimeoutPolicy) merge(other *proxyTimeoutPolicy) *proxyTimeoutPolicy {
+	merged := *p
+	if other.MaxReadTimeout != nil {
+		merged.MaxReadTimeout = other.MaxReadTimeout
+	}
+	if other.MaxSendTimeout != nil {
+		merged.MaxSendTimeout = other.MaxSendTimeout
+	}
+	return &merged
+}
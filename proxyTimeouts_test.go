@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// proxyTimeoutsManifests is completed with the annotations of the Ingress
+const proxyTimeoutsManifests = `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+  annotations:
+%v
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`
+
+func TestCheckProxyTimeouts(t *testing.T) {
+	testCases := map[string]struct {
+		annotations string
+		expected    []string
+	}{
+		"defaults": {
+			annotations: `    nginx.ingress.kubernetes.io/ssl-redirect: "false"`,
+		},
+		"timeouts within the policy": {
+			annotations: `    nginx.ingress.kubernetes.io/proxy-read-timeout: "300"
+    nginx.ingress.kubernetes.io/proxy-send-timeout: "60"`,
+		},
+		"timeouts over the policy": {
+			annotations: `    nginx.ingress.kubernetes.io/proxy-read-timeout: "3600"
+    nginx.ingress.kubernetes.io/proxy-send-timeout: "600"`,
+			expected: []string{"proxy-timeout-policy", "proxy-timeout-policy"},
+		},
+		"connect timeout zero": {
+			annotations: `    nginx.ingress.kubernetes.io/proxy-connect-timeout: "0"`,
+			expected:    []string{"proxy-connect-timeout-zero"},
+		},
+		"non idempotent retries": {
+			annotations: `    nginx.ingress.kubernetes.io/proxy-next-upstream: error timeout non_idempotent`,
+			expected:    []string{"proxy-next-upstream-non-idempotent"},
+		},
+		"non idempotent without retries": {
+			annotations: `    nginx.ingress.kubernetes.io/proxy-next-upstream: error timeout non_idempotent
+    nginx.ingress.kubernetes.io/proxy-next-upstream-tries: "1"`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, fmt.Sprintf(proxyTimeoutsManifests, tc.annotations))
+			n.cfg.MaxProxyReadTimeout = 5 * time.Minute
+			n.cfg.MaxProxySendTimeout = 5 * time.Minute
+			rules := []string{}
+			for _, f := range n.checkProxyTimeouts(ingresses, cfg) {
+				rules = append(rules, f.Rule)
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(rules, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, rules)
+			}
+		})
+	}
+}
+
+func TestCheckProxyTimeoutsMessage(t *testing.T) {
+	n, ingresses, cfg := testConfiguration(t, fmt.Sprintf(proxyTimeoutsManifests, `    nginx.ingress.kubernetes.io/proxy-read-timeout: "3600"`))
+	if findings := n.checkProxyTimeouts(ingresses, cfg); len(findings) != 0 {
+		t.Errorf("expected no finding without policy, got %+v", findings)
+	}
+
+	n.cfg.MaxProxyReadTimeout = 5 * time.Minute
+	findings := n.checkProxyTimeouts(ingresses, cfg)
+	expected := `proxy-read-timeout of location "/" is 1h0m0s, over the maximum of 5m0s of the cluster; slow backends hold the connections and the workers of nginx`
+	if len(findings) != 1 || findings[0].Ingress != "default/web" || findings[0].Message != expected {
+		t.Errorf("expected %q on default/web, got %+v", expected, findings)
+	}
+}
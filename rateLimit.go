@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// defaultBurstMultiplier is used by ingress-nginx when limit-burst-multiplier
+// is not set
+const defaultBurstMultiplier = 5
+
+// checkRateLimits validates the rate limit annotations. ingress-nginx ignores
+// values that are not numbers and does not check how the limits combine, so
+// mistakes generate zones that do not limit what was expected.
+func (n *NGINXController) checkRateLimits(ingresses []*Ingress, _ *Configuration) []Finding {
+	findings := []Finding{}
+
+	for _, ing := range ingresses {
+		key := k8s.MetaNamespaceKey(ing)
+		report := func(severity Severity, format string, args ...interface{}) {
+			findings = append(findings, Finding{
+				Rule:     "ratelimit",
+				Severity: severity,
+				Ingress:  key,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		}
+
+		anns := ing.GetAnnotations()
+		values := map[string]int{}
+		for _, name := range []string{"limit-connections", "limit-rps", "limit-rpm", "limit-burst-multiplier", "limit-rate", "limit-rate-after"} {
+			raw, ok := anns[parser.GetAnnotationWithPrefix(name)]
+			if !ok {
+				continue
+			}
+			v, err := strconv.Atoi(strings.TrimSpace(raw))
+			switch {
+			case err != nil:
+				report(SeverityError, "%v %q is not an integer; ingress-nginx ignores it and the location is not limited", name, raw)
+				continue
+			case v < 0:
+				report(SeverityError, "%v is negative (%d); ingress-nginx ignores it and the location is not limited", name, v)
+				continue
+			case v == 0:
+				report(SeverityWarning, "%v is 0, which disables the limit", name)
+			}
+			values[name] = v
+		}
+
+		multiplier := defaultBurstMultiplier
+		if v, ok := values["limit-burst-multiplier"]; ok {
+			if v == 0 {
+				report(SeverityError, "limit-burst-multiplier 0 makes every request over the rate fail, without burst")
+			}
+			multiplier = v
+		}
+
+		rps, rpm, connections := values["limit-rps"], values["limit-rpm"], values["limit-connections"]
+		if _, ok := values["limit-burst-multiplier"]; ok && rps == 0 && rpm == 0 && connections == 0 {
+			report(SeverityWarning, "limit-burst-multiplier has no effect without limit-rps, limit-rpm or limit-connections")
+		}
+
+		if rps > 0 && rpm > 0 {
+			switch {
+			case rpm >= rps*60:
+				report(SeverityWarning, "limit-rpm %d is never reached because limit-rps %d allows at most %d requests per minute", rpm, rps, rps*60)
+			case rpm < rps:
+				report(SeverityWarning, "limit-rpm %d is lower than limit-rps %d; the per-second limit is never reached", rpm, rps)
+			}
+		}
+
+		// every limit-rpm bursts over the rate with the default multiplier,
+		// only a multiplier chosen for the Ingress is reported
+		if _, ok := values["limit-burst-multiplier"]; ok && rpm > 0 && multiplier > 1 {
+			report(SeverityInfo, "limit-rpm %d with limit-burst-multiplier %d lets a client send %d requests at once, more than the limit per minute",
+				rpm, multiplier, rpm*multiplier)
+		}
+
+		if connections > 0 && rps > 0 && connections > rps*multiplier {
+			report(SeverityInfo, "limit-connections %d is higher than the requests allowed by limit-rps with burst (%d); the connection limit is never reached by short requests",
+				connections, rps*multiplier)
+		}
+
+		if _, ok := values["limit-rate-after"]; ok {
+			if values["limit-rate"] == 0 {
+				report(SeverityWarning, "limit-rate-after has no effect without limit-rate")
+			}
+		}
+		if values["limit-rate"] > 0 && ing.ParsedAnnotations != nil && ing.ParsedAnnotations.Proxy.ProxyBuffering == "off" {
+			report(SeverityWarning, "limit-rate has no effect because proxy-buffering is off")
+		}
+
+		if raw, ok := anns[parser.GetAnnotationWithPrefix("limit-allowlist")]; ok {
+			for _, cidr := range strings.Split(raw, ",") {
+				cidr = strings.TrimSpace(cidr)
+				if cidr == "" {
+					continue
+				}
+				if _, _, err := net.ParseCIDR(cidr); err != nil && net.ParseIP(cidr) == nil {
+					report(SeverityError, "invalid address %q in limit-allowlist; the rate limits are not applied", cidr)
+				}
+			}
+			if rps == 0 && rpm == 0 && connections == 0 {
+				report(SeverityWarning, "limit-allowlist has no effect without limit-rps, limit-rpm or limit-connections")
+			}
+		}
+	}
+
+	return findings
+}
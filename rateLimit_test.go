@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// rateLimitIngress returns the manifest of an Ingress with the annotations
+func rateLimitIngress(annotations map[string]string) string {
+	manifest := `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+  annotations:
+`
+	for k, v := range annotations {
+		manifest += fmt.Sprintf("    nginx.ingress.kubernetes.io/%v: %q\n", k, v)
+	}
+	return manifest + "spec:\n  ingressClassName: nginx\n"
+}
+
+func TestCheckRateLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		// expected holds the severity and the first word of each message
+		expected []string
+	}{
+		{
+			name:        "rps limit",
+			annotations: map[string]string{"limit-rps": "10"},
+			expected:    []string{},
+		},
+		{
+			name:        "not a number",
+			annotations: map[string]string{"limit-rps": "10r/s"},
+			expected:    []string{"error limit-rps"},
+		},
+		{
+			name:        "negative",
+			annotations: map[string]string{"limit-connections": "-1"},
+			expected:    []string{"error limit-connections"},
+		},
+		{
+			name:        "zero",
+			annotations: map[string]string{"limit-rps": "0"},
+			expected:    []string{"warning limit-rps"},
+		},
+		{
+			name:        "zero burst multiplier",
+			annotations: map[string]string{"limit-rps": "10", "limit-burst-multiplier": "0"},
+			expected:    []string{"warning limit-burst-multiplier", "error limit-burst-multiplier"},
+		},
+		{
+			name:        "burst multiplier without limit",
+			annotations: map[string]string{"limit-burst-multiplier": "2"},
+			expected:    []string{"warning limit-burst-multiplier"},
+		},
+		{
+			name:        "rpm never reached",
+			annotations: map[string]string{"limit-rps": "1", "limit-rpm": "60", "limit-burst-multiplier": "1"},
+			expected:    []string{"warning limit-rpm"},
+		},
+		{
+			name:        "rpm lower than rps",
+			annotations: map[string]string{"limit-rps": "10", "limit-rpm": "5", "limit-burst-multiplier": "1"},
+			expected:    []string{"warning limit-rpm"},
+		},
+		{
+			name:        "rpm with the default burst multiplier",
+			annotations: map[string]string{"limit-rpm": "100"},
+			expected:    []string{},
+		},
+		{
+			name:        "rpm with a burst multiplier",
+			annotations: map[string]string{"limit-rpm": "100", "limit-burst-multiplier": "3"},
+			expected:    []string{"info limit-rpm"},
+		},
+		{
+			name:        "rpm without burst",
+			annotations: map[string]string{"limit-rpm": "100", "limit-burst-multiplier": "1"},
+			expected:    []string{},
+		},
+		{
+			name:        "connections never reached",
+			annotations: map[string]string{"limit-rps": "2", "limit-connections": "20"},
+			expected:    []string{"info limit-connections"},
+		},
+		{
+			name:        "rate after without rate",
+			annotations: map[string]string{"limit-rate-after": "1024"},
+			expected:    []string{"warning limit-rate-after"},
+		},
+		{
+			name:        "rate without proxy buffering",
+			annotations: map[string]string{"limit-rate": "1024", "proxy-buffering": "off"},
+			expected:    []string{"warning limit-rate"},
+		},
+		{
+			name:        "allowlist",
+			annotations: map[string]string{"limit-rps": "10", "limit-allowlist": "10.0.0.0/8, 192.168.1.1"},
+			expected:    []string{},
+		},
+		{
+			name:        "invalid allowlist",
+			annotations: map[string]string{"limit-allowlist": "10.0.0.0/33"},
+			expected:    []string{"error invalid", "warning limit-allowlist"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, rateLimitIngress(tc.annotations))
+
+			got := []string{}
+			for _, f := range n.checkRateLimits(ingresses, cfg) {
+				if f.Rule != "ratelimit" {
+					t.Errorf("unexpected rule %v", f.Rule)
+				}
+				got = append(got, fmt.Sprintf("%v %v", f.Severity, strings.Fields(f.Message)[0]))
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+)
+
+// redirectAnnotations are the annotations redirecting the locations, with the
+// annotation of their code and its default
+var redirectAnnotations = []struct {
+	name        string
+	code        string
+	defaultCode int
+}{
+	{"permanent-redirect", "permanent-redirect-code", 301},
+	{"temporal-redirect", "temporal-redirect-code", 302},
+}
+
+// redirectShadowedAnnotations configure the proxying of the requests to the
+// backend, which the return of the redirect skips
+var redirectShadowedAnnotations = []string{
+	"rewrite-target",
+	"upstream-vhost",
+	"x-forwarded-prefix",
+	"backend-protocol",
+	"custom-headers",
+	"mirror-target",
+	"service-upstream",
+	"load-balance",
+	"upstream-hash-by",
+	"affinity",
+}
+
+// redirectCode returns the problem of a redirect code annotation, empty when
+// nginx redirects with it
+func redirectCode(value string, defaultCode int) (Severity, string) {
+	code, err := strconv.Atoi(value)
+	switch {
+	case err != nil || code < 300 || code > 308:
+		return SeverityError, fmt.Sprintf("%q is not a redirect code; the ingress controller uses %d", value, defaultCode)
+	case code == 303:
+		return SeverityWarning, "303 makes the clients repeat the request with GET; use 307 to keep the method"
+	case code != 301 && code != 302 && code != 307 && code != 308:
+		return SeverityError, fmt.Sprintf("%d is not a redirect; nginx sends the URL as the body of the response", code)
+	}
+	return "", ""
+}
+
+// wwwAlternate returns the host redirected to the host by from-to-www-redirect
+func wwwAlternate(host string) string {
+	if strings.HasPrefix(host, "www.") {
+		return strings.TrimPrefix(host, "www.")
+	}
+	return "www." + host
+}
+
+// checkRedirects validates the redirect annotations of the locations and
+// detects the redirects going through several hosts of the configuration or
+// back to their location
+func (n *NGINXController) checkRedirects(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	checked := map[*Ingress]bool{}
+
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			if loc.Ingress != nil && !checked[loc.Ingress] {
+				checked[loc.Ingress] = true
+				findings = append(findings, redirectAnnotationFindings(server, loc)...)
+			}
+
+			if loc.Redirect.URL == "" {
+				continue
+			}
+			if shadowed := redirectShadowed(loc); len(shadowed) > 0 {
+				findings = append(findings, newLocationFinding("redirect-rewrite-conflict", SeverityWarning, server, loc,
+					"location %q redirects every request with %d to %v before they are rewritten or proxied; backend %v is never reached and %v have no effect",
+					loc.Path, loc.Redirect.Code, loc.Redirect.URL, loc.Backend, strings.Join(shadowed, ", ")))
+			}
+
+			target, err := parseNginxURL(loc.Redirect.URL)
+			if err != nil || target.dynamicHost {
+				continue
+			}
+			if f := redirectLoop(cfg, server, loc, target); f != nil {
+				findings = append(findings, *f)
+			}
+		}
+	}
+
+	return findings
+}
+
+// redirectAnnotationFindings validates the redirect annotations of the
+// Ingress of the location, which the ingress controller ignores when invalid
+func redirectAnnotationFindings(server *Server, loc *Location) []Finding {
+	findings := []Finding{}
+	anns := loc.Ingress.Annotations
+
+	set := []string{}
+	for _, r := range redirectAnnotations {
+		value, ok := anns[parser.GetAnnotationWithPrefix(r.name)]
+		if !ok {
+			continue
+		}
+		set = append(set, r.name)
+		if _, err := parseNginxURL(value); err != nil {
+			findings = append(findings, newLocationFinding("redirect-invalid", SeverityError, server, loc,
+				"%v %q is not a valid URL: %v; the ingress controller ignores it", r.name, value, err))
+		}
+		if code, ok := anns[parser.GetAnnotationWithPrefix(r.code)]; ok {
+			if severity, problem := redirectCode(code, r.defaultCode); problem != "" {
+				findings = append(findings, newLocationFinding("redirect-invalid", severity, server, loc,
+					"%v: %v", r.code, problem))
+			}
+		}
+	}
+	if len(set) > 1 {
+		findings = append(findings, newLocationFinding("redirect-conflict", SeverityWarning, server, loc,
+			"both %v are set; the ingress controller only uses temporal-redirect", strings.Join(set, " and ")))
+	}
+
+	return findings
+}
+
+// redirectShadowed returns the annotations of the Ingress of a redirected
+// location that only apply to the requests sent to the backend
+func redirectShadowed(loc *Location) []string {
+	if loc.Ingress == nil {
+		return nil
+	}
+	shadowed := []string{}
+	proxy := parser.GetAnnotationWithPrefix("proxy-")
+	for name := range loc.Ingress.Annotations {
+		if strings.HasPrefix(name, proxy) {
+			shadowed = append(shadowed, strings.TrimPrefix(name, parser.GetAnnotationWithPrefix("")))
+		}
+	}
+	sort.Strings(shadowed)
+	for _, name := range redirectShadowedAnnotations {
+		if _, ok := loc.Ingress.Annotations[parser.GetAnnotationWithPrefix(name)]; ok {
+			shadowed = append(shadowed, name)
+		}
+	}
+	return shadowed
+}
+
+// redirectLoop follows the redirects from a location through the hosts of
+// the configuration, including the from-to-www-redirect of the servers, and
+// returns a finding when the clients are redirected several times or back to
+// the location
+func redirectLoop(cfg *Configuration, server *Server, loc *Location, target *nginxURL) *Finding {
+	chain := []string{fmt.Sprintf("%v%v", server.Hostname, loc.Path)}
+	visited := map[*Location]bool{loc: true}
+	redirects := 1
+
+	host, path := target.url.Hostname(), nginxURLPath(loc.Redirect.URL, target.url, loc)
+	for {
+		if findServer(cfg, host) == nil {
+			// the server of the other host redirects to this one
+			for _, s := range cfg.Servers {
+				if s.RedirectFromToWWW && wwwAlternate(s.Hostname) == host {
+					chain = append(chain, host)
+					host = s.Hostname
+					redirects++
+					break
+				}
+			}
+		}
+
+		next, _ := routeServer(cfg, host)
+		if next == nil || next.Hostname == "_" {
+			break
+		}
+		nextLoc := matchLocation(next, path)
+		if nextLoc == nil {
+			break
+		}
+		chain = append(chain, fmt.Sprintf("%v%v", next.Hostname, nextLoc.Path))
+
+		if visited[nextLoc] {
+			f := newLocationFinding("redirect-loop", SeverityError, server, loc,
+				"redirect of location %q to %v is a loop (%v); the clients give up with too many redirects",
+				loc.Path, loc.Redirect.URL, strings.Join(chain, " -> "))
+			return &f
+		}
+		visited[nextLoc] = true
+
+		if nextLoc.Redirect.URL == "" {
+			break
+		}
+		nextTarget, err := parseNginxURL(nextLoc.Redirect.URL)
+		if err != nil || nextTarget.dynamicHost {
+			break
+		}
+		redirects++
+		host, path = nextTarget.url.Hostname(), nginxURLPath(nextLoc.Redirect.URL, nextTarget.url, nextLoc)
+	}
+
+	if redirects == 1 {
+		return nil
+	}
+	f := newLocationFinding("redirect-chain", SeverityWarning, server, loc,
+		"redirect of location %q to %v goes through %d redirects (%v); redirect to the final URL directly",
+		loc.Path, loc.Redirect.URL, redirects, strings.Join(chain, " -> "))
+	return &f
+}
+
+// serverCertificate returns the Secret of the certificate of a server
+func serverCertificate(server *Server) string {
+	if server.SSLCert == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%v/%v", server.SSLCert.Namespace, server.SSLCert.Name)
+}
+
+// serverBackends returns the backends of the locations of a server
+func serverBackends(server *Server) []string {
+	backends := map[string]bool{}
+	for _, loc := range server.Locations {
+		backends[loc.Backend] = true
+	}
+	return sortedSet(backends)
+}
+
+// checkFromToWWW reports the servers whose from-to-www-redirect host is also
+// defined by another Ingress with a different certificate or backends. The
+// template skips the redirect of the hosts defined by a server, as
+// redirectLoop does, so the other Ingress serves the host instead of the
+// expected redirect.
+func (n *NGINXController) checkFromToWWW(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+
+	for _, server := range cfg.Servers {
+		if !server.RedirectFromToWWW {
+			continue
+		}
+		alternate := wwwAlternate(server.Hostname)
+		other := findServer(cfg, alternate)
+		if other == nil || other == server {
+			continue
+		}
+
+		differences := []string{}
+		if cert, otherCert := serverCertificate(server), serverCertificate(other); cert != otherCert {
+			differences = append(differences, fmt.Sprintf("certificate %v instead of %v", otherCert, cert))
+		}
+		backends, otherBackends := serverBackends(server), serverBackends(other)
+		if strings.Join(backends, ",") != strings.Join(otherBackends, ",") {
+			differences = append(differences, fmt.Sprintf("backends %v instead of %v",
+				strings.Join(otherBackends, ", "), strings.Join(backends, ", ")))
+		}
+		if len(differences) == 0 {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Rule:     "from-to-www-collision",
+			Severity: SeverityWarning,
+			Ingress:  serverIngress(server),
+			Host:     server.Hostname,
+			Message: fmt.Sprintf("from-to-www-redirect of %v is skipped because Ingress %v defines the host; the requests for %v are served by that Ingress with %v",
+				alternate, serverIngress(other), alternate, strings.Join(differences, " and ")),
+		})
+	}
+
+	return findings
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedirectCode(t *testing.T) {
+	for value, expected := range map[string]Severity{
+		"301":   "",
+		"308":   "",
+		"303":   SeverityWarning,
+		"305":   SeverityError,
+		"200":   SeverityError,
+		"moved": SeverityError,
+	} {
+		if severity, problem := redirectCode(value, 301); severity != expected || (problem == "") != (expected == "") {
+			t.Errorf("expected %q for %v, got %q %q", expected, value, severity, problem)
+		}
+	}
+}
+
+func TestWWWAlternate(t *testing.T) {
+	if got := wwwAlternate("example.com"); got != "www.example.com" {
+		t.Errorf("expected www.example.com, got %v", got)
+	}
+	if got := wwwAlternate("www.example.com"); got != "example.com" {
+		t.Errorf("expected example.com, got %v", got)
+	}
+}
+
+func TestRedirectShadowed(t *testing.T) {
+	loc := &Location{Ingress: &Ingress{}}
+	loc.Ingress.Annotations = map[string]string{
+		"nginx.ingress.kubernetes.io/permanent-redirect": "https://new.example.com",
+		"nginx.ingress.kubernetes.io/proxy-body-size":    "8m",
+		"nginx.ingress.kubernetes.io/proxy-buffering":    "on",
+		"nginx.ingress.kubernetes.io/affinity":           "cookie",
+		"nginx.ingress.kubernetes.io/rewrite-target":     "/app",
+		"nginx.ingress.kubernetes.io/ssl-redirect":       "false",
+	}
+	expected := []string{"proxy-body-size", "proxy-buffering", "rewrite-target", "affinity"}
+	if got := redirectShadowed(loc); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+	if got := redirectShadowed(&Location{}); got != nil {
+		t.Errorf("expected nothing without Ingress, got %v", got)
+	}
+}
+
+// redirectIngress returns an Ingress of host with the annotations
+func redirectIngress(name, host, annotations string) string {
+	return fmt.Sprintf(`
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: %v
+  namespace: default
+  annotations:
+%v
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: %v
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: %v
+            port:
+              number: 80
+`, name, annotations, host, name)
+}
+
+func TestCheckRedirects(t *testing.T) {
+	testCases := map[string]struct {
+		manifests string
+		expected  []string
+	}{
+		"single redirect": {
+			manifests: redirectIngress("old", "old.example.com", `    nginx.ingress.kubernetes.io/permanent-redirect: https://new.example.com$request_uri
+    nginx.ingress.kubernetes.io/permanent-redirect-code: "308"`) +
+				redirectIngress("new", "new.example.com", `    nginx.ingress.kubernetes.io/ssl-redirect: "false"`),
+		},
+		"invalid URL": {
+			manifests: redirectIngress("old", "old.example.com", `    nginx.ingress.kubernetes.io/permanent-redirect: new.example.com`),
+			expected:  []string{"redirect-invalid error old.example.com"},
+		},
+		"invalid codes": {
+			manifests: redirectIngress("old", "old.example.com", `    nginx.ingress.kubernetes.io/temporal-redirect: https://new.example.com
+    nginx.ingress.kubernetes.io/temporal-redirect-code: "303"`) +
+				redirectIngress("legacy", "legacy.example.com", `    nginx.ingress.kubernetes.io/permanent-redirect: https://new.example.com
+    nginx.ingress.kubernetes.io/permanent-redirect-code: "200"`),
+			expected: []string{"redirect-invalid error legacy.example.com", "redirect-invalid warning old.example.com"},
+		},
+		"both redirects": {
+			manifests: redirectIngress("old", "old.example.com", `    nginx.ingress.kubernetes.io/permanent-redirect: https://new.example.com
+    nginx.ingress.kubernetes.io/temporal-redirect: https://maintenance.example.com`),
+			expected: []string{"redirect-conflict warning old.example.com"},
+		},
+		"chain": {
+			manifests: redirectIngress("old", "old.example.com", `    nginx.ingress.kubernetes.io/permanent-redirect: https://legacy.example.com$request_uri`) +
+				redirectIngress("legacy", "legacy.example.com", `    nginx.ingress.kubernetes.io/permanent-redirect: https://new.example.com$request_uri`) +
+				redirectIngress("new", "new.example.com", `    nginx.ingress.kubernetes.io/ssl-redirect: "false"`),
+			expected: []string{"redirect-chain warning old.example.com"},
+		},
+		"chain through from-to-www-redirect": {
+			manifests: redirectIngress("old", "old.example.com", `    nginx.ingress.kubernetes.io/permanent-redirect: https://www.example.com/`) +
+				redirectIngress("web", "example.com", `    nginx.ingress.kubernetes.io/from-to-www-redirect: "true"`),
+			expected: []string{"redirect-chain warning old.example.com"},
+		},
+		"proxy annotations": {
+			manifests: redirectIngress("old", "old.example.com", `    nginx.ingress.kubernetes.io/permanent-redirect: https://new.example.com
+    nginx.ingress.kubernetes.io/proxy-read-timeout: "120"
+    nginx.ingress.kubernetes.io/rewrite-target: /app`),
+			expected: []string{"redirect-rewrite-conflict warning old.example.com"},
+		},
+		"loop": {
+			manifests: redirectIngress("old", "old.example.com", `    nginx.ingress.kubernetes.io/permanent-redirect: https://new.example.com$request_uri`) +
+				redirectIngress("new", "new.example.com", `    nginx.ingress.kubernetes.io/permanent-redirect: https://old.example.com$request_uri`),
+			expected: []string{"redirect-loop error new.example.com", "redirect-loop error old.example.com"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, tc.manifests)
+			findings := []string{}
+			for _, f := range n.checkRedirects(ingresses, cfg) {
+				findings = append(findings, fmt.Sprintf("%v %v %v", f.Rule, f.Severity, f.Host))
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(findings, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, findings)
+			}
+		})
+	}
+
+	// the hosts of the chain are reported
+	n, ingresses, cfg := testConfiguration(t, testCases["chain through from-to-www-redirect"].manifests)
+	findings := n.checkRedirects(ingresses, cfg)
+	if len(findings) != 1 || !strings.Contains(findings[0].Message, "(old.example.com/ -> www.example.com -> example.com/)") {
+		t.Errorf("expected the chain through www.example.com, got %+v", findings)
+	}
+
+	n, ingresses, cfg = testConfiguration(t, testCases["proxy annotations"].manifests)
+	findings = n.checkRedirects(ingresses, cfg)
+	expected := `location "/" redirects every request with 301 to https://new.example.com before they are rewritten or proxied; backend default-old-80 is never reached and proxy-read-timeout, rewrite-target have no effect`
+	if len(findings) != 1 || findings[0].Message != expected {
+		t.Errorf("expected %q, got %+v", expected, findings)
+	}
+}
+
+func TestCheckFromToWWW(t *testing.T) {
+	redirect := redirectIngress("web", "example.com", `    nginx.ingress.kubernetes.io/from-to-www-redirect: "true"`)
+	testCases := map[string]struct {
+		manifests string
+		expected  []string
+	}{
+		"no other Ingress": {
+			manifests: redirect,
+		},
+		"other Ingress with the same backend": {
+			manifests: redirect + strings.Replace(redirectIngress("www", "www.example.com", `    nginx.ingress.kubernetes.io/ssl-redirect: "false"`),
+				"            name: www\n", "            name: web\n", 1),
+		},
+		"other Ingress with another backend": {
+			manifests: redirect + redirectIngress("www", "www.example.com", `    nginx.ingress.kubernetes.io/ssl-redirect: "false"`),
+			expected: []string{
+				"example.com: from-to-www-redirect of www.example.com is skipped because Ingress default/www defines the host; " +
+					"the requests for www.example.com are served by that Ingress with backends default-www-80 instead of default-web-80",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, tc.manifests)
+			findings := []string{}
+			for _, f := range n.checkFromToWWW(ingresses, cfg) {
+				findings = append(findings, f.Host+": "+f.Message)
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(findings, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, findings)
+			}
+		})
+	}
+}
+
+func TestServerCertificate(t *testing.T) {
+	if got := serverCertificate(&Server{}); got != "none" {
+		t.Errorf("expected none, got %v", got)
+	}
+	server := &Server{SSLCert: &SSLCert{}}
+	server.SSLCert.Namespace, server.SSLCert.Name = "default", "web-tls"
+	if got := serverCertificate(server); got != "default/web-tls" {
+		t.Errorf("expected default/web-tls, got %v", got)
+	}
+	server.Locations = []*Location{{Backend: "b"}, {Backend: "a"}, {Backend: "b"}}
+	if got := serverBackends(server); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("expected the backends once, got %v", got)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+)
+
+// renderHelmChart renders the chart using helm template and returns the
+// resulting manifests
+func renderHelmChart(chart, release, namespace string, values []string) ([]byte, error) {
+	args := []string{"template", release, chart, "--namespace", namespace}
+	for _, v := range values {
+		args = append(args, "--values", v)
+	}
+
+	return runRenderer("helm", args...)
+}
+
+// renderKustomization builds the kustomize overlay in dir, using the
+// kustomize binary or kubectl when kustomize is not installed
+func renderKustomization(dir string) ([]byte, error) {
+	if _, err := exec.LookPath("kustomize"); err == nil {
+		return runRenderer("kustomize", "build", dir)
+	}
+
+	return runRenderer("kubectl", "kustomize", dir)
+}
+
+func runRenderer(name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	defer profileExec(name)()
+	//nolint:gosec // Ignore G204 error
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%v %v: %w\n%v", name, args[0], err, stderr.String())
+	}
+
+	return stdout.Bytes(), nil
+}
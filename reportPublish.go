@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// destinations of the validation reports
+const (
+	reportSinkNone      = ""
+	reportSinkConfigMap = "configmap"
+	reportSinkCRD       = "crd"
+)
+
+const (
+	// defaultReportInterval is the interval between publications of the
+	// reports
+	defaultReportInterval = 30 * time.Second
+	// maxReportFindings limits the findings stored per Ingress, ConfigMaps
+	// and objects are limited to 1MiB
+	maxReportFindings = 50
+	// maxReportConfigMapSize limits the size of the reports written to the
+	// ConfigMap, the oldest reports are left out above it
+	maxReportConfigMapSize = 900 * 1024
+	// maxOrphanReportAge is how long the report of an Ingress missing from
+	// the cluster state, e.g. a rejected CREATE, is kept
+	maxOrphanReportAge = time.Hour
+)
+
+// ingressValidationReportResource is the IngressValidationReport CRD. A
+// report has the namespace and name of its Ingress.
+var ingressValidationReportResource = schema.GroupVersionResource{
+	Group:    "nginx-config-validator.justice.gov.uk",
+	Version:  "v1alpha1",
+	Resource: "ingressvalidationreports",
+}
+
+// IngressValidationReport is the result of the last validation of an Ingress
+type IngressValidationReport struct {
+	Ingress string    `json:"ingress"`
+	Time    time.Time `json:"time"`
+	// Checksum identifies the spec and annotations that were validated
+	Checksum string    `json:"checksum"`
+	Allowed  bool      `json:"allowed"`
+	Error    string    `json:"error,omitempty"`
+	Findings []Finding `json:"findings"`
+	// Truncated is the number of findings not included in the report
+	Truncated int `json:"truncated,omitempty"`
+}
+
+// reportPublisher collects the validation reports of the webhook and
+// publishes them periodically
+type reportPublisher struct {
+	sink      string
+	configMap string
+	client    dynamic.Interface
+	n         *NGINXController
+
+	lock    sync.Mutex
+	reports map[string]*IngressValidationReport
+	// pending contains the keys of the reports not published yet
+	pending map[string]bool
+}
+
+// newReportPublisher returns the publisher configured by ReportSink, or nil
+// when the reports are not published
+func (n *NGINXController) newReportPublisher(client dynamic.Interface) (*reportPublisher, error) {
+	switch n.cfg.ReportSink {
+	case reportSinkNone:
+		return nil, nil
+	case reportSinkConfigMap:
+		if _, _, err := k8s.ParseNameNS(n.cfg.ReportConfigMap); err != nil {
+			return nil, fmt.Errorf("--report-configmap must use the format namespace/name: %w", err)
+		}
+	case reportSinkCRD:
+		if client == nil {
+			return nil, fmt.Errorf("the %v report sink requires a dynamic client", reportSinkCRD)
+		}
+	default:
+		return nil, fmt.Errorf("unknown report sink %q, expected %v or %v", n.cfg.ReportSink, reportSinkConfigMap, reportSinkCRD)
+	}
+
+	return &reportPublisher{
+		sink:      n.cfg.ReportSink,
+		configMap: n.cfg.ReportConfigMap,
+		client:    client,
+		n:         n,
+		reports:   map[string]*IngressValidationReport{},
+		pending:   map[string]bool{},
+	}, nil
+}
+
+// recordClusterReport records the result of the validation of an Ingress of
+// the cluster state, so the report of the leader covers the Ingresses
+// admitted by the other replicas
+func (n *NGINXController) recordClusterReport(ing *networking.Ingress, findings []Finding) {
+	var err error
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			err = fmt.Errorf("ingress %v: %v: %v", k8s.MetaNamespaceKey(ing), f.Rule, f.Message)
+			break
+		}
+	}
+	n.reports.record(ing, findings, err)
+}
+
+// record stores the result of the validation of an Ingress
+func (p *reportPublisher) record(ing *networking.Ingress, findings []Finding, err error) {
+	if p == nil {
+		return
+	}
+
+	key := k8s.MetaNamespaceKey(ing)
+	report := &IngressValidationReport{
+		Ingress:  key,
+		Time:     time.Now().UTC(),
+		Checksum: ingressChecksum(ing),
+		Allowed:  err == nil,
+		Findings: findings,
+	}
+	if report.Findings == nil {
+		report.Findings = []Finding{}
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	if len(report.Findings) > maxReportFindings {
+		report.Truncated = len(report.Findings) - maxReportFindings
+		report.Findings = report.Findings[:maxReportFindings]
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.reports[key] = report
+	p.pending[key] = true
+}
+
+// Run publishes the pending reports every interval until stopCh is closed.
+// The reports still pending are published by the shutdown of the controller.
+func (p *reportPublisher) Run(interval time.Duration, stopCh chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			p.publish()
+		}
+	}
+}
+
+// publish writes the pending reports to the sink. Reports failing to be
+// published stay pending.
+func (p *reportPublisher) publish() {
+	p.lock.Lock()
+	if len(p.pending) == 0 {
+		p.lock.Unlock()
+		return
+	}
+	p.prune(time.Now())
+	pending := p.pending
+	p.pending = map[string]bool{}
+	reports := make(map[string]*IngressValidationReport, len(p.reports))
+	for k, v := range p.reports {
+		reports[k] = v
+	}
+	p.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), statusUpdateTimeout)
+	defer cancel()
+
+	var failed []string
+	switch p.sink {
+	case reportSinkConfigMap:
+		if err := p.publishConfigMap(ctx, reports); err != nil {
+			klog.Warningf("Error publishing the validation reports to ConfigMap %v: %v", p.configMap, err)
+			failed = sortedSet(pending)
+		}
+	case reportSinkCRD:
+		for _, key := range sortedSet(pending) {
+			if err := p.publishCRD(ctx, reports[key]); err != nil {
+				klog.Warningf("Error publishing the validation report of Ingress %v: %v", key, err)
+				failed = append(failed, key)
+			}
+		}
+	}
+
+	p.lock.Lock()
+	for _, key := range failed {
+		p.pending[key] = true
+	}
+	p.lock.Unlock()
+	klog.V(2).Infof("Published %d validation reports", len(pending)-len(failed))
+}
+
+// prune forgets the reports of the Ingresses missing from the cluster state
+// for longer than maxOrphanReportAge. The caller holds the lock.
+func (p *reportPublisher) prune(now time.Time) {
+	for _, key := range pruneReports(p.reports, p.existingIngresses(), now) {
+		delete(p.pending, key)
+	}
+}
+
+// existingIngresses returns the keys of the Ingresses of the cluster state
+func (p *reportPublisher) existingIngresses() map[string]bool {
+	existing := map[string]bool{}
+	for _, ing := range p.n.store.ListIngresses() {
+		existing[k8s.MetaNamespaceKey(ing)] = true
+	}
+	return existing
+}
+
+// pruneReports removes the reports of the Ingresses not in existing for
+// longer than maxOrphanReportAge and returns their keys
+func pruneReports(reports map[string]*IngressValidationReport, existing map[string]bool, now time.Time) []string {
+	pruned := []string{}
+	for key, report := range reports {
+		if !existing[key] && now.Sub(report.Time) > maxOrphanReportAge {
+			delete(reports, key)
+			pruned = append(pruned, key)
+		}
+	}
+	return pruned
+}
+
+// publishConfigMap merges the reports with the ones already in the
+// ConfigMap, keeping the newest report of each Ingress, so the replicas and
+// the successive leaders share the ConfigMap without dropping the reports of
+// the others. The oldest reports are left out when they do not fit in
+// maxReportConfigMapSize.
+func (p *reportPublisher) publishConfigMap(ctx context.Context, reports map[string]*IngressValidationReport) error {
+	ns, name, _ := k8s.ParseNameNS(p.configMap)
+	client := p.n.cfg.Client.CoreV1().ConfigMaps(ns)
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := client.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			cm = nil
+		} else if err != nil {
+			return err
+		}
+
+		merged := map[string]*IngressValidationReport{}
+		if cm != nil {
+			for key, raw := range cm.Data {
+				report := &IngressValidationReport{}
+				if err := json.Unmarshal([]byte(raw), report); err != nil || report.Ingress == "" {
+					klog.Warningf("Ignoring invalid validation report %v in ConfigMap %v: %v", key, p.configMap, err)
+					continue
+				}
+				merged[report.Ingress] = report
+			}
+		}
+		for key, report := range reports {
+			if existing, ok := merged[key]; !ok || !existing.Time.After(report.Time) {
+				merged[key] = report
+			}
+		}
+		pruneReports(merged, p.existingIngresses(), time.Now())
+
+		data, err := p.configMapData(merged)
+		if err != nil {
+			return err
+		}
+
+		if cm == nil {
+			_, err = client.Create(ctx, &apiv1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+				Data:       data,
+			}, metav1.CreateOptions{})
+			return err
+		}
+		cm.Data = data
+		_, err = client.Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// configMapData returns the content of the ConfigMap, one key per Ingress,
+// the newest reports first until maxReportConfigMapSize is reached
+func (p *reportPublisher) configMapData(reports map[string]*IngressValidationReport) (map[string]string, error) {
+	keys := make([]string, 0, len(reports))
+	for key := range reports {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return reports[keys[i]].Time.After(reports[keys[j]].Time) })
+
+	data := make(map[string]string, len(reports))
+	size := 0
+	for i, key := range keys {
+		raw, err := json.Marshal(reports[key])
+		if err != nil {
+			return nil, err
+		}
+		// ConfigMap keys can not contain slashes
+		dataKey := strings.ReplaceAll(key, "/", "_") + ".json"
+		if size += len(dataKey) + len(raw); size > maxReportConfigMapSize {
+			klog.Warningf("The validation reports do not fit in ConfigMap %v, leaving out the %d oldest", p.configMap, len(keys)-i)
+			break
+		}
+		data[dataKey] = string(raw)
+	}
+	return data, nil
+}
+
+// publishCRD creates or updates the IngressValidationReport of an Ingress.
+// The report is owned by the Ingress, so it is deleted with it. Reports of
+// Ingresses that were never persisted, such as rejected CREATEs, are not
+// published: they would have no owner.
+func (p *reportPublisher) publishCRD(ctx context.Context, report *IngressValidationReport) error {
+	ns, name, err := k8s.ParseNameNS(report.Ingress)
+	if err != nil {
+		return err
+	}
+
+	ing, err := p.client.Resource(networking.SchemeGroupVersion.WithResource("ingresses")).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		klog.V(2).Infof("Ingress %v does not exist, skipping its validation report", report.Ingress)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	status, err := runtime.DefaultUnstructuredConverter.ToUnstructured(report)
+	if err != nil {
+		return err
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(ingressValidationReportResource.GroupVersion().String())
+	obj.SetKind("IngressValidationReport")
+	obj.SetNamespace(ns)
+	obj.SetName(name)
+	obj.SetOwnerReferences([]metav1.OwnerReference{{
+		APIVersion: networking.SchemeGroupVersion.String(),
+		Kind:       "Ingress",
+		Name:       name,
+		UID:        ing.GetUID(),
+	}})
+	if err := unstructured.SetNestedMap(obj.Object, status, "status"); err != nil {
+		return err
+	}
+
+	client := p.client.Resource(ingressValidationReportResource).Namespace(ns)
+	existing, err := client.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, obj, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	_, err = client.Update(ctx, obj, metav1.UpdateOptions{})
+	return err
+}
+
+// ingressChecksum returns the checksum of the spec and annotations of an
+// Ingress, the fields that determine the result of the validation
+func ingressChecksum(ing *networking.Ingress) string {
+	annotations := make([]string, 0, len(ing.Annotations))
+	for k, v := range ing.Annotations {
+		annotations = append(annotations, k+"="+v)
+	}
+	sort.Strings(annotations)
+
+	raw, err := json.Marshal(struct {
+		Spec        networking.IngressSpec `json:"spec"`
+		Annotations []string               `json:"annotations"`
+	}{ing.Spec, annotations})
+	if err != nil {
+		return ""
+	}
+	return sha1Hex(raw)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestNewReportPublisher(t *testing.T) {
+	tests := []struct {
+		sink, configMap string
+		dynamic         bool
+		valid           bool
+	}{
+		{sink: reportSinkNone, valid: true},
+		{sink: reportSinkConfigMap, configMap: "ingress-nginx/reports", valid: true},
+		{sink: reportSinkConfigMap, configMap: "reports"},
+		{sink: reportSinkCRD, dynamic: true, valid: true},
+		{sink: reportSinkCRD},
+		{sink: "elasticsearch"},
+	}
+
+	for _, tc := range tests {
+		n := newTestController(t, "")
+		n.cfg.ReportSink, n.cfg.ReportConfigMap = tc.sink, tc.configMap
+		var client dynamic.Interface
+		if tc.dynamic {
+			client = dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+		}
+		_, err := n.newReportPublisher(client)
+		if tc.valid != (err == nil) {
+			t.Errorf("sink %q: expected valid %v, got %v", tc.sink, tc.valid, err)
+		}
+	}
+}
+
+func TestReportPublisherConfigMap(t *testing.T) {
+	n := newTestController(t, "")
+	client := fake.NewSimpleClientset()
+	n.cfg.Client = client
+	n.cfg.ReportSink, n.cfg.ReportConfigMap = reportSinkConfigMap, "ingress-nginx/reports"
+	p, err := n.newReportPublisher(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	many := make([]Finding, maxReportFindings+3)
+	p.record(&networking.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}, many, errors.New("invalid"))
+	p.record(&networking.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}}, nil, nil)
+
+	// the reports stay pending while the ConfigMap can not be written
+	client.PrependReactor("create", "configmaps", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("forbidden")
+	})
+	p.publish()
+	if len(p.pending) != 2 {
+		t.Fatalf("expected two pending reports, got %v", p.pending)
+	}
+	client.ReactionChain = client.ReactionChain[1:]
+
+	p.publish()
+	if len(p.pending) != 0 {
+		t.Errorf("expected the reports to be published, got %v pending", p.pending)
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("ingress-nginx").Get(context.Background(), "reports", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the ConfigMap to be created: %v", err)
+	}
+	report := &IngressValidationReport{}
+	if err := json.Unmarshal([]byte(cm.Data["default_web.json"]), report); err != nil {
+		t.Fatalf("invalid report %q: %v", cm.Data["default_web.json"], err)
+	}
+	if report.Allowed || report.Error != "invalid" || len(report.Findings) != maxReportFindings || report.Truncated != 3 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if _, ok := cm.Data["default_api.json"]; !ok {
+		t.Errorf("expected the report of default/api, got %v", cm.Data)
+	}
+}
+
+func TestReportPublisherCRD(t *testing.T) {
+	n := newTestController(t, "")
+	scheme := runtime.NewScheme()
+	if err := networking.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	ing := &networking.Ingress{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "Ingress"},
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "1234"},
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(scheme,
+		map[schema.GroupVersionResource]string{ingressValidationReportResource: "IngressValidationReportList"}, ing)
+	n.cfg.ReportSink = reportSinkCRD
+	p, err := n.newReportPublisher(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, findings := range [][]Finding{{{Rule: "first"}}, {{Rule: "second"}}} {
+		p.record(ing, findings, nil)
+		p.publish()
+	}
+
+	obj, err := client.Resource(ingressValidationReportResource).Namespace("default").Get(context.Background(), "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the report to be created: %v", err)
+	}
+	if owners := obj.GetOwnerReferences(); len(owners) != 1 || owners[0].UID != "1234" {
+		t.Errorf("expected the report to be owned by the Ingress, got %v", owners)
+	}
+	findings, _, _ := unstructured.NestedSlice(obj.Object, "status", "findings")
+	if len(findings) != 1 || findings[0].(map[string]interface{})["rule"] != "second" {
+		t.Errorf("expected the last report, got %v", findings)
+	}
+}
+
+func TestIngressChecksum(t *testing.T) {
+	a := &networking.Ingress{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"a": "1", "b": "2"}}}
+	b := &networking.Ingress{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"b": "2", "a": "1"}, Labels: map[string]string{"x": "y"}}}
+	if ingressChecksum(a) != ingressChecksum(b) {
+		t.Error("expected the checksum to depend on the spec and annotations only")
+	}
+	b.Annotations["a"] = "3"
+	if ingressChecksum(a) == ingressChecksum(b) {
+		t.Error("expected the checksum to change with the annotations")
+	}
+}
+
+func TestPublishCRDOwner(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := networking.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	persisted := &networking.Ingress{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "Ingress"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "persisted", UID: types.UID("persisted-uid")},
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(scheme, map[schema.GroupVersionResource]string{
+		ingressValidationReportResource: "IngressValidationReportList",
+	}, persisted)
+	p := &reportPublisher{sink: reportSinkCRD, client: client}
+
+	ctx := context.Background()
+	for _, key := range []string{"default/persisted", "default/rejected"} {
+		if err := p.publishCRD(ctx, &IngressValidationReport{Ingress: key, Findings: []Finding{}}); err != nil {
+			t.Fatalf("%v: unexpected error: %v", key, err)
+		}
+	}
+
+	reports := client.Resource(ingressValidationReportResource).Namespace("default")
+	report, err := reports.Get(ctx, "persisted", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	owners := report.GetOwnerReferences()
+	if len(owners) != 1 || owners[0].UID != persisted.UID {
+		t.Errorf("expected the report to be owned by %v, got %v", persisted.UID, owners)
+	}
+
+	if _, err := reports.Get(ctx, "rejected", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected no report for an Ingress that does not exist, got %v", err)
+	}
+}
+
+func TestReportPrune(t *testing.T) {
+	s := newMemoryStore("")
+	if err := s.Add(&networking.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "existing"}}); err != nil {
+		t.Fatal(err)
+	}
+	n := newOfflineController(&NginxConfiguration{}, s)
+
+	now := time.Now()
+	p := &reportPublisher{
+		n: n,
+		reports: map[string]*IngressValidationReport{
+			"default/existing": {Ingress: "default/existing", Time: now.Add(-2 * maxOrphanReportAge)},
+			"default/recent":   {Ingress: "default/recent", Time: now.Add(-time.Minute)},
+			"default/deleted":  {Ingress: "default/deleted", Time: now.Add(-2 * maxOrphanReportAge)},
+		},
+		pending: map[string]bool{"default/deleted": true},
+	}
+
+	p.prune(now)
+
+	for _, key := range []string{"default/existing", "default/recent"} {
+		if p.reports[key] == nil {
+			t.Errorf("expected the report of %v to be kept", key)
+		}
+	}
+	if p.reports["default/deleted"] != nil || p.pending["default/deleted"] {
+		t.Errorf("expected the report of the deleted Ingress to be pruned")
+	}
+}
+
+func TestPublishConfigMapMerge(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	other := &IngressValidationReport{Ingress: "default/other", Time: now, Allowed: true, Findings: []Finding{}}
+	stale := &IngressValidationReport{Ingress: "default/app", Time: now.Add(-time.Minute), Allowed: true, Findings: []Finding{}}
+	rawOther, _ := json.Marshal(other)
+	rawStale, _ := json.Marshal(stale)
+
+	client := fake.NewSimpleClientset(&apiv1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ingress-nginx", Name: "reports"},
+		Data: map[string]string{
+			"default_other.json": string(rawOther),
+			"default_app.json":   string(rawStale),
+		},
+	})
+	s := newMemoryStore("")
+	for _, name := range []string{"app", "other"} {
+		if err := s.Add(&networking.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n := newOfflineController(&NginxConfiguration{Client: client}, s)
+	p := &reportPublisher{sink: reportSinkConfigMap, configMap: "ingress-nginx/reports", n: n}
+
+	ctx := context.Background()
+	err := p.publishConfigMap(ctx, map[string]*IngressValidationReport{
+		"default/app": {Ingress: "default/app", Time: now, Allowed: false, Error: "rejected", Findings: []Finding{}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("ingress-nginx").Get(ctx, "reports", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cm.Data["default_other.json"] != string(rawOther) {
+		t.Errorf("expected the report published by another replica to be kept, got %v", cm.Data["default_other.json"])
+	}
+	app := &IngressValidationReport{}
+	if err := json.Unmarshal([]byte(cm.Data["default_app.json"]), app); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if app.Allowed || app.Error != "rejected" {
+		t.Errorf("expected the newest report of default/app, got %+v", app)
+	}
+}
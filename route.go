@@ -0,0 +1,383 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// routeRequest is the request whose routing is simulated
+type routeRequest struct {
+	method   string
+	scheme   string
+	host     string
+	path     string
+	headers  http.Header
+	cookies  map[string]string
+	clientIP net.IP
+}
+
+// routeResult is the outcome of the simulation of a request
+type routeResult struct {
+	// steps explain each decision, in the order nginx takes them
+	steps []string
+	// response is set when nginx answers the request itself
+	response string
+	// backend is the upstream receiving the request
+	backend string
+}
+
+func (r *routeResult) step(format string, args ...interface{}) {
+	r.steps = append(r.steps, fmt.Sprintf(format, args...))
+}
+
+// routeServer returns the server nginx selects for the host: the exact
+// server name or alias, else the longest wildcard name, else the default
+// server
+func routeServer(cfg *Configuration, host string) (*Server, string) {
+	if server := findServer(cfg, host); server != nil {
+		if server.Hostname == host {
+			return server, "exact server_name"
+		}
+		return server, "alias of " + server.Hostname
+	}
+
+	var wildcard *Server
+	for _, server := range cfg.Servers {
+		if !strings.HasPrefix(server.Hostname, "*.") || !strings.HasSuffix(host, server.Hostname[1:]) {
+			continue
+		}
+		if wildcard == nil || len(server.Hostname) > len(wildcard.Hostname) {
+			wildcard = server
+		}
+	}
+	if wildcard != nil {
+		return wildcard, "wildcard server_name " + wildcard.Hostname
+	}
+
+	return findServer(cfg, "_"), "no server_name matches, default server"
+}
+
+// locationMatch describes why matchLocation selected the location of the key
+func locationMatch(key string) string {
+	switch {
+	case strings.HasPrefix(key, "= "):
+		return "exact match"
+	case strings.HasPrefix(key, "~"):
+		return "first matching regular expression"
+	default:
+		return "longest prefix"
+	}
+}
+
+// inSourceRange returns the CIDR of the ranges containing the address
+func inSourceRange(ip net.IP, ranges []string) (string, bool) {
+	for _, r := range ranges {
+		if !strings.Contains(r, "/") {
+			if other := net.ParseIP(r); other != nil && other.Equal(ip) {
+				return r, true
+			}
+			continue
+		}
+		if _, network, err := net.ParseCIDR(r); err == nil && network.Contains(ip) {
+			return r, true
+		}
+	}
+	return "", false
+}
+
+// routeToCanary evaluates the traffic shaping policy of a canary backend the
+// way the balancer of ingress-nginx does: the header, then the cookie, then
+// the weight. A header not matching canary-by-header-value or
+// canary-by-header-pattern falls through to the cookie and the weight.
+func routeToCanary(req *routeRequest, policy TrafficShapingPolicy, result *routeResult) (bool, bool) {
+	if policy.Header != "" {
+		if value := req.headers.Get(policy.Header); value != "" {
+			switch {
+			case policy.HeaderValue != "":
+				if value == policy.HeaderValue {
+					result.step("header %v is %q, matching canary-by-header-value", policy.Header, value)
+					return true, true
+				}
+			case policy.HeaderPattern != "":
+				re, err := regexp.Compile(policy.HeaderPattern)
+				if err == nil && re.MatchString(value) {
+					result.step("header %v is %q, matching canary-by-header-pattern %v", policy.Header, value, policy.HeaderPattern)
+					return true, true
+				}
+			case value == "always":
+				result.step("header %v is always", policy.Header)
+				return true, true
+			case value == "never":
+				result.step("header %v is never", policy.Header)
+				return false, true
+			}
+			result.step("header %v is %q, not selecting the canary; the cookie and the weight decide", policy.Header, value)
+		}
+	}
+
+	if policy.Cookie != "" {
+		switch req.cookies[policy.Cookie] {
+		case "always":
+			result.step("cookie %v is always", policy.Cookie)
+			return true, true
+		case "never":
+			result.step("cookie %v is never", policy.Cookie)
+			return false, true
+		}
+	}
+
+	total := policy.WeightTotal
+	if total == 0 {
+		total = 100
+	}
+	switch {
+	case policy.Weight <= 0:
+		result.step("canary weight is 0")
+		return false, true
+	case policy.Weight >= total:
+		result.step("canary weight is %d of %d", policy.Weight, total)
+		return true, true
+	default:
+		result.step("canary weight is %d of %d: %.1f%% of the requests go to the canary", policy.Weight, total, 100*float64(policy.Weight)/float64(total))
+		return false, false
+	}
+}
+
+// rewriteTarget returns the URI sent to the backend when the location
+// rewrites it, the whole URI being replaced as with the rewrite directive
+func rewriteTarget(loc *Location, path string) (string, bool) {
+	if loc.Rewrite.Target == "" {
+		return path, false
+	}
+	re, err := regexp.Compile("(?i)" + loc.Path)
+	if err != nil {
+		return path, false
+	}
+	match := re.FindStringSubmatchIndex(path)
+	if match == nil {
+		return path, false
+	}
+	template := regexp.MustCompile(`\$(\d+)`).ReplaceAllString(loc.Rewrite.Target, "$${$1}")
+	return string(re.ExpandString(nil, template, path, match)), true
+}
+
+// simulateRoute follows a request through the built configuration and
+// returns the server, location and backend handling it
+func simulateRoute(cfg *Configuration, req *routeRequest, tlsHosts map[string]string) *routeResult {
+	result := &routeResult{}
+
+	server, why := routeServer(cfg, req.host)
+	if server == nil {
+		result.step("no server matches host %v and there is no default server", req.host)
+		result.response = "404 from the default backend"
+		return result
+	}
+	result.step("server %v: %v", server.Hostname, why)
+
+	loc := matchLocation(server, req.path)
+	if loc == nil {
+		result.step("no location of %v matches %v", server.Hostname, req.path)
+		result.response = "404 from the default backend"
+		return result
+	}
+	pathType := networking.PathTypeImplementationSpecific
+	if loc.PathType != nil {
+		pathType = *loc.PathType
+	}
+	ingress := "none"
+	if loc.Ingress != nil {
+		ingress = k8s.MetaNamespaceKey(loc.Ingress)
+	}
+	key := conformanceLocationKey(loc, enforceRegexModifier(server.Locations))
+	result.step("location %v (%v, Ingress %v): %v", key, pathType, ingress, locationMatch(key))
+
+	_, hasTLS := tlsHosts[server.Hostname]
+	if req.scheme == "http" && (loc.Rewrite.ForceSSLRedirect || (loc.Rewrite.SSLRedirect && (hasTLS || server.SSLCert != nil))) {
+		result.step("the request uses http and ssl-redirect is enabled")
+		result.response = fmt.Sprintf("308 redirect to https://%v%v", req.host, req.path)
+		return result
+	}
+
+	if req.clientIP != nil {
+		if cidr, ok := inSourceRange(req.clientIP, loc.Denylist.CIDR); ok {
+			result.step("client %v is in the denylist range %v", req.clientIP, cidr)
+			result.response = "403 forbidden"
+			return result
+		}
+		if len(loc.Allowlist.CIDR) > 0 {
+			cidr, ok := inSourceRange(req.clientIP, loc.Allowlist.CIDR)
+			if !ok {
+				result.step("client %v is not in the allowlist %v", req.clientIP, strings.Join(loc.Allowlist.CIDR, ","))
+				result.response = "403 forbidden"
+				return result
+			}
+			result.step("client %v is in the allowlist range %v", req.clientIP, cidr)
+		}
+	} else if len(loc.Allowlist.CIDR) > 0 || len(loc.Denylist.CIDR) > 0 {
+		result.step("the location restricts client addresses, use --client-ip to evaluate them")
+	}
+
+	if loc.Denied != nil {
+		result.step("the location is denied: %v", *loc.Denied)
+		result.response = "503 service unavailable"
+		return result
+	}
+
+	if loc.Redirect.URL != "" {
+		result.step("the location has a permanent or temporal redirect")
+		result.response = fmt.Sprintf("%d redirect to %v", loc.Redirect.Code, loc.Redirect.URL)
+		return result
+	}
+	if loc.Rewrite.AppRoot != "" && req.path == "/" {
+		result.step("app-root redirects /")
+		result.response = fmt.Sprintf("302 redirect to %v", loc.Rewrite.AppRoot)
+		return result
+	}
+
+	if req.method == http.MethodOptions && loc.CorsConfig.CorsEnabled {
+		result.step("OPTIONS request on a location with CORS enabled")
+		result.response = "204 CORS preflight answered by nginx"
+		return result
+	}
+
+	if loc.BasicDigestAuth.Secured {
+		result.step("the request requires %v authentication", loc.BasicDigestAuth.Type)
+	}
+	if loc.ExternalAuth.URL != "" {
+		result.step("the request is authorized by a subrequest to %v", loc.ExternalAuth.URL)
+	}
+
+	if uri, ok := rewriteTarget(loc, req.path); ok {
+		result.step("the URI is rewritten to %v", uri)
+	}
+
+	result.backend = loc.Backend
+	var backend *Backend
+	for _, b := range cfg.Backends {
+		if b.Name == loc.Backend {
+			backend = b
+		}
+	}
+	if backend == nil || len(backend.AlternativeBackends) == 0 {
+		return result
+	}
+
+	// the balancer only considers the first alternative backend
+	canary := backend.AlternativeBackends[0]
+	for _, b := range cfg.Backends {
+		if b.Name != canary {
+			continue
+		}
+		result.step("backend %v has the canary %v", backend.Name, canary)
+		if routed, decided := routeToCanary(req, b.TrafficShapingPolicy, result); routed {
+			result.backend = canary
+		} else if !decided {
+			result.backend = fmt.Sprintf("%v or %v (canary)", backend.Name, canary)
+		}
+	}
+	return result
+}
+
+// runRoute prints the server, location and backend a request would hit,
+// explaining each decision
+func runRoute(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("route", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	cfg := &NginxConfiguration{}
+	addConfigurationFlags(fs, cfg)
+
+	in := &validateInput{}
+	addInputFlags(fs, in, cfg)
+	method := fs.String("method", http.MethodGet, "Method of the request.")
+	scheme := fs.String("scheme", "https", "Scheme of the request, http or https.")
+	host := fs.String("host", "", "Host of the request.")
+	path := fs.String("path", "/", "Path of the request.")
+	clientIP := fs.String("client-ip", "", "Address of the client, used to evaluate the allowlists and denylists.")
+	headers := stringSliceFlag{}
+	fs.Var(&headers, "header", "Header of the request, as name:value. Can be repeated.")
+	cookies := stringSliceFlag{}
+	fs.Var(&cookies, "cookie", "Cookie of the request, as name=value. Can be repeated.")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitInternal
+	}
+
+	if *host == "" {
+		fmt.Fprintln(stderr, "--host is required")
+		return exitInternal
+	}
+	if *scheme != "http" && *scheme != "https" {
+		fmt.Fprintf(stderr, "invalid --scheme %q, expected http or https\n", *scheme)
+		return exitInternal
+	}
+	if err := in.check(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitInternal
+	}
+
+	req := &routeRequest{
+		method:  strings.ToUpper(*method),
+		scheme:  *scheme,
+		host:    strings.ToLower(*host),
+		path:    *path,
+		headers: http.Header{},
+		cookies: map[string]string{},
+	}
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok {
+			fmt.Fprintf(stderr, "invalid --header %q, expected name:value\n", h)
+			return exitInternal
+		}
+		req.headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	for _, c := range cookies {
+		name, value, ok := strings.Cut(c, "=")
+		if !ok {
+			fmt.Fprintf(stderr, "invalid --cookie %q, expected name=value\n", c)
+			return exitInternal
+		}
+		req.cookies[name] = value
+	}
+	if *clientIP != "" {
+		if req.clientIP = net.ParseIP(*clientIP); req.clientIP == nil {
+			fmt.Fprintf(stderr, "invalid --client-ip %q\n", *clientIP)
+			return exitInternal
+		}
+	}
+
+	s, err := in.load(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitInternal
+	}
+
+	n := newOfflineController(cfg, s)
+	ingresses := s.ListIngresses()
+	_, _, configuration := n.getConfiguration(ingresses)
+
+	result := simulateRoute(configuration, req, tlsSecretsByHost(ingresses))
+	for _, step := range result.steps {
+		fmt.Fprintf(stdout, "- %v\n", step)
+	}
+	if result.response != "" {
+		fmt.Fprintf(stdout, "response: %v\n", result.response)
+		return exitOK
+	}
+	fmt.Fprintf(stdout, "backend: %v\n", result.backend)
+	return exitOK
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const routeManifests = `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+spec:
+  ingressClassName: nginx
+  tls:
+  - hosts:
+    - web.example.com
+    secretName: web-tls
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web-canary
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/canary: "true"
+    nginx.ingress.kubernetes.io/canary-by-header: X-Canary
+    nginx.ingress.kubernetes.io/canary-weight: "10"
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web-canary
+            port:
+              number: 80
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: admin
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/whitelist-source-range: 10.0.0.0/8
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /admin
+        pathType: Exact
+        backend:
+          service:
+            name: admin
+            port:
+              number: 80
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: wildcard
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/rewrite-target: /$2
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: "*.apps.example.com"
+    http:
+      paths:
+      - path: /api(/|$)(.*)
+        pathType: ImplementationSpecific
+        backend:
+          service:
+            name: api
+            port:
+              number: 80
+`
+
+func TestSimulateRoute(t *testing.T) {
+	_, ingresses, cfg := testConfiguration(t, routeManifests)
+	tlsHosts := tlsSecretsByHost(ingresses)
+
+	tests := []struct {
+		name     string
+		req      routeRequest
+		steps    []string
+		response string
+		backend  string
+	}{
+		{
+			name:     "ssl redirect",
+			req:      routeRequest{scheme: "http", host: "web.example.com", path: "/"},
+			steps:    []string{"server web.example.com: exact server_name", "the request uses http and ssl-redirect is enabled"},
+			response: "308 redirect to https://web.example.com/",
+		},
+		{
+			name:    "weighted canary",
+			req:     routeRequest{scheme: "https", host: "web.example.com", path: "/users"},
+			steps:   []string{"location / (Prefix, Ingress default/web): longest prefix", "canary weight is 10 of 100: 10.0% of the requests go to the canary"},
+			backend: "default-web-80 or default-web-canary-80 (canary)",
+		},
+		{
+			name:    "canary header",
+			req:     routeRequest{scheme: "https", host: "web.example.com", path: "/", headers: http.Header{"X-Canary": []string{"always"}}},
+			steps:   []string{"header X-Canary is always"},
+			backend: "default-web-canary-80",
+		},
+		{
+			name:    "canary header never",
+			req:     routeRequest{scheme: "https", host: "web.example.com", path: "/", headers: http.Header{"X-Canary": []string{"never"}}},
+			steps:   []string{"header X-Canary is never"},
+			backend: "default-web-80",
+		},
+		{
+			name:     "client outside the allowlist",
+			req:      routeRequest{scheme: "https", host: "web.example.com", path: "/admin", clientIP: net.ParseIP("192.168.1.1")},
+			steps:    []string{"location = /admin (Exact, Ingress default/admin): exact match", "client 192.168.1.1 is not in the allowlist 10.0.0.0/8"},
+			response: "403 forbidden",
+		},
+		{
+			name:    "client in the allowlist",
+			req:     routeRequest{scheme: "https", host: "web.example.com", path: "/admin", clientIP: net.ParseIP("10.1.2.3")},
+			steps:   []string{"client 10.1.2.3 is in the allowlist range 10.0.0.0/8"},
+			backend: "default-admin-80",
+		},
+		{
+			name:    "wildcard server and rewrite",
+			req:     routeRequest{scheme: "https", host: "shop.apps.example.com", path: "/api/orders"},
+			steps:   []string{"server *.apps.example.com: wildcard server_name *.apps.example.com", "the URI is rewritten to /orders"},
+			backend: "default-api-80",
+		},
+		{
+			name:    "default server",
+			req:     routeRequest{scheme: "https", host: "unknown.example.com", path: "/"},
+			steps:   []string{"server _: no server_name matches, default server"},
+			backend: "upstream-default-backend",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.req.headers == nil {
+				tc.req.headers = http.Header{}
+			}
+			result := simulateRoute(cfg, &tc.req, tlsHosts)
+			steps := strings.Join(result.steps, "\n")
+			for _, step := range tc.steps {
+				if !strings.Contains(steps, step) {
+					t.Errorf("expected step %q, got\n%v", step, steps)
+				}
+			}
+			if result.response != tc.response || result.backend != tc.backend {
+				t.Errorf("expected response %q and backend %q, got %q and %q", tc.response, tc.backend, result.response, result.backend)
+			}
+		})
+	}
+}
+
+func TestRouteToCanary(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  TrafficShapingPolicy
+		req     routeRequest
+		routed  bool
+		decided bool
+	}{
+		{"header value", TrafficShapingPolicy{Header: "X-Canary", HeaderValue: "v2"}, routeRequest{headers: http.Header{"X-Canary": {"v2"}}}, true, true},
+		{"other header value", TrafficShapingPolicy{Header: "X-Canary", HeaderValue: "v2"}, routeRequest{headers: http.Header{"X-Canary": {"v1"}}}, false, true},
+		{"header pattern", TrafficShapingPolicy{Header: "X-Canary", HeaderPattern: "^v[23]$"}, routeRequest{headers: http.Header{"X-Canary": {"v3"}}}, true, true},
+		{"header pattern not matching", TrafficShapingPolicy{Header: "X-Canary", HeaderPattern: "^v[23]$"}, routeRequest{headers: http.Header{"X-Canary": {"v4"}}}, false, true},
+		{"header pattern not matching falls through to the cookie", TrafficShapingPolicy{Header: "X-Canary", HeaderPattern: "^v[23]$", Cookie: "canary"}, routeRequest{headers: http.Header{"X-Canary": {"v4"}}, cookies: map[string]string{"canary": "always"}}, true, true},
+		{"other header value falls through to the weight", TrafficShapingPolicy{Header: "X-Canary", HeaderValue: "v2", Weight: 100}, routeRequest{headers: http.Header{"X-Canary": {"v1"}}}, true, true},
+		{"cookie always", TrafficShapingPolicy{Cookie: "canary"}, routeRequest{cookies: map[string]string{"canary": "always"}}, true, true},
+		{"cookie never", TrafficShapingPolicy{Cookie: "canary", Weight: 100}, routeRequest{cookies: map[string]string{"canary": "never"}}, false, true},
+		{"ignored header falls back to the weight", TrafficShapingPolicy{Header: "X-Canary", Weight: 100}, routeRequest{headers: http.Header{"X-Canary": {"maybe"}}}, true, true},
+		{"weight 0", TrafficShapingPolicy{}, routeRequest{}, false, true},
+		{"weight total", TrafficShapingPolicy{Weight: 50, WeightTotal: 50}, routeRequest{}, true, true},
+		{"partial weight", TrafficShapingPolicy{Weight: 20}, routeRequest{}, false, false},
+	}
+
+	for _, tc := range tests {
+		if tc.req.headers == nil {
+			tc.req.headers = http.Header{}
+		}
+		routed, decided := routeToCanary(&tc.req, tc.policy, &routeResult{})
+		if routed != tc.routed || decided != tc.decided {
+			t.Errorf("%v: expected %v and %v, got %v and %v", tc.name, tc.routed, tc.decided, routed, decided)
+		}
+	}
+}
+
+func TestInSourceRange(t *testing.T) {
+	ranges := []string{"10.0.0.0/8", "192.168.1.10", "2001:db8::/32", "invalid"}
+	tests := map[string]string{
+		"10.20.30.40":  "10.0.0.0/8",
+		"192.168.1.10": "192.168.1.10",
+		"192.168.1.11": "",
+		"2001:db8::1":  "2001:db8::/32",
+	}
+
+	for ip, expected := range tests {
+		cidr, ok := inSourceRange(net.ParseIP(ip), ranges)
+		if cidr != expected || ok != (expected != "") {
+			t.Errorf("%v: expected %q, got %q", ip, expected, cidr)
+		}
+	}
+}
+
+func TestRewriteTarget(t *testing.T) {
+	tests := []struct {
+		path     string
+		target   string
+		uri      string
+		rewrites bool
+	}{
+		{"/api(/|$)(.*)", "/$2", "/orders/1", true},
+		{"/api", "/", "/", true},
+		{"/api", "", "/api/orders/1", false},
+		{"/web", "/", "/api/orders/1", false},
+	}
+
+	for _, tc := range tests {
+		loc := &Location{Path: tc.path}
+		loc.Rewrite.Target = tc.target
+		uri, ok := rewriteTarget(loc, "/api/orders/1")
+		if uri != tc.uri || ok != tc.rewrites {
+			t.Errorf("%v -> %v: expected %q, got %q", tc.path, tc.target, tc.uri, uri)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-plugin"
+	"k8s.io/klog/v2"
+)
+
+// defaultRulePluginTimeout is used when RulePluginTimeout is not set
+const defaultRulePluginTimeout = 10 * time.Second
+
+// rulePluginName is the name of the plugin dispensed by the rule plugins
+const rulePluginName = "rules"
+
+// rulePluginHandshake is the handshake the rule plugins are started with.
+// The plugins are go-plugin binaries serving, with the net/rpc protocol,
+// the method Plugin.Check: it receives a rulePluginRequest and returns a
+// rulePluginResponse. The plugins decode the configuration from JSON, so
+// they do not depend on the types of the validator. Only native binaries
+// are supported, there is no WebAssembly runtime for the plugins.
+var rulePluginHandshake = plugin.HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "NGINX_CONFIG_VALIDATOR_PLUGIN",
+	MagicCookieValue: rulePluginName,
+}
+
+// rulePluginRequest is the argument of Plugin.Check
+type rulePluginRequest struct {
+	// Configuration is the configuration built from the Ingresses, encoded
+	// in JSON
+	Configuration []byte
+}
+
+// rulePluginResponse is the result of Plugin.Check
+type rulePluginResponse struct {
+	Findings []Finding
+}
+
+// rulePlugin is the client side of the rule plugins
+type rulePlugin struct{}
+
+func (rulePlugin) Server(*plugin.MuxBroker) (interface{}, error) {
+	return nil, errors.New("rule plugins are only served by plugin binaries")
+}
+
+func (rulePlugin) Client(_ *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
+	return c, nil
+}
+
+var (
+	// rulePlugins are the plugins started, by path. They are started once
+	// and reused by the validations.
+	rulePlugins     = map[string]*plugin.Client{}
+	rulePluginsLock sync.Mutex
+)
+
+// rulePluginClient returns the RPC client of the plugin, started the first
+// time or when it exited
+func rulePluginClient(path string) (*rpc.Client, error) {
+	rulePluginsLock.Lock()
+	defer rulePluginsLock.Unlock()
+
+	client, ok := rulePlugins[path]
+	if !ok || client.Exited() {
+		client = plugin.NewClient(&plugin.ClientConfig{
+			HandshakeConfig:  rulePluginHandshake,
+			Plugins:          map[string]plugin.Plugin{rulePluginName: rulePlugin{}},
+			Cmd:              exec.Command(path),
+			AllowedProtocols: []plugin.Protocol{plugin.ProtocolNetRPC},
+			Managed:          true,
+			Logger: hclog.New(&hclog.LoggerOptions{
+				Name:   "rule-plugin",
+				Output: os.Stderr,
+				Level:  hclog.Warn,
+			}),
+		})
+		rulePlugins[path] = client
+	}
+
+	protocol, err := client.Client()
+	if err != nil {
+		client.Kill()
+		delete(rulePlugins, path)
+		return nil, fmt.Errorf("error starting rule plugin: %w", err)
+	}
+	raw, err := protocol.Dispense(rulePluginName)
+	if err != nil {
+		return nil, err
+	}
+	return raw.(*rpc.Client), nil
+}
+
+// stopRulePlugins stops the processes of the rule plugins
+func stopRulePlugins() {
+	plugin.CleanupClients()
+}
+
+// runRulePlugin sends the configuration to the plugin and returns its
+// findings. The plugin is killed when it does not answer in time, and
+// started again by the next validation.
+func (n *NGINXController) runRulePlugin(path string, configuration []byte) ([]Finding, error) {
+	client, err := rulePluginClient(path)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := n.cfg.RulePluginTimeout
+	if timeout == 0 {
+		timeout = defaultRulePluginTimeout
+	}
+	resp := &rulePluginResponse{}
+	call := client.Go("Plugin.Check", &rulePluginRequest{Configuration: configuration}, resp, nil)
+	select {
+	case <-call.Done:
+		if call.Error != nil {
+			return nil, call.Error
+		}
+	case <-time.After(timeout):
+		rulePluginsLock.Lock()
+		if p, ok := rulePlugins[path]; ok {
+			p.Kill()
+			delete(rulePlugins, path)
+		}
+		rulePluginsLock.Unlock()
+		return nil, fmt.Errorf("no answer after %v", timeout)
+	}
+
+	// the rules of the plugins are prefixed by the name of the plugin so
+	// they can not be mistaken for the rules of the validator
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	for i := range resp.Findings {
+		f := &resp.Findings[i]
+		if f.Rule == "" {
+			f.Rule = name
+		} else {
+			f.Rule = name + "/" + f.Rule
+		}
+		switch f.Severity {
+		case SeverityError, SeverityWarning, SeverityInfo:
+		default:
+			f.Severity = SeverityWarning
+		}
+	}
+	return resp.Findings, nil
+}
+
+// redactedConfiguration returns a copy of the configuration without the
+// private keys of the certificates, the plugins being code supplied by the
+// teams that must not read the secrets of the cluster
+func redactedConfiguration(cfg *Configuration) *Configuration {
+	redacted := *cfg
+	redacted.DefaultSSLCertificate = nil
+	redacted.Servers = make([]*Server, len(cfg.Servers))
+	for i, server := range cfg.Servers {
+		if server.SSLCert == nil || server.SSLCert.PemCertKey == "" {
+			redacted.Servers[i] = server
+			continue
+		}
+		s := *server
+		cert := *server.SSLCert
+		cert.PemCertKey = ""
+		s.SSLCert = &cert
+		redacted.Servers[i] = &s
+	}
+	return &redacted
+}
+
+// checkRulePlugins runs the rules of the plugins against the configuration.
+// A plugin failing is reported as a warning, the validation does not depend
+// on the availability of the plugins.
+func (n *NGINXController) checkRulePlugins(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	if len(n.cfg.RulePlugins) == 0 {
+		return findings
+	}
+
+	configuration, err := json.Marshal(redactedConfiguration(cfg))
+	if err != nil {
+		klog.Errorf("Error encoding the configuration for the rule plugins: %v", err)
+		return findings
+	}
+
+	for _, path := range n.cfg.RulePlugins {
+		pluginFindings, err := n.runRulePlugin(path, configuration)
+		if err != nil {
+			klog.Errorf("Error running rule plugin %v: %v", path, err)
+			findings = append(findings, Finding{
+				Rule:     "rule-plugin-failed",
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("rule plugin %v failed, its rules were not checked: %v", path, err),
+			})
+			continue
+		}
+		findings = append(findings, pluginFindings...)
+	}
+	return findings
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+)
+
+// sandboxCertDirectives are the directives reading a certificate, a key, a
+// list of CAs or a CRL, replaced by stubs in the sandbox when the
+// configuration does not come with the file
+var sandboxCertDirectives = map[string]func(stubCertificate) []byte{
+	"ssl_certificate":               func(s stubCertificate) []byte { return s.pemCertKey },
+	"ssl_certificate_key":           func(s stubCertificate) []byte { return s.pemCertKey },
+	"proxy_ssl_certificate":         func(s stubCertificate) []byte { return s.pemCertKey },
+	"proxy_ssl_certificate_key":     func(s stubCertificate) []byte { return s.pemCertKey },
+	"ssl_client_certificate":        func(s stubCertificate) []byte { return s.ca },
+	"ssl_trusted_certificate":       func(s stubCertificate) []byte { return s.ca },
+	"proxy_ssl_trusted_certificate": func(s stubCertificate) []byte { return s.ca },
+	"ssl_crl":                       func(s stubCertificate) []byte { return s.crl },
+	"proxy_ssl_crl":                 func(s stubCertificate) []byte { return s.crl },
+}
+
+// sandboxWriteDirectives are the directives naming a file or directory nginx
+// creates, moved to the sandbox
+var sandboxWriteDirectives = map[string]bool{
+	"pid":                   true,
+	"error_log":             true,
+	"access_log":            true,
+	"client_body_temp_path": true,
+	"proxy_temp_path":       true,
+	"fastcgi_temp_path":     true,
+	"uwsgi_temp_path":       true,
+	"scgi_temp_path":        true,
+}
+
+// defaultNginxBinary is the nginx binary of the ingress controller image,
+// also the wrapper chrooting nginx in the chroot image
+const defaultNginxBinary = "/usr/bin/nginx"
+
+// maxConfigLineLength is the longest line of a configuration the sandbox
+// reads
+const maxConfigLineLength = 16 * 1024 * 1024
+
+// luaRequirePattern matches the Lua modules loaded by the configuration
+var luaRequirePattern = regexp.MustCompile(`require\(?\s*["']([\w.-]+)["']`)
+
+// stubLuaModule replaces the Lua modules of the ingress controller: any
+// function of the module can be called and does nothing, so the init_by_lua
+// blocks run during the test
+const stubLuaModule = `return setmetatable({}, { __index = function() return function() end end })
+`
+
+// sandbox is a throwaway directory, in the sandboxes of the work directory,
+// containing a configuration and every file it references: the files nginx
+// reads are stubs and the files it writes are moved into the sandbox. This
+// makes nginx -t independent of the files of the host.
+type sandbox struct {
+	dir   string
+	paths pathMapper
+	// binary is the nginx binary testing the configuration
+	binary string
+	// files maps the paths of the configuration to the files of the sandbox
+	files map[string]string
+}
+
+// newSandbox creates an empty sandbox, removed by Close
+func (n *NGINXController) newSandbox() (*sandbox, error) {
+	parent, err := n.workDirPath("sandboxes")
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(parent, "test-")
+	if err != nil {
+		return nil, err
+	}
+	binary := n.cfg.NginxBinary
+	if binary == "" {
+		binary = defaultNginxBinary
+	}
+	return &sandbox{dir: dir, paths: n.paths(), binary: binary, files: map[string]string{}}, nil
+}
+
+// addSandboxFlags registers the flags of the nginx running in the sandboxes
+func addSandboxFlags(fs *flag.FlagSet, cfg *NginxConfiguration) {
+	fs.StringVar(&cfg.NginxBinary, "nginx-binary", defaultNginxBinary,
+		"nginx binary testing the configurations with nginx -t, the one of the ingress controller image for the same modules and version.")
+}
+
+// Close removes the sandbox
+func (sb *sandbox) Close() error {
+	return os.RemoveAll(sb.dir)
+}
+
+// local returns the file of the sandbox replacing path
+func (sb *sandbox) local(path string) string {
+	return filepath.Join(sb.dir, "root", path)
+}
+
+// add writes data in the sandbox as the file path of the configuration. The
+// first content added for a path is kept.
+func (sb *sandbox) add(path string, data []byte) error {
+	if _, ok := sb.files[path]; ok {
+		return nil
+	}
+
+	local := sb.local(path)
+	if err := os.MkdirAll(filepath.Dir(local), 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(local, data, 0o600); err != nil {
+		return err
+	}
+	sb.files[path] = local
+	return nil
+}
+
+// addSecrets stores the files of the Secrets, by file name of the
+// configuration, in files instead of the sandbox
+func (sb *sandbox) addSecrets(files secretFiles, data map[string][]byte) error {
+	for path, content := range data {
+		if _, ok := sb.files[path]; ok {
+			continue
+		}
+		local, err := files.write(filepath.Base(path), content)
+		if err != nil {
+			return err
+		}
+		sb.files[path] = local
+	}
+	return nil
+}
+
+// addCertificates writes stubs for the certificates, CAs and CRLs of the
+// built configuration whose Secrets were not added
+func (sb *sandbox) addCertificates(cfg *Configuration) error {
+	stub, err := stubCertificates()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	add := func(pemFileName, caFileName, crlFileName string) {
+		if pemFileName != "" {
+			errs = append(errs, sb.add(pemFileName, stub.pemCertKey))
+		}
+		if caFileName != "" {
+			errs = append(errs, sb.add(caFileName, stub.ca))
+		}
+		if crlFileName != "" {
+			errs = append(errs, sb.add(crlFileName, stub.crl))
+		}
+	}
+
+	if c := cfg.DefaultSSLCertificate; c != nil {
+		add(c.PemFileName, c.CAFileName, c.CRLFileName)
+	}
+	for _, server := range cfg.Servers {
+		if c := server.SSLCert; c != nil {
+			add(c.PemFileName, c.CAFileName, c.CRLFileName)
+		}
+		for _, c := range []*resolver.AuthSSLCert{&server.CertificateAuth.AuthSSLCert, &server.ProxySSL.AuthSSLCert} {
+			add(c.PemFileName, c.CAFileName, c.CRLFileName)
+		}
+		for _, loc := range server.Locations {
+			c := loc.ProxySSL.AuthSSLCert
+			add(c.PemFileName, c.CAFileName, c.CRLFileName)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rewrite copies the configuration line by line, using the files of the
+// sandbox, creating stubs for the files it reads that were not added and the
+// Lua modules it loads. The configuration is never held in memory.
+func (sb *sandbox) rewrite(in io.Reader, w io.Writer) error {
+	stub, err := stubCertificates()
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(w)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxConfigLineLength)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if err := sb.addLuaModules(line); err != nil {
+			return err
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			out.WriteString(line + "\n")
+			continue
+		}
+
+		directive, arg := fields[0], strings.Trim(strings.TrimSuffix(fields[1], ";"), `"'`)
+		if directive == "lua_package_path" {
+			fmt.Fprintf(out, "lua_package_path \"%v/?.lua;;\";\n", sb.nginxPath(filepath.Join(sb.dir, "lua")))
+			continue
+		}
+		if directive == "ssl_dhparam" {
+			// generating parameters is too slow for a test, nginx uses its
+			// defaults
+			out.WriteString("# " + line + "\n")
+			continue
+		}
+		if !filepath.IsAbs(arg) {
+			out.WriteString(line + "\n")
+			continue
+		}
+
+		switch {
+		case sandboxCertDirectives[directive] != nil:
+			err = sb.add(arg, sandboxCertDirectives[directive](stub))
+		case directive == "include" && !strings.ContainsAny(arg, "*?["):
+			// mime.types and the snippets of the image, the directives
+			// they contain are not tested
+			err = sb.add(arg, nil)
+		case directive == "include":
+			sb.files[arg] = sb.local(arg)
+			err = os.MkdirAll(filepath.Dir(sb.local(arg)), 0o700)
+		case sandboxWriteDirectives[directive]:
+			sb.files[arg] = sb.local(arg)
+			err = os.MkdirAll(filepath.Dir(sb.local(arg)), 0o700)
+		}
+		if err != nil {
+			return err
+		}
+
+		if local, ok := sb.files[arg]; ok {
+			line = strings.Replace(line, arg, sb.nginxPath(local), 1)
+		}
+		out.WriteString(line + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// addLuaModules creates stubs of the Lua modules loaded by a line of the
+// configuration
+func (sb *sandbox) addLuaModules(line string) error {
+	for _, m := range luaRequirePattern.FindAllStringSubmatch(line, -1) {
+		module := filepath.Join(sb.dir, "lua", strings.ReplaceAll(m[1], ".", "/")+".lua")
+		if _, err := os.Stat(module); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(module), 0o700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(module, []byte(stubLuaModule), 0o600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nginxPath returns the path nginx uses for a file of the sandbox. The work
+// directory must be in the chroot when nginx runs chrooted, the path is left
+// as is otherwise and the test fails on the missing file.
+func (sb *sandbox) nginxPath(local string) string {
+	path, err := sb.paths.nginx(local)
+	if err != nil {
+		return local
+	}
+	return path
+}
+
+// test checks the syntax of the configuration file with the files of the
+// sandbox
+func (sb *sandbox) test(path string) ([]byte, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	cfg := filepath.Join(sb.dir, "nginx.conf")
+	out, err := os.OpenFile(cfg, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := sb.rewrite(in, out); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+	return Test(sb.binary, sb.nginxPath(cfg))
+}
+
+// testConfiguration checks the syntax of the configuration file rendered for
+// cfg in a sandbox, with the certificates of the Secrets of the store, stored
+// as selected by --secret-storage, and stubs of the missing ones. The file is
+// streamed to the sandbox, so large configurations are not loaded in memory.
+func (n *NGINXController) testConfiguration(cfg *Configuration, path string) ([]byte, error) {
+	sb, err := n.newSandbox()
+	if err != nil {
+		return nil, fmt.Errorf("error creating sandbox: %w", err)
+	}
+	defer sb.Close()
+
+	files, err := n.newSecretFiles()
+	if err != nil {
+		return nil, fmt.Errorf("error creating secret storage: %w", err)
+	}
+	defer files.Close()
+
+	if err := sb.addSecrets(files, n.secretData(cfg)); err != nil {
+		return nil, fmt.Errorf("error writing secrets: %w", err)
+	}
+	if err := sb.addCertificates(cfg); err != nil {
+		return nil, fmt.Errorf("error writing certificates to the sandbox: %w", err)
+	}
+	return sb.test(path)
+}
+
+// checkNginxConf tests the nginx.conf rendered by the ingress controller for
+// cfg. It returns an error when nginx could not be run.
+func (n *NGINXController) checkNginxConf(cfg *Configuration, path string) ([]Finding, error) {
+	output, err := n.testConfiguration(cfg, path)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return []Finding{{
+			Rule:     "nginx-test",
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("nginx -t rejects %v: %v", path, strings.TrimSpace(string(output))),
+		}}, nil
+	}
+	return nil, err
+}
+
+// stubCertificate is a self-signed certificate, its key and an empty CRL,
+// used in place of the files of the Secrets
+type stubCertificate struct {
+	pemCertKey []byte
+	ca         []byte
+	crl        []byte
+}
+
+var (
+	stubOnce sync.Once
+	stub     stubCertificate
+	stubErr  error
+)
+
+// stubCertificates returns the stub certificate, generated on first use
+func stubCertificates() (stubCertificate, error) {
+	stubOnce.Do(func() {
+		stub, stubErr = newStubCertificate(time.Now())
+	})
+	return stub, stubErr
+}
+
+func newStubCertificate(now time.Time) (stubCertificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return stubCertificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "nginx-config-validator sandbox"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return stubCertificate{}, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return stubCertificate{}, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return stubCertificate{}, err
+	}
+	crlDER, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: now.Add(-time.Hour),
+		NextUpdate: now.AddDate(10, 0, 0),
+	}, cert, key)
+	if err != nil {
+		return stubCertificate{}, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return stubCertificate{
+		pemCertKey: append(append([]byte{}, certPEM...), keyPEM...),
+		ca:         certPEM,
+		crl:        pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crlDER}),
+	}, nil
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// newTestSandbox returns a sandbox in a temporary work directory
+func newTestSandbox(t *testing.T) *sandbox {
+	t.Helper()
+
+	n := newTestController(t, "")
+	n.cfg.WorkDir = t.TempDir()
+	sb, err := n.newSandbox()
+	if err != nil {
+		t.Fatalf("unexpected error creating the sandbox: %v", err)
+	}
+	t.Cleanup(func() { sb.Close() })
+	return sb
+}
+
+func TestSandboxRewrite(t *testing.T) {
+	stub, err := stubCertificates()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testCases := map[string]struct {
+		line     string
+		expected func(sb *sandbox) string
+		// file is the path of the configuration expected in the sandbox
+		file    string
+		content []byte
+		dir     bool
+	}{
+		"certificate replaced by the stub": {
+			line: "ssl_certificate /etc/ingress-controller/ssl/default-web.pem;",
+			expected: func(sb *sandbox) string {
+				return "ssl_certificate " + sb.local("/etc/ingress-controller/ssl/default-web.pem") + ";"
+			},
+			file:    "/etc/ingress-controller/ssl/default-web.pem",
+			content: stub.pemCertKey,
+		},
+		"CA replaced by the stub CA": {
+			line: `ssl_client_certificate "/etc/ingress-controller/ssl/ca.pem";`,
+			expected: func(sb *sandbox) string {
+				return `ssl_client_certificate "` + sb.local("/etc/ingress-controller/ssl/ca.pem") + `";`
+			},
+			file:    "/etc/ingress-controller/ssl/ca.pem",
+			content: stub.ca,
+		},
+		"CRL replaced by the stub CRL": {
+			line: "ssl_crl /etc/ingress-controller/ssl/crl.pem;",
+			expected: func(sb *sandbox) string {
+				return "ssl_crl " + sb.local("/etc/ingress-controller/ssl/crl.pem") + ";"
+			},
+			file:    "/etc/ingress-controller/ssl/crl.pem",
+			content: stub.crl,
+		},
+		"written file moved to the sandbox": {
+			line: "pid /tmp/nginx/nginx.pid;",
+			expected: func(sb *sandbox) string {
+				return "pid " + sb.local("/tmp/nginx/nginx.pid") + ";"
+			},
+			file: "/tmp/nginx",
+			dir:  true,
+		},
+		"included file replaced by an empty file": {
+			line: "include /etc/nginx/mime.types;",
+			expected: func(sb *sandbox) string {
+				return "include " + sb.local("/etc/nginx/mime.types") + ";"
+			},
+			file:    "/etc/nginx/mime.types",
+			content: []byte{},
+		},
+		"included pattern matches nothing": {
+			line: "include /etc/nginx/conf.d/*.conf;",
+			expected: func(sb *sandbox) string {
+				return "include " + sb.local("/etc/nginx/conf.d/*.conf") + ";"
+			},
+			file: "/etc/nginx/conf.d",
+			dir:  true,
+		},
+		"Lua modules loaded from the sandbox": {
+			line: `lua_package_path "/etc/nginx/lua/?.lua;;";`,
+			expected: func(sb *sandbox) string {
+				return `lua_package_path "` + filepath.Join(sb.dir, "lua") + `/?.lua;;";`
+			},
+		},
+		"DH parameters commented out": {
+			line: "ssl_dhparam /etc/ingress-controller/ssl/dhparam.pem;",
+			expected: func(sb *sandbox) string {
+				return "# ssl_dhparam /etc/ingress-controller/ssl/dhparam.pem;"
+			},
+		},
+		"relative path unchanged": {
+			line: "ssl_certificate certs/web.pem;",
+			expected: func(sb *sandbox) string {
+				return "ssl_certificate certs/web.pem;"
+			},
+		},
+		"directive without a path unchanged": {
+			line: "listen 80;",
+			expected: func(sb *sandbox) string {
+				return "listen 80;"
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			sb := newTestSandbox(t)
+
+			var out bytes.Buffer
+			err := sb.rewrite(strings.NewReader(tc.line+"\n"), &out)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if expected := tc.expected(sb) + "\n"; out.String() != expected {
+				t.Errorf("expected %q, got %q", expected, out.String())
+			}
+
+			if tc.file == "" {
+				return
+			}
+			info, err := os.Stat(sb.local(tc.file))
+			if err != nil {
+				t.Fatalf("expected %v in the sandbox: %v", tc.file, err)
+			}
+			if tc.dir {
+				if !info.IsDir() {
+					t.Errorf("expected %v to be a directory", tc.file)
+				}
+				return
+			}
+			content, err := os.ReadFile(sb.local(tc.file))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(content, tc.content) {
+				t.Errorf("unexpected content of %v: %q", tc.file, content)
+			}
+		})
+	}
+}
+
+func TestSandboxRewriteLuaModules(t *testing.T) {
+	sb := newTestSandbox(t)
+
+	content := `init_by_lua_block {
+    local ok, res = pcall(require, "lua_ingress")
+    balancer = require("balancer")
+    require "plugins.monitor"
+}
+`
+	var out bytes.Buffer
+	if err := sb.rewrite(strings.NewReader(content), &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != content {
+		t.Errorf("expected the Lua block unchanged, got %q", out.String())
+	}
+
+	for _, module := range []string{"balancer.lua", filepath.Join("plugins", "monitor.lua")} {
+		stub, err := os.ReadFile(filepath.Join(sb.dir, "lua", module))
+		if err != nil {
+			t.Errorf("expected a stub for %v: %v", module, err)
+			continue
+		}
+		if string(stub) != stubLuaModule {
+			t.Errorf("unexpected stub for %v: %q", module, stub)
+		}
+	}
+	// pcall(require, ...) is not matched, lua_ingress is a module of the
+	// image loaded with its search path
+	if _, err := os.Stat(filepath.Join(sb.dir, "lua", "lua_ingress.lua")); !os.IsNotExist(err) {
+		t.Errorf("expected no stub for lua_ingress, got %v", err)
+	}
+}
+
+func TestSandboxRewriteStream(t *testing.T) {
+	sb := newTestSandbox(t)
+
+	// a line longer than the buffer of the scanner, as the Lua blocks of
+	// large configurations
+	long := "set $config '" + strings.Repeat("x", 1024*1024) + "';\n"
+	var out bytes.Buffer
+	if err := sb.rewrite(strings.NewReader(long), &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != long {
+		t.Errorf("expected the long line unchanged, got %v bytes", out.Len())
+	}
+
+	readErr := errors.New("read error")
+	if err := sb.rewrite(iotest.ErrReader(readErr), &out); !errors.Is(err, readErr) {
+		t.Errorf("expected the read error, got %v", err)
+	}
+
+	// a module already written is not replaced by a stub
+	module := filepath.Join(sb.dir, "lua", "balancer.lua")
+	if err := os.MkdirAll(filepath.Dir(module), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(module, []byte("return {}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := sb.rewrite(strings.NewReader(`balancer = require("balancer")`+"\n"), io.Discard); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(module); string(data) != "return {}" {
+		t.Errorf("expected the module to be kept, got %q", data)
+	}
+}
+
+func TestSandboxTest(t *testing.T) {
+	sb := newTestSandbox(t)
+	if _, err := sb.test(filepath.Join(t.TempDir(), "missing.conf")); !os.IsNotExist(err) {
+		t.Errorf("expected a missing file error, got %v", err)
+	}
+}
+
+func TestSandboxAddCertificates(t *testing.T) {
+	stub, err := stubCertificates()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sb := newTestSandbox(t)
+	cfg := &Configuration{
+		DefaultSSLCertificate: &SSLCert{PemFileName: "/ssl/default.pem"},
+		Servers: []*Server{{
+			Hostname: "example.com",
+			SSLCert:  &SSLCert{PemFileName: "/ssl/web.pem", CAFileName: "/ssl/web-ca.pem", CRLFileName: "/ssl/web-crl.pem"},
+			Locations: []*Location{{
+				Path: "/",
+			}},
+		}},
+	}
+	cfg.Servers[0].Locations[0].ProxySSL.AuthSSLCert.CAFileName = "/ssl/upstream-ca.pem"
+
+	if err := sb.addCertificates(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string][]byte{
+		"/ssl/default.pem":     stub.pemCertKey,
+		"/ssl/web.pem":         stub.pemCertKey,
+		"/ssl/web-ca.pem":      stub.ca,
+		"/ssl/web-crl.pem":     stub.crl,
+		"/ssl/upstream-ca.pem": stub.ca,
+	}
+	if len(sb.files) != len(expected) {
+		t.Errorf("expected %v files in the sandbox, got %v", len(expected), sb.files)
+	}
+	for path, content := range expected {
+		data, err := os.ReadFile(sb.local(path))
+		if err != nil {
+			t.Errorf("expected %v in the sandbox: %v", path, err)
+			continue
+		}
+		if !bytes.Equal(data, content) {
+			t.Errorf("unexpected content of %v", path)
+		}
+	}
+
+	// a file added first is kept when the configuration references it again
+	if err := sb.add("/ssl/web.pem", []byte("other")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(sb.local("/ssl/web.pem")); !bytes.Equal(data, stub.pemCertKey) {
+		t.Errorf("expected the first content of /ssl/web.pem to be kept, got %q", data)
+	}
+}
+
+func TestSandboxClose(t *testing.T) {
+	sb := newTestSandbox(t)
+	if err := sb.add("/ssl/web.pem", []byte("cert")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := sb.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(sb.dir); !os.IsNotExist(err) {
+		t.Errorf("expected the sandbox to be removed, got %v", err)
+	}
+}
+
+func TestNewStubCertificate(t *testing.T) {
+	now := time.Now()
+	stub, err := newStubCertificate(now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := tls.X509KeyPair(stub.pemCertKey, stub.pemCertKey); err != nil {
+		t.Errorf("expected a certificate and its key: %v", err)
+	}
+
+	block, _ := pem.Decode(stub.ca)
+	if block == nil {
+		t.Fatalf("expected a PEM CA, got %q", stub.ca)
+	}
+	ca, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("unexpected error parsing the CA: %v", err)
+	}
+	if !ca.IsCA || now.Before(ca.NotBefore) || now.After(ca.NotAfter) {
+		t.Errorf("expected a valid CA, got IsCA %v from %v to %v", ca.IsCA, ca.NotBefore, ca.NotAfter)
+	}
+
+	block, _ = pem.Decode(stub.crl)
+	if block == nil || block.Type != "X509 CRL" {
+		t.Fatalf("expected a PEM CRL, got %q", stub.crl)
+	}
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	if err != nil {
+		t.Fatalf("unexpected error parsing the CRL: %v", err)
+	}
+	if err := crl.CheckSignatureFrom(ca); err != nil {
+		t.Errorf("expected the CRL to be signed by the CA: %v", err)
+	}
+}
+
+// fakeNginx is an nginx binary rejecting the configurations containing
+// "invalid"
+const fakeNginx = `#!/bin/sh
+if grep -q invalid "$2"; then
+  echo "nginx: [emerg] unknown directive \"invalid\" in $2:1"
+  exit 1
+fi
+echo "nginx: configuration file $2 test is successful"
+`
+
+func TestCheckNginxConf(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake nginx binary is a shell script")
+	}
+
+	n := newTestController(t, "")
+	n.cfg.WorkDir = t.TempDir()
+
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "nginx")
+	if err := os.WriteFile(binary, []byte(fakeNginx), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeConf := func(name, conf string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(conf), 0o600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return path
+	}
+
+	testCases := map[string]struct {
+		path        string
+		binary      string
+		findings    int
+		expectedErr string
+	}{
+		"valid": {
+			path:   writeConf("valid.conf", "events {}\n"),
+			binary: binary,
+		},
+		"invalid": {
+			path:     writeConf("invalid.conf", "invalid;\n"),
+			binary:   binary,
+			findings: 1,
+		},
+		"missing file": {
+			path:        filepath.Join(dir, "missing.conf"),
+			binary:      binary,
+			expectedErr: "no such file",
+		},
+		"nginx not run": {
+			path:        writeConf("nginx.conf", "events {}\n"),
+			binary:      "nginx-missing",
+			expectedErr: "executable file not found",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n.cfg.NginxBinary = tc.binary
+			findings, err := n.checkNginxConf(&Configuration{}, tc.path)
+			if tc.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+					t.Errorf("expected an error containing %q, got %v", tc.expectedErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(findings) != tc.findings {
+				t.Fatalf("expected %d findings, got %v", tc.findings, findings)
+			}
+			if tc.findings > 0 && !strings.Contains(findings[0].Message, "unknown directive") {
+				t.Errorf("expected the output of nginx in the finding, got %v", findings[0].Message)
+			}
+		})
+	}
+
+	// the sandbox is removed after the test
+	entries, err := os.ReadDir(filepath.Join(n.cfg.WorkDir, "sandboxes"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected the sandboxes to be removed, got %v", entries)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+
+// accessMechanisms returns the annotations of the location restricting the
+// access in the access phase of nginx, the ones combined by satisfy
+func accessMechanisms(loc *Location, globalAuthURL string) []string {
+	var mechanisms []string
+	if loc.BasicDigestAuth.Secured {
+		mechanisms = append(mechanisms, "auth-type")
+	}
+	if loc.ExternalAuth.URL != "" {
+		mechanisms = append(mechanisms, "auth-url")
+	} else if loc.EnableGlobalAuth && globalAuthURL != "" {
+		mechanisms = append(mechanisms, "global-auth-url")
+	}
+	if len(loc.Allowlist.CIDR) > 0 {
+		mechanisms = append(mechanisms, "allowlist-source-range")
+	}
+	if len(loc.Denylist.CIDR) > 0 {
+		mechanisms = append(mechanisms, "denylist-source-range")
+	}
+	return mechanisms
+}
+
+// checkSatisfy validates the satisfy annotations, which only matter when
+// several access mechanisms protect the location
+func (n *NGINXController) checkSatisfy(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	globalAuth, _ := n.globalExternalAuth()
+	globalAuthURL := globalAuth.URL
+
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			if loc.Ingress == nil {
+				continue
+			}
+			value, ok := loc.Ingress.Annotations[parser.GetAnnotationWithPrefix("satisfy")]
+			if !ok {
+				continue
+			}
+
+			if value != "any" && value != "all" {
+				findings = append(findings, newLocationFinding("satisfy-invalid", SeverityError, server, loc,
+					"satisfy %q of location %q is not any or all; the ingress controller ignores it and nginx requires all the access mechanisms",
+					value, loc.Path))
+				continue
+			}
+
+			mechanisms := accessMechanisms(loc, globalAuthURL)
+			if len(mechanisms) < 2 {
+				detail := "none is configured"
+				if len(mechanisms) == 1 {
+					detail = "only " + mechanisms[0] + " is configured"
+				}
+				findings = append(findings, newLocationFinding("satisfy-ineffective", SeverityWarning, server, loc,
+					"satisfy %v of location %q has no effect: it combines several access mechanisms but %v",
+					value, loc.Path, detail))
+			}
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/auth"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/authreq"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/ipallowlist"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/ipdenylist"
+	ngx_config "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/controller/config"
+)
+
+func TestAccessMechanisms(t *testing.T) {
+	testCases := map[string]struct {
+		loc           *Location
+		globalAuthURL string
+		expected      []string
+	}{
+		"none": {
+			loc: &Location{EnableGlobalAuth: true},
+		},
+		"all": {
+			loc: &Location{
+				BasicDigestAuth: auth.Config{Secured: true},
+				ExternalAuth:    authreq.Config{URL: "https://auth.example.com/verify"},
+				Allowlist:       ipallowlist.SourceRange{CIDR: []string{"10.0.0.0/8"}},
+				Denylist:        ipdenylist.SourceRange{CIDR: []string{"10.0.0.1/32"}},
+			},
+			globalAuthURL: "https://login.example.com/verify",
+			expected:      []string{"auth-type", "auth-url", "allowlist-source-range", "denylist-source-range"},
+		},
+		"global authentication": {
+			loc:           &Location{EnableGlobalAuth: true},
+			globalAuthURL: "https://login.example.com/verify",
+			expected:      []string{"global-auth-url"},
+		},
+		"global authentication disabled": {
+			loc:           &Location{},
+			globalAuthURL: "https://login.example.com/verify",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if got := accessMechanisms(tc.loc, tc.globalAuthURL); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+// satisfyManifests is completed with the data of the ConfigMap and the
+// annotations of the Ingress
+const satisfyManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+data:
+%v
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: admin
+  namespace: default
+  annotations:
+%v
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: admin.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: admin
+            port:
+              number: 80
+`
+
+func TestCheckSatisfy(t *testing.T) {
+	testCases := map[string]struct {
+		data        string
+		annotations string
+		// globalAuthURL is the global authentication of the override
+		globalAuthURL string
+		expected      []string
+	}{
+		"no annotation": {
+			data:        "  proxy-body-size: 8m",
+			annotations: `    nginx.ingress.kubernetes.io/whitelist-source-range: 10.0.0.0/8`,
+		},
+		"several mechanisms": {
+			data: "  proxy-body-size: 8m",
+			annotations: `    nginx.ingress.kubernetes.io/satisfy: any
+    nginx.ingress.kubernetes.io/whitelist-source-range: 10.0.0.0/8
+    nginx.ingress.kubernetes.io/auth-url: https://auth.example.com/verify`,
+		},
+		"global authentication": {
+			data: "  global-auth-url: https://login.example.com/verify",
+			annotations: `    nginx.ingress.kubernetes.io/satisfy: all
+    nginx.ingress.kubernetes.io/whitelist-source-range: 10.0.0.0/8`,
+		},
+		"global authentication of the override": {
+			data: "  proxy-body-size: 8m",
+			annotations: `    nginx.ingress.kubernetes.io/satisfy: any
+    nginx.ingress.kubernetes.io/whitelist-source-range: 10.0.0.0/8`,
+			globalAuthURL: "https://login.example.com/verify",
+		},
+		"invalid": {
+			data: "  proxy-body-size: 8m",
+			annotations: `    nginx.ingress.kubernetes.io/satisfy: some
+    nginx.ingress.kubernetes.io/whitelist-source-range: 10.0.0.0/8
+    nginx.ingress.kubernetes.io/auth-url: https://auth.example.com/verify`,
+			expected: []string{"satisfy-invalid"},
+		},
+		"single mechanism": {
+			data: "  proxy-body-size: 8m",
+			annotations: `    nginx.ingress.kubernetes.io/satisfy: any
+    nginx.ingress.kubernetes.io/whitelist-source-range: 10.0.0.0/8`,
+			expected: []string{"satisfy-ineffective"},
+		},
+		"no mechanism": {
+			data:        "  proxy-body-size: 8m",
+			annotations: `    nginx.ingress.kubernetes.io/satisfy: any`,
+			expected:    []string{"satisfy-ineffective"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, fmt.Sprintf(satisfyManifests, tc.data, tc.annotations))
+			if tc.globalAuthURL != "" {
+				n.cfg.GlobalExternalAuth = &ngx_config.GlobalExternalAuth{URL: tc.globalAuthURL}
+			}
+			rules := []string{}
+			for _, f := range n.checkSatisfy(ingresses, cfg) {
+				rules = append(rules, f.Rule)
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(rules, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, rules)
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	openapierrors "k8s.io/kube-openapi/pkg/validation/errors"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+)
+
+// schemaRoot is the root of the JSONPath of the schema errors
+var schemaRoot = field.NewPath("$")
+
+// types whose JSON form differs from their Go structure
+var (
+	intOrStringType = reflect.TypeOf(intstr.IntOrString{})
+	quantityType    = reflect.TypeOf(resource.Quantity{})
+	timeType        = reflect.TypeOf(metav1.Time{})
+	microTimeType   = reflect.TypeOf(metav1.MicroTime{})
+	rawMessageType  = reflect.TypeOf(json.RawMessage{})
+	rawExtension    = reflect.TypeOf(runtime.RawExtension{})
+	bytesType       = reflect.TypeOf([]byte{})
+)
+
+// schemaError lists the schema errors of a manifest
+type schemaError struct {
+	kind string
+	errs field.ErrorList
+}
+
+func (e *schemaError) Error() string {
+	messages := make([]string, 0, len(e.errs))
+	for _, err := range e.errs {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("%v does not match its schema:\n  %v", e.kind, strings.Join(messages, "\n  "))
+}
+
+// checkSchema validates a manifest against the OpenAPI schema of the Go type
+// obj, from which the OpenAPI schemas of Kubernetes are generated, and the
+// required fields of the objects used to build the configuration. It runs
+// before the manifest is decoded, so malformed manifests fail with the
+// location of the error instead of deep in the build of the configuration.
+func checkSchema(raw []byte, obj runtime.Object, kind string) error {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return err
+	}
+
+	errs := typeSchemaErrors(value, reflect.TypeOf(obj), schemaRoot)
+	if len(errs) == 0 {
+		// the structure is valid, check the content
+		if err := json.Unmarshal(raw, obj); err != nil {
+			return err
+		}
+		errs = requiredFieldErrors(obj)
+	}
+	if len(errs) > 0 {
+		return &schemaError{kind: kind, errs: errs}
+	}
+	return nil
+}
+
+// structuralSchemas caches the OpenAPI schemas of the Go types by reflect.Type
+var structuralSchemas sync.Map
+
+// typeSchemaErrors validates value, decoded from JSON, against the structural
+// OpenAPI schema of t: the fields unknown to t or of a different type
+func typeSchemaErrors(value interface{}, t reflect.Type, path *field.Path) field.ErrorList {
+	result := validate.NewSchemaValidator(structuralSchema(t), nil, path.String(), strfmt.Default).Validate(value)
+
+	errs := field.ErrorList{}
+	for _, err := range result.Errors {
+		errs = append(errs, openAPIFieldError(err))
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+// openAPIFieldError converts an error of the OpenAPI validation to a field
+// error, using the name of the field as its path
+func openAPIFieldError(err error) *field.Error {
+	v, ok := err.(*openapierrors.Validation)
+	if !ok {
+		return field.InternalError(schemaRoot, err)
+	}
+
+	// the messages start with the name of the field, which is the path of
+	// the field error
+	detail := strings.TrimPrefix(strings.TrimPrefix(v.Error(), v.Name+" "), "in "+v.In+" ")
+	switch v.Code() {
+	case openapierrors.UnallowedPropertyCode:
+		return &field.Error{Type: field.ErrorTypeForbidden, Field: v.Name + "." + fmt.Sprint(v.Value), Detail: "unknown field"}
+	case openapierrors.InvalidTypeCode:
+		// the value of the error is the type of the value
+		return &field.Error{Type: field.ErrorTypeTypeInvalid, Field: v.Name, BadValue: field.OmitValueType{}, Detail: strings.SplitN(detail, ": ", 2)[0]}
+	case openapierrors.RequiredFailCode:
+		return &field.Error{Type: field.ErrorTypeRequired, Field: v.Name}
+	}
+	return &field.Error{Type: field.ErrorTypeInvalid, Field: v.Name, BadValue: v.Value, Detail: detail}
+}
+
+// structuralSchema returns the structural OpenAPI schema of the Go type t, as
+// generated by openapi-gen for the Kubernetes types: objects reject the
+// fields they do not declare. The required fields are not part of it, as
+// they are declared by comments of the types, and are checked by
+// requiredFieldErrors.
+func structuralSchema(t reflect.Type) *spec.Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if schema, ok := structuralSchemas.Load(t); ok {
+		return schema.(*spec.Schema)
+	}
+
+	// null is accepted as the zero value of the field, as by the API server
+	schema := &spec.Schema{SchemaProps: spec.SchemaProps{Nullable: true}}
+	// recursive types refer to the schema being built
+	if cached, loaded := structuralSchemas.LoadOrStore(t, schema); loaded {
+		return cached.(*spec.Schema)
+	}
+
+	switch t {
+	case intOrStringType:
+		schema.Type = spec.StringOrArray{"integer", "string"}
+		return schema
+	case quantityType:
+		schema.Type = spec.StringOrArray{"number", "string"}
+		return schema
+	case timeType, microTimeType:
+		schema.Type = spec.StringOrArray{"string"}
+		schema.Format = "date-time"
+		return schema
+	case bytesType:
+		schema.Type = spec.StringOrArray{"string"}
+		schema.Format = "byte"
+		return schema
+	case rawMessageType, rawExtension:
+		// any value
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		schema.Type = spec.StringOrArray{"object"}
+		schema.Properties = map[string]spec.Schema{}
+		schema.AdditionalProperties = &spec.SchemaOrBool{Allows: false}
+		for name, ft := range jsonFields(t) {
+			schema.Properties[name] = *structuralSchema(ft)
+		}
+	case reflect.Slice, reflect.Array:
+		schema.Type = spec.StringOrArray{"array"}
+		schema.Items = &spec.SchemaOrArray{Schema: structuralSchema(t.Elem())}
+	case reflect.Map:
+		schema.Type = spec.StringOrArray{"object"}
+		schema.AdditionalProperties = &spec.SchemaOrBool{Allows: true, Schema: structuralSchema(t.Elem())}
+	case reflect.String:
+		schema.Type = spec.StringOrArray{"string"}
+	case reflect.Bool:
+		schema.Type = spec.StringOrArray{"boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		schema.Type = spec.StringOrArray{"integer"}
+	case reflect.Float32, reflect.Float64:
+		schema.Type = spec.StringOrArray{"number"}
+	}
+	return schema
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jsonFields returns the types of the fields of a struct by JSON name,
+// including the fields of inlined structs
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" || len(tag) > 1 && tag[1] == "inline" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			for k, v := range jsonFields(ft) {
+				fields[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// requiredFieldErrors checks the fields the build of the configuration
+// relies on
+func requiredFieldErrors(obj runtime.Object) field.ErrorList {
+	errs := field.ErrorList{}
+	switch o := obj.(type) {
+	case *networking.Ingress:
+		if o.Name == "" {
+			errs = append(errs, field.Required(schemaRoot.Child("metadata", "name"), ""))
+		}
+		spec := schemaRoot.Child("spec")
+		if o.Spec.DefaultBackend != nil {
+			errs = append(errs, ingressBackendErrors(o.Spec.DefaultBackend, spec.Child("defaultBackend"))...)
+		}
+		for i, rule := range o.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			paths := spec.Child("rules").Index(i).Child("http", "paths")
+			for j, p := range rule.HTTP.Paths {
+				errs = append(errs, ingressPathErrors(&p, paths.Index(j))...)
+			}
+		}
+	case *apiv1.Service:
+		if o.Name == "" {
+			errs = append(errs, field.Required(schemaRoot.Child("metadata", "name"), ""))
+		}
+		for i, port := range o.Spec.Ports {
+			if port.Port < 1 || port.Port > 65535 {
+				errs = append(errs, field.Invalid(schemaRoot.Child("spec", "ports").Index(i).Child("port"), port.Port, "must be between 1 and 65535"))
+			}
+		}
+	case *apiv1.Secret:
+		if o.Name == "" {
+			errs = append(errs, field.Required(schemaRoot.Child("metadata", "name"), ""))
+		}
+		if o.Type == apiv1.SecretTypeTLS {
+			for _, key := range []string{apiv1.TLSCertKey, apiv1.TLSPrivateKeyKey} {
+				if _, ok := o.Data[key]; !ok && o.StringData[key] == "" {
+					errs = append(errs, field.Required(schemaRoot.Child("data").Key(key), "required by the kubernetes.io/tls type"))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+func ingressPathErrors(p *networking.HTTPIngressPath, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	switch {
+	case p.PathType == nil:
+		errs = append(errs, field.Required(path.Child("pathType"), ""))
+	case *p.PathType != networking.PathTypeExact && *p.PathType != networking.PathTypePrefix && *p.PathType != networking.PathTypeImplementationSpecific:
+		errs = append(errs, field.NotSupported(path.Child("pathType"), *p.PathType,
+			[]string{string(networking.PathTypeExact), string(networking.PathTypePrefix), string(networking.PathTypeImplementationSpecific)}))
+	case *p.PathType != networking.PathTypeImplementationSpecific && !strings.HasPrefix(p.Path, "/"):
+		errs = append(errs, field.Invalid(path.Child("path"), p.Path, "must be an absolute path"))
+	}
+	return append(errs, ingressBackendErrors(&p.Backend, path.Child("backend"))...)
+}
+
+func ingressBackendErrors(b *networking.IngressBackend, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	switch {
+	case b.Service == nil && b.Resource == nil:
+		errs = append(errs, field.Required(path, "one of service or resource is required"))
+	case b.Service != nil && b.Resource != nil:
+		errs = append(errs, field.Forbidden(path.Child("resource"), "can not be set with service"))
+	case b.Service != nil:
+		service := path.Child("service")
+		if b.Service.Name == "" {
+			errs = append(errs, field.Required(service.Child("name"), ""))
+		}
+		port := b.Service.Port
+		switch {
+		case port.Name == "" && port.Number == 0:
+			errs = append(errs, field.Required(service.Child("port"), "one of number or name is required"))
+		case port.Name != "" && port.Number != 0:
+			errs = append(errs, field.Forbidden(service.Child("port", "number"), "can not be set with name"))
+		case port.Number < 0 || port.Number > 65535:
+			errs = append(errs, field.Invalid(service.Child("port", "number"), port.Number, "must be between 1 and 65535"))
+		}
+	}
+	return errs
+}
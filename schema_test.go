@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestCheckSchema(t *testing.T) {
+	ingress := func(path string) string {
+		return `{
+  "apiVersion": "networking.k8s.io/v1",
+  "kind": "Ingress",
+  "metadata": {"name": "web", "namespace": "default", "annotations": {"a": "b"}},
+  "spec": {"rules": [{"host": "web.example.com", "http": {"paths": [` + path + `]}}]}
+}`
+	}
+
+	testCases := map[string]struct {
+		raw      string
+		obj      runtime.Object
+		expected []string
+	}{
+		"valid Ingress": {
+			raw: ingress(`{"path": "/", "pathType": "Prefix", "backend": {"service": {"name": "web", "port": {"number": 80}}}}`),
+			obj: &networking.Ingress{},
+		},
+		"unknown field": {
+			raw:      ingress(`{"path": "/", "pathType": "Prefix", "backend": {"serviceName": "web", "servicePort": 80}}`),
+			obj:      &networking.Ingress{},
+			expected: []string{"$.spec.rules[0].http.paths[0].backend.serviceName: Forbidden: unknown field", "$.spec.rules[0].http.paths[0].backend.servicePort: Forbidden: unknown field"},
+		},
+		"wrong types": {
+			raw:      ingress(`{"path": 1, "pathType": "Prefix", "backend": {"service": {"name": "web", "port": {"number": "80"}}}}`),
+			obj:      &networking.Ingress{},
+			expected: []string{"$.spec.rules[0].http.paths[0].backend.service.port.number: Invalid value: must be of type integer", "$.spec.rules[0].http.paths[0].path: Invalid value: must be of type string"},
+		},
+		"annotations not a map of strings": {
+			raw:      strings.Replace(ingress(`{"path": "/", "pathType": "Prefix", "backend": {"service": {"name": "web", "port": {"number": 80}}}}`), `"a": "b"`, `"a": true`, 1),
+			obj:      &networking.Ingress{},
+			expected: []string{"$.metadata.annotations.a: Invalid value: must be of type string"},
+		},
+		"missing pathType": {
+			raw:      ingress(`{"path": "/", "backend": {"service": {"name": "web", "port": {"number": 80}}}}`),
+			obj:      &networking.Ingress{},
+			expected: []string{"$.spec.rules[0].http.paths[0].pathType: Required value"},
+		},
+		"unsupported pathType": {
+			raw:      ingress(`{"path": "/", "pathType": "Regex", "backend": {"service": {"name": "web", "port": {"number": 80}}}}`),
+			obj:      &networking.Ingress{},
+			expected: []string{`$.spec.rules[0].http.paths[0].pathType: Unsupported value: "Regex"`},
+		},
+		"relative Prefix path": {
+			raw:      ingress(`{"path": "api", "pathType": "Prefix", "backend": {"service": {"name": "web", "port": {"number": 80}}}}`),
+			obj:      &networking.Ingress{},
+			expected: []string{`$.spec.rules[0].http.paths[0].path: Invalid value: "api": must be an absolute path`},
+		},
+		"port with a name and a number": {
+			raw:      ingress(`{"path": "/", "pathType": "Prefix", "backend": {"service": {"name": "web", "port": {"number": 80, "name": "http"}}}}`),
+			obj:      &networking.Ingress{},
+			expected: []string{"$.spec.rules[0].http.paths[0].backend.service.port.number: Forbidden: can not be set with name"},
+		},
+		"backend without a service": {
+			raw:      ingress(`{"path": "/", "pathType": "Prefix", "backend": {}}`),
+			obj:      &networking.Ingress{},
+			expected: []string{"$.spec.rules[0].http.paths[0].backend: Required value: one of service or resource is required"},
+		},
+		"Service port out of range": {
+			raw:      `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "web"}, "spec": {"ports": [{"port": 80, "targetPort": "http"}, {"port": 70000}]}}`,
+			obj:      &apiv1.Service{},
+			expected: []string{"$.spec.ports[1].port: Invalid value: 70000: must be between 1 and 65535"},
+		},
+		"Service without a name": {
+			raw:      `{"apiVersion": "v1", "kind": "Service", "metadata": {"namespace": "default"}}`,
+			obj:      &apiv1.Service{},
+			expected: []string{"$.metadata.name: Required value"},
+		},
+		"TLS Secret without key": {
+			raw:      `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "web-tls"}, "type": "kubernetes.io/tls", "data": {"tls.crt": "Y2VydA=="}}`,
+			obj:      &apiv1.Secret{},
+			expected: []string{"$.data[tls.key]: Required value: required by the kubernetes.io/tls type"},
+		},
+		"int or string": {
+			raw:      `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "web"}, "spec": {"ports": [{"port": 80, "targetPort": 8080}, {"port": 81, "targetPort": true}]}}`,
+			obj:      &apiv1.Service{},
+			expected: []string{"$.spec.ports[1].targetPort: Invalid value: must be of type integer,string"},
+		},
+		"Secret data not base64": {
+			raw:      `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "web"}, "data": {"password": "not base64!"}}`,
+			obj:      &apiv1.Secret{},
+			expected: []string{"$.data.password: Invalid value: must be of type byte"},
+		},
+		"invalid timestamp": {
+			raw:      `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "web", "creationTimestamp": "yesterday"}}`,
+			obj:      &apiv1.Service{},
+			expected: []string{"$.metadata.creationTimestamp: Invalid value: must be of type date-time"},
+		},
+		"null fields": {
+			raw: `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "web", "labels": null}, "spec": {"ports": null}}`,
+			obj: &apiv1.Service{},
+		},
+		"unknown top-level field": {
+			raw:      `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "web"}, "specs": {}}`,
+			obj:      &apiv1.Service{},
+			expected: []string{"$.specs: Forbidden: unknown field"},
+		},
+		"TLS Secret with stringData": {
+			raw: `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "web-tls"}, "type": "kubernetes.io/tls", "stringData": {"tls.crt": "cert", "tls.key": "key"}}`,
+			obj: &apiv1.Secret{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := checkSchema([]byte(tc.raw), tc.obj, "Object")
+			if len(tc.expected) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var schemaErr *schemaError
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("expected a schema error, got %v", err)
+			}
+			if !strings.HasPrefix(err.Error(), "Object does not match its schema:\n  ") {
+				t.Errorf("unexpected message %q", err.Error())
+			}
+			if len(schemaErr.errs) != len(tc.expected) {
+				t.Fatalf("expected %q, got %v", tc.expected, schemaErr.errs)
+			}
+			for i, expected := range tc.expected {
+				if got := schemaErr.errs[i].Error(); !strings.HasPrefix(got, expected) {
+					t.Errorf("expected %q, got %q", expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestStructuralSchema(t *testing.T) {
+	schema := structuralSchema(reflect.TypeOf(&networking.Ingress{}))
+	if !schema.Type.Contains("object") || schema.AdditionalProperties == nil || schema.AdditionalProperties.Allows {
+		t.Errorf("expected an object rejecting unknown fields, got %+v", schema)
+	}
+	// the fields of the inlined TypeMeta are fields of the object
+	for _, name := range []string{"apiVersion", "kind", "metadata", "spec", "status"} {
+		if _, ok := schema.Properties[name]; !ok {
+			t.Errorf("expected the property %v, got %v", name, sortedKeys(schema.Properties))
+		}
+	}
+	annotations := schema.Properties["metadata"].Properties["annotations"]
+	if !annotations.Type.Contains("object") || annotations.AdditionalProperties == nil || !annotations.AdditionalProperties.Schema.Type.Contains("string") {
+		t.Errorf("expected the annotations to be a map of strings, got %+v", annotations)
+	}
+	if structuralSchema(reflect.TypeOf(networking.Ingress{})) != schema {
+		t.Error("expected the schema to be cached")
+	}
+}
+
+func TestConvertIngressSchema(t *testing.T) {
+	raw := `{
+  "apiVersion": "networking.k8s.io/v1beta1",
+  "kind": "Ingress",
+  "metadata": {"name": "web"},
+  "spec": {"rules": [{"http": {"paths": [{"path": "/", "backend": {"serviceName": "web"}}]}}]}
+}`
+	_, err := convertIngress([]byte(raw))
+	if err == nil || !strings.Contains(err.Error(), "$.spec.rules[0].http.paths[0].backend.service.port: Required value") {
+		t.Errorf("expected the missing port of the converted Ingress, got %v", err)
+	}
+
+	_, err = convertIngress([]byte(strings.Replace(raw, `"serviceName"`, `"service"`, 1)))
+	if err == nil || !strings.Contains(err.Error(), "backend.service: Forbidden: unknown field") {
+		t.Errorf("expected a v1 field to be unknown in v1beta1, got %v", err)
+	}
+}
+
+func TestLoadManifestSchema(t *testing.T) {
+	manifest := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: 80
+    protocl: TCP
+`
+	err := newMemoryStore("").LoadManifest(strings.NewReader(manifest), "service.yaml")
+	expected := "service.yaml:1 (document 0): Service does not match its schema:\n  $.spec.ports[0].protocl: Forbidden: unknown field"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+)
+
+// storages of the files of the Secrets during a test
+const (
+	// secretStorageDisk writes the files in the work directory
+	secretStorageDisk = "disk"
+	// secretStorageTmpfs writes the files in a directory on a tmpfs
+	secretStorageTmpfs = "tmpfs"
+	// secretStorageMemfd keeps the files in anonymous memory, nginx opens
+	// them through /proc
+	secretStorageMemfd = "memfd"
+
+	defaultSecretTmpfsDir = "/dev/shm"
+)
+
+var secretStorages = []string{secretStorageDisk, secretStorageTmpfs, secretStorageMemfd}
+
+// secretFiles stores the certificates, private keys, CAs and CRLs of the
+// Secrets nginx reads during a test
+type secretFiles interface {
+	// write stores data as the file name and returns the path nginx opens
+	write(name string, data []byte) (string, error)
+	// Close removes the files
+	Close() error
+}
+
+// addSecretStorageFlags registers the flags selecting where the files of the
+// Secrets are written
+func addSecretStorageFlags(fs *flag.FlagSet, cfg *NginxConfiguration) {
+	cfg.SecretStorage = secretStorageDisk
+	fs.Func("secret-storage",
+		"Where the certificates and keys of the Secrets are written for nginx -t: disk (the work directory), tmpfs or memfd (Linux only), so private keys never reach persistent storage. Defaults to disk.", func(value string) error {
+			if !containsString(secretStorages, value) {
+				return fmt.Errorf("unknown secret storage %q, expected one of %v", value, secretStorages)
+			}
+			cfg.SecretStorage = value
+			return nil
+		})
+	fs.StringVar(&cfg.SecretTmpfsDir, "secret-tmpfs-dir", defaultSecretTmpfsDir,
+		"Directory on a tmpfs used with --secret-storage=tmpfs.")
+}
+
+// newSecretFiles returns the storage selected with --secret-storage
+func (n *NGINXController) newSecretFiles() (secretFiles, error) {
+	switch n.cfg.SecretStorage {
+	case secretStorageMemfd:
+		return newMemfdSecretFiles()
+	case secretStorageTmpfs:
+		tmpfs, err := isTmpfs(n.cfg.SecretTmpfsDir)
+		if err != nil {
+			return nil, err
+		}
+		if !tmpfs {
+			return nil, fmt.Errorf("%v is not on a tmpfs", n.cfg.SecretTmpfsDir)
+		}
+		return newDirSecretFiles(n.cfg.SecretTmpfsDir)
+	default:
+		dir, err := n.workDirPath("certs")
+		if err != nil {
+			return nil, err
+		}
+		return newDirSecretFiles(dir)
+	}
+}
+
+// dirSecretFiles writes the files in a temporary directory
+type dirSecretFiles struct {
+	dir string
+}
+
+func newDirSecretFiles(parent string) (*dirSecretFiles, error) {
+	dir, err := os.MkdirTemp(parent, "secrets-")
+	if err != nil {
+		return nil, err
+	}
+	return &dirSecretFiles{dir: dir}, nil
+}
+
+func (d *dirSecretFiles) write(name string, data []byte) (string, error) {
+	path := filepath.Join(d.dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (d *dirSecretFiles) Close() error {
+	return os.RemoveAll(d.dir)
+}
+
+// secretData returns the content of the certificate, CA and CRL files of the
+// configuration, by file name, from the Secrets of the store. The files of
+// missing Secrets are left out.
+func (n *NGINXController) secretData(cfg *Configuration) map[string][]byte {
+	data := map[string][]byte{}
+	add := func(fileName string, content []byte) {
+		if fileName != "" && len(content) > 0 {
+			data[fileName] = content
+		}
+	}
+	addSSLCert := func(c *SSLCert) {
+		if c == nil {
+			return
+		}
+		add(c.PemFileName, []byte(c.PemCertKey))
+		if secret, err := n.store.GetSecret(fmt.Sprintf("%v/%v", c.Namespace, c.Name)); err == nil {
+			add(c.CAFileName, secret.Data["ca.crt"])
+			add(c.CRLFileName, secret.Data["ca.crl"])
+		}
+	}
+	addAuthSSLCert := func(c *resolver.AuthSSLCert) {
+		if c.Secret == "" || strings.HasPrefix(c.Secret, spiffeScheme+"://") {
+			return
+		}
+		secret, err := n.store.GetSecret(c.Secret)
+		if err != nil {
+			return
+		}
+		if cert, ok := secret.Data[apiv1.TLSCertKey]; ok {
+			add(c.PemFileName, append(append(append([]byte{}, cert...), '\n'), secret.Data[apiv1.TLSPrivateKeyKey]...))
+		} else {
+			add(c.PemFileName, secret.Data["ca.crt"])
+		}
+		add(c.CAFileName, secret.Data["ca.crt"])
+		add(c.CRLFileName, secret.Data["ca.crl"])
+	}
+
+	addSSLCert(cfg.DefaultSSLCertificate)
+	for _, server := range cfg.Servers {
+		addSSLCert(server.SSLCert)
+		addAuthSSLCert(&server.CertificateAuth.AuthSSLCert)
+		addAuthSSLCert(&server.ProxySSL.AuthSSLCert)
+		for _, loc := range server.Locations {
+			addAuthSSLCert(&loc.ProxySSL.AuthSSLCert)
+		}
+	}
+	return data
+}
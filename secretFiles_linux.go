@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// memfdSecretFiles keeps the files in memory file descriptors of the
+// validator, nginx opens them through /proc/<pid>/fd
+type memfdSecretFiles struct {
+	files []*os.File
+}
+
+func newMemfdSecretFiles() (secretFiles, error) {
+	return &memfdSecretFiles{}, nil
+}
+
+func (m *memfdSecretFiles) write(name string, data []byte) (string, error) {
+	fd, err := unix.MemfdCreate(name, unix.MFD_CLOEXEC)
+	if err != nil {
+		return "", fmt.Errorf("error creating memfd: %w", err)
+	}
+
+	f := os.NewFile(uintptr(fd), name)
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return "", err
+	}
+	m.files = append(m.files, f)
+	return fmt.Sprintf("/proc/%d/fd/%d", os.Getpid(), fd), nil
+}
+
+func (m *memfdSecretFiles) Close() error {
+	for _, f := range m.files {
+		f.Close()
+	}
+	m.files = nil
+	return nil
+}
+
+// isTmpfs returns true if dir is on a tmpfs
+func isTmpfs(dir string) (bool, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return false, err
+	}
+	return st.Type == unix.TMPFS_MAGIC, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func newMemfdSecretFiles() (secretFiles, error) {
+	return nil, errors.New("--secret-storage=memfd is only supported on Linux")
+}
+
+func isTmpfs(string) (bool, error) {
+	return false, errors.New("--secret-storage=tmpfs is only supported on Linux")
+}
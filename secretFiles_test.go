@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"flag"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAddSecretStorageFlags(t *testing.T) {
+	testCases := map[string]struct {
+		args      []string
+		storage   string
+		tmpfsDir  string
+		expectErr string
+	}{
+		"defaults": {
+			storage:  secretStorageDisk,
+			tmpfsDir: defaultSecretTmpfsDir,
+		},
+		"memfd": {
+			args:     []string{"--secret-storage=memfd"},
+			storage:  secretStorageMemfd,
+			tmpfsDir: defaultSecretTmpfsDir,
+		},
+		"tmpfs in another directory": {
+			args:     []string{"--secret-storage=tmpfs", "--secret-tmpfs-dir=/run/secrets"},
+			storage:  secretStorageTmpfs,
+			tmpfsDir: "/run/secrets",
+		},
+		"unknown storage": {
+			args:      []string{"--secret-storage=s3"},
+			expectErr: `unknown secret storage "s3"`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cfg := &NginxConfiguration{}
+			fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			addSecretStorageFlags(fs, cfg)
+
+			err := fs.Parse(tc.args)
+			if tc.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectErr) {
+					t.Errorf("expected an error containing %q, got %v", tc.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.SecretStorage != tc.storage || cfg.SecretTmpfsDir != tc.tmpfsDir {
+				t.Errorf("expected storage %q in %q, got %q in %q", tc.storage, tc.tmpfsDir, cfg.SecretStorage, cfg.SecretTmpfsDir)
+			}
+		})
+	}
+}
+
+func TestNewSecretFiles(t *testing.T) {
+	workDir := t.TempDir()
+	notTmpfs := t.TempDir()
+	if tmpfs, err := isTmpfs(notTmpfs); err == nil && tmpfs {
+		t.Skipf("%v is on a tmpfs", notTmpfs)
+	}
+
+	testCases := map[string]struct {
+		storage  string
+		tmpfsDir string
+		// expected is the directory of the written files, empty for
+		// memfd
+		expected  string
+		expectErr string
+		linux     bool
+	}{
+		"disk": {
+			storage:  secretStorageDisk,
+			expected: filepath.Join(workDir, "certs"),
+		},
+		"tmpfs": {
+			storage:  secretStorageTmpfs,
+			tmpfsDir: defaultSecretTmpfsDir,
+			expected: defaultSecretTmpfsDir,
+			linux:    true,
+		},
+		"tmpfs directory not on a tmpfs": {
+			storage:   secretStorageTmpfs,
+			tmpfsDir:  notTmpfs,
+			expectErr: "is not on a tmpfs",
+			linux:     true,
+		},
+		"memfd": {
+			storage: secretStorageMemfd,
+			linux:   true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if tc.linux && runtime.GOOS != "linux" {
+				t.Skip("only supported on Linux")
+			}
+			if tc.storage == secretStorageTmpfs && tc.expectErr == "" {
+				if tmpfs, err := isTmpfs(tc.tmpfsDir); err != nil || !tmpfs {
+					t.Skipf("%v is not on a tmpfs", tc.tmpfsDir)
+				}
+			}
+
+			n := newTestController(t, "")
+			n.cfg.WorkDir = workDir
+			n.cfg.SecretStorage = tc.storage
+			n.cfg.SecretTmpfsDir = tc.tmpfsDir
+
+			files, err := n.newSecretFiles()
+			if tc.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectErr) {
+					t.Errorf("expected an error containing %q, got %v", tc.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			path, err := files.write("web.pem", []byte("certificate"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.expected != "" && filepath.Dir(filepath.Dir(path)) != tc.expected {
+				t.Errorf("expected the file in %v, got %v", tc.expected, path)
+			}
+			if tc.expected == "" && !strings.HasPrefix(path, "/proc/") {
+				t.Errorf("expected a file of /proc, got %v", path)
+			}
+			if data, err := os.ReadFile(path); err != nil || string(data) != "certificate" {
+				t.Errorf("expected the content to be readable from %v, got %q, %v", path, data, err)
+			}
+
+			if err := files.Close(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("expected %v to be removed, got %v", path, err)
+			}
+		})
+	}
+}
+
+func TestSecretData(t *testing.T) {
+	now := time.Now()
+	ca, caKey, caPEM, _ := testCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "client ca"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}, nil, nil)
+	_, _, certPEM, keyPEM := testCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "web.example.com"},
+		DNSNames:     []string{"web.example.com"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(24 * time.Hour),
+	}, ca, caKey)
+
+	manifests := strings.Replace(authTLSIngress(map[string]string{"auth-tls-secret": "default/client-ca"}),
+		"  - hosts: [web.example.com]\n", "  - hosts: [web.example.com]\n    secretName: web-tls\n", 1)
+	n := newTestController(t, manifests)
+	for _, secret := range []*apiv1.Secret{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-tls", Namespace: "default"},
+			Type:       apiv1.SecretTypeTLS,
+			Data:       map[string][]byte{apiv1.TLSCertKey: certPEM, apiv1.TLSPrivateKeyKey: keyPEM},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "client-ca", Namespace: "default"},
+			Data:       map[string][]byte{"ca.crt": caPEM},
+		},
+	} {
+		if err := n.store.(*memoryStore).Add(secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, _, cfg := n.getConfiguration(n.store.ListIngresses())
+
+	server := findServer(cfg, "web.example.com")
+	if server == nil {
+		t.Fatal("expected a server for web.example.com")
+	}
+	if server.SSLCert == nil || server.SSLCert.PemFileName == "" {
+		t.Fatalf("expected the certificate of web-tls, got %+v", server.SSLCert)
+	}
+	clientCA := server.CertificateAuth.AuthSSLCert.CAFileName
+	if clientCA == "" {
+		t.Fatalf("expected the CA of client-ca, got %+v", server.CertificateAuth.AuthSSLCert)
+	}
+
+	data := n.secretData(cfg)
+	if pem := data[server.SSLCert.PemFileName]; !bytes.Contains(pem, certPEM) || !bytes.Contains(pem, keyPEM) {
+		t.Errorf("expected the certificate and key of web-tls, got %q", pem)
+	}
+	if !bytes.Equal(data[clientCA], caPEM) {
+		t.Errorf("expected ca.crt of client-ca, got %q", data[clientCA])
+	}
+
+	// ca.crt is removed from the Secret: the sandbox stubs the file
+	if err := n.store.(*memoryStore).Add(&apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "client-ca", Namespace: "default"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := n.secretData(cfg)[clientCA]; ok {
+		t.Errorf("expected no file for client-ca without ca.crt")
+	}
+}
+
+func TestSandboxAddSecrets(t *testing.T) {
+	stub, err := stubCertificates()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sb := newTestSandbox(t)
+	files, err := newDirSecretFiles(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer files.Close()
+
+	if err := sb.addSecrets(files, map[string][]byte{"/ssl/default-web.pem": []byte("web certificate")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := sb.addCertificates(&Configuration{
+		Servers: []*Server{{
+			Hostname: "web.example.com",
+			SSLCert:  &SSLCert{PemFileName: "/ssl/default-web.pem", CAFileName: "/ssl/default-ca.pem"},
+		}},
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	local := filepath.Join(files.dir, "default-web.pem")
+	if sb.files["/ssl/default-web.pem"] != local {
+		t.Errorf("expected the Secret to be stored in %v, got %v", local, sb.files["/ssl/default-web.pem"])
+	}
+	if data, _ := os.ReadFile(local); string(data) != "web certificate" {
+		t.Errorf("expected the content of the Secret, got %q", data)
+	}
+	if data, _ := os.ReadFile(sb.local("/ssl/default-ca.pem")); !bytes.Equal(data, stub.ca) {
+		t.Errorf("expected a stub for the CA without a Secret, got %q", data)
+	}
+
+	var out bytes.Buffer
+	if err := sb.rewrite(strings.NewReader("ssl_certificate /ssl/default-web.pem;\n"), &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "ssl_certificate " + local + ";\n"; out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
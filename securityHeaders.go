@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// securityHeaderRules contains the checks of the security header audit,
+// executed only when the audit is enabled
+var securityHeaderRules = []validationRule{
+	(*NGINXController).checkSecurityHeaders,
+}
+
+// defaultSecurityHeadersBaseline contains the response headers every host
+// must send, following the MoJ security guidance for web applications
+var defaultSecurityHeadersBaseline = []string{
+	"Strict-Transport-Security",
+	"X-Frame-Options",
+	"X-Content-Type-Options",
+	"Content-Security-Policy",
+	"Referrer-Policy",
+}
+
+// checkSecurityHeaders reports, for every host, the headers of the baseline
+// missing from the responses of its locations. Hosts sending all of them get
+// an info finding, so the report lists the compliance of every host.
+func (n *NGINXController) checkSecurityHeaders(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	global := n.store.GetBackendConfiguration()
+
+	baseline := n.cfg.SecurityHeadersBaseline
+	if len(baseline) == 0 {
+		baseline = defaultSecurityHeadersBaseline
+	}
+
+	globalHeaders := map[string]bool{}
+	if global.AddHeaders != "" {
+		cm, err := n.store.GetConfigMap(global.AddHeaders)
+		if err != nil {
+			klog.Warningf("Error reading add-headers ConfigMap %q: %v", global.AddHeaders, err)
+		} else {
+			for header := range cm.Data {
+				globalHeaders[http.CanonicalHeaderKey(header)] = true
+			}
+		}
+	}
+
+	for _, server := range cfg.Servers {
+		if server.Hostname == "_" || len(server.Locations) == 0 {
+			continue
+		}
+
+		serverHeaders := snippetResponseHeaders(server.ServerSnippet)
+		if global.HSTS && server.SSLCert != nil {
+			serverHeaders["Strict-Transport-Security"] = true
+		}
+
+		// missing contains the paths of the locations missing each header
+		missing := map[string][]string{}
+		for _, loc := range server.Locations {
+			locHeaders := snippetResponseHeaders(loc.ConfigurationSnippet)
+			for header := range loc.CustomHeaders.Headers {
+				locHeaders[http.CanonicalHeaderKey(header)] = true
+			}
+
+			for _, header := range baseline {
+				header = http.CanonicalHeaderKey(header)
+				if !globalHeaders[header] && !serverHeaders[header] && !locHeaders[header] {
+					missing[header] = append(missing[header], loc.Path)
+				}
+			}
+		}
+
+		if len(missing) == 0 {
+			findings = append(findings, Finding{
+				Rule:     "security-headers",
+				Severity: SeverityInfo,
+				Ingress:  serverIngress(server),
+				Host:     server.Hostname,
+				Message:  fmt.Sprintf("compliant: all the responses include %v", strings.Join(baseline, ", ")),
+			})
+			continue
+		}
+
+		headers := make([]string, 0, len(missing))
+		for header := range missing {
+			headers = append(headers, header)
+		}
+		sort.Strings(headers)
+
+		details := make([]string, 0, len(headers))
+		for _, header := range headers {
+			if len(missing[header]) == len(server.Locations) {
+				details = append(details, header)
+			} else {
+				details = append(details, fmt.Sprintf("%v (%v)", header, strings.Join(missing[header], ", ")))
+			}
+		}
+
+		findings = append(findings, Finding{
+			Rule:     "security-headers",
+			Severity: SeverityWarning,
+			Ingress:  serverIngress(server),
+			Host:     server.Hostname,
+			Message: fmt.Sprintf("not compliant: missing %v; add them with the add-headers ConfigMap or the custom-headers annotation",
+				strings.Join(details, ", ")),
+		})
+	}
+
+	return findings
+}
+
+// snippetResponseHeaders returns the response headers set by a snippet
+func snippetResponseHeaders(snippet string) map[string]bool {
+	headers := map[string]bool{}
+	for _, m := range responseHeaderDirective.FindAllStringSubmatch(snippet, -1) {
+		if strings.HasPrefix(strings.TrimSpace(m[0]), "more_clear_headers") {
+			continue
+		}
+		headers[http.CanonicalHeaderKey(m[1])] = true
+	}
+	return headers
+}
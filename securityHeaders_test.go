@@ -0,0 +1,125 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+const securityHeadersConfigMaps = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+data:
+  allow-snippet-annotations: "true"
+  annotations-risk-level: Critical
+  add-headers: ingress-nginx/security-headers
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: security-headers
+  namespace: ingress-nginx
+data:
+  X-Frame-Options: DENY
+  x-content-type-options: nosniff
+  Referrer-Policy: same-origin
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/configuration-snippet: |
+      more_set_headers "Content-Security-Policy: default-src 'self'";
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: api
+  namespace: default
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /api
+        pathType: Prefix
+        backend:
+          service:
+            name: api
+            port:
+              number: 80
+`
+
+func TestCheckSecurityHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseline []string
+		expected []string
+	}{
+		{
+			name: "default baseline",
+			expected: []string{
+				"warning: not compliant: missing Content-Security-Policy (/api/, /api), Strict-Transport-Security; add them with the add-headers ConfigMap or the custom-headers annotation",
+			},
+		},
+		{
+			name:     "custom baseline",
+			baseline: []string{"x-frame-options", "Referrer-Policy"},
+			expected: []string{"info: compliant: all the responses include x-frame-options, Referrer-Policy"},
+		},
+		{
+			name:     "header set by a single location",
+			baseline: []string{"Content-Security-Policy"},
+			expected: []string{"warning: not compliant: missing Content-Security-Policy (/api/, /api); add them with the add-headers ConfigMap or the custom-headers annotation"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, securityHeadersConfigMaps)
+			n.cfg.SecurityHeadersBaseline = tc.baseline
+
+			got := []string{}
+			for _, f := range n.checkSecurityHeaders(ingresses, cfg) {
+				if f.Host != "web.example.com" {
+					t.Errorf("unexpected host %q", f.Host)
+				}
+				got = append(got, string(f.Severity)+": "+f.Message)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestSnippetResponseHeaders(t *testing.T) {
+	snippet := `
+add_header X-Frame-Options DENY always;
+more_set_headers "Referrer-Policy: no-referrer";
+more_clear_headers Server;
+proxy_set_header X-Forwarded-Host $host;
+`
+	expected := map[string]bool{"X-Frame-Options": true, "Referrer-Policy": true}
+	if got := snippetResponseHeaders(snippet); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+)
+
+// cookieNameRegex matches the cookie names allowed by RFC 6265, which are
+// tokens: no control characters, spaces or separators
+var cookieNameRegex = regexp.MustCompile("^[!#$%&'*+\\-.^_`|~0-9A-Za-z]+$")
+
+// cookieSecondsRegex matches the values of session-cookie-expires and
+// session-cookie-max-age, a number of seconds
+var cookieSecondsRegex = regexp.MustCompile(`^[0-9]+$`)
+
+// cookieSameSiteValues are the values of the SameSite attribute understood by
+// the browsers
+var cookieSameSiteValues = []string{"None", "Lax", "Strict"}
+
+// checkSessionAffinity validates the cookie session affinity of the
+// locations. The ingress controller drops the invalid expires and max-age
+// values, the annotations are read to report them.
+func (n *NGINXController) checkSessionAffinity(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	checked := map[*Ingress]bool{}
+
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			if loc.Ingress == nil || loc.Ingress.ParsedAnnotations == nil {
+				continue
+			}
+			affinity := loc.Ingress.ParsedAnnotations.SessionAffinity
+			if affinity.Type != "cookie" {
+				continue
+			}
+			cookie := affinity.Cookie
+
+			// the path of the cookie defaults to the path of the location,
+			// which is a regular expression with use-regex
+			if loc.Ingress.ParsedAnnotations.Rewrite.UseRegex && cookie.Path == "" {
+				findings = append(findings, newLocationFinding("session-cookie-path-missing", SeverityWarning, server, loc,
+					"location %q uses a regular expression but session-cookie-path is not set; the browsers do not send the cookie %v back and the session is lost",
+					loc.Path, cookie.Name))
+			}
+
+			if checked[loc.Ingress] {
+				continue
+			}
+			checked[loc.Ingress] = true
+
+			if !cookieNameRegex.MatchString(cookie.Name) {
+				findings = append(findings, newLocationFinding("session-cookie-invalid", SeverityError, server, loc,
+					"session-cookie-name %q is not a valid cookie name; it can not contain spaces, control characters or ()<>@,;:\\\"/[]?={}",
+					cookie.Name))
+			}
+			for _, name := range []string{"session-cookie-expires", "session-cookie-max-age"} {
+				value, ok := loc.Ingress.Annotations[parser.GetAnnotationWithPrefix(name)]
+				if ok && !cookieSecondsRegex.MatchString(value) {
+					findings = append(findings, newLocationFinding("session-cookie-invalid", SeverityError, server, loc,
+						"%v %q is not a number of seconds; the ingress controller ignores it and the cookie expires with the session of the browser",
+						name, value))
+				}
+			}
+
+			if cookie.SameSite != "" {
+				if !containsString(cookieSameSiteValues, cookie.SameSite) {
+					findings = append(findings, newLocationFinding("session-cookie-invalid", SeverityError, server, loc,
+						"session-cookie-samesite %q is not one of %v; the browsers ignore the attribute",
+						cookie.SameSite, strings.Join(cookieSameSiteValues, ", ")))
+				} else if cookie.SameSite == "None" && !cookie.Secure {
+					findings = append(findings, newLocationFinding("session-cookie-insecure", SeverityWarning, server, loc,
+						"session-cookie-samesite is None but session-cookie-secure is not enabled; the browsers reject the cookie %v",
+						cookie.Name))
+				}
+			}
+
+			if affinity.Mode == "persistent" && cookie.ChangeOnFailure {
+				findings = append(findings, newLocationFinding("session-affinity-conflict", SeverityWarning, server, loc,
+					"session-cookie-change-on-failure moves the sessions to another endpoint when a request fails, which contradicts affinity-mode persistent"))
+			}
+		}
+	}
+
+	return findings
+}
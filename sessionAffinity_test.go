@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// sessionAffinityManifests is completed with the annotations of the
+// Ingress, which has two paths
+const sessionAffinityManifests = `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/affinity: cookie
+%v
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /api/.*
+        pathType: ImplementationSpecific
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+      - path: /static/.*
+        pathType: ImplementationSpecific
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`
+
+func TestCheckSessionAffinity(t *testing.T) {
+	testCases := map[string]struct {
+		annotations string
+		expected    []string
+	}{
+		"valid": {
+			annotations: `    nginx.ingress.kubernetes.io/session-cookie-name: route
+    nginx.ingress.kubernetes.io/session-cookie-expires: "172800"
+    nginx.ingress.kubernetes.io/session-cookie-max-age: "172800"
+    nginx.ingress.kubernetes.io/session-cookie-samesite: None
+    nginx.ingress.kubernetes.io/session-cookie-secure: "true"`,
+		},
+		"regular expressions without cookie path": {
+			annotations: `    nginx.ingress.kubernetes.io/use-regex: "true"`,
+			// every location is reported
+			expected: []string{"session-cookie-path-missing", "session-cookie-path-missing"},
+		},
+		"regular expressions with cookie path": {
+			annotations: `    nginx.ingress.kubernetes.io/use-regex: "true"
+    nginx.ingress.kubernetes.io/session-cookie-path: /`,
+		},
+		"invalid name": {
+			annotations: `    nginx.ingress.kubernetes.io/session-cookie-name: "route id"`,
+			expected:    []string{"session-cookie-invalid"},
+		},
+		"invalid expires and max-age": {
+			annotations: `    nginx.ingress.kubernetes.io/session-cookie-expires: 2d
+    nginx.ingress.kubernetes.io/session-cookie-max-age: "-1"`,
+			expected: []string{"session-cookie-invalid", "session-cookie-invalid"},
+		},
+		"invalid SameSite": {
+			annotations: `    nginx.ingress.kubernetes.io/session-cookie-samesite: lax`,
+			expected:    []string{"session-cookie-invalid"},
+		},
+		"SameSite None without Secure": {
+			annotations: `    nginx.ingress.kubernetes.io/session-cookie-samesite: None`,
+			expected:    []string{"session-cookie-insecure"},
+		},
+		"persistent affinity changing on failure": {
+			annotations: `    nginx.ingress.kubernetes.io/affinity-mode: persistent
+    nginx.ingress.kubernetes.io/session-cookie-change-on-failure: "true"`,
+			expected: []string{"session-affinity-conflict"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, fmt.Sprintf(sessionAffinityManifests, tc.annotations))
+			rules := []string{}
+			for _, f := range n.checkSessionAffinity(ingresses, cfg) {
+				rules = append(rules, f.Rule)
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(rules, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, rules)
+			}
+		})
+	}
+
+	// the Ingresses without cookie affinity are not checked
+	n, ingresses, cfg := testConfiguration(t, isolationManifests)
+	if findings := n.checkSessionAffinity(ingresses, cfg); len(findings) != 0 {
+		t.Errorf("expected no findings, got %+v", findings)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+var (
+	proxyHTTPVersionDirective = regexp.MustCompile(`(?m)^\s*proxy_http_version\s+([^;\s]+)\s*;`)
+	// directives setting request headers sent to the backends
+	requestHeaderDirective = regexp.MustCompile(`(?mi)^\s*(?:proxy_set_header|more_set_input_headers|more_clear_input_headers|grpc_set_header)\s+["']?([A-Za-z0-9_-]+)`)
+	// directives setting response headers sent to the clients
+	responseHeaderDirective = regexp.MustCompile(`(?mi)^\s*(?:add_header|more_set_headers|more_clear_headers)\s+["']?([A-Za-z0-9_-]+)`)
+)
+
+// framingHeaders determine where a message ends. Setting them from the
+// configuration makes nginx and the backends disagree on the request
+// boundaries.
+var framingHeaders = []string{"transfer-encoding", "content-length"}
+
+// checkRequestSmuggling reports configurations allowing request smuggling or
+// desynchronisation of the keepalive connections between nginx and the
+// backends
+func (n *NGINXController) checkRequestSmuggling(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	global := n.store.GetBackendConfiguration()
+	keepalive := global.UpstreamKeepaliveConnections > 0
+
+	for _, header := range n.proxySetHeaders(global.ProxySetHeaders) {
+		if containsString(framingHeaders, strings.ToLower(header)) {
+			findings = append(findings, Finding{
+				Rule:     "smuggling-framing-header",
+				Severity: SeverityError,
+				Message:  "the proxy-set-headers ConfigMap sets the " + header + " header sent to every backend; a value that does not match the body lets requests be smuggled to the backends",
+			})
+		}
+	}
+
+	for _, server := range cfg.Servers {
+		if server.ServerSnippet != "" && len(server.Locations) > 0 {
+			findings = append(findings, snippetSmugglingFindings("server-snippet", server.ServerSnippet, keepalive, server, server.Locations[0])...)
+		}
+
+		for _, loc := range server.Locations {
+			if loc.ConfigurationSnippet != "" {
+				findings = append(findings, snippetSmugglingFindings("configuration-snippet", loc.ConfigurationSnippet, keepalive, server, loc)...)
+			}
+
+			for header := range loc.CustomHeaders.Headers {
+				if containsString(framingHeaders, strings.ToLower(header)) {
+					findings = append(findings, newLocationFinding("smuggling-framing-header", SeverityError, server, loc,
+						"custom-headers sets the %v header on location %q; clients and intermediate proxies can desynchronise on the response boundaries",
+						header, loc.Path))
+				}
+			}
+
+			if keepalive && loc.Proxy.ProxyHTTPVersion == "1.0" {
+				findings = append(findings, newLocationFinding("smuggling-proxy-http-version", SeverityWarning, server, loc,
+					"location %q uses proxy-http-version 1.0 while upstream keepalive is enabled; nginx closes the connections after every request to this backend",
+					loc.Path))
+			}
+
+			if global.EnableUnderscoresInHeaders && loc.Service != nil && n.isHeaderMergingBackend(k8s.MetaNamespaceKey(loc.Service)) {
+				findings = append(findings, newLocationFinding("smuggling-underscores-in-headers", SeverityWarning, server, loc,
+					"enable-underscores-in-headers is on and Service %v merges headers whose names only differ in dashes and underscores; a client can spoof headers set by nginx (e.g. X-Forwarded-For as X_Forwarded_For)",
+					k8s.MetaNamespaceKey(loc.Service)))
+			}
+		}
+	}
+
+	return findings
+}
+
+// snippetSmugglingFindings checks the directives of a snippet changing the
+// protocol version or the framing headers
+func snippetSmugglingFindings(name, snippet string, keepalive bool, server *Server, loc *Location) []Finding {
+	findings := []Finding{}
+
+	if m := proxyHTTPVersionDirective.FindStringSubmatch(snippet); m != nil && keepalive && m[1] != "1.1" {
+		findings = append(findings, newLocationFinding("smuggling-proxy-http-version", SeverityError, server, loc,
+			"%v sets proxy_http_version %v while upstream keepalive is enabled; HTTP/1.0 requests on reused connections are framed differently by nginx and the backend, use the proxy-http-version annotation instead",
+			name, m[1]))
+	}
+
+	for _, m := range requestHeaderDirective.FindAllStringSubmatch(snippet, -1) {
+		if containsString(framingHeaders, strings.ToLower(m[1])) {
+			findings = append(findings, newLocationFinding("smuggling-framing-header", SeverityError, server, loc,
+				"%v sets the %v request header; a value that does not match the body lets requests be smuggled to the backend",
+				name, m[1]))
+		}
+	}
+	for _, m := range responseHeaderDirective.FindAllStringSubmatch(snippet, -1) {
+		if containsString(framingHeaders, strings.ToLower(m[1])) {
+			findings = append(findings, newLocationFinding("smuggling-framing-header", SeverityError, server, loc,
+				"%v sets the %v response header; clients and intermediate proxies can desynchronise on the response boundaries",
+				name, m[1]))
+		}
+	}
+
+	return findings
+}
+
+// proxySetHeaders returns the names of the headers defined in the
+// proxy-set-headers ConfigMap
+func (n *NGINXController) proxySetHeaders(configmapName string) []string {
+	if configmapName == "" {
+		return nil
+	}
+
+	cm, err := n.store.GetConfigMap(configmapName)
+	if err != nil {
+		klog.Warningf("Error reading proxy-set-headers ConfigMap %q: %v", configmapName, err)
+		return nil
+	}
+
+	headers := make([]string, 0, len(cm.Data))
+	for header := range cm.Data {
+		headers = append(headers, header)
+	}
+	sort.Strings(headers)
+	return headers
+}
+
+// isHeaderMergingBackend returns true if the Service matches one of the
+// patterns of backends known to normalise header names
+func (n *NGINXController) isHeaderMergingBackend(service string) bool {
+	for _, pattern := range n.cfg.HeaderMergingBackends {
+		if ok, _ := path.Match(pattern, service); ok {
+			return true
+		}
+	}
+	return false
+}
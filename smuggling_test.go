@@ -0,0 +1,129 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSnippetSmugglingFindings(t *testing.T) {
+	server := &Server{Hostname: "web.example.com"}
+	loc := &Location{Path: "/"}
+
+	tests := []struct {
+		name      string
+		snippet   string
+		keepalive bool
+		expected  []string
+	}{
+		{
+			name:      "HTTP/1.1",
+			snippet:   "proxy_http_version 1.1;",
+			keepalive: true,
+		},
+		{
+			name:      "HTTP/1.0 with keepalive",
+			snippet:   "  proxy_http_version 1.0;",
+			keepalive: true,
+			expected:  []string{"smuggling-proxy-http-version"},
+		},
+		{
+			name:    "HTTP/1.0 without keepalive",
+			snippet: "proxy_http_version 1.0;",
+		},
+		{
+			name:     "framing request header",
+			snippet:  `proxy_set_header Transfer-Encoding "";`,
+			expected: []string{"smuggling-framing-header"},
+		},
+		{
+			name:     "framing response header",
+			snippet:  "more_set_headers 'Content-Length: 0';",
+			expected: []string{"smuggling-framing-header"},
+		},
+		{
+			name:    "other headers",
+			snippet: "proxy_set_header X-Request-Start $msec;\nadd_header X-Frame-Options DENY;",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rules := []string{}
+			for _, f := range snippetSmugglingFindings("configuration-snippet", tc.snippet, tc.keepalive, server, loc) {
+				rules = append(rules, f.Rule)
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(rules, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, rules)
+			}
+		})
+	}
+}
+
+const smugglingManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+data:
+  enable-underscores-in-headers: "true"
+  proxy-set-headers: ingress-nginx/custom-headers
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: custom-headers
+  namespace: ingress-nginx
+data:
+  Content-Length: "0"
+  X-Team: platform
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: legacy
+  namespace: default
+spec:
+  ports:
+  - name: http
+    port: 80
+    protocol: TCP
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: legacy
+  namespace: default
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: legacy.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: legacy
+            port:
+              number: 80
+`
+
+func TestCheckRequestSmuggling(t *testing.T) {
+	n, ingresses, cfg := testConfiguration(t, smugglingManifests)
+	n.cfg.HeaderMergingBackends = []string{"default/*"}
+
+	findings := n.checkRequestSmuggling(ingresses, cfg)
+
+	framing := findingsWithRule(findings, "smuggling-framing-header")
+	if len(framing) != 1 || framing[0].Host != "" {
+		t.Errorf("expected a global finding for the Content-Length of proxy-set-headers, got %v", framing)
+	}
+	underscores := findingsWithRule(findings, "smuggling-underscores-in-headers")
+	if len(underscores) != 1 || underscores[0].Host != "legacy.example.com" {
+		t.Errorf("expected a finding for the header merging backend, got %v", underscores)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// snapshotFile is the name of the golden configuration summary of a snapshot
+// case
+const snapshotFile = "summary.conf"
+
+// writeConfigSummary writes a summary of the built configuration in a layout
+// resembling nginx.conf: one upstream per backend and one server per host,
+// with the effective settings of the server and its locations as directives.
+// It is not the nginx.conf rendered by the template of ingress-nginx, which
+// the validator does not render. Endpoints are left out, so the summary only
+// changes when the Ingresses or the ConfigMap do. It is streamed to w, one
+// block at a time.
+func (n *NGINXController) writeConfigSummary(w io.Writer, cfg *Configuration) error {
+	b := bufio.NewWriter(w)
+	separate := false
+	block := func() {
+		if separate {
+			b.WriteString("\n")
+		}
+		separate = true
+	}
+
+	backends := append([]*Backend{}, cfg.Backends...)
+	sort.Slice(backends, func(i, j int) bool { return backends[i].Name < backends[j].Name })
+	for _, backend := range backends {
+		block()
+		fmt.Fprintf(b, "upstream %v {\n", backend.Name)
+		if backend.Service != nil {
+			fmt.Fprintf(b, "    # service %v/%v port %v\n", backend.Service.Namespace, backend.Service.Name, backend.Port.String())
+		}
+		if backend.NoServer {
+			p := backend.TrafficShapingPolicy
+			fmt.Fprintf(b, "    # canary weight %d/%d header %q value %q pattern %q cookie %q\n",
+				p.Weight, p.WeightTotal, p.Header, p.HeaderValue, p.HeaderPattern, p.Cookie)
+		}
+		for _, alternative := range backend.AlternativeBackends {
+			fmt.Fprintf(b, "    # alternative backend %v\n", alternative)
+		}
+		if backend.LoadBalancing != "" {
+			writeSummaryDirective(b, "    ", "load-balance", backend.LoadBalancing)
+		}
+		b.WriteString("}\n")
+	}
+
+	servers := append([]*Server{}, cfg.Servers...)
+	sort.Slice(servers, func(i, j int) bool { return servers[i].Hostname < servers[j].Hostname })
+	for _, server := range servers {
+		ehc, err := n.effectiveHostConfiguration(cfg, server.Hostname)
+		if err != nil {
+			return err
+		}
+
+		block()
+		b.WriteString("server {\n")
+		fmt.Fprintf(b, "    server_name %v;\n", strings.Join(append([]string{ehc.Hostname}, ehc.Aliases...), " "))
+		for _, s := range ehc.Server {
+			writeSummaryDirective(b, "    ", s.Key, s.Value)
+		}
+
+		for _, loc := range ehc.Locations {
+			fmt.Fprintf(b, "\n    location %v {\n", loc.Path)
+			fmt.Fprintf(b, "        # pathType %v, Ingress %v\n", loc.PathType, loc.Ingress)
+			writeSummaryDirective(b, "        ", "proxy-pass", loc.Backend)
+			for _, s := range loc.Settings {
+				writeSummaryDirective(b, "        ", s.Key, s.Value)
+			}
+			b.WriteString("    }\n")
+		}
+		b.WriteString("}\n")
+	}
+
+	return b.Flush()
+}
+
+// writeSummaryDirective writes a setting as a directive, the name using
+// underscores as in nginx.conf
+func writeSummaryDirective(w io.Writer, indent, key, value string) {
+	if value == "" || strings.ContainsAny(value, " ;") {
+		value = fmt.Sprintf("%q", value)
+	}
+	fmt.Fprintf(w, "%v%v %v;\n", indent, strings.ReplaceAll(key, "-", "_"), value)
+}
+
+// snapshotCase summarizes the configuration built from the manifests of the
+// case directory with the flags of cfg and compares it with its golden file.
+// With update, the golden file is written instead. It returns a description
+// of the first difference, empty when the snapshot matches.
+func snapshotCase(cfg *NginxConfiguration, dir string, update bool) (string, error) {
+	s := newMemoryStore(cfg.ConfigMapName)
+	if err := s.LoadManifests(dir); err != nil {
+		return "", fmt.Errorf("error loading manifests: %w", err)
+	}
+
+	n := newOfflineController(cfg, s)
+	_, _, configuration := n.getConfiguration(s.ListIngresses())
+
+	golden := filepath.Join(dir, snapshotFile)
+	if update {
+		f, err := os.Create(golden)
+		if err != nil {
+			return "", err
+		}
+		if err := n.writeConfigSummary(f, configuration); err != nil {
+			f.Close()
+			return "", err
+		}
+		return "", f.Close()
+	}
+
+	expected, err := os.Open(golden)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Sprintf("%v does not exist, run with --update to create it", golden), nil
+		}
+		return "", err
+	}
+	defer expected.Close()
+
+	// the summary is compared while it is written
+	actual, w := io.Pipe()
+	defer actual.Close()
+	go func() {
+		w.CloseWithError(n.writeConfigSummary(w, configuration))
+	}()
+	return snapshotDiff(expected, actual)
+}
+
+// snapshotDiff describes the first line differing between the golden file
+// and the configuration summary
+func snapshotDiff(expected, actual io.Reader) (string, error) {
+	e, a := bufio.NewReader(expected), bufio.NewReader(actual)
+	for line := 1; ; line++ {
+		eLine, err := e.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		aLine, err := a.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+
+		eText, aText := strings.TrimRight(eLine, "\r\n"), strings.TrimRight(aLine, "\r\n")
+		switch {
+		case eLine == "" && aLine == "":
+			return "", nil
+		case eLine == aLine:
+			continue
+		case eLine == "":
+			return fmt.Sprintf("line %d: unexpected %q", line, aText), nil
+		case aLine == "":
+			return fmt.Sprintf("line %d: missing %q", line, eText), nil
+		case eText == aText:
+			return "the files differ in their line endings", nil
+		default:
+			return fmt.Sprintf("line %d: expected %q, got %q", line, eText, aText), nil
+		}
+	}
+}
+
+// snapshotCases returns the case directories of dir, the subdirectories
+// containing manifests, or dir itself when it has none
+func snapshotCases(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	cases := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			cases = append(cases, filepath.Join(dir, entry.Name()))
+		}
+	}
+	if len(cases) == 0 {
+		cases = append(cases, dir)
+	}
+	return cases, nil
+}
+
+// runSnapshot compares the configuration summary of each case of the
+// snapshot directory with its golden summary.conf
+func runSnapshot(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	cfg := &NginxConfiguration{}
+	addConfigurationFlags(fs, cfg)
+
+	dir := fs.String("dir", "", "Directory of the snapshot cases: subdirectories with manifests and a golden "+snapshotFile+".")
+	update := fs.Bool("update", false, "Write the golden files instead of comparing them.")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitInternal
+	}
+
+	if *dir == "" {
+		fmt.Fprintln(stderr, "--dir is required")
+		return exitInternal
+	}
+
+	cases, err := snapshotCases(*dir)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitInternal
+	}
+
+	code := exitOK
+	for _, c := range cases {
+		diff, err := snapshotCase(cfg, c, *update)
+		switch {
+		case err != nil:
+			fmt.Fprintf(stderr, "%v: %v\n", c, err)
+			return exitInternal
+		case *update:
+			fmt.Fprintf(stdout, "%v: updated\n", c)
+		case diff != "":
+			fmt.Fprintf(stdout, "%v: %v\n", c, diff)
+			code = exitErrors
+		default:
+			fmt.Fprintf(stdout, "%v: ok\n", c)
+		}
+	}
+	return code
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+var updateSnapshots = flag.Bool("update", false, "Write the golden configuration summaries of testdata/snapshot.")
+
+// checkSnapshots compares every case of dir with its golden file, failing
+// the test on differences. With update, the golden files are written.
+func checkSnapshots(t *testing.T, dir string, update bool) {
+	t.Helper()
+
+	cases, err := snapshotCases(dir)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	for _, c := range cases {
+		diff, err := snapshotCase(&NginxConfiguration{ConfigMapName: defaultConfigMapName}, c, update)
+		switch {
+		case err != nil:
+			t.Errorf("%v: %v", c, err)
+		case diff != "":
+			t.Errorf("%v: %v", c, diff)
+		}
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	checkSnapshots(t, filepath.Join("testdata", "snapshot"), *updateSnapshots)
+}
+
+func TestSnapshotCase(t *testing.T) {
+	dir := t.TempDir()
+	manifests, err := os.ReadFile(filepath.Join("testdata", "snapshot", "canary", "ingresses.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ingresses.yaml"), manifests, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &NginxConfiguration{ConfigMapName: defaultConfigMapName}
+	diff, err := snapshotCase(cfg, dir, false)
+	if err != nil || !strings.Contains(diff, "does not exist, run with --update to create it") {
+		t.Fatalf("expected the missing golden file to be reported, got %q, %v", diff, err)
+	}
+
+	if _, err := snapshotCase(cfg, dir, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	golden := filepath.Join(dir, snapshotFile)
+	data, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := strings.Replace(string(data), "proxy_body_size 8m;", "proxy_body_size 1m;", 1)
+	if err := os.WriteFile(golden, []byte(changed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err = snapshotCase(cfg, dir, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(diff, `expected "        proxy_body_size 1m;", got "        proxy_body_size 8m;"`) {
+		t.Errorf("expected the changed directive to be reported, got %q", diff)
+	}
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	tests := []struct {
+		expected, actual, want string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{"a\nb\n", "a\nc\n", `line 2: expected "b", got "c"`},
+		{"a\n", "a\nb\n", `line 2: unexpected "b"`},
+		{"a\nb\n", "a\n", `line 2: missing "b"`},
+		{"a\r\nb\r\n", "a\nb\n", "the files differ in their line endings"},
+	}
+	for _, tc := range tests {
+		got, err := snapshotDiff(strings.NewReader(tc.expected), strings.NewReader(tc.actual))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != tc.want {
+			t.Errorf("expected %q comparing %q and %q, got %q", tc.want, tc.expected, tc.actual, got)
+		}
+	}
+}
+
+func TestSnapshotDiffReadError(t *testing.T) {
+	readErr := errors.New("read error")
+	if _, err := snapshotDiff(strings.NewReader("a\n"), iotest.ErrReader(readErr)); !errors.Is(err, readErr) {
+		t.Errorf("expected the read error of the rendered configuration, got %v", err)
+	}
+	if _, err := snapshotDiff(iotest.ErrReader(readErr), strings.NewReader("a\n")); !errors.Is(err, readErr) {
+		t.Errorf("expected the read error of the golden file, got %v", err)
+	}
+}
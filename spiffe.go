@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto/sha1" //nolint:gosec // sha1 is only used to detect changes, as for secrets
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/util/file"
+)
+
+// file names used by the SPIFFE helper when writing the X.509 SVID to disk
+const (
+	svidCertFileName   = "svid.pem"
+	svidKeyFileName    = "svid_key.pem"
+	svidBundleFileName = "svid_bundle.pem"
+
+	spiffeScheme = "spiffe"
+)
+
+// getSPIFFEClientCert returns the client identity used to authenticate against
+// backends when it is issued by a service mesh instead of a Kubernetes Secret.
+// It returns nil if SPIFFE is not configured or the SVID is not valid.
+func (n *NGINXController) getSPIFFEClientCert() *resolver.AuthSSLCert {
+	if n.cfg.SPIFFESVIDDirectory == "" {
+		return nil
+	}
+
+	cert, err := loadSPIFFESVID(n.cfg.SPIFFESVIDDirectory, n.cfg.SPIFFETrustDomain, n.paths(), time.Now())
+	if err != nil {
+		klog.Errorf("Error loading SPIFFE SVID from %q, client cert authentication against backends disabled: %v",
+			n.cfg.SPIFFESVIDDirectory, err)
+		return nil
+	}
+
+	return cert
+}
+
+// loadSPIFFESVID reads the X.509 SVID, private key and trust bundle written by
+// the SPIFFE helper in dir, checks the SVID belongs to trustDomain, is valid at
+// the given time and chains to the bundle, and stores the certificate and key
+// in a single PEM file as expected by proxy_ssl_certificate. The file names
+// returned are the ones of nginx, mapped with paths.
+func loadSPIFFESVID(dir, trustDomain string, paths pathMapper, now time.Time) (*resolver.AuthSSLCert, error) {
+	certPEM, err := os.ReadFile(filepath.Join(dir, svidCertFileName))
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(dir, svidKeyFileName))
+	if err != nil {
+		return nil, err
+	}
+	bundleFileName := filepath.Join(dir, svidBundleFileName)
+	bundlePEM, err := os.ReadFile(bundleFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return nil, fmt.Errorf("invalid SVID key pair: %w", err)
+	}
+
+	chain, err := parsePEMCertificates(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SVID: %w", err)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no certificate found in %v", svidCertFileName)
+	}
+	leaf := chain[0]
+
+	if err := checkSPIFFEID(leaf, trustDomain); err != nil {
+		return nil, err
+	}
+
+	if now.Before(leaf.NotBefore) {
+		return nil, fmt.Errorf("SVID is not valid before %v", leaf.NotBefore)
+	}
+	if now.After(leaf.NotAfter) {
+		return nil, fmt.Errorf("SVID expired on %v", leaf.NotAfter)
+	}
+
+	bundle, err := parsePEMCertificates(bundlePEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trust bundle: %w", err)
+	}
+	if len(bundle) == 0 {
+		return nil, fmt.Errorf("no certificate found in %v", svidBundleFileName)
+	}
+
+	roots := x509.NewCertPool()
+	for _, ca := range bundle {
+		roots.AddCert(ca)
+	}
+	intermediates := x509.NewCertPool()
+	for _, ca := range chain[1:] {
+		intermediates.AddCert(ca)
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SVID is not signed by the trust bundle: %w", err)
+	}
+
+	pemCertKey := append(append([]byte{}, certPEM...), keyPEM...)
+	pemFileName := filepath.Join(file.DefaultSSLDirectory, fmt.Sprintf("spiffe-%v.pem", trustDomain))
+	//nolint:gosec // the file contains a private key and is only readable by the owner
+	if err := os.WriteFile(paths.local(pemFileName), pemCertKey, 0o600); err != nil {
+		return nil, fmt.Errorf("could not create PEM certificate file %v: %w", pemFileName, err)
+	}
+	caFileName, err := paths.nginx(bundleFileName)
+	if err != nil {
+		return nil, fmt.Errorf("trust bundle not readable by nginx: %w", err)
+	}
+
+	return &resolver.AuthSSLCert{
+		Secret:      fmt.Sprintf("%v://%v", spiffeScheme, trustDomain),
+		CAFileName:  caFileName,
+		CASHA:       sha1Hex(bundlePEM),
+		PemFileName: pemFileName,
+	}, nil
+}
+
+// checkSPIFFEID verifies the certificate contains exactly one SPIFFE ID and
+// that it belongs to trustDomain
+func checkSPIFFEID(cert *x509.Certificate, trustDomain string) error {
+	if len(cert.URIs) != 1 {
+		return fmt.Errorf("SVID must contain exactly one URI SAN, found %v", len(cert.URIs))
+	}
+
+	id := cert.URIs[0]
+	if id.Scheme != spiffeScheme {
+		return fmt.Errorf("URI SAN %q is not a SPIFFE ID", id.String())
+	}
+
+	if !strings.EqualFold(id.Host, trustDomain) {
+		return fmt.Errorf("SPIFFE ID %q does not belong to trust domain %q", id.String(), trustDomain)
+	}
+
+	return nil
+}
+
+// parsePEMCertificates returns all the certificates contained in the PEM data
+func parsePEMCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	return certs, nil
+}
+
+func sha1Hex(data []byte) string {
+	//nolint:gosec // sha1 is only used to detect changes
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertificate returns a PEM certificate and key issued by parent, or
+// self-signed when parent is nil
+func testCertificate(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestLoadSPIFFESVID(t *testing.T) {
+	now := time.Now()
+	ca, caKey, caPEM, _ := testCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "spiffe ca"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	_, _, otherCAPEM, _ := testCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "other ca"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	svid := func(id string, notAfter time.Time) ([]byte, []byte) {
+		uri, err := url.Parse(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, certPEM, keyPEM := testCertificate(t, &x509.Certificate{
+			SerialNumber: big.NewInt(3),
+			NotBefore:    now.Add(-time.Hour),
+			NotAfter:     notAfter,
+			URIs:         []*url.URL{uri},
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}, ca, caKey)
+		return certPEM, keyPEM
+	}
+
+	tests := []struct {
+		name   string
+		id     string
+		expiry time.Time
+		bundle []byte
+		err    string
+	}{
+		{
+			name:   "other trust domain",
+			id:     "spiffe://other.org/ns/ingress-nginx/sa/controller",
+			expiry: now.Add(time.Hour),
+			bundle: caPEM,
+			err:    "does not belong to trust domain",
+		},
+		{
+			name:   "not a SPIFFE ID",
+			id:     "https://example.org/controller",
+			expiry: now.Add(time.Hour),
+			bundle: caPEM,
+			err:    "is not a SPIFFE ID",
+		},
+		{
+			name:   "expired",
+			id:     "spiffe://example.org/ns/ingress-nginx/sa/controller",
+			expiry: now.Add(-time.Minute),
+			bundle: caPEM,
+			err:    "SVID expired",
+		},
+		{
+			name:   "not signed by the bundle",
+			id:     "spiffe://example.org/ns/ingress-nginx/sa/controller",
+			expiry: now.Add(time.Hour),
+			bundle: otherCAPEM,
+			err:    "not signed by the trust bundle",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			certPEM, keyPEM := svid(tc.id, tc.expiry)
+			for name, data := range map[string][]byte{
+				svidCertFileName:   certPEM,
+				svidKeyFileName:    keyPEM,
+				svidBundleFileName: tc.bundle,
+			} {
+				if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err := loadSPIFFESVID(dir, "example.org", pathMapper{}, now)
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+
+	if _, err := loadSPIFFESVID(t.TempDir(), "example.org", pathMapper{}, now); err == nil {
+		t.Errorf("expected an error for a directory without SVID")
+	}
+}
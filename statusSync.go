@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+const (
+	// statusUpdateInterval is the interval between updates of the status of
+	// the Ingresses, the same used by ingress-nginx
+	statusUpdateInterval = 60 * time.Second
+	// statusUpdateTimeout limits the time spent updating one Ingress
+	statusUpdateTimeout = 10 * time.Second
+)
+
+// statusSyncer keeps status.loadBalancer of the Ingresses handled by the
+// controller in sync with PublishService or PublishStatusAddress, which
+// external-dns uses to create the DNS records of the hosts
+type statusSyncer struct {
+	n      *NGINXController
+	client clientset.Interface
+}
+
+// newStatusSyncer returns the syncer of the status of the Ingresses
+func (n *NGINXController) newStatusSyncer() *statusSyncer {
+	return &statusSyncer{n: n, client: n.cfg.Client}
+}
+
+// Run updates the status of the Ingresses, while this replica is the leader,
+// until stopCh is closed
+func (s *statusSyncer) Run(stopCh chan struct{}) {
+	ticker := time.NewTicker(statusUpdateInterval)
+	defer ticker.Stop()
+
+	for {
+		if s.n.leading() {
+			addresses, err := s.loadBalancerStatus()
+			if err != nil {
+				klog.Warningf("Unable to update the status of the Ingresses: %v", err)
+			} else {
+				s.update(addresses)
+			}
+		}
+
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown removes the addresses from the status of the Ingresses when
+// UpdateStatusOnShutdown is enabled and this replica is the leader
+func (s *statusSyncer) Shutdown() {
+	if !s.n.cfg.UpdateStatusOnShutdown || !s.n.leading() {
+		klog.Info("Skipping the update of the status of the Ingresses on shutdown")
+		return
+	}
+
+	klog.Info("Removing the address from the status of the Ingresses")
+	s.update([]networking.IngressLoadBalancerIngress{})
+}
+
+// loadBalancerStatus returns the published addresses of the controller, or
+// the addresses of the nodes running the controller pods when neither
+// PublishService nor PublishStatusAddress is set, sorted
+func (s *statusSyncer) loadBalancerStatus() ([]networking.IngressLoadBalancerIngress, error) {
+	var addresses []string
+	var err error
+	if s.n.cfg.PublishService == "" && s.n.cfg.PublishStatusAddress == "" {
+		addresses, err = s.nodeAddresses()
+	} else {
+		addresses, err = s.n.publishedAddresses()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]networking.IngressLoadBalancerIngress, 0, len(addresses))
+	for _, addr := range addresses {
+		if net.ParseIP(addr) != nil {
+			status = append(status, networking.IngressLoadBalancerIngress{IP: addr})
+		} else {
+			status = append(status, networking.IngressLoadBalancerIngress{Hostname: addr})
+		}
+	}
+	sortLoadBalancerIngress(status)
+	return status, nil
+}
+
+// nodeAddresses returns the external addresses of the nodes running the
+// controller pods, or their internal addresses when UseNodeInternalIP is set
+// or the nodes have no external address, as ingress-nginx does without a
+// publish service
+func (s *statusSyncer) nodeAddresses() ([]string, error) {
+	if len(s.n.cfg.ControllerPodLabels) == 0 {
+		return nil, fmt.Errorf("--controller-pod-labels is required without --publish-service or --publish-status-address")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), statusUpdateTimeout)
+	defer cancel()
+
+	pods, err := s.client.CoreV1().Pods(s.n.cfg.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(s.n.cfg.ControllerPodLabels).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing controller pods: %w", err)
+	}
+
+	addresses := map[string]bool{}
+	nodes := map[string]bool{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName == "" || nodes[pod.Spec.NodeName] || pod.DeletionTimestamp != nil {
+			continue
+		}
+		nodes[pod.Spec.NodeName] = true
+
+		node, err := s.client.CoreV1().Nodes().Get(ctx, pod.Spec.NodeName, metav1.GetOptions{})
+		if err != nil {
+			klog.Warningf("Error getting node %v: %v", pod.Spec.NodeName, err)
+			continue
+		}
+		if addr := nodeAddress(node, s.n.cfg.UseNodeInternalIP); addr != "" {
+			addresses[addr] = true
+		}
+	}
+
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("the nodes of the controller pods have no address")
+	}
+	return sortedSet(addresses), nil
+}
+
+// nodeAddress returns the external address of the node, or its internal
+// address when useInternalIP is set or it has no external address
+func nodeAddress(node *apiv1.Node, useInternalIP bool) string {
+	external, internal := "", ""
+	for _, addr := range node.Status.Addresses {
+		switch addr.Type {
+		case apiv1.NodeExternalIP:
+			if external == "" {
+				external = addr.Address
+			}
+		case apiv1.NodeInternalIP:
+			if internal == "" {
+				internal = addr.Address
+			}
+		}
+	}
+
+	if external == "" || useInternalIP {
+		return internal
+	}
+	return external
+}
+
+// update sets the addresses in the status of the Ingresses handled by the
+// controller, skipping the ones already up to date
+func (s *statusSyncer) update(addresses []networking.IngressLoadBalancerIngress) {
+	ingresses, _ := s.n.filterIngressesByNamespace(s.n.store.ListIngresses())
+	ingresses, _ = s.n.filterIngressesByClass(ingresses)
+
+	for _, ing := range ingresses {
+		current := append([]networking.IngressLoadBalancerIngress{}, ing.Status.LoadBalancer.Ingress...)
+		sortLoadBalancerIngress(current)
+		if reflect.DeepEqual(current, addresses) || (len(current) == 0 && len(addresses) == 0) {
+			continue
+		}
+
+		key := k8s.MetaNamespaceKey(ing)
+		ctx, cancel := context.WithTimeout(context.Background(), statusUpdateTimeout)
+		latest, err := s.client.NetworkingV1().Ingresses(ing.Namespace).Get(ctx, ing.Name, metav1.GetOptions{})
+		if err != nil {
+			cancel()
+			klog.Warningf("Error getting Ingress %v: %v", key, err)
+			continue
+		}
+
+		latest.Status.LoadBalancer.Ingress = addresses
+		if _, err := s.client.NetworkingV1().Ingresses(ing.Namespace).UpdateStatus(ctx, latest, metav1.UpdateOptions{}); err != nil {
+			klog.Warningf("Error updating the status of Ingress %v: %v", key, err)
+		} else {
+			klog.V(2).Infof("Updated the status of Ingress %v to %v", key, addresses)
+		}
+		cancel()
+	}
+}
+
+func sortLoadBalancerIngress(lbi []networking.IngressLoadBalancerIngress) {
+	sort.SliceStable(lbi, func(a, b int) bool {
+		if lbi[a].IP != lbi[b].IP {
+			return lbi[a].IP < lbi[b].IP
+		}
+		return lbi[a].Hostname < lbi[b].Hostname
+	})
+}
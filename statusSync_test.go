@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/controller/ingressclass"
+)
+
+const statusSyncManifests = `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+spec:
+  ingressClassName: nginx
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: other
+  namespace: default
+spec:
+  ingressClassName: other
+`
+
+func TestStatusSyncer(t *testing.T) {
+	// hostnames sort first, their IP is empty
+	addresses := []networking.IngressLoadBalancerIngress{{Hostname: "lb.example.com"}, {IP: "10.0.0.1"}, {IP: "10.0.0.2"}}
+
+	tests := []struct {
+		name       string
+		onShutdown bool
+		expected   []networking.IngressLoadBalancerIngress
+	}{
+		{
+			name:       "addresses removed on shutdown",
+			onShutdown: true,
+			expected:   nil,
+		},
+		{
+			name:     "addresses kept on shutdown",
+			expected: addresses,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n := newTestController(t, statusSyncManifests)
+			client := fake.NewSimpleClientset()
+			for _, ing := range n.store.ListIngresses() {
+				if _, err := client.NetworkingV1().Ingresses(ing.Namespace).Create(context.Background(), &ing.Ingress, metav1.CreateOptions{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			n.cfg.Client = client
+			n.cfg.IngressClassConfiguration = &ingressclass.Configuration{Controller: ingressclass.DefaultControllerName, AnnotationValue: "nginx", IngressClassByName: true}
+			n.cfg.PublishStatusAddress = "lb.example.com, 10.0.0.2,10.0.0.1"
+			n.cfg.UpdateStatusOnShutdown = tc.onShutdown
+			n.isLeader.Store(true)
+			s := n.newStatusSyncer()
+
+			status := func(name string) []networking.IngressLoadBalancerIngress {
+				ing, err := client.NetworkingV1().Ingresses("default").Get(context.Background(), name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return ing.Status.LoadBalancer.Ingress
+			}
+			// resync reloads the Ingresses of the cluster into the store
+			resync := func() {
+				ing, err := client.NetworkingV1().Ingresses("default").Get(context.Background(), "web", metav1.GetOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := n.store.(*memoryStore).Add(ing); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			published, err := s.loadBalancerStatus()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(published, addresses) {
+				t.Fatalf("expected the addresses %v, got %v", addresses, published)
+			}
+
+			s.update(published)
+			if got := status("web"); !reflect.DeepEqual(got, addresses) {
+				t.Errorf("expected the status %v, got %v", addresses, got)
+			}
+			if got := status("other"); len(got) != 0 {
+				t.Errorf("expected the Ingress of another class not to change, got %v", got)
+			}
+
+			resync()
+			s.Shutdown()
+			if got := status("web"); len(got) != len(tc.expected) {
+				t.Errorf("expected the status %v after the shutdown, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestNodeAddresses(t *testing.T) {
+	node := func(name string, addresses ...apiv1.NodeAddress) *apiv1.Node {
+		return &apiv1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}, Status: apiv1.NodeStatus{Addresses: addresses}}
+	}
+	pod := func(name, nodeName string, labels map[string]string) *apiv1.Pod {
+		return &apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ingress-nginx", Labels: labels},
+			Spec:       apiv1.PodSpec{NodeName: nodeName},
+		}
+	}
+	controller := map[string]string{"app.kubernetes.io/name": "ingress-nginx"}
+
+	client := fake.NewSimpleClientset(
+		node("node-a",
+			apiv1.NodeAddress{Type: apiv1.NodeInternalIP, Address: "10.0.0.1"},
+			apiv1.NodeAddress{Type: apiv1.NodeExternalIP, Address: "203.0.113.1"}),
+		node("node-b", apiv1.NodeAddress{Type: apiv1.NodeInternalIP, Address: "10.0.0.2"}),
+		node("node-c", apiv1.NodeAddress{Type: apiv1.NodeExternalIP, Address: "203.0.113.3"}),
+		pod("controller-1", "node-a", controller),
+		pod("controller-2", "node-a", controller),
+		pod("controller-3", "node-b", controller),
+		pod("pending", "", controller),
+		pod("web", "node-c", map[string]string{"app.kubernetes.io/name": "web"}),
+	)
+
+	tests := []struct {
+		name          string
+		podLabels     map[string]string
+		internal      bool
+		expected      []string
+		expectedError bool
+	}{
+		{name: "external addresses", podLabels: controller, expected: []string{"10.0.0.2", "203.0.113.1"}},
+		{name: "internal addresses", podLabels: controller, internal: true, expected: []string{"10.0.0.1", "10.0.0.2"}},
+		{name: "no pod labels", expectedError: true},
+		{name: "no pods", podLabels: map[string]string{"app": "missing"}, expectedError: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n := newTestController(t, "")
+			n.cfg.Client = client
+			n.cfg.Namespace = "ingress-nginx"
+			n.cfg.ControllerPodLabels = tc.podLabels
+			n.cfg.UseNodeInternalIP = tc.internal
+
+			addresses, err := n.newStatusSyncer().nodeAddresses()
+			if tc.expectedError != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tc.expectedError, err)
+			}
+			if !reflect.DeepEqual(addresses, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, addresses)
+			}
+		})
+	}
+}
@@ -0,0 +1,1013 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networking "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog/v2"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+	ngx_config "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/controller/config"
+	ngx_template "github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/controller/template"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/defaults"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/resolver"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/util/file"
+)
+
+// Storer is the interface that wraps the required methods to gather
+// information about the Kubernetes objects used to build the configuration
+type Storer interface {
+	// GetBackendConfiguration returns the nginx configuration stored in a configmap
+	GetBackendConfiguration() ngx_config.Configuration
+	// GetSecurityConfiguration returns the configuration options from Ingress
+	GetSecurityConfiguration() defaults.SecurityConfiguration
+	// GetDefaultBackend returns the default backend configuration
+	GetDefaultBackend() defaults.Backend
+	// GetConfigMap returns the ConfigMap matching key.
+	GetConfigMap(key string) (*apiv1.ConfigMap, error)
+	// GetSecret returns the Secret matching key.
+	GetSecret(key string) (*apiv1.Secret, error)
+	// GetService returns the Service matching key.
+	GetService(key string) (*apiv1.Service, error)
+	// GetNamespace returns the Namespace matching name.
+	GetNamespace(name string) (*apiv1.Namespace, error)
+	// GetServiceEndpointsSlices returns the EndpointSlices of the Service matching key.
+	GetServiceEndpointsSlices(key string) ([]*discoveryv1.EndpointSlice, error)
+	// GetIngressClass returns the IngressClass matching name.
+	GetIngressClass(name string) (*networking.IngressClass, error)
+	// ListIngressClasses returns all the IngressClasses sorted by name.
+	ListIngressClasses() []*networking.IngressClass
+	// ListNetworkPolicies returns the NetworkPolicies of a namespace.
+	ListNetworkPolicies(namespace string) []*networking.NetworkPolicy
+	// ListDeployments returns the Deployments of a namespace.
+	ListDeployments(namespace string) []*appsv1.Deployment
+	// ListPodDisruptionBudgets returns the PodDisruptionBudgets of a namespace.
+	ListPodDisruptionBudgets(namespace string) []*policyv1.PodDisruptionBudget
+	// ListIngresses returns a list of all Ingresses in the store.
+	ListIngresses() []*Ingress
+	// ListConnectedIngresses returns the Ingresses of the store sharing a
+	// host or a Service with ing, directly or through other Ingresses.
+	ListConnectedIngresses(ing *networking.Ingress) []*Ingress
+	// GetIngressParseErrors returns the errors parsing the annotations of
+	// the Ingresses left out by the last ListIngresses, by namespace/name.
+	GetIngressParseErrors() map[string]error
+	// GetLocalSSLCert returns the local copy of a SSLCert
+	GetLocalSSLCert(name string) (*SSLCert, error)
+	// GetAuthCertificate resolves a given secret name into an SSL certificate.
+	GetAuthCertificate(name string) (*resolver.AuthSSLCert, error)
+	// GetIngressSource returns the position in the manifests of the Ingress
+	// matching key, empty when it was not loaded from a manifest.
+	GetIngressSource(key string) string
+	// GetIngressAPIVersion returns the apiVersion of the manifest of the
+	// Ingress matching key, empty when it was not loaded from a manifest.
+	GetIngressAPIVersion(key string) string
+}
+
+// NotExistsError is returned when an object does not exist in a store.
+type NotExistsError string
+
+// Error implements the error interface.
+func (e NotExistsError) Error() string {
+	return fmt.Sprintf("no object matching key %q in local store", string(e))
+}
+
+// memoryStore is a Storer backed by maps, populated from objects added
+// directly or decoded from manifest files. It allows running the complete
+// validation without access to a cluster.
+type memoryStore struct {
+	lock sync.RWMutex
+
+	backendConfig ngx_config.Configuration
+
+	// configMapName is the namespace/name of the ConfigMap containing the
+	// global nginx configuration
+	configMapName string
+	// namespace is set on the namespaced objects of the manifests without
+	// metadata.namespace, as kubectl does
+	namespace string
+
+	ingresses      map[string]*networking.Ingress
+	services       map[string]*apiv1.Service
+	secrets        map[string]*apiv1.Secret
+	configMaps     map[string]*apiv1.ConfigMap
+	namespaces     map[string]*apiv1.Namespace
+	endpointSlices map[string][]*discoveryv1.EndpointSlice
+	netPolicies    map[string]*networking.NetworkPolicy
+	deployments    map[string]*appsv1.Deployment
+	pdbs           map[string]*policyv1.PodDisruptionBudget
+	ingressClasses map[string]*networking.IngressClass
+
+	// sources contains the position in the manifests of the Ingresses
+	// loaded from files, by namespace/name
+	sources map[string]string
+	// apiVersions contains the apiVersion of the manifests of the Ingresses
+	// loaded from files, by namespace/name
+	apiVersions map[string]string
+	// parseErrors contains the errors parsing the annotations of the
+	// Ingresses left out by the last ListIngresses, by namespace/name
+	parseErrors map[string]error
+}
+
+// newMemoryStore returns an empty store using the default nginx configuration.
+// configMapName is the namespace/name of the ConfigMap that, once added,
+// overrides the default configuration.
+func newMemoryStore(configMapName string) *memoryStore {
+	return &memoryStore{
+		backendConfig:  ngx_config.NewDefault(),
+		configMapName:  configMapName,
+		namespace:      apiv1.NamespaceDefault,
+		ingresses:      map[string]*networking.Ingress{},
+		services:       map[string]*apiv1.Service{},
+		secrets:        map[string]*apiv1.Secret{},
+		configMaps:     map[string]*apiv1.ConfigMap{},
+		namespaces:     map[string]*apiv1.Namespace{},
+		endpointSlices: map[string][]*discoveryv1.EndpointSlice{},
+		netPolicies:    map[string]*networking.NetworkPolicy{},
+		deployments:    map[string]*appsv1.Deployment{},
+		pdbs:           map[string]*policyv1.PodDisruptionBudget{},
+		ingressClasses: map[string]*networking.IngressClass{},
+		sources:        map[string]string{},
+		apiVersions:    map[string]string{},
+	}
+}
+
+// Add inserts or replaces an object in the store
+func (s *memoryStore) Add(obj runtime.Object) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch o := obj.(type) {
+	case *networking.Ingress:
+		s.ingresses[k8s.MetaNamespaceKey(o)] = o
+		delete(s.sources, k8s.MetaNamespaceKey(o))
+		delete(s.apiVersions, k8s.MetaNamespaceKey(o))
+	case *apiv1.Service:
+		s.services[k8s.MetaNamespaceKey(o)] = o
+	case *apiv1.Secret:
+		// stringData is merged by the API server, do the same for manifests
+		for k, v := range o.StringData {
+			if o.Data == nil {
+				o.Data = map[string][]byte{}
+			}
+			o.Data[k] = []byte(v)
+		}
+		s.secrets[k8s.MetaNamespaceKey(o)] = o
+	case *apiv1.Namespace:
+		s.namespaces[o.Name] = o
+	case *networking.NetworkPolicy:
+		s.netPolicies[k8s.MetaNamespaceKey(o)] = o
+	case *appsv1.Deployment:
+		s.deployments[k8s.MetaNamespaceKey(o)] = o
+	case *policyv1.PodDisruptionBudget:
+		s.pdbs[k8s.MetaNamespaceKey(o)] = o
+	case *networking.IngressClass:
+		s.ingressClasses[o.Name] = o
+	case *apiv1.ConfigMap:
+		key := k8s.MetaNamespaceKey(o)
+		s.configMaps[key] = o
+		if key == s.configMapName {
+			s.backendConfig = ngx_template.ReadConfig(o.Data)
+		}
+	case *discoveryv1.EndpointSlice:
+		svcName, ok := o.Labels[discoveryv1.LabelServiceName]
+		if !ok {
+			return fmt.Errorf("EndpointSlice %v does not contain the %v label", k8s.MetaNamespaceKey(o), discoveryv1.LabelServiceName)
+		}
+		key := fmt.Sprintf("%v/%v", o.Namespace, svcName)
+		slices := s.endpointSlices[key][:0:0]
+		for _, eps := range s.endpointSlices[key] {
+			if eps.Name != o.Name {
+				slices = append(slices, eps)
+			}
+		}
+		s.endpointSlices[key] = append(slices, o)
+	default:
+		return fmt.Errorf("unsupported object type %T", obj)
+	}
+
+	return nil
+}
+
+// removeIngress deletes an Ingress from the store
+func (s *memoryStore) removeIngress(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.ingresses, key)
+	delete(s.sources, key)
+	delete(s.apiVersions, key)
+}
+
+// GetBackendConfiguration returns the nginx configuration stored in a configmap
+func (s *memoryStore) GetBackendConfiguration() ngx_config.Configuration {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.backendConfig
+}
+
+// GetSecurityConfiguration returns the configuration options from Ingress
+func (s *memoryStore) GetSecurityConfiguration() defaults.SecurityConfiguration {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return defaults.SecurityConfiguration{
+		AllowCrossNamespaceResources: s.backendConfig.AllowCrossNamespaceResources,
+		AnnotationsRiskLevel:         s.backendConfig.AnnotationsRiskLevel,
+	}
+}
+
+// GetDefaultBackend returns the default backend configuration
+func (s *memoryStore) GetDefaultBackend() defaults.Backend {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.backendConfig.Backend
+}
+
+// GetConfigMap returns the ConfigMap matching key.
+func (s *memoryStore) GetConfigMap(key string) (*apiv1.ConfigMap, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	cm, ok := s.configMaps[key]
+	if !ok {
+		return nil, NotExistsError(key)
+	}
+	return cm, nil
+}
+
+// GetSecret returns the Secret matching key.
+func (s *memoryStore) GetSecret(key string) (*apiv1.Secret, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	secret, ok := s.secrets[key]
+	if !ok {
+		return nil, NotExistsError(key)
+	}
+	return secret, nil
+}
+
+// GetService returns the Service matching key.
+func (s *memoryStore) GetService(key string) (*apiv1.Service, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	svc, ok := s.services[key]
+	if !ok {
+		return nil, NotExistsError(key)
+	}
+	return svc, nil
+}
+
+// GetNamespace returns the Namespace matching name.
+func (s *memoryStore) GetNamespace(name string) (*apiv1.Namespace, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	ns, ok := s.namespaces[name]
+	if !ok {
+		return nil, NotExistsError(name)
+	}
+	return ns, nil
+}
+
+// GetServiceEndpointsSlices returns the EndpointSlices of the Service matching key.
+func (s *memoryStore) GetServiceEndpointsSlices(key string) ([]*discoveryv1.EndpointSlice, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	slices, ok := s.endpointSlices[key]
+	if !ok {
+		return nil, NotExistsError(key)
+	}
+	return slices, nil
+}
+
+// GetIngressSource returns the position in the manifests of the Ingress
+func (s *memoryStore) GetIngressSource(key string) string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.sources[key]
+}
+
+// setIngressSource replaces the position in the manifests of the Ingress
+func (s *memoryStore) setIngressSource(key, source string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.sources[key] = source
+}
+
+// GetIngressAPIVersion returns the apiVersion of the manifest of the Ingress
+func (s *memoryStore) GetIngressAPIVersion(key string) string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.apiVersions[key]
+}
+
+// GetIngressClass returns the IngressClass matching name.
+func (s *memoryStore) GetIngressClass(name string) (*networking.IngressClass, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	ic, ok := s.ingressClasses[name]
+	if !ok {
+		return nil, NotExistsError(name)
+	}
+	return ic, nil
+}
+
+// ListIngressClasses returns all the IngressClasses sorted by name.
+func (s *memoryStore) ListIngressClasses() []*networking.IngressClass {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	classes := make([]*networking.IngressClass, 0, len(s.ingressClasses))
+	for _, ic := range s.ingressClasses {
+		classes = append(classes, ic)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		return classes[i].Name < classes[j].Name
+	})
+
+	return classes
+}
+
+// ListNetworkPolicies returns the NetworkPolicies of a namespace sorted by name
+func (s *memoryStore) ListNetworkPolicies(namespace string) []*networking.NetworkPolicy {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	policies := []*networking.NetworkPolicy{}
+	for _, np := range s.netPolicies {
+		if np.Namespace == namespace {
+			policies = append(policies, np)
+		}
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].Name < policies[j].Name
+	})
+
+	return policies
+}
+
+// ListDeployments returns the Deployments of a namespace sorted by name
+func (s *memoryStore) ListDeployments(namespace string) []*appsv1.Deployment {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	deployments := []*appsv1.Deployment{}
+	for _, d := range s.deployments {
+		if d.Namespace == namespace {
+			deployments = append(deployments, d)
+		}
+	}
+	sort.Slice(deployments, func(i, j int) bool {
+		return deployments[i].Name < deployments[j].Name
+	})
+
+	return deployments
+}
+
+// ListPodDisruptionBudgets returns the PodDisruptionBudgets of a namespace sorted by name
+func (s *memoryStore) ListPodDisruptionBudgets(namespace string) []*policyv1.PodDisruptionBudget {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	pdbs := []*policyv1.PodDisruptionBudget{}
+	for _, pdb := range s.pdbs {
+		if pdb.Namespace == namespace {
+			pdbs = append(pdbs, pdb)
+		}
+	}
+	sort.Slice(pdbs, func(i, j int) bool {
+		return pdbs[i].Name < pdbs[j].Name
+	})
+
+	return pdbs
+}
+
+// replaceWith replaces the content of the store with the objects of other
+func (s *memoryStore) replaceWith(other *memoryStore) {
+	other.lock.RLock()
+	defer other.lock.RUnlock()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.backendConfig = other.backendConfig
+	s.ingresses = other.ingresses
+	s.services = other.services
+	s.secrets = other.secrets
+	s.configMaps = other.configMaps
+	s.namespaces = other.namespaces
+	s.endpointSlices = other.endpointSlices
+	s.netPolicies = other.netPolicies
+	s.deployments = other.deployments
+	s.pdbs = other.pdbs
+	s.ingressClasses = other.ingressClasses
+	s.sources = other.sources
+	s.apiVersions = other.apiVersions
+}
+
+// clone returns a store containing the objects of s, the objects added to
+// the copy are not added to s
+func (s *memoryStore) clone() *memoryStore {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	c := newMemoryStore(s.configMapName)
+	c.backendConfig = s.backendConfig
+	c.namespace = s.namespace
+	copyMap(c.ingresses, s.ingresses)
+	copyMap(c.services, s.services)
+	copyMap(c.secrets, s.secrets)
+	copyMap(c.configMaps, s.configMaps)
+	copyMap(c.namespaces, s.namespaces)
+	copyMap(c.endpointSlices, s.endpointSlices)
+	copyMap(c.netPolicies, s.netPolicies)
+	copyMap(c.deployments, s.deployments)
+	copyMap(c.pdbs, s.pdbs)
+	copyMap(c.ingressClasses, s.ingressClasses)
+	copyMap(c.sources, s.sources)
+	copyMap(c.apiVersions, s.apiVersions)
+	return c
+}
+
+func copyMap[K comparable, V any](dst, src map[K]V) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
+
+// objectsVersion returns a checksum of the versions of the objects in the
+// store other than the EndpointSlices, which changes when any of them does
+func (s *memoryStore) objectsVersion() string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	versions := []string{}
+	add := func(kind string, obj metav1.Object) {
+		versions = append(versions, fmt.Sprintf("%v %v/%v %v", kind, obj.GetNamespace(), obj.GetName(), obj.GetResourceVersion()))
+	}
+	for _, o := range s.ingresses {
+		add("Ingress", o)
+	}
+	for _, o := range s.services {
+		add("Service", o)
+	}
+	for _, o := range s.secrets {
+		add("Secret", o)
+	}
+	for _, o := range s.configMaps {
+		add("ConfigMap", o)
+	}
+	for _, o := range s.namespaces {
+		add("Namespace", o)
+	}
+	for _, o := range s.netPolicies {
+		add("NetworkPolicy", o)
+	}
+	for _, o := range s.deployments {
+		add("Deployment", o)
+	}
+	for _, o := range s.pdbs {
+		add("PodDisruptionBudget", o)
+	}
+	for _, o := range s.ingressClasses {
+		add("IngressClass", o)
+	}
+
+	sort.Strings(versions)
+	return sha1Hex([]byte(strings.Join(versions, "\n")))
+}
+
+// ListIngresses returns the Ingresses in the store, with the annotations
+// parsed, in the order used by the ingress controller: oldest first, then
+// by namespace and name
+func (s *memoryStore) ListIngresses() []*Ingress {
+	s.lock.RLock()
+	ings := make([]*networking.Ingress, 0, len(s.ingresses))
+	for _, ing := range s.ingresses {
+		ings = append(ings, ing)
+	}
+	s.lock.RUnlock()
+
+	sortIngressObjects(ings)
+
+	ingresses := make([]*Ingress, 0, len(ings))
+	parseErrors := map[string]error{}
+	for _, ing := range ings {
+		parsed, err := newIngress(ing, s)
+		if err != nil {
+			klog.Errorf("Error parsing annotations of Ingress %q: %v", k8s.MetaNamespaceKey(ing), err)
+			parseErrors[k8s.MetaNamespaceKey(ing)] = err
+			continue
+		}
+		ingresses = append(ingresses, parsed)
+	}
+
+	s.lock.Lock()
+	s.parseErrors = parseErrors
+	s.lock.Unlock()
+
+	return ingresses
+}
+
+// ListConnectedIngresses returns the Ingresses of the store sharing a host,
+// an alias or a Service with ing, directly or through other Ingresses, with
+// the annotations parsed, in the order of ListIngresses. The other Ingresses
+// do not change the configuration of the hosts and upstreams of ing, so the
+// admission of ing only validates these, instead of parsing the annotations
+// of every Ingress of the cluster.
+func (s *memoryStore) ListConnectedIngresses(ing *networking.Ingress) []*Ingress {
+	s.lock.RLock()
+	all := make([]*networking.Ingress, 0, len(s.ingresses))
+	for _, existing := range s.ingresses {
+		all = append(all, existing)
+	}
+	s.lock.RUnlock()
+
+	ings := connectedIngresses(ing, all)
+	sortIngressObjects(ings)
+
+	ingresses := make([]*Ingress, 0, len(ings))
+	for _, connected := range ings {
+		parsed, err := newIngress(connected, s)
+		if err != nil {
+			klog.V(3).Infof("Error parsing annotations of Ingress %q: %v", k8s.MetaNamespaceKey(connected), err)
+			continue
+		}
+		ingresses = append(ingresses, parsed)
+	}
+	return ingresses
+}
+
+// connectedIngresses returns the Ingresses of all reachable from ing through
+// shared hosts, aliases and Services
+func connectedIngresses(ing *networking.Ingress, all []*networking.Ingress) []*networking.Ingress {
+	byKey := map[string][]int{}
+	keys := make([][]string, len(all))
+	for i, existing := range all {
+		keys[i] = ingressRoutingKeys(existing)
+		for _, key := range keys[i] {
+			byKey[key] = append(byKey[key], i)
+		}
+	}
+
+	connected := []*networking.Ingress{}
+	visited := make([]bool, len(all))
+	seen := map[string]bool{}
+	pending := ingressRoutingKeys(ing)
+	for len(pending) > 0 {
+		key := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		for _, i := range byKey[key] {
+			if visited[i] {
+				continue
+			}
+			visited[i] = true
+			connected = append(connected, all[i])
+			pending = append(pending, keys[i]...)
+		}
+	}
+	return connected
+}
+
+// ingressRoutingKeys returns the hosts, aliases and Services of an Ingress
+func ingressRoutingKeys(ing *networking.Ingress) []string {
+	keys := []string{}
+	addService := func(service *networking.IngressServiceBackend) {
+		if service != nil {
+			keys = append(keys, "service "+ing.Namespace+"/"+service.Name)
+		}
+	}
+	addHost := func(host string) {
+		keys = append(keys, "host "+host)
+		// the from-to-www redirect is skipped when another Ingress defines
+		// the alternate host
+		if strings.HasPrefix(host, "www.") {
+			keys = append(keys, "host "+strings.TrimPrefix(host, "www."))
+		} else {
+			keys = append(keys, "host www."+host)
+		}
+	}
+
+	if ing.Spec.DefaultBackend != nil {
+		addHost("")
+		addService(ing.Spec.DefaultBackend.Service)
+	}
+	for _, rule := range ing.Spec.Rules {
+		addHost(rule.Host)
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			addService(path.Backend.Service)
+		}
+	}
+	for _, alias := range strings.Split(ing.Annotations[parser.GetAnnotationWithPrefix("server-alias")], ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			addHost(alias)
+		}
+	}
+	return keys
+}
+
+// sortIngressObjects sorts the Ingresses in the order used by the ingress
+// controller: oldest first, then by namespace and name
+func sortIngressObjects(ings []*networking.Ingress) {
+	sort.SliceStable(ings, func(i, j int) bool {
+		return ingressBefore(ings[i], ings[j])
+	})
+}
+
+// ingressBefore returns true if a is processed before b by the ingress
+// controller
+func ingressBefore(a, b *networking.Ingress) bool {
+	ar := a.CreationTimestamp
+	br := b.CreationTimestamp
+	if ar.Equal(&br) {
+		return k8s.MetaNamespaceKey(a) < k8s.MetaNamespaceKey(b)
+	}
+	return ar.Before(&br)
+}
+
+// GetIngressParseErrors returns the errors parsing the annotations of the
+// Ingresses left out by the last ListIngresses
+func (s *memoryStore) GetIngressParseErrors() map[string]error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.parseErrors
+}
+
+// GetLocalSSLCert returns the SSLCert created from the TLS Secret matching name
+func (s *memoryStore) GetLocalSSLCert(name string) (*SSLCert, error) {
+	secret, err := s.GetSecret(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSSLCertFromSecret(secret)
+}
+
+// GetAuthCertificate resolves a given secret name into an SSL certificate.
+// The files are not written to disk, only their expected location for nginx
+// is returned.
+func (s *memoryStore) GetAuthCertificate(name string) (*resolver.AuthSSLCert, error) {
+	secret, err := s.GetSecret(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ca, ok := secret.Data["ca.crt"]
+	if !ok {
+		return &resolver.AuthSSLCert{Secret: name}, nil
+	}
+
+	nsName := fmt.Sprintf("%v-%v", secret.Namespace, secret.Name)
+	cert := &resolver.AuthSSLCert{
+		Secret:      name,
+		CAFileName:  filepath.Join(file.DefaultSSLDirectory, fmt.Sprintf("ca-%v.pem", nsName)),
+		CASHA:       sha1Hex(ca),
+		PemFileName: filepath.Join(file.DefaultSSLDirectory, fmt.Sprintf("%v.pem", nsName)),
+	}
+	if crl, ok := secret.Data["ca.crl"]; ok {
+		cert.CRLFileName = filepath.Join(file.DefaultSSLDirectory, fmt.Sprintf("crl-%v.pem", nsName))
+		cert.CRLSHA = sha1Hex(crl)
+	}
+
+	return cert, nil
+}
+
+// newSSLCertFromSecret returns the SSLCert described by the tls.crt, tls.key
+// and ca.crt keys of a Secret
+func newSSLCertFromSecret(secret *apiv1.Secret) (*SSLCert, error) {
+	key := k8s.MetaNamespaceKey(secret)
+	certPEM, ok := secret.Data[apiv1.TLSCertKey]
+	if !ok {
+		return nil, fmt.Errorf("secret %q has no %q key", key, apiv1.TLSCertKey)
+	}
+	keyPEM, ok := secret.Data[apiv1.TLSPrivateKeyKey]
+	if !ok {
+		return nil, fmt.Errorf("secret %q has no %q key", key, apiv1.TLSPrivateKeyKey)
+	}
+
+	certs, err := parsePEMCertificates(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("secret %q contains an invalid certificate: %w", key, err)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("secret %q does not contain a certificate", key)
+	}
+	if block, _ := pem.Decode(keyPEM); block == nil {
+		return nil, fmt.Errorf("secret %q does not contain a PEM encoded private key", key)
+	}
+
+	leaf := certs[0]
+	cn := []string{}
+	if leaf.Subject.CommonName != "" {
+		cn = append(cn, leaf.Subject.CommonName)
+	}
+	for _, name := range leaf.DNSNames {
+		if !containsString(cn, name) {
+			cn = append(cn, name)
+		}
+	}
+
+	pemCertKey := string(certPEM) + "\n" + string(keyPEM)
+	nsName := fmt.Sprintf("%v-%v", secret.Namespace, secret.Name)
+	sslCert := &SSLCert{
+		Name:        secret.Name,
+		Namespace:   secret.Namespace,
+		Certificate: leaf,
+		PemFileName: filepath.Join(file.DefaultSSLDirectory, fmt.Sprintf("%v.pem", nsName)),
+		PemSHA:      sha1Hex([]byte(pemCertKey)),
+		CN:          cn,
+		ExpireTime:  leaf.NotAfter,
+		PemCertKey:  pemCertKey,
+		UID:         string(secret.UID),
+	}
+
+	if ca, ok := secret.Data["ca.crt"]; ok {
+		caCerts, err := parsePEMCertificates(ca)
+		if err != nil {
+			return nil, fmt.Errorf("secret %q contains an invalid CA certificate: %w", key, err)
+		}
+		sslCert.CACertificate = caCerts
+		sslCert.CAFileName = filepath.Join(file.DefaultSSLDirectory, fmt.Sprintf("ca-%v.pem", nsName))
+		sslCert.CASHA = sha1Hex(ca)
+	}
+	if crl, ok := secret.Data["ca.crl"]; ok {
+		sslCert.CRLFileName = filepath.Join(file.DefaultSSLDirectory, fmt.Sprintf("crl-%v.pem", nsName))
+		sslCert.CRLSHA = sha1Hex(crl)
+	}
+
+	return sslCert, nil
+}
+
+// manifestTypes maps the apiVersion and kind of the supported manifests to
+// the type used to decode them
+var manifestTypes = map[string]func() runtime.Object{
+	"networking.k8s.io/v1/Ingress":       func() runtime.Object { return &networking.Ingress{} },
+	"networking.k8s.io/v1/IngressClass":  func() runtime.Object { return &networking.IngressClass{} },
+	"networking.k8s.io/v1/NetworkPolicy": func() runtime.Object { return &networking.NetworkPolicy{} },
+	"v1/Service":                         func() runtime.Object { return &apiv1.Service{} },
+	"v1/Secret":                          func() runtime.Object { return &apiv1.Secret{} },
+	"v1/ConfigMap":                       func() runtime.Object { return &apiv1.ConfigMap{} },
+	"v1/Namespace":                       func() runtime.Object { return &apiv1.Namespace{} },
+	"apps/v1/Deployment":                 func() runtime.Object { return &appsv1.Deployment{} },
+	"policy/v1/PodDisruptionBudget":      func() runtime.Object { return &policyv1.PodDisruptionBudget{} },
+	"discovery.k8s.io/v1/EndpointSlice":  func() runtime.Object { return &discoveryv1.EndpointSlice{} },
+}
+
+// clusterScopedKinds are the kinds of manifestTypes without namespace
+var clusterScopedKinds = map[string]bool{
+	"IngressClass": true,
+	"Namespace":    true,
+}
+
+// LoadManifests decodes the YAML or JSON manifests contained in the files and
+// directories in paths and adds the supported objects to the store.
+// Objects of unsupported kinds are ignored.
+func (s *memoryStore) LoadManifests(paths ...string) error {
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			if p != path && !isManifestFile(p) {
+				return nil
+			}
+
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			return s.LoadManifest(bytes.NewReader(data), p)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// manifestDocument is a document of a manifest and its position
+type manifestDocument struct {
+	// index is the position of the document in the stream, from 0
+	index int
+	// line is the line the document starts at, from 1
+	line int
+	data []byte
+}
+
+// splitManifest returns the documents of a stream of YAML documents
+// separated by ---, or of concatenated JSON objects
+func splitManifest(data []byte) ([]manifestDocument, error) {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return splitJSONManifest(data)
+	}
+
+	docs := []manifestDocument{}
+	start, line := 0, 1
+	for offset := 0; offset <= len(data); {
+		end := bytes.IndexByte(data[offset:], '\n')
+		next := offset + end + 1
+		if end < 0 {
+			end, next = len(data)-offset, len(data)+1
+		}
+		text := data[offset : offset+end]
+
+		if isYAMLSeparator(text) {
+			docs = append(docs, manifestDocument{index: len(docs), line: line, data: data[start:offset]})
+			start = min(next, len(data))
+			line = bytes.Count(data[:start], []byte("\n")) + 1
+		}
+		offset = next
+	}
+	docs = append(docs, manifestDocument{index: len(docs), line: line, data: data[start:]})
+	return docs, nil
+}
+
+// isYAMLSeparator returns true for the --- lines separating YAML documents
+func isYAMLSeparator(line []byte) bool {
+	line = bytes.TrimRight(line, "\r")
+	return bytes.HasPrefix(line, []byte("---")) && (len(line) == 3 || line[3] == ' ' || line[3] == '\t')
+}
+
+func splitJSONManifest(data []byte) ([]manifestDocument, error) {
+	docs := []manifestDocument{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var raw json.RawMessage
+		offset := decoder.InputOffset()
+		if err := decoder.Decode(&raw); err != nil {
+			if err == io.EOF {
+				return docs, nil
+			}
+			return nil, fmt.Errorf("document %d: %w", len(docs), err)
+		}
+		offset += int64(len(data[offset:]) - len(bytes.TrimLeft(data[offset:], " \t\r\n")))
+		docs = append(docs, manifestDocument{
+			index: len(docs),
+			line:  bytes.Count(data[:offset], []byte("\n")) + 1,
+			data:  raw,
+		})
+	}
+}
+
+// LoadManifest decodes a stream of YAML or JSON documents and adds the
+// supported objects to the store, including the items of Lists. source names
+// the stream in the positions of the Ingresses.
+func (s *memoryStore) LoadManifest(r io.Reader, source string) error {
+	return s.LoadManifestInNamespace(r, source, s.namespace)
+}
+
+// LoadManifestInNamespace is LoadManifest setting namespace on the objects
+// without metadata.namespace, as helm does with the namespace of a release
+func (s *memoryStore) LoadManifestInNamespace(r io.Reader, source, namespace string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("%v: %w", source, err)
+	}
+
+	docs, err := splitManifest(data)
+	if err != nil {
+		return fmt.Errorf("%v: %w", source, err)
+	}
+	for _, doc := range docs {
+		raw, err := yaml.ToJSON(doc.data)
+		if err != nil {
+			return fmt.Errorf("%v:%d (document %d): %w", source, doc.line, doc.index, err)
+		}
+		position := fmt.Sprintf("%v:%d (document %d)", source, doc.line, doc.index)
+		if err := s.loadObject(raw, position, namespace); err != nil {
+			return fmt.Errorf("%v: %w", position, err)
+		}
+	}
+	return nil
+}
+
+// loadObject adds the object, or the items of the List, encoded in raw. The
+// namespaced objects without namespace are added to namespace.
+func (s *memoryStore) loadObject(raw []byte, position, namespace string) error {
+	if len(bytes.TrimSpace(raw)) == 0 || string(raw) == "null" {
+		return nil
+	}
+
+	var tm metav1.TypeMeta
+	if err := json.Unmarshal(raw, &tm); err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(tm.Kind, "List") {
+		var list struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return fmt.Errorf("%v: %w", tm.Kind, err)
+		}
+		for i, item := range list.Items {
+			var err error
+			if tm.Kind != "List" {
+				// the items of typed lists, such as IngressList, have no kind
+				if item, err = withTypeMeta(item, tm.APIVersion, strings.TrimSuffix(tm.Kind, "List")); err != nil {
+					return fmt.Errorf("item %d: %w", i, err)
+				}
+			}
+			if err := s.loadObject(item, fmt.Sprintf("%v item %d", position, i), namespace); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		return nil
+	}
+
+	var obj runtime.Object
+	if tm.Kind == "Ingress" && deprecatedIngressAPIVersions[tm.APIVersion] {
+		ing, err := convertIngress(raw)
+		if err != nil {
+			return err
+		}
+		obj = ing
+	} else {
+		newObj, ok := manifestTypes[fmt.Sprintf("%v/%v", tm.APIVersion, tm.Kind)]
+		if !ok {
+			klog.V(3).Infof("Ignoring %v of kind %v/%v", position, tm.APIVersion, tm.Kind)
+			return nil
+		}
+
+		obj = newObj()
+		if err := checkSchema(raw, obj, tm.Kind); err != nil {
+			return err
+		}
+	}
+	if o, ok := obj.(metav1.Object); ok && o.GetNamespace() == "" && !clusterScopedKinds[tm.Kind] {
+		o.SetNamespace(namespace)
+	}
+	if err := s.Add(obj); err != nil {
+		return fmt.Errorf("%v: %w", tm.Kind, err)
+	}
+
+	if ing, ok := obj.(*networking.Ingress); ok {
+		s.lock.Lock()
+		s.sources[k8s.MetaNamespaceKey(ing)] = position
+		s.apiVersions[k8s.MetaNamespaceKey(ing)] = tm.APIVersion
+		s.lock.Unlock()
+	}
+	return nil
+}
+
+// withTypeMeta sets the apiVersion and kind of an object missing them
+func withTypeMeta(raw json.RawMessage, apiVersion, kind string) (json.RawMessage, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	if _, ok := obj["apiVersion"]; !ok {
+		obj["apiVersion"] = apiVersion
+	}
+	if _, ok := obj["kind"]; !ok {
+		obj["kind"] = kind
+	}
+	return json.Marshal(obj)
+}
+
+func isManifestFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
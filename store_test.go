@@ -0,0 +1,441 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+const storeManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+data:
+  proxy-body-size: 8m
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: default
+spec:
+  ports:
+  - name: http
+    port: 80
+    protocol: TCP
+    targetPort: 8080
+---
+apiVersion: discovery.k8s.io/v1
+kind: EndpointSlice
+metadata:
+  name: web-1
+  namespace: default
+  labels:
+    kubernetes.io/service-name: web
+addressType: IPv4
+endpoints:
+- addresses: [10.0.0.1]
+ports:
+- name: http
+  port: 8080
+  protocol: TCP
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/rewrite-target: /
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /app
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`
+
+func TestMemoryStoreLoadManifest(t *testing.T) {
+	n, ingresses, cfg := testConfiguration(t, storeManifests)
+
+	if len(ingresses) != 1 || ingresses[0].ParsedAnnotations == nil {
+		t.Fatalf("expected one Ingress with parsed annotations, got %v", ingresses)
+	}
+	if ingresses[0].ParsedAnnotations.Rewrite.Target != "/" {
+		t.Errorf("expected the rewrite-target annotation to be parsed, got %q", ingresses[0].ParsedAnnotations.Rewrite.Target)
+	}
+	if size := n.store.GetBackendConfiguration().ProxyBodySize; size != "8m" {
+		t.Errorf("expected the proxy-body-size of the ConfigMap, got %q", size)
+	}
+	if _, err := n.store.GetService("default/web"); err != nil {
+		t.Errorf("unexpected error getting the Service: %v", err)
+	}
+	slices, err := n.store.GetServiceEndpointsSlices("default/web")
+	if err != nil || len(slices) != 1 {
+		t.Errorf("expected the EndpointSlice of the Service, got %v (%v)", slices, err)
+	}
+
+	var server *Server
+	for _, s := range cfg.Servers {
+		if s.Hostname == "web.example.com" {
+			server = s
+		}
+	}
+	if server == nil {
+		t.Fatalf("expected a server for web.example.com, got %v", cfg.Servers)
+	}
+	var backend *Backend
+	for _, b := range cfg.Backends {
+		if b.Name == "default-web-80" {
+			backend = b
+		}
+	}
+	if backend == nil || len(backend.Endpoints) != 1 || backend.Endpoints[0].Address != "10.0.0.1" {
+		t.Errorf("expected the endpoint of the EndpointSlice, got %v", backend)
+	}
+}
+
+func TestMemoryStoreLoadManifestErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		manifests string
+	}{
+		{
+			name:      "invalid YAML",
+			manifests: "apiVersion: v1\nkind: Service\nmetadata: [",
+		},
+		{
+			name: "EndpointSlice without Service",
+			manifests: `
+apiVersion: discovery.k8s.io/v1
+kind: EndpointSlice
+metadata:
+  name: orphan
+  namespace: default
+addressType: IPv4
+`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := newMemoryStore("").LoadManifest(strings.NewReader(tc.manifests), "test"); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestSplitManifest(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		lines []int
+		texts []string
+	}{
+		{
+			name:  "yaml",
+			data:  "a: 1\n---\nb: 2\nc: 3\n--- # comment\nd: 4",
+			lines: []int{1, 3, 6},
+			texts: []string{"a: 1\n", "b: 2\nc: 3\n", "d: 4"},
+		},
+		{
+			name:  "leading separator and crlf",
+			data:  "---\r\na: 1\r\n---\r\n",
+			lines: []int{1, 2, 4},
+			texts: []string{"", "a: 1\r\n", ""},
+		},
+		{
+			name:  "block scalar containing dashes",
+			data:  "a: |\n  ----\n  text\n",
+			lines: []int{1},
+			texts: []string{"a: |\n  ----\n  text\n"},
+		},
+		{
+			name:  "json",
+			data:  "{\"a\": 1}\n\n  {\"b\": 2}",
+			lines: []int{1, 3},
+			texts: []string{"{\"a\": 1}", "{\"b\": 2}"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			docs, err := splitManifest([]byte(tc.data))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(docs) != len(tc.lines) {
+				t.Fatalf("expected %d documents, got %d", len(tc.lines), len(docs))
+			}
+			for i, doc := range docs {
+				if doc.index != i || doc.line != tc.lines[i] || string(doc.data) != tc.texts[i] {
+					t.Errorf("expected document %d at line %d with %q, got %d at line %d with %q",
+						i, tc.lines[i], tc.texts[i], doc.index, doc.line, doc.data)
+				}
+			}
+		})
+	}
+
+	if _, err := splitManifest([]byte("{\"a\": 1} {")); err == nil {
+		t.Errorf("expected an error for a truncated JSON document")
+	}
+}
+
+func TestMemoryStoreLoadManifestSources(t *testing.T) {
+	manifests := `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: web
+    namespace: default
+- apiVersion: networking.k8s.io/v1
+  kind: Ingress
+  metadata:
+    name: web
+    namespace: default
+  spec:
+    ingressClassName: nginx
+    rules:
+    - host: web.example.com
+---
+apiVersion: networking.k8s.io/v1
+kind: IngressList
+items:
+- metadata:
+    name: api
+    namespace: default
+  spec:
+    ingressClassName: nginx
+    rules:
+    - host: api.example.com
+`
+
+	s := newMemoryStore(defaultConfigMapName)
+	if err := s.LoadManifest(strings.NewReader(manifests), "ingresses.yaml"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := s.GetService("default/web"); err != nil {
+		t.Errorf("expected the Service of the List: %v", err)
+	}
+	if len(s.ListIngresses()) != 2 {
+		t.Errorf("expected the Ingresses of both Lists, got %v", s.ListIngresses())
+	}
+
+	expected := map[string]string{
+		"default/web":     "ingresses.yaml:1 (document 0) item 1",
+		"default/api":     "ingresses.yaml:19 (document 1) item 0",
+		"default/missing": "",
+	}
+	for key, source := range expected {
+		if got := s.GetIngressSource(key); got != source {
+			t.Errorf("expected the source of %v to be %q, got %q", key, source, got)
+		}
+	}
+
+	// an Ingress added from the API has no position in the manifests
+	if err := s.Add(&networking.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.GetIngressSource("default/web"); got != "" {
+		t.Errorf("expected no source for an Ingress added from the API, got %q", got)
+	}
+}
+
+func TestMemoryStoreLoadManifestNamespace(t *testing.T) {
+	manifests := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: api
+  namespace: api
+spec:
+  ingressClassName: nginx
+---
+apiVersion: networking.k8s.io/v1
+kind: IngressClass
+metadata:
+  name: nginx
+spec:
+  controller: k8s.io/ingress-nginx
+`
+
+	for _, namespace := range []string{"", "team-a"} {
+		s := newMemoryStore(defaultConfigMapName)
+		expected := "default"
+		if namespace != "" {
+			s.namespace = namespace
+			expected = namespace
+		}
+		if err := s.LoadManifest(strings.NewReader(manifests), "ingresses.yaml"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, err := s.GetService(expected + "/web"); err != nil {
+			t.Errorf("expected the Service in the namespace %v: %v", expected, err)
+		}
+		keys := []string{}
+		for _, ing := range s.ListIngresses() {
+			keys = append(keys, k8s.MetaNamespaceKey(ing))
+		}
+		if want := "api/api " + expected + "/web"; strings.Join(keys, " ") != want {
+			t.Errorf("expected the Ingresses %v, got %v", want, keys)
+		}
+		if class, err := s.GetIngressClass("nginx"); err != nil || class.Namespace != "" {
+			t.Errorf("expected the IngressClass without namespace, got %+v %v", class, err)
+		}
+	}
+}
+
+func TestMemoryStoreLoadManifestErrorPositions(t *testing.T) {
+	tests := []struct {
+		name      string
+		manifests string
+		expected  string
+	}{
+		{
+			name:      "invalid document",
+			manifests: "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n---\napiVersion: v1\nkind: Service\nmetadata: [\n",
+			expected:  "services.yaml:6 (document 1):",
+		},
+		{
+			name:      "invalid item of a List",
+			manifests: "apiVersion: v1\nkind: List\nitems:\n- apiVersion: v1\n  kind: Service\n  metadata: []\n",
+			expected:  "services.yaml:1 (document 0): item 0: Service",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := newMemoryStore("").LoadManifest(strings.NewReader(tc.manifests), "services.yaml")
+			if err == nil || !strings.HasPrefix(err.Error(), tc.expected) {
+				t.Errorf("expected an error starting with %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestFindingSource(t *testing.T) {
+	n := newTestController(t, "")
+	if err := n.store.(*memoryStore).LoadManifest(strings.NewReader(preValidationManifests), "ingress.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	n.cfg.NginxVersion = "1.13.9"
+
+	_, findings := n.validate(n.store.ListIngresses())
+	found := false
+	for _, f := range findings {
+		if f.Ingress == "" {
+			continue
+		}
+		found = true
+		if !strings.HasPrefix(f.Source, "ingress.yaml:") {
+			t.Errorf("expected the position of %v in ingress.yaml, got %q", f.Ingress, f.Source)
+		}
+		if !strings.Contains(f.String(), "source=\""+f.Source+"\"") {
+			t.Errorf("expected the source in %q", f.String())
+		}
+	}
+	if !found {
+		t.Fatalf("expected findings on the Ingress, got %v", findings)
+	}
+
+	// the findings of a baseline match when the Ingress moved in the file
+	baseline := make([]Finding, len(findings))
+	for i, f := range findings {
+		f.Source = "ingress.yaml:100 (document 3)"
+		baseline[i] = f
+	}
+	if kept, suppressed, stale := applyBaseline(findings, baseline); len(kept) != 0 || suppressed != len(findings) || stale != 0 {
+		t.Errorf("expected the moved findings to be suppressed, got %v kept, %v suppressed, %v stale", kept, suppressed, stale)
+	}
+	if added, resolved := diffFindings(baseline, findings); len(added) != 0 || len(resolved) != 0 {
+		t.Errorf("expected no change when the Ingress moved, got %v added, %v resolved", added, resolved)
+	}
+}
+
+// connectedIngress returns an Ingress routing host to service
+func connectedIngress(name, host, service string, annotations map[string]string) *networking.Ingress {
+	return &networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Annotations: annotations},
+		Spec: networking.IngressSpec{Rules: []networking.IngressRule{{
+			Host: host,
+			IngressRuleValue: networking.IngressRuleValue{HTTP: &networking.HTTPIngressRuleValue{Paths: []networking.HTTPIngressPath{{
+				Path:     "/",
+				PathType: &pathTypePrefix,
+				Backend: networking.IngressBackend{Service: &networking.IngressServiceBackend{
+					Name: service, Port: networking.ServiceBackendPort{Number: 80},
+				}},
+			}}}},
+		}}},
+	}
+}
+
+func TestListConnectedIngresses(t *testing.T) {
+	s := newMemoryStore(defaultConfigMapName)
+	for _, ing := range []*networking.Ingress{
+		connectedIngress("same-host", "app.example.com", "web", nil),
+		connectedIngress("same-service", "admin.example.com", "web", nil),
+		connectedIngress("through-service", "admin.example.com", "admin", nil),
+		connectedIngress("www", "www.app.example.com", "www", nil),
+		connectedIngress("alias", "other.example.com", "other", map[string]string{
+			"nginx.ingress.kubernetes.io/server-alias": "legacy.example.com, app.example.com",
+		}),
+		connectedIngress("unrelated", "unrelated.example.com", "unrelated", nil),
+		connectedIngress("candidate-service", "elsewhere.example.com", "api", nil),
+	} {
+		if err := s.Add(ing); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	candidate := connectedIngress("candidate", "app.example.com", "api", nil)
+	got := map[string]bool{}
+	for _, ing := range s.ListConnectedIngresses(candidate) {
+		got[k8s.MetaNamespaceKey(ing)] = true
+	}
+
+	for _, name := range []string{"same-host", "same-service", "through-service", "www", "alias", "candidate-service"} {
+		if !got["default/"+name] {
+			t.Errorf("expected default/%v to be connected to the candidate", name)
+		}
+	}
+	if got["default/unrelated"] {
+		t.Errorf("expected default/unrelated not to be connected to the candidate")
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+)
+
+// defaultSubFilterTypes are the MIME types rewritten when sub-filter-types is
+// not set. nginx always includes text/html.
+var defaultSubFilterTypes = []string{"text/html"}
+
+// subFilterSeparator separates the string to search and its replacement in
+// each line of the sub-filter annotation
+const subFilterSeparator = "=>"
+
+var (
+	// acceptEncodingDirective matches snippets setting the Accept-Encoding
+	// header sent to the backend
+	acceptEncodingDirective = regexp.MustCompile(`(?mi)^\s*proxy_set_header\s+["']?Accept-Encoding["']?\s+(.*?);`)
+	subFilterDirective      = regexp.MustCompile(`(?m)^\s*sub_filter(_once|_types|_last_modified)?\s`)
+	mimeType                = regexp.MustCompile(`^(\*|[a-zA-Z0-9][a-zA-Z0-9!#$&^_.+-]*/(\*|[a-zA-Z0-9][a-zA-Z0-9!#$&^_.+-]*))$`)
+)
+
+// SubFilterReplacement replaces a string in the body of the responses
+type SubFilterReplacement struct {
+	Search      string `json:"search"`
+	Replacement string `json:"replacement"`
+}
+
+// SubFilterConfig rewrites the bodies of the responses of a location using
+// the nginx sub module, e.g. to fix absolute URLs generated by applications
+// unaware of the external host
+type SubFilterConfig struct {
+	Replacements []SubFilterReplacement `json:"replacements,omitempty"`
+	// Once replaces only the first occurrence of each string
+	Once bool `json:"once"`
+	// Types are the MIME types of the responses to rewrite
+	Types []string `json:"types,omitempty"`
+	// Error contains the problem found parsing the annotations, which
+	// prevents the filter from being configured
+	Error string `json:"error,omitempty"`
+}
+
+// Enabled returns true if the location rewrites response bodies
+func (c SubFilterConfig) Enabled() bool {
+	return len(c.Replacements) > 0
+}
+
+// parseSubFilter reads the sub-filter, sub-filter-once and sub-filter-types
+// annotations. sub-filter contains one replacement per line, using the
+// format <search> => <replacement>.
+func parseSubFilter(ing *networking.Ingress) SubFilterConfig {
+	cfg := SubFilterConfig{}
+	anns := ing.GetAnnotations()
+
+	raw, ok := anns[parser.GetAnnotationWithPrefix("sub-filter")]
+	if !ok {
+		return cfg
+	}
+
+	for i, line := range strings.Split(raw, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		search, replacement, found := strings.Cut(line, subFilterSeparator)
+		if !found {
+			cfg.Error = fmt.Sprintf("line %d of sub-filter does not use the format <search> %v <replacement>", i+1, subFilterSeparator)
+			return cfg
+		}
+		cfg.Replacements = append(cfg.Replacements, SubFilterReplacement{
+			Search:      strings.TrimSpace(search),
+			Replacement: strings.TrimSpace(replacement),
+		})
+	}
+
+	if once, ok := anns[parser.GetAnnotationWithPrefix("sub-filter-once")]; ok {
+		b, err := strconv.ParseBool(once)
+		if err != nil {
+			cfg.Error = fmt.Sprintf("invalid sub-filter-once value %q", once)
+			return cfg
+		}
+		cfg.Once = b
+	}
+
+	cfg.Types = defaultSubFilterTypes
+	if types, ok := anns[parser.GetAnnotationWithPrefix("sub-filter-types")]; ok {
+		cfg.Types = nil
+		for _, t := range strings.Split(types, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				cfg.Types = append(cfg.Types, t)
+			}
+		}
+	}
+
+	return cfg
+}
+
+// checkSubFilters validates the sub-filter annotations and the locations
+// they are used in
+func (n *NGINXController) checkSubFilters(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			sf := loc.SubFilter
+			if sf.Error != "" {
+				findings = append(findings, newLocationFinding("sub-filter-invalid", SeverityError, server, loc,
+					"%v; responses of location %q are not rewritten", sf.Error, loc.Path))
+				continue
+			}
+			if !sf.Enabled() {
+				if subFilterDirective.MatchString(loc.ConfigurationSnippet) {
+					findings = append(findings, newLocationFinding("sub-filter-snippet", SeverityInfo, server, loc,
+						"location %q configures sub_filter in configuration-snippet; use the sub-filter annotation, which also disables the compression of the responses",
+						loc.Path))
+				}
+				continue
+			}
+
+			findings = append(findings, subFilterFindings(sf, server, loc)...)
+		}
+	}
+
+	return findings
+}
+
+func subFilterFindings(sf SubFilterConfig, server *Server, loc *Location) []Finding {
+	findings := []Finding{}
+
+	searches := map[string]bool{}
+	for _, r := range sf.Replacements {
+		switch {
+		case r.Search == "":
+			findings = append(findings, newLocationFinding("sub-filter-invalid", SeverityError, server, loc,
+				"sub-filter of location %q contains an empty search string", loc.Path))
+		case searches[r.Search]:
+			findings = append(findings, newLocationFinding("sub-filter-invalid", SeverityError, server, loc,
+				"sub-filter of location %q replaces %q more than once; only the first replacement is applied", loc.Path, r.Search))
+		case r.Search == r.Replacement:
+			findings = append(findings, newLocationFinding("sub-filter-noop", SeverityWarning, server, loc,
+				"sub-filter of location %q replaces %q with itself", loc.Path, r.Search))
+		case strings.Contains(r.Replacement, r.Search) && !sf.Once:
+			findings = append(findings, newLocationFinding("sub-filter-noop", SeverityInfo, server, loc,
+				"the replacement of %q in location %q contains the search string; the result is not filtered again", r.Search, loc.Path))
+		}
+		searches[r.Search] = true
+	}
+
+	for _, t := range sf.Types {
+		if !mimeType.MatchString(t) {
+			findings = append(findings, newLocationFinding("sub-filter-invalid", SeverityError, server, loc,
+				"sub-filter-types of location %q contains the invalid MIME type %q", loc.Path, t))
+		}
+	}
+
+	if m := acceptEncodingDirective.FindStringSubmatch(loc.ConfigurationSnippet); m != nil && strings.Trim(m[1], `"' `) != "" {
+		findings = append(findings, newLocationFinding("sub-filter-compression", SeverityError, server, loc,
+			"configuration-snippet of location %q sets Accept-Encoding to %v; compressed responses from the backend are not rewritten by sub-filter",
+			loc.Path, m[1]))
+	}
+	if subFilterDirective.MatchString(loc.ConfigurationSnippet) {
+		findings = append(findings, newLocationFinding("sub-filter-snippet", SeverityError, server, loc,
+			"location %q configures sub_filter both in configuration-snippet and with the sub-filter annotation; nginx fails with duplicate directives",
+			loc.Path))
+	}
+
+	switch strings.ToUpper(loc.BackendProtocol) {
+	case "GRPC", "GRPCS", backendProtocolH2C, "FCGI":
+		findings = append(findings, newLocationFinding("sub-filter-backend-protocol", SeverityWarning, server, loc,
+			"location %q uses backend protocol %v; sub-filter removes the Accept-Encoding header only for proxied HTTP backends",
+			loc.Path, loc.BackendProtocol))
+	}
+
+	return findings
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+)
+
+const subFilterManifests = `
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+  namespace: default
+spec:
+  ports:
+  - port: 80
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: invalid
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/sub-filter: |
+      http://internal => https://example.com
+      missing separator
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: invalid.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: app
+            port:
+              number: 80
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: noop
+  namespace: default
+  annotations:
+    nginx.ingress.kubernetes.io/sub-filter: "same => same"
+    nginx.ingress.kubernetes.io/sub-filter-types: "text/html, not a type"
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: noop.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: app
+            port:
+              number: 80
+`
+
+func TestParseSubFilter(t *testing.T) {
+	n := newTestController(t, subFilterManifests)
+	ing := testIngress(t, n, "default/noop")
+
+	sf := ing.ParsedAnnotations.SubFilter
+	if len(sf.Replacements) != 1 || sf.Replacements[0] != (SubFilterReplacement{Search: "same", Replacement: "same"}) {
+		t.Errorf("unexpected replacements %v", sf.Replacements)
+	}
+	if len(sf.Types) != 2 || sf.Types[1] != "not a type" {
+		t.Errorf("unexpected types %v", sf.Types)
+	}
+}
+
+func TestCheckSubFilters(t *testing.T) {
+	n := newTestController(t, subFilterManifests)
+
+	// the rule runs as part of the validation
+	_, findings := n.validate(n.store.ListIngresses())
+
+	invalid := findingsWithRule(findings, "sub-filter-invalid")
+	hosts := map[string]int{}
+	for _, f := range invalid {
+		hosts[f.Host]++
+	}
+	if hosts["invalid.example.com"] != 1 || hosts["noop.example.com"] != 1 {
+		t.Errorf("expected one sub-filter-invalid finding per host, got %v", invalid)
+	}
+	if noop := findingsWithRule(findings, "sub-filter-noop"); len(noop) != 1 || noop[0].Host != "noop.example.com" {
+		t.Errorf("expected one sub-filter-noop finding, got %v", noop)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// ignoreRulesAnnotation contains the comma separated rules whose findings
+// are not reported for the Ingress
+var ignoreRulesAnnotation = validatorAnnotation("ignore-rules")
+
+// suppressionRule is the rule of the findings listing the active
+// suppressions, which can not be suppressed
+const suppressionRule = "suppressed-rules"
+
+// suppression counts the findings of a rule suppressed for an Ingress, and
+// the errors kept as the rule is not suppressible
+type suppression struct {
+	suppressed int
+	kept       int
+}
+
+// applySuppressions removes the findings of the rules listed in the
+// ignore-rules annotation of their Ingress. Errors are only removed for the
+// suppressible rules chosen by the operator, so the annotation of a tenant
+// can not lift the admission guard. Every Ingress with the annotation gets
+// an info finding listing the suppressed rules, so the exemptions stay
+// visible in the reports.
+func applySuppressions(ingresses []*Ingress, findings []Finding, suppressible []string) []Finding {
+	ignored := map[string]map[string]*suppression{}
+	for _, ing := range ingresses {
+		value, ok := ing.GetAnnotations()[ignoreRulesAnnotation]
+		if !ok {
+			continue
+		}
+		rules := map[string]*suppression{}
+		for _, rule := range strings.Split(value, ",") {
+			if rule = strings.TrimSpace(rule); rule != "" && rule != suppressionRule {
+				rules[rule] = &suppression{}
+			}
+		}
+		ignored[k8s.MetaNamespaceKey(ing)] = rules
+	}
+	if len(ignored) == 0 {
+		return findings
+	}
+
+	kept := make([]Finding, 0, len(findings))
+	for _, f := range findings {
+		if rules, ok := ignored[f.Ingress]; ok {
+			if s, ok := rules[f.Rule]; ok {
+				if f.Severity != SeverityError || containsString(suppressible, f.Rule) {
+					s.suppressed++
+					continue
+				}
+				s.kept++
+			}
+		}
+		kept = append(kept, f)
+	}
+
+	keys := make([]string, 0, len(ignored))
+	for key := range ignored {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		rules := ignored[key]
+		names := make([]string, 0, len(rules))
+		for rule := range rules {
+			names = append(names, rule)
+		}
+		sort.Strings(names)
+
+		details := make([]string, 0, len(names))
+		for _, rule := range names {
+			s := rules[rule]
+			if s.kept > 0 {
+				details = append(details, fmt.Sprintf("%v (%d findings, %d errors kept as the rule is not suppressible)", rule, s.suppressed, s.kept))
+				continue
+			}
+			details = append(details, fmt.Sprintf("%v (%d findings)", rule, s.suppressed))
+		}
+		kept = append(kept, Finding{
+			Rule:     suppressionRule,
+			Severity: SeverityInfo,
+			Ingress:  key,
+			Message:  fmt.Sprintf("%v suppresses %v", ignoreRulesAnnotation, strings.Join(details, ", ")),
+		})
+	}
+	return kept
+}
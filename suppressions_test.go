@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+const suppressionManifests = `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: legacy
+  namespace: default
+  annotations:
+    nginx-config-validator/ignore-rules: "cors-permissive, h2c-nginx-version,suppressed-rules"
+spec:
+  ingressClassName: nginx
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+spec:
+  ingressClassName: nginx
+`
+
+func TestApplySuppressions(t *testing.T) {
+	n := newTestController(t, suppressionManifests)
+	legacy := testIngress(t, n, "default/legacy")
+	web := testIngress(t, n, "default/web")
+
+	cors := Finding{Rule: "cors-permissive", Severity: SeverityWarning, Ingress: "default/legacy"}
+	webCORS := Finding{Rule: "cors-permissive", Severity: SeverityWarning, Ingress: "default/web"}
+	snippet := Finding{Rule: "snippet-risk", Severity: SeverityError, Ingress: "default/legacy"}
+	h2c := Finding{Rule: "h2c-nginx-version", Severity: SeverityError, Ingress: "default/legacy"}
+	global := Finding{Rule: "configmap-invalid", Severity: SeverityError}
+
+	tests := []struct {
+		name         string
+		ingresses    []*Ingress
+		findings     []Finding
+		suppressible []string
+		expected     []Finding
+	}{
+		{
+			name:      "no annotation",
+			ingresses: []*Ingress{web},
+			findings:  []Finding{webCORS, global},
+			expected:  []Finding{webCORS, global},
+		},
+		{
+			name:      "suppressed rule",
+			ingresses: []*Ingress{legacy, web},
+			findings:  []Finding{cors, cors, webCORS, snippet, global},
+			expected: []Finding{webCORS, snippet, global, {
+				Rule:     suppressionRule,
+				Severity: SeverityInfo,
+				Ingress:  "default/legacy",
+				Message:  "nginx-config-validator/ignore-rules suppresses cors-permissive (2 findings), h2c-nginx-version (0 findings)",
+			}},
+		},
+		{
+			name:      "error of a rule not suppressible",
+			ingresses: []*Ingress{legacy},
+			findings:  []Finding{cors, h2c},
+			expected: []Finding{h2c, {
+				Rule:     suppressionRule,
+				Severity: SeverityInfo,
+				Ingress:  "default/legacy",
+				Message:  "nginx-config-validator/ignore-rules suppresses cors-permissive (1 findings), h2c-nginx-version (0 findings, 1 errors kept as the rule is not suppressible)",
+			}},
+		},
+		{
+			name:         "error of a suppressible rule",
+			ingresses:    []*Ingress{legacy},
+			findings:     []Finding{cors, h2c},
+			suppressible: []string{"h2c-nginx-version"},
+			expected: []Finding{{
+				Rule:     suppressionRule,
+				Severity: SeverityInfo,
+				Ingress:  "default/legacy",
+				Message:  "nginx-config-validator/ignore-rules suppresses cors-permissive (1 findings), h2c-nginx-version (1 findings)",
+			}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if findings := applySuppressions(tc.ingresses, tc.findings, tc.suppressible); !reflect.DeepEqual(findings, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, findings)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"expvar"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// defaultSyncDebounce is the time to wait for more events before
+	// synchronizing the cluster state
+	defaultSyncDebounce = 2 * time.Second
+	// maxSyncDebounces bounds the delay of a sync when events keep arriving,
+	// as a multiple of the debounce
+	maxSyncDebounces = 10
+)
+
+// counters of the sync queue
+var (
+	syncEventsTotal          = expvar.NewInt("sync_events_total")
+	syncEventsCoalescedTotal = expvar.NewInt("sync_events_coalesced_total")
+	syncsTotal               = expvar.NewInt("syncs_total")
+)
+
+// syncQueue collapses bursts of events into a single synchronization of the
+// cluster state. Every event delays the sync by the debounce, up to
+// maxSyncDebounces times, and the events received while a sync runs trigger
+// a single sync once it finishes.
+type syncQueue struct {
+	debounce time.Duration
+	sync     func()
+
+	signal chan struct{}
+
+	lock    sync.Mutex
+	pending []string
+}
+
+func newSyncQueue(debounce time.Duration, sync func()) *syncQueue {
+	return &syncQueue{
+		debounce: debounce,
+		sync:     sync,
+		signal:   make(chan struct{}, 1),
+	}
+}
+
+// enqueue requests a sync, reason describing the event for the logs
+func (q *syncQueue) enqueue(reason string) {
+	syncEventsTotal.Add(1)
+
+	q.lock.Lock()
+	q.pending = append(q.pending, reason)
+	q.lock.Unlock()
+
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+// Run processes the events until the stop channel is closed
+func (q *syncQueue) Run(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-q.signal:
+		}
+
+		if !q.wait(stopCh) {
+			return
+		}
+
+		q.lock.Lock()
+		reasons := q.pending
+		q.pending = nil
+		q.lock.Unlock()
+		if len(reasons) == 0 {
+			continue
+		}
+
+		if len(reasons) > 1 {
+			syncEventsCoalescedTotal.Add(int64(len(reasons) - 1))
+		}
+		klog.V(2).Infof("Syncing the cluster state after %d events: %v", len(reasons), strings.Join(reasons, ", "))
+		q.sync()
+		syncsTotal.Add(1)
+	}
+}
+
+// wait returns once no event was received for the debounce, or after
+// maxSyncDebounces debounces. It returns false if the stop channel was closed.
+func (q *syncQueue) wait(stopCh <-chan struct{}) bool {
+	if q.debounce <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(q.debounce)
+	defer timer.Stop()
+	deadline := time.After(maxSyncDebounces * q.debounce)
+
+	for {
+		select {
+		case <-stopCh:
+			return false
+		case <-q.signal:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(q.debounce)
+		case <-timer.C:
+			return true
+		case <-deadline:
+			return true
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// runSyncQueue runs a sync queue calling sync until the end of the test
+func runSyncQueue(t *testing.T, debounce time.Duration, sync func()) *syncQueue {
+	t.Helper()
+
+	q := newSyncQueue(debounce, sync)
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		q.Run(stopCh)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		close(stopCh)
+		<-done
+	})
+	return q
+}
+
+func TestSyncQueueCoalesces(t *testing.T) {
+	syncs := make(chan time.Time, 10)
+	q := runSyncQueue(t, 50*time.Millisecond, func() { syncs <- time.Now() })
+
+	coalesced := syncEventsCoalescedTotal.Value()
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		q.enqueue("Ingress default/web")
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case at := <-syncs:
+		if elapsed := at.Sub(start); elapsed < 50*time.Millisecond {
+			t.Errorf("expected the sync to wait for the debounce, it ran after %v", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a sync")
+	}
+	select {
+	case <-syncs:
+		t.Errorf("expected the burst to be synchronized once")
+	case <-time.After(150 * time.Millisecond):
+	}
+	if got := syncEventsCoalescedTotal.Value() - coalesced; got != 4 {
+		t.Errorf("expected 4 coalesced events, got %d", got)
+	}
+}
+
+func TestSyncQueueEventsDuringSync(t *testing.T) {
+	syncs := make(chan struct{}, 10)
+	var q *syncQueue
+	first := true
+	q = runSyncQueue(t, 0, func() {
+		if first {
+			first = false
+			q.enqueue("Service default/web")
+			q.enqueue("Service default/api")
+		}
+		syncs <- struct{}{}
+	})
+
+	q.enqueue("resync")
+	for i := 0; i < 2; i++ {
+		select {
+		case <-syncs:
+		case <-time.After(time.Second):
+			t.Fatalf("expected sync %d", i+1)
+		}
+	}
+	select {
+	case <-syncs:
+		t.Errorf("expected the events received during the sync to trigger a single sync")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSyncQueueMaxDelay(t *testing.T) {
+	debounce := 20 * time.Millisecond
+	syncs := make(chan time.Time, 10)
+	q := runSyncQueue(t, debounce, func() { syncs <- time.Now() })
+
+	start := time.Now()
+	stop := time.After(maxSyncDebounces * debounce * 3)
+	ticker := time.NewTicker(debounce / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case at := <-syncs:
+			if elapsed := at.Sub(start); elapsed > 2*maxSyncDebounces*debounce {
+				t.Errorf("expected the sync to run within %v, it ran after %v", maxSyncDebounces*debounce, elapsed)
+			}
+			return
+		case <-stop:
+			t.Fatal("expected events arriving continuously not to delay the sync forever")
+		case <-ticker.C:
+			q.enqueue("EndpointSlice default/web")
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// counters of the SSL passthrough proxy
+var (
+	proxyConnectionsTotal  = expvar.NewInt("passthrough_connections_total")
+	proxyActiveConnections = expvar.NewInt("passthrough_active_connections")
+	proxyDefaultTotal      = expvar.NewInt("passthrough_default_total")
+	proxyErrorsTotal       = expvar.NewInt("passthrough_errors_total")
+	proxyBytesTotal        = expvar.NewInt("passthrough_bytes_total")
+)
+
+// errClientHelloRead stops the TLS handshake once the ClientHello was read
+var errClientHelloRead = errors.New("client hello read")
+
+// TCPProxy routes TLS connections by the server name of their ClientHello:
+// the hosts with SSL passthrough go straight to the endpoints of their
+// backend, the other connections to the default server, nginx terminating
+// TLS
+type TCPProxy struct {
+	// Default is the address of the server receiving the connections of
+	// hosts without SSL passthrough
+	Default string
+	// DefaultProxyProtocol sends a PROXY protocol header to the default
+	// server, which ingress-nginx listens on with proxy_protocol when SSL
+	// passthrough is enabled
+	DefaultProxyProtocol bool
+
+	// ClientHelloTimeout bounds the time to receive the ClientHello
+	ClientHelloTimeout time.Duration
+	// DialTimeout bounds the time to connect to the upstream
+	DialTimeout time.Duration
+	// IdleTimeout closes the connections without traffic for that long, 0
+	// disables it
+	IdleTimeout time.Duration
+
+	lock sync.RWMutex
+	// servers contains the endpoints of each passthrough host
+	servers map[string][]string
+	next    uint64
+}
+
+// Update replaces the passthrough hosts with the ones of the configuration
+func (p *TCPProxy) Update(cfg *Configuration) {
+	endpoints := map[string][]string{}
+	for _, b := range cfg.Backends {
+		for _, ep := range b.Endpoints {
+			endpoints[b.Name] = append(endpoints[b.Name], net.JoinHostPort(ep.Address, ep.Port))
+		}
+	}
+
+	servers := map[string][]string{}
+	for _, pb := range cfg.PassthroughBackends {
+		if len(endpoints[pb.Backend]) == 0 {
+			klog.Warningf("SSL passthrough host %v has no endpoints, its connections go to the default server", pb.Hostname)
+			continue
+		}
+		servers[strings.ToLower(pb.Hostname)] = endpoints[pb.Backend]
+	}
+
+	p.lock.Lock()
+	p.servers = servers
+	p.lock.Unlock()
+	klog.V(2).Infof("Updated the SSL passthrough proxy with %d hosts", len(servers))
+}
+
+// upstream returns the address the connections for the server name go to,
+// balancing them between the endpoints of passthrough hosts
+func (p *TCPProxy) upstream(serverName string) (string, bool) {
+	p.lock.RLock()
+	endpoints := p.servers[strings.ToLower(serverName)]
+	p.lock.RUnlock()
+
+	if len(endpoints) == 0 {
+		return p.Default, false
+	}
+	i := atomic.AddUint64(&p.next, 1)
+	return endpoints[i%uint64(len(endpoints))], true
+}
+
+// Handle proxies a connection and closes it
+func (p *TCPProxy) Handle(conn net.Conn) {
+	defer conn.Close()
+	proxyConnectionsTotal.Add(1)
+	proxyActiveConnections.Add(1)
+	defer proxyActiveConnections.Add(-1)
+
+	if p.ClientHelloTimeout > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(p.ClientHelloTimeout))
+	}
+	serverName, hello, err := readClientHello(conn)
+	if err != nil {
+		// not TLS, or a broken handshake: nginx answers it
+		klog.V(3).Infof("Unable to read the ClientHello from %v: %v", conn.RemoteAddr(), err)
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+
+	address, passthrough := p.upstream(serverName)
+	if !passthrough {
+		proxyDefaultTotal.Add(1)
+	}
+
+	upstream, err := net.DialTimeout("tcp", address, p.DialTimeout)
+	if err != nil {
+		proxyErrorsTotal.Add(1)
+		klog.Warningf("Error connecting to %v for server name %q: %v", address, serverName, err)
+		return
+	}
+	defer upstream.Close()
+	klog.V(4).Infof("Proxying connection from %v for server name %q to %v", conn.RemoteAddr(), serverName, address)
+
+	if !passthrough && p.DefaultProxyProtocol {
+		if _, err := upstream.Write(proxyProtocolHeader(conn.RemoteAddr(), conn.LocalAddr())); err != nil {
+			proxyErrorsTotal.Add(1)
+			klog.Warningf("Error writing the PROXY protocol header to %v: %v", address, err)
+			return
+		}
+	}
+	if _, err := upstream.Write(hello); err != nil {
+		proxyErrorsTotal.Add(1)
+		klog.Warningf("Error writing the ClientHello to %v: %v", address, err)
+		return
+	}
+
+	var idle *idleMonitor
+	if p.IdleTimeout > 0 {
+		idle = newIdleMonitor(p.IdleTimeout, func() {
+			conn.Close()
+			upstream.Close()
+		})
+		defer idle.stop()
+	}
+
+	done := make(chan struct{}, 2)
+	go p.pipe(upstream, conn, idle, done)
+	go p.pipe(conn, upstream, idle, done)
+	<-done
+}
+
+// pipe copies src to dst, and signals done once the copy stops
+func (p *TCPProxy) pipe(dst, src net.Conn, idle *idleMonitor, done chan<- struct{}) {
+	defer func() { done <- struct{}{} }()
+
+	var r io.Reader = src
+	if idle != nil {
+		r = &activityReader{r: src, idle: idle}
+	}
+	n, _ := io.Copy(dst, r)
+	proxyBytesTotal.Add(n)
+}
+
+// proxyProtocolHeader returns the PROXY protocol v1 header of a connection
+// from src to dst, as the one sent by the SSL passthrough proxy of
+// ingress-nginx
+func proxyProtocolHeader(src, dst net.Addr) []byte {
+	srcTCP, ok := src.(*net.TCPAddr)
+	dstTCP, ok2 := dst.(*net.TCPAddr)
+	if !ok || !ok2 {
+		return []byte("PROXY UNKNOWN\r\n")
+	}
+	protocol := "TCP4"
+	if srcTCP.IP.To4() == nil {
+		protocol = "TCP6"
+	}
+	return []byte(fmt.Sprintf("PROXY %v %v %v %d %d\r\n", protocol, srcTCP.IP, dstTCP.IP, srcTCP.Port, dstTCP.Port))
+}
+
+// idleMonitor closes a proxied connection once neither direction had
+// traffic for the timeout, so a long stream in one direction keeps the
+// connection open
+type idleMonitor struct {
+	timeout time.Duration
+	onIdle  func()
+	// last is the time of the last traffic, in nanoseconds
+	last  atomic.Int64
+	timer *time.Timer
+}
+
+func newIdleMonitor(timeout time.Duration, onIdle func()) *idleMonitor {
+	m := &idleMonitor{timeout: timeout, onIdle: onIdle}
+	m.touch()
+	m.timer = time.AfterFunc(timeout, m.check)
+	return m
+}
+
+// touch records traffic on the connection
+func (m *idleMonitor) touch() {
+	m.last.Store(time.Now().UnixNano())
+}
+
+// check closes the connection when it is idle, and waits for the rest of
+// the timeout otherwise
+func (m *idleMonitor) check() {
+	idle := time.Since(time.Unix(0, m.last.Load()))
+	if idle >= m.timeout {
+		m.onIdle()
+		return
+	}
+	m.timer.Reset(m.timeout - idle)
+}
+
+func (m *idleMonitor) stop() {
+	m.timer.Stop()
+}
+
+// activityReader records the traffic read in its idle monitor
+type activityReader struct {
+	r    io.Reader
+	idle *idleMonitor
+}
+
+func (r *activityReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	if n > 0 {
+		r.idle.touch()
+	}
+	return n, err
+}
+
+// Serve accepts connections on the listener until it is closed
+func (p *TCPProxy) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go p.Handle(conn)
+	}
+}
+
+// readClientHello reads the ClientHello of a TLS connection and returns its
+// server name and the bytes read, which must be sent to the upstream
+func readClientHello(r io.Reader) (string, []byte, error) {
+	var read bytes.Buffer
+	var hello *tls.ClientHelloInfo
+
+	err := tls.Server(readOnlyConn{r: io.TeeReader(r, &read)}, &tls.Config{
+		GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+			hello = info
+			return nil, errClientHelloRead
+		},
+	}).Handshake()
+
+	if hello == nil {
+		return "", read.Bytes(), err
+	}
+	return hello.ServerName, read.Bytes(), nil
+}
+
+// readOnlyConn is a connection that can only be read, used to parse the
+// ClientHello without answering it
+type readOnlyConn struct {
+	r io.Reader
+}
+
+func (c readOnlyConn) Read(b []byte) (int, error)         { return c.r.Read(b) }
+func (c readOnlyConn) Write(_ []byte) (int, error)        { return 0, io.ErrClosedPipe }
+func (c readOnlyConn) Close() error                       { return nil }
+func (c readOnlyConn) LocalAddr() net.Addr                { return nil }
+func (c readOnlyConn) RemoteAddr() net.Addr               { return nil }
+func (c readOnlyConn) SetDeadline(_ time.Time) error      { return nil }
+func (c readOnlyConn) SetReadDeadline(_ time.Time) error  { return nil }
+func (c readOnlyConn) SetWriteDeadline(_ time.Time) error { return nil }
+
+// runProxy serves the SSL passthrough proxy for the hosts of the input,
+// reloading the cluster state every ResyncPeriod with --against-cluster
+func runProxy(args []string, _, stderr io.Writer) int {
+	fs := flag.NewFlagSet("proxy", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	cfg := &NginxConfiguration{}
+	addConfigurationFlags(fs, cfg)
+
+	in := &validateInput{}
+	addInputFlags(fs, in, cfg)
+	listen := fs.String("listen", ":443", "Address the proxy listens on.")
+	proxy := &TCPProxy{}
+	fs.StringVar(&proxy.Default, "default-server", "127.0.0.1:442",
+		"Address of nginx, receiving the connections of the hosts without SSL passthrough.")
+	fs.BoolVar(&proxy.DefaultProxyProtocol, "default-server-proxy-protocol", true,
+		"Send a PROXY protocol header to --default-server, which ingress-nginx expects on its SSL passthrough port.")
+	fs.DurationVar(&proxy.ClientHelloTimeout, "client-hello-timeout", 10*time.Second, "Time to receive the ClientHello.")
+	fs.DurationVar(&proxy.DialTimeout, "dial-timeout", 5*time.Second, "Time to connect to the upstream.")
+	fs.DurationVar(&proxy.IdleTimeout, "idle-timeout", 10*time.Minute, "Time after which connections without traffic are closed. 0 disables it.")
+	metricsAddress := fs.String("metrics-address", "", "Address serving the connection metrics on "+metricsPath+". Empty disables it.")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitInternal
+	}
+
+	if err := in.check(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitInternal
+	}
+
+	s, err := in.load(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitInternal
+	}
+
+	n := newOfflineController(cfg, s)
+	n.Proxy = proxy
+	_, _, configuration := n.getConfiguration(s.ListIngresses())
+	proxy.Update(configuration)
+
+	if in.againstCluster {
+		go func() {
+			ticker := time.NewTicker(cfg.ResyncPeriod)
+			defer ticker.Stop()
+			for range ticker.C {
+				fresh, err := in.load(cfg)
+				if err != nil {
+					klog.Errorf("Error reloading cluster state: %v", err)
+					continue
+				}
+				s.replaceWith(fresh)
+				_, _, configuration := n.getConfiguration(s.ListIngresses())
+				proxy.Update(configuration)
+			}
+		}()
+	}
+
+	if *metricsAddress != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle(metricsPath, expvar.Handler())
+			server := &http.Server{Addr: *metricsAddress, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+			if err := server.ListenAndServe(); err != nil {
+				klog.Errorf("Error serving metrics: %v", err)
+			}
+		}()
+	}
+
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitInternal
+	}
+	klog.Infof("Starting SSL passthrough proxy on %v", *listen)
+	if err := proxy.Serve(l); err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitInternal
+	}
+	return exitOK
+}
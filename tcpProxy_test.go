@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clientHello returns the ClientHello a TLS client sends for the server name
+func clientHello(t *testing.T, serverName string) []byte {
+	t.Helper()
+
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		_ = tls.Client(client, &tls.Config{ServerName: serverName, InsecureSkipVerify: true}).Handshake()
+		client.Close()
+	}()
+
+	name, hello, err := readClientHello(server)
+	if err != nil || name != serverName {
+		t.Fatalf("expected the ClientHello of %v, got %q, %v", serverName, name, err)
+	}
+	return hello
+}
+
+func TestReadClientHello(t *testing.T) {
+	hello := clientHello(t, "db.example.com")
+	if len(hello) == 0 || hello[0] != 0x16 {
+		t.Fatalf("expected a TLS handshake record, got %x", hello)
+	}
+
+	// the bytes read are returned for the upstream
+	name, read, err := readClientHello(strings.NewReader(string(hello)))
+	if err != nil || name != "db.example.com" || string(read) != string(hello) {
+		t.Errorf("expected the ClientHello to be read again, got %q, %v", name, err)
+	}
+
+	name, read, err = readClientHello(strings.NewReader("GET / HTTP/1.1\r\n\r\n"))
+	if err == nil || name != "" || !strings.HasPrefix(string(read), "GET /") {
+		t.Errorf("expected an error and the bytes read for plain HTTP, got %q %q %v", name, read, err)
+	}
+}
+
+// listenUpstream accepts connections, answering each with its name once the
+// ClientHello was received
+func listenUpstream(t *testing.T, name string) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if _, _, err := readClientHello(conn); err == nil {
+					io.WriteString(conn, name)
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestTCPProxy(t *testing.T) {
+	passthrough := listenUpstream(t, "passthrough")
+	host, port, _ := net.SplitHostPort(passthrough)
+	proxy := &TCPProxy{
+		Default:     listenUpstream(t, "nginx"),
+		DialTimeout: time.Second,
+		IdleTimeout: time.Second,
+	}
+	proxy.Update(&Configuration{
+		Backends: []*Backend{
+			{Name: "db-db-443", Endpoints: []Endpoint{{Address: host, Port: port}}},
+			{Name: "empty-empty-443"},
+		},
+		PassthroughBackends: []*SSLPassthroughBackend{
+			{Backend: "db-db-443", Hostname: "db.example.com"},
+			{Backend: "empty-empty-443", Hostname: "empty.example.com"},
+		},
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go proxy.Serve(l)
+
+	tests := map[string]string{
+		"db.example.com":  "passthrough",
+		"DB.example.com":  "passthrough",
+		"web.example.com": "nginx",
+		// passthrough hosts without endpoints are served by nginx
+		"empty.example.com": "nginx",
+	}
+	for serverName, expected := range tests {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Write(clientHello(t, serverName)); err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		answer, err := io.ReadAll(conn)
+		conn.Close()
+		if err != nil || string(answer) != expected {
+			t.Errorf("%v: expected the connection to reach %v, got %q, %v", serverName, expected, answer, err)
+		}
+	}
+}
+
+// listenTCP returns a listener on a free local port
+func listenTCP(t *testing.T) net.Listener {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+// serveProxy serves the proxy on a local port and returns its address
+func serveProxy(t *testing.T, p *TCPProxy) string {
+	t.Helper()
+
+	l := listenTCP(t)
+	go func() { _ = p.Serve(l) }()
+	return l.Addr().String()
+}
+
+func TestTCPProxyDefaultProxyProtocol(t *testing.T) {
+	nginx := listenTCP(t)
+	proxy := serveProxy(t, &TCPProxy{Default: nginx.Addr().String(), DefaultProxyProtocol: true, ClientHelloTimeout: time.Second, DialTimeout: time.Second})
+
+	client, err := net.Dial("tcp", proxy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Write([]byte("GET / HTTP/1.1\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := nginx.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	header, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, port, _ := net.SplitHostPort(client.LocalAddr().String())
+	_, proxyPort, _ := net.SplitHostPort(proxy)
+	expected := "PROXY TCP4 127.0.0.1 127.0.0.1 " + port + " " + proxyPort + "\r\n"
+	if header != expected {
+		t.Errorf("expected the PROXY protocol header %q, got %q", expected, header)
+	}
+}
+
+func TestTCPProxySharedIdleTimeout(t *testing.T) {
+	nginx := listenTCP(t)
+	idleTimeout := 100 * time.Millisecond
+	proxy := serveProxy(t, &TCPProxy{Default: nginx.Addr().String(), ClientHelloTimeout: time.Second, DialTimeout: time.Second, IdleTimeout: idleTimeout})
+
+	client, err := net.Dial("tcp", proxy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Write([]byte("upload")); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := nginx.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go func() { _, _ = io.Copy(io.Discard, conn) }()
+
+	// the upstream never answers, the upload keeps the connection open
+	for deadline := time.Now().Add(4 * idleTimeout); time.Now().Before(deadline); {
+		if _, err := client.Write([]byte(strings.Repeat("x", 64))); err != nil {
+			t.Fatalf("expected a one-way stream to keep the connection open, got %v", err)
+		}
+		time.Sleep(idleTimeout / 5)
+	}
+
+	_ = client.SetReadDeadline(time.Now().Add(10 * idleTimeout))
+	if _, err := client.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("expected the idle connection to be closed, got %v", err)
+	}
+}
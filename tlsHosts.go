@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// tlsHostSecret is a host listed in the TLS section of an Ingress
+type tlsHostSecret struct {
+	ingress string
+	secret  string
+}
+
+// checkDuplicateTLSHosts reports hosts listed in the TLS section of several
+// Ingresses referencing different Secrets. Only the certificate of the first
+// Ingress processed is served, which depends on the creation timestamps.
+func (n *NGINXController) checkDuplicateTLSHosts(ingresses []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+
+	servers := map[string]*Server{}
+	for _, server := range cfg.Servers {
+		servers[server.Hostname] = server
+	}
+
+	hosts := map[string][]tlsHostSecret{}
+	order := []string{}
+	for _, ing := range ingresses {
+		ingKey := k8s.MetaNamespaceKey(ing)
+		for _, tls := range ing.Spec.TLS {
+			if tls.SecretName == "" {
+				continue
+			}
+			secret := fmt.Sprintf("%v/%v", ing.Namespace, tls.SecretName)
+			for _, host := range tls.Hosts {
+				if _, ok := hosts[host]; !ok {
+					order = append(order, host)
+				}
+				hosts[host] = append(hosts[host], tlsHostSecret{ingress: ingKey, secret: secret})
+			}
+		}
+	}
+
+	for _, host := range order {
+		refs := hosts[host]
+		winner := refs[0]
+		if server, ok := servers[host]; ok && server.SSLCert != nil {
+			served := fmt.Sprintf("%v/%v", server.SSLCert.Namespace, server.SSLCert.Name)
+			for _, ref := range refs {
+				if ref.secret == served {
+					winner = ref
+					break
+				}
+			}
+		}
+
+		conflicting := []string{}
+		for _, ref := range refs {
+			if ref.secret != winner.secret {
+				conflicting = append(conflicting, fmt.Sprintf("%v (Ingress %v)", ref.secret, ref.ingress))
+			}
+		}
+		if len(conflicting) == 0 {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Rule:     "tls-duplicate-host",
+			Severity: SeverityWarning,
+			Ingress:  winner.ingress,
+			Host:     host,
+			Message: fmt.Sprintf("host is listed in the TLS section of several Ingresses with different Secrets; the controller serves %v and ignores %v",
+				winner.secret, strings.Join(conflicting, ", ")),
+		})
+	}
+
+	return findings
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// tlsIngress returns the manifest of an Ingress serving host with the TLS secret
+func tlsIngress(name, created, host, secret string) string {
+	return fmt.Sprintf(`
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: %v
+  namespace: default
+  creationTimestamp: "%v"
+spec:
+  ingressClassName: nginx
+  tls:
+  - hosts: [%v]
+    secretName: %v
+  rules:
+  - host: %v
+`, name, created, host, secret, host)
+}
+
+func TestCheckDuplicateTLSHosts(t *testing.T) {
+	tests := []struct {
+		name      string
+		manifests string
+		ingress   string
+		message   string
+	}{
+		{
+			name: "same secret",
+			manifests: tlsIngress("a", "2024-01-01T00:00:00Z", "web.example.com", "web-tls") +
+				tlsIngress("b", "2024-01-02T00:00:00Z", "web.example.com", "web-tls"),
+		},
+		{
+			name: "different hosts",
+			manifests: tlsIngress("a", "2024-01-01T00:00:00Z", "a.example.com", "a-tls") +
+				tlsIngress("b", "2024-01-02T00:00:00Z", "b.example.com", "b-tls"),
+		},
+		{
+			name: "oldest Ingress wins",
+			manifests: tlsIngress("a", "2024-01-02T00:00:00Z", "web.example.com", "new-tls") +
+				tlsIngress("b", "2024-01-01T00:00:00Z", "web.example.com", "old-tls"),
+			ingress: "default/b",
+			message: "host is listed in the TLS section of several Ingresses with different Secrets; the controller serves default/old-tls and ignores default/new-tls (Ingress default/a)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, tc.manifests)
+
+			findings := findingsWithRule(n.checkDuplicateTLSHosts(ingresses, cfg), "tls-duplicate-host")
+			if tc.message == "" {
+				if len(findings) != 0 {
+					t.Errorf("expected no findings, got %v", findings)
+				}
+				return
+			}
+			if len(findings) != 1 || findings[0].Ingress != tc.ingress || findings[0].Message != tc.message {
+				t.Errorf("expected one finding of %v with message %q, got %v", tc.ingress, tc.message, findings)
+			}
+		})
+	}
+}
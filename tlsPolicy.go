@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// tlsPolicy describes the TLS versions and ciphers allowed in the
+// configuration
+type tlsPolicy struct {
+	// protocols are the ssl_protocols allowed
+	protocols []string
+	// ciphers are the TLS 1.2 ciphers allowed, empty means any cipher not
+	// matching bannedCiphers
+	ciphers []string
+	// preferServerCiphers is the recommended value of ssl_prefer_server_ciphers,
+	// empty when there is no recommendation
+	preferServerCiphers string
+}
+
+// bannedCiphers are components of cipher names that are never acceptable
+var bannedCiphers = []string{"RC4", "3DES", "DES-CBC3", "DES", "NULL", "EXPORT", "EXP", "MD5", "aNULL", "eNULL", "ADH", "AECDH", "PSK", "SRP", "IDEA", "SEED", "CAMELLIA"}
+
+// bannedProtocols are the protocols with known vulnerabilities
+var bannedProtocols = []string{"SSLv2", "SSLv3", "TLSv1", "TLSv1.1"}
+
+// tlsPolicies are the profiles of the Mozilla server side TLS guidelines
+// (https://wiki.mozilla.org/Security/Server_Side_TLS)
+var tlsPolicies = map[string]tlsPolicy{
+	"mozilla-modern": {
+		protocols:           []string{"TLSv1.3"},
+		preferServerCiphers: "off",
+	},
+	"mozilla-intermediate": {
+		protocols: []string{"TLSv1.2", "TLSv1.3"},
+		ciphers: []string{
+			"ECDHE-ECDSA-AES128-GCM-SHA256", "ECDHE-RSA-AES128-GCM-SHA256",
+			"ECDHE-ECDSA-AES256-GCM-SHA384", "ECDHE-RSA-AES256-GCM-SHA384",
+			"ECDHE-ECDSA-CHACHA20-POLY1305", "ECDHE-RSA-CHACHA20-POLY1305",
+			"DHE-RSA-AES128-GCM-SHA256", "DHE-RSA-AES256-GCM-SHA384", "DHE-RSA-CHACHA20-POLY1305",
+		},
+		preferServerCiphers: "off",
+	},
+	// baseline only rejects the protocols and ciphers known to be broken
+	"baseline": {
+		protocols: []string{"TLSv1.2", "TLSv1.3"},
+	},
+}
+
+var (
+	sslProtocolsDirective           = regexp.MustCompile(`(?m)^\s*ssl_protocols\s+([^;]+);`)
+	sslCiphersDirective             = regexp.MustCompile(`(?m)^\s*ssl_ciphers\s+["']?([^;"']+)["']?\s*;`)
+	sslPreferServerCiphersDirective = regexp.MustCompile(`(?m)^\s*ssl_prefer_server_ciphers\s+(on|off)\s*;`)
+	// cipherKeyword matches OpenSSL cipher groups, which expand to lists that
+	// depend on the OpenSSL version and can not be checked statically
+	cipherKeyword = regexp.MustCompile(`^[A-Z]+[0-9]*$`)
+)
+
+// tlsPolicyNames returns the names of the available TLS policies
+func tlsPolicyNames() []string {
+	return []string{"baseline", "mozilla-intermediate", "mozilla-modern"}
+}
+
+// checkTLSPolicy validates the TLS protocols and ciphers of the global
+// configuration, the ssl-ciphers annotations and the server snippets against
+// the TLS policy selected with --tls-policy
+func (n *NGINXController) checkTLSPolicy(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	if n.cfg.TLSPolicy == "" {
+		return findings
+	}
+	policy, ok := tlsPolicies[n.cfg.TLSPolicy]
+	if !ok {
+		return findings
+	}
+	global := n.store.GetBackendConfiguration()
+
+	report := func(server *Server, severity Severity, format string, args ...interface{}) {
+		f := Finding{
+			Rule:     "tls-policy",
+			Severity: severity,
+			Message:  fmt.Sprintf("%v: ", n.cfg.TLSPolicy) + fmt.Sprintf(format, args...),
+		}
+		if server != nil {
+			f.Host = server.Hostname
+			f.Ingress = serverIngress(server)
+		}
+		findings = append(findings, f)
+	}
+
+	for _, err := range policy.checkProtocols(global.SSLProtocols) {
+		report(nil, SeverityError, "ssl-protocols: %v", err)
+	}
+	for _, err := range policy.checkCiphers(global.SSLCiphers) {
+		report(nil, SeverityError, "ssl-ciphers: %v", err)
+	}
+	if policy.preferServerCiphers != "" && boolToOnOff(global.SSLPreferServerCiphers) != policy.preferServerCiphers {
+		report(nil, SeverityWarning, "ssl-prefer-server-ciphers should be %v", policy.preferServerCiphers)
+	}
+
+	for _, server := range cfg.Servers {
+		if server.SSLCert == nil {
+			continue
+		}
+
+		if server.SSLCiphers != "" {
+			for _, err := range policy.checkCiphers(server.SSLCiphers) {
+				report(server, SeverityError, "ssl-ciphers annotation: %v", err)
+			}
+		}
+		if server.SSLPreferServerCiphers != "" && policy.preferServerCiphers != "" && server.SSLPreferServerCiphers != policy.preferServerCiphers {
+			report(server, SeverityWarning, "ssl-prefer-server-ciphers annotation should be %v", policy.preferServerCiphers)
+		}
+
+		if m := sslProtocolsDirective.FindStringSubmatch(server.ServerSnippet); m != nil {
+			for _, err := range policy.checkProtocols(m[1]) {
+				report(server, SeverityError, "ssl_protocols in server-snippet: %v", err)
+			}
+		}
+		if m := sslCiphersDirective.FindStringSubmatch(server.ServerSnippet); m != nil {
+			for _, err := range policy.checkCiphers(m[1]) {
+				report(server, SeverityError, "ssl_ciphers in server-snippet: %v", err)
+			}
+		}
+		if m := sslPreferServerCiphersDirective.FindStringSubmatch(server.ServerSnippet); m != nil &&
+			policy.preferServerCiphers != "" && m[1] != policy.preferServerCiphers {
+			report(server, SeverityWarning, "ssl_prefer_server_ciphers in server-snippet should be %v", policy.preferServerCiphers)
+		}
+	}
+
+	return findings
+}
+
+// checkProtocols returns an error for each protocol not allowed
+func (p tlsPolicy) checkProtocols(protocols string) []error {
+	var errs []error
+	for _, protocol := range strings.Fields(protocols) {
+		switch {
+		case containsString(bannedProtocols, protocol):
+			errs = append(errs, fmt.Errorf("%v is insecure and must be disabled", protocol))
+		case !containsString(p.protocols, protocol):
+			errs = append(errs, fmt.Errorf("%v is not allowed, use %v", protocol, strings.Join(p.protocols, " ")))
+		}
+	}
+	return errs
+}
+
+// checkCiphers returns an error for each enabled cipher not allowed. Ciphers
+// removed with ! or - are ignored.
+func (p tlsPolicy) checkCiphers(ciphers string) []error {
+	var errs []error
+	if len(p.protocols) == 1 && p.protocols[0] == "TLSv1.3" {
+		// the TLS 1.3 suites are not configured with ssl_ciphers
+		return errs
+	}
+
+	for _, cipher := range strings.FieldsFunc(ciphers, func(r rune) bool { return r == ':' || r == ',' || r == ' ' }) {
+		if strings.HasPrefix(cipher, "!") || strings.HasPrefix(cipher, "-") || strings.HasPrefix(cipher, "@") {
+			continue
+		}
+		cipher = strings.TrimPrefix(cipher, "+")
+
+		if banned := bannedCipherComponent(cipher); banned != "" {
+			errs = append(errs, fmt.Errorf("cipher %v uses %v, which is insecure", cipher, banned))
+			continue
+		}
+		if len(p.ciphers) == 0 {
+			continue
+		}
+		if cipherKeyword.MatchString(cipher) {
+			errs = append(errs, fmt.Errorf("cipher group %v can not be checked, list the ciphers explicitly", cipher))
+			continue
+		}
+		if !containsString(p.ciphers, cipher) {
+			errs = append(errs, fmt.Errorf("cipher %v is not allowed", cipher))
+		}
+	}
+	return errs
+}
+
+// bannedCipherComponent returns the insecure algorithm used by a cipher
+func bannedCipherComponent(cipher string) string {
+	for _, part := range strings.Split(cipher, "-") {
+		for _, banned := range bannedCiphers {
+			if strings.EqualFold(part, banned) {
+				return banned
+			}
+		}
+	}
+	for _, banned := range []string{"3DES", "DES-CBC3"} {
+		if strings.Contains(strings.ToUpper(cipher), banned) {
+			return banned
+		}
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTLSPolicyCheckProtocols(t *testing.T) {
+	tests := []struct {
+		policy    string
+		protocols string
+		errors    int
+	}{
+		{"baseline", "TLSv1.2 TLSv1.3", 0},
+		{"baseline", "TLSv1 TLSv1.1 TLSv1.2", 2},
+		{"mozilla-intermediate", "SSLv3 TLSv1.2", 1},
+		{"mozilla-modern", "TLSv1.2 TLSv1.3", 1},
+		{"mozilla-modern", "TLSv1.3", 0},
+	}
+
+	for _, tc := range tests {
+		if errs := tlsPolicies[tc.policy].checkProtocols(tc.protocols); len(errs) != tc.errors {
+			t.Errorf("%v %q: expected %d errors, got %v", tc.policy, tc.protocols, tc.errors, errs)
+		}
+	}
+}
+
+func TestTLSPolicyCheckCiphers(t *testing.T) {
+	tests := []struct {
+		policy   string
+		ciphers  string
+		expected []string
+	}{
+		{"baseline", "ECDHE-RSA-AES128-GCM-SHA256:HIGH:!aNULL:!MD5", nil},
+		{"baseline", "ECDHE-RSA-AES128-GCM-SHA256:DES-CBC3-SHA:RC4-MD5", []string{"cipher DES-CBC3-SHA uses DES, which is insecure", "cipher RC4-MD5 uses RC4, which is insecure"}},
+		{"mozilla-intermediate", "ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-CHACHA20-POLY1305", nil},
+		{"mozilla-intermediate", "ECDHE-RSA-AES128-SHA:HIGH:!aNULL", []string{"cipher ECDHE-RSA-AES128-SHA is not allowed", "cipher group HIGH can not be checked, list the ciphers explicitly"}},
+		// the TLS 1.3 suites are not configured with ssl_ciphers
+		{"mozilla-modern", "RC4-MD5", nil},
+	}
+
+	for _, tc := range tests {
+		var got []string
+		for _, err := range tlsPolicies[tc.policy].checkCiphers(tc.ciphers) {
+			got = append(got, err.Error())
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%v %q: expected %q, got %q", tc.policy, tc.ciphers, tc.expected, got)
+		}
+	}
+}
+
+func TestCheckTLSPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		data     map[string]string
+		expected []string
+	}{
+		{
+			name:     "disabled",
+			data:     map[string]string{"ssl-protocols": "TLSv1 TLSv1.2"},
+			expected: []string{},
+		},
+		{
+			name:     "defaults",
+			policy:   "baseline",
+			expected: []string{},
+		},
+		{
+			name:     "insecure protocol",
+			policy:   "baseline",
+			data:     map[string]string{"ssl-protocols": "TLSv1.1 TLSv1.2"},
+			expected: []string{"error: baseline: ssl-protocols: TLSv1.1 is insecure and must be disabled"},
+		},
+		{
+			name:   "prefer server ciphers",
+			policy: "mozilla-intermediate",
+			data: map[string]string{
+				"ssl-ciphers":               "ECDHE-RSA-AES128-GCM-SHA256",
+				"ssl-prefer-server-ciphers": "true",
+			},
+			expected: []string{"warning: mozilla-intermediate: ssl-prefer-server-ciphers should be off"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n := newTestController(t, tlsSessionConfigMap(tc.data)+
+				tlsIngress("web", "2024-01-01T00:00:00Z", "web.example.com", "web-tls"))
+			n.cfg.FakeCertificate = &SSLCert{}
+			n.cfg.TLSPolicy = tc.policy
+			ingresses := n.store.ListIngresses()
+			_, _, cfg := n.getConfiguration(ingresses)
+
+			got := []string{}
+			for _, f := range n.checkTLSPolicy(ingresses, cfg) {
+				got = append(got, fmt.Sprintf("%v: %v", f.Severity, f.Message))
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
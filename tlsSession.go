@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// sessionsPerMegabyte is the number of sessions stored in 1 megabyte of
+	// ssl_session_cache, according to the nginx documentation
+	sessionsPerMegabyte = 4000
+	// defaultTLSSessionsPerHost is used when TLSSessionsPerHost is not set
+	defaultTLSSessionsPerHost = 1000
+)
+
+// minTicketKey80NginxVersion is the first nginx version accepting 80 byte
+// session ticket keys (AES256)
+var minTicketKey80NginxVersion = nginxVersion{Major: 1, Minor: 11, Patch: 8}
+
+// checkTLSSessions validates the TLS session resumption settings of the
+// global configuration: the size of the shared session cache compared to the
+// number of TLS hosts, and the session ticket key. The warnings about the
+// resumption of the sessions are only reported when some hosts use TLS.
+func (n *NGINXController) checkTLSSessions(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	global := n.store.GetBackendConfiguration()
+	report := func(rule string, severity Severity, format string, args ...interface{}) {
+		findings = append(findings, Finding{
+			Rule:     rule,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	tlsServers := 0
+	for _, server := range cfg.Servers {
+		if server.SSLCert != nil {
+			tlsServers++
+		}
+	}
+
+	if global.SSLSessionCache {
+		size, err := parseNginxSize(global.SSLSessionCacheSize)
+		switch {
+		case err != nil:
+			report("tls-session-cache", SeverityError, "invalid ssl-session-cache-size %q: %v", global.SSLSessionCacheSize, err)
+		case tlsServers > 0:
+			perHost := n.cfg.TLSSessionsPerHost
+			if perHost <= 0 {
+				perHost = defaultTLSSessionsPerHost
+			}
+			required := int64(math.Ceil(float64(tlsServers*perHost) / sessionsPerMegabyte * 1024 * 1024))
+			if size < required {
+				report("tls-session-cache", SeverityWarning,
+					"ssl-session-cache-size %v stores about %d sessions shared by %d TLS hosts; at %d sessions per host it should be at least %v, or sessions are evicted before ssl-session-timeout and clients do full handshakes",
+					global.SSLSessionCacheSize, size*sessionsPerMegabyte/(1024*1024), tlsServers, perHost, formatNginxSize(required))
+			}
+		}
+	} else if !global.SSLSessionTickets && tlsServers > 0 {
+		report("tls-session-cache", SeverityInfo,
+			"ssl-session-cache and ssl-session-tickets are disabled; every TLS connection does a full handshake")
+	}
+
+	if !global.SSLSessionTickets {
+		if global.SSLSessionTicketKey != "" && tlsServers > 0 {
+			report("tls-session-ticket-key", SeverityWarning, "ssl-session-ticket-key is set but ssl-session-tickets is disabled, the key is not used")
+		}
+		return findings
+	}
+
+	if global.SSLSessionTicketKey == "" {
+		if tlsServers == 0 {
+			return findings
+		}
+		report("tls-session-ticket-key", SeverityWarning,
+			"ssl-session-tickets is enabled without ssl-session-ticket-key; each controller pod generates its own key, so tickets are not resumed by other replicas and the key only changes when the pod restarts")
+		return findings
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(global.SSLSessionTicketKey))
+	if err != nil {
+		report("tls-session-ticket-key", SeverityError, "ssl-session-ticket-key is not valid base64: %v", err)
+		return findings
+	}
+
+	switch len(key) {
+	case 48:
+	case 80:
+		if version, ok := n.targetNginxVersion(); ok && !version.AtLeast(minTicketKey80NginxVersion) {
+			report("tls-session-ticket-key", SeverityError, "80 byte session ticket keys require nginx %v or later, the target version is %v",
+				minTicketKey80NginxVersion, version)
+		}
+	default:
+		report("tls-session-ticket-key", SeverityError, "ssl-session-ticket-key must decode to 48 or 80 bytes, found %d; nginx fails to start", len(key))
+		return findings
+	}
+
+	// the key is read even without TLS hosts, only its use is gated
+	if tlsServers == 0 {
+		return findings
+	}
+	report("tls-session-ticket-key", SeverityWarning,
+		"ssl-session-ticket-key is a static key: tickets encrypted with it can be decrypted as long as it is in use, breaking forward secrecy; rotate it regularly")
+
+	return findings
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// tlsSessionConfigMap returns the manifest of the controller ConfigMap with the data
+func tlsSessionConfigMap(data map[string]string) string {
+	manifest := `
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ingress-nginx-controller
+  namespace: ingress-nginx
+data:
+`
+	for k, v := range data {
+		manifest += fmt.Sprintf("  %v: %q\n", k, v)
+	}
+	return manifest
+}
+
+func TestCheckTLSSessions(t *testing.T) {
+	key48 := base64.StdEncoding.EncodeToString(make([]byte, 48))
+	key80 := base64.StdEncoding.EncodeToString(make([]byte, 80))
+
+	tests := []struct {
+		name         string
+		data         map[string]string
+		perHost      int
+		nginxVersion string
+		// plain serves the host without TLS
+		plain    bool
+		expected []string
+	}{
+		{
+			name:     "defaults",
+			expected: []string{},
+		},
+		{
+			name:     "cache too small",
+			perHost:  50000,
+			expected: []string{"tls-session-cache/warning"},
+		},
+		{
+			name:     "invalid cache size",
+			data:     map[string]string{"ssl-session-cache-size": "10x"},
+			expected: []string{"tls-session-cache/error"},
+		},
+		{
+			name:     "no resumption",
+			data:     map[string]string{"ssl-session-cache": "false"},
+			expected: []string{"tls-session-cache/info"},
+		},
+		{
+			name:     "unused ticket key",
+			data:     map[string]string{"ssl-session-ticket-key": key48},
+			expected: []string{"tls-session-ticket-key/warning"},
+		},
+		{
+			name:     "tickets without key",
+			data:     map[string]string{"ssl-session-tickets": "true"},
+			expected: []string{"tls-session-ticket-key/warning"},
+		},
+		{
+			name:     "tickets without key or TLS hosts",
+			data:     map[string]string{"ssl-session-tickets": "true"},
+			plain:    true,
+			expected: []string{},
+		},
+		{
+			name:     "unused ticket key without TLS hosts",
+			data:     map[string]string{"ssl-session-ticket-key": key48},
+			plain:    true,
+			expected: []string{},
+		},
+		{
+			name:     "static key without TLS hosts",
+			data:     map[string]string{"ssl-session-tickets": "true", "ssl-session-ticket-key": key48},
+			plain:    true,
+			expected: []string{},
+		},
+		{
+			name:     "invalid key without TLS hosts",
+			data:     map[string]string{"ssl-session-tickets": "true", "ssl-session-ticket-key": "not base64"},
+			plain:    true,
+			expected: []string{"tls-session-ticket-key/error"},
+		},
+		{
+			name:     "static key",
+			data:     map[string]string{"ssl-session-tickets": "true", "ssl-session-ticket-key": key48},
+			expected: []string{"tls-session-ticket-key/warning"},
+		},
+		{
+			name:     "invalid key",
+			data:     map[string]string{"ssl-session-tickets": "true", "ssl-session-ticket-key": "not base64"},
+			expected: []string{"tls-session-ticket-key/error"},
+		},
+		{
+			name:     "key length",
+			data:     map[string]string{"ssl-session-tickets": "true", "ssl-session-ticket-key": base64.StdEncoding.EncodeToString(make([]byte, 32))},
+			expected: []string{"tls-session-ticket-key/error"},
+		},
+		{
+			name:         "80 byte key",
+			data:         map[string]string{"ssl-session-tickets": "true", "ssl-session-ticket-key": key80},
+			nginxVersion: "1.11.8",
+			expected:     []string{"tls-session-ticket-key/warning"},
+		},
+		{
+			name:         "80 byte key with an old nginx",
+			data:         map[string]string{"ssl-session-tickets": "true", "ssl-session-ticket-key": key80},
+			nginxVersion: "1.11.7",
+			expected:     []string{"tls-session-ticket-key/error", "tls-session-ticket-key/warning"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ingress := tlsIngress("web", "2024-01-01T00:00:00Z", "web.example.com", "web-tls")
+			if tc.plain {
+				ingress = loadBalancingIngress("web", "web.example.com", "/", "")
+			}
+			n := newTestController(t, tlsSessionConfigMap(tc.data)+ingress)
+			if !tc.plain {
+				// the host is served with the default certificate
+				n.cfg.FakeCertificate = &SSLCert{}
+			}
+			ingresses := n.store.ListIngresses()
+			_, _, cfg := n.getConfiguration(ingresses)
+			n.cfg.TLSSessionsPerHost = tc.perHost
+			n.cfg.NginxVersion = tc.nginxVersion
+
+			got := []string{}
+			for _, f := range n.checkTLSSessions(ingresses, cfg) {
+				got = append(got, fmt.Sprintf("%v/%v", f.Rule, f.Severity))
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+// views of the terminal UI
+const (
+	tuiServers = iota
+	tuiBackends
+	tuiFindings
+	// tuiLocations lists the locations of the selected server
+	tuiLocations
+	// tuiConfig shows the configuration summary of the selected server
+	tuiConfig
+)
+
+// ANSI escape sequences used to draw the terminal UI
+const (
+	ansiAltScreen   = "\x1b[?1049h"
+	ansiMainScreen  = "\x1b[?1049l"
+	ansiHideCursor  = "\x1b[?25l"
+	ansiShowCursor  = "\x1b[?25h"
+	ansiClearScreen = "\x1b[H\x1b[2J"
+	ansiBold        = "\x1b[1m"
+	ansiReverse     = "\x1b[7m"
+	ansiRed         = "\x1b[31m"
+	ansiYellow      = "\x1b[33m"
+	ansiReset       = "\x1b[0m"
+)
+
+// tuiRow is a line of a view
+type tuiRow struct {
+	text  string
+	color string
+	// server is the server the row refers to, nil when none
+	server *Server
+	// location is the location of the row in the configuration view
+	location string
+}
+
+// tuiPosition is the view and the position of the cursor, kept to go back
+// to the previous views
+type tuiPosition struct {
+	view   int
+	server *Server
+	cursor int
+	offset int
+}
+
+// tuiModel is the state of the terminal UI browsing the result of a
+// validation
+type tuiModel struct {
+	n        *NGINXController
+	cfg      *Configuration
+	findings []Finding
+	servers  []*Server
+	backends []*Backend
+	// severities are the severities of the findings shown
+	severities map[Severity]bool
+
+	tuiPosition
+	history []tuiPosition
+	// rows are the rows of the current view
+	rows []tuiRow
+}
+
+func newTUIModel(n *NGINXController, cfg *Configuration, findings []Finding) *tuiModel {
+	m := &tuiModel{
+		n:          n,
+		cfg:        cfg,
+		findings:   findings,
+		servers:    append([]*Server{}, cfg.Servers...),
+		backends:   append([]*Backend{}, cfg.Backends...),
+		severities: map[Severity]bool{SeverityError: true, SeverityWarning: true, SeverityInfo: true},
+	}
+	sort.Slice(m.servers, func(i, j int) bool { return m.servers[i].Hostname < m.servers[j].Hostname })
+	sort.Slice(m.backends, func(i, j int) bool { return m.backends[i].Name < m.backends[j].Name })
+	m.show(tuiServers, nil)
+	return m
+}
+
+// show switches to a view, the position in the current view being kept to
+// go back to it
+func (m *tuiModel) show(view int, server *Server) {
+	if m.rows != nil {
+		m.history = append(m.history, m.tuiPosition)
+	}
+	m.tuiPosition = tuiPosition{view: view, server: server}
+	m.rows = m.viewRows()
+}
+
+// back returns to the previous view
+func (m *tuiModel) back() {
+	if len(m.history) == 0 {
+		return
+	}
+	m.tuiPosition = m.history[len(m.history)-1]
+	m.history = m.history[:len(m.history)-1]
+	m.rows = m.viewRows()
+}
+
+// severityCounts counts the findings of each severity, by host when host
+// is not empty
+func (m *tuiModel) severityCounts(host string) map[Severity]int {
+	counts := map[Severity]int{}
+	for _, f := range m.findings {
+		if host == "" || f.Host == host {
+			counts[f.Severity]++
+		}
+	}
+	return counts
+}
+
+func (m *tuiModel) viewRows() []tuiRow {
+	rows := []tuiRow{}
+	switch m.view {
+	case tuiServers:
+		for _, server := range m.servers {
+			counts := m.severityCounts(server.Hostname)
+			row := tuiRow{
+				text: fmt.Sprintf("%-50v %4d locations %4d errors %4d warnings",
+					server.Hostname, len(server.Locations), counts[SeverityError], counts[SeverityWarning]),
+				server: server,
+			}
+			switch {
+			case counts[SeverityError] > 0:
+				row.color = ansiRed
+			case counts[SeverityWarning] > 0:
+				row.color = ansiYellow
+			}
+			rows = append(rows, row)
+		}
+	case tuiBackends:
+		for _, backend := range m.backends {
+			service := "-"
+			if backend.Service != nil {
+				service = k8s.MetaNamespaceKey(backend.Service)
+			}
+			rows = append(rows, tuiRow{text: fmt.Sprintf("%-60v service %v port %v, %d endpoints",
+				backend.Name, service, backend.Port.String(), len(backend.Endpoints))})
+		}
+	case tuiFindings:
+		for _, f := range m.findings {
+			if !m.severities[f.Severity] {
+				continue
+			}
+			row := tuiRow{text: f.String()}
+			if f.Host != "" {
+				row.server = findServer(m.cfg, f.Host)
+			}
+			switch f.Severity {
+			case SeverityError:
+				row.color = ansiRed
+			case SeverityWarning:
+				row.color = ansiYellow
+			}
+			rows = append(rows, row)
+		}
+	case tuiLocations:
+		enforceRegex := enforceRegexModifier(m.server.Locations)
+		for _, loc := range m.server.Locations {
+			ingress := ""
+			if loc.Ingress != nil {
+				ingress = k8s.MetaNamespaceKey(loc.Ingress)
+			}
+			rows = append(rows, tuiRow{
+				text:     fmt.Sprintf("%-40v -> %-50v Ingress %v", conformanceLocationKey(loc, enforceRegex), loc.Backend, ingress),
+				server:   m.server,
+				location: loc.Path,
+			})
+		}
+	case tuiConfig:
+		for _, line := range m.serverConfig(m.server) {
+			rows = append(rows, tuiRow{text: line, server: m.server})
+		}
+	}
+	return rows
+}
+
+// serverConfig renders the configuration of a server and of the upstreams
+// of its locations
+func (m *tuiModel) serverConfig(server *Server) []string {
+	used := map[string]bool{}
+	for _, loc := range server.Locations {
+		used[loc.Backend] = true
+	}
+	cfg := &Configuration{Servers: []*Server{server}}
+	for _, backend := range m.backends {
+		if used[backend.Name] {
+			cfg.Backends = append(cfg.Backends, backend)
+		}
+	}
+
+	b := &bytes.Buffer{}
+	if err := m.n.writeConfigSummary(b, cfg); err != nil {
+		return []string{fmt.Sprintf("error summarizing the configuration of %v: %v", server.Hostname, err)}
+	}
+	return strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+}
+
+// handleKey applies a key press and returns false when the UI must exit
+func (m *tuiModel) handleKey(key string, height int) bool {
+	page := max(height-3, 1)
+	switch key {
+	case "q", "ctrl-c":
+		return false
+	case "down", "j":
+		m.cursor++
+	case "up", "k":
+		m.cursor--
+	case "pgdown", " ":
+		m.cursor += page
+	case "pgup":
+		m.cursor -= page
+	case "home", "g":
+		m.cursor = 0
+	case "end", "G":
+		m.cursor = len(m.rows) - 1
+	case "1":
+		m.history = nil
+		m.rows = nil
+		m.show(tuiServers, nil)
+	case "2":
+		m.history = nil
+		m.rows = nil
+		m.show(tuiBackends, nil)
+	case "3":
+		m.history = nil
+		m.rows = nil
+		m.show(tuiFindings, nil)
+	case "e", "w", "i":
+		severity := map[string]Severity{"e": SeverityError, "w": SeverityWarning, "i": SeverityInfo}[key]
+		m.severities[severity] = !m.severities[severity]
+		if m.view == tuiFindings {
+			m.cursor, m.offset = 0, 0
+			m.rows = m.viewRows()
+		}
+	case "a":
+		for severity := range m.severities {
+			m.severities[severity] = true
+		}
+		if m.view == tuiFindings {
+			m.rows = m.viewRows()
+		}
+	case "enter", "l":
+		m.open()
+	case "c":
+		if m.cursor < len(m.rows) && m.rows[m.cursor].server != nil && m.view != tuiConfig {
+			m.show(tuiConfig, m.rows[m.cursor].server)
+		}
+	case "esc", "backspace", "h":
+		m.back()
+	}
+
+	m.cursor = min(max(m.cursor, 0), max(len(m.rows)-1, 0))
+	return true
+}
+
+// open drills into the selected row: the locations of a server, or the
+// configuration summary of a location
+func (m *tuiModel) open() {
+	if m.cursor >= len(m.rows) || m.rows[m.cursor].server == nil {
+		return
+	}
+	row := m.rows[m.cursor]
+	switch m.view {
+	case tuiServers, tuiFindings:
+		m.show(tuiLocations, row.server)
+	case tuiLocations:
+		m.show(tuiConfig, row.server)
+		for i, r := range m.rows {
+			if strings.HasPrefix(strings.TrimSpace(r.text), "location "+row.location+" {") {
+				m.cursor, m.offset = i, i
+				break
+			}
+		}
+	}
+}
+
+// render draws the current view in a terminal of the size
+func (m *tuiModel) render(w io.Writer, width, height int) {
+	b := bufio.NewWriter(w)
+	defer b.Flush()
+
+	b.WriteString(ansiClearScreen)
+	counts := m.severityCounts("")
+	filter := []string{}
+	for _, severity := range []Severity{SeverityError, SeverityWarning, SeverityInfo} {
+		if m.severities[severity] {
+			filter = append(filter, string(severity))
+		}
+	}
+	header := fmt.Sprintf("[1] servers (%d)  [2] backends (%d)  [3] findings (%d errors, %d warnings)  severities: %v",
+		len(m.servers), len(m.backends), counts[SeverityError], counts[SeverityWarning], strings.Join(filter, ","))
+	fmt.Fprintf(b, "%v%v%v\r\n", ansiBold, truncate(header, width), ansiReset)
+
+	title := map[int]string{
+		tuiServers:  "servers",
+		tuiBackends: "backends",
+		tuiFindings: "findings",
+	}[m.view]
+	if m.server != nil {
+		title = map[int]string{tuiLocations: "locations of ", tuiConfig: "configuration summary of "}[m.view] + m.server.Hostname
+	}
+	fmt.Fprintf(b, "%v%v (%d/%d)%v\r\n", ansiBold, truncate(title, width-12), min(m.cursor+1, len(m.rows)), len(m.rows), ansiReset)
+
+	// the cursor is kept in the page
+	page := max(height-3, 1)
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+page {
+		m.offset = m.cursor - page + 1
+	}
+	for i := m.offset; i < len(m.rows) && i < m.offset+page; i++ {
+		row := m.rows[i]
+		text := truncate(strings.ReplaceAll(row.text, "\t", "    "), width)
+		if i == m.cursor {
+			text = ansiReverse + text + strings.Repeat(" ", max(width-len([]rune(text)), 0))
+		}
+		fmt.Fprintf(b, "%v%v%v\r\n", row.color, text, ansiReset)
+	}
+	for i := len(m.rows) - m.offset; i < page; i++ {
+		b.WriteString("\r\n")
+	}
+
+	help := "j/k move  enter open  c config  esc back  e/w/i toggle severity  a all  q quit"
+	fmt.Fprintf(b, "%v%v%v", ansiReverse, truncate(help, width), ansiReset)
+}
+
+// truncate shortens s to width runes
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if width <= 0 || len(r) <= width {
+		return s
+	}
+	return string(r[:width])
+}
+
+// tuiKeys are the escape sequences of the keys with no character
+var tuiKeys = map[string]string{
+	"\x1b[A":  "up",
+	"\x1b[B":  "down",
+	"\x1b[C":  "enter",
+	"\x1b[D":  "esc",
+	"\x1b[5~": "pgup",
+	"\x1b[6~": "pgdown",
+	"\x1b[H":  "home",
+	"\x1b[F":  "end",
+	"\x1b[1~": "home",
+	"\x1b[4~": "end",
+	"\x1b":    "esc",
+	"\r":      "enter",
+	"\n":      "enter",
+	"\x7f":    "backspace",
+	"\x08":    "backspace",
+	"\x03":    "ctrl-c",
+}
+
+// readKey reads a key press from the terminal
+func readKey(r io.Reader) (string, error) {
+	buf := make([]byte, 16)
+	n, err := r.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	if key, ok := tuiKeys[string(buf[:n])]; ok {
+		return key, nil
+	}
+	return string(buf[:n]), nil
+}
+
+// runTUI lets the operator browse the servers, locations, backends and
+// findings of the validation in the terminal until q is pressed
+func runTUI(n *NGINXController, cfg *Configuration, findings []Finding) error {
+	in, out := os.Stdin, os.Stdout
+	if !term.IsTerminal(int(in.Fd())) || !term.IsTerminal(int(out.Fd())) {
+		return errors.New("--tui requires a terminal")
+	}
+
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(in.Fd()), state)
+	fmt.Fprint(out, ansiAltScreen+ansiHideCursor)
+	defer fmt.Fprint(out, ansiShowCursor+ansiMainScreen)
+
+	m := newTUIModel(n, cfg, findings)
+	for {
+		// the size is read again for each key, the terminal can be resized
+		width, height, err := term.GetSize(int(out.Fd()))
+		if err != nil {
+			return err
+		}
+		m.render(out, width, height)
+
+		key, err := readKey(in)
+		if err != nil {
+			return err
+		}
+		if !m.handleKey(key, height) {
+			return nil
+		}
+	}
+}
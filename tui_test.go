@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestTUIModel(t *testing.T) {
+	n, _, cfg := testConfiguration(t, isolationManifests)
+	findings := []Finding{
+		{Rule: "cors-permissive", Severity: SeverityWarning, Ingress: "default/web", Host: "web.example.com", Message: "permissive"},
+		{Rule: "tls-policy", Severity: SeverityError, Ingress: "default/api", Host: "web.example.com", Message: "weak"},
+		{Rule: "snapshot", Severity: SeverityInfo, Message: "global"},
+	}
+	m := newTUIModel(n, cfg, findings)
+
+	// the default server is listed first
+	if m.view != tuiServers || len(m.rows) != 2 || m.rows[1].server.Hostname != "web.example.com" {
+		t.Fatalf("expected the servers, got %+v", m.rows)
+	}
+	if m.rows[1].color != ansiRed || !strings.Contains(m.rows[1].text, "1 errors") {
+		t.Errorf("expected the server with errors in red, got %+v", m.rows[1])
+	}
+
+	// the cursor stays within the rows
+	m.handleKey("up", 20)
+	if m.cursor != 0 {
+		t.Errorf("expected the cursor on the first row, got %v", m.cursor)
+	}
+	m.handleKey("G", 20)
+	if m.cursor != 1 {
+		t.Errorf("expected the cursor on the last row, got %v", m.cursor)
+	}
+
+	m.handleKey("enter", 20)
+	if m.view != tuiLocations || m.server.Hostname != "web.example.com" || len(m.rows) != len(m.server.Locations) {
+		t.Fatalf("expected the locations of web.example.com, got %v %+v", m.view, m.rows)
+	}
+
+	// the configuration is opened on the selected location
+	m.handleKey("j", 20)
+	selected := m.rows[m.cursor].location
+	m.handleKey("enter", 20)
+	if m.view != tuiConfig || !strings.HasPrefix(strings.TrimSpace(m.rows[m.cursor].text), "location "+selected+" {") {
+		t.Errorf("expected the configuration at location %v, got %q", selected, m.rows[m.cursor].text)
+	}
+	if m.rows[0].text != "upstream default-api-80 {" {
+		t.Errorf("expected the upstreams of the server first, got %q", m.rows[0].text)
+	}
+
+	// back to the locations, at the same position
+	m.handleKey("esc", 20)
+	if m.view != tuiLocations || m.cursor != 1 {
+		t.Errorf("expected the locations with the cursor on the second row, got view %v cursor %v", m.view, m.cursor)
+	}
+	m.handleKey("h", 20)
+	if m.view != tuiServers || m.cursor != 1 {
+		t.Errorf("expected the servers with the cursor on the second row, got view %v cursor %v", m.view, m.cursor)
+	}
+
+	// the findings filtered by severity
+	m.handleKey("3", 20)
+	if m.view != tuiFindings || len(m.rows) != 3 || len(m.history) != 0 {
+		t.Fatalf("expected the 3 findings, got %+v", m.rows)
+	}
+	m.handleKey("i", 20)
+	m.handleKey("w", 20)
+	if len(m.rows) != 1 || m.rows[0].color != ansiRed || m.rows[0].server == nil {
+		t.Errorf("expected only the error, with its server, got %+v", m.rows)
+	}
+	m.handleKey("c", 20)
+	if m.view != tuiConfig || m.server.Hostname != "web.example.com" {
+		t.Errorf("expected the configuration of the host of the finding, got %v", m.view)
+	}
+	m.handleKey("esc", 20)
+	m.handleKey("a", 20)
+	if len(m.rows) != 3 {
+		t.Errorf("expected all the findings, got %+v", m.rows)
+	}
+
+	m.handleKey("2", 20)
+	if m.view != tuiBackends || len(m.rows) != len(cfg.Backends) {
+		t.Errorf("expected the backends, got %+v", m.rows)
+	}
+
+	if m.handleKey("q", 20) {
+		t.Errorf("expected q to exit")
+	}
+}
+
+func TestTUIRender(t *testing.T) {
+	n, _, cfg := testConfiguration(t, isolationManifests)
+	m := newTUIModel(n, cfg, nil)
+	m.handleKey("j", 5)
+
+	var out bytes.Buffer
+	m.render(&out, 40, 5)
+	lines := strings.Split(out.String(), "\r\n")
+	if !strings.HasPrefix(lines[0], ansiClearScreen+ansiBold+"[1] servers (2)  [2] backends") {
+		t.Errorf("unexpected header %q", lines[0])
+	}
+	if lines[1] != ansiBold+"servers (2/2)"+ansiReset {
+		t.Errorf("unexpected title %q", lines[1])
+	}
+	// a page of 2 rows, the second one selected and truncated to the width
+	if !strings.HasPrefix(lines[3], ansiReverse+"web.example.com") || len([]rune(strings.TrimPrefix(strings.TrimSuffix(lines[3], ansiReset), ansiReverse))) != 40 {
+		t.Errorf("expected the selected row filling the width, got %q", lines[3])
+	}
+	if len(lines) != 5 || !strings.Contains(lines[4], "j/k move") {
+		t.Errorf("expected the help on the last line, got %q", lines)
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	for input, expected := range map[string]string{
+		"\x1b[A":  "up",
+		"\x1b[6~": "pgdown",
+		"\r":      "enter",
+		"\x7f":    "backspace",
+		"q":       "q",
+	} {
+		key, err := readKey(strings.NewReader(input))
+		if err != nil || key != expected {
+			t.Errorf("expected %q for %q, got %q %v", expected, input, key, err)
+		}
+	}
+	if _, err := readKey(iotest.ErrReader(os.ErrClosed)); err != os.ErrClosed {
+		t.Errorf("expected the read error, got %v", err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("héllo", 2); got != "hé" {
+		t.Errorf("expected the first 2 runes, got %q", got)
+	}
+	if got := truncate("hello", 0); got != "hello" {
+		t.Errorf("expected no truncation without width, got %q", got)
+	}
+}
+
+func TestRunValidateTUI(t *testing.T) {
+	manifest := filepath.Join(t.TempDir(), "ingress.yaml")
+	if err := os.WriteFile(manifest, []byte(cliManifests), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for args, expected := range map[string]string{
+		"--tui --watch": "--tui can not be used with --watch",
+		// the tests do not run in a terminal
+		"--tui": "--tui requires a terminal",
+	} {
+		var stdout, stderr bytes.Buffer
+		code := runCLI(append([]string{"validate", "-f", manifest}, strings.Fields(args)...), &stdout, &stderr)
+		if code != exitInternal || !strings.Contains(stderr.String(), expected) {
+			t.Errorf("expected %q for %v, got %v %q", expected, args, code, stderr.String())
+		}
+	}
+}
@@ -6,7 +6,31 @@ import (
 
 	apiv1 "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/auth"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/authreq"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/authtls"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/canary"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/connection"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/cors"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/customheaders"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/fastcgi"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/ipallowlist"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/ipdenylist"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/log"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/mirror"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/modsecurity"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/opentelemetry"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/proxy"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/proxyssl"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/ratelimit"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/redirect"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/rewrite"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/sessionaffinity"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/sslcipher"
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/upstreamhashby"
 )
 
 type Configuration struct {
@@ -35,6 +59,11 @@ type Configuration struct {
 	DefaultSSLCertificate *SSLCert `json:"-"`
 
 	StreamSnippets []string `json:"StreamSnippets"`
+
+	// IngressErrors reports the Ingresses left out of the configuration
+	// because processing them failed
+	// +optional
+	IngressErrors []Finding `json:"-"`
 }
 
 // SSLCert describes a SSL certificate to be used in a server
@@ -397,6 +426,9 @@ type Location struct {
 	// Opentelemetry allows the global opentelemetry setting to be overridden for a location
 	// +optional
 	Opentelemetry opentelemetry.Config `json:"opentelemetry"`
+	// SubFilter rewrites the bodies of the responses
+	// +optional
+	SubFilter SubFilterConfig `json:"subFilter,omitempty"`
 }
 
 // Ingress defines the valid annotations present in one NGINX Ingress rule
@@ -443,4 +475,8 @@ type AnnotationsIngress struct {
 	Mirror                      mirror.Config
 	StreamSnippet               string
 	Allowlist                   ipallowlist.SourceRange
+	SubFilter                   SubFilterConfig
+	// Errors contains the errors of the annotations ignored because of an
+	// invalid value, by name of the annotation parser
+	Errors map[string]error
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/internal/ingress/annotations/parser"
+)
+
+// sizeAnnotations are the annotations taking an nginx size
+var sizeAnnotations = []string{
+	"client-body-buffer-size",
+	"proxy-body-size",
+	"proxy-buffer-size",
+	"proxy-max-temp-file-size",
+}
+
+// timeoutAnnotations are the annotations taking a number of seconds
+var timeoutAnnotations = []string{
+	"proxy-connect-timeout",
+	"proxy-send-timeout",
+	"proxy-read-timeout",
+	"proxy-next-upstream-timeout",
+}
+
+// checkUnitAnnotations validates the annotations taking sizes and timeouts,
+// and the sizes nginx refuses together
+func (n *NGINXController) checkUnitAnnotations(_ []*Ingress, cfg *Configuration) []Finding {
+	findings := []Finding{}
+	checked := map[*Ingress]bool{}
+
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			if loc.Ingress != nil && !checked[loc.Ingress] {
+				checked[loc.Ingress] = true
+				findings = append(findings, unitAnnotationFindings(server, loc)...)
+			}
+			findings = append(findings, locationSizeFindings(server, loc)...)
+		}
+	}
+
+	return findings
+}
+
+// unitAnnotationFindings validates the syntax of the size and timeout
+// annotations of the Ingress of the location
+func unitAnnotationFindings(server *Server, loc *Location) []Finding {
+	findings := []Finding{}
+	anns := loc.Ingress.Annotations
+
+	for _, name := range sizeAnnotations {
+		value, ok := anns[parser.GetAnnotationWithPrefix(name)]
+		if !ok {
+			continue
+		}
+		if _, err := parseNginxSize(value); err != nil {
+			findings = append(findings, newLocationFinding("unit-invalid", SeverityError, server, loc,
+				"%v %q is not an nginx size like 8k or 1m; nginx refuses it at reload", name, value))
+		}
+	}
+
+	for _, name := range timeoutAnnotations {
+		value, ok := anns[parser.GetAnnotationWithPrefix(name)]
+		if !ok {
+			continue
+		}
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			continue
+		}
+		if d, err := parseNginxTime(value); err == nil {
+			findings = append(findings, newLocationFinding("unit-invalid", SeverityError, server, loc,
+				"%v %q has a unit but the annotation is a number of seconds; the ingress controller ignores it and uses the default, write %d",
+				name, value, int(d.Seconds())))
+			continue
+		}
+		findings = append(findings, newLocationFinding("unit-invalid", SeverityError, server, loc,
+			"%v %q is not a number of seconds; the ingress controller ignores it and uses the default", name, value))
+	}
+
+	return findings
+}
+
+// locationSizeFindings reports the sizes of a location that contradict each
+// other
+func locationSizeFindings(server *Server, loc *Location) []Finding {
+	findings := []Finding{}
+
+	bodyBuffer, err := parseNginxSize(loc.ClientBodyBufferSize)
+	if err == nil {
+		// proxy-body-size 0 disables the limit
+		maxBody, err := parseNginxSize(loc.Proxy.BodySize)
+		if err == nil && maxBody > 0 && bodyBuffer > maxBody {
+			findings = append(findings, newLocationFinding("unit-inconsistent", SeverityWarning, server, loc,
+				"client-body-buffer-size %v of location %q is bigger than proxy-body-size %v; the buffer is never filled, the bigger bodies are rejected with 413",
+				loc.ClientBodyBufferSize, loc.Path, loc.Proxy.BodySize))
+		}
+	}
+
+	// nginx refuses a max temp file size between 0 and the buffer size
+	buffer, err := parseNginxSize(loc.Proxy.BufferSize)
+	if err != nil {
+		return findings
+	}
+	maxTempFile, err := parseNginxSize(loc.Proxy.ProxyMaxTempFileSize)
+	if err == nil && maxTempFile > 0 && maxTempFile < buffer {
+		findings = append(findings, newLocationFinding("unit-inconsistent", SeverityError, server, loc,
+			"proxy-max-temp-file-size %v of location %q is smaller than proxy-buffer-size %v; nginx fails to reload, set it to 0 or at least %v",
+			loc.Proxy.ProxyMaxTempFileSize, loc.Path, loc.Proxy.BufferSize, formatNginxSize(buffer)))
+	}
+
+	return findings
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// unitAnnotationsManifests is completed with the annotations of the Ingress
+const unitAnnotationsManifests = `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: default
+  annotations:
+%v
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`
+
+func TestCheckUnitAnnotations(t *testing.T) {
+	testCases := map[string]struct {
+		annotations string
+		expected    []string
+	}{
+		"valid": {
+			annotations: `    nginx.ingress.kubernetes.io/proxy-body-size: 8m
+    nginx.ingress.kubernetes.io/client-body-buffer-size: 16k
+    nginx.ingress.kubernetes.io/proxy-read-timeout: "120"`,
+		},
+		"invalid size": {
+			annotations: `    nginx.ingress.kubernetes.io/proxy-body-size: 8mb`,
+			expected: []string{
+				`unit-invalid: proxy-body-size "8mb" is not an nginx size like 8k or 1m; nginx refuses it at reload`,
+			},
+		},
+		"timeout with a unit": {
+			annotations: `    nginx.ingress.kubernetes.io/proxy-read-timeout: 2m`,
+			expected: []string{
+				`unit-invalid: proxy-read-timeout "2m" has a unit but the annotation is a number of seconds; the ingress controller ignores it and uses the default, write 120`,
+			},
+		},
+		"invalid timeout": {
+			annotations: `    nginx.ingress.kubernetes.io/proxy-connect-timeout: "-5"`,
+			expected: []string{
+				`unit-invalid: proxy-connect-timeout "-5" is not a number of seconds; the ingress controller ignores it and uses the default`,
+			},
+		},
+		"body buffer bigger than the body": {
+			annotations: `    nginx.ingress.kubernetes.io/proxy-body-size: 1m
+    nginx.ingress.kubernetes.io/client-body-buffer-size: 2m`,
+			expected: []string{
+				`unit-inconsistent: client-body-buffer-size 2m of location "/" is bigger than proxy-body-size 1m; the buffer is never filled, the bigger bodies are rejected with 413`,
+			},
+		},
+		"unlimited body": {
+			annotations: `    nginx.ingress.kubernetes.io/proxy-body-size: "0"
+    nginx.ingress.kubernetes.io/client-body-buffer-size: 2m`,
+		},
+		"max temp file smaller than the buffer": {
+			annotations: `    nginx.ingress.kubernetes.io/proxy-buffer-size: 16k
+    nginx.ingress.kubernetes.io/proxy-max-temp-file-size: 8k`,
+			expected: []string{
+				`unit-inconsistent: proxy-max-temp-file-size 8k of location "/" is smaller than proxy-buffer-size 16k; nginx fails to reload, set it to 0 or at least 16k`,
+			},
+		},
+		"temporary files disabled": {
+			annotations: `    nginx.ingress.kubernetes.io/proxy-buffer-size: 16k
+    nginx.ingress.kubernetes.io/proxy-max-temp-file-size: "0"`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			n, ingresses, cfg := testConfiguration(t, fmt.Sprintf(unitAnnotationsManifests, tc.annotations))
+			findings := []string{}
+			for _, f := range n.checkUnitAnnotations(ingresses, cfg) {
+				findings = append(findings, f.Rule+": "+f.Message)
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			if !reflect.DeepEqual(findings, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, findings)
+			}
+		})
+	}
+}
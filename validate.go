@@ -0,0 +1,113 @@
+package main
+
+import (
+	"k8s.io/klog/v2"
+)
+
+// validationRule inspects the configuration generated from a list of Ingresses
+// and returns the problems found
+type validationRule func(n *NGINXController, ingresses []*Ingress, cfg *Configuration) []Finding
+
+// validationRules contains the checks executed against every generated configuration
+var validationRules = []validationRule{
+	(*NGINXController).checkBackendServices,
+	(*NGINXController).checkServiceMesh,
+	(*NGINXController).checkExternalNameResolution,
+	(*NGINXController).checkH2CBackends,
+	(*NGINXController).checkNetworkPolicies,
+	(*NGINXController).checkDuplicateTLSHosts,
+	(*NGINXController).checkHeaderBuffers,
+	(*NGINXController).checkModSecuritySnippets,
+	(*NGINXController).checkRequestSmuggling,
+	(*NGINXController).checkExternalAuthURLs,
+	(*NGINXController).checkGlobalExternalAuth,
+	(*NGINXController).checkSubFilters,
+	(*NGINXController).checkCustomErrors,
+	(*NGINXController).checkFastCGI,
+	(*NGINXController).checkMirrors,
+	(*NGINXController).checkSessionAffinity,
+	(*NGINXController).checkLoadBalancing,
+	(*NGINXController).checkConnectionHeader,
+	(*NGINXController).checkSatisfy,
+	(*NGINXController).checkRedirects,
+	(*NGINXController).checkFromToWWW,
+	(*NGINXController).checkOpentelemetry,
+	(*NGINXController).checkUnitAnnotations,
+	(*NGINXController).checkProxyTimeouts,
+	(*NGINXController).checkLogs,
+	(*NGINXController).checkBasicAuthSecrets,
+	(*NGINXController).checkCORS,
+	(*NGINXController).checkRateLimits,
+	(*NGINXController).checkTLSSessions,
+	(*NGINXController).checkDNSRecords,
+	(*NGINXController).checkTLSPolicy,
+	(*NGINXController).checkSSLPassthrough,
+	(*NGINXController).checkAuthTLS,
+	(*NGINXController).checkCRLs,
+	(*NGINXController).checkCertificateIssuers,
+	(*NGINXController).checkBudgets,
+	(*NGINXController).checkConfigMap,
+	(*NGINXController).checkMaxmindDatabases,
+	(*NGINXController).checkNginxDeprecations,
+	(*NGINXController).checkListenPorts,
+	(*NGINXController).checkDeprecatedIngressAPIs,
+	(*NGINXController).checkCELRules,
+	(*NGINXController).checkRulePlugins,
+	(*NGINXController).checkIngressErrors,
+	(*NGINXController).checkAnnotationErrors,
+}
+
+// validate generates the configuration for the ingresses and runs all the
+// validation rules against it
+func (n *NGINXController) validate(ingresses []*Ingress) (*Configuration, []Finding) {
+	n.configLock.RLock()
+	defer n.configLock.RUnlock()
+
+	ctx, done := startValidationProfile()
+	defer done()
+
+	var findings []Finding
+	profilePhase(ctx, "filter", func() {
+		ingresses, findings = n.filterIngressesByNamespace(ingresses)
+		findings = append(findings, n.simulateIngressClasses(ingresses)...)
+
+		var classFindings []Finding
+		ingresses, classFindings = n.filterIngressesByClass(ingresses)
+		findings = append(findings, classFindings...)
+	})
+
+	var cfg *Configuration
+	profilePhase(ctx, "build", func() {
+		_, _, cfg = n.getConfiguration(ingresses)
+	})
+
+	rules := validationRules
+	if n.cfg.EnableAvailabilityAudit {
+		rules = append(rules[:len(rules):len(rules)], availabilityRules...)
+	}
+	if n.cfg.EnableSecurityHeadersAudit {
+		rules = append(rules[:len(rules):len(rules)], securityHeaderRules...)
+	}
+
+	for _, rule := range rules {
+		if !profiling.Load() {
+			findings = append(findings, rule(n, ingresses, cfg)...)
+			continue
+		}
+		profilePhase(ctx, ruleName(rule), func() {
+			findings = append(findings, rule(n, ingresses, cfg)...)
+		})
+	}
+	findings = n.applySeverities(findings)
+	findings = n.applyMessageTemplates(findings)
+	findings = applySuppressions(ingresses, findings, n.cfg.SuppressibleRules)
+	for i := range findings {
+		if findings[i].Ingress != "" {
+			findings[i].Source = n.store.GetIngressSource(findings[i].Ingress)
+		}
+	}
+
+	sortFindings(findings)
+	klog.V(2).InfoS("Validated configuration", "ingresses", len(ingresses), "servers", len(cfg.Servers), "findings", len(findings))
+	return cfg, findings
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	networking "k8s.io/api/networking/v1"
+)
+
+const (
+	// defaultStateRefreshInterval is the minimum time between two reloads of
+	// the cluster state for the validation-generation annotation
+	defaultStateRefreshInterval = 30 * time.Second
+	// stateRefreshTimeout bounds a reload of the cluster state for an
+	// admission review, leaving time to validate the Ingress within the
+	// default timeout of the webhooks
+	stateRefreshTimeout = 5 * time.Second
+	// clusterStateTimeout bounds a reload of the cluster state by the sync
+	// queue
+	clusterStateTimeout = time.Minute
+)
+
+// counters of the reloads for the validation-generation annotation
+var (
+	stateRefreshesTotal        = expvar.NewInt("state_refreshes_total")
+	stateRefreshesSkippedTotal = expvar.NewInt("state_refreshes_skipped_total")
+)
+
+// validationGenerationAnnotation is a no-op annotation: changing its value
+// reloads the state of the cluster before the Ingress is validated. It is
+// used when external state, like a secret rotated out-of-band, invalidates
+// the previous results without any field of the Ingress changing.
+var validationGenerationAnnotation = validatorAnnotation("validation-generation")
+
+// validationGenerationChanged returns true when the request creates an
+// Ingress with the annotation or updates its value
+func validationGenerationChanged(req *admissionv1.AdmissionRequest, ing *networking.Ingress) bool {
+	generation, ok := ing.Annotations[validationGenerationAnnotation]
+	if !ok {
+		return false
+	}
+	if req.Operation != admissionv1.Update || len(req.OldObject.Raw) == 0 {
+		return true
+	}
+
+	old := &networking.Ingress{}
+	if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+		return true
+	}
+	return old.Annotations[validationGenerationAnnotation] != generation
+}
+
+// withoutValidationGeneration returns a copy of the annotations without the
+// validation-generation annotation
+func withoutValidationGeneration(annotations map[string]string) map[string]string {
+	if _, ok := annotations[validationGenerationAnnotation]; !ok {
+		return annotations
+	}
+
+	filtered := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		if k != validationGenerationAnnotation {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
+
+// refreshClusterState replaces the content of the store with the current
+// objects of the cluster
+func (n *NGINXController) refreshClusterState(ctx context.Context, s *memoryStore) error {
+	fresh := newMemoryStore(n.cfg.ConfigMapName)
+	if err := loadClusterState(ctx, n.cfg.Client, fresh); err != nil {
+		return err
+	}
+	s.replaceWith(fresh)
+	return nil
+}
+
+// stateRefresher rate limits the reloads of the cluster state requested by
+// the validation-generation annotation, each one listing the whole cluster.
+// The reviews arriving while a reload runs wait for it, and the reloads
+// requested less than interval after the previous one are skipped, the state
+// being recent enough.
+type stateRefresher struct {
+	interval time.Duration
+	timeout  time.Duration
+	refresh  func(ctx context.Context) error
+
+	// running holds the slot of the reload in progress
+	running chan struct{}
+	last    time.Time
+	lastErr error
+}
+
+func newStateRefresher(interval, timeout time.Duration, refresh func(ctx context.Context) error) *stateRefresher {
+	return &stateRefresher{
+		interval: interval,
+		timeout:  timeout,
+		refresh:  refresh,
+		running:  make(chan struct{}, 1),
+	}
+}
+
+// Refresh reloads the cluster state unless it was reloaded less than interval
+// ago, returning the error of the last reload in that case
+func (r *stateRefresher) Refresh(ctx context.Context) error {
+	select {
+	case r.running <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-r.running }()
+
+	if !r.last.IsZero() && time.Since(r.last) < r.interval {
+		stateRefreshesSkippedTotal.Add(1)
+		return r.lastErr
+	}
+
+	stateRefreshesTotal.Add(1)
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	r.last = time.Now()
+	r.lastErr = r.refresh(ctx)
+	return r.lastErr
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// generationIngress returns an Ingress with the validation generation, or
+// without the annotation when generation is empty
+func generationIngress(generation string) *networking.Ingress {
+	ing := &networking.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	if generation != "" {
+		ing.Annotations = map[string]string{validationGenerationAnnotation: generation}
+	}
+	return ing
+}
+
+func TestValidationGenerationChanged(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation admissionv1.Operation
+		old, ing  string
+		expected  bool
+	}{
+		{"create without the annotation", admissionv1.Create, "", "", false},
+		{"create with the annotation", admissionv1.Create, "", "1", true},
+		{"annotation added", admissionv1.Update, "", "1", true},
+		{"annotation changed", admissionv1.Update, "1", "2", true},
+		{"annotation unchanged", admissionv1.Update, "1", "1", false},
+		{"annotation removed", admissionv1.Update, "1", "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var old *networking.Ingress
+			if tc.operation == admissionv1.Update {
+				old = generationIngress(tc.old)
+			}
+			ing := generationIngress(tc.ing)
+			if got := validationGenerationChanged(freezeRequest(t, tc.operation, old, ing), ing); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidationGenerationReview(t *testing.T) {
+	tests := []struct {
+		name       string
+		old, ing   string
+		refreshErr error
+		refreshed  bool
+		warnings   int
+	}{
+		{
+			name: "unchanged generation",
+			old:  "1",
+			ing:  "1",
+		},
+		{
+			name:      "new generation",
+			old:       "1",
+			ing:       "2",
+			refreshed: true,
+		},
+		{
+			name:       "reload error",
+			old:        "1",
+			ing:        "2",
+			refreshErr: errors.New("forbidden"),
+			refreshed:  true,
+			warnings:   1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			refreshed := false
+			h := &admissionHandler{
+				checkIngress: func(*networking.Ingress) ([]Finding, error) { return nil, nil },
+				refreshState: func(context.Context) error {
+					refreshed = true
+					return tc.refreshErr
+				},
+			}
+
+			resp := h.review(context.Background(), freezeRequest(t, admissionv1.Update, generationIngress(tc.old), generationIngress(tc.ing)))
+			if !resp.Allowed {
+				t.Errorf("expected the Ingress to be admitted: %v", resp.Result)
+			}
+			if refreshed != tc.refreshed {
+				t.Errorf("expected the cluster state to be reloaded: %v", tc.refreshed)
+			}
+			if len(resp.Warnings) != tc.warnings {
+				t.Errorf("expected %d warnings, got %v", tc.warnings, resp.Warnings)
+			}
+		})
+	}
+}
+
+func TestIngressChangedIgnoresValidationGeneration(t *testing.T) {
+	old, ing := generationIngress("1"), generationIngress("2")
+	if ingressChanged(freezeRequest(t, admissionv1.Update, old, ing), ing) {
+		t.Error("expected a change of the validation generation only not to count as a change")
+	}
+}
+
+func TestStateRefresher(t *testing.T) {
+	var refreshes atomic.Int32
+	release := make(chan struct{})
+	r := newStateRefresher(time.Hour, time.Minute, func(ctx context.Context) error {
+		refreshes.Add(1)
+		if _, ok := ctx.Deadline(); !ok {
+			t.Errorf("expected the reload to have a deadline")
+		}
+		<-release
+		return errors.New("forbidden")
+	})
+
+	// the reviews arriving during a reload share it
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- r.Refresh(context.Background())
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	if got := refreshes.Load(); got != 1 {
+		t.Errorf("expected a single reload of the cluster state, got %d", got)
+	}
+	for err := range errs {
+		if err == nil || err.Error() != "forbidden" {
+			t.Errorf("expected the error of the reload, got %v", err)
+		}
+	}
+
+	// the reloads are rate limited
+	if err := r.Refresh(context.Background()); err == nil || refreshes.Load() != 1 {
+		t.Errorf("expected the reload to be skipped, got %d reloads and error %v", refreshes.Load(), err)
+	}
+	r.last = time.Now().Add(-2 * time.Hour)
+	r.Refresh(context.Background())
+	if got := refreshes.Load(); got != 2 {
+		t.Errorf("expected a reload once the interval elapsed, got %d reloads", got)
+	}
+}
+
+func TestStateRefresherTimeout(t *testing.T) {
+	r := newStateRefresher(0, 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err := r.Refresh(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the reload to time out, got %v", err)
+	}
+
+	// a review giving up while another reload runs does not wait for it
+	r.running <- struct{}{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.Refresh(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the canceled review not to wait, got %v", err)
+	}
+}
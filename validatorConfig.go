@@ -0,0 +1,431 @@
+package main
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog/v2"
+)
+
+// severityOff drops the findings of a rule
+const severityOff Severity = "off"
+
+var (
+	configReloadsTotal      = expvar.NewInt("config_reloads_total")
+	configReloadErrorsTotal = expvar.NewInt("config_reload_errors_total")
+)
+
+// validatorConfig contains the settings of the validator read from --config,
+// reloaded on SIGHUP or when the file or the trust bundle change, without
+// restarting the webhook. The settings of the file take precedence over the
+// flags.
+type validatorConfig struct {
+	// Severities overrides the severity of the findings of a rule, off
+	// drops them
+	// +optional
+	Severities map[string]Severity `json:"severities,omitempty"`
+	// MessageTemplates are the text/template rewriting the message of the
+	// findings of a rule, {{.Message}} being the original message
+	// +optional
+	MessageTemplates map[string]string `json:"messageTemplates,omitempty"`
+	// TLSPolicy is the policy the TLS protocols and ciphers are validated
+	// against
+	// +optional
+	TLSPolicy string `json:"tlsPolicy,omitempty"`
+	// CORSProfile is the security profile of the CORS configurations
+	// +optional
+	CORSProfile string `json:"corsProfile,omitempty"`
+	// SecurityHeaders are the response headers required by the security
+	// headers audit
+	// +optional
+	SecurityHeaders []string `json:"securityHeaders,omitempty"`
+	// ProductionHosts are the patterns of the hosts audited for availability
+	// +optional
+	ProductionHosts []string `json:"productionHosts,omitempty"`
+	// AccessLogHosts are the patterns of the hosts whose access log is
+	// required
+	// +optional
+	AccessLogHosts []string `json:"accessLogHosts,omitempty"`
+	// TrustBundle is the PEM file of the CAs the certificates must be issued
+	// by
+	// +optional
+	TrustBundle string `json:"trustBundle,omitempty"`
+	// IssuerExemptHosts are the patterns of the hosts allowed to use
+	// untrusted certificates
+	// +optional
+	IssuerExemptHosts []string `json:"issuerExemptHosts,omitempty"`
+	// IssuerExemptNamespaces are the namespaces allowed to use untrusted
+	// certificates
+	// +optional
+	IssuerExemptNamespaces []string `json:"issuerExemptNamespaces,omitempty"`
+	// CELRules are the rules written in the Common Expression Language
+	// +optional
+	CELRules []CELRule `json:"celRules,omitempty"`
+	// ProxyTimeouts are the maximum proxy timeouts of the locations
+	// +optional
+	ProxyTimeouts *proxyTimeoutPolicy `json:"proxyTimeouts,omitempty"`
+	// SuppressibleRules are the rules whose errors the Ingresses can
+	// suppress
+	// +optional
+	SuppressibleRules []string `json:"suppressibleRules,omitempty"`
+}
+
+// flagValidatorConfig returns the reloadable settings set by the flags
+func flagValidatorConfig(cfg *NginxConfiguration) *validatorConfig {
+	return &validatorConfig{
+		Severities:             cfg.RuleSeverities,
+		TLSPolicy:              cfg.TLSPolicy,
+		CORSProfile:            cfg.CORSProfile,
+		SecurityHeaders:        cfg.SecurityHeadersBaseline,
+		ProductionHosts:        cfg.ProductionHosts,
+		AccessLogHosts:         cfg.AccessLogHosts,
+		TrustBundle:            cfg.TrustBundle,
+		IssuerExemptHosts:      cfg.IssuerExemptHosts,
+		IssuerExemptNamespaces: cfg.IssuerExemptNamespaces,
+		CELRules:               cfg.CELRules,
+		SuppressibleRules:      cfg.SuppressibleRules,
+		ProxyTimeouts: &proxyTimeoutPolicy{
+			MaxReadTimeout: &metav1.Duration{Duration: cfg.MaxProxyReadTimeout},
+			MaxSendTimeout: &metav1.Duration{Duration: cfg.MaxProxySendTimeout},
+		},
+	}
+}
+
+// loadValidatorConfig reads a YAML or JSON configuration file
+func loadValidatorConfig(path string) (*validatorConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	vc := &validatorConfig{}
+	if err := yaml.NewYAMLOrJSONDecoder(f, 4096).Decode(vc); err != nil {
+		return nil, fmt.Errorf("error parsing %v: %w", path, err)
+	}
+	return vc, nil
+}
+
+// merge returns the settings of c overridden by the ones set in other
+func (c *validatorConfig) merge(other *validatorConfig) *validatorConfig {
+	merged := *c
+	if other.Severities != nil {
+		merged.Severities = other.Severities
+	}
+	if other.MessageTemplates != nil {
+		merged.MessageTemplates = other.MessageTemplates
+	}
+	if other.TLSPolicy != "" {
+		merged.TLSPolicy = other.TLSPolicy
+	}
+	if other.CORSProfile != "" {
+		merged.CORSProfile = other.CORSProfile
+	}
+	if other.SecurityHeaders != nil {
+		merged.SecurityHeaders = other.SecurityHeaders
+	}
+	if other.ProductionHosts != nil {
+		merged.ProductionHosts = other.ProductionHosts
+	}
+	if other.AccessLogHosts != nil {
+		merged.AccessLogHosts = other.AccessLogHosts
+	}
+	if other.TrustBundle != "" {
+		merged.TrustBundle = other.TrustBundle
+	}
+	if other.IssuerExemptHosts != nil {
+		merged.IssuerExemptHosts = other.IssuerExemptHosts
+	}
+	if other.IssuerExemptNamespaces != nil {
+		merged.IssuerExemptNamespaces = other.IssuerExemptNamespaces
+	}
+	if other.CELRules != nil {
+		merged.CELRules = other.CELRules
+	}
+	if other.SuppressibleRules != nil {
+		merged.SuppressibleRules = other.SuppressibleRules
+	}
+	if other.ProxyTimeouts != nil {
+		if merged.ProxyTimeouts == nil {
+			merged.ProxyTimeouts = &proxyTimeoutPolicy{}
+		}
+		merged.ProxyTimeouts = merged.ProxyTimeouts.merge(other.ProxyTimeouts)
+	}
+	return &merged
+}
+
+// check returns an error if a setting is invalid, the trust bundle being
+// loaded to detect broken files before they are used
+func (c *validatorConfig) check() error {
+	var errs []error
+	for rule, severity := range c.Severities {
+		switch severity {
+		case SeverityError, SeverityWarning, SeverityInfo, severityOff:
+		default:
+			errs = append(errs, fmt.Errorf("unknown severity %q for rule %v", severity, rule))
+		}
+	}
+	if _, err := parseMessageTemplates(c.MessageTemplates); err != nil {
+		errs = append(errs, err)
+	}
+	if c.TLSPolicy != "" {
+		if _, ok := tlsPolicies[c.TLSPolicy]; !ok {
+			errs = append(errs, fmt.Errorf("unknown TLS policy %q, expected one of %v", c.TLSPolicy, tlsPolicyNames()))
+		}
+	}
+	if c.CORSProfile != "" && !containsString(corsProfiles, c.CORSProfile) {
+		errs = append(errs, fmt.Errorf("unknown CORS profile %q, expected one of %v", c.CORSProfile, corsProfiles))
+	}
+	if err := validateCELRules(c.CELRules); err != nil {
+		errs = append(errs, err)
+	}
+	if c.ProxyTimeouts != nil {
+		if err := c.ProxyTimeouts.check(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.TrustBundle != "" {
+		if _, err := loadTrustBundle(c.TrustBundle); err != nil {
+			errs = append(errs, fmt.Errorf("invalid trust bundle: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// apply sets the settings in the configuration of the controller
+func (c *validatorConfig) apply(cfg *NginxConfiguration) {
+	cfg.RuleSeverities = c.Severities
+	// the templates were parsed by check
+	cfg.MessageTemplates, _ = parseMessageTemplates(c.MessageTemplates)
+	cfg.TLSPolicy = c.TLSPolicy
+	cfg.CORSProfile = c.CORSProfile
+	cfg.SecurityHeadersBaseline = c.SecurityHeaders
+	cfg.ProductionHosts = c.ProductionHosts
+	cfg.AccessLogHosts = c.AccessLogHosts
+	cfg.TrustBundle = c.TrustBundle
+	cfg.IssuerExemptHosts = c.IssuerExemptHosts
+	cfg.IssuerExemptNamespaces = c.IssuerExemptNamespaces
+	cfg.CELRules = c.CELRules
+	cfg.SuppressibleRules = c.SuppressibleRules
+	if c.ProxyTimeouts != nil {
+		if c.ProxyTimeouts.MaxReadTimeout != nil {
+			cfg.MaxProxyReadTimeout = c.ProxyTimeouts.MaxReadTimeout.Duration
+		}
+		if c.ProxyTimeouts.MaxSendTimeout != nil {
+			cfg.MaxProxySendTimeout = c.ProxyTimeouts.MaxSendTimeout.Duration
+		}
+	}
+}
+
+// applySeverities overrides the severity of the findings of the rules listed
+// in RuleSeverities
+func (n *NGINXController) applySeverities(findings []Finding) []Finding {
+	if len(n.cfg.RuleSeverities) == 0 {
+		return findings
+	}
+
+	kept := findings[:0]
+	for _, f := range findings {
+		severity, ok := n.cfg.RuleSeverities[f.Rule]
+		if !ok {
+			kept = append(kept, f)
+			continue
+		}
+		if severity == severityOff {
+			continue
+		}
+		f.Severity = severity
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// parseMessageTemplates parses the message templates of the rules, nil when
+// there are none
+func parseMessageTemplates(sources map[string]string) (map[string]*template.Template, error) {
+	if len(sources) == 0 {
+		return nil, nil
+	}
+
+	var errs []error
+	templates := make(map[string]*template.Template, len(sources))
+	for _, rule := range sortedKeys(sources) {
+		t, err := template.New(rule).Option("missingkey=error").Parse(sources[rule])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid message template for rule %v: %w", rule, err))
+			continue
+		}
+		templates[rule] = t
+	}
+	return templates, errors.Join(errs...)
+}
+
+// applyMessageTemplates rewrites the message of the findings of the rules
+// listed in MessageTemplates. The original message is kept when the template
+// fails.
+func (n *NGINXController) applyMessageTemplates(findings []Finding) []Finding {
+	if len(n.cfg.MessageTemplates) == 0 {
+		return findings
+	}
+
+	for i, f := range findings {
+		t, ok := n.cfg.MessageTemplates[f.Rule]
+		if !ok {
+			continue
+		}
+		var b strings.Builder
+		if err := t.Execute(&b, f); err != nil {
+			klog.Warningf("Error executing the message template of rule %v: %v", f.Rule, err)
+			continue
+		}
+		findings[i].Message = b.String()
+	}
+	return findings
+}
+
+// reloadConfig reads ConfigFile again and applies it once the validations in
+// progress are done. The previous settings are kept if the file is invalid.
+func (n *NGINXController) reloadConfig() error {
+	vc, err := loadValidatorConfig(n.cfg.ConfigFile)
+	if err != nil {
+		configReloadErrorsTotal.Add(1)
+		return err
+	}
+	merged := n.flagConfig.merge(vc)
+	if err := merged.check(); err != nil {
+		configReloadErrorsTotal.Add(1)
+		return fmt.Errorf("invalid configuration %v: %w", n.cfg.ConfigFile, err)
+	}
+
+	n.configLock.Lock()
+	merged.apply(n.cfg)
+	n.configLock.Unlock()
+
+	configReloadsTotal.Add(1)
+	klog.Infof("Loaded configuration %v", n.cfg.ConfigFile)
+	return nil
+}
+
+// watchConfig reloads the configuration on SIGHUP, and when ConfigFile, the
+// trust bundle or the client CA and token file of the webhook authentication
+// change, until the stop channel is closed. The directories of the files are
+// watched, as the files of mounted ConfigMaps and Secrets are replaced by
+// symlink swaps, and watched again after each reload as the trust bundle may
+// have moved.
+func (n *NGINXController) watchConfig() error {
+	watched := n.configDirs()
+	if len(watched) == 0 {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating file watcher: %w", err)
+	}
+	for _, dir := range sortedKeys(watched) {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return fmt.Errorf("error watching %v: %w", dir, err)
+		}
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer watcher.Close()
+		defer signal.Stop(hup)
+
+		reload := func() {
+			if n.cfg.ConfigFile != "" {
+				if err := n.reloadConfig(); err != nil {
+					klog.Errorf("Error reloading configuration, keeping the previous one: %v", err)
+				}
+			}
+			if err := n.auth.reload(); err != nil {
+				configReloadErrorsTotal.Add(1)
+				klog.Errorf("Error reloading webhook authentication, keeping the previous one: %v", err)
+			}
+			watched = rewatch(watcher, watched, n.configDirs())
+		}
+
+		var timer <-chan time.Time
+		for {
+			select {
+			case <-n.stopCh:
+				return
+			case <-hup:
+				klog.Infof("Received SIGHUP, reloading configuration")
+				reload()
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				timer = time.After(watchDebounce)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				klog.Warningf("Error watching configuration: %v", err)
+			case <-timer:
+				timer = nil
+				reload()
+			}
+		}
+	}()
+	return nil
+}
+
+// configDirs returns the directories of ConfigFile, the trust bundle and the
+// files of the webhook authentication
+func (n *NGINXController) configDirs() map[string]bool {
+	n.configLock.RLock()
+	paths := append([]string{n.cfg.ConfigFile, n.cfg.TrustBundle}, n.auth.files()...)
+	n.configLock.RUnlock()
+
+	dirs := map[string]bool{}
+	for _, path := range paths {
+		if path != "" {
+			dirs[filepath.Dir(path)] = true
+		}
+	}
+	return dirs
+}
+
+// rewatch watches the directories of the reloaded configuration, a trust
+// bundle moved to another directory being watched from then on, and stops
+// watching the directories no longer used. It returns the directories
+// watched.
+func rewatch(watcher *fsnotify.Watcher, watched, dirs map[string]bool) map[string]bool {
+	for _, dir := range sortedKeys(watched) {
+		if dirs[dir] {
+			continue
+		}
+		if err := watcher.Remove(dir); err != nil {
+			klog.V(2).Infof("Error removing the watch of %v: %v", dir, err)
+		}
+		delete(watched, dir)
+	}
+	for _, dir := range sortedKeys(dirs) {
+		if watched[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			klog.Warningf("Error watching %v, it is watched again on the next reload: %v", dir, err)
+			continue
+		}
+		watched[dir] = true
+	}
+	return watched
+}
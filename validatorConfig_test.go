@@ -0,0 +1,362 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// writeTestFile writes content to name in dir and returns its path
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("unexpected error writing %v: %v", path, err)
+	}
+	return path
+}
+
+func TestLoadValidatorConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	testCases := map[string]struct {
+		content   string
+		expected  *validatorConfig
+		expectErr string
+	}{
+		"YAML": {
+			content: `severities:
+  cors-permissive: error
+  snippet: "off"
+tlsPolicy: mozilla-modern
+issuerExemptNamespaces: [sandbox]
+`,
+			expected: &validatorConfig{
+				Severities:             map[string]Severity{"cors-permissive": SeverityError, "snippet": severityOff},
+				TLSPolicy:              "mozilla-modern",
+				IssuerExemptNamespaces: []string{"sandbox"},
+			},
+		},
+		"JSON": {
+			content:  `{"corsProfile": "strict", "securityHeaders": ["X-Frame-Options"]}`,
+			expected: &validatorConfig{CORSProfile: "strict", SecurityHeaders: []string{"X-Frame-Options"}},
+		},
+		"proxy timeouts": {
+			content: "proxyTimeouts:\n  maxReadTimeout: 5m\n",
+			expected: &validatorConfig{
+				ProxyTimeouts: &proxyTimeoutPolicy{MaxReadTimeout: &metav1.Duration{Duration: 5 * time.Minute}},
+			},
+		},
+		"invalid": {
+			content:   "severities: [error]\n",
+			expectErr: "error parsing",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			vc, err := loadValidatorConfig(writeTestFile(t, dir, "config.yaml", tc.content))
+			if tc.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectErr) {
+					t.Errorf("expected an error containing %q, got %v", tc.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(vc, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, vc)
+			}
+		})
+	}
+
+	if _, err := loadValidatorConfig(filepath.Join(dir, "missing.yaml")); !os.IsNotExist(err) {
+		t.Errorf("expected a missing file error, got %v", err)
+	}
+}
+
+func TestValidatorConfigMerge(t *testing.T) {
+	flags := &validatorConfig{
+		Severities:      map[string]Severity{"snippet": SeverityError},
+		TLSPolicy:       "baseline",
+		CORSProfile:     "default",
+		ProductionHosts: []string{"*.service.justice.gov.uk"},
+	}
+	file := &validatorConfig{
+		TLSPolicy:       "mozilla-intermediate",
+		ProductionHosts: []string{},
+	}
+
+	expected := &validatorConfig{
+		Severities:      map[string]Severity{"snippet": SeverityError},
+		TLSPolicy:       "mozilla-intermediate",
+		CORSProfile:     "default",
+		ProductionHosts: []string{},
+	}
+	if merged := flags.merge(file); !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected %+v, got %+v", expected, merged)
+	}
+	if flags.TLSPolicy != "baseline" {
+		t.Errorf("expected the settings of the flags to be unchanged, got %+v", flags)
+	}
+}
+
+func TestValidatorConfigCheck(t *testing.T) {
+	dir := t.TempDir()
+	_, _, caPEM, _ := testCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "trusted ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}, nil, nil)
+	bundle := writeTestFile(t, dir, "bundle.pem", string(caPEM))
+	empty := writeTestFile(t, dir, "empty.pem", "")
+
+	testCases := map[string]struct {
+		config   validatorConfig
+		expected []string
+	}{
+		"valid": {
+			config: validatorConfig{
+				Severities:  map[string]Severity{"snippet": severityOff, "cors-permissive": SeverityInfo},
+				TLSPolicy:   "mozilla-modern",
+				CORSProfile: "strict",
+				TrustBundle: bundle,
+			},
+		},
+		"unknown severity": {
+			config:   validatorConfig{Severities: map[string]Severity{"snippet": "critical"}},
+			expected: []string{`unknown severity "critical" for rule snippet`},
+		},
+		"unknown policies": {
+			config: validatorConfig{TLSPolicy: "legacy", CORSProfile: "open"},
+			expected: []string{
+				`unknown TLS policy "legacy"`,
+				`unknown CORS profile "open"`,
+			},
+		},
+		"trust bundle without certificates": {
+			config:   validatorConfig{TrustBundle: empty},
+			expected: []string{"invalid trust bundle: " + empty + " does not contain certificates"},
+		},
+		"invalid CEL rule": {
+			config:   validatorConfig{CELRules: []CELRule{{Name: "tls", Object: celObjectServer, Expression: "server."}}},
+			expected: []string{"invalid CEL rule tls"},
+		},
+		"negative proxy timeout": {
+			config:   validatorConfig{ProxyTimeouts: &proxyTimeoutPolicy{MaxSendTimeout: &metav1.Duration{Duration: -time.Second}}},
+			expected: []string{"the maximum proxy timeouts can not be negative"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.config.check()
+			if len(tc.expected) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected errors %q", tc.expected)
+			}
+			for _, expected := range tc.expected {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("expected an error containing %q, got %v", expected, err)
+				}
+			}
+		})
+	}
+}
+
+func TestApplySeverities(t *testing.T) {
+	findings := []Finding{
+		{Rule: "snippet", Severity: SeverityWarning, Ingress: "default/a"},
+		{Rule: "cors-permissive", Severity: SeverityWarning, Ingress: "default/b"},
+		{Rule: "tls-policy", Severity: SeverityError, Ingress: "default/c"},
+	}
+
+	n := newTestController(t, "")
+	n.cfg.RuleSeverities = map[string]Severity{"snippet": severityOff, "cors-permissive": SeverityError}
+
+	expected := []Finding{
+		{Rule: "cors-permissive", Severity: SeverityError, Ingress: "default/b"},
+		{Rule: "tls-policy", Severity: SeverityError, Ingress: "default/c"},
+	}
+	if got := n.applySeverities(append([]Finding{}, findings...)); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+
+	n.cfg.RuleSeverities = nil
+	if got := n.applySeverities(append([]Finding{}, findings...)); !reflect.DeepEqual(got, findings) {
+		t.Errorf("expected the findings unchanged without severities, got %+v", got)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "config.yaml", "tlsPolicy: mozilla-modern\nseverities:\n  snippet: \"off\"\n")
+
+	n := newTestController(t, "")
+	n.cfg.TLSPolicy = "baseline"
+	n.cfg.CORSProfile = "default"
+	n.cfg.ConfigFile = path
+	n.flagConfig = flagValidatorConfig(n.cfg)
+
+	reloads, errs := configReloadsTotal.Value(), configReloadErrorsTotal.Value()
+	if err := n.reloadConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n.cfg.TLSPolicy != "mozilla-modern" || n.cfg.CORSProfile != "default" || n.cfg.RuleSeverities["snippet"] != severityOff {
+		t.Errorf("expected the file to override the flags, got TLS policy %q, CORS profile %q, severities %v",
+			n.cfg.TLSPolicy, n.cfg.CORSProfile, n.cfg.RuleSeverities)
+	}
+
+	// an invalid file keeps the settings in use
+	writeTestFile(t, dir, "config.yaml", "tlsPolicy: legacy\n")
+	if err := n.reloadConfig(); err == nil || !strings.Contains(err.Error(), `unknown TLS policy "legacy"`) {
+		t.Errorf("expected an invalid TLS policy error, got %v", err)
+	}
+	if n.cfg.TLSPolicy != "mozilla-modern" {
+		t.Errorf("expected the previous TLS policy to be kept, got %q", n.cfg.TLSPolicy)
+	}
+
+	// a setting removed from the file falls back to its flag
+	writeTestFile(t, dir, "config.yaml", "{}\n")
+	if err := n.reloadConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n.cfg.TLSPolicy != "baseline" || n.cfg.RuleSeverities != nil {
+		t.Errorf("expected the settings of the flags, got TLS policy %q, severities %v", n.cfg.TLSPolicy, n.cfg.RuleSeverities)
+	}
+
+	if got := configReloadsTotal.Value() - reloads; got != 2 {
+		t.Errorf("expected 2 reloads, got %v", got)
+	}
+	if got := configReloadErrorsTotal.Value() - errs; got != 1 {
+		t.Errorf("expected 1 reload error, got %v", got)
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "config.yaml", "tlsPolicy: baseline\n")
+
+	n := newTestController(t, "")
+	n.cfg.ConfigFile = path
+	n.flagConfig = flagValidatorConfig(n.cfg)
+	if err := n.reloadConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := n.watchConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer close(n.stopCh)
+
+	// mounted ConfigMaps are updated by replacing the file
+	next := writeTestFile(t, dir, "config.yaml.tmp", "tlsPolicy: mozilla-modern\n")
+	if err := os.Rename(next, path); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		n.configLock.RLock()
+		policy := n.cfg.TLSPolicy
+		n.configLock.RUnlock()
+		if policy == "mozilla-modern" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the configuration to be reloaded, got TLS policy %q", policy)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestValidatorConfigMergeProxyTimeouts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("proxyTimeouts:\n  maxReadTimeout: 2m\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	vc, err := loadValidatorConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg := &NginxConfiguration{MaxProxyReadTimeout: time.Minute, MaxProxySendTimeout: 30 * time.Second}
+	merged := flagValidatorConfig(cfg).merge(vc)
+	if err := merged.check(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	merged.apply(cfg)
+
+	if cfg.MaxProxyReadTimeout != 2*time.Minute {
+		t.Errorf("expected the maximum read timeout of the file, got %v", cfg.MaxProxyReadTimeout)
+	}
+	if cfg.MaxProxySendTimeout != 30*time.Second {
+		t.Errorf("expected the maximum send timeout of the flag to be kept, got %v", cfg.MaxProxySendTimeout)
+	}
+}
+
+func TestValidatorConfigMessageTemplates(t *testing.T) {
+	vc := &validatorConfig{MessageTemplates: map[string]string{
+		"tls": "{{.Message}}, see https://docs.example.com/tls",
+	}}
+	if err := vc.check(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	n := newOfflineController(&NginxConfiguration{}, newMemoryStore(""))
+	vc.apply(n.cfg)
+
+	findings := n.applyMessageTemplates([]Finding{
+		{Rule: "tls", Message: "expired certificate"},
+		{Rule: "cors", Message: "wildcard origin"},
+	})
+	if findings[0].Message != "expired certificate, see https://docs.example.com/tls" {
+		t.Errorf("expected the message of the template, got %q", findings[0].Message)
+	}
+	if findings[1].Message != "wildcard origin" {
+		t.Errorf("expected the message of a rule without template to be kept, got %q", findings[1].Message)
+	}
+
+	invalid := &validatorConfig{MessageTemplates: map[string]string{"tls": "{{.Message"}}
+	if err := invalid.check(); err == nil {
+		t.Errorf("expected an error for an invalid template")
+	}
+}
+
+func TestRewatch(t *testing.T) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+
+	previous, moved, missing := t.TempDir(), t.TempDir(), filepath.Join(t.TempDir(), "missing")
+	if err := watcher.Add(previous); err != nil {
+		t.Fatal(err)
+	}
+	watched := rewatch(watcher, map[string]bool{previous: true}, map[string]bool{moved: true, missing: true})
+
+	if !watched[moved] || watched[previous] || watched[missing] {
+		t.Errorf("expected only %v to be watched, got %v", moved, watched)
+	}
+	if list := watcher.WatchList(); len(list) != 1 || list[0] != moved {
+		t.Errorf("expected the watcher to watch %v, got %v", moved, list)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is the time to wait for more changes before validating again,
+// editors usually write several events when saving a file
+const watchDebounce = 300 * time.Millisecond
+
+// watchValidate validates the input every time one of the local input files
+// changes, printing the findings that appeared or were resolved since the
+// previous run. It only returns on errors setting up the watches.
+func watchValidate(cfg *NginxConfiguration, in *validateInput, stdout, stderr io.Writer) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	watched := newWatchedPaths()
+	for _, path := range in.localPaths() {
+		if err := watched.add(watcher, path); err != nil {
+			return fmt.Errorf("error watching %v: %w", path, err)
+		}
+	}
+
+	var previous []Finding
+	run := func() {
+		s, err := in.load(cfg)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v %v\n", time.Now().Format(time.TimeOnly), err)
+			return
+		}
+
+		_, findings := newOfflineController(cfg, s).validate(s.ListIngresses())
+		added, resolved := diffFindings(previous, findings)
+		previous = findings
+
+		fmt.Fprintf(stdout, "%v validated: %d finding(s), %d new, %d resolved\n",
+			time.Now().Format(time.TimeOnly), len(findings), len(added), len(resolved))
+		for _, f := range added {
+			fmt.Fprintf(stdout, "  + %v\n", f)
+		}
+		for _, f := range resolved {
+			fmt.Fprintf(stdout, "  - %v\n", f)
+		}
+	}
+
+	run()
+
+	var timer <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !watched.relevant(event.Name) {
+				continue
+			}
+			if event.Has(fsnotify.Create) && watched.dirs[filepath.Dir(event.Name)] {
+				// watch directories created inside watched directories
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					_ = watched.add(watcher, event.Name)
+				}
+			}
+			timer = time.After(watchDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintf(stderr, "file watcher error: %v\n", err)
+		case <-timer:
+			timer = nil
+			run()
+		}
+	}
+}
+
+// watchedPaths are the watched directories, and the files watched through
+// their directory. Editors saving by rename and the symlink swaps of the
+// ConfigMap volumes replace the file, which removes a watch on the file
+// itself.
+type watchedPaths struct {
+	dirs map[string]bool
+	// files are the watched files by directory
+	files map[string]map[string]bool
+}
+
+func newWatchedPaths() *watchedPaths {
+	return &watchedPaths{dirs: map[string]bool{}, files: map[string]map[string]bool{}}
+}
+
+// relevant returns true if an event on path changes the input: any change in
+// the watched directories, and in the directory of a watched file the
+// changes of the file or of the hidden entries of the symlink swaps, such as
+// ..data
+func (w *watchedPaths) relevant(path string) bool {
+	dir, name := filepath.Split(filepath.Clean(path))
+	dir = filepath.Clean(dir)
+	if w.dirs[dir] {
+		return true
+	}
+	return w.files[dir][name] || strings.HasPrefix(name, "..")
+}
+
+// add watches a file through its directory, or a directory and all its
+// subdirectories
+func (w *watchedPaths) add(watcher *fsnotify.Watcher, path string) error {
+	path = filepath.Clean(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		dir := filepath.Dir(path)
+		if w.files[dir] == nil {
+			w.files[dir] = map[string]bool{}
+		}
+		w.files[dir][filepath.Base(path)] = true
+		return watcher.Add(dir)
+	}
+
+	return filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			w.dirs[p] = true
+			return watcher.Add(p)
+		}
+		return nil
+	})
+}
+
+// diffFindings returns the findings present in current but not in previous,
+// and the ones present in previous but not in current
+func diffFindings(previous, current []Finding) ([]Finding, []Finding) {
+	prev := map[Finding]bool{}
+	for _, f := range previous {
+		prev[f.withoutSource()] = true
+	}
+	curr := map[Finding]bool{}
+	for _, f := range current {
+		curr[f.withoutSource()] = true
+	}
+
+	added := []Finding{}
+	for _, f := range current {
+		if !prev[f.withoutSource()] {
+			added = append(added, f)
+		}
+	}
+	resolved := []Finding{}
+	for _, f := range previous {
+		if !curr[f.withoutSource()] {
+			resolved = append(resolved, f)
+		}
+	}
+
+	return added, resolved
+}
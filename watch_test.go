@@ -0,0 +1,155 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestDiffFindings(t *testing.T) {
+	a := Finding{Rule: "a", Severity: SeverityWarning, Host: "a.example.com"}
+	b := Finding{Rule: "b", Severity: SeverityError, Host: "b.example.com"}
+	c := Finding{Rule: "c", Severity: SeverityInfo, Host: "c.example.com"}
+
+	tests := []struct {
+		name     string
+		previous []Finding
+		current  []Finding
+		added    []Finding
+		resolved []Finding
+	}{
+		{
+			name:     "first run",
+			current:  []Finding{a, b},
+			added:    []Finding{a, b},
+			resolved: []Finding{},
+		},
+		{
+			name:     "unchanged",
+			previous: []Finding{a, b},
+			current:  []Finding{b, a},
+			added:    []Finding{},
+			resolved: []Finding{},
+		},
+		{
+			name:     "added and resolved",
+			previous: []Finding{a, b},
+			current:  []Finding{b, c},
+			added:    []Finding{c},
+			resolved: []Finding{a},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			added, resolved := diffFindings(tc.previous, tc.current)
+			if !reflect.DeepEqual(added, tc.added) {
+				t.Errorf("expected added %v, got %v", tc.added, added)
+			}
+			if !reflect.DeepEqual(resolved, tc.resolved) {
+				t.Errorf("expected resolved %v, got %v", tc.resolved, resolved)
+			}
+		})
+	}
+}
+
+func TestValidateInputLocalPaths(t *testing.T) {
+	in := &validateInput{
+		manifests:     stringSliceFlag{"a.yaml", "dir"},
+		values:        stringSliceFlag{"values.yaml"},
+		helmChart:     "chart",
+		kustomization: "overlay",
+	}
+
+	expected := []string{"a.yaml", "dir", "values.yaml", "chart", "overlay"}
+	if paths := in.localPaths(); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+}
+
+func TestWatchedPathsRelevant(t *testing.T) {
+	dir := t.TempDir()
+	manifests := filepath.Join(dir, "manifests")
+	if err := os.Mkdir(manifests, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "ingress.yaml")
+	if err := os.WriteFile(file, []byte("kind: List\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+
+	watched := newWatchedPaths()
+	for _, path := range []string{file, manifests} {
+		if err := watched.add(watcher, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string]bool{
+		file:                                   true,
+		filepath.Join(dir, "..data"):           true,
+		filepath.Join(dir, "other.yaml"):       false,
+		filepath.Join(manifests, "other.yaml"): true,
+	}
+	for path, expected := range tests {
+		if relevant := watched.relevant(path); relevant != expected {
+			t.Errorf("%v: expected relevant %v, got %v", path, expected, relevant)
+		}
+	}
+}
+
+func TestWatchedPathsRenameSave(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "ingress.yaml")
+	if err := os.WriteFile(file, []byte("kind: List\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+
+	watched := newWatchedPaths()
+	if err := watched.add(watcher, file); err != nil {
+		t.Fatal(err)
+	}
+
+	// save the file the way editors do, twice: a watch on the file itself
+	// would miss the second save
+	for i := 0; i < 2; i++ {
+		tmp := filepath.Join(dir, ".ingress.yaml.swp")
+		if err := os.WriteFile(tmp, []byte("kind: List\nitems: []\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, file); err != nil {
+			t.Fatal(err)
+		}
+
+		timeout := time.After(5 * time.Second)
+	wait:
+		for {
+			select {
+			case event := <-watcher.Events:
+				if watched.relevant(event.Name) && filepath.Base(event.Name) == "ingress.yaml" {
+					break wait
+				}
+			case err := <-watcher.Errors:
+				t.Fatalf("unexpected error: %v", err)
+			case <-timeout:
+				t.Fatalf("save %d: no event for %v", i+1, file)
+			}
+		}
+	}
+}
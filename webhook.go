@@ -0,0 +1,654 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+
+	"github.com/jaskaransarkaria/nginx-ingress-validator/pkg/k8s"
+)
+
+const (
+	// admissionPath is the path of the validating webhook for Ingresses
+	admissionPath = "/networking/v1/ingresses"
+
+	// maxAdmissionWarnings limits the number of warnings returned to the
+	// API server, which truncates long lists anyway
+	maxAdmissionWarnings = 20
+)
+
+var ingressResource = metav1.GroupVersionResource{
+	Group:    networking.GroupName,
+	Version:  "v1",
+	Resource: "ingresses",
+}
+
+// CheckIngress validates the configuration obtained adding ing to the
+// Ingresses of the store connected to it, or replacing the existing version,
+// and returns the findings related to the Ingress. Only the Ingresses sharing
+// a host or a Service with ing, directly or through other Ingresses, are
+// built, keeping the memory of an admission proportional to the Ingresses
+// it affects. An error is returned when the Ingress must not be admitted.
+func (n *NGINXController) CheckIngress(ing *networking.Ingress) ([]Finding, error) {
+	key := k8s.MetaNamespaceKey(ing)
+	if !n.namespaceInScope(ing.Namespace) {
+		klog.V(3).Infof("Ingress %q is in a namespace not matching the namespace selector, skipping validation", key)
+		return nil, nil
+	}
+
+	candidate, err := newIngress(ing, n.store)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing annotations: %w", err)
+	}
+
+	ingresses := []*Ingress{}
+	for _, existing := range n.store.ListConnectedIngresses(ing) {
+		if k8s.MetaNamespaceKey(existing) != key {
+			ingresses = append(ingresses, existing)
+		}
+	}
+	ingresses = append(ingresses, candidate)
+
+	_, findings := n.validate(ingresses)
+	findings = findingsForIngress(findings, candidate)
+
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return findings, fmt.Errorf("ingress %v: %v: %v", key, f.Rule, f.Message)
+		}
+	}
+
+	return findings, nil
+}
+
+// recordAdmission records the result of the validation of an admission
+// request in the Events and the reports. Dry runs are not persisted, so they
+// are not reported.
+func (n *NGINXController) recordAdmission(req *admissionv1.AdmissionRequest, ing *networking.Ingress, findings []Finding, err error) {
+	n.recordAdmissionEvents(req, ing, findings, err)
+	if req.DryRun == nil || !*req.DryRun {
+		n.reports.record(ing, findings, err)
+	}
+}
+
+// findingsForIngress returns the findings reported for the Ingress or for
+// one of the hosts it defines
+func findingsForIngress(findings []Finding, ing *Ingress) []Finding {
+	key := k8s.MetaNamespaceKey(ing)
+	hosts := map[string]bool{}
+	for _, rule := range ing.Spec.Rules {
+		hosts[rule.Host] = true
+	}
+
+	filtered := []Finding{}
+	for _, f := range findings {
+		if f.Ingress == key || (f.Host != "" && hosts[f.Host]) {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
+}
+
+// findingsForIngresses returns the findings of any of the Ingresses, once
+func findingsForIngresses(findings []Finding, ingresses []*Ingress) []Finding {
+	filtered := []Finding{}
+	seen := map[Finding]bool{}
+	for _, ing := range ingresses {
+		for _, f := range findingsForIngress(findings, ing) {
+			if !seen[f] {
+				seen[f] = true
+				filtered = append(filtered, f)
+			}
+		}
+	}
+	sortFindings(filtered)
+	return filtered
+}
+
+// admissionHandler handles AdmissionReview requests for Ingresses
+type admissionHandler struct {
+	checkIngress func(*networking.Ingress) ([]Finding, error)
+
+	// limiter bounds the validations running in parallel, nil means no limit
+	limiter *validationLimiter
+	// namespaceLimiter bounds the rate of the validations of each namespace,
+	// nil means no limit
+	namespaceLimiter *namespaceRateLimiter
+	// allowOnOverload admits the Ingresses that can not be validated
+	// because of the limits instead of rejecting them
+	allowOnOverload bool
+
+	// policies are checked before the validation of the configuration
+	policies []admissionPolicy
+
+	// refreshState reloads the state of the cluster when the
+	// validation-generation annotation changes, nil disables the reload
+	refreshState func(ctx context.Context) error
+
+	// accepted is called with the admitted Ingresses, nil when not needed
+	accepted func(*networking.Ingress)
+
+	// validated is called with the result of the validation of the
+	// configuration, nil when not needed
+	validated func(req *admissionv1.AdmissionRequest, ing *networking.Ingress, findings []Finding, err error)
+}
+
+func (h *admissionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	review := &admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(r.Body).Decode(review); err != nil {
+		klog.Errorf("Error decoding AdmissionReview: %v", err)
+		http.Error(w, "invalid AdmissionReview", http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "AdmissionReview without request", http.StatusBadRequest)
+		return
+	}
+
+	review.Response = h.review(r.Context(), review.Request)
+	review.Request = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		klog.Errorf("Error writing AdmissionReview response: %v", err)
+	}
+}
+
+// review returns the response to an admission request
+func (h *admissionHandler) review(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	resp := &admissionv1.AdmissionResponse{
+		UID:     req.UID,
+		Allowed: true,
+	}
+
+	if req.Resource != ingressResource ||
+		(req.Operation != admissionv1.Create && req.Operation != admissionv1.Update) {
+		return resp
+	}
+
+	ing := &networking.Ingress{}
+	if err := json.Unmarshal(req.Object.Raw, ing); err != nil {
+		klog.Errorf("Error decoding Ingress %v/%v: %v", req.Namespace, req.Name, err)
+		resp.Allowed = false
+		resp.Result = &metav1.Status{
+			Status: metav1.StatusFailure, Code: http.StatusBadRequest,
+			Reason: metav1.StatusReasonBadRequest, Message: err.Error(),
+		}
+		return resp
+	}
+	if ing.Namespace == "" {
+		ing.Namespace = req.Namespace
+	}
+
+	var findings []Finding
+	var err error
+	for _, policy := range h.policies {
+		for _, f := range policy(req, ing) {
+			findings = append(findings, f)
+			if f.Severity == SeverityError && err == nil {
+				err = fmt.Errorf("ingress %v/%v: %v: %v", ing.Namespace, ing.Name, f.Rule, f.Message)
+			}
+		}
+	}
+
+	// the configuration is not validated when a policy rejects the Ingress
+	if err == nil {
+		// the namespaces over their rate are rejected even with
+		// allowOnOverload, which would let them skip the validation
+		if limitErr := h.namespaceLimiter.accept(ing.Namespace); limitErr != nil {
+			klog.Warningf("Unable to validate Ingress %v/%v: %v", ing.Namespace, ing.Name, limitErr)
+			admissionShedTotal.Add(shedReason(limitErr), 1)
+			return overloadedResponse(resp, limitErr, false, h.namespaceLimiter.retryAfter())
+		}
+
+		release, limitErr := h.limiter.acquire(ctx)
+		if limitErr != nil {
+			klog.Warningf("Unable to validate Ingress %v/%v: %v", ing.Namespace, ing.Name, limitErr)
+			admissionShedTotal.Add(shedReason(limitErr), 1)
+			retryAfter := time.Second
+			if h.limiter != nil && h.limiter.timeout > retryAfter {
+				retryAfter = h.limiter.timeout
+			}
+			return overloadedResponse(resp, limitErr, h.allowOnOverload, retryAfter)
+		}
+		defer release()
+
+		if h.refreshState != nil && validationGenerationChanged(req, ing) {
+			klog.Infof("Validation generation of Ingress %v/%v changed, reloading the cluster state", ing.Namespace, ing.Name)
+			if refreshErr := h.refreshState(ctx); refreshErr != nil {
+				klog.Errorf("Error reloading cluster state: %v", refreshErr)
+				findings = append(findings, Finding{
+					Rule:     "validation-generation",
+					Severity: SeverityWarning,
+					Ingress:  ing.Namespace + "/" + ing.Name,
+					Message:  fmt.Sprintf("unable to reload the cluster state, the Ingress is validated against the previous state: %v", refreshErr),
+				})
+			}
+		}
+
+		var validationFindings []Finding
+		validationFindings, err = h.checkIngress(ing)
+		if h.validated != nil {
+			h.validated(req, ing, validationFindings, err)
+		}
+		findings = append(findings, validationFindings...)
+	}
+
+	for _, f := range findings {
+		if f.Severity != SeverityWarning {
+			continue
+		}
+		if len(resp.Warnings) == maxAdmissionWarnings {
+			break
+		}
+		resp.Warnings = append(resp.Warnings, fmt.Sprintf("%v: %v", f.Rule, f.Message))
+	}
+
+	if err != nil {
+		klog.Infof("Rejecting Ingress %v/%v: %v", ing.Namespace, ing.Name, err)
+		resp.Allowed = false
+		resp.Result = &metav1.Status{
+			Status: metav1.StatusFailure, Code: http.StatusBadRequest,
+			Reason: metav1.StatusReasonBadRequest, Message: err.Error(),
+		}
+		return resp
+	}
+
+	klog.V(2).Infof("Accepting Ingress %v/%v", ing.Namespace, ing.Name)
+	if h.accepted != nil {
+		h.accepted(ing)
+	}
+	return resp
+}
+
+// startValidationWebhook serves the admission webhook until the server is closed
+func (n *NGINXController) startValidationWebhook() error {
+	limiter := newValidationLimiter(n.cfg.MaxConcurrentValidations, n.cfg.ValidationQueueDepth, n.cfg.ValidationQueueTimeout)
+	namespaceLimiter := newNamespaceRateLimiter(n.cfg.NamespaceRateLimit, n.cfg.NamespaceRateLimitBurst)
+	handler := &admissionHandler{
+		checkIngress:     n.CheckIngress,
+		limiter:          limiter,
+		allowOnOverload:  n.cfg.AllowOnOverload,
+		namespaceLimiter: namespaceLimiter,
+		validated:        n.recordAdmission,
+	}
+	if s, ok := n.store.(*memoryStore); ok && n.cfg.Client != nil {
+		refresher := newStateRefresher(n.cfg.StateRefreshInterval, stateRefreshTimeout, func(ctx context.Context) error {
+			return n.refreshClusterState(ctx, s)
+		})
+		handler.refreshState = refresher.Refresh
+	}
+	if n.syncQueue != nil {
+		// the debounce leaves time to the API server to persist the Ingress
+		// before the cluster state is reloaded
+		handler.accepted = func(ing *networking.Ingress) {
+			n.syncQueue.enqueue(fmt.Sprintf("Ingress %v/%v", ing.Namespace, ing.Name))
+		}
+	}
+
+	freeze, err := newChangeFreeze(n.cfg)
+	if err != nil {
+		return err
+	}
+	if freeze != nil {
+		handler.policies = append(handler.policies, freeze.check)
+	}
+
+	auth := n.auth
+	mux := http.NewServeMux()
+	mux.Handle(admissionPath, auth.wrap(handler))
+	mux.HandleFunc(healthzPath, n.healthzHandler)
+	mux.HandleFunc(readyzPath, n.readyzHandler)
+	mux.Handle(preValidationPath, auth.wrap(limiter.wrap(http.HandlerFunc(n.preValidationHandler))))
+	switch {
+	case n.cfg.AuditInterval > 0 && auth != nil:
+		mux.Handle(auditPath, auth.wrap(http.HandlerFunc(n.auditHandler)))
+	case n.cfg.AuditInterval > 0:
+		klog.Warningf("Not serving %v without the authentication of the webhook", auditPath)
+	}
+	if n.cfg.EnableAPI {
+		if err := n.registerAPI(mux, auth, limiter, namespaceLimiter); err != nil {
+			return err
+		}
+	}
+
+	if n.cfg.ValidationWebhookBootstrap != "" {
+		if err := n.bootstrapWebhookCertificate(context.Background(), time.Now()); err != nil {
+			return err
+		}
+		go n.renewWebhookCertificate()
+	} else {
+		if _, err := n.loadWebhookCertificate(); err != nil {
+			return err
+		}
+		if err := n.watchWebhookCertificate(); err != nil {
+			return err
+		}
+	}
+
+	tlsConfig := &tls.Config{GetCertificate: n.getWebhookCertificate, MinVersion: tls.VersionTLS12}
+	auth.configureTLS(tlsConfig)
+
+	if n.cfg.GRPCAddress != "" {
+		if err := n.startGRPCServer(tlsConfig, auth, limiter, namespaceLimiter); err != nil {
+			return err
+		}
+	}
+
+	n.validationWebhookServer = &http.Server{
+		Addr:              n.cfg.ValidationWebhook,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         tlsConfig,
+	}
+
+	klog.Infof("Starting validation webhook on %v", n.cfg.ValidationWebhook)
+	err = n.validationWebhookServer.ListenAndServeTLS("", "")
+	if errors.Is(err, http.ErrServerClosed) {
+		// the server closes at the start of the shutdown, wait for the
+		// reviews in flight and the reports
+		<-n.shutdownDone
+		return nil
+	}
+	return err
+}
+
+// webhookDrainTimeout bounds the time the shutdown waits for the admission
+// reviews in flight
+const webhookDrainTimeout = 30 * time.Second
+
+// stopOnSignal stops the webhook when the process receives SIGTERM or SIGINT
+func (n *NGINXController) stopOnSignal() {
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, syscall.SIGTERM, os.Interrupt)
+
+	sig := <-signalCh
+	klog.Infof("Received %v, shutting down", sig)
+	n.stop()
+}
+
+// stop shuts the webhook down gracefully: the webhook reports it is not
+// ready and keeps serving for ShutdownGracePeriod seconds, so the API server
+// stops sending reviews, then drains the reviews in flight, stops the
+// background tasks, flushes the reports and waits PostShutdownGracePeriod
+// seconds before the process exits. The status of the Ingresses is updated
+// before the server stops.
+func (n *NGINXController) stop() {
+	n.stopLock.Lock()
+	if n.isShuttingDown {
+		n.stopLock.Unlock()
+		return
+	}
+	n.isShuttingDown = true
+	n.stopLock.Unlock()
+	defer close(n.shutdownDone)
+
+	if n.cfg.ShutdownGracePeriod > 0 {
+		klog.Infof("Waiting %d seconds before stopping the validation webhook", n.cfg.ShutdownGracePeriod)
+		time.Sleep(time.Duration(n.cfg.ShutdownGracePeriod) * time.Second)
+	}
+
+	if n.syncStatus != nil {
+		n.syncStatus.Shutdown()
+	}
+
+	if n.validationWebhookServer != nil {
+		// Shutdown stops accepting connections and waits for the reviews
+		// in flight
+		ctx, cancel := context.WithTimeout(context.Background(), webhookDrainTimeout)
+		defer cancel()
+		if err := n.validationWebhookServer.Shutdown(ctx); err != nil {
+			klog.Errorf("Error stopping validation webhook: %v", err)
+		}
+	}
+
+	if n.grpcServer != nil {
+		n.grpcServer.GracefulStop()
+	}
+
+	close(n.stopCh)
+
+	if n.reports != nil {
+		n.reports.publish()
+	}
+	klog.Flush()
+
+	if n.cfg.PostShutdownGracePeriod > 0 {
+		klog.Infof("Waiting %d seconds before exiting", n.cfg.PostShutdownGracePeriod)
+		time.Sleep(time.Duration(n.cfg.PostShutdownGracePeriod) * time.Second)
+	}
+	klog.Info("Validation webhook stopped")
+}
+
+// shuttingDown returns true once the shutdown started
+func (n *NGINXController) shuttingDown() bool {
+	n.stopLock.Lock()
+	defer n.stopLock.Unlock()
+	return n.isShuttingDown
+}
+
+// syncClusterState reloads the objects of the cluster into the store and
+// validates the resulting configuration
+func (n *NGINXController) syncClusterState(s *memoryStore) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterStateTimeout)
+	defer cancel()
+	if err := n.refreshClusterState(ctx, s); err != nil {
+		klog.Errorf("Error reloading cluster state: %v", err)
+		return
+	}
+	n.syncConfiguration(s)
+}
+
+// resyncClusterState requests a sync of the cluster state every ResyncPeriod
+// until the stop channel is closed
+func (n *NGINXController) resyncClusterState() {
+	ticker := time.NewTicker(n.cfg.ResyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.stopCh:
+			return
+		case <-ticker.C:
+			n.syncQueue.enqueue("resync")
+		}
+	}
+}
+
+// runWebhook serves the admission webhook using the state of the cluster
+func runWebhook(args []string, _, stderr io.Writer) int {
+	fs := flag.NewFlagSet("webhook", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	cfg := &NginxConfiguration{}
+	addConfigurationFlags(fs, cfg)
+	addWorkDirFlags(fs, cfg)
+	addSandboxFlags(fs, cfg)
+	addLeaderElectionFlags(fs, cfg)
+	fs.BoolVar(&cfg.EnableProfiling, "profiling", false,
+		"Serve pprof, with profiles of the validations and commands in flight, and the timings of the validation pipeline on 127.0.0.1:10245.")
+	addHealthCheckFlags(fs, cfg)
+	fs.StringVar(&cfg.MetricsAddress, "metrics-address", defaultMetricsAddress,
+		"Address serving the metrics on "+metricsPath+", which include the command line. Empty disables them.")
+	fs.StringVar(&cfg.KubeConfigFile, "kubeconfig", "", "Path to the kubeconfig file. Uses the in-cluster configuration when empty.")
+	fs.StringVar(&cfg.ConfigFile, "config", "",
+		"YAML or JSON file of rule severities, policies and trust bundle, reloaded on SIGHUP or when it changes. Its settings take precedence over the flags.")
+	fs.StringVar(&cfg.APIServerHost, "apiserver-host", "", "Address of the Kubernetes API server.")
+	fs.StringVar(&cfg.ValidationWebhook, "validating-webhook", ":8443", "Address the admission webhook listens on.")
+	fs.StringVar(&cfg.ValidationWebhookCertPath, "validating-webhook-certificate", "", "File containing the webhook certificate.")
+	fs.StringVar(&cfg.ValidationWebhookKeyPath, "validating-webhook-key", "", "File containing the webhook private key.")
+	fs.BoolVar(&cfg.EnableAPI, "enable-api", false,
+		"Serve the validation API ("+apiValidatePath+" and "+apiReportPath+") and its OpenAPI specification ("+apiOpenAPIPath+") on the address of the webhook, with the same authentication, which is required.")
+	fs.StringVar(&cfg.GRPCAddress, "grpc-address", "",
+		"Address of the gRPC validation service of pkg/api/validator/v1/validator.proto, served with the certificate, the authentication, which is required, and the validation limits of the webhook. Disabled when empty.")
+	fs.StringVar(&cfg.WebhookClientCA, "webhook-client-ca", "",
+		"PEM file of the CAs of the client certificates allowed to submit admission reviews, such as the one of the API server.")
+	fs.Var((*stringSliceFlag)(&cfg.WebhookClientCNs), "webhook-client-cn",
+		"Common name of the client certificates allowed to submit admission reviews, any when not set. Can be repeated.")
+	fs.StringVar(&cfg.WebhookTokenFile, "webhook-token-file", "",
+		"File of the bearer tokens allowed to call the webhook, one per line. A token can be followed by the comma separated namespaces its caller is restricted to in the validation API, all namespaces otherwise.")
+	fs.StringVar(&cfg.ValidationWebhookBootstrap, "validating-webhook-bootstrap", "",
+		"Name of the ValidatingWebhookConfiguration of the webhook. When set, the webhook generates its certificate, renewed before it expires, and adds its CA to the caBundle of the configuration instead of loading --validating-webhook-certificate.")
+	fs.StringVar(&cfg.ValidationWebhookService, "validating-webhook-service", "ingress-nginx/nginx-config-validator",
+		"Namespace/name of the Service of the webhook, the names of the certificate generated with --validating-webhook-bootstrap.")
+	fs.StringVar(&cfg.ValidationWebhookCASecret, "validating-webhook-ca-secret", "ingress-nginx/nginx-config-validator-ca",
+		"Namespace/name of the Secret of the CA shared by the replicas to sign the certificates generated with --validating-webhook-bootstrap, created when it does not exist.")
+	fs.DurationVar(&cfg.ResyncPeriod, "sync-period", 10*time.Minute, "Interval between reloads of the cluster state.")
+	fs.IntVar(&cfg.MaxConcurrentValidations, "max-concurrent-validations", defaultMaxConcurrentValidations,
+		"Maximum number of validations running in parallel. 0 disables the limit.")
+	fs.IntVar(&cfg.ValidationQueueDepth, "validation-queue-depth", defaultValidationQueueDepth,
+		"Maximum number of admission requests waiting for a validation slot. Requests over the limit are shed.")
+	fs.DurationVar(&cfg.ValidationQueueTimeout, "validation-queue-timeout", defaultValidationQueueTimeout,
+		"Maximum time an admission request waits for a validation slot before being shed.")
+	fs.BoolVar(&cfg.AllowOnOverload, "allow-on-overload", false,
+		"Admit the Ingresses shed because of the validation limits, with a warning, instead of rejecting them with a retriable error.")
+	fs.Float64Var(&cfg.NamespaceRateLimit, "namespace-rate-limit", 0,
+		"Admission requests per second validated for each namespace, the requests over the rate are rejected with a retriable error. 0 disables the limit.")
+	fs.IntVar(&cfg.NamespaceRateLimitBurst, "namespace-rate-limit-burst", defaultNamespaceRateLimitBurst,
+		"Admission requests of a namespace validated at once above --namespace-rate-limit.")
+	fs.Var((*stringSliceFlag)(&cfg.FreezeWindows), "freeze-window",
+		"Period during which Ingresses must not change, as <RFC3339>/<RFC3339> or weekly as <day> <HH:MM>/<day> <HH:MM> (e.g. \"Fri 16:00/Mon 08:00\"). Can be repeated.")
+	fs.StringVar(&cfg.FreezeTimezone, "freeze-timezone", "UTC", "Timezone of the weekly freeze windows.")
+	fs.StringVar(&cfg.FreezeMode, "freeze-mode", freezeModeReject,
+		"Action on changes during a freeze window: reject, or warn to admit them with a warning.")
+	fs.BoolVar(&cfg.UpdateStatus, "update-status", false,
+		"Set the addresses of --publish-service, --publish-status-address or of the nodes running the controller pods in the status of the Ingresses handled by the controller.")
+	fs.BoolVar(&cfg.UpdateStatusOnShutdown, "update-status-on-shutdown", true,
+		"Remove the addresses from the status of the Ingresses on shutdown, used with --update-status.")
+	fs.BoolVar(&cfg.UseNodeInternalIP, "report-node-internal-ip-address", false,
+		"Use the internal addresses of the nodes running the controller pods in the status when neither --publish-service nor --publish-status-address is set.")
+	fs.StringVar(&cfg.NotifySinksFile, "notify-file", "",
+		"File, such as a mounted Secret, of the endpoints notified when the Ingresses of the cluster fail validation, one per line as slack=<url>, teams=<url> or http=<url>. Defaults to the endpoints of the "+notifySinksEnv+" environment variable.")
+	fs.StringVar(&cfg.NotifyReportURL, "notify-report-url", "",
+		"Link to the validation report included in the notifications.")
+	fs.BoolVar(&cfg.DisableSyncEvents, "disable-sync-events", false,
+		"Do not create Events on the Ingresses for the validation findings.")
+	fs.StringVar(&cfg.ReportSink, "report-sink", reportSinkNone,
+		"Publish the result of the validation of every Ingress to a ConfigMap (configmap) or to IngressValidationReport objects (crd).")
+	fs.StringVar(&cfg.ReportConfigMap, "report-configmap", "ingress-nginx/nginx-config-validator-reports",
+		"Namespace/name of the ConfigMap used with --report-sink=configmap.")
+	fs.DurationVar(&cfg.ReportInterval, "report-interval", defaultReportInterval,
+		"Interval between publications of the validation reports.")
+	fs.DurationVar(&cfg.AuditInterval, "audit-interval", 0,
+		"Interval between audits of the cluster state, reporting the new findings, the changed Ingresses and the expiring certificates on "+auditPath+" of the webhook when its authentication is configured. 0 disables the audits.")
+	fs.StringVar(&cfg.AuditDirectory, "audit-dir", "",
+		"Directory the leader writes the timestamped audit reports to.")
+	fs.IntVar(&cfg.AuditMaxReports, "audit-max-reports", defaultAuditMaxReports,
+		"Number of reports kept in --audit-dir, the oldest being removed. 0 keeps them all.")
+	fs.DurationVar(&cfg.AuditCertificatePeriod, "audit-certificate-period", defaultAuditCertificatePeriod,
+		"Period in which the certificates expiring are listed by the audits.")
+	fs.StringVar(&cfg.RunningConfig, "running-config", "",
+		"nginx.conf served by the ingress controller, a file shared with a controller pod (e.g. "+nginxConfPath+" in a shared volume) or the http(s) URL of a status endpoint, compared by the audits with the configuration of the Ingresses. The differences found by two audits in a row are notified.")
+	fs.IntVar(&cfg.ShutdownGracePeriod, "shutdown-grace-period", 0,
+		"Seconds to keep serving admission reviews after SIGTERM while reporting not ready, so the API server stops sending them.")
+	fs.IntVar(&cfg.PostShutdownGracePeriod, "post-shutdown-grace-period", 0,
+		"Seconds to wait after the webhook stopped before the process exits.")
+	fs.DurationVar(&cfg.SyncDebounce, "sync-debounce", defaultSyncDebounce,
+		"Time to wait for more changes before validating the cluster state again, bursts of changes are validated once.")
+	fs.DurationVar(&cfg.StateRefreshInterval, "validation-generation-interval", defaultStateRefreshInterval,
+		"Minimum time between two reloads of the cluster state requested by the validation-generation annotation.")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitInternal
+	}
+
+	client, err := newKubernetesClient(cfg.APIServerHost, cfg.KubeConfigFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "error creating Kubernetes client: %v\n", err)
+		return exitInternal
+	}
+	cfg.Client = client
+
+	s := newMemoryStore(cfg.ConfigMapName)
+	if err := loadClusterState(context.Background(), client, s); err != nil {
+		fmt.Fprintf(stderr, "error reading cluster state: %v\n", err)
+		return exitInternal
+	}
+
+	n := newOfflineController(cfg, s)
+	n.recorder = newEventRecorder(client, cfg.DisableSyncEvents)
+	n.flagConfig = flagValidatorConfig(cfg)
+	if n.auth, err = newWebhookAuth(cfg); err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitInternal
+	}
+	if cfg.ConfigFile != "" {
+		if err := n.reloadConfig(); err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return exitInternal
+		}
+	}
+	if err := n.watchConfig(); err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitInternal
+	}
+	if err := n.runLeaderElection(); err != nil {
+		fmt.Fprintf(stderr, "error starting leader election: %v\n", err)
+		return exitInternal
+	}
+
+	var dynamicClient dynamic.Interface
+	if cfg.ReportSink == reportSinkCRD {
+		if dynamicClient, err = newDynamicClient(cfg.APIServerHost, cfg.KubeConfigFile); err != nil {
+			fmt.Fprintf(stderr, "error creating Kubernetes client: %v\n", err)
+			return exitInternal
+		}
+	}
+	if n.reports, err = n.newReportPublisher(dynamicClient); err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitInternal
+	}
+	if n.reports != nil {
+		go n.reports.Run(cfg.ReportInterval, n.stopCh)
+	}
+	if n.notifications, err = newNotifications(cfg); err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitInternal
+	}
+
+	// the webhook is not ready until the existing Ingresses are valid, so a
+	// version disagreeing with the running configuration is never rolled out
+	n.syncConfiguration(s)
+	n.syncQueue = newSyncQueue(cfg.SyncDebounce, func() { n.syncClusterState(s) })
+	go n.syncQueue.Run(n.stopCh)
+	go n.resyncClusterState()
+	go n.collectWorkDirGarbage()
+	if cfg.AuditInterval > 0 {
+		go n.runAudits()
+	}
+
+	if cfg.UpdateStatus {
+		n.syncStatus = n.newStatusSyncer()
+		go n.syncStatus.Run(n.stopCh)
+	}
+	go n.stopOnSignal()
+	n.startProfiler()
+	n.startMetricsServer()
+	if n.cfg.HealthCheckPort > 0 {
+		go n.startHealthServer()
+	}
+
+	if err := n.startValidationWebhook(); err != nil {
+		fmt.Fprintf(stderr, "error serving validation webhook: %v\n", err)
+		return exitInternal
+	}
+	return exitOK
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+// admissionUnauthorizedTotal counts the admission requests rejected because
+// the caller is not authenticated or not allowed
+var admissionUnauthorizedTotal = expvar.NewInt("admission_unauthorized_total")
+
+// webhookAuth authenticates the callers of the admission endpoint with a
+// client certificate, issued by ClientCA and with an allowed common name, or
+// with a bearer token. The client CA and the tokens are read again by reload.
+type webhookAuth struct {
+	cfg *NginxConfiguration
+
+	lock      sync.RWMutex
+	clientCAs *x509.CertPool
+	// allowedCNs are the common names of the client certificates allowed,
+	// any common name when empty
+	allowedCNs map[string]bool
+	tokens     []webhookToken
+}
+
+// webhookToken is a bearer token of the token file and the namespaces its
+// caller can validate
+type webhookToken struct {
+	value  []byte
+	caller *webhookCaller
+}
+
+// webhookCaller is an authenticated caller of the webhook
+type webhookCaller struct {
+	// namespaces are the namespaces whose Ingresses the caller can validate
+	// and read the findings of, all of them when nil
+	namespaces map[string]bool
+}
+
+// allows returns true if the caller can access the namespace. Callers are
+// only allowed all the namespaces when the authentication is disabled, or
+// with a client certificate or a token without namespaces.
+func (c *webhookCaller) allows(namespace string) bool {
+	return c == nil || c.namespaces == nil || c.namespaces[namespace]
+}
+
+// allowsAll returns true if the caller can access every namespace, and so the
+// findings of the whole configuration
+func (c *webhookCaller) allowsAll() bool {
+	return c == nil || c.namespaces == nil
+}
+
+type webhookCallerKey struct{}
+
+// callerFromContext returns the caller of the request, nil when the
+// authentication is disabled
+func callerFromContext(ctx context.Context) *webhookCaller {
+	caller, _ := ctx.Value(webhookCallerKey{}).(*webhookCaller)
+	return caller
+}
+
+// newWebhookAuth returns the authentication of the admission endpoint, nil
+// when neither a client CA nor a token file is configured
+func newWebhookAuth(cfg *NginxConfiguration) (*webhookAuth, error) {
+	if cfg.WebhookClientCA == "" && cfg.WebhookTokenFile == "" {
+		if len(cfg.WebhookClientCNs) > 0 {
+			return nil, errors.New("--webhook-client-cn requires --webhook-client-ca")
+		}
+		return nil, nil
+	}
+
+	auth := &webhookAuth{cfg: cfg, allowedCNs: map[string]bool{}}
+	for _, cn := range cfg.WebhookClientCNs {
+		auth.allowedCNs[cn] = true
+	}
+	if err := auth.reload(); err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
+
+// reload reads the client CA and the token file again. The previous ones are
+// kept when a file is invalid.
+func (a *webhookAuth) reload() error {
+	if a == nil {
+		return nil
+	}
+
+	var clientCAs *x509.CertPool
+	if a.cfg.WebhookClientCA != "" {
+		data, err := os.ReadFile(a.cfg.WebhookClientCA)
+		if err != nil {
+			return fmt.Errorf("error reading webhook client CA: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificate found in webhook client CA %v", a.cfg.WebhookClientCA)
+		}
+	}
+
+	var tokens []webhookToken
+	if a.cfg.WebhookTokenFile != "" {
+		data, err := os.ReadFile(a.cfg.WebhookTokenFile)
+		if err != nil {
+			return fmt.Errorf("error reading webhook token file: %w", err)
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			// a token, followed by the comma separated namespaces of its
+			// caller
+			token := webhookToken{value: []byte(fields[0]), caller: &webhookCaller{}}
+			if len(fields) > 1 {
+				token.caller.namespaces = map[string]bool{}
+				for _, namespace := range strings.Split(fields[1], ",") {
+					if namespace != "" {
+						token.caller.namespaces[namespace] = true
+					}
+				}
+			}
+			tokens = append(tokens, token)
+		}
+		if len(tokens) == 0 {
+			return fmt.Errorf("no token found in webhook token file %v", a.cfg.WebhookTokenFile)
+		}
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.clientCAs = clientCAs
+	a.tokens = tokens
+	return nil
+}
+
+// files returns the files of the authentication, watched for reloads
+func (a *webhookAuth) files() []string {
+	if a == nil {
+		return nil
+	}
+	return []string{a.cfg.WebhookClientCA, a.cfg.WebhookTokenFile}
+}
+
+// configureTLS requests the client certificates in the TLS handshake. The
+// certificates are required when they are the only authentication method.
+// The CAs of the last reload are used for every new connection.
+func (a *webhookAuth) configureTLS(config *tls.Config) {
+	if a == nil || a.cfg.WebhookClientCA == "" {
+		return
+	}
+
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	if a.cfg.WebhookTokenFile != "" {
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	base := config.Clone()
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := base.Clone()
+		a.lock.RLock()
+		c.ClientCAs = a.clientCAs
+		a.lock.RUnlock()
+		return c, nil
+	}
+}
+
+// authenticate returns the caller, from its TLS connection and Authorization
+// header, or the HTTP status and the reason of its rejection
+func (a *webhookAuth) authenticate(state *tls.ConnectionState, authorization string) (*webhookCaller, int, string) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	if state != nil && len(state.VerifiedChains) > 0 {
+		cn := state.VerifiedChains[0][0].Subject.CommonName
+		if len(a.allowedCNs) == 0 || a.allowedCNs[cn] {
+			return &webhookCaller{}, 0, ""
+		}
+		if len(a.tokens) == 0 {
+			return nil, http.StatusForbidden, fmt.Sprintf("client certificate %q is not allowed", cn)
+		}
+	}
+
+	if token, ok := strings.CutPrefix(authorization, "Bearer "); ok {
+		for _, allowed := range a.tokens {
+			if subtle.ConstantTimeCompare([]byte(token), allowed.value) == 1 {
+				return allowed.caller, 0, ""
+			}
+		}
+		return nil, http.StatusForbidden, "invalid bearer token"
+	}
+	return nil, http.StatusUnauthorized, "missing client certificate or bearer token"
+}
+
+// wrap rejects the requests of the callers not allowed before h. Every
+// endpoint of the webhook but the probes of the kubelet must be wrapped.
+func (a *webhookAuth) wrap(h http.Handler) http.Handler {
+	if a == nil {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller, status, reason := a.authenticate(r.TLS, r.Header.Get("Authorization"))
+		if status != 0 {
+			admissionUnauthorizedTotal.Add(1)
+			klog.Warningf("Rejecting request for %v from %v: %v", r.URL.Path, r.RemoteAddr, reason)
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), webhookCallerKey{}, caller)))
+	})
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewWebhookAuth(t *testing.T) {
+	dir := t.TempDir()
+	_, _, caPEM, _ := testCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "apiserver ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}, nil, nil)
+	ca := writeTestFile(t, dir, "ca.pem", string(caPEM))
+	empty := writeTestFile(t, dir, "empty", "\n# no token\n")
+	tokens := writeTestFile(t, dir, "tokens", "# callers\nfirst\n\n  second  \nthird team-b,team-a\n")
+
+	testCases := map[string]struct {
+		cfg       NginxConfiguration
+		expectErr string
+		tokens    []string
+		cas       bool
+	}{
+		"disabled": {},
+		"common names without CA": {
+			cfg:       NginxConfiguration{WebhookClientCNs: []string{"kube-apiserver"}},
+			expectErr: "--webhook-client-cn requires --webhook-client-ca",
+		},
+		"client CA": {
+			cfg: NginxConfiguration{WebhookClientCA: ca},
+			cas: true,
+		},
+		"missing client CA": {
+			cfg:       NginxConfiguration{WebhookClientCA: dir + "/missing.pem"},
+			expectErr: "error reading webhook client CA",
+		},
+		"client CA without certificate": {
+			cfg:       NginxConfiguration{WebhookClientCA: empty},
+			expectErr: "no certificate found in webhook client CA",
+		},
+		"tokens": {
+			cfg:    NginxConfiguration{WebhookTokenFile: tokens},
+			tokens: []string{"first", "second", "third team-a,team-b"},
+		},
+		"token file without token": {
+			cfg:       NginxConfiguration{WebhookTokenFile: empty},
+			expectErr: "no token found in webhook token file",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			auth, err := newWebhookAuth(&tc.cfg)
+			if tc.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectErr) {
+					t.Errorf("expected an error containing %q, got %v", tc.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.cfg.WebhookClientCA == "" && tc.cfg.WebhookTokenFile == "" {
+				if auth != nil {
+					t.Errorf("expected no authentication, got %+v", auth)
+				}
+				return
+			}
+			if (auth.clientCAs != nil) != tc.cas {
+				t.Errorf("expected client CAs %v, got %v", tc.cas, auth.clientCAs)
+			}
+			got := []string{}
+			for _, token := range auth.tokens {
+				// the token, followed by the namespaces of its caller
+				entry := string(token.value)
+				if token.caller.namespaces != nil {
+					entry += " " + strings.Join(sortedKeys(token.caller.namespaces), ",")
+				}
+				got = append(got, entry)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.tokens, ",") {
+				t.Errorf("expected the tokens %v, got %v", tc.tokens, got)
+			}
+		})
+	}
+}
+
+func TestWebhookAuthConfigureTLS(t *testing.T) {
+	var none *webhookAuth
+	config := &tls.Config{}
+	none.configureTLS(config)
+	if config.ClientAuth != tls.NoClientCert {
+		t.Errorf("expected no client certificate without authentication, got %v", config.ClientAuth)
+	}
+
+	pool := x509.NewCertPool()
+	(&webhookAuth{cfg: &NginxConfiguration{WebhookClientCA: "ca.crt"}, clientCAs: pool}).configureTLS(config)
+	if config.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Errorf("expected a client certificate to be required, got %v", config.ClientAuth)
+	}
+	if c, err := config.GetConfigForClient(nil); err != nil || c.ClientCAs != pool {
+		t.Errorf("expected the client CAs of the last reload, got %v", err)
+	}
+
+	config = &tls.Config{}
+	cfg := &NginxConfiguration{WebhookClientCA: "ca.crt", WebhookTokenFile: "tokens"}
+	(&webhookAuth{cfg: cfg, clientCAs: pool, tokens: []webhookToken{{value: []byte("token")}}}).configureTLS(config)
+	if config.ClientAuth != tls.VerifyClientCertIfGiven {
+		t.Errorf("expected an optional client certificate with tokens, got %v", config.ClientAuth)
+	}
+}
+
+func TestWebhookAuthWrap(t *testing.T) {
+	client := func(cn string) *tls.ConnectionState {
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: cn}}}}}
+	}
+	certOnly := &webhookAuth{allowedCNs: map[string]bool{"kube-apiserver": true}}
+	anyCN := &webhookAuth{allowedCNs: map[string]bool{}}
+	withTokens := &webhookAuth{allowedCNs: map[string]bool{"kube-apiserver": true}, tokens: []webhookToken{{value: []byte("first"), caller: &webhookCaller{}}, {value: []byte("second"), caller: &webhookCaller{}}}}
+
+	testCases := map[string]struct {
+		auth     *webhookAuth
+		tls      *tls.ConnectionState
+		header   string
+		expected int
+	}{
+		"allowed common name":         {auth: certOnly, tls: client("kube-apiserver"), expected: http.StatusOK},
+		"other common name":           {auth: certOnly, tls: client("intruder"), expected: http.StatusForbidden},
+		"any common name":             {auth: anyCN, tls: client("intruder"), expected: http.StatusOK},
+		"nothing":                     {auth: certOnly, expected: http.StatusUnauthorized},
+		"token":                       {auth: withTokens, header: "Bearer second", expected: http.StatusOK},
+		"invalid token":               {auth: withTokens, header: "Bearer third", expected: http.StatusForbidden},
+		"basic credentials":           {auth: withTokens, header: "Basic Zmlyc3Q6", expected: http.StatusUnauthorized},
+		"other common name, token":    {auth: withTokens, tls: client("intruder"), header: "Bearer first", expected: http.StatusOK},
+		"other common name, no token": {auth: withTokens, tls: client("intruder"), expected: http.StatusUnauthorized},
+		"no authentication":           {expected: http.StatusOK},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			h := tc.auth.wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, admissionPath, nil)
+			req.TLS = tc.tls
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			unauthorized := admissionUnauthorizedTotal.Value()
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tc.expected {
+				t.Errorf("expected status %v, got %v", tc.expected, w.Code)
+			}
+			rejected := int64(0)
+			if tc.expected != http.StatusOK {
+				rejected = 1
+			}
+			if got := admissionUnauthorizedTotal.Value() - unauthorized; got != rejected {
+				t.Errorf("expected %v rejected requests counted, got %v", rejected, got)
+			}
+		})
+	}
+}
+
+func TestWebhookAuthReload(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(tokenFile, []byte("# callers\nfirst\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	auth, err := newWebhookAuth(&NginxConfiguration{WebhookTokenFile: tokenFile})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler := auth.wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	status := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, preValidationPath, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := status(""); code != http.StatusUnauthorized {
+		t.Errorf("expected a request without token to be unauthorized, got %d", code)
+	}
+	if code := status("first"); code != http.StatusOK {
+		t.Errorf("expected the token to be allowed, got %d", code)
+	}
+
+	if err := os.WriteFile(tokenFile, []byte("second\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if code := status("first"); code != http.StatusForbidden {
+		t.Errorf("expected the removed token to be forbidden, got %d", code)
+	}
+	if code := status("second"); code != http.StatusOK {
+		t.Errorf("expected the new token to be allowed, got %d", code)
+	}
+
+	if err := os.WriteFile(tokenFile, []byte("# empty\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.reload(); err == nil {
+		t.Errorf("expected an error reloading a token file without token")
+	}
+	if code := status("second"); code != http.StatusOK {
+		t.Errorf("expected the previous tokens to be kept, got %d", code)
+	}
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"expvar"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// webhookBootstrapValidity is the validity of the certificates generated
+	// by the webhook
+	webhookBootstrapValidity = 365 * 24 * time.Hour
+	// webhookBootstrapRenewBefore is the time before the expiry of a
+	// generated certificate when it is replaced
+	webhookBootstrapRenewBefore = 30 * 24 * time.Hour
+	// webhookBootstrapCheckInterval is the interval between checks of the
+	// expiry of the generated certificate
+	webhookBootstrapCheckInterval = time.Hour
+)
+
+var (
+	webhookCertificateReloadsTotal      = expvar.NewInt("webhook_certificate_reloads_total")
+	webhookCertificateReloadErrorsTotal = expvar.NewInt("webhook_certificate_reload_errors_total")
+)
+
+// getWebhookCertificate returns the certificate currently served by the
+// webhook, so renewed certificates are used by the next TLS handshakes
+func (n *NGINXController) getWebhookCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert := n.webhookCertificate.Load()
+	if cert == nil {
+		return nil, errors.New("webhook certificate not loaded")
+	}
+	return cert, nil
+}
+
+// watchWebhookCertificate reloads the certificate of the webhook when its
+// files change, until the stop channel is closed. The directories of the
+// files are watched, as the files of mounted Secrets are replaced by symlink
+// swaps. The previous certificate is kept when the new files are invalid, a
+// renewal usually writing the certificate and the key one after the other.
+func (n *NGINXController) watchWebhookCertificate() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating file watcher: %w", err)
+	}
+	watched := map[string]bool{}
+	for _, path := range []string{n.cfg.ValidationWebhookCertPath, n.cfg.ValidationWebhookKeyPath} {
+		if watched[filepath.Dir(path)] {
+			continue
+		}
+		if err := watcher.Add(filepath.Dir(path)); err != nil {
+			watcher.Close()
+			return fmt.Errorf("error watching %v: %w", path, err)
+		}
+		watched[filepath.Dir(path)] = true
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var timer <-chan time.Time
+		for {
+			select {
+			case <-n.stopCh:
+				return
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				timer = time.After(watchDebounce)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				klog.Warningf("Error watching webhook certificate: %v", err)
+			case <-timer:
+				timer = nil
+				cert, err := n.loadWebhookCertificate()
+				if err != nil {
+					webhookCertificateReloadErrorsTotal.Add(1)
+					klog.Errorf("Error reloading webhook certificate, keeping the previous one: %v", err)
+					continue
+				}
+				webhookCertificateReloadsTotal.Add(1)
+				klog.Infof("Loaded webhook certificate valid until %v", cert.Leaf.NotAfter.Format(time.RFC3339))
+			}
+		}
+	}()
+	return nil
+}
+
+// webhookServiceHosts returns the names of the Service of the webhook,
+// namespace/name, used by the API server to call it
+func webhookServiceHosts(service string) ([]string, error) {
+	namespace, name, ok := strings.Cut(service, "/")
+	if !ok || namespace == "" || name == "" {
+		return nil, fmt.Errorf("invalid webhook service %q, expected namespace/name", service)
+	}
+	return []string{
+		name,
+		name + "." + namespace,
+		name + "." + namespace + ".svc",
+		name + "." + namespace + ".svc.cluster.local",
+	}, nil
+}
+
+// webhookCA is the CA of the generated certificates, shared by the replicas
+// of the webhook through the Secret ValidationWebhookCASecret so the caBundle
+// does not get a CA per pod
+type webhookCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+// newWebhookCA generates a CA valid for webhookBootstrapValidity
+func newWebhookCA(now time.Time) (*webhookCA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "nginx-config-validator webhook CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(webhookBootstrapValidity),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhookCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}, nil
+}
+
+// parseWebhookCA reads the CA from the data of its Secret
+func parseWebhookCA(data map[string][]byte) (*webhookCA, error) {
+	certBlock, _ := pem.Decode(data[apiv1.TLSCertKey])
+	keyBlock, _ := pem.Decode(data[apiv1.TLSPrivateKeyKey])
+	if certBlock == nil || keyBlock == nil {
+		return nil, errors.New("no PEM encoded CA certificate and key")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return &webhookCA{cert: cert, key: key, pem: pem.EncodeToMemory(certBlock)}, nil
+}
+
+// secretData returns the data of the Secret of the CA
+func (ca *webhookCA) secretData() (map[string][]byte, error) {
+	key, err := x509.MarshalECPrivateKey(ca.key)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		apiv1.TLSCertKey:       ca.pem,
+		apiv1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}),
+	}, nil
+}
+
+// issue generates a certificate signed by the CA for the hosts, expiring
+// with the CA at the latest
+func (ca *webhookCA) issue(hosts []string, now time.Time) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	notAfter := now.Add(webhookBootstrapValidity)
+	if notAfter.After(ca.cert.NotAfter) {
+		notAfter = ca.cert.NotAfter
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: hosts[len(hosts)-2]},
+		DNSNames:     hosts,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// sharedWebhookCA returns the CA of the Secret ValidationWebhookCASecret. The
+// CA is created when the Secret does not exist, and replaced when it expires
+// within webhookBootstrapRenewBefore. Replicas racing to create or replace
+// it get the CA of the first one.
+func (n *NGINXController) sharedWebhookCA(ctx context.Context, now time.Time) (*webhookCA, error) {
+	namespace, name, ok := strings.Cut(n.cfg.ValidationWebhookCASecret, "/")
+	if !ok || namespace == "" || name == "" {
+		return nil, fmt.Errorf("invalid webhook CA secret %q, expected namespace/name", n.cfg.ValidationWebhookCASecret)
+	}
+	secrets := n.cfg.Client.CoreV1().Secrets(namespace)
+
+	var ca *webhookCA
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			if ca, err = newWebhookCA(now); err != nil {
+				return err
+			}
+			data, err := ca.secretData()
+			if err != nil {
+				return err
+			}
+			_, err = secrets.Create(ctx, &apiv1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+				Type:       apiv1.SecretTypeTLS,
+				Data:       data,
+			}, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// another replica created the CA first, use it
+				return apierrors.NewConflict(apiv1.Resource("secrets"), name, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		current, err := parseWebhookCA(secret.Data)
+		if err == nil && now.Before(current.cert.NotAfter.Add(-webhookBootstrapRenewBefore)) {
+			ca = current
+			return nil
+		}
+		if err != nil {
+			klog.Warningf("Replacing the invalid webhook CA of Secret %v: %v", n.cfg.ValidationWebhookCASecret, err)
+		}
+		if ca, err = newWebhookCA(now); err != nil {
+			return err
+		}
+		if secret.Data, err = ca.secretData(); err != nil {
+			return err
+		}
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting the webhook CA of Secret %v: %w", n.cfg.ValidationWebhookCASecret, err)
+	}
+	return ca, nil
+}
+
+// rotateCABundle returns the bundle with the CA, keeping the most recent of
+// the previous CAs still valid so the API server trusts the replicas still
+// serving a certificate it signed while the CA is rotated. The other CAs,
+// such as the ones generated per pod by previous versions, are dropped.
+func rotateCABundle(bundle, ca []byte, now time.Time) []byte {
+	var previous *pem.Block
+	var previousNotBefore time.Time
+	for block, rest := pem.Decode(bundle); block != nil; block, rest = pem.Decode(rest) {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil || now.After(cert.NotAfter) || bytes.Contains(ca, pem.EncodeToMemory(block)) {
+			continue
+		}
+		if previous == nil || cert.NotBefore.After(previousNotBefore) {
+			previous, previousNotBefore = block, cert.NotBefore
+		}
+	}
+
+	rotated := append([]byte{}, ca...)
+	if previous != nil {
+		rotated = append(rotated, pem.EncodeToMemory(previous)...)
+	}
+	return rotated
+}
+
+// bootstrapWebhookCertificate generates the certificate of the webhook with
+// the CA shared by the replicas, and adds the CA to the caBundle of the
+// webhooks of the ValidatingWebhookConfiguration calling the Service of the
+// webhook. The certificate is served once the API server trusts it.
+func (n *NGINXController) bootstrapWebhookCertificate(ctx context.Context, now time.Time) error {
+	hosts, err := webhookServiceHosts(n.cfg.ValidationWebhookService)
+	if err != nil {
+		return err
+	}
+	ca, err := n.sharedWebhookCA(ctx, now)
+	if err != nil {
+		return err
+	}
+	cert, err := ca.issue(hosts, now)
+	if err != nil {
+		return fmt.Errorf("error generating webhook certificate: %w", err)
+	}
+
+	// the replicas of the webhook update the caBundle at the same time
+	webhooks := n.cfg.Client.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	namespace, name, _ := strings.Cut(n.cfg.ValidationWebhookService, "/")
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		vwc, err := webhooks.Get(ctx, n.cfg.ValidationWebhookBootstrap, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		patched := 0
+		for i := range vwc.Webhooks {
+			svc := vwc.Webhooks[i].ClientConfig.Service
+			if svc == nil || svc.Namespace != namespace || svc.Name != name {
+				continue
+			}
+			vwc.Webhooks[i].ClientConfig.CABundle = rotateCABundle(vwc.Webhooks[i].ClientConfig.CABundle, ca.pem, now)
+			patched++
+		}
+		if patched == 0 {
+			return fmt.Errorf("no webhook calls Service %v", n.cfg.ValidationWebhookService)
+		}
+		_, err = webhooks.Update(ctx, vwc, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error adding the webhook CA to ValidatingWebhookConfiguration %v: %w", n.cfg.ValidationWebhookBootstrap, err)
+	}
+
+	n.webhookCertificate.Store(cert)
+	klog.Infof("Generated webhook certificate valid until %v, CA added to ValidatingWebhookConfiguration %v",
+		cert.Leaf.NotAfter.Format(time.RFC3339), n.cfg.ValidationWebhookBootstrap)
+	return nil
+}
+
+// renewWebhookCertificate replaces the generated certificate before it
+// expires, until the stop channel is closed
+func (n *NGINXController) renewWebhookCertificate() {
+	ticker := time.NewTicker(webhookBootstrapCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.stopCh:
+			return
+		case now := <-ticker.C:
+			cert := n.webhookCertificate.Load()
+			if cert != nil && now.Before(cert.Leaf.NotAfter.Add(-webhookBootstrapRenewBefore)) {
+				continue
+			}
+			if err := n.bootstrapWebhookCertificate(context.Background(), now); err != nil {
+				webhookCertificateReloadErrorsTotal.Add(1)
+				klog.Errorf("Error renewing webhook certificate: %v", err)
+				continue
+			}
+			webhookCertificateReloadsTotal.Add(1)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWebhookServiceHosts(t *testing.T) {
+	hosts, err := webhookServiceHosts("ingress-nginx/validator")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		"validator",
+		"validator.ingress-nginx",
+		"validator.ingress-nginx.svc",
+		"validator.ingress-nginx.svc.cluster.local",
+	}
+	if !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("expected %v, got %v", expected, hosts)
+	}
+
+	for _, service := range []string{"validator", "/validator", "ingress-nginx/"} {
+		if _, err := webhookServiceHosts(service); err == nil || !strings.Contains(err.Error(), "expected namespace/name") {
+			t.Errorf("expected an invalid service error for %q, got %v", service, err)
+		}
+	}
+}
+
+func TestNewWebhookCertificate(t *testing.T) {
+	now := time.Now()
+	hosts, _ := webhookServiceHosts("ingress-nginx/validator")
+	generated, err := newWebhookCA(now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the CA is read back from its Secret by the other replicas
+	data, err := generated.secretData()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ca, err := parseWebhookCA(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cert, err := ca.issue(hosts, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca.pem) {
+		t.Fatalf("expected a PEM encoded CA, got %q", ca.pem)
+	}
+	_, err = cert.Leaf.Verify(x509.VerifyOptions{
+		DNSName:     "validator.ingress-nginx.svc",
+		Roots:       roots,
+		CurrentTime: now,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	if err != nil {
+		t.Errorf("expected the certificate to be trusted for the Service, got %v", err)
+	}
+	if cert.Leaf.Subject.CommonName != "validator.ingress-nginx.svc" {
+		t.Errorf("unexpected common name %q", cert.Leaf.Subject.CommonName)
+	}
+	if got := cert.Leaf.NotAfter.Sub(now).Round(time.Hour); got != webhookBootstrapValidity {
+		t.Errorf("expected a validity of %v, got %v", webhookBootstrapValidity, got)
+	}
+}
+
+func TestRotateCABundle(t *testing.T) {
+	now := time.Now()
+	ca := func(cn string, notAfter time.Time) []byte {
+		_, _, caPEM, _ := testCertificate(t, &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: cn},
+			NotBefore:             now.Add(-48 * time.Hour),
+			NotAfter:              notAfter,
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+		}, nil, nil)
+		return caPEM
+	}
+	current := ca("current", now.Add(time.Hour))
+	expired := ca("expired", now.Add(-time.Hour))
+	next := ca("next", now.Add(24*time.Hour))
+
+	bundle := append(append(append([]byte{}, current...), expired...), []byte("not a certificate")...)
+	rotated := rotateCABundle(bundle, next, now)
+
+	names := []string{}
+	for block, rest := pem.Decode(rotated); block != nil; block, rest = pem.Decode(rest) {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		names = append(names, cert.Subject.CommonName)
+	}
+	// the new CA first, the expired one dropped
+	if strings.Join(names, ",") != "next,current" {
+		t.Errorf("expected the CAs next and current, got %v", names)
+	}
+
+	// a CA already in the bundle is not repeated
+	if again := rotateCABundle(rotated, next, now); !bytes.Equal(again, rotated) {
+		t.Errorf("expected the bundle unchanged, got %q", again)
+	}
+}
+
+func TestBootstrapWebhookCertificate(t *testing.T) {
+	service := func(namespace, name string) admissionregistrationv1.WebhookClientConfig {
+		return admissionregistrationv1.WebhookClientConfig{
+			Service:  &admissionregistrationv1.ServiceReference{Namespace: namespace, Name: name},
+			CABundle: []byte("previous"),
+		}
+	}
+	client := fake.NewSimpleClientset(&admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "validator"},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{
+			{Name: "validate.nginx.ingress.kubernetes.io", ClientConfig: service("ingress-nginx", "validator")},
+			{Name: "other.example.com", ClientConfig: service("other", "webhook")},
+		},
+	})
+
+	n := newTestController(t, "")
+	n.cfg.Client = client
+	n.cfg.ValidationWebhookBootstrap = "validator"
+	n.cfg.ValidationWebhookService = "ingress-nginx/validator"
+	n.cfg.ValidationWebhookCASecret = "ingress-nginx/validator-ca"
+
+	now := time.Now()
+	if err := n.bootstrapWebhookCertificate(context.Background(), now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cert, err := n.getWebhookCertificate(nil)
+	if err != nil {
+		t.Fatalf("expected the generated certificate to be served, got %v", err)
+	}
+
+	vwc, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.Background(), "validator", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(vwc.Webhooks[0].ClientConfig.CABundle) {
+		t.Fatalf("expected the CA in the caBundle, got %q", vwc.Webhooks[0].ClientConfig.CABundle)
+	}
+	if _, err := cert.Leaf.Verify(x509.VerifyOptions{DNSName: "validator.ingress-nginx.svc", Roots: roots, CurrentTime: now}); err != nil {
+		t.Errorf("expected the caBundle to trust the served certificate, got %v", err)
+	}
+	if string(vwc.Webhooks[1].ClientConfig.CABundle) != "previous" {
+		t.Errorf("expected the webhook of another Service unchanged, got %q", vwc.Webhooks[1].ClientConfig.CABundle)
+	}
+
+	// the configuration must call the Service of the webhook
+	n.cfg.ValidationWebhookService = "ingress-nginx/missing"
+	err = n.bootstrapWebhookCertificate(context.Background(), now)
+	if err == nil || !strings.Contains(err.Error(), "no webhook calls Service ingress-nginx/missing") {
+		t.Errorf("expected no webhook to be patched, got %v", err)
+	}
+	if got, _ := n.getWebhookCertificate(nil); got != cert {
+		t.Errorf("expected the previous certificate to be kept")
+	}
+}
+
+func TestWatchWebhookCertificate(t *testing.T) {
+	now := time.Now()
+	certificate := func(cn string) ([]byte, []byte) {
+		_, _, certPEM, keyPEM := testCertificate(t, &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: cn},
+			NotBefore:    now.Add(-time.Hour),
+			NotAfter:     now.Add(time.Hour),
+		}, nil, nil)
+		return certPEM, keyPEM
+	}
+
+	dir := t.TempDir()
+	certPEM, keyPEM := certificate("first")
+	n := newTestController(t, "")
+	n.cfg.ValidationWebhookCertPath = writeTestFile(t, dir, "tls.crt", string(certPEM))
+	n.cfg.ValidationWebhookKeyPath = writeTestFile(t, dir, "tls.key", string(keyPEM))
+	if _, err := n.loadWebhookCertificate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := n.watchWebhookCertificate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer close(n.stopCh)
+
+	reloads, errs := webhookCertificateReloadsTotal.Value(), webhookCertificateReloadErrorsTotal.Value()
+
+	// a certificate without its key is not loaded
+	certPEM, keyPEM = certificate("second")
+	writeTestFile(t, dir, "tls.crt", string(certPEM))
+	waitFor(t, func() bool { return webhookCertificateReloadErrorsTotal.Value() > errs })
+	if cert, _ := n.getWebhookCertificate(nil); cert.Leaf.Subject.CommonName != "first" {
+		t.Errorf("expected the previous certificate to be kept, got %v", cert.Leaf.Subject.CommonName)
+	}
+
+	next := writeTestFile(t, dir, "tls.key.tmp", string(keyPEM))
+	if err := os.Rename(next, filepath.Join(dir, "tls.key")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return webhookCertificateReloadsTotal.Value() > reloads })
+	if cert, _ := n.getWebhookCertificate(nil); cert.Leaf.Subject.CommonName != "second" {
+		t.Errorf("expected the renewed certificate, got %v", cert.Leaf.Subject.CommonName)
+	}
+}
+
+// waitFor waits up to 5 seconds for done to return true
+func waitFor(t *testing.T, done func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// caBundleSize returns the number of certificates of the caBundle of the
+// webhook of the test ValidatingWebhookConfiguration
+func caBundleSize(t *testing.T, client *fake.Clientset) int {
+	t.Helper()
+
+	vwc, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.Background(), "validator", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for block, rest := pem.Decode(vwc.Webhooks[0].ClientConfig.CABundle); block != nil; block, rest = pem.Decode(rest) {
+		count++
+	}
+	return count
+}
+
+func TestBootstrapWebhookCertificateSharesCA(t *testing.T) {
+	client := fake.NewSimpleClientset(&admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "validator"},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+			Name: "validate.nginx.ingress.kubernetes.io",
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				Service: &admissionregistrationv1.ServiceReference{Namespace: "ingress-nginx", Name: "nginx-config-validator"},
+			},
+		}},
+	})
+	newReplica := func() *NGINXController {
+		return newOfflineController(&NginxConfiguration{
+			Client:                     client,
+			ValidationWebhookBootstrap: "validator",
+			ValidationWebhookService:   "ingress-nginx/nginx-config-validator",
+			ValidationWebhookCASecret:  "ingress-nginx/nginx-config-validator-ca",
+		}, newMemoryStore(""))
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	replicas := []*NGINXController{newReplica(), newReplica(), newReplica()}
+	for _, n := range replicas {
+		if err := n.bootstrapWebhookCertificate(ctx, now); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if size := caBundleSize(t, client); size != 1 {
+		t.Errorf("expected the replicas to share one CA, got %d in the caBundle", size)
+	}
+	issuer := replicas[0].webhookCertificate.Load().Leaf.Issuer.String()
+	for _, n := range replicas[1:] {
+		if got := n.webhookCertificate.Load().Leaf.Issuer.String(); got != issuer {
+			t.Errorf("expected the certificates to be issued by the same CA, got %v and %v", issuer, got)
+		}
+	}
+
+	// the CA is rotated close to its expiry, the previous CA being kept for
+	// the replicas still serving a certificate it signed
+	rotation := now.Add(webhookBootstrapValidity - webhookBootstrapRenewBefore + time.Hour)
+	for i := 0; i < 2; i++ {
+		if err := replicas[i].bootstrapWebhookCertificate(ctx, rotation); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if size := caBundleSize(t, client); size != 2 {
+		t.Errorf("expected the caBundle to contain the new and the previous CA, got %d", size)
+	}
+	if err := replicas[0].bootstrapWebhookCertificate(ctx, rotation.Add(webhookBootstrapRenewBefore)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if size := caBundleSize(t, client); size != 1 {
+		t.Errorf("expected the expired CA to be dropped, got %d", size)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// defaults of the validation limits of the webhook
+const (
+	defaultMaxConcurrentValidations = 4
+	defaultValidationQueueDepth     = 32
+	defaultValidationQueueTimeout   = 5 * time.Second
+	defaultNamespaceRateLimitBurst  = 10
+)
+
+var (
+	errValidationQueueFull    = errors.New("too many validations queued")
+	errValidationQueueTimeout = errors.New("timed out waiting for a validation slot")
+)
+
+// admissionShedTotal counts the admission requests not validated because of
+// the limits, by reason
+var admissionShedTotal = expvar.NewMap("admission_shed_total")
+
+// shedReason returns the reason of admissionShedTotal of a limiter error
+func shedReason(err error) string {
+	switch {
+	case errors.Is(err, errValidationQueueFull):
+		return "queue_full"
+	case errors.Is(err, errValidationQueueTimeout):
+		return "queue_timeout"
+	case errors.As(err, new(*namespaceRateLimitError)):
+		return "namespace_rate_limit"
+	default:
+		return "canceled"
+	}
+}
+
+// validationLimiter bounds the number of validations running in parallel and
+// the number of requests waiting for one. Every validation builds the whole
+// configuration, so running them without limit exhausts the memory of the
+// webhook under load.
+type validationLimiter struct {
+	slots   chan struct{}
+	queue   chan struct{}
+	timeout time.Duration
+}
+
+// newValidationLimiter returns a limiter, or nil when maxConcurrent is not
+// positive and validations are not limited
+func newValidationLimiter(maxConcurrent, queueDepth int, timeout time.Duration) *validationLimiter {
+	if maxConcurrent <= 0 {
+		return nil
+	}
+	if queueDepth < 0 {
+		queueDepth = 0
+	}
+
+	return &validationLimiter{
+		slots:   make(chan struct{}, maxConcurrent),
+		queue:   make(chan struct{}, maxConcurrent+queueDepth),
+		timeout: timeout,
+	}
+}
+
+// acquire waits for a validation slot. The returned function releases the
+// slot and must be called once the validation finishes.
+func (l *validationLimiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case l.queue <- struct{}{}:
+	default:
+		return nil, errValidationQueueFull
+	}
+
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return func() {
+			<-l.slots
+			<-l.queue
+		}, nil
+	case <-ctx.Done():
+		<-l.queue
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, errValidationQueueTimeout
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// wrap runs h in a validation slot, for the endpoints other than the
+// admission one running full validations. The requests not getting a slot
+// are rejected with 429 Too Many Requests.
+func (l *validationLimiter) wrap(h http.Handler) http.Handler {
+	if l == nil {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		release, err := l.acquire(r.Context())
+		if err != nil {
+			admissionShedTotal.Add(shedReason(err), 1)
+			w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(l.timeout.Seconds())))))
+			http.Error(w, fmt.Sprintf("the ingress validation webhook is overloaded: %v", err), http.StatusTooManyRequests)
+			return
+		}
+		defer release()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// namespaceRateLimitError is returned when a namespace sends admission
+// requests faster than its rate limit
+type namespaceRateLimitError struct {
+	namespace string
+}
+
+func (e *namespaceRateLimitError) Error() string {
+	return fmt.Sprintf("namespace %v exceeded its rate of validations", e.namespace)
+}
+
+// namespaceRateLimiter limits the rate of the validations of each namespace
+// with a token bucket per namespace, so a namespace sending admission
+// requests in a loop can not take the validation slots of the others
+type namespaceRateLimiter struct {
+	lock     sync.Mutex
+	qps      float32
+	burst    int
+	limiters map[string]flowcontrol.RateLimiter
+}
+
+// newNamespaceRateLimiter returns a limiter, or nil when qps is not positive
+// and namespaces are not limited
+func newNamespaceRateLimiter(qps float64, burst int) *namespaceRateLimiter {
+	if qps <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &namespaceRateLimiter{
+		qps:      float32(qps),
+		burst:    burst,
+		limiters: map[string]flowcontrol.RateLimiter{},
+	}
+}
+
+// accept takes a token of the bucket of the namespace, without waiting
+func (l *namespaceRateLimiter) accept(namespace string) error {
+	if l == nil {
+		return nil
+	}
+
+	l.lock.Lock()
+	limiter, ok := l.limiters[namespace]
+	if !ok {
+		limiter = flowcontrol.NewTokenBucketRateLimiter(l.qps, l.burst)
+		l.limiters[namespace] = limiter
+	}
+	l.lock.Unlock()
+
+	if !limiter.TryAccept() {
+		return &namespaceRateLimitError{namespace: namespace}
+	}
+	return nil
+}
+
+// retryAfter is the time for the bucket of a namespace to get a token back
+func (l *namespaceRateLimiter) retryAfter() time.Duration {
+	return time.Duration(float64(time.Second) / float64(l.qps))
+}
+
+// overloadedResponse is returned when a validation can not be started. The
+// request is admitted with a warning when allow is true, and rejected with a
+// retriable error otherwise.
+func overloadedResponse(resp *admissionv1.AdmissionResponse, err error, allow bool, retryAfter time.Duration) *admissionv1.AdmissionResponse {
+	message := fmt.Sprintf("the ingress validation webhook is overloaded: %v", err)
+
+	if allow {
+		resp.Allowed = true
+		resp.Warnings = append(resp.Warnings, message+"; the Ingress was admitted without validation")
+		return resp
+	}
+
+	resp.Allowed = false
+	resp.Result = &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusTooManyRequests,
+		Reason:  metav1.StatusReasonTooManyRequests,
+		Message: message + "; retry later",
+		Details: &metav1.StatusDetails{
+			RetryAfterSeconds: int32(math.Ceil(retryAfter.Seconds())),
+		},
+	}
+	return resp
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestValidationLimiterAcquire(t *testing.T) {
+	var unlimited *validationLimiter
+	release, err := unlimited.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("expected a nil limiter to allow every validation, got %v", err)
+	}
+	release()
+
+	limiter := newValidationLimiter(1, 1, 200*time.Millisecond)
+	release, err = limiter.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the second request waits in the queue until the timeout
+	waiting := make(chan error)
+	go func() {
+		_, err := limiter.acquire(context.Background())
+		waiting <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// the queue is full
+	if _, err := limiter.acquire(context.Background()); !errors.Is(err, errValidationQueueFull) {
+		t.Errorf("expected %v, got %v", errValidationQueueFull, err)
+	}
+	if err := <-waiting; !errors.Is(err, errValidationQueueTimeout) {
+		t.Errorf("expected %v, got %v", errValidationQueueTimeout, err)
+	}
+
+	// the slot is available again once released
+	release()
+	release, err = limiter.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("expected the released slot to be available, got %v", err)
+	}
+	release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	hold, _ := limiter.acquire(context.Background())
+	defer hold()
+	if _, err := limiter.acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestAdmissionHandlerOverload(t *testing.T) {
+	req := &admissionv1.AdmissionRequest{
+		Resource:  ingressResource,
+		Operation: admissionv1.Create,
+		Namespace: "default",
+		Object:    runtime.RawExtension{Raw: []byte(`{"metadata":{"name":"web"}}`)},
+	}
+
+	tests := []struct {
+		name            string
+		allowOnOverload bool
+	}{
+		{name: "reject", allowOnOverload: false},
+		{name: "admit", allowOnOverload: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			limiter := newValidationLimiter(1, 0, time.Millisecond)
+			release, err := limiter.acquire(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer release()
+
+			h := &admissionHandler{
+				checkIngress: func(*networking.Ingress) ([]Finding, error) {
+					t.Error("the Ingress must not be validated without a slot")
+					return nil, nil
+				},
+				limiter:         limiter,
+				allowOnOverload: tc.allowOnOverload,
+			}
+
+			resp := h.review(context.Background(), req)
+			if resp.Allowed != tc.allowOnOverload {
+				t.Errorf("expected allowed %v, got %v", tc.allowOnOverload, resp.Allowed)
+			}
+			if tc.allowOnOverload && len(resp.Warnings) != 1 {
+				t.Errorf("expected a warning, got %v", resp.Warnings)
+			}
+			if !tc.allowOnOverload && (resp.Result == nil || resp.Result.Code != http.StatusTooManyRequests || resp.Result.Details.RetryAfterSeconds < 1) {
+				t.Errorf("expected a retriable 429 error, got %v", resp.Result)
+			}
+		})
+	}
+}
+
+func TestNamespaceRateLimiter(t *testing.T) {
+	var unlimited *namespaceRateLimiter
+	if newNamespaceRateLimiter(0, 10) != nil {
+		t.Errorf("expected no limiter without a rate")
+	}
+	if err := unlimited.accept("default"); err != nil {
+		t.Errorf("expected a nil limiter to accept every request, got %v", err)
+	}
+
+	limiter := newNamespaceRateLimiter(0.5, 2)
+	for i := 0; i < 2; i++ {
+		if err := limiter.accept("default"); err != nil {
+			t.Fatalf("expected request %v to be in the burst, got %v", i, err)
+		}
+	}
+	err := limiter.accept("default")
+	var rateErr *namespaceRateLimitError
+	if !errors.As(err, &rateErr) || err.Error() != "namespace default exceeded its rate of validations" {
+		t.Errorf("expected the namespace to exceed its rate, got %v", err)
+	}
+	// the other namespaces have their own bucket
+	if err := limiter.accept("other"); err != nil {
+		t.Errorf("expected another namespace to be accepted, got %v", err)
+	}
+	if got := limiter.retryAfter(); got != 2*time.Second {
+		t.Errorf("expected to retry after 2s, got %v", got)
+	}
+
+	if limiter := newNamespaceRateLimiter(1, 0); limiter.burst != 1 {
+		t.Errorf("expected a burst of at least 1, got %v", limiter.burst)
+	}
+}
+
+func TestShedReason(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{errValidationQueueFull, "queue_full"},
+		{errValidationQueueTimeout, "queue_timeout"},
+		{&namespaceRateLimitError{namespace: "default"}, "namespace_rate_limit"},
+		{context.Canceled, "canceled"},
+	}
+	for _, tc := range tests {
+		if got := shedReason(tc.err); got != tc.expected {
+			t.Errorf("expected %q for %v, got %q", tc.expected, tc.err, got)
+		}
+	}
+}
+
+func TestAdmissionHandlerNamespaceRateLimit(t *testing.T) {
+	req := &admissionv1.AdmissionRequest{
+		Resource:  ingressResource,
+		Operation: admissionv1.Create,
+		Namespace: "default",
+		Object:    runtime.RawExtension{Raw: []byte(`{"metadata":{"name":"web","namespace":"default"}}`)},
+	}
+
+	validations := 0
+	h := &admissionHandler{
+		checkIngress: func(*networking.Ingress) ([]Finding, error) {
+			validations++
+			return nil, nil
+		},
+		namespaceLimiter: newNamespaceRateLimiter(0.5, 1),
+		// the namespaces over their rate can not skip the validation
+		allowOnOverload: true,
+	}
+
+	if resp := h.review(context.Background(), req); !resp.Allowed {
+		t.Fatalf("expected the first request to be validated, got %v", resp.Result)
+	}
+
+	shed := expvarCount(admissionShedTotal, "namespace_rate_limit")
+	resp := h.review(context.Background(), req)
+	if resp.Allowed || resp.Result == nil || resp.Result.Code != http.StatusTooManyRequests || resp.Result.Details.RetryAfterSeconds != 2 {
+		t.Errorf("expected a retriable 429 error after 2s, got %v", resp.Result)
+	}
+	if validations != 1 {
+		t.Errorf("expected one validation, got %v", validations)
+	}
+	if got := expvarCount(admissionShedTotal, "namespace_rate_limit") - shed; got != 1 {
+		t.Errorf("expected one shed request, got %v", got)
+	}
+}
+
+func TestValidationLimiterWrap(t *testing.T) {
+	limiter := newValidationLimiter(1, 0, 10*time.Millisecond)
+	handler := limiter.wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, preValidationPath, nil))
+		return rec
+	}
+
+	if rec := serve(); rec.Code != http.StatusOK {
+		t.Fatalf("expected the request to get a slot, got %d", rec.Code)
+	}
+
+	release, err := limiter.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer release()
+
+	rec := serve()
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 429 with Retry-After while the slot is taken, got %d %v", rec.Code, rec.Header())
+	}
+}
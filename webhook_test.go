@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAdmissionHandlerReview(t *testing.T) {
+	ingress, err := json.Marshal(&networking.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "web"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manyWarnings := []Finding{}
+	for i := 0; i < maxAdmissionWarnings+5; i++ {
+		manyWarnings = append(manyWarnings, Finding{Rule: fmt.Sprintf("rule-%d", i), Severity: SeverityWarning})
+	}
+
+	tests := []struct {
+		name      string
+		req       *admissionv1.AdmissionRequest
+		findings  []Finding
+		err       error
+		allowed   bool
+		warnings  int
+		checked   bool
+		namespace string
+	}{
+		{
+			name:    "other resource",
+			req:     &admissionv1.AdmissionRequest{Resource: metav1.GroupVersionResource{Version: "v1", Resource: "services"}, Operation: admissionv1.Create},
+			allowed: true,
+		},
+		{
+			name:    "delete",
+			req:     &admissionv1.AdmissionRequest{Resource: ingressResource, Operation: admissionv1.Delete},
+			allowed: true,
+		},
+		{
+			name:    "invalid object",
+			req:     &admissionv1.AdmissionRequest{Resource: ingressResource, Operation: admissionv1.Create, Object: runtime.RawExtension{Raw: []byte("{")}},
+			allowed: false,
+		},
+		{
+			name:      "valid Ingress",
+			req:       &admissionv1.AdmissionRequest{Resource: ingressResource, Operation: admissionv1.Create, Namespace: "apps", Object: runtime.RawExtension{Raw: ingress}},
+			findings:  []Finding{{Rule: "info", Severity: SeverityInfo}, {Rule: "warning", Severity: SeverityWarning}},
+			allowed:   true,
+			warnings:  1,
+			checked:   true,
+			namespace: "apps",
+		},
+		{
+			name:      "rejected Ingress",
+			req:       &admissionv1.AdmissionRequest{Resource: ingressResource, Operation: admissionv1.Update, Namespace: "apps", Object: runtime.RawExtension{Raw: ingress}},
+			findings:  manyWarnings,
+			err:       errors.New("invalid"),
+			allowed:   false,
+			warnings:  maxAdmissionWarnings,
+			checked:   true,
+			namespace: "apps",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var checked *networking.Ingress
+			h := &admissionHandler{checkIngress: func(ing *networking.Ingress) ([]Finding, error) {
+				checked = ing
+				return tc.findings, tc.err
+			}}
+
+			resp := h.review(context.Background(), tc.req)
+			if resp.Allowed != tc.allowed {
+				t.Errorf("expected allowed %v, got %v (%v)", tc.allowed, resp.Allowed, resp.Result)
+			}
+			if len(resp.Warnings) != tc.warnings {
+				t.Errorf("expected %d warnings, got %v", tc.warnings, resp.Warnings)
+			}
+			if tc.checked != (checked != nil) {
+				t.Fatalf("expected the Ingress to be checked: %v", tc.checked)
+			}
+			if checked != nil && checked.Namespace != tc.namespace {
+				t.Errorf("expected the namespace of the request %q, got %q", tc.namespace, checked.Namespace)
+			}
+		})
+	}
+}
+
+func TestCheckIngress(t *testing.T) {
+	n := newTestController(t, "")
+	n.cfg.NginxVersion = "1.13.9"
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		rejected    bool
+	}{
+		{
+			name: "valid Ingress",
+		},
+		{
+			name:        "error finding",
+			annotations: map[string]string{"nginx.ingress.kubernetes.io/backend-protocol": "H2C"},
+			rejected:    true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ing := &networking.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: tc.annotations},
+				Spec: networking.IngressSpec{
+					Rules: []networking.IngressRule{{
+						Host: "web.example.com",
+						IngressRuleValue: networking.IngressRuleValue{HTTP: &networking.HTTPIngressRuleValue{
+							Paths: []networking.HTTPIngressPath{{
+								Path:     "/",
+								PathType: &pathTypePrefix,
+								Backend: networking.IngressBackend{Service: &networking.IngressServiceBackend{
+									Name: "web", Port: networking.ServiceBackendPort{Number: 80},
+								}},
+							}},
+						}},
+					}},
+				},
+			}
+
+			_, err := n.CheckIngress(ing)
+			if tc.rejected != (err != nil) {
+				t.Errorf("expected rejected %v, got %v", tc.rejected, err)
+			}
+		})
+	}
+}
+
+func TestStop(t *testing.T) {
+	n := newTestController(t, preValidationManifests)
+	n.preValidate()
+	client := fake.NewSimpleClientset()
+	n.cfg.Client = client
+	n.cfg.ReportSink, n.cfg.ReportConfigMap = reportSinkConfigMap, "ingress-nginx/reports"
+	n.isLeader.Store(true)
+	var err error
+	if n.reports, err = n.newReportPublisher(nil); err != nil {
+		t.Fatal(err)
+	}
+	n.reports.record(&networking.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}, nil, nil)
+
+	// a review in flight when the shutdown starts
+	inFlight, release := make(chan struct{}), make(chan struct{})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.validationWebhookServer = &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(inFlight)
+		<-release
+		fmt.Fprint(w, "reviewed")
+	})}
+	go n.validationWebhookServer.Serve(listener)
+
+	response := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		response <- string(body)
+	}()
+	<-inFlight
+
+	go n.stop()
+	// a second signal does not start another shutdown
+	go n.stop()
+
+	select {
+	case <-n.shutdownDone:
+		t.Fatal("expected the shutdown to wait for the review in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	rec := httptest.NewRecorder()
+	n.readyzHandler(rec, httptest.NewRequest(http.MethodGet, readyzPath, nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "shutting down") {
+		t.Errorf("expected the webhook not to be ready while shutting down, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	close(release)
+	if body := <-response; body != "reviewed" {
+		t.Errorf("expected the review in flight to complete, got %q", body)
+	}
+	select {
+	case <-n.shutdownDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the shutdown to complete")
+	}
+
+	select {
+	case <-n.stopCh:
+	default:
+		t.Errorf("expected the background tasks to be stopped")
+	}
+	if _, err := client.CoreV1().ConfigMaps("ingress-nginx").Get(context.Background(), "reports", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the pending reports to be flushed: %v", err)
+	}
+}
+
+func TestCheckIngressConnected(t *testing.T) {
+	s := newMemoryStore(defaultConfigMapName)
+	for _, ing := range []*networking.Ingress{
+		connectedIngress("www", "www.app.example.com", "www", nil),
+		connectedIngress("unrelated", "unrelated.example.com", "unrelated", nil),
+	} {
+		if err := s.Add(ing); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n := newOfflineController(&NginxConfiguration{ConfigMapName: defaultConfigMapName}, s)
+
+	candidate := connectedIngress("app", "app.example.com", "app", map[string]string{
+		"nginx.ingress.kubernetes.io/from-to-www-redirect": "true",
+	})
+	findings, err := n.CheckIngress(candidate)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	found := false
+	for _, f := range findings {
+		found = found || f.Rule == "from-to-www-collision"
+	}
+	if !found {
+		t.Errorf("expected the Ingress defining the www host to be validated with the candidate, got %v", findings)
+	}
+}
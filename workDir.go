@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// defaultWorkDirTTL is the age after which the entries of the work
+	// directory are removed
+	defaultWorkDirTTL = 24 * time.Hour
+	// defaultWorkDirMaxBytes caps the disk usage of the work directory
+	defaultWorkDirMaxBytes = 1 << 30
+	// workDirGCInterval is the interval between collections in the webhook
+	workDirGCInterval = 5 * time.Minute
+
+	metricsPath = "/debug/vars"
+)
+
+// workDirKinds are the subdirectories of the work directory. Every entry of
+// a subdirectory, a file or a directory, is removed as a whole.
+var workDirKinds = []string{"sandboxes", "snapshots", "certs"}
+
+var (
+	workDirBytes        = expvar.NewInt("workdir_bytes")
+	workDirEntries      = expvar.NewInt("workdir_entries")
+	workDirRemovedTotal = expvar.NewInt("workdir_gc_removed_total")
+	workDirFreedTotal   = expvar.NewInt("workdir_gc_freed_bytes_total")
+)
+
+// defaultWorkDir returns the directory used when --work-dir is not set
+func defaultWorkDir() string {
+	return filepath.Join(os.TempDir(), "nginx-config-validator")
+}
+
+// workDirPath returns the subdirectory of the work directory used for kind,
+// creating it if needed
+func (n *NGINXController) workDirPath(kind string) (string, error) {
+	dir := filepath.Join(n.cfg.WorkDir, kind)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// workDirEntry is a sandbox, snapshot or certificate in the work directory
+type workDirEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// gcResult summarizes a garbage collection of the work directory
+type gcResult struct {
+	Removed    int
+	FreedBytes int64
+	UsedBytes  int64
+	Entries    int
+}
+
+// collectGarbage removes the entries of the work directory older than ttl,
+// then the oldest entries until the directory uses at most maxBytes. Zero
+// disables the corresponding limit. With dryRun nothing is removed.
+func collectGarbage(dir string, ttl time.Duration, maxBytes int64, now time.Time, dryRun bool) (gcResult, error) {
+	result := gcResult{}
+
+	entries, err := listWorkDirEntries(dir)
+	if err != nil {
+		return result, err
+	}
+
+	remove := func(e workDirEntry) error {
+		if !dryRun {
+			if err := os.RemoveAll(e.path); err != nil {
+				return err
+			}
+			klog.V(2).Infof("Removed %v (%v bytes, modified %v)", e.path, e.size, e.modTime.Format(time.RFC3339))
+		}
+		result.Removed++
+		result.FreedBytes += e.size
+		return nil
+	}
+
+	// oldest first
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+
+	var kept []workDirEntry
+	var errs []error
+	for _, e := range entries {
+		if ttl > 0 && now.Sub(e.modTime) > ttl {
+			if err := remove(e); err != nil {
+				errs = append(errs, err)
+				kept = append(kept, e)
+			}
+			continue
+		}
+		kept = append(kept, e)
+	}
+
+	var used int64
+	for _, e := range kept {
+		used += e.size
+	}
+	for len(kept) > 0 && maxBytes > 0 && used > maxBytes {
+		e := kept[0]
+		kept = kept[1:]
+		if err := remove(e); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		used -= e.size
+	}
+
+	result.UsedBytes = used
+	result.Entries = len(kept)
+	return result, errors.Join(errs...)
+}
+
+// listWorkDirEntries returns the entries of the subdirectories of the work
+// directory, with their total size and most recent modification time
+func listWorkDirEntries(dir string) ([]workDirEntry, error) {
+	var entries []workDirEntry
+	for _, kind := range workDirKinds {
+		children, err := os.ReadDir(filepath.Join(dir, kind))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, child := range children {
+			e := workDirEntry{path: filepath.Join(dir, kind, child.Name())}
+			err := filepath.WalkDir(e.path, func(_ string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				if !d.IsDir() {
+					e.size += info.Size()
+				}
+				if info.ModTime().After(e.modTime) {
+					e.modTime = info.ModTime()
+				}
+				return nil
+			})
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// collectWorkDirGarbage cleans the work directory every workDirGCInterval
+// until the stop channel is closed
+func (n *NGINXController) collectWorkDirGarbage() {
+	ticker := time.NewTicker(workDirGCInterval)
+	defer ticker.Stop()
+
+	for {
+		result, err := collectGarbage(n.cfg.WorkDir, n.cfg.WorkDirTTL, n.cfg.WorkDirMaxBytes, time.Now(), false)
+		if err != nil {
+			klog.Warningf("Error cleaning work directory %v: %v", n.cfg.WorkDir, err)
+		}
+		updateWorkDirMetrics(result)
+		if result.Removed > 0 {
+			klog.Infof("Removed %v entries from work directory %v, freeing %v bytes", result.Removed, n.cfg.WorkDir, result.FreedBytes)
+		}
+
+		select {
+		case <-n.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func updateWorkDirMetrics(result gcResult) {
+	workDirBytes.Set(result.UsedBytes)
+	workDirEntries.Set(int64(result.Entries))
+	workDirRemovedTotal.Add(int64(result.Removed))
+	workDirFreedTotal.Add(result.FreedBytes)
+}
+
+// addWorkDirFlags registers the flags configuring the work directory
+func addWorkDirFlags(fs *flag.FlagSet, cfg *NginxConfiguration) {
+	fs.StringVar(&cfg.WorkDir, "work-dir", defaultWorkDir(),
+		"Directory containing the sandboxes, rendered configuration snapshots and certificate files.")
+	fs.DurationVar(&cfg.WorkDirTTL, "work-dir-ttl", defaultWorkDirTTL,
+		"Age after which the entries of the work directory are removed. 0 disables the expiration.")
+	cfg.WorkDirMaxBytes = defaultWorkDirMaxBytes
+	fs.Func("work-dir-max-size",
+		"Maximum disk usage of the work directory (e.g. 512Mi), the oldest entries are removed first. 0 disables the cap. Defaults to 1Gi.", func(value string) error {
+			q, err := resource.ParseQuantity(value)
+			if err != nil {
+				return err
+			}
+			cfg.WorkDirMaxBytes = q.Value()
+			return nil
+		})
+}
+
+// runGC cleans the work directory once
+func runGC(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	cfg := &NginxConfiguration{}
+	addLoggingFlags(fs)
+	addWorkDirFlags(fs, cfg)
+	dryRun := fs.Bool("dry-run", false, "Print what would be removed without removing anything.")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitInternal
+	}
+
+	result, err := collectGarbage(cfg.WorkDir, cfg.WorkDirTTL, cfg.WorkDirMaxBytes, time.Now(), *dryRun)
+	verb := "removed"
+	if *dryRun {
+		verb = "would remove"
+	}
+	fmt.Fprintf(stdout, "%v %v entries (%v bytes), %v entries using %v bytes remain in %v\n",
+		verb, result.Removed, result.FreedBytes, result.Entries, result.UsedBytes, cfg.WorkDir)
+	if err != nil {
+		fmt.Fprintf(stderr, "error cleaning work directory: %v\n", err)
+		return exitInternal
+	}
+	return exitOK
+}
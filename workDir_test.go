@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeWorkDirEntry creates a file of size bytes in the subdirectory kind of
+// dir, modified at modTime
+func writeWorkDirEntry(t *testing.T, dir, kind, name string, size int, modTime time.Time) {
+	t.Helper()
+
+	path := filepath.Join(dir, kind, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// workDirNames returns the names of the entries left in the work directory
+func workDirNames(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := listWorkDirEntries(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, filepath.Base(e.path))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestCollectGarbage(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		ttl      time.Duration
+		maxBytes int64
+		dryRun   bool
+		removed  int
+		freed    int64
+		expected []string
+	}{
+		{
+			name:     "no limits",
+			expected: []string{"a", "b", "c"},
+		},
+		{
+			name:     "ttl",
+			ttl:      2 * time.Hour,
+			removed:  1,
+			freed:    100,
+			expected: []string{"b", "c"},
+		},
+		{
+			name:     "size",
+			maxBytes: 200,
+			removed:  2,
+			freed:    300,
+			expected: []string{"c"},
+		},
+		{
+			name:     "dry run",
+			ttl:      2 * time.Hour,
+			maxBytes: 200,
+			dryRun:   true,
+			removed:  2,
+			freed:    300,
+			expected: []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeWorkDirEntry(t, dir, "snapshots", "a", 100, now.Add(-3*time.Hour))
+			writeWorkDirEntry(t, dir, "certs", "b", 200, now.Add(-time.Hour))
+			writeWorkDirEntry(t, dir, "sandboxes", filepath.Join("c", "nginx.conf"), 50, now.Add(-time.Minute))
+			// the directory of the sandbox is modified when the file is created
+			if err := os.Chtimes(filepath.Join(dir, "sandboxes", "c"), now.Add(-time.Minute), now.Add(-time.Minute)); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := collectGarbage(dir, tc.ttl, tc.maxBytes, now, tc.dryRun)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Removed != tc.removed || result.FreedBytes != tc.freed {
+				t.Errorf("expected %d entries and %d bytes removed, got %+v", tc.removed, tc.freed, result)
+			}
+			if names := workDirNames(t, dir); strings.Join(names, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("expected the entries %v, got %v", tc.expected, names)
+			}
+		})
+	}
+}
+
+func TestRunGC(t *testing.T) {
+	dir := t.TempDir()
+	writeWorkDirEntry(t, dir, "snapshots", "old", 10, time.Now().Add(-48*time.Hour))
+
+	var stdout, stderr bytes.Buffer
+	if code := runGC([]string{"--work-dir", dir, "--dry-run"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %v", code, stderr.String())
+	}
+	if !strings.HasPrefix(stdout.String(), "would remove 1 entries (10 bytes)") {
+		t.Errorf("unexpected output %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := runGC([]string{"--work-dir", dir, "--work-dir-max-size", "1Ki"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %v", code, stderr.String())
+	}
+	if !strings.HasPrefix(stdout.String(), "removed 1 entries (10 bytes), 0 entries using 0 bytes remain") {
+		t.Errorf("unexpected output %q", stdout.String())
+	}
+
+	if code := runGC([]string{"--work-dir-max-size", "lots"}, &stdout, &stderr); code != exitInternal {
+		t.Errorf("expected exit code %d for an invalid size, got %d", exitInternal, code)
+	}
+}